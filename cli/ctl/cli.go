@@ -42,6 +42,7 @@ func Execute(version string) {
 	root.PersistentFlags().Uint32P("api-port", "", 30417, "deepflow-server service node port")
 	root.PersistentFlags().Uint32P("rpc-port", "", 30035, "deepflow-server service grpc port")
 	root.PersistentFlags().Uint32P("svc-port", "", 20417, "deepflow-server service http port")
+	root.PersistentFlags().Uint32P("querier-port", "", 30416, "deepflow-server querier node port")
 	root.PersistentFlags().DurationP("timeout", "", time.Second*30, "deepflow-ctl timeout")
 	root.ParseFlags(os.Args[1:])
 
@@ -67,6 +68,12 @@ func Execute(version string) {
 	root.AddCommand(RegisterPluginCommand())
 	root.AddCommand(RegisterPrometheusCommand())
 	root.AddCommand(RegisterPromQLCommand())
+	root.AddCommand(RegisterQueryCommand())
+	root.AddCommand(RegisterApplyCommand())
+	root.AddCommand(RegisterDiffCommand())
+	root.AddCommand(RegisterExportCommand())
+	root.AddCommand(RegisterPolicyCommand())
+	root.AddCommand(RegisterCustomIPTagCommand())
 
 	cmd.RegisterIngesterCommand(root)
 
@@ -177,10 +177,11 @@ func CURLResponseRawJson(method string, url string, opts ...HTTPOption) (*simple
 }
 
 type Server struct {
-	IP      string
-	Port    uint32
-	RpcPort uint32
-	SvcPort uint32
+	IP          string
+	Port        uint32
+	RpcPort     uint32
+	SvcPort     uint32
+	QuerierPort uint32
 }
 
 func GetServerInfo(cmd *cobra.Command) *Server {
@@ -188,7 +189,8 @@ func GetServerInfo(cmd *cobra.Command) *Server {
 	port, _ := cmd.Flags().GetUint32("api-port")
 	rpcPort, _ := cmd.Flags().GetUint32("rpc-port")
 	svcPort, _ := cmd.Flags().GetUint32("svc-port")
-	return &Server{ip, port, rpcPort, svcPort}
+	querierPort, _ := cmd.Flags().GetUint32("querier-port")
+	return &Server{ip, port, rpcPort, svcPort, querierPort}
 }
 
 func GetTimeout(cmd *cobra.Command) time.Duration {
@@ -0,0 +1,952 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+	"github.com/deepflowio/deepflow/cli/ctl/example"
+)
+
+const (
+	BUNDLE_KIND_AGENT_GROUP                = "AgentGroup"
+	BUNDLE_KIND_AGENT_GROUP_CONFIG         = "AgentGroupConfig"
+	BUNDLE_KIND_DOMAIN                     = "Domain"
+	BUNDLE_KIND_SUB_DOMAIN                 = "SubDomain"
+	BUNDLE_KIND_PLUGIN                     = "Plugin"
+	BUNDLE_KIND_DOMAIN_ADDITIONAL_RESOURCE = "DomainAdditionalResource"
+
+	// 附属资源是全局唯一的，固定使用该名称
+	BUNDLE_DOMAIN_ADDITIONAL_RESOURCE_NAME = "default"
+
+	DEFAULT_AGENT_GROUP_ID = 1
+	DEFAULT_DOMAIN_LCUUID  = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+)
+
+// bundleKinds 按依赖顺序排列，apply 时顺序创建/更新，prune 时逆序删除
+var bundleKinds = []string{
+	BUNDLE_KIND_DOMAIN,
+	BUNDLE_KIND_SUB_DOMAIN,
+	BUNDLE_KIND_AGENT_GROUP,
+	BUNDLE_KIND_AGENT_GROUP_CONFIG,
+	BUNDLE_KIND_PLUGIN,
+	BUNDLE_KIND_DOMAIN_ADDITIONAL_RESOURCE,
+}
+
+func bundleKindOrder(kind string) int {
+	for i, k := range bundleKinds {
+		if k == kind {
+			return i
+		}
+	}
+	return len(bundleKinds)
+}
+
+// bundleObject 是 bundle 文件中的一个 yaml 文档，例如:
+//
+//	kind: Domain
+//	name: k8s-prod
+//	spec:
+//	  type: kubernetes
+//	  config: {...}
+type bundleObject struct {
+	Kind string                 `json:"kind"`
+	Name string                 `json:"name"`
+	Spec map[string]interface{} `json:"spec,omitempty"`
+
+	// 以下字段不输出到 yaml
+	dir    string // 所在文件的目录，用于解析 Plugin 镜像的相对路径
+	lcuuid string // 服务端对象的 lcuuid
+}
+
+func (o *bundleObject) key() string {
+	return o.Kind + "/" + o.Name
+}
+
+func sortBundleObjects(objects []*bundleObject) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].Kind != objects[j].Kind {
+			return bundleKindOrder(objects[i].Kind) < bundleKindOrder(objects[j].Kind)
+		}
+		return objects[i].Name < objects[j].Name
+	})
+}
+
+func RegisterApplyCommand() *cobra.Command {
+	var filename string
+	var dryRun, prune, showExample bool
+	apply := &cobra.Command{
+		Use:   "apply -f <filename|dir>",
+		Short: "apply a bundle of agent-group, agent-group-config, domain, sub-domain, plugin and domain-additional-resource",
+		Example: "deepflow-ctl apply -f deepflow-config/\n" +
+			"deepflow-ctl apply -f deepflow-config/ --prune --dry-run",
+		Run: func(cmd *cobra.Command, args []string) {
+			if showExample {
+				fmt.Printf(string(example.YamlConfigBundle))
+				return
+			}
+			if err := applyBundle(cmd, filename, dryRun, prune); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	apply.Flags().StringVarP(&filename, "filename", "f", "", "bundle file or directory, multiple yaml documents are separated by '---'")
+	apply.Flags().BoolVar(&dryRun, "dry-run", false, "only validate the bundle by server and print the changes")
+	apply.Flags().BoolVar(&prune, "prune", false, "delete objects of the kinds in bundle which are not declared in bundle")
+	apply.Flags().BoolVar(&showExample, "example", false, "show example bundle")
+	return apply
+}
+
+func RegisterDiffCommand() *cobra.Command {
+	var filename string
+	var prune bool
+	diff := &cobra.Command{
+		Use:     "diff -f <filename|dir>",
+		Short:   "diff a bundle against current configuration of deepflow-server",
+		Example: "deepflow-ctl diff -f deepflow-config/ --prune",
+		Run: func(cmd *cobra.Command, args []string) {
+			changed, err := diffBundle(cmd, filename, prune)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			// 与 diff 命令一致，有差异时返回 1
+			if changed {
+				os.Exit(1)
+			}
+		},
+	}
+	diff.Flags().StringVarP(&filename, "filename", "f", "", "bundle file or directory, multiple yaml documents are separated by '---'")
+	diff.Flags().BoolVar(&prune, "prune", false, "show objects which will be deleted by 'apply --prune'")
+	return diff
+}
+
+func RegisterExportCommand() *cobra.Command {
+	var kinds []string
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "export current configuration of deepflow-server as a bundle",
+		Example: "deepflow-ctl export > deepflow-config.yaml\n" +
+			"deepflow-ctl export --kind AgentGroup --kind AgentGroupConfig",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := exportBundle(cmd, kinds); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	export.Flags().StringArrayVar(&kinds, "kind", nil, fmt.Sprintf("kinds to export, default: all, support: %s", strings.Join(bundleKinds, ", ")))
+	return export
+}
+
+var bundleDocumentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// loadBundle 读取文件或目录（递归读取 .yaml/.yml 文件，按路径排序）中的所有对象
+func loadBundle(filename string) ([]*bundleObject, error) {
+	if filename == "" {
+		return nil, errors.New("must specify filename with -f")
+	}
+	var files []string
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		err = filepath.Walk(filename, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(path)
+			if !info.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	} else {
+		files = []string{filename}
+	}
+
+	var objects []*bundleObject
+	keys := make(map[string]string)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for i, doc := range bundleDocumentSeparator.Split(string(content), -1) {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			object := &bundleObject{dir: filepath.Dir(file)}
+			if err = yaml.UnmarshalStrict([]byte(doc), object); err != nil {
+				return nil, fmt.Errorf("parse %s document %d failed: %v", file, i, err)
+			}
+			if err = checkBundleObject(object); err != nil {
+				return nil, fmt.Errorf("%s document %d: %v", file, i, err)
+			}
+			if prev, ok := keys[object.key()]; ok {
+				return nil, fmt.Errorf("%s declared in both %s and %s", object.key(), prev, file)
+			}
+			keys[object.key()] = file
+			objects = append(objects, object)
+		}
+	}
+	sortBundleObjects(objects)
+	return objects, nil
+}
+
+func checkBundleObject(object *bundleObject) error {
+	if bundleKindOrder(object.Kind) == len(bundleKinds) {
+		return fmt.Errorf("unknown kind (%s), support: %s", object.Kind, strings.Join(bundleKinds, ", "))
+	}
+	if object.Kind == BUNDLE_KIND_DOMAIN_ADDITIONAL_RESOURCE && object.Name == "" {
+		object.Name = BUNDLE_DOMAIN_ADDITIONAL_RESOURCE_NAME
+	}
+	if object.Name == "" {
+		return fmt.Errorf("name of %s is required", object.Kind)
+	}
+	if object.Kind == BUNDLE_KIND_DOMAIN_ADDITIONAL_RESOURCE && object.Name != BUNDLE_DOMAIN_ADDITIONAL_RESOURCE_NAME {
+		return fmt.Errorf("name of %s must be %s", object.Kind, BUNDLE_DOMAIN_ADDITIONAL_RESOURCE_NAME)
+	}
+	if object.Spec == nil {
+		object.Spec = map[string]interface{}{}
+	}
+	return nil
+}
+
+func upperKeys(spec map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(spec))
+	for k, v := range spec {
+		result[strings.ToUpper(k)] = v
+	}
+	return result
+}
+
+// toAPISpec 将 bundle 中的 spec 转换为资源创建接口的请求体
+func toAPISpec(object *bundleObject) (map[string]interface{}, error) {
+	switch object.Kind {
+	case BUNDLE_KIND_AGENT_GROUP:
+		spec := upperKeys(object.Spec)
+		spec["NAME"] = object.Name
+		return spec, nil
+	case BUNDLE_KIND_DOMAIN:
+		spec := upperKeys(object.Spec)
+		spec["NAME"] = object.Name
+		domainTypeStr, ok := spec["TYPE"].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: spec.type must specify as string", object.key())
+		}
+		domainType := common.GetDomainTypeByName(domainTypeStr)
+		if domainType == common.DOMAIN_TYPE_UNKNOWN {
+			return nil, fmt.Errorf("%s: domain type (%s) not supported", object.key(), domainTypeStr)
+		}
+		spec["TYPE"] = int(domainType)
+		return spec, nil
+	case BUNDLE_KIND_SUB_DOMAIN:
+		spec := upperKeys(object.Spec)
+		spec["NAME"] = object.Name
+		return spec, nil
+	case BUNDLE_KIND_PLUGIN:
+		spec := upperKeys(object.Spec)
+		pluginType, err := getPluginTypeByName(spec["TYPE"])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", object.key(), err)
+		}
+		spec["TYPE"] = pluginType
+		return spec, nil
+	default:
+		// AgentGroupConfig/DomainAdditionalResource 与原有 yaml 格式保持一致
+		return object.Spec, nil
+	}
+}
+
+func getPluginTypeByName(t interface{}) (int, error) {
+	switch t {
+	case "wasm":
+		return 1, nil
+	case "so":
+		return 2, nil
+	}
+	return 0, fmt.Errorf("unknown plugin type %v, support: wasm, so", t)
+}
+
+func getPluginTypeName(t int) string {
+	switch t {
+	case 1:
+		return "wasm"
+	case 2:
+		return "so"
+	}
+	return fmt.Sprintf("%d", t)
+}
+
+// dryRunBundle 请求服务端校验 bundle，不修改任何配置
+func dryRunBundle(cmd *cobra.Command, objects []*bundleObject) error {
+	apiObjects := make([]map[string]interface{}, 0, len(objects))
+	for _, object := range objects {
+		spec, err := toAPISpec(object)
+		if err != nil {
+			return err
+		}
+		apiObjects = append(apiObjects, map[string]interface{}{
+			"KIND": object.Kind,
+			"NAME": object.Name,
+			"SPEC": spec,
+		})
+	}
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/config-bundle/dry-run/", server.IP, server.Port)
+	response, err := common.CURLPerform("POST", url, map[string]interface{}{"OBJECTS": apiObjects}, "",
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for i := range response.Get("DATA").MustArray() {
+		result := response.Get("DATA").GetIndex(i)
+		if msg := result.Get("ERROR").MustString(); msg != "" {
+			failed = append(failed, fmt.Sprintf("  %s/%s: %s", result.Get("KIND").MustString(), result.Get("NAME").MustString(), msg))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("bundle validation failed:\n%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// fetchCurrentObjects 获取服务端对应类型的当前配置，转换为 bundle 格式
+func fetchCurrentObjects(cmd *cobra.Command, kinds []string) ([]*bundleObject, error) {
+	var objects []*bundleObject
+	for _, kind := range kinds {
+		var items []*bundleObject
+		var err error
+		switch kind {
+		case BUNDLE_KIND_AGENT_GROUP:
+			items, err = fetchAgentGroups(cmd)
+		case BUNDLE_KIND_AGENT_GROUP_CONFIG:
+			items, err = fetchAgentGroupConfigs(cmd)
+		case BUNDLE_KIND_DOMAIN:
+			items, err = fetchDomains(cmd)
+		case BUNDLE_KIND_SUB_DOMAIN:
+			items, err = fetchSubDomains(cmd)
+		case BUNDLE_KIND_PLUGIN:
+			items, err = fetchPlugins(cmd)
+		case BUNDLE_KIND_DOMAIN_ADDITIONAL_RESOURCE:
+			items, err = fetchDomainAdditionalResource(cmd)
+		default:
+			err = fmt.Errorf("unknown kind (%s), support: %s", kind, strings.Join(bundleKinds, ", "))
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, items...)
+	}
+	sortBundleObjects(objects)
+	return objects, nil
+}
+
+func getControllerData(cmd *cobra.Command, path string) (*bundleResponse, error) {
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d%s", server.IP, server.Port, path)
+	response, err := common.CURLPerform("GET", url, nil, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return nil, err
+	}
+	b, err := response.Get("DATA").MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return &bundleResponse{data: b}, nil
+}
+
+type bundleResponse struct {
+	data []byte
+}
+
+func (r *bundleResponse) decode(v interface{}) error {
+	return yaml.Unmarshal(r.data, v)
+}
+
+func fetchAgentGroups(cmd *cobra.Command) ([]*bundleObject, error) {
+	response, err := getControllerData(cmd, "/v1/vtap-groups/")
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		ID        int    `json:"ID"`
+		Name      string `json:"NAME"`
+		ShortUUID string `json:"SHORT_UUID"`
+		Lcuuid    string `json:"LCUUID"`
+	}
+	if err = response.decode(&groups); err != nil {
+		return nil, err
+	}
+	objects := make([]*bundleObject, 0, len(groups))
+	for _, g := range groups {
+		// 默认采集器组由服务端维护
+		if g.ID == DEFAULT_AGENT_GROUP_ID {
+			continue
+		}
+		objects = append(objects, &bundleObject{
+			Kind:   BUNDLE_KIND_AGENT_GROUP,
+			Name:   g.Name,
+			Spec:   map[string]interface{}{"group_id": g.ShortUUID},
+			lcuuid: g.Lcuuid,
+		})
+	}
+	return objects, nil
+}
+
+func fetchAgentGroupConfigs(cmd *cobra.Command) ([]*bundleObject, error) {
+	response, err := getControllerData(cmd, "/v1/vtap-group-configuration/advanced/")
+	if err != nil {
+		return nil, err
+	}
+	var configs []string
+	if err = response.decode(&configs); err != nil {
+		return nil, err
+	}
+	objects := make([]*bundleObject, 0, len(configs))
+	for _, c := range configs {
+		spec := map[string]interface{}{}
+		if err = yaml.Unmarshal([]byte(c), &spec); err != nil {
+			return nil, err
+		}
+		groupID, _ := spec["vtap_group_id"].(string)
+		if groupID == "" {
+			continue
+		}
+		delete(spec, "vtap_group_id")
+		objects = append(objects, &bundleObject{
+			Kind: BUNDLE_KIND_AGENT_GROUP_CONFIG,
+			Name: groupID,
+			Spec: spec,
+		})
+	}
+	return objects, nil
+}
+
+func fetchDomains(cmd *cobra.Command) ([]*bundleObject, error) {
+	response, err := getControllerData(cmd, "/v2/domains/")
+	if err != nil {
+		return nil, err
+	}
+	var domains []struct {
+		Name         string                 `json:"NAME"`
+		ClusterID    string                 `json:"CLUSTER_ID"`
+		Type         int                    `json:"TYPE"`
+		ControllerIP string                 `json:"CONTROLLER_IP"`
+		IconID       int                    `json:"ICON_ID"`
+		Config       map[string]interface{} `json:"CONFIG"`
+		Lcuuid       string                 `json:"LCUUID"`
+	}
+	if err = response.decode(&domains); err != nil {
+		return nil, err
+	}
+	objects := make([]*bundleObject, 0, len(domains))
+	for _, d := range domains {
+		if d.Lcuuid == DEFAULT_DOMAIN_LCUUID {
+			continue
+		}
+		spec := map[string]interface{}{
+			"type":          common.DomainType(d.Type).String(),
+			"controller_ip": d.ControllerIP,
+			"icon_id":       float64(d.IconID),
+			"config":        d.Config,
+		}
+		if d.ClusterID != "" && common.DomainType(d.Type) == common.DOMAIN_TYPE_KUBERNETES {
+			spec["kubernetes_cluster_id"] = d.ClusterID
+		}
+		objects = append(objects, &bundleObject{
+			Kind:   BUNDLE_KIND_DOMAIN,
+			Name:   d.Name,
+			Spec:   spec,
+			lcuuid: d.Lcuuid,
+		})
+	}
+	return objects, nil
+}
+
+func fetchSubDomains(cmd *cobra.Command) ([]*bundleObject, error) {
+	response, err := getControllerData(cmd, "/v2/sub-domains/")
+	if err != nil {
+		return nil, err
+	}
+	var subDomains []struct {
+		Name       string                 `json:"NAME"`
+		DomainName string                 `json:"DOMAIN_NAME"`
+		Config     map[string]interface{} `json:"CONFIG"`
+		Lcuuid     string                 `json:"LCUUID"`
+	}
+	if err = response.decode(&subDomains); err != nil {
+		return nil, err
+	}
+	objects := make([]*bundleObject, 0, len(subDomains))
+	for _, s := range subDomains {
+		objects = append(objects, &bundleObject{
+			Kind:   BUNDLE_KIND_SUB_DOMAIN,
+			Name:   s.Name,
+			Spec:   map[string]interface{}{"domain": s.DomainName, "config": s.Config},
+			lcuuid: s.Lcuuid,
+		})
+	}
+	return objects, nil
+}
+
+func fetchPlugins(cmd *cobra.Command) ([]*bundleObject, error) {
+	response, err := getControllerData(cmd, "/v1/plugin/")
+	if err != nil {
+		return nil, err
+	}
+	var plugins []struct {
+		Name string `json:"NAME"`
+		Type int    `json:"TYPE"`
+	}
+	if err = response.decode(&plugins); err != nil {
+		return nil, err
+	}
+	objects := make([]*bundleObject, 0, len(plugins))
+	for _, p := range plugins {
+		// 服务端不返回插件镜像，导出后需要手动补充 image 文件路径
+		objects = append(objects, &bundleObject{
+			Kind: BUNDLE_KIND_PLUGIN,
+			Name: p.Name,
+			Spec: map[string]interface{}{"type": getPluginTypeName(p.Type)},
+		})
+	}
+	return objects, nil
+}
+
+func fetchDomainAdditionalResource(cmd *cobra.Command) ([]*bundleObject, error) {
+	response, err := getControllerData(cmd, "/v1/domain-additional-resources/advanced/")
+	if err != nil {
+		return nil, err
+	}
+	var content string
+	if err = response.decode(&content); err != nil {
+		return nil, err
+	}
+	spec := map[string]interface{}{}
+	if err = yaml.Unmarshal([]byte(content), &spec); err != nil {
+		return nil, err
+	}
+	if len(spec) == 0 {
+		return nil, nil
+	}
+	return []*bundleObject{{
+		Kind: BUNDLE_KIND_DOMAIN_ADDITIONAL_RESOURCE,
+		Name: BUNDLE_DOMAIN_ADDITIONAL_RESOURCE_NAME,
+		Spec: spec,
+	}}, nil
+}
+
+func exportBundle(cmd *cobra.Command, kinds []string) error {
+	if len(kinds) == 0 {
+		kinds = bundleKinds
+	}
+	objects, err := fetchCurrentObjects(cmd, kinds)
+	if err != nil {
+		return err
+	}
+	for i, object := range objects {
+		b, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Print(string(b))
+	}
+	return nil
+}
+
+const (
+	BUNDLE_ACTION_CREATE = "create"
+	BUNDLE_ACTION_UPDATE = "update"
+	BUNDLE_ACTION_DELETE = "delete"
+)
+
+type bundleChange struct {
+	action  string
+	desired *bundleObject
+	current *bundleObject
+}
+
+func (c *bundleChange) object() *bundleObject {
+	if c.desired != nil {
+		return c.desired
+	}
+	return c.current
+}
+
+// planBundle 对比期望配置与当前配置，prune 只删除 bundle 中出现的类型的对象
+func planBundle(cmd *cobra.Command, desired []*bundleObject, prune bool) ([]*bundleChange, error) {
+	var kinds []string
+	kindSet := make(map[string]bool)
+	for _, object := range desired {
+		if !kindSet[object.Kind] {
+			kindSet[object.Kind] = true
+			kinds = append(kinds, object.Kind)
+		}
+	}
+	current, err := fetchCurrentObjects(cmd, kinds)
+	if err != nil {
+		return nil, err
+	}
+	return compareBundleObjects(desired, current, prune), nil
+}
+
+// compareBundleObjects 对比期望配置与服务端当前配置，prune 时删除 bundle 中不存在的对象
+func compareBundleObjects(desired, current []*bundleObject, prune bool) []*bundleChange {
+	currentMap := make(map[string]*bundleObject, len(current))
+	for _, object := range current {
+		currentMap[object.key()] = object
+	}
+
+	var changes []*bundleChange
+	desiredKeys := make(map[string]bool, len(desired))
+	for _, object := range desired {
+		desiredKeys[object.key()] = true
+		cur, ok := currentMap[object.key()]
+		if !ok {
+			changes = append(changes, &bundleChange{action: BUNDLE_ACTION_CREATE, desired: object})
+		} else if !bundleSpecEqual(object, cur) {
+			changes = append(changes, &bundleChange{action: BUNDLE_ACTION_UPDATE, desired: object, current: cur})
+		} else {
+			// 后续操作需要使用服务端 lcuuid
+			object.lcuuid = cur.lcuuid
+		}
+	}
+	if prune {
+		for i := len(current) - 1; i >= 0; i-- {
+			if !desiredKeys[current[i].key()] {
+				changes = append(changes, &bundleChange{action: BUNDLE_ACTION_DELETE, current: current[i]})
+			}
+		}
+	}
+	return changes
+}
+
+// bundleSpecEqual 比较 spec，Domain/SubDomain 的 config 只比较 bundle 中声明的字段，
+// 因为服务端会补充默认值，且密码等敏感字段返回时被隐藏
+func bundleSpecEqual(desired, current *bundleObject) bool {
+	switch desired.Kind {
+	case BUNDLE_KIND_AGENT_GROUP:
+		groupID, _ := desired.Spec["group_id"].(string)
+		return groupID == "" || groupID == current.Spec["group_id"]
+	case BUNDLE_KIND_PLUGIN:
+		// 无法获取服务端插件镜像，只比较类型，需要更新镜像时删除后重新 apply
+		return desired.Spec["type"] == current.Spec["type"]
+	case BUNDLE_KIND_DOMAIN, BUNDLE_KIND_SUB_DOMAIN:
+		return specContains(normalizeSpec(desired.Spec), normalizeSpec(current.Spec))
+	default:
+		return reflect.DeepEqual(normalizeSpec(desired.Spec), normalizeSpec(current.Spec))
+	}
+}
+
+// normalizeSpec 通过 yaml 序列化统一数值等类型
+func normalizeSpec(spec map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	b, err := yaml.Marshal(spec)
+	if err != nil {
+		return spec
+	}
+	if err = yaml.Unmarshal(b, &result); err != nil {
+		return spec
+	}
+	return result
+}
+
+func specContains(desired, current interface{}) bool {
+	if s, ok := current.(string); ok && s == common.DEFAULT_ENCRYPTION_PASSWORD {
+		return true
+	}
+	desiredMap, ok := desired.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(desired, current)
+	}
+	currentMap, ok := current.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for k, v := range desiredMap {
+		if !specContains(v, currentMap[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+func printBundleChanges(changes []*bundleChange) error {
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+		return nil
+	}
+	for _, change := range changes {
+		object := change.object()
+		switch change.action {
+		case BUNDLE_ACTION_CREATE:
+			fmt.Printf("+ %s (create)\n", object.key())
+		case BUNDLE_ACTION_DELETE:
+			fmt.Printf("- %s (delete)\n", object.key())
+		case BUNDLE_ACTION_UPDATE:
+			fmt.Printf("~ %s (update)\n", object.key())
+			currentYaml, err := yaml.Marshal(change.current.Spec)
+			if err != nil {
+				return err
+			}
+			desiredYaml, err := yaml.Marshal(change.desired.Spec)
+			if err != nil {
+				return err
+			}
+			for _, line := range diffLines(string(currentYaml), string(desiredYaml)) {
+				fmt.Println("    " + line)
+			}
+		}
+	}
+	return nil
+}
+
+// diffLines 基于最长公共子序列输出逐行差异，只输出变化的行
+func diffLines(a, b string) []string {
+	x := strings.Split(strings.TrimRight(a, "\n"), "\n")
+	y := strings.Split(strings.TrimRight(b, "\n"), "\n")
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var result []string
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, "- "+x[i])
+			i++
+		default:
+			result = append(result, "+ "+y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		result = append(result, "- "+x[i])
+	}
+	for ; j < len(y); j++ {
+		result = append(result, "+ "+y[j])
+	}
+	return result
+}
+
+func diffBundle(cmd *cobra.Command, filename string, prune bool) (bool, error) {
+	objects, err := loadBundle(filename)
+	if err != nil {
+		return false, err
+	}
+	changes, err := planBundle(cmd, objects, prune)
+	if err != nil {
+		return false, err
+	}
+	return len(changes) > 0, printBundleChanges(changes)
+}
+
+func applyBundle(cmd *cobra.Command, filename string, dryRun, prune bool) error {
+	objects, err := loadBundle(filename)
+	if err != nil {
+		return err
+	}
+	if err = dryRunBundle(cmd, objects); err != nil {
+		return err
+	}
+	changes, err := planBundle(cmd, objects, prune)
+	if err != nil {
+		return err
+	}
+	if err = printBundleChanges(changes); err != nil {
+		return err
+	}
+	if dryRun || len(changes) == 0 {
+		return nil
+	}
+
+	// planBundle 已按照依赖顺序排列：先创建/更新，再逆序删除
+	for _, change := range changes {
+		err = applyBundleChange(cmd, change)
+		if errors.Is(err, errBundleChangeSkipped) {
+			fmt.Printf("%s unchanged, %v\n", change.object().key(), err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s failed: %v", change.action, change.object().key(), err)
+		}
+		fmt.Printf("%s %sd\n", change.object().key(), change.action)
+	}
+	return nil
+}
+
+// errBundleChangeSkipped 变更无法通过接口执行，对象保持不变
+var errBundleChangeSkipped = errors.New("change skipped")
+
+func applyBundleChange(cmd *cobra.Command, change *bundleChange) error {
+	server := common.GetServerInfo(cmd)
+	opts := []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}
+	object := change.object()
+	var spec map[string]interface{}
+	if change.desired != nil {
+		var err error
+		if spec, err = toAPISpec(change.desired); err != nil {
+			return err
+		}
+	}
+
+	var url, method string
+	var body map[string]interface{}
+	var strBody string
+	switch object.Kind {
+	case BUNDLE_KIND_AGENT_GROUP:
+		switch change.action {
+		case BUNDLE_ACTION_CREATE:
+			method, url, body = "POST", "/v1/vtap-groups/", spec
+		case BUNDLE_ACTION_DELETE:
+			method, url = "DELETE", fmt.Sprintf("/v1/vtap-groups/%s/", object.lcuuid)
+		default:
+			// 采集器组只有 id 可以声明且不可修改，服务端 dry-run 会拒绝此类变更
+			return fmt.Errorf("%w: agent group id can not be changed", errBundleChangeSkipped)
+		}
+	case BUNDLE_KIND_AGENT_GROUP_CONFIG:
+		switch change.action {
+		case BUNDLE_ACTION_CREATE, BUNDLE_ACTION_UPDATE:
+			configSpec := make(map[string]interface{}, len(spec)+1)
+			for k, v := range spec {
+				configSpec[k] = v
+			}
+			configSpec["vtap_group_id"] = object.Name
+			b, err := yaml.Marshal(configSpec)
+			if err != nil {
+				return err
+			}
+			strBody = string(b)
+			method, url = "POST", "/v1/vtap-group-configuration/advanced/"
+			if change.action == BUNDLE_ACTION_UPDATE {
+				lcuuid, err := getAgentGroupConfigLcuuid(cmd, object.Name)
+				if err != nil {
+					return err
+				}
+				method, url = "PATCH", fmt.Sprintf("/v1/vtap-group-configuration/advanced/%s/", lcuuid)
+			}
+		case BUNDLE_ACTION_DELETE:
+			method, url = "DELETE", fmt.Sprintf("/v1/vtap-group-configuration/filter/?vtap_group_id=%s", object.Name)
+		}
+	case BUNDLE_KIND_DOMAIN:
+		switch change.action {
+		case BUNDLE_ACTION_CREATE:
+			method, url, body = "POST", "/v1/domains/", spec
+		case BUNDLE_ACTION_UPDATE:
+			// 只允许更新 config 等字段，与 domain update 保持一致
+			delete(spec, "KUBERNETES_CLUSTER_ID")
+			method, url, body = "PATCH", fmt.Sprintf("/v1/domains/%s/", change.current.lcuuid), spec
+		case BUNDLE_ACTION_DELETE:
+			method, url = "DELETE", fmt.Sprintf("/v1/domains/%s/", object.lcuuid)
+		}
+	case BUNDLE_KIND_SUB_DOMAIN:
+		switch change.action {
+		case BUNDLE_ACTION_CREATE:
+			domainName, _ := spec["DOMAIN"].(string)
+			domainLcuuid, err := getLcuuidByDomainName(cmd, domainName, nil)
+			if err != nil {
+				return err
+			}
+			spec["DOMAIN"] = domainLcuuid
+			method, url, body = "POST", "/v2/sub-domains/", spec
+		case BUNDLE_ACTION_UPDATE:
+			method, url = "PATCH", fmt.Sprintf("/v2/sub-domains/%s/", change.current.lcuuid)
+			body = map[string]interface{}{"CONFIG": spec["CONFIG"]}
+		case BUNDLE_ACTION_DELETE:
+			method, url = "DELETE", fmt.Sprintf("/v2/sub-domains/%s/", object.lcuuid)
+		}
+	case BUNDLE_KIND_PLUGIN:
+		switch change.action {
+		case BUNDLE_ACTION_CREATE, BUNDLE_ACTION_UPDATE:
+			image, _ := object.Spec["image"].(string)
+			if image == "" {
+				return errors.New("spec.image is required")
+			}
+			if !filepath.IsAbs(image) {
+				image = filepath.Join(object.dir, image)
+			}
+			pluginType, _ := object.Spec["type"].(string)
+			return createPlugin(cmd, pluginType, image, object.Name)
+		case BUNDLE_ACTION_DELETE:
+			method, url = "DELETE", fmt.Sprintf("/v1/plugin/%s/", object.Name)
+		}
+	case BUNDLE_KIND_DOMAIN_ADDITIONAL_RESOURCE:
+		method, url, body = "PUT", "/v1/domain-additional-resources/", spec
+		if change.action == BUNDLE_ACTION_DELETE {
+			body = map[string]interface{}{}
+		}
+	}
+
+	_, err := common.CURLPerform(method, fmt.Sprintf("http://%s:%d%s", server.IP, server.Port, url), body, strBody, opts...)
+	return err
+}
+
+func getAgentGroupConfigLcuuid(cmd *cobra.Command, agentGroupID string) (string, error) {
+	response, err := getControllerData(cmd, fmt.Sprintf("/v1/vtap-group-configuration/?vtap_group_id=%s", agentGroupID))
+	if err != nil {
+		return "", err
+	}
+	var configs []struct {
+		Lcuuid string `json:"LCUUID"`
+	}
+	if err = response.decode(&configs); err != nil {
+		return "", err
+	}
+	if len(configs) == 0 {
+		return "", fmt.Errorf("agent-group (%s) config not exist", agentGroupID)
+	}
+	return configs[0].Lcuuid, nil
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+)
+
+func newAgentGroup(name, groupID, lcuuid string) *bundleObject {
+	return &bundleObject{
+		Kind:   BUNDLE_KIND_AGENT_GROUP,
+		Name:   name,
+		Spec:   map[string]interface{}{"group_id": groupID},
+		lcuuid: lcuuid,
+	}
+}
+
+func newAgentGroupConfig(groupID string, maxCPUs int) *bundleObject {
+	return &bundleObject{
+		Kind: BUNDLE_KIND_AGENT_GROUP_CONFIG,
+		Name: groupID,
+		Spec: map[string]interface{}{"max_cpus": maxCPUs},
+	}
+}
+
+func TestPlanBundle(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired []*bundleObject
+		current []*bundleObject
+		prune   bool
+		want    []string // action key
+	}{
+		{
+			name:    "unchanged",
+			desired: []*bundleObject{newAgentGroup("g1", "g-1", ""), newAgentGroupConfig("g-1", 1)},
+			current: []*bundleObject{newAgentGroup("g1", "g-1", "lcuuid-1"), newAgentGroupConfig("g-1", 1)},
+		},
+		{
+			name:    "unchanged without group id",
+			desired: []*bundleObject{newAgentGroup("g1", "", "")},
+			current: []*bundleObject{newAgentGroup("g1", "g-1", "lcuuid-1")},
+		},
+		{
+			name:    "changed",
+			desired: []*bundleObject{newAgentGroup("g1", "g-1", ""), newAgentGroupConfig("g-1", 2)},
+			current: []*bundleObject{newAgentGroup("g1", "g-1", "lcuuid-1"), newAgentGroupConfig("g-1", 1)},
+			want:    []string{"update AgentGroupConfig/g-1"},
+		},
+		{
+			name:    "created",
+			desired: []*bundleObject{newAgentGroup("g1", "g-1", ""), newAgentGroup("g2", "g-2", "")},
+			current: []*bundleObject{newAgentGroup("g1", "g-1", "lcuuid-1")},
+			want:    []string{"create AgentGroup/g2"},
+		},
+		{
+			name:    "removed without prune",
+			desired: []*bundleObject{newAgentGroup("g1", "g-1", "")},
+			current: []*bundleObject{newAgentGroup("g1", "g-1", "lcuuid-1"), newAgentGroup("g2", "g-2", "lcuuid-2")},
+		},
+		{
+			name:    "removed",
+			desired: []*bundleObject{newAgentGroup("g1", "g-1", "")},
+			current: []*bundleObject{
+				newAgentGroup("g1", "g-1", "lcuuid-1"), newAgentGroup("g2", "g-2", "lcuuid-2"),
+				newAgentGroupConfig("g-1", 1), newAgentGroupConfig("g-2", 1),
+			},
+			prune: true,
+			// 逆序删除，先删除依赖采集器组的配置
+			want: []string{"delete AgentGroupConfig/g-2", "delete AgentGroupConfig/g-1", "delete AgentGroup/g2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, change := range compareBundleObjects(tt.desired, tt.current, tt.prune) {
+				got = append(got, change.action+" "+change.object().key())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanBundleUsesServerLcuuid(t *testing.T) {
+	desired := newAgentGroup("g1", "g-1", "")
+	compareBundleObjects([]*bundleObject{desired}, []*bundleObject{newAgentGroup("g1", "g-1", "lcuuid-1")}, false)
+	if desired.lcuuid != "lcuuid-1" {
+		t.Errorf("lcuuid of unchanged object = %s, want lcuuid-1", desired.lcuuid)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "unchanged",
+			a:    "a: 1\nb: 2\n",
+			b:    "a: 1\nb: 2\n",
+		},
+		{
+			name: "changed",
+			a:    "a: 1\nb: 2\nc: 3\n",
+			b:    "a: 1\nb: 4\nc: 3\n",
+			want: []string{"- b: 2", "+ b: 4"},
+		},
+		{
+			name: "added",
+			a:    "a: 1\n",
+			b:    "a: 1\nb: 2\n",
+			want: []string{"+ b: 2"},
+		},
+		{
+			name: "removed",
+			a:    "a: 1\nb: 2\nc: 3\n",
+			b:    "a: 1\nc: 3\n",
+			want: []string{"- b: 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecContains(t *testing.T) {
+	current := map[string]interface{}{
+		"type": "kubernetes",
+		"config": map[string]interface{}{
+			"region_uuid":                "ffffffff-ffff-ffff-ffff-ffffffffffff",
+			"secret_key":                 common.DEFAULT_ENCRYPTION_PASSWORD,
+			"pod_net_ipv4_cidr_max_mask": 16,
+		},
+	}
+	tests := []struct {
+		name    string
+		desired map[string]interface{}
+		want    bool
+	}{
+		{
+			name:    "unchanged",
+			desired: map[string]interface{}{"type": "kubernetes", "config": map[string]interface{}{"pod_net_ipv4_cidr_max_mask": 16}},
+			want:    true,
+		},
+		{
+			name:    "hidden password",
+			desired: map[string]interface{}{"config": map[string]interface{}{"secret_key": "secret"}},
+			want:    true,
+		},
+		{
+			name:    "changed",
+			desired: map[string]interface{}{"config": map[string]interface{}{"pod_net_ipv4_cidr_max_mask": 24}},
+			want:    false,
+		},
+		{
+			name:    "removed on server",
+			desired: map[string]interface{}{"config": map[string]interface{}{"node_port_name_regex": "^(cni|flannel)"}},
+			want:    false,
+		},
+		{
+			name:    "not a map on server",
+			desired: map[string]interface{}{"type": map[string]interface{}{"name": "kubernetes"}},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := specContains(tt.desired, current); got != tt.want {
+				t.Errorf("specContains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+	"github.com/deepflowio/deepflow/cli/ctl/common/table"
+)
+
+func RegisterCustomIPTagCommand() *cobra.Command {
+	customIPTag := &cobra.Command{
+		Use:   "custom-ip-tag",
+		Short: "custom ip/cidr tag set operation commands",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf("please run with 'list | create | upload | sync | tags | delete'.\n")
+		},
+	}
+
+	var listOutput string
+	listCmd := &cobra.Command{
+		Use:     "list [name]",
+		Short:   "list custom ip tag sets",
+		Example: "deepflow-ctl custom-ip-tag list",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := listCustomIPTagSet(cmd, args, listOutput); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "", "output format")
+
+	var sourceURL string
+	var syncInterval int
+	createCmd := &cobra.Command{
+		Use:   "create [name]",
+		Short: "create custom ip tag set, tags are uploaded by file or synced from --url",
+		Example: "deepflow-ctl custom-ip-tag create cmdb\n" +
+			"deepflow-ctl custom-ip-tag create cmdb --url http://cmdb.example.com/ip.csv --sync-interval 3600",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := createCustomIPTagSet(cmd, args, sourceURL, syncInterval); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	createCmd.Flags().StringVarP(&sourceURL, "url", "", "", "http(s) url of csv file, periodically synced if specified")
+	createCmd.Flags().IntVarP(&syncInterval, "sync-interval", "", 0, "sync interval of --url, unit: s, default 3600")
+
+	var uploadFilename string
+	uploadCmd := &cobra.Command{
+		Use:   "upload [name]",
+		Short: "upload csv file to replace all tags of custom ip tag set",
+		Long: "upload csv file to replace all tags of custom ip tag set\n" +
+			"csv header: ip (or cidr), optional l3_epc_id, then one column per tag key, e.g.\n" +
+			"  ip,l3_epc_id,team,env\n" +
+			"  10.1.0.0/16,,payment,prod\n" +
+			"  192.168.1.10,3,order,test",
+		Example: "deepflow-ctl custom-ip-tag upload cmdb -f ip.csv",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := uploadCustomIPTags(cmd, args, uploadFilename); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	uploadCmd.Flags().StringVarP(&uploadFilename, "filename", "f", "", "csv file to upload")
+	if err := uploadCmd.MarkFlagRequired("filename"); err != nil {
+		fmt.Println(err)
+	}
+
+	syncCmd := &cobra.Command{
+		Use:     "sync [name]",
+		Short:   "sync custom ip tag set from its url immediately",
+		Example: "deepflow-ctl custom-ip-tag sync cmdb",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := syncCustomIPTagSet(cmd, args); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+
+	tagsCmd := &cobra.Command{
+		Use:     "tags [name]",
+		Short:   "list tags of custom ip tag set",
+		Example: "deepflow-ctl custom-ip-tag tags cmdb",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := listCustomIPTags(cmd, args); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:     "delete [name]",
+		Short:   "delete custom ip tag set",
+		Example: "deepflow-ctl custom-ip-tag delete cmdb",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := deleteCustomIPTagSet(cmd, args); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+
+	customIPTag.AddCommand(listCmd)
+	customIPTag.AddCommand(createCmd)
+	customIPTag.AddCommand(uploadCmd)
+	customIPTag.AddCommand(syncCmd)
+	customIPTag.AddCommand(tagsCmd)
+	customIPTag.AddCommand(deleteCmd)
+	return customIPTag
+}
+
+func listCustomIPTagSet(cmd *cobra.Command, args []string, output string) error {
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/custom-ip-tag-sets/", server.IP, server.Port)
+	filter := common.Filter{}
+	if len(args) > 0 {
+		filter["name"] = args[0]
+	}
+	response, err := common.GetByFilter(url, nil, filter, []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+
+	if output == "yaml" {
+		jData, _ := response.Get("DATA").MarshalJSON()
+		yData, _ := yaml.JSONToYAML(jData)
+		fmt.Printf(string(yData))
+		return nil
+	}
+	t := table.New()
+	t.SetHeader([]string{"NAME", "SOURCE", "SYNC_INTERVAL", "TAG_KEYS", "ENTRY_COUNT", "SYNCED_AT", "SYNC_ERROR", "LCUUID"})
+	tableItems := [][]string{}
+	for i := range response.Get("DATA").MustArray() {
+		data := response.Get("DATA").GetIndex(i)
+		source, interval := "upload", ""
+		if data.Get("SOURCE_TYPE").MustInt() == 1 {
+			source = data.Get("SOURCE_URL").MustString()
+			interval = strconv.Itoa(data.Get("SYNC_INTERVAL").MustInt())
+		}
+		tableItems = append(tableItems, []string{
+			data.Get("NAME").MustString(),
+			source,
+			interval,
+			strings.Join(data.Get("TAG_KEYS").MustStringArray(), ","),
+			strconv.Itoa(data.Get("ENTRY_COUNT").MustInt()),
+			data.Get("SYNCED_AT").MustString(),
+			data.Get("SYNC_ERROR").MustString(),
+			data.Get("LCUUID").MustString(),
+		})
+	}
+	t.AppendBulk(tableItems)
+	t.Render()
+	return nil
+}
+
+func createCustomIPTagSet(cmd *cobra.Command, args []string, sourceURL string, syncInterval int) error {
+	if len(args) == 0 {
+		return fmt.Errorf("must specify name\nExample: %s", cmd.Example)
+	}
+	body := map[string]interface{}{"NAME": args[0]}
+	if sourceURL != "" {
+		body["SOURCE_TYPE"] = 1
+		body["SOURCE_URL"] = sourceURL
+		body["SYNC_INTERVAL"] = syncInterval
+	}
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/custom-ip-tag-sets/", server.IP, server.Port)
+	response, err := common.CURLPerform("POST", url, body, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("create custom ip tag set (%s) success, lcuuid: %s\n", args[0], response.Get("DATA").Get("LCUUID").MustString())
+	if syncError := response.Get("DATA").Get("SYNC_ERROR").MustString(); syncError != "" {
+		fmt.Printf("sync from %s failed: %s\n", sourceURL, syncError)
+	}
+	return nil
+}
+
+func uploadCustomIPTags(cmd *cobra.Command, args []string, fileName string) error {
+	lcuuid, err := getCustomIPTagSetLcuuid(cmd, args)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bodyBuf := &bytes.Buffer{}
+	bodyWriter := multipart.NewWriter(bodyBuf)
+	fileWriter, err := bodyWriter.CreateFormFile("FILE", path.Base(fileName))
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(fileWriter, f); err != nil {
+		return err
+	}
+	contentType := bodyWriter.FormDataContentType()
+	bodyWriter.Close()
+
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/custom-ip-tag-sets/%s/tags/", server.IP, server.Port, lcuuid)
+	response, err := common.CURLPostFormData(url, contentType, bodyBuf, []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("upload custom ip tag set (%s) success, entry count: %d\n", args[0], response.Get("DATA").Get("ENTRY_COUNT").MustInt())
+	return nil
+}
+
+func syncCustomIPTagSet(cmd *cobra.Command, args []string) error {
+	lcuuid, err := getCustomIPTagSetLcuuid(cmd, args)
+	if err != nil {
+		return err
+	}
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/custom-ip-tag-sets/%s/sync/", server.IP, server.Port, lcuuid)
+	response, err := common.CURLPerform("POST", url, nil, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("sync custom ip tag set (%s) success, entry count: %d\n", args[0], response.Get("DATA").Get("ENTRY_COUNT").MustInt())
+	return nil
+}
+
+func listCustomIPTags(cmd *cobra.Command, args []string) error {
+	lcuuid, err := getCustomIPTagSetLcuuid(cmd, args)
+	if err != nil {
+		return err
+	}
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/custom-ip-tag-sets/%s/tags/", server.IP, server.Port, lcuuid)
+	response, err := common.CURLPerform("GET", url, nil, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+
+	t := table.New()
+	t.SetHeader([]string{"CIDR", "L3_EPC_ID", "TAGS"})
+	tableItems := [][]string{}
+	for i := range response.Get("DATA").MustArray() {
+		data := response.Get("DATA").GetIndex(i)
+		tags := data.Get("TAGS").MustMap()
+		kvs := make([]string, 0, len(tags))
+		for key, value := range tags {
+			kvs = append(kvs, fmt.Sprintf("%s=%v", key, value))
+		}
+		sort.Strings(kvs)
+		tableItems = append(tableItems, []string{
+			data.Get("CIDR").MustString(),
+			strconv.Itoa(data.Get("L3_EPC_ID").MustInt()),
+			strings.Join(kvs, ","),
+		})
+	}
+	t.AppendBulk(tableItems)
+	t.Render()
+	return nil
+}
+
+func deleteCustomIPTagSet(cmd *cobra.Command, args []string) error {
+	lcuuid, err := getCustomIPTagSetLcuuid(cmd, args)
+	if err != nil {
+		return err
+	}
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/custom-ip-tag-sets/%s/", server.IP, server.Port, lcuuid)
+	_, err = common.CURLPerform("DELETE", url, nil, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("delete custom ip tag set (%s) success\n", args[0])
+	return nil
+}
+
+func getCustomIPTagSetLcuuid(cmd *cobra.Command, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("must specify name\nExample: %s", cmd.Example)
+	}
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/custom-ip-tag-sets/", server.IP, server.Port)
+	response, err := common.GetByFilter(url, nil, common.Filter{"name": args[0]}, []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return "", err
+	}
+	if len(response.Get("DATA").MustArray()) == 0 {
+		return "", errors.New(fmt.Sprintf("custom ip tag set (%s) not found", args[0]))
+	}
+	return response.Get("DATA").GetIndex(0).Get("LCUUID").MustString(), nil
+}
@@ -30,6 +30,9 @@ var YamlDomainBaiduBce []byte
 //go:embed domain_filereader.yaml
 var YamlDomainFileReader []byte
 
+//go:embed domain_gcp.yaml
+var YamlDomainGCP []byte
+
 //go:embed domain_genesis.yaml
 var YamlDomainGenesis []byte
 
@@ -50,3 +53,18 @@ var YamlSubDomain []byte
 
 //go:embed vtap_update.yaml
 var YamlVtapUpdateConfig []byte
+
+//go:embed config_bundle.yaml
+var YamlConfigBundle []byte
+
+//go:embed flow_acl_create.yaml
+var YamlFlowACL []byte
+
+//go:embed npb_tunnel_create.yaml
+var YamlNpbTunnel []byte
+
+//go:embed npb_policy_create.yaml
+var YamlNpbPolicy []byte
+
+//go:embed pcap_policy_create.yaml
+var YamlPcapPolicy []byte
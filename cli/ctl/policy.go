@@ -0,0 +1,458 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+	"github.com/deepflowio/deepflow/cli/ctl/common/table"
+	"github.com/deepflowio/deepflow/cli/ctl/example"
+)
+
+// policyResource 描述一类策略资源的 API 路径及列表展示字段
+type policyResource struct {
+	use     string
+	name    string
+	path    string
+	example []byte
+	header  []string
+	row     func(data *simplejson.Json) []string
+}
+
+var policyResources = []policyResource{
+	{
+		use:     "flow-acl",
+		name:    "flow acl",
+		path:    "v1/flow-acls",
+		example: example.YamlFlowACL,
+		header:  []string{"ID", "NAME", "APPLICATION", "STATE", "TAP_TYPE", "SRC_GROUP_IDS", "DST_GROUP_IDS", "PROTOCOL", "SRC_PORTS", "DST_PORTS", "VLAN", "LCUUID"},
+		row: func(data *simplejson.Json) []string {
+			protocol := "any"
+			if p, err := data.Get("PROTOCOL").Int(); err == nil {
+				protocol = strconv.Itoa(p)
+			}
+			return []string{
+				strconv.Itoa(data.Get("ID").MustInt()),
+				data.Get("NAME").MustString(),
+				policyApplicationName(data.Get("APPLICATION").MustInt()),
+				policyStateName(data.Get("STATE").MustInt()),
+				strconv.Itoa(data.Get("TAP_TYPE").MustInt()),
+				policyGroupIDs(data.Get("SRC_GROUP_IDS")),
+				policyGroupIDs(data.Get("DST_GROUP_IDS")),
+				protocol,
+				data.Get("SRC_PORTS").MustString(),
+				data.Get("DST_PORTS").MustString(),
+				strconv.Itoa(data.Get("VLAN").MustInt()),
+				data.Get("LCUUID").MustString(),
+			}
+		},
+	},
+	{
+		use:     "npb-tunnel",
+		name:    "npb tunnel",
+		path:    "v1/npb-tunnels",
+		example: example.YamlNpbTunnel,
+		header:  []string{"ID", "NAME", "IP", "TYPE", "VNI_INPUT_TYPE", "LCUUID"},
+		row: func(data *simplejson.Json) []string {
+			tunnelType := "VXLAN"
+			if data.Get("TYPE").MustInt() == 1 {
+				tunnelType = "ERSPAN"
+			}
+			return []string{
+				strconv.Itoa(data.Get("ID").MustInt()),
+				data.Get("NAME").MustString(),
+				data.Get("IP").MustString(),
+				tunnelType,
+				strconv.Itoa(data.Get("VNI_INPUT_TYPE").MustInt()),
+				data.Get("LCUUID").MustString(),
+			}
+		},
+	},
+	{
+		use:     "npb",
+		name:    "npb policy",
+		path:    "v1/npb-policies",
+		example: example.YamlNpbPolicy,
+		header:  []string{"ID", "NAME", "STATE", "ACL_ID", "NPB_TUNNEL_ID", "VNI", "DIRECTION", "TAP_SIDE", "DISTRIBUTE", "PAYLOAD_SLICE", "VTAP_IDS", "LCUUID"},
+		row: func(data *simplejson.Json) []string {
+			distribute := "drop"
+			if data.Get("DISTRIBUTE").MustInt() == 1 {
+				distribute = "distribute"
+			}
+			return []string{
+				strconv.Itoa(data.Get("ID").MustInt()),
+				data.Get("NAME").MustString(),
+				policyStateName(data.Get("STATE").MustInt()),
+				strconv.Itoa(data.Get("ACL_ID").MustInt()),
+				strconv.Itoa(data.Get("NPB_TUNNEL_ID").MustInt()),
+				strconv.Itoa(data.Get("VNI").MustInt()),
+				policyDirectionName(data.Get("DIRECTION").MustInt()),
+				policyTapSideName(data.Get("TAP_SIDE").MustInt()),
+				distribute,
+				policyPayloadSlice(data),
+				policyVTapIDs(data),
+				data.Get("LCUUID").MustString(),
+			}
+		},
+	},
+	{
+		use:     "pcap",
+		name:    "pcap policy",
+		path:    "v1/pcap-policies",
+		example: example.YamlPcapPolicy,
+		header:  []string{"ID", "NAME", "STATE", "ACL_ID", "TAP_SIDE", "PAYLOAD_SLICE", "VTAP_IDS", "LCUUID"},
+		row: func(data *simplejson.Json) []string {
+			return []string{
+				strconv.Itoa(data.Get("ID").MustInt()),
+				data.Get("NAME").MustString(),
+				policyStateName(data.Get("STATE").MustInt()),
+				strconv.Itoa(data.Get("ACL_ID").MustInt()),
+				policyTapSideName(data.Get("TAP_SIDE").MustInt()),
+				policyPayloadSlice(data),
+				policyVTapIDs(data),
+				data.Get("LCUUID").MustString(),
+			}
+		},
+	},
+}
+
+func RegisterPolicyCommand() *cobra.Command {
+	policy := &cobra.Command{
+		Use:   "policy",
+		Short: "flow acl, npb and pcap policy operation commands",
+	}
+	for i := range policyResources {
+		policy.AddCommand(registerPolicyResourceCommand(&policyResources[i]))
+	}
+
+	var effectiveOutput string
+	effectiveCmd := &cobra.Command{
+		Use:     "effective [agent-name]",
+		Short:   "show flow acls and npb actions sent to the agent",
+		Example: "deepflow-ctl policy effective deepflow-agent-1",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := showEffectivePolicy(cmd, args, effectiveOutput); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	effectiveCmd.Flags().StringVarP(&effectiveOutput, "output", "o", "", "output format")
+	policy.AddCommand(effectiveCmd)
+	return policy
+}
+
+func registerPolicyResourceCommand(r *policyResource) *cobra.Command {
+	resourceCmd := &cobra.Command{
+		Use:   r.use,
+		Short: fmt.Sprintf("%s operation commands", r.name),
+	}
+
+	var listOutput string
+	listCmd := &cobra.Command{
+		Use:     "list [name]",
+		Short:   fmt.Sprintf("list %s info", r.name),
+		Example: fmt.Sprintf("deepflow-ctl policy %s list", r.use),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := listPolicyResource(cmd, r, args, listOutput); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "", "output format")
+
+	exampleCmd := &cobra.Command{
+		Use:     "example",
+		Short:   fmt.Sprintf("example %s create yaml", r.name),
+		Example: fmt.Sprintf("deepflow-ctl policy %s example", r.use),
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf(string(r.example))
+		},
+	}
+
+	var createFilename string
+	createCmd := &cobra.Command{
+		Use:     "create",
+		Short:   fmt.Sprintf("create %s", r.name),
+		Example: fmt.Sprintf("deepflow-ctl policy %s create -f filename", r.use),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := createPolicyResource(cmd, r, createFilename); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	createCmd.Flags().StringVarP(&createFilename, "filename", "f", "", fmt.Sprintf("create %s from file or stdin", r.name))
+	if err := createCmd.MarkFlagRequired("filename"); err != nil {
+		fmt.Println(err)
+	}
+
+	var updateFilename string
+	updateCmd := &cobra.Command{
+		Use:     "update [lcuuid]",
+		Short:   fmt.Sprintf("update %s, only fields in the file are modified", r.name),
+		Example: fmt.Sprintf("deepflow-ctl policy %s update -f filename ${lcuuid}", r.use),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := updatePolicyResource(cmd, r, args, updateFilename); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	updateCmd.Flags().StringVarP(&updateFilename, "filename", "f", "", fmt.Sprintf("update %s from file or stdin", r.name))
+	if err := updateCmd.MarkFlagRequired("filename"); err != nil {
+		fmt.Println(err)
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:     "delete [lcuuid]",
+		Short:   fmt.Sprintf("delete %s", r.name),
+		Example: fmt.Sprintf("deepflow-ctl policy %s delete ${lcuuid}", r.use),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := deletePolicyResource(cmd, r, args); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+
+	resourceCmd.AddCommand(listCmd)
+	resourceCmd.AddCommand(exampleCmd)
+	resourceCmd.AddCommand(createCmd)
+	resourceCmd.AddCommand(updateCmd)
+	resourceCmd.AddCommand(deleteCmd)
+	return resourceCmd
+}
+
+func listPolicyResource(cmd *cobra.Command, r *policyResource, args []string, output string) error {
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/%s/", server.IP, server.Port, r.path)
+	filter := common.Filter{}
+	if len(args) > 0 {
+		filter["name"] = args[0]
+	}
+	response, err := common.GetByFilter(url, nil, filter, []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+
+	if output == "yaml" {
+		jData, _ := response.Get("DATA").MarshalJSON()
+		yData, _ := yaml.JSONToYAML(jData)
+		fmt.Printf(string(yData))
+		return nil
+	}
+	t := table.New()
+	t.SetHeader(r.header)
+	tableItems := [][]string{}
+	for i := range response.Get("DATA").MustArray() {
+		tableItems = append(tableItems, r.row(response.Get("DATA").GetIndex(i)))
+	}
+	t.AppendBulk(tableItems)
+	t.Render()
+	return nil
+}
+
+func createPolicyResource(cmd *cobra.Command, r *policyResource, fileName string) error {
+	body, err := formatBody(fileName)
+	if err != nil {
+		return err
+	}
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/%s/", server.IP, server.Port, r.path)
+	response, err := common.CURLPerform("POST", url, body, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("create %s (%s) success, lcuuid: %s\n", r.name, response.Get("DATA").Get("NAME").MustString(),
+		response.Get("DATA").Get("LCUUID").MustString())
+	return nil
+}
+
+func updatePolicyResource(cmd *cobra.Command, r *policyResource, args []string, fileName string) error {
+	if len(args) == 0 {
+		return errors.New("lcuuid is required")
+	}
+	body, err := formatBody(fileName)
+	if err != nil {
+		return err
+	}
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/%s/%s/", server.IP, server.Port, r.path, args[0])
+	_, err = common.CURLPerform("PATCH", url, body, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("update %s (%s) success\n", r.name, args[0])
+	return nil
+}
+
+func deletePolicyResource(cmd *cobra.Command, r *policyResource, args []string) error {
+	if len(args) == 0 {
+		return errors.New("lcuuid is required")
+	}
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/%s/%s/", server.IP, server.Port, r.path, args[0])
+	_, err := common.CURLPerform("DELETE", url, nil, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("delete %s (%s) success\n", r.name, args[0])
+	return nil
+}
+
+// showEffectivePolicy 从采集器所属控制器获取编译后实际下发的策略
+func showEffectivePolicy(cmd *cobra.Command, args []string, output string) error {
+	if len(args) == 0 {
+		return errors.New("agent name is required")
+	}
+	server := common.GetServerInfo(cmd)
+	vtapURL := fmt.Sprintf("http://%s:%d/v1/vtaps/?name=%s", server.IP, server.Port, args[0])
+	response, err := common.CURLPerform("GET", vtapURL, nil, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	if len(response.Get("DATA").MustArray()) == 0 {
+		return fmt.Errorf("agent (%s) not found", args[0])
+	}
+	vtap := response.Get("DATA").GetIndex(0)
+	controllerIP := vtap.Get("CONTROLLER_IP").MustString()
+	if controllerIP == "" {
+		controllerIP = server.IP
+	}
+
+	url := fmt.Sprintf("http://%s:%d/v1/effective-policies/vtap/%s/", controllerIP, server.Port, vtap.Get("LCUUID").MustString())
+	response, err = common.CURLPerform("GET", url, nil, "", []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	data := response.Get("DATA")
+	if output == "yaml" {
+		jData, _ := data.MarshalJSON()
+		yData, _ := yaml.JSONToYAML(jData)
+		fmt.Printf(string(yData))
+		return nil
+	}
+
+	fmt.Printf("agent: %s, controller: %s, version: %d\n", data.Get("VTAP_NAME").MustString(), controllerIP, data.Get("VERSION").MustUint64())
+	t := table.New()
+	t.SetHeader([]string{"ACL_ID", "ACL_NAME", "TAP_TYPE", "PROTOCOL", "SRC_GROUP_IDS", "DST_GROUP_IDS", "SRC_PORTS", "DST_PORTS", "VLAN", "POLICY", "TUNNEL_TYPE", "TUNNEL", "TAP_SIDE", "DIRECTION", "PAYLOAD_SLICE"})
+	tableItems := [][]string{}
+	for i := range data.Get("FLOW_ACLS").MustArray() {
+		flowACL := data.Get("FLOW_ACLS").GetIndex(i)
+		protocol := "any"
+		if p := flowACL.Get("PROTOCOL").MustInt(); p != 256 {
+			protocol = strconv.Itoa(p)
+		}
+		aclItem := []string{
+			strconv.Itoa(flowACL.Get("ACL_ID").MustInt()),
+			flowACL.Get("ACL_NAME").MustString(),
+			strconv.Itoa(flowACL.Get("TAP_TYPE").MustInt()),
+			protocol,
+			fmt.Sprint(flowACL.Get("SRC_GROUP_IDS").MustArray()),
+			fmt.Sprint(flowACL.Get("DST_GROUP_IDS").MustArray()),
+			flowACL.Get("SRC_PORTS").MustString(),
+			flowACL.Get("DST_PORTS").MustString(),
+			strconv.Itoa(flowACL.Get("VLAN").MustInt()),
+		}
+		// 每个分发动作占一行
+		for j := range flowACL.Get("NPB_ACTIONS").MustArray() {
+			npbAction := flowACL.Get("NPB_ACTIONS").GetIndex(j)
+			tunnel := npbAction.Get("TUNNEL_IP").MustString()
+			if tunnel != "" {
+				tunnel = fmt.Sprintf("%s (%d)", tunnel, npbAction.Get("TUNNEL_ID").MustInt())
+			}
+			tableItems = append(tableItems, append(append([]string{}, aclItem...),
+				npbAction.Get("POLICY_NAME").MustString(),
+				npbAction.Get("TUNNEL_TYPE").MustString(),
+				tunnel,
+				npbAction.Get("TAP_SIDE").MustString(),
+				npbAction.Get("DIRECTION").MustString(),
+				strconv.Itoa(npbAction.Get("PAYLOAD_SLICE").MustInt()),
+			))
+		}
+	}
+	t.AppendBulk(tableItems)
+	t.Render()
+	return nil
+}
+
+func policyApplicationName(application int) string {
+	switch application {
+	case 4:
+		return "pcap"
+	case 6:
+		return "npb"
+	}
+	return strconv.Itoa(application)
+}
+
+func policyStateName(state int) string {
+	if state == 1 {
+		return "enable"
+	}
+	return "disable"
+}
+
+func policyDirectionName(direction int) string {
+	switch direction {
+	case 1:
+		return "all"
+	case 2:
+		return "forward"
+	case 3:
+		return "backward"
+	}
+	return strconv.Itoa(direction)
+}
+
+func policyTapSideName(tapSide int) string {
+	switch tapSide {
+	case 1:
+		return "src"
+	case 2:
+		return "dst"
+	case 3:
+		return "both"
+	}
+	return strconv.Itoa(tapSide)
+}
+
+func policyGroupIDs(groupIDs *simplejson.Json) string {
+	if len(groupIDs.MustArray()) == 0 {
+		return "any"
+	}
+	return fmt.Sprint(groupIDs.MustArray())
+}
+
+func policyPayloadSlice(data *simplejson.Json) string {
+	if payloadSlice, err := data.Get("PAYLOAD_SLICE").Int(); err == nil {
+		return strconv.Itoa(payloadSlice)
+	}
+	return "all"
+}
+
+func policyVTapIDs(data *simplejson.Json) string {
+	vtapIDs := data.Get("VTAP_IDS").MustArray()
+	if len(vtapIDs) == 0 {
+		return "all"
+	}
+	return fmt.Sprint(vtapIDs)
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitly/go-simplejson"
+	"sigs.k8s.io/yaml"
+)
+
+func getPolicyResource(t *testing.T, use string) *policyResource {
+	for i := range policyResources {
+		if policyResources[i].use == use {
+			return &policyResources[i]
+		}
+	}
+	t.Fatalf("policy resource %s not found", use)
+	return nil
+}
+
+func TestPolicyResourceRows(t *testing.T) {
+	tests := []struct {
+		use  string
+		data string
+		want []string
+	}{
+		{
+			use:  "flow-acl",
+			data: `{"ID": 1, "NAME": "acl", "APPLICATION": 6, "STATE": 1, "TAP_TYPE": 3, "SRC_GROUP_IDS": [1, 2], "DST_GROUP_IDS": [], "PROTOCOL": 6, "DST_PORTS": "80", "LCUUID": "u"}`,
+			want: []string{"1", "acl", "npb", "enable", "3", "[1 2]", "any", "6", "", "80", "0", "u"},
+		},
+		{
+			use:  "flow-acl",
+			data: `{"ID": 2, "NAME": "acl", "APPLICATION": 4, "STATE": 0, "TAP_TYPE": 3, "PROTOCOL": null, "LCUUID": "u"}`,
+			want: []string{"2", "acl", "pcap", "disable", "3", "any", "any", "any", "", "", "0", "u"},
+		},
+		{
+			use:  "npb",
+			data: `{"ID": 1, "NAME": "npb", "STATE": 1, "ACL_ID": 1, "NPB_TUNNEL_ID": 2, "VNI": 100, "DIRECTION": 2, "TAP_SIDE": 3, "DISTRIBUTE": 0, "PAYLOAD_SLICE": null, "VTAP_IDS": [], "LCUUID": "u"}`,
+			want: []string{"1", "npb", "enable", "1", "2", "100", "forward", "both", "drop", "all", "all", "u"},
+		},
+		{
+			use:  "pcap",
+			data: `{"ID": 1, "NAME": "pcap", "STATE": 1, "ACL_ID": 2, "TAP_SIDE": 2, "PAYLOAD_SLICE": 128, "VTAP_IDS": [1, 3], "LCUUID": "u"}`,
+			want: []string{"1", "pcap", "enable", "2", "dst", "128", "[1 3]", "u"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.use, func(t *testing.T) {
+			r := getPolicyResource(t, tt.use)
+			data, err := simplejson.NewJson([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := r.row(data)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("row() = %q, want %q", got, tt.want)
+			}
+			if len(got) != len(r.header) {
+				t.Errorf("row() has %d columns, header has %d", len(got), len(r.header))
+			}
+		})
+	}
+}
+
+// 示例文件中的字段需与 API 的字段一致，否则创建时会被忽略
+func TestPolicyResourceExamples(t *testing.T) {
+	tests := []struct {
+		use    string
+		fields []string
+	}{
+		{use: "flow-acl", fields: []string{"name", "application", "src_group_ids", "dst_group_ids", "protocol"}},
+		{use: "npb", fields: []string{"name", "acl_id", "npb_tunnel_id", "direction", "tap_side"}},
+		{use: "pcap", fields: []string{"name", "acl_id", "tap_side", "payload_slice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.use, func(t *testing.T) {
+			var body map[string]interface{}
+			if err := yaml.Unmarshal(getPolicyResource(t, tt.use).example, &body); err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range tt.fields {
+				if _, ok := body[field]; !ok {
+					t.Errorf("example of %s has no field %s", tt.use, field)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyNames(t *testing.T) {
+	if got := policyTapSideName(0); got != "0" {
+		t.Errorf("policyTapSideName(0) = %s, want 0", got)
+	}
+	if got := policyDirectionName(1); got != "all" {
+		t.Errorf("policyDirectionName(1) = %s, want all", got)
+	}
+	if got := policyApplicationName(5); got != "5" {
+		t.Errorf("policyApplicationName(5) = %s, want 5", got)
+	}
+}
@@ -0,0 +1,476 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/spf13/cobra"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+	"github.com/deepflowio/deepflow/cli/ctl/common/table"
+)
+
+const (
+	QUERY_OUTPUT_TABLE = "table"
+	QUERY_OUTPUT_JSON  = "json"
+	QUERY_OUTPUT_CSV   = "csv"
+
+	QUERY_HISTORY_FILE = ".deepflow-ctl_history"
+	// 自动计算 step 时，单条曲线最多返回的点数
+	QUERY_PROMQL_MAX_POINTS = 250
+)
+
+// 查询结果的统一表示，table/csv 输出共用
+type queryResult struct {
+	columns []string
+	rows    [][]string
+}
+
+func RegisterQueryCommand() *cobra.Command {
+	query := &cobra.Command{
+		Use:   "query",
+		Short: "query deepflow sql, promql and trace from querier",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println("please run with 'sql | promql | trace'.")
+		},
+	}
+	query.PersistentFlags().StringP("output", "o", QUERY_OUTPUT_TABLE, "output format, support: table, json, csv")
+	query.PersistentFlags().String("since", "1h", "query since time duration like [5s,1m,5m,1h], default: 1h")
+	query.PersistentFlags().String("from", "", "query from a specific time(RFC3339), e.g.: 2000-01-01T00:00:00Z")
+	query.PersistentFlags().String("to", "", "query to a specific time(RFC3339), e.g.: 2000-01-01T00:00:00Z")
+
+	query.AddCommand(querySQLSubCommand())
+	query.AddCommand(queryPromQLSubCommand())
+	query.AddCommand(queryTraceSubCommand())
+	return query
+}
+
+func querySQLSubCommand() *cobra.Command {
+	var db, dataPrecision string
+	sql := &cobra.Command{
+		Use:   "sql [statement]",
+		Short: "execute deepflow sql, enter interactive mode without statement",
+		Long: "execute deepflow sql, enter interactive mode without statement.\n" +
+			"macros $__timeFilter, $__from and $__to are replaced by the time range of --since/--from/--to",
+		Example: "deepflow-ctl query sql --db flow_log \"SELECT Count(row) FROM l7_flow_log WHERE \\$__timeFilter\"\n" +
+			"deepflow-ctl query sql --db flow_metrics -o csv",
+		Run: func(cmd *cobra.Command, args []string) {
+			runQuery(cmd, args, "sql", func(statement string) error {
+				return executeSQL(cmd, db, dataPrecision, statement)
+			})
+		},
+	}
+	sql.Flags().StringVar(&db, "db", "flow_log", "database to query")
+	sql.Flags().StringVar(&dataPrecision, "data-precision", "", "data precision of flow_metrics, e.g.: 1s, 1m")
+	return sql
+}
+
+func queryPromQLSubCommand() *cobra.Command {
+	var step string
+	promql := &cobra.Command{
+		Use:     "promql [expression]",
+		Short:   "execute promql range query, enter interactive mode without expression",
+		Example: "deepflow-ctl query promql 'sum(rate(deepflow_system_deepflow_server_ingester_recv[1m]))' --since 30m --step 1m",
+		Run: func(cmd *cobra.Command, args []string) {
+			runQuery(cmd, args, "promql", func(expr string) error {
+				return executePromQL(cmd, step, expr)
+			})
+		},
+	}
+	promql.Flags().StringVar(&step, "step", "", "query resolution step like [15s,1m], default: calculated from time range")
+	return promql
+}
+
+func queryTraceSubCommand() *cobra.Command {
+	trace := &cobra.Command{
+		Use:     "trace <trace-id>",
+		Short:   "fetch spans of a trace",
+		Example: "deepflow-ctl query trace 5455e8b558250c7bfd2eed1bba623314 --since 24h",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "must specify one trace id.\nExample:", cmd.Example)
+				return
+			}
+			if err := executeTrace(cmd, args[0]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		},
+	}
+	return trace
+}
+
+// runQuery 有参数时直接执行，否则进入交互模式
+func runQuery(cmd *cobra.Command, args []string, prompt string, execute func(string) error) {
+	if err := checkQueryOutput(cmd); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	if len(args) > 0 {
+		if err := execute(strings.Join(args, " ")); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return
+	}
+	queryREPL(prompt, execute)
+}
+
+func checkQueryOutput(cmd *cobra.Command) error {
+	output, _ := cmd.Flags().GetString("output")
+	switch output {
+	case QUERY_OUTPUT_TABLE, QUERY_OUTPUT_JSON, QUERY_OUTPUT_CSV:
+		return nil
+	}
+	return fmt.Errorf("invalid output format: %s, support: %s, %s, %s",
+		output, QUERY_OUTPUT_TABLE, QUERY_OUTPUT_JSON, QUERY_OUTPUT_CSV)
+}
+
+func getQueryHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, QUERY_HISTORY_FILE)
+}
+
+func loadQueryHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var history []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+func appendQueryHistory(path, line string) {
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// queryREPL 逐行读取语句并执行，历史记录保存在 ~/.deepflow-ctl_history，
+// 支持 history 查看历史、!N 重新执行第 N 条、!! 重新执行上一条
+func queryREPL(prompt string, execute func(string) error) {
+	historyPath := getQueryHistoryPath()
+	history := loadQueryHistory(historyPath)
+	fmt.Fprintf(os.Stderr, "enter %s statement, 'history' to list history, '!N' or '!!' to rerun, 'exit' to quit.\n", prompt)
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(os.Stderr, "%s> ", prompt)
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(os.Stderr)
+			return
+		}
+		line = strings.TrimSuffix(strings.TrimSpace(line), ";")
+		switch {
+		case line == "":
+			continue
+		case line == "exit" || line == "quit":
+			return
+		case line == "history":
+			for i, h := range history {
+				fmt.Printf("%5d  %s\n", i+1, h)
+			}
+			continue
+		case line == "!!":
+			if len(history) == 0 {
+				fmt.Fprintln(os.Stderr, "no history")
+				continue
+			}
+			line = history[len(history)-1]
+			fmt.Fprintln(os.Stderr, line)
+		case strings.HasPrefix(line, "!"):
+			index, err := strconv.Atoi(line[1:])
+			if err != nil || index <= 0 || index > len(history) {
+				fmt.Fprintf(os.Stderr, "history %s not found\n", line[1:])
+				continue
+			}
+			line = history[index-1]
+			fmt.Fprintln(os.Stderr, line)
+		}
+
+		if len(history) == 0 || history[len(history)-1] != line {
+			history = append(history, line)
+			appendQueryHistory(historyPath, line)
+		}
+		if err := execute(line); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+func getQuerierURL(cmd *cobra.Command, path string) string {
+	server := common.GetServerInfo(cmd)
+	return fmt.Sprintf("http://%s:%d%s", server.IP, server.QuerierPort, path)
+}
+
+func replaceTimeMacros(sql string, from, to int64) string {
+	return strings.NewReplacer(
+		"$__timeFilter", fmt.Sprintf("time>=%d AND time<=%d", from, to),
+		"$__from", strconv.FormatInt(from, 10),
+		"$__to", strconv.FormatInt(to, 10),
+	).Replace(sql)
+}
+
+func executeSQL(cmd *cobra.Command, db, dataPrecision, sql string) error {
+	from, to, err := getQueryTime(cmd)
+	if err != nil {
+		return fmt.Errorf("parse time error: %v", err)
+	}
+	values := url.Values{}
+	values.Set("db", db)
+	values.Set("sql", replaceTimeMacros(sql, from, to))
+	if dataPrecision != "" {
+		values.Set("data_precision", dataPrecision)
+	}
+	response, err := common.CURLPerform("POST", getQuerierURL(cmd, "/v1/query/"), nil, values.Encode(),
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+
+	data := response.Get("result")
+	columns := make([]string, 0, len(data.Get("columns").MustArray()))
+	for i := range data.Get("columns").MustArray() {
+		columns = append(columns, jsonToString(data.Get("columns").GetIndex(i)))
+	}
+	result := &queryResult{columns: columns}
+	for i := range data.Get("values").MustArray() {
+		row := data.Get("values").GetIndex(i)
+		items := make([]string, 0, len(columns))
+		for j := range columns {
+			items = append(items, jsonToString(row.GetIndex(j)))
+		}
+		result.rows = append(result.rows, items)
+	}
+	return outputQueryResult(cmd, data, result)
+}
+
+func getPromQLStep(step string, from, to int64) string {
+	if step != "" {
+		return step
+	}
+	seconds := (to - from) / QUERY_PROMQL_MAX_POINTS
+	if seconds < 1 {
+		seconds = 1
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+func executePromQL(cmd *cobra.Command, step, expr string) error {
+	from, to, err := getQueryTime(cmd)
+	if err != nil {
+		return fmt.Errorf("parse time error: %v", err)
+	}
+	values := url.Values{}
+	values.Set("query", expr)
+	values.Set("start", strconv.FormatInt(from, 10))
+	values.Set("end", strconv.FormatInt(to, 10))
+	values.Set("step", getPromQLStep(step, from, to))
+	response, err := common.CURLPerform("POST", getQuerierURL(cmd, "/prom/api/v1/query_range"), nil, values.Encode(),
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		return err
+	}
+	if status := response.Get("status").MustString(); status != "success" {
+		return fmt.Errorf("promql query failed, (%s)", response.Get("error").MustString())
+	}
+
+	// matrix 按 metric/timestamp/value 展开，每个点一行
+	data := response.Get("data")
+	result := &queryResult{columns: []string{"METRIC", "TIMESTAMP", "VALUE"}}
+	for i := range data.Get("result").MustArray() {
+		series := data.Get("result").GetIndex(i)
+		metric := formatPromMetric(series.Get("metric").MustMap())
+		points := series.Get("values")
+		for j := range points.MustArray() {
+			point := points.GetIndex(j)
+			timestamp := time.Unix(int64(point.GetIndex(0).MustFloat64()), 0).Format(time.RFC3339)
+			result.rows = append(result.rows, []string{metric, timestamp, point.GetIndex(1).MustString()})
+		}
+	}
+	return outputQueryResult(cmd, data, result)
+}
+
+func formatPromMetric(metric map[string]interface{}) string {
+	name, _ := metric["__name__"].(string)
+	labels := make([]string, 0, len(metric))
+	for k, v := range metric {
+		if k == "__name__" {
+			continue
+		}
+		labels = append(labels, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(labels)
+	return name + "{" + strings.Join(labels, ", ") + "}"
+}
+
+func executeTrace(cmd *cobra.Command, traceID string) error {
+	if err := checkQueryOutput(cmd); err != nil {
+		return err
+	}
+	from, to, err := getQueryTime(cmd)
+	if err != nil {
+		return fmt.Errorf("parse time error: %v", err)
+	}
+	traceURL := getQuerierURL(cmd, fmt.Sprintf("/api/traces/%s?start=%d&end=%d", url.PathEscape(traceID), from, to))
+	response, err := common.CURLResponseRawJson("GET", traceURL, []common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd))}...)
+	if err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return fmt.Errorf("trace %s not found in time range [%d, %d]", traceID, from, to)
+		}
+		return err
+	}
+
+	// tempo 格式: batches[].resource.attributes, batches[].instrumentationLibrarySpans[].spans[]
+	result := &queryResult{columns: []string{"SPAN_ID", "PARENT_SPAN_ID", "SERVICE", "NAME", "START_TIME", "DURATION"}}
+	type span struct {
+		startTime int64
+		row       []string
+	}
+	var spans []span
+	batches := response.Get("batches")
+	if len(batches.MustArray()) == 0 {
+		return fmt.Errorf("trace %s has no span", traceID)
+	}
+	for i := range batches.MustArray() {
+		batch := batches.GetIndex(i)
+		service := getTraceAttribute(batch.Get("resource").Get("attributes"), "service.name")
+		ils := batch.Get("instrumentationLibrarySpans")
+		for j := range ils.MustArray() {
+			items := ils.GetIndex(j).Get("spans")
+			for k := range items.MustArray() {
+				item := items.GetIndex(k)
+				start, _ := strconv.ParseInt(jsonToString(item.Get("startTimeUnixNano")), 10, 64)
+				end, _ := strconv.ParseInt(jsonToString(item.Get("endTimeUnixNano")), 10, 64)
+				spans = append(spans, span{
+					startTime: start,
+					row: []string{
+						decodeTraceID(item.Get("spanId").MustString()),
+						decodeTraceID(item.Get("parentSpanId").MustString()),
+						service,
+						item.Get("name").MustString(),
+						time.Unix(0, start).Format("2006-01-02T15:04:05.000000Z07:00"),
+						time.Duration(end - start).String(),
+					},
+				})
+			}
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].startTime < spans[j].startTime })
+	for _, s := range spans {
+		result.rows = append(result.rows, s.row)
+	}
+	return outputQueryResult(cmd, response, result)
+}
+
+func getTraceAttribute(attributes *simplejson.Json, key string) string {
+	for i := range attributes.MustArray() {
+		attribute := attributes.GetIndex(i)
+		if attribute.Get("key").MustString() == key {
+			return attribute.Get("value").Get("stringValue").MustString()
+		}
+	}
+	return ""
+}
+
+// jsonpb 将 bytes 类型的 span id 编码为 base64，转换为常见的 hex 格式
+func decodeTraceID(id string) string {
+	if id == "" {
+		return ""
+	}
+	b, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		return id
+	}
+	return hex.EncodeToString(b)
+}
+
+func jsonToString(j *simplejson.Json) string {
+	switch v := j.Interface().(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		b, err := j.MarshalJSON()
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// outputQueryResult json 格式输出接口原始结果，table/csv 输出 result
+func outputQueryResult(cmd *cobra.Command, raw *simplejson.Json, result *queryResult) error {
+	output, _ := cmd.Flags().GetString("output")
+	switch output {
+	case QUERY_OUTPUT_JSON:
+		b, err := raw.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		str, err := common.JsonFormat(b)
+		if err != nil {
+			return err
+		}
+		fmt.Println(str)
+	case QUERY_OUTPUT_CSV:
+		w := csv.NewWriter(os.Stdout)
+		w.Write(result.columns)
+		w.WriteAll(result.rows)
+		return w.Error()
+	default:
+		t := table.New()
+		t.SetHeader(result.columns)
+		t.AppendBulk(result.rows)
+		t.Render()
+		fmt.Fprintf(os.Stderr, "%d rows\n", len(result.rows))
+	}
+	return nil
+}
@@ -27,6 +27,7 @@ const QUEUE_SIZE = 1 << 16
 
 type ControllerIngesterShared struct {
 	ResourceEventQueue *queue.OverwriteQueue
+	AlarmEventQueue    *queue.OverwriteQueue
 }
 
 func NewControllerIngesterShared() *ControllerIngesterShared {
@@ -35,5 +36,9 @@ func NewControllerIngesterShared() *ControllerIngesterShared {
 			"controller-to-ingester-resource_event", QUEUE_SIZE,
 			queue.OptionFlushIndicator(time.Second*3),
 			queue.OptionRelease(func(p interface{}) { p.(*eventapi.ResourceEvent).Release() })),
+		AlarmEventQueue: queue.NewOverwriteQueue(
+			"controller-to-ingester-alarm_event", QUEUE_SIZE,
+			queue.OptionFlushIndicator(time.Second*3),
+			queue.OptionRelease(func(p interface{}) { p.(*eventapi.AlarmEvent).Release() })),
 	}
 }
@@ -0,0 +1,448 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/controller/config"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/election"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+	querierconfig "github.com/deepflowio/deepflow/server/querier/config"
+)
+
+var log = logging.MustGetLogger("alert")
+
+const (
+	ALERT_STATUS_FIRING   = "firing"
+	ALERT_STATUS_RESOLVED = "resolved"
+
+	// the value is the same as enum file policy_app_type
+	POLICY_APP_TYPE_METRIC = 3
+
+	QUERY_URL_PATH = "/v1/query"
+)
+
+var alarmEventQueue *queue.OverwriteQueue
+
+// SetAlarmEventQueue 设置告警事件的发送队列，由同进程的 ingester 写入 event.alarm_event，未设置时只发送通知
+func SetAlarmEventQueue(q *queue.OverwriteQueue) {
+	alarmEventQueue = q
+}
+
+// AlertEvent 为一个分组的告警触发或恢复
+type AlertEvent struct {
+	Status     string
+	EventLevel int // 恢复时为 normal
+	FiredLevel int // 恢复时为恢复前的告警等级
+	Labels     map[string]string
+	Target     string
+	TargetUID  string
+	Value      float64
+	ActiveAt   time.Time
+	Time       time.Time
+}
+
+type seriesState struct {
+	labels     map[string]string
+	value      float64
+	activeAt   time.Time
+	firedAt    time.Time
+	firedLevel int
+}
+
+type ruleState struct {
+	lastEvalAt time.Time
+	series     map[string]*seriesState
+}
+
+// persistedSeries 为保存在 alert_rule.eval_state 中的分组状态
+type persistedSeries struct {
+	Labels     map[string]string `json:"labels"`
+	Value      float64           `json:"value"`
+	ActiveAt   time.Time         `json:"active_at"`
+	FiredAt    time.Time         `json:"fired_at"`
+	FiredLevel int               `json:"fired_level"`
+}
+
+// restoreRuleState 从 alert_rule.eval_state 恢复各分组的状态，切换 master 或重启后仍能发送恢复通知
+func restoreRuleState(rule *mysql.AlertRule) *ruleState {
+	state := &ruleState{series: make(map[string]*seriesState)}
+	if rule.EvalState == "" {
+		return state
+	}
+	persisted := make(map[string]persistedSeries)
+	if err := json.Unmarshal([]byte(rule.EvalState), &persisted); err != nil {
+		log.Warningf("restore state of alert rule (%s) failed: %s", rule.Name, err.Error())
+		return state
+	}
+	for key, s := range persisted {
+		state.series[key] = &seriesState{
+			labels:     s.Labels,
+			value:      s.Value,
+			activeAt:   s.ActiveAt,
+			firedAt:    s.FiredAt,
+			firedLevel: s.FiredLevel,
+		}
+	}
+	return state
+}
+
+func dumpRuleState(state *ruleState) string {
+	if len(state.series) == 0 {
+		return ""
+	}
+	persisted := make(map[string]persistedSeries, len(state.series))
+	for key, s := range state.series {
+		persisted[key] = persistedSeries{
+			Labels:     s.labels,
+			Value:      s.value,
+			ActiveAt:   s.activeAt,
+			FiredAt:    s.firedAt,
+			FiredLevel: s.firedLevel,
+		}
+	}
+	b, _ := json.Marshal(persisted)
+	return string(b)
+}
+
+// Evaluator 按告警规则的计算周期查询 DeepFlow SQL，维护各分组的告警状态，
+// 将告警触发和恢复写入 event.alarm_event 并发送通知，仅在 master controller 运行
+type Evaluator struct {
+	cfg    *config.ControllerConfig
+	pCtx   context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	query  func(ctx context.Context, db, sql string) ([]byte, error)
+	states map[int]*ruleState
+}
+
+func NewEvaluator(ctx context.Context, cfg *config.ControllerConfig) *Evaluator {
+	return &Evaluator{
+		cfg:    cfg,
+		pCtx:   ctx,
+		query:  queryDeepFlowSQL,
+		states: make(map[int]*ruleState),
+	}
+}
+
+func (e *Evaluator) Start() {
+	if !e.cfg.AlertCfg.Enabled {
+		return
+	}
+	e.ctx, e.cancel = context.WithCancel(e.pCtx)
+	// 告警状态在首次计算时从 alert_rule.eval_state 恢复
+	e.states = make(map[int]*ruleState)
+	log.Info("alert evaluator started")
+	go func() {
+		ticker := time.NewTicker(time.Duration(e.cfg.AlertCfg.CheckInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-ticker.C:
+				if election.CheckFencingToken() != nil {
+					continue
+				}
+				e.evaluateDue(time.Now())
+			}
+		}
+	}()
+}
+
+func (e *Evaluator) Stop() {
+	if e.cancel != nil {
+		e.cancel()
+		log.Info("alert evaluator stopped")
+	}
+}
+
+func (e *Evaluator) evaluateDue(now time.Time) {
+	var rules []mysql.AlertRule
+	if err := mysql.Db.Where("enabled = ?", 1).Find(&rules).Error; err != nil {
+		log.Errorf("get alert rules failed: %s", err.Error())
+		return
+	}
+	ruleIDs := make(map[int]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		ruleIDs[rule.ID] = true
+		state, ok := e.states[rule.ID]
+		if !ok {
+			state = restoreRuleState(rule)
+			e.states[rule.ID] = state
+		}
+		if now.Sub(state.lastEvalAt) < time.Duration(rule.EvalInterval)*time.Second {
+			continue
+		}
+		state.lastEvalAt = now
+		e.evaluateRule(rule, state, now)
+	}
+	// 规则被删除或禁用时直接丢弃告警状态
+	for id := range e.states {
+		if !ruleIDs[id] {
+			delete(e.states, id)
+		}
+	}
+}
+
+func (e *Evaluator) evaluateRule(rule *mysql.AlertRule, state *ruleState, now time.Time) {
+	to := now.Unix()
+	sql := renderSQL(rule.QuerySQL, to-int64(rule.EvalRange), to)
+	ctx, cancel := context.WithTimeout(e.ctx, time.Duration(e.cfg.AlertCfg.QueryTimeout)*time.Second)
+	body, err := e.query(ctx, rule.DB, sql)
+	cancel()
+	var samples []sample
+	if err == nil {
+		samples, err = parseQueryResult(body, rule.ValueColumn)
+	}
+	if err != nil {
+		log.Errorf("evaluate alert rule (%s) failed: %s", rule.Name, err.Error())
+		e.updateEvalResult(rule, state, now, err.Error())
+		return
+	}
+	var truncated map[string]bool
+	if maxSeries := e.cfg.AlertCfg.MaxSeriesPerRule; len(samples) > maxSeries {
+		log.Warningf("alert rule (%s) returns %d series, only %d are evaluated", rule.Name, len(samples), maxSeries)
+		samples, truncated = truncateSamples(state, samples, maxSeries)
+	}
+
+	events := updateSeries(rule, state, samples, truncated, now)
+	evalError := ""
+	if len(events) > 0 {
+		for _, event := range events {
+			log.Infof("alert rule (%s) %s: %s, level: %s, value: %v", rule.Name, event.Status, event.Target, eventLevelNames[event.EventLevel], event.Value)
+			writeAlarmEvent(rule, sql, event)
+		}
+		if err := e.notify(rule, events); err != nil {
+			evalError = err.Error()
+		}
+	}
+	e.updateEvalResult(rule, state, now, evalError)
+}
+
+// truncateSamples 截断超出上限的分组，已有状态的分组优先保留，
+// 返回被截断的分组，这些分组本次未评估，保留原有状态，不能视为消失而恢复
+func truncateSamples(state *ruleState, samples []sample, maxSeries int) ([]sample, map[string]bool) {
+	kept := make([]sample, 0, maxSeries)
+	keptKeys := make(map[string]bool, maxSeries)
+	for _, s := range samples {
+		key := seriesKey(s.labels)
+		if len(kept) < maxSeries && state.series[key] != nil && !keptKeys[key] {
+			kept = append(kept, s)
+			keptKeys[key] = true
+		}
+	}
+	truncated := make(map[string]bool)
+	for _, s := range samples {
+		key := seriesKey(s.labels)
+		if keptKeys[key] {
+			continue
+		}
+		if len(kept) < maxSeries && state.series[key] == nil {
+			kept = append(kept, s)
+			keptKeys[key] = true
+		} else {
+			truncated[key] = true
+		}
+	}
+	return kept, truncated
+}
+
+// updateSeries 根据本次查询结果更新各分组的状态，返回需要通知的告警触发和恢复，
+// 连续满足阈值达到 for_duration 后触发，告警等级变化时再次触发，不再满足阈值或分组消失时恢复，
+// truncated 中的分组因超出上限未评估，保持原有状态
+func updateSeries(rule *mysql.AlertRule, state *ruleState, samples []sample, truncated map[string]bool, now time.Time) []*AlertEvent {
+	events := []*AlertEvent{}
+	forDuration := time.Duration(rule.ForDuration) * time.Second
+	seen := make(map[string]bool, len(samples))
+	for _, s := range samples {
+		key := seriesKey(s.labels)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		series := state.series[key]
+		eventLevel := matchLevel(rule, s.value)
+		if eventLevel == 0 {
+			if series != nil {
+				series.value = s.value
+				if !series.firedAt.IsZero() {
+					events = append(events, resolvedEvent(rule, key, series, now))
+				}
+				delete(state.series, key)
+			}
+			continue
+		}
+		if series == nil {
+			series = &seriesState{labels: s.labels, activeAt: now}
+			state.series[key] = series
+		}
+		series.value = s.value
+		if now.Sub(series.activeAt) < forDuration || series.firedLevel == eventLevel {
+			continue
+		}
+		if series.firedAt.IsZero() {
+			series.firedAt = now
+		}
+		series.firedLevel = eventLevel
+		events = append(events, &AlertEvent{
+			Status:     ALERT_STATUS_FIRING,
+			EventLevel: eventLevel,
+			FiredLevel: eventLevel,
+			Labels:     series.labels,
+			Target:     key,
+			TargetUID:  seriesUID(rule.Lcuuid, key),
+			Value:      series.value,
+			ActiveAt:   series.activeAt,
+			Time:       now,
+		})
+	}
+	for key, series := range state.series {
+		if seen[key] || truncated[key] {
+			continue
+		}
+		if !series.firedAt.IsZero() {
+			events = append(events, resolvedEvent(rule, key, series, now))
+		}
+		delete(state.series, key)
+	}
+	return events
+}
+
+func resolvedEvent(rule *mysql.AlertRule, key string, series *seriesState, now time.Time) *AlertEvent {
+	return &AlertEvent{
+		Status:     ALERT_STATUS_RESOLVED,
+		EventLevel: eventapi.ALARM_EVENT_LEVEL_NORMAL,
+		FiredLevel: series.firedLevel,
+		Labels:     series.labels,
+		Target:     key,
+		TargetUID:  seriesUID(rule.Lcuuid, key),
+		Value:      series.value,
+		ActiveAt:   series.activeAt,
+		Time:       now,
+	}
+}
+
+func writeAlarmEvent(rule *mysql.AlertRule, sql string, event *AlertEvent) {
+	if alarmEventQueue == nil {
+		return
+	}
+	e := eventapi.AcquireAlarmEvent()
+	e.Time = uint32(event.Time.Unix())
+	e.Lcuuid = uuid.New().String()
+	e.PolicyId = uint32(rule.ID)
+	e.PolicyName = rule.Name
+	e.PolicyLevel = uint32(rule.Level)
+	e.PolicyAppType = POLICY_APP_TYPE_METRIC
+	e.PolicyDataLevel = rule.DB
+	e.PolicyTargetUid = event.TargetUID
+	e.PolicyTargetName = event.Target
+	e.PolicyTargetField = rule.ValueColumn
+	e.TriggerCondition = triggerCondition(rule, event.FiredLevel)
+	e.TriggerValue = event.Value
+	e.ValueUnit = rule.ValueUnit
+	e.EventLevel = uint32(event.EventLevel)
+	e.AlarmTarget = event.Target
+	e.PolicyQueryUrl = QUERY_URL_PATH
+	e.PolicyQueryConditions = sql
+	e.PolicyThresholdCritical = formatThreshold(rule.ThresholdCritical)
+	e.PolicyThresholdError = formatThreshold(rule.ThresholdError)
+	e.PolicyThresholdWarning = formatThreshold(rule.ThresholdWarning)
+	alarmEventQueue.Put(e)
+}
+
+func (e *Evaluator) notify(rule *mysql.AlertRule, events []*AlertEvent) error {
+	if rule.ChannelLcuuids == "" {
+		return nil
+	}
+	var channels []mysql.AlertChannel
+	if err := mysql.Db.Where("lcuuid IN (?)", strings.Split(rule.ChannelLcuuids, ",")).Find(&channels).Error; err != nil {
+		return err
+	}
+	errs := []string{}
+	for i := range channels {
+		channel := &channels[i]
+		err := NotifyWithRetry(e.ctx, channel, rule, events, time.Duration(e.cfg.AlertCfg.NotifyTimeout)*time.Second,
+			e.cfg.AlertCfg.NotifyRetryCount, time.Duration(e.cfg.AlertCfg.NotifyRetryInterval)*time.Second)
+		if err != nil {
+			log.Errorf("send alert rule (%s) notification to channel (%s) failed: %s", rule.Name, channel.Name, err.Error())
+			errs = append(errs, fmt.Sprintf("notify channel (%s) failed: %s", channel.Name, err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// updateEvalResult 记录最近一次计算的时间、错误和各分组的状态，不修改规则的 updated_at
+func (e *Evaluator) updateEvalResult(rule *mysql.AlertRule, state *ruleState, now time.Time, evalError string) {
+	err := mysql.Db.Model(rule).UpdateColumns(map[string]interface{}{
+		"last_eval_at":    now,
+		"last_eval_error": evalError,
+		"eval_state":      dumpRuleState(state),
+		"updated_at":      rule.UpdatedAt,
+	}).Error
+	if err != nil {
+		log.Errorf("update eval result of alert rule (%s) failed: %s", rule.Name, err.Error())
+	}
+}
+
+func queryDeepFlowSQL(ctx context.Context, db, sql string) ([]byte, error) {
+	queryURL := fmt.Sprintf("http://deepflow-server:%d%s", querierconfig.Cfg.ListenPort, QUERY_URL_PATH)
+	values := url.Values{}
+	values.Add("db", db)
+	values.Add("sql", sql)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, queryURL, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("curl (%s) failed, err: %s", queryURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// SQL 错误时返回 querier 的错误描述
+		var errResp queryResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Description != "" {
+			return nil, fmt.Errorf("query failed: %s", errResp.Description)
+		}
+		return nil, fmt.Errorf("curl (%s) failed, status code: %d, body: %s", queryURL, resp.StatusCode, string(body))
+	}
+	return body, nil
+}
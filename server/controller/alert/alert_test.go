@@ -0,0 +1,354 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+)
+
+func newTestRule() *mysql.AlertRule {
+	critical, warning := float64(100), float64(50)
+	return &mysql.AlertRule{
+		ID:                1,
+		Name:              "rrt",
+		DB:                "flow_metrics",
+		ValueColumn:       DEFAULT_VALUE_COLUMN,
+		ValueUnit:         "us",
+		Comparator:        ">",
+		ThresholdCritical: &critical,
+		ThresholdWarning:  &warning,
+		ForDuration:       60,
+		Lcuuid:            "rule-lcuuid",
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	threshold := float64(1)
+	rule := &model.AlertRuleCreate{
+		Name:             "rrt",
+		DB:               "flow_metrics",
+		SQL:              " SELECT Avg(rrt) AS value FROM network.1m WHERE $__timeFilter ",
+		ThresholdWarning: &threshold,
+	}
+	if err := ValidateRule(rule); err != nil {
+		t.Fatalf("ValidateRule() error = %v", err)
+	}
+	if *rule.Enabled != 1 || *rule.Level != RULE_LEVEL_MEDIUM || rule.ValueColumn != DEFAULT_VALUE_COLUMN ||
+		rule.Comparator != DEFAULT_COMPARATOR || rule.EvalInterval != DEFAULT_EVAL_INTERVAL || rule.EvalRange != DEFAULT_EVAL_RANGE {
+		t.Errorf("ValidateRule() defaults not filled: %+v", rule)
+	}
+	if rule.SQL != "SELECT Avg(rrt) AS value FROM network.1m WHERE $__timeFilter" {
+		t.Errorf("ValidateRule() sql = %q", rule.SQL)
+	}
+
+	invalids := map[string]func(r *model.AlertRuleCreate){
+		"not select":     func(r *model.AlertRuleCreate) { r.SQL = "SHOW tags FROM network.1m WHERE $__timeFilter" },
+		"no placeholder": func(r *model.AlertRuleCreate) { r.SQL = "SELECT Avg(rrt) AS value FROM network.1m" },
+		"no threshold":   func(r *model.AlertRuleCreate) { r.ThresholdWarning = nil },
+		"bad comparator": func(r *model.AlertRuleCreate) { r.Comparator = "=~" },
+		"short interval": func(r *model.AlertRuleCreate) { r.EvalInterval = 1 },
+		"long range":     func(r *model.AlertRuleCreate) { r.EvalRange = MAX_EVAL_RANGE + 1 },
+	}
+	for name, modify := range invalids {
+		r := *rule
+		modify(&r)
+		if err := ValidateRule(&r); err == nil {
+			t.Errorf("ValidateRule() with %s should fail", name)
+		}
+	}
+}
+
+func TestRenderSQL(t *testing.T) {
+	got := renderSQL("SELECT * FROM t WHERE $__timeFilter AND x>$__from AND y<$__to", 100, 400)
+	want := "SELECT * FROM t WHERE time>=100 AND time<=400 AND x>100 AND y<400"
+	if got != want {
+		t.Errorf("renderSQL() = %q, want %q", got, want)
+	}
+}
+
+func TestMatchLevel(t *testing.T) {
+	rule := newTestRule()
+	cases := map[float64]int{
+		150: eventapi.ALARM_EVENT_LEVEL_CRITICAL,
+		100: eventapi.ALARM_EVENT_LEVEL_WARNING,
+		60:  eventapi.ALARM_EVENT_LEVEL_WARNING,
+		10:  0,
+	}
+	for value, want := range cases {
+		if got := matchLevel(rule, value); got != want {
+			t.Errorf("matchLevel(%v) = %d, want %d", value, got, want)
+		}
+	}
+	if got := triggerCondition(rule, eventapi.ALARM_EVENT_LEVEL_CRITICAL); got != "value > 100" {
+		t.Errorf("triggerCondition() = %q", got)
+	}
+}
+
+func TestParseQueryResult(t *testing.T) {
+	body := []byte(`{"OPT_STATUS":"SUCCESS","result":{"columns":["pod","value"],"values":[["a",1.5],["b",null],["c","2"]]}}`)
+	samples, err := parseQueryResult(body, "value")
+	if err != nil {
+		t.Fatalf("parseQueryResult() error = %v", err)
+	}
+	if len(samples) != 2 || samples[0].labels["pod"] != "a" || samples[0].value != 1.5 || samples[1].value != 2 {
+		t.Errorf("parseQueryResult() = %+v", samples)
+	}
+	if _, err := parseQueryResult(body, "rrt"); err == nil {
+		t.Error("parseQueryResult() with missing value column should fail")
+	}
+	if _, err := parseQueryResult([]byte(`{"OPT_STATUS":"FAIL","DESCRIPTION":"bad sql"}`), "value"); err == nil || err.Error() != "bad sql" {
+		t.Errorf("parseQueryResult() error = %v, want bad sql", err)
+	}
+}
+
+func TestUpdateSeries(t *testing.T) {
+	rule := newTestRule()
+	state := &ruleState{series: make(map[string]*seriesState)}
+	start := time.Unix(1700000000, 0)
+	a := map[string]string{"pod": "a"}
+	b := map[string]string{"pod": "b"}
+
+	// 未达到 for_duration 时不触发
+	if events := updateSeries(rule, state, []sample{{a, 60}, {b, 10}}, nil, start); len(events) != 0 {
+		t.Fatalf("pending series should not fire, got %d events", len(events))
+	}
+	if len(state.series) != 1 {
+		t.Fatalf("series = %d, want 1", len(state.series))
+	}
+
+	events := updateSeries(rule, state, []sample{{a, 60}}, nil, start.Add(time.Minute))
+	if len(events) != 1 || events[0].Status != ALERT_STATUS_FIRING || events[0].EventLevel != eventapi.ALARM_EVENT_LEVEL_WARNING ||
+		events[0].Target != "pod=a" || !events[0].ActiveAt.Equal(start) {
+		t.Fatalf("expect warning firing, got %+v", events)
+	}
+
+	// 等级不变时不重复通知
+	if events := updateSeries(rule, state, []sample{{a, 70}}, nil, start.Add(2*time.Minute)); len(events) != 0 {
+		t.Fatalf("unchanged level should not notify, got %d events", len(events))
+	}
+
+	events = updateSeries(rule, state, []sample{{a, 200}}, nil, start.Add(3*time.Minute))
+	if len(events) != 1 || events[0].EventLevel != eventapi.ALARM_EVENT_LEVEL_CRITICAL {
+		t.Fatalf("expect critical firing, got %+v", events)
+	}
+
+	// 分组消失时恢复
+	events = updateSeries(rule, state, nil, nil, start.Add(4*time.Minute))
+	if len(events) != 1 || events[0].Status != ALERT_STATUS_RESOLVED || events[0].EventLevel != eventapi.ALARM_EVENT_LEVEL_NORMAL ||
+		events[0].FiredLevel != eventapi.ALARM_EVENT_LEVEL_CRITICAL || events[0].Value != 200 {
+		t.Fatalf("expect resolved, got %+v", events)
+	}
+	if len(state.series) != 0 {
+		t.Errorf("series = %d, want 0", len(state.series))
+	}
+
+	// pending 的分组恢复阈值时不通知
+	rule.ForDuration = 0
+	if events := updateSeries(rule, state, []sample{{b, 60}}, nil, start.Add(5*time.Minute)); len(events) != 1 {
+		t.Fatalf("expect firing without for_duration, got %d events", len(events))
+	}
+	events = updateSeries(rule, state, []sample{{b, 1}}, nil, start.Add(6*time.Minute))
+	if len(events) != 1 || events[0].Status != ALERT_STATUS_RESOLVED || events[0].Value != 1 {
+		t.Fatalf("expect resolved below threshold, got %+v", events)
+	}
+}
+
+func TestTruncateSamples(t *testing.T) {
+	rule := newTestRule()
+	rule.ForDuration = 0
+	state := &ruleState{series: make(map[string]*seriesState)}
+	start := time.Unix(1700000000, 0)
+	a := map[string]string{"pod": "a"}
+	b := map[string]string{"pod": "b"}
+	c := map[string]string{"pod": "c"}
+
+	if events := updateSeries(rule, state, []sample{{a, 60}, {b, 60}}, nil, start); len(events) != 2 {
+		t.Fatalf("expect 2 firing, got %d events", len(events))
+	}
+	// 已有状态的分组优先保留，新分组被截断
+	samples, truncated := truncateSamples(state, []sample{{c, 60}, {b, 60}, {a, 60}}, 2)
+	if len(samples) != 2 || seriesKey(samples[0].labels) != "pod=b" || seriesKey(samples[1].labels) != "pod=a" ||
+		len(truncated) != 1 || !truncated["pod=c"] {
+		t.Fatalf("truncateSamples() = %v, %v, want b and a kept, c truncated", samples, truncated)
+	}
+	// 被截断的已触发分组不能被恢复
+	samples, truncated = truncateSamples(state, []sample{{c, 60}, {b, 60}, {a, 60}}, 1)
+	if len(samples) != 1 || !truncated["pod=a"] || !truncated["pod=c"] {
+		t.Fatalf("truncateSamples() = %v, %v, want only b kept", samples, truncated)
+	}
+	if events := updateSeries(rule, state, samples, truncated, start.Add(time.Minute)); len(events) != 0 {
+		t.Fatalf("truncated series should not resolve, got %+v", events)
+	}
+	if state.series["pod=a"] == nil || state.series["pod=a"].firedAt.IsZero() {
+		t.Errorf("state of truncated series pod=a should be kept")
+	}
+}
+
+func TestRestoreRuleState(t *testing.T) {
+	rule := newTestRule()
+	state := &ruleState{series: make(map[string]*seriesState)}
+	start := time.Unix(1700000000, 0)
+	a := map[string]string{"pod": "a"}
+	b := map[string]string{"pod": "b"}
+
+	updateSeries(rule, state, []sample{{a, 150}}, nil, start)
+	if events := updateSeries(rule, state, []sample{{a, 150}, {b, 60}}, nil, start.Add(time.Minute)); len(events) != 1 {
+		t.Fatalf("expect firing, got %d events", len(events))
+	}
+
+	// 重启后从 eval_state 恢复，已触发的分组恢复阈值时发送恢复通知
+	rule.EvalState = dumpRuleState(state)
+	restored := restoreRuleState(rule)
+	if len(restored.series) != 2 {
+		t.Fatalf("restored series = %d, want 2", len(restored.series))
+	}
+	events := updateSeries(rule, restored, []sample{{a, 1}, {b, 60}}, nil, start.Add(90*time.Second))
+	if len(events) != 1 || events[0].Status != ALERT_STATUS_RESOLVED || events[0].Target != "pod=a" ||
+		events[0].FiredLevel != eventapi.ALARM_EVENT_LEVEL_CRITICAL || !events[0].ActiveAt.Equal(start) {
+		t.Fatalf("expect resolved after restart, got %+v", events)
+	}
+	// pending 的分组保留 active_at，达到 for_duration 后触发
+	events = updateSeries(rule, restored, []sample{{b, 60}}, nil, start.Add(2*time.Minute))
+	if len(events) != 1 || events[0].Status != ALERT_STATUS_FIRING || events[0].Target != "pod=b" {
+		t.Fatalf("expect pending series to fire after restart, got %+v", events)
+	}
+
+	rule.EvalState = "{"
+	if restored := restoreRuleState(rule); len(restored.series) != 0 {
+		t.Errorf("restore invalid state got %d series, want 0", len(restored.series))
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	cases := []struct {
+		channelType string
+		config      string
+		valid       bool
+	}{
+		{CHANNEL_TYPE_EMAIL, `{"to":["ops@example.com"]}`, true},
+		{CHANNEL_TYPE_EMAIL, `{"to":[]}`, false},
+		{CHANNEL_TYPE_EMAIL, `{"to":["ops"]}`, false},
+		{CHANNEL_TYPE_WEBHOOK, `{"url":"http://example.com/hook","headers":{"X-Token":"t"}}`, true},
+		{CHANNEL_TYPE_WEBHOOK, `{"url":"example.com"}`, false},
+		{CHANNEL_TYPE_WEBHOOK, `{"url":"http://example.com","token":"t"}`, false},
+		{CHANNEL_TYPE_SLACK, `{"url":"https://hooks.slack.com/services/x"}`, true},
+		{CHANNEL_TYPE_PAGERDUTY, `{"routing_key":"key"}`, true},
+		{CHANNEL_TYPE_PAGERDUTY, `{}`, false},
+		{"sms", `{}`, false},
+	}
+	for _, c := range cases {
+		_, err := newNotifier(c.channelType, c.config, time.Second)
+		if (err == nil) != c.valid {
+			t.Errorf("newNotifier(%s, %s) error = %v, want valid %v", c.channelType, c.config, err, c.valid)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var bodies []map[string]interface{}
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Token")
+		b, _ := io.ReadAll(r.Body)
+		body := make(map[string]interface{})
+		json.Unmarshal(b, &body)
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	rule := newTestRule()
+	now := time.Unix(1700000000, 0)
+	events := []*AlertEvent{
+		{Status: ALERT_STATUS_FIRING, EventLevel: eventapi.ALARM_EVENT_LEVEL_CRITICAL, FiredLevel: eventapi.ALARM_EVENT_LEVEL_CRITICAL,
+			Labels: map[string]string{"pod": "a"}, Target: "pod=a", TargetUID: "1", Value: 150, ActiveAt: now, Time: now},
+		{Status: ALERT_STATUS_RESOLVED, EventLevel: eventapi.ALARM_EVENT_LEVEL_NORMAL, FiredLevel: eventapi.ALARM_EVENT_LEVEL_WARNING,
+			Labels: map[string]string{"pod": "b"}, Target: "pod=b", TargetUID: "2", Value: 1, ActiveAt: now, Time: now},
+	}
+
+	channel := &mysql.AlertChannel{Type: CHANNEL_TYPE_WEBHOOK, Config: `{"url":"` + server.URL + `","headers":{"X-Token":"t"}}`}
+	if err := Notify(context.Background(), channel, rule, events, time.Second); err != nil {
+		t.Fatalf("webhook Notify() error = %v", err)
+	}
+	alerts := bodies[0]["alerts"].([]interface{})
+	firing := alerts[0].(map[string]interface{})
+	if token != "t" || len(alerts) != 2 || firing["event_level_name"] != "critical" || firing["condition"] != "value > 100" ||
+		bodies[0]["rule"].(map[string]interface{})["lcuuid"] != rule.Lcuuid {
+		t.Errorf("unexpected webhook payload: %v", bodies[0])
+	}
+
+	channel = &mysql.AlertChannel{Type: CHANNEL_TYPE_SLACK, Config: `{"url":"` + server.URL + `"}`}
+	if err := Notify(context.Background(), channel, rule, events, time.Second); err != nil {
+		t.Fatalf("slack Notify() error = %v", err)
+	}
+	if text, _ := bodies[1]["text"].(string); !strings.HasPrefix(text, "*[DeepFlow][FIRING:1, RESOLVED:1] rrt*\n") {
+		t.Errorf("unexpected slack payload: %v", bodies[1])
+	}
+
+	channel = &mysql.AlertChannel{Type: CHANNEL_TYPE_PAGERDUTY, Config: `{"routing_key":"key","url":"` + server.URL + `"}`}
+	if err := Notify(context.Background(), channel, rule, events, time.Second); err != nil {
+		t.Fatalf("pagerduty Notify() error = %v", err)
+	}
+	trigger, resolve := bodies[2], bodies[3]
+	payload, _ := trigger["payload"].(map[string]interface{})
+	if trigger["event_action"] != "trigger" || trigger["dedup_key"] != "rule-lcuuid-1" || payload["severity"] != "critical" {
+		t.Errorf("unexpected pagerduty trigger: %v", trigger)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != "rule-lcuuid-2" || resolve["payload"] != nil {
+		t.Errorf("unexpected pagerduty resolve: %v", resolve)
+	}
+}
+
+func TestNotifyWithRetry(t *testing.T) {
+	var requests int
+	statusCodes := []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCodes[requests%len(statusCodes)])
+		requests++
+	}))
+	defer server.Close()
+
+	rule := newTestRule()
+	now := time.Unix(1700000000, 0)
+	events := []*AlertEvent{{Status: ALERT_STATUS_FIRING, EventLevel: eventapi.ALARM_EVENT_LEVEL_WARNING, Target: "pod=a", ActiveAt: now, Time: now}}
+	channel := &mysql.AlertChannel{Type: CHANNEL_TYPE_WEBHOOK, Config: `{"url":"` + server.URL + `"}`}
+	if err := NotifyWithRetry(context.Background(), channel, rule, events, time.Second, 2, time.Millisecond); err != nil || requests != 3 {
+		t.Fatalf("NotifyWithRetry() error = %v, requests = %d, want success after 3 requests", err, requests)
+	}
+
+	requests = 0
+	if err := NotifyWithRetry(context.Background(), channel, rule, events, time.Second, 1, time.Millisecond); err == nil || requests != 2 {
+		t.Fatalf("NotifyWithRetry() error = %v, requests = %d, want failure after 2 requests", err, requests)
+	}
+
+	// 4xx 不重试
+	statusCodes = []int{http.StatusUnauthorized}
+	requests = 0
+	if err := NotifyWithRetry(context.Background(), channel, rule, events, time.Second, 2, time.Millisecond); err == nil || requests != 1 {
+		t.Fatalf("NotifyWithRetry() error = %v, requests = %d, want failure without retry", err, requests)
+	}
+}
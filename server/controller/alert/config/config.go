@@ -0,0 +1,27 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+type Config struct {
+	Enabled             bool `default:"true" yaml:"enabled"`
+	CheckInterval       int  `default:"10" yaml:"check-interval"`        // unit: second, 检查告警规则是否到达计算周期的间隔
+	QueryTimeout        int  `default:"30" yaml:"query-timeout"`         // unit: second
+	NotifyTimeout       int  `default:"10" yaml:"notify-timeout"`        // unit: second
+	NotifyRetryCount    int  `default:"2" yaml:"notify-retry-count"`     // 通知发送失败后的重试次数
+	NotifyRetryInterval int  `default:"5" yaml:"notify-retry-interval"`  // unit: second, 首次重试的间隔，之后逐次翻倍
+	MaxSeriesPerRule    int  `default:"1000" yaml:"max-series-per-rule"` // 单个规则查询结果的最大分组数，超出部分不评估并保留原有状态
+}
@@ -0,0 +1,491 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+)
+
+const (
+	CHANNEL_TYPE_EMAIL     = "email"
+	CHANNEL_TYPE_WEBHOOK   = "webhook"
+	CHANNEL_TYPE_SLACK     = "slack"
+	CHANNEL_TYPE_PAGERDUTY = "pagerduty"
+
+	PAGERDUTY_EVENTS_URL = "https://events.pagerduty.com/v2/enqueue"
+
+	MAIL_SERVER_STATUS_ENABLED = 1
+)
+
+// 各通知渠道的配置，保存在 alert_channel.config 中
+type EmailConfig struct {
+	To []string `json:"to"`
+}
+
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+// SlackConfig 也适用于 Mattermost、Rocket.Chat 等兼容 Slack incoming webhook 的服务
+type SlackConfig struct {
+	URL string `json:"url"`
+}
+
+type PagerDutyConfig struct {
+	RoutingKey string `json:"routing_key"`
+	URL        string `json:"url"` // default: https://events.pagerduty.com/v2/enqueue
+}
+
+type notifier interface {
+	notify(ctx context.Context, rule *mysql.AlertRule, events []*AlertEvent) error
+}
+
+func decodeChannelConfig(config string, v interface{}) error {
+	if config == "" {
+		config = "{}"
+	}
+	decoder := json.NewDecoder(strings.NewReader(config))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url (%s), http or https url is required", s)
+	}
+	return nil
+}
+
+// newNotifier 根据渠道类型解析并校验配置
+func newNotifier(channelType, config string, timeout time.Duration) (notifier, error) {
+	client := &http.Client{Timeout: timeout}
+	switch channelType {
+	case CHANNEL_TYPE_EMAIL:
+		n := &emailNotifier{timeout: timeout}
+		if err := decodeChannelConfig(config, &n.config); err != nil {
+			return nil, err
+		}
+		if len(n.config.To) == 0 {
+			return nil, errors.New("to is required")
+		}
+		for _, to := range n.config.To {
+			if _, err := mail.ParseAddress(to); err != nil {
+				return nil, fmt.Errorf("invalid email address (%s)", to)
+			}
+		}
+		return n, nil
+	case CHANNEL_TYPE_WEBHOOK:
+		n := &webhookNotifier{client: client}
+		if err := decodeChannelConfig(config, &n.config); err != nil {
+			return nil, err
+		}
+		return n, validateURL(n.config.URL)
+	case CHANNEL_TYPE_SLACK:
+		n := &slackNotifier{client: client}
+		if err := decodeChannelConfig(config, &n.config); err != nil {
+			return nil, err
+		}
+		return n, validateURL(n.config.URL)
+	case CHANNEL_TYPE_PAGERDUTY:
+		n := &pagerDutyNotifier{client: client}
+		if err := decodeChannelConfig(config, &n.config); err != nil {
+			return nil, err
+		}
+		if n.config.RoutingKey == "" {
+			return nil, errors.New("routing_key is required")
+		}
+		if n.config.URL == "" {
+			n.config.URL = PAGERDUTY_EVENTS_URL
+		}
+		return n, validateURL(n.config.URL)
+	}
+	return nil, fmt.Errorf("invalid channel type (%s), support: email, webhook, slack, pagerduty", channelType)
+}
+
+// ValidateChannel 校验通知渠道的类型和配置
+func ValidateChannel(channelType string, config map[string]interface{}) error {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	_, err = newNotifier(channelType, string(b), 0)
+	return err
+}
+
+// Notify 将一次计算产生的告警触发和恢复发送到通知渠道
+func Notify(ctx context.Context, channel *mysql.AlertChannel, rule *mysql.AlertRule, events []*AlertEvent, timeout time.Duration) error {
+	return NotifyWithRetry(ctx, channel, rule, events, timeout, 0, 0)
+}
+
+// NotifyWithRetry 发送失败时最多重试 retryCount 次，重试间隔从 retryInterval 开始逐次翻倍，
+// 渠道返回 4xx（429 除外）时说明请求本身有误，不再重试
+func NotifyWithRetry(ctx context.Context, channel *mysql.AlertChannel, rule *mysql.AlertRule, events []*AlertEvent,
+	timeout time.Duration, retryCount int, retryInterval time.Duration) error {
+	n, err := newNotifier(channel.Type, channel.Config, timeout)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		err = n.notify(ctx, rule, events)
+		if err == nil || i >= retryCount || !retryable(err) {
+			return err
+		}
+		log.Warningf("send alert rule (%s) notification to channel (%s) failed: %s, retry %d/%d", rule.Name, channel.Name, err.Error(), i+1, retryCount)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryInterval << i):
+		}
+	}
+}
+
+// statusError 为通知渠道返回的非 2xx 响应
+type statusError struct {
+	endpoint   string
+	statusCode int
+	body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("post %s failed, status code: %d, body: %s", e.endpoint, e.statusCode, e.body)
+}
+
+func retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.statusCode >= http.StatusInternalServerError || se.statusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// SendTestNotification 发送一条测试告警，用于检查通知渠道的配置
+func SendTestNotification(ctx context.Context, channel *mysql.AlertChannel, timeout time.Duration) error {
+	threshold := float64(0)
+	rule := &mysql.AlertRule{
+		Name:             "test alert of channel " + channel.Name,
+		Lcuuid:           "test-" + channel.Lcuuid,
+		ValueColumn:      DEFAULT_VALUE_COLUMN,
+		Comparator:       DEFAULT_COMPARATOR,
+		ThresholdWarning: &threshold,
+	}
+	now := time.Now()
+	labels := map[string]string{"channel": channel.Name}
+	event := &AlertEvent{
+		Status:     ALERT_STATUS_FIRING,
+		EventLevel: eventapi.ALARM_EVENT_LEVEL_WARNING,
+		FiredLevel: eventapi.ALARM_EVENT_LEVEL_WARNING,
+		Labels:     labels,
+		Target:     seriesKey(labels),
+		TargetUID:  seriesUID(rule.Lcuuid, seriesKey(labels)),
+		Value:      1,
+		ActiveAt:   now,
+		Time:       now,
+	}
+	return Notify(ctx, channel, rule, []*AlertEvent{event}, timeout)
+}
+
+func formatValue(rule *mysql.AlertRule, value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64) + rule.ValueUnit
+}
+
+// formatMessage 返回文本形式的通知标题和内容，用于邮件和 Slack
+func formatMessage(rule *mysql.AlertRule, events []*AlertEvent) (string, string) {
+	firing, resolved := 0, 0
+	for _, event := range events {
+		if event.Status == ALERT_STATUS_FIRING {
+			firing++
+		} else {
+			resolved++
+		}
+	}
+	status := []string{}
+	if firing > 0 {
+		status = append(status, fmt.Sprintf("FIRING:%d", firing))
+	}
+	if resolved > 0 {
+		status = append(status, fmt.Sprintf("RESOLVED:%d", resolved))
+	}
+	title := fmt.Sprintf("[DeepFlow][%s] %s", strings.Join(status, ", "), rule.Name)
+
+	lines := []string{}
+	if rule.Description != "" {
+		lines = append(lines, rule.Description)
+	}
+	for _, event := range events {
+		target := event.Target
+		if target == "" {
+			target = "-"
+		}
+		line := fmt.Sprintf("[%s] %s: %s, value: %s, condition: %s, since: %s",
+			strings.ToUpper(eventLevelNames[event.EventLevel]), event.Status, target, formatValue(rule, event.Value),
+			triggerCondition(rule, event.FiredLevel), event.ActiveAt.Format(time.RFC3339))
+		lines = append(lines, line)
+	}
+	return title, strings.Join(lines, "\n")
+}
+
+func postJSON(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &statusError{endpoint: endpoint, statusCode: resp.StatusCode, body: string(respBody)}
+	}
+	return nil
+}
+
+type webhookNotifier struct {
+	client *http.Client
+	config WebhookConfig
+}
+
+type webhookRule struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Level       int    `json:"level"`
+	Lcuuid      string `json:"lcuuid"`
+}
+
+type webhookAlert struct {
+	Status         string            `json:"status"`
+	EventLevel     int               `json:"event_level"`
+	EventLevelName string            `json:"event_level_name"`
+	Labels         map[string]string `json:"labels"`
+	Target         string            `json:"target"`
+	TargetUID      string            `json:"target_uid"`
+	Value          float64           `json:"value"`
+	ValueUnit      string            `json:"value_unit"`
+	Condition      string            `json:"condition"`
+	ActiveAt       int64             `json:"active_at"`
+	Time           int64             `json:"time"`
+}
+
+type webhookPayload struct {
+	Rule   webhookRule    `json:"rule"`
+	Alerts []webhookAlert `json:"alerts"`
+}
+
+func newWebhookPayload(rule *mysql.AlertRule, events []*AlertEvent) *webhookPayload {
+	payload := &webhookPayload{
+		Rule: webhookRule{
+			ID:          rule.ID,
+			Name:        rule.Name,
+			Description: rule.Description,
+			Level:       rule.Level,
+			Lcuuid:      rule.Lcuuid,
+		},
+		Alerts: make([]webhookAlert, 0, len(events)),
+	}
+	for _, event := range events {
+		payload.Alerts = append(payload.Alerts, webhookAlert{
+			Status:         event.Status,
+			EventLevel:     event.EventLevel,
+			EventLevelName: eventLevelNames[event.EventLevel],
+			Labels:         event.Labels,
+			Target:         event.Target,
+			TargetUID:      event.TargetUID,
+			Value:          event.Value,
+			ValueUnit:      rule.ValueUnit,
+			Condition:      triggerCondition(rule, event.FiredLevel),
+			ActiveAt:       event.ActiveAt.Unix(),
+			Time:           event.Time.Unix(),
+		})
+	}
+	return payload
+}
+
+func (n *webhookNotifier) notify(ctx context.Context, rule *mysql.AlertRule, events []*AlertEvent) error {
+	return postJSON(ctx, n.client, n.config.URL, n.config.Headers, newWebhookPayload(rule, events))
+}
+
+type slackNotifier struct {
+	client *http.Client
+	config SlackConfig
+}
+
+func (n *slackNotifier) notify(ctx context.Context, rule *mysql.AlertRule, events []*AlertEvent) error {
+	title, content := formatMessage(rule, events)
+	return postJSON(ctx, n.client, n.config.URL, nil, map[string]string{"text": "*" + title + "*\n" + content})
+}
+
+type pagerDutyNotifier struct {
+	client *http.Client
+	config PagerDutyConfig
+}
+
+var pagerDutySeverities = map[int]string{
+	eventapi.ALARM_EVENT_LEVEL_CRITICAL: "critical",
+	eventapi.ALARM_EVENT_LEVEL_ERROR:    "error",
+	eventapi.ALARM_EVENT_LEVEL_WARNING:  "warning",
+}
+
+// newPagerDutyEvent 返回 Events API v2 的请求体，同一分组使用相同的 dedup_key 以便恢复时关闭告警
+func newPagerDutyEvent(routingKey string, rule *mysql.AlertRule, event *AlertEvent) map[string]interface{} {
+	pdEvent := map[string]interface{}{
+		"routing_key": routingKey,
+		"dedup_key":   rule.Lcuuid + "-" + event.TargetUID,
+	}
+	if event.Status == ALERT_STATUS_RESOLVED {
+		pdEvent["event_action"] = "resolve"
+		return pdEvent
+	}
+	summary := rule.Name
+	if event.Target != "" {
+		summary += ": " + event.Target
+	}
+	details := map[string]interface{}{
+		"value":     formatValue(rule, event.Value),
+		"condition": triggerCondition(rule, event.FiredLevel),
+	}
+	for key, value := range event.Labels {
+		details[key] = value
+	}
+	pdEvent["event_action"] = "trigger"
+	pdEvent["payload"] = map[string]interface{}{
+		"summary":        summary,
+		"source":         "deepflow",
+		"severity":       pagerDutySeverities[event.EventLevel],
+		"timestamp":      event.Time.Format(time.RFC3339),
+		"component":      rule.DB,
+		"custom_details": details,
+	}
+	return pdEvent
+}
+
+func (n *pagerDutyNotifier) notify(ctx context.Context, rule *mysql.AlertRule, events []*AlertEvent) error {
+	for _, event := range events {
+		if err := postJSON(ctx, n.client, n.config.URL, nil, newPagerDutyEvent(n.config.RoutingKey, rule, event)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type emailNotifier struct {
+	timeout time.Duration
+	config  EmailConfig
+}
+
+// notify 使用 /v1/mail-server/ 中第一个启用的邮件服务器发送
+func (n *emailNotifier) notify(ctx context.Context, rule *mysql.AlertRule, events []*AlertEvent) error {
+	var mailServer mysql.MailServer
+	if err := mysql.Db.Where("status = ?", MAIL_SERVER_STATUS_ENABLED).Order("id").Limit(1).Find(&mailServer).Error; err != nil {
+		return err
+	}
+	if mailServer.ID == 0 {
+		return errors.New("no enabled mail server")
+	}
+	title, content := formatMessage(rule, events)
+	return sendMail(ctx, &mailServer, n.config.To, title, content, n.timeout)
+}
+
+// sendMail security 为 SSL 时使用 TLS 连接，为 TLS 或 STARTTLS 时使用 STARTTLS
+func sendMail(ctx context.Context, mailServer *mysql.MailServer, to []string, subject, content string, timeout time.Duration) error {
+	addr := net.JoinHostPort(mailServer.Host, strconv.Itoa(mailServer.Port))
+	tlsConfig := &tls.Config{ServerName: mailServer.Host}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	security := strings.ToUpper(mailServer.Security)
+	if security == "SSL" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	client, err := smtp.NewClient(conn, mailServer.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if security == "TLS" || security == "STARTTLS" {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if mailServer.Password != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err = client.Auth(smtp.PlainAuth("", mailServer.User, mailServer.Password, mailServer.Host)); err != nil {
+				return err
+			}
+		}
+	}
+	if err = client.Mail(mailServer.User); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err = client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		mailServer.User, strings.Join(to, ", "), mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(content, "\n", "\r\n"))
+	if _, err = w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
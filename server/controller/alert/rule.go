@@ -0,0 +1,275 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+)
+
+const (
+	DEFAULT_VALUE_COLUMN  = "value"
+	DEFAULT_COMPARATOR    = ">"
+	DEFAULT_EVAL_INTERVAL = 60
+	DEFAULT_EVAL_RANGE    = 300
+	MIN_EVAL_INTERVAL     = 10
+	MAX_EVAL_RANGE        = 86400
+
+	RULE_LEVEL_LOW    = 0
+	RULE_LEVEL_MEDIUM = 1
+	RULE_LEVEL_HIGH   = 2
+
+	// SQL 中的时间占位符，计算时替换为 [now - eval_range, now]
+	SQL_PLACEHOLDER_TIME_FILTER = "$__timeFilter"
+	SQL_PLACEHOLDER_FROM        = "$__from"
+	SQL_PLACEHOLDER_TO          = "$__to"
+)
+
+var comparators = map[string]func(value, threshold float64) bool{
+	">":  func(value, threshold float64) bool { return value > threshold },
+	">=": func(value, threshold float64) bool { return value >= threshold },
+	"<":  func(value, threshold float64) bool { return value < threshold },
+	"<=": func(value, threshold float64) bool { return value <= threshold },
+	"==": func(value, threshold float64) bool { return value == threshold },
+	"!=": func(value, threshold float64) bool { return value != threshold },
+}
+
+var eventLevelNames = map[int]string{
+	eventapi.ALARM_EVENT_LEVEL_CRITICAL: "critical",
+	eventapi.ALARM_EVENT_LEVEL_ERROR:    "error",
+	eventapi.ALARM_EVENT_LEVEL_WARNING:  "warning",
+	eventapi.ALARM_EVENT_LEVEL_NO_DATA:  "no_data",
+	eventapi.ALARM_EVENT_LEVEL_NORMAL:   "normal",
+}
+
+// ValidateRule 校验告警规则并补全默认值
+func ValidateRule(rule *model.AlertRuleCreate) error {
+	if rule.Enabled == nil {
+		enabled := 1
+		rule.Enabled = &enabled
+	} else if *rule.Enabled != 0 && *rule.Enabled != 1 {
+		return fmt.Errorf("invalid enabled (%d), support: 0, 1", *rule.Enabled)
+	}
+	if rule.Level == nil {
+		level := RULE_LEVEL_MEDIUM
+		rule.Level = &level
+	} else if *rule.Level < RULE_LEVEL_LOW || *rule.Level > RULE_LEVEL_HIGH {
+		return fmt.Errorf("invalid level (%d), support: 0 (low), 1 (medium), 2 (high)", *rule.Level)
+	}
+	if rule.DB == "" {
+		return errors.New("db is required")
+	}
+	sql := strings.TrimSpace(rule.SQL)
+	if !strings.HasPrefix(strings.ToUpper(sql), "SELECT ") {
+		return errors.New("sql should be a select statement")
+	}
+	if !strings.Contains(sql, SQL_PLACEHOLDER_TIME_FILTER) && !strings.Contains(sql, SQL_PLACEHOLDER_FROM) {
+		return fmt.Errorf("sql should filter time with %s, or %s and %s", SQL_PLACEHOLDER_TIME_FILTER, SQL_PLACEHOLDER_FROM, SQL_PLACEHOLDER_TO)
+	}
+	rule.SQL = sql
+	if rule.ValueColumn == "" {
+		rule.ValueColumn = DEFAULT_VALUE_COLUMN
+	}
+	if rule.Comparator == "" {
+		rule.Comparator = DEFAULT_COMPARATOR
+	}
+	if _, ok := comparators[rule.Comparator]; !ok {
+		return fmt.Errorf("invalid comparator (%s), support: >, >=, <, <=, ==, !=", rule.Comparator)
+	}
+	if rule.ThresholdCritical == nil && rule.ThresholdError == nil && rule.ThresholdWarning == nil {
+		return errors.New("at least one of threshold_critical, threshold_error and threshold_warning is required")
+	}
+	if rule.EvalInterval == 0 {
+		rule.EvalInterval = DEFAULT_EVAL_INTERVAL
+	}
+	if rule.EvalInterval < MIN_EVAL_INTERVAL {
+		return fmt.Errorf("eval interval (%d) should be at least %ds", rule.EvalInterval, MIN_EVAL_INTERVAL)
+	}
+	if rule.EvalRange == 0 {
+		rule.EvalRange = DEFAULT_EVAL_RANGE
+	}
+	if rule.EvalRange < 0 || rule.EvalRange > MAX_EVAL_RANGE {
+		return fmt.Errorf("eval range (%d) should be in (0, %d]", rule.EvalRange, MAX_EVAL_RANGE)
+	}
+	if rule.ForDuration < 0 {
+		return fmt.Errorf("invalid for duration (%d)", rule.ForDuration)
+	}
+	return nil
+}
+
+// renderSQL 替换 SQL 中的时间占位符
+func renderSQL(sql string, from, to int64) string {
+	return strings.NewReplacer(
+		SQL_PLACEHOLDER_TIME_FILTER, fmt.Sprintf("time>=%d AND time<=%d", from, to),
+		SQL_PLACEHOLDER_FROM, strconv.FormatInt(from, 10),
+		SQL_PLACEHOLDER_TO, strconv.FormatInt(to, 10),
+	).Replace(sql)
+}
+
+func thresholdOf(rule *mysql.AlertRule, eventLevel int) *float64 {
+	switch eventLevel {
+	case eventapi.ALARM_EVENT_LEVEL_CRITICAL:
+		return rule.ThresholdCritical
+	case eventapi.ALARM_EVENT_LEVEL_ERROR:
+		return rule.ThresholdError
+	case eventapi.ALARM_EVENT_LEVEL_WARNING:
+		return rule.ThresholdWarning
+	}
+	return nil
+}
+
+func formatThreshold(threshold *float64) string {
+	if threshold == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*threshold, 'f', -1, 64)
+}
+
+// matchLevel 按 critical、error、warning 的顺序匹配阈值，均未匹配时返回 0
+func matchLevel(rule *mysql.AlertRule, value float64) int {
+	compare, ok := comparators[rule.Comparator]
+	if !ok {
+		return 0
+	}
+	for _, eventLevel := range []int{eventapi.ALARM_EVENT_LEVEL_CRITICAL, eventapi.ALARM_EVENT_LEVEL_ERROR, eventapi.ALARM_EVENT_LEVEL_WARNING} {
+		if threshold := thresholdOf(rule, eventLevel); threshold != nil && compare(value, *threshold) {
+			return eventLevel
+		}
+	}
+	return 0
+}
+
+// triggerCondition 返回告警等级对应的触发条件，如 value > 100
+func triggerCondition(rule *mysql.AlertRule, eventLevel int) string {
+	return fmt.Sprintf("%s %s %s", rule.ValueColumn, rule.Comparator, formatThreshold(thresholdOf(rule, eventLevel)))
+}
+
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+type queryResponse struct {
+	OptStatus   string `json:"OPT_STATUS"`
+	Description string `json:"DESCRIPTION"`
+	Result      struct {
+		Columns []string        `json:"columns"`
+		Values  [][]interface{} `json:"values"`
+	} `json:"result"`
+}
+
+// parseQueryResult 解析 querier 的查询结果，除 valueColumn 外的列均作为分组标签
+func parseQueryResult(body []byte, valueColumn string) ([]sample, error) {
+	var resp queryResponse
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.OptStatus != "" && resp.OptStatus != "SUCCESS" {
+		return nil, errors.New(resp.Description)
+	}
+	if len(resp.Result.Values) == 0 {
+		return nil, nil
+	}
+	valueIndex := -1
+	for i, column := range resp.Result.Columns {
+		if column == valueColumn {
+			valueIndex = i
+			break
+		}
+	}
+	if valueIndex < 0 {
+		return nil, fmt.Errorf("value column (%s) not found in %v", valueColumn, resp.Result.Columns)
+	}
+
+	samples := make([]sample, 0, len(resp.Result.Values))
+	for _, row := range resp.Result.Values {
+		if len(row) != len(resp.Result.Columns) {
+			continue
+		}
+		value, ok := toFloat64(row[valueIndex])
+		if !ok {
+			continue
+		}
+		labels := make(map[string]string, len(row)-1)
+		for i, column := range resp.Result.Columns {
+			if i != valueIndex {
+				labels[column] = toString(row[i])
+			}
+		}
+		samples = append(samples, sample{labels: labels, value: value})
+	}
+	return samples, nil
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// seriesKey 返回按标签名排序的 k=v 列表，作为告警对象的名称
+func seriesKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]string, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, key+"="+labels[key])
+	}
+	return strings.Join(items, ", ")
+}
+
+func seriesUID(ruleLcuuid, key string) string {
+	h := fnv.New64a()
+	h.Write([]byte(ruleLcuuid))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+)
+
+const (
+	DEFAULT_COMPUTE_ENDPOINT   = "https://compute.googleapis.com/compute/v1"
+	DEFAULT_CONTAINER_ENDPOINT = "https://container.googleapis.com/v1"
+	DEFAULT_TOKEN_URI          = "https://oauth2.googleapis.com/token"
+	DEFAULT_SCOPE              = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+type Config struct {
+	RegionLcuuid      string
+	ProjectIDs        []string
+	ClientEmail       string
+	PrivateKeyID      string
+	PrivateKey        string
+	TokenURI          string
+	ComputeEndpoint   string
+	ContainerEndpoint string
+	ExcludeRegions    []string
+	IncludeRegions    []string
+}
+
+func (c *Config) LoadFromString(sConf string) (err error) {
+	jConf, err := simplejson.NewJson([]byte(sConf))
+	if err != nil {
+		log.Errorf("convert config string: %s to json failed: %v", sConf, err)
+		return
+	}
+	c.RegionLcuuid = jConf.Get("region_uuid").MustString()
+
+	// 服务账号密钥为 GCP 控制台导出的 JSON 文件内容，与其他云平台的 secret_key 一样加密存储
+	key, err := jConf.Get("service_account_key").String()
+	if err != nil {
+		log.Error("service_account_key must be specified")
+		return
+	}
+	dKey, err := common.DecryptSecretKey(key)
+	if err != nil {
+		log.Error("decrypt service_account_key failed")
+		return
+	}
+	jKey, err := simplejson.NewJson([]byte(dKey))
+	if err != nil {
+		log.Errorf("service_account_key is not a valid json: %v", err)
+		return
+	}
+	c.ClientEmail, err = jKey.Get("client_email").String()
+	if err != nil {
+		log.Error("client_email must be specified in service_account_key")
+		return
+	}
+	c.PrivateKey, err = jKey.Get("private_key").String()
+	if err != nil {
+		log.Error("private_key must be specified in service_account_key")
+		return
+	}
+	c.PrivateKeyID = jKey.Get("private_key_id").MustString()
+	c.TokenURI = jKey.Get("token_uri").MustString()
+	if c.TokenURI == "" {
+		c.TokenURI = DEFAULT_TOKEN_URI
+	}
+
+	// 未指定项目时，使用服务账号所属的项目
+	projectIDs := jConf.Get("project_ids").MustString()
+	if projectIDs == "" {
+		projectIDs = jKey.Get("project_id").MustString()
+	}
+	for _, id := range strings.Split(projectIDs, ",") {
+		id = strings.TrimSpace(id)
+		if id != "" && !common.Contains(c.ProjectIDs, id) {
+			c.ProjectIDs = append(c.ProjectIDs, id)
+		}
+	}
+	if len(c.ProjectIDs) == 0 {
+		err = errors.New("project_ids must be specified")
+		log.Error(err)
+		return
+	}
+
+	c.ComputeEndpoint = strings.TrimSuffix(jConf.Get("compute_endpoint").MustString(), "/")
+	if c.ComputeEndpoint == "" {
+		c.ComputeEndpoint = DEFAULT_COMPUTE_ENDPOINT
+	}
+	c.ContainerEndpoint = strings.TrimSuffix(jConf.Get("container_endpoint").MustString(), "/")
+	if c.ContainerEndpoint == "" {
+		c.ContainerEndpoint = DEFAULT_CONTAINER_ENDPOINT
+	}
+
+	eRegions := jConf.Get("exclude_regions").MustString()
+	if eRegions != "" {
+		c.ExcludeRegions = strings.Split(eRegions, ",")
+	}
+	iRegions := jConf.Get("include_regions").MustString()
+	if iRegions != "" {
+		c.IncludeRegions = strings.Split(iRegions, ",")
+	}
+	return
+}
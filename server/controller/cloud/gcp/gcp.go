@@ -0,0 +1,285 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/op/go-logging"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+)
+
+var log = logging.MustGetLogger("cloud.gcp")
+
+const PAGE_MAX_RESULTS = 500
+
+type GCP struct {
+	lcuuid         string
+	lcuuidGenerate string
+	name           string
+	httpTimeout    int
+	config         *Config
+	token          *Token
+	toolDataSet    *ToolDataSet       // 处理资源数据时，构建的需要提供给其他资源使用的工具数据
+	cloudStatsd    statsd.CloudStatsd // 性能监控
+	debugger       *cloudcommon.Debugger
+}
+
+func NewGCP(domain mysql.Domain, globalCloudCfg config.CloudConfig) (*GCP, error) {
+	conf := &Config{}
+	err := conf.LoadFromString(domain.Config)
+	if err != nil {
+		return nil, err
+	}
+	return newGCP(domain, globalCloudCfg, conf), nil
+}
+
+func newGCP(domain mysql.Domain, globalCloudCfg config.CloudConfig, conf *Config) *GCP {
+	return &GCP{
+		lcuuid: domain.Lcuuid,
+		// TODO: display_name后期需要修改为uuid_generate
+		lcuuidGenerate: domain.DisplayName,
+		name:           domain.Name,
+		httpTimeout:    globalCloudCfg.HTTPTimeout,
+		config:         conf,
+		debugger:       cloudcommon.NewDebugger(domain.Name),
+	}
+}
+
+func (g *GCP) ClearDebugLog() {
+	g.debugger.Clear()
+}
+
+func (g *GCP) CheckAuth() error {
+	_, err := g.getToken()
+	return err
+}
+
+func (g *GCP) GetCloudData() (model.Resource, error) {
+	g.cloudStatsd = statsd.NewCloudStatsd()
+	g.toolDataSet = NewToolDataSet()
+	var resource model.Resource
+
+	regions, azs, err := g.getRegionsAndAZs()
+	if err != nil {
+		return resource, err
+	}
+
+	networks, subnets, err := g.getNetworks()
+	if err != nil {
+		return resource, err
+	}
+	resource.Networks = append(resource.Networks, networks...)
+	resource.Subnets = append(resource.Subnets, subnets...)
+
+	vpcs, err := g.getVPCs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VPCs = append(resource.VPCs, vpcs...)
+
+	vms, vifs, ips, err := g.getVMs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VMs = append(resource.VMs, vms...)
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+	resource.IPs = append(resource.IPs, ips...)
+
+	ngws, vifs, ips, err := g.getNATGateways()
+	if err != nil {
+		return resource, err
+	}
+	resource.NATGateways = append(resource.NATGateways, ngws...)
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+	resource.IPs = append(resource.IPs, ips...)
+
+	lbs, listeners, targetServers, vifs, ips, err := g.getLBs()
+	if err != nil {
+		return resource, err
+	}
+	resource.LBs = append(resource.LBs, lbs...)
+	resource.LBListeners = append(resource.LBListeners, listeners...)
+	resource.LBTargetServers = append(resource.LBTargetServers, targetServers...)
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+	resource.IPs = append(resource.IPs, ips...)
+
+	subDomains, err := g.getSubDomains()
+	if err != nil {
+		return resource, err
+	}
+	resource.SubDomains = append(resource.SubDomains, subDomains...)
+
+	log.Debugf("region resource num info: %v", g.toolDataSet.regionLcuuidToResourceNum)
+	log.Debugf("az resource num info: %v", g.toolDataSet.azLcuuidToResourceNum)
+	resource.Regions = cloudcommon.EliminateEmptyRegions(regions, g.toolDataSet.regionLcuuidToResourceNum)
+	resource.AZs = cloudcommon.EliminateEmptyAZs(azs, g.toolDataSet.azLcuuidToResourceNum)
+
+	g.cloudStatsd.ResCount = statsd.GetResCount(resource)
+	statsd.MetaStatsd.RegisterStatsdTable(g)
+
+	g.debugger.Refresh()
+	return resource, nil
+}
+
+func (g *GCP) GetStatter() statsd.StatsdStatter {
+	globalTags := map[string]string{
+		"domain_name": g.name,
+		"domain":      g.lcuuid,
+		"platform":    common.GCP_EN,
+	}
+
+	return statsd.StatsdStatter{
+		GlobalTags: globalTags,
+		Element:    statsd.GetCloudStatsd(g.cloudStatsd),
+	}
+}
+
+// 获取资源列表，自动处理分页
+// 聚合查询（aggregated）的返回结果以 scope 分组，如 {"items": {"zones/us-central1-a": {"instances": [...]}}}，
+// 这里会将其展开为列表，并将 scope 记录在每个资源的 _scope 字段中
+func (g *GCP) getRawData(ctx rawDataGetContext) ([]*simplejson.Json, error) {
+	statsdAPIStartTime := time.Now()
+	var jsonList []*simplejson.Json
+	var pageToken string
+	for {
+		token, err := g.getToken()
+		if err != nil {
+			return nil, err
+		}
+		reqURL := ctx.url
+		if ctx.paged {
+			query := url.Values{}
+			query.Set("maxResults", fmt.Sprint(PAGE_MAX_RESULTS))
+			if pageToken != "" {
+				query.Set("pageToken", pageToken)
+			}
+			reqURL = fmt.Sprintf("%s?%s", ctx.url, query.Encode())
+		}
+		var resp *simplejson.Json
+		if ctx.post {
+			resp, err = RequestPost(reqURL, token, time.Duration(g.httpTimeout))
+		} else {
+			resp, err = RequestGet(reqURL, token, time.Duration(g.httpTimeout))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if ctx.aggregated {
+			scopes := resp.Get("items").MustMap()
+			scopeNames := make([]string, 0, len(scopes))
+			for scope := range scopes {
+				scopeNames = append(scopeNames, scope)
+			}
+			sort.Strings(scopeNames)
+			for _, scope := range scopeNames {
+				jData := resp.Get("items").Get(scope).Get(ctx.resultKey)
+				for i := range jData.MustArray() {
+					item := jData.GetIndex(i)
+					item.Set("_scope", scope)
+					jsonList = append(jsonList, item)
+				}
+			}
+		} else if jData := resp.Get(ctx.resultKey); jData.Interface() != nil {
+			// 部分接口（如 getRouterStatus）返回的是单个对象
+			if _, err := jData.Map(); err == nil {
+				jsonList = append(jsonList, jData)
+			}
+			for i := range jData.MustArray() {
+				jsonList = append(jsonList, jData.GetIndex(i))
+			}
+		}
+
+		pageToken = resp.Get("nextPageToken").MustString()
+		if !ctx.paged || pageToken == "" {
+			break
+		}
+	}
+	g.cloudStatsd.RefreshAPIMoniter(ctx.resultKey, len(jsonList), statsdAPIStartTime)
+
+	g.debugger.WriteJson(ctx.resultKey, ctx.url, jsonList)
+	return jsonList, nil
+}
+
+type rawDataGetContext struct {
+	url        string
+	resultKey  string
+	paged      bool
+	aggregated bool
+	post       bool
+}
+
+func newRawDataGetContext(url, resultKey string) rawDataGetContext {
+	return rawDataGetContext{
+		url:       url,
+		resultKey: resultKey,
+		paged:     true,
+	}
+}
+
+func (c rawDataGetContext) setAggregated() rawDataGetContext {
+	c.aggregated = true
+	return c
+}
+
+func (c rawDataGetContext) setPost() rawDataGetContext {
+	c.post = true
+	return c
+}
+
+func (c rawDataGetContext) setNotPaged() rawDataGetContext {
+	c.paged = false
+	return c
+}
+
+func (g *GCP) computeURL(project, path string) string {
+	return fmt.Sprintf("%s/projects/%s/%s", g.config.ComputeEndpoint, project, path)
+}
+
+// 由资源的 selfLink 拼接其子接口的 URL，如 instanceGroups/xxx/listInstances
+func (g *GCP) linkURL(link, path string) string {
+	return fmt.Sprintf("%s/%s/%s", g.config.ComputeEndpoint, resourceKey(link), path)
+}
+
+// GCP API 中资源之间通过 selfLink 互相引用，如
+// https://www.googleapis.com/compute/v1/projects/p/regions/us-central1/subnetworks/default，
+// 统一截取 projects/ 之后的部分作为资源的 key，兼容不同的 endpoint
+func resourceKey(link string) string {
+	index := strings.Index(link, "projects/")
+	if index < 0 {
+		return link
+	}
+	return link[index:]
+}
+
+// 取 selfLink 的最后一段作为资源名称，如 zones/us-central1-a -> us-central1-a
+func lastPart(link string) string {
+	parts := strings.Split(link, "/")
+	return parts[len(parts)-1]
+}
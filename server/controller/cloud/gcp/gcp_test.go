@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+	statsdconfig "github.com/deepflowio/deepflow/server/controller/statsd/config"
+)
+
+const TEST_ACCESS_TOKEN = "test-access-token"
+
+// 使用 testdata 下录制的 Compute/Container API 响应模拟 GCP，请求路径与文件路径一一对应
+func newTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.FormValue("grant_type") != JWT_BEARER_GRANT_TYPE || len(strings.Split(r.FormValue("assertion"), ".")) != 3 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token": "` + TEST_ACCESS_TOKEN + `", "expires_in": 3600, "token_type": "Bearer"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+TEST_ACCESS_TOKEN {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", r.URL.Path+".json"))
+		if err != nil {
+			t.Logf("no fixture for %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func newTestGCP(serverURL, regionLcuuid string) *GCP {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keyBytes, _ := x509.MarshalPKCS8PrivateKey(key)
+	conf := &Config{
+		ProjectIDs:        []string{"demo"},
+		ClientEmail:       "deepflow@demo.iam.gserviceaccount.com",
+		PrivateKey:        string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})),
+		TokenURI:          serverURL + "/token",
+		ComputeEndpoint:   serverURL + "/compute/v1",
+		ContainerEndpoint: serverURL + "/container/v1",
+		RegionLcuuid:      regionLcuuid,
+	}
+	domain := mysql.Domain{Base: mysql.Base{Lcuuid: "test_gcp"}, Name: "test_gcp", DisplayName: "test_gcp"}
+	return newGCP(domain, config.CloudConfig{HTTPTimeout: 30}, conf)
+}
+
+func TestGCP(t *testing.T) {
+	config.SetCloudGlobalConfig(config.CloudConfig{HTTPTimeout: 30})
+	statsd.NewStatsdMonitor(statsdconfig.StatsdConfig{})
+	server := newTestServer(t)
+	defer server.Close()
+
+	Convey("TestGCP", t, func() {
+		gcp := newTestGCP(server.URL, "")
+		So(gcp.CheckAuth(), ShouldBeNil)
+
+		data, err := gcp.GetCloudData()
+		So(err, ShouldBeNil)
+
+		Convey("regions and azs without resources should be eliminated", func() {
+			So(len(data.Regions), ShouldEqual, 1)
+			So(data.Regions[0].Name, ShouldEqual, "us-central1")
+			So(len(data.AZs), ShouldEqual, 1)
+			So(data.AZs[0].Name, ShouldEqual, "us-central1-a")
+		})
+
+		Convey("vpcs and subnets should be synced with secondary ranges", func() {
+			So(len(data.VPCs), ShouldEqual, 1)
+			So(data.VPCs[0].Name, ShouldEqual, "vpc-prod")
+			So(data.VPCs[0].RegionLcuuid, ShouldEqual, data.Regions[0].Lcuuid)
+			So(len(data.Networks), ShouldEqual, 1)
+			So(data.Networks[0].VPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+			So(len(data.Subnets), ShouldEqual, 3)
+		})
+
+		Convey("vms should carry nics, alias ips and external ips", func() {
+			So(len(data.VMs), ShouldEqual, 2)
+			vmNameToVM := make(map[string]model.VM)
+			for _, vm := range data.VMs {
+				vmNameToVM[vm.Name] = vm
+			}
+			So(vmNameToVM["web-1"].State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(vmNameToVM["web-1"].IP, ShouldEqual, "10.128.0.2")
+			So(vmNameToVM["web-1"].CloudTags["team"], ShouldEqual, "web")
+			So(vmNameToVM["web-2"].State, ShouldEqual, common.VM_STATE_STOPPED)
+
+			var lanMacs []string
+			for _, vif := range data.VInterfaces {
+				if vif.DeviceLcuuid == vmNameToVM["web-1"].Lcuuid && vif.Type == common.VIF_TYPE_LAN {
+					lanMacs = append(lanMacs, vif.Mac)
+				}
+			}
+			So(lanMacs, ShouldResemble, []string{"42:01:0a:80:00:02"})
+
+			ipSet := make(map[string]bool)
+			for _, ip := range data.IPs {
+				ipSet[ip.IP] = true
+			}
+			So(ipSet["10.128.0.100"], ShouldBeTrue)
+			So(ipSet["34.1.2.3"], ShouldBeTrue)
+			So(ipSet["10.4.0.0"], ShouldBeFalse)
+		})
+
+		Convey("cloud nat should be synced with manual and auto allocated ips", func() {
+			So(len(data.NATGateways), ShouldEqual, 2)
+			natNameToIPs := make(map[string]string)
+			for _, ngw := range data.NATGateways {
+				natNameToIPs[ngw.Name] = ngw.FloatingIPs
+			}
+			So(natNameToIPs["nat-manual"], ShouldEqual, "35.1.1.1")
+			So(natNameToIPs["nat-auto"], ShouldEqual, "35.2.2.2,35.2.2.3")
+		})
+
+		Convey("forwarding rules should be grouped by backend", func() {
+			So(len(data.LBs), ShouldEqual, 2)
+			lbNameToLB := make(map[string]model.LB)
+			for _, lb := range data.LBs {
+				lbNameToLB[lb.Name] = lb
+			}
+			So(lbNameToLB["pool-web"].Model, ShouldEqual, common.LB_MODEL_EXTERNAL)
+			So(lbNameToLB["pool-web"].VPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+			So(lbNameToLB["ilb-web"].Model, ShouldEqual, common.LB_MODEL_INTERNAL)
+			So(len(data.LBListeners), ShouldEqual, 3)
+			So(len(data.LBTargetServers), ShouldEqual, 4)
+		})
+
+		Convey("gke clusters should be synced as sub domains", func() {
+			So(len(data.SubDomains), ShouldEqual, 1)
+			So(data.SubDomains[0].Name, ShouldEqual, "gke-prod")
+			So(data.SubDomains[0].VpcUUID, ShouldEqual, data.VPCs[0].Lcuuid)
+		})
+
+		Convey("resource count should be equal", func() {
+			So(len(data.VInterfaces), ShouldEqual, 7)
+			So(len(data.IPs), ShouldEqual, 9)
+		})
+	})
+}
+
+func TestGCPWithRegionLcuuid(t *testing.T) {
+	config.SetCloudGlobalConfig(config.CloudConfig{HTTPTimeout: 30})
+	statsd.NewStatsdMonitor(statsdconfig.StatsdConfig{})
+	server := newTestServer(t)
+	defer server.Close()
+
+	Convey("TestGCPWithRegionLcuuid", t, func() {
+		regionLcuuid := "ffffffff-ffff-ffff-ffff-ffffffffffff"
+		gcp := newTestGCP(server.URL, regionLcuuid)
+		data, err := gcp.GetCloudData()
+		So(err, ShouldBeNil)
+
+		Convey("resources should belong to the configured region", func() {
+			So(len(data.Regions), ShouldEqual, 0)
+			So(len(data.AZs), ShouldEqual, 1)
+			So(data.AZs[0].RegionLcuuid, ShouldEqual, regionLcuuid)
+			So(len(data.VPCs), ShouldEqual, 1)
+			So(data.VPCs[0].RegionLcuuid, ShouldEqual, regionLcuuid)
+			for _, vm := range data.VMs {
+				So(vm.RegionLcuuid, ShouldEqual, regionLcuuid)
+			}
+			So(data.SubDomains[0].Config, ShouldContainSubstring, regionLcuuid)
+		})
+	})
+}
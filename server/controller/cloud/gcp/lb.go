@@ -0,0 +1,256 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+)
+
+// GCP 中负载均衡器没有独立的资源，由 forwarding rule（前端）和 target pool / backend service（后端）组合而成，
+// 这里以后端为单位生成负载均衡器，指向同一后端的每个 forwarding rule 的每个端口生成一个监听器
+func (g *GCP) getLBs() (
+	lbs []model.LB, listeners []model.LBListener, targetServers []model.LBTargetServer, vifs []model.VInterface, ips []model.IP, err error,
+) {
+	requiredAttrs := []string{"id", "name", "IPAddress", "IPProtocol"}
+	for _, project := range g.config.ProjectIDs {
+		backendKeyToVMLcuuids, err := g.getLBBackends(project)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+		jRules, err := g.getRawData(newRawDataGetContext(g.computeURL(project, "aggregated/forwardingRules"), "forwardingRules").setAggregated())
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+
+		lbLcuuidToLB := make(map[string]*model.LB)
+		var lbLcuuids []string
+		vifLcuuids := make(map[string]bool)
+		for i := range jRules {
+			jRule := jRules[i]
+			ruleName := jRule.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jRule, requiredAttrs) {
+				log.Infof("exclude forwarding rule: %s, missing attr", ruleName)
+				continue
+			}
+			regionName := g.scopeToRegionName(jRule.Get("_scope").MustString())
+			if regionName == "" {
+				log.Debugf("exclude forwarding rule: %s, region not included", ruleName)
+				continue
+			}
+			backend := jRule.Get("backendService").MustString()
+			if backend == "" {
+				backend = jRule.Get("target").MustString()
+			}
+			if backend == "" {
+				log.Infof("exclude forwarding rule: %s, missing backend", ruleName)
+				continue
+			}
+			backendKey := resourceKey(backend)
+			vmLcuuids := backendKeyToVMLcuuids[backendKey]
+
+			// 外部负载均衡的 forwarding rule 没有 network 字段，使用后端虚拟机所在的 VPC
+			var vpcLcuuid string
+			if network := jRule.Get("network").MustString(); network != "" {
+				vpcLcuuid = g.vpcLcuuid(network)
+			} else if len(vmLcuuids) > 0 {
+				vpcLcuuid = g.toolDataSet.vmLcuuidToVPCLcuuid[vmLcuuids[0]]
+			}
+			if _, ok := g.toolDataSet.vpcLcuuidToRegionLcuuid[vpcLcuuid]; !ok {
+				log.Infof("exclude forwarding rule: %s, missing vpc info", ruleName)
+				continue
+			}
+			regionLcuuid := g.getRegionLcuuid(regionName)
+
+			lbModel := common.LB_MODEL_EXTERNAL
+			if strings.HasPrefix(jRule.Get("loadBalancingScheme").MustString(), "INTERNAL") {
+				lbModel = common.LB_MODEL_INTERNAL
+			}
+			vip := jRule.Get("IPAddress").MustString()
+			lbLcuuid := common.GenerateUUID(backendKey)
+			if _, ok := lbLcuuidToLB[lbLcuuid]; !ok {
+				lbLcuuidToLB[lbLcuuid] = &model.LB{
+					Lcuuid:       lbLcuuid,
+					Name:         lastPart(backend),
+					Label:        backendKey,
+					Model:        lbModel,
+					VIP:          vip,
+					VPCLcuuid:    vpcLcuuid,
+					RegionLcuuid: regionLcuuid,
+				}
+				lbLcuuids = append(lbLcuuids, lbLcuuid)
+				g.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+			}
+
+			// 同一 VIP 可以配置多个 forwarding rule（如 TCP 和 UDP）
+			vif, vifIP := g.formatLBVInterface(jRule, lbLcuuid, vpcLcuuid, regionLcuuid, lbModel)
+			if !vifLcuuids[vif.Lcuuid] {
+				vifLcuuids[vif.Lcuuid] = true
+				vifs = append(vifs, vif)
+				ips = append(ips, vifIP)
+			}
+
+			protocol := jRule.Get("IPProtocol").MustString()
+			for _, port := range formatForwardingRulePorts(jRule) {
+				listenerName := fmt.Sprintf("%s-%s-%d", ruleName, protocol, port)
+				listenerLcuuid := common.GenerateUUID(jRule.Get("id").MustString() + listenerName)
+				listeners = append(listeners, model.LBListener{
+					Lcuuid:   listenerLcuuid,
+					LBLcuuid: lbLcuuid,
+					Name:     listenerName,
+					Label:    ruleName,
+					IPs:      vip,
+					Protocol: protocol,
+					Port:     port,
+				})
+				for _, vmLcuuid := range vmLcuuids {
+					targetServers = append(targetServers, model.LBTargetServer{
+						Lcuuid:           common.GenerateUUID(listenerLcuuid + vmLcuuid),
+						LBLcuuid:         lbLcuuid,
+						LBListenerLcuuid: listenerLcuuid,
+						Type:             common.LB_SERVER_TYPE_VM,
+						IP:               g.toolDataSet.vmLcuuidToIP[vmLcuuid],
+						VMLcuuid:         vmLcuuid,
+						Protocol:         protocol,
+						Port:             port,
+						VPCLcuuid:        vpcLcuuid,
+					})
+				}
+			}
+		}
+		for _, lbLcuuid := range lbLcuuids {
+			lbs = append(lbs, *lbLcuuidToLB[lbLcuuid])
+		}
+	}
+	return
+}
+
+// 内部负载均衡的 VIP 属于 forwarding rule 所在的子网，外部负载均衡的 VIP 为公网 IP
+func (g *GCP) formatLBVInterface(jRule *simplejson.Json, lbLcuuid, vpcLcuuid, regionLcuuid string, lbModel int) (model.VInterface, model.IP) {
+	vip := jRule.Get("IPAddress").MustString()
+	vif := model.VInterface{
+		Lcuuid:        common.GenerateUUID(lbLcuuid + vip),
+		Type:          common.VIF_TYPE_WAN,
+		Mac:           common.VIF_DEFAULT_MAC,
+		DeviceLcuuid:  lbLcuuid,
+		DeviceType:    common.VIF_DEVICE_TYPE_LB,
+		NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+		VPCLcuuid:     vpcLcuuid,
+		RegionLcuuid:  regionLcuuid,
+	}
+	ip := model.IP{
+		Lcuuid:           common.GenerateUUID(vif.Lcuuid + vip),
+		VInterfaceLcuuid: vif.Lcuuid,
+		IP:               vip,
+		RegionLcuuid:     regionLcuuid,
+	}
+	if lbModel == common.LB_MODEL_INTERNAL {
+		if network, ok := g.toolDataSet.subnetworkKeyToNetwork[resourceKey(jRule.Get("subnetwork").MustString())]; ok {
+			vif.Type = common.VIF_TYPE_LAN
+			vif.NetworkLcuuid = network.Lcuuid
+			ip.SubnetLcuuid = g.getSubnetLcuuid(network.Lcuuid, vip)
+		}
+	}
+	return vif, ip
+}
+
+// 端口可能以 portRange（如 80-80、8000-8080）或 ports 列表的形式给出，端口范围仅记录起始端口
+func formatForwardingRulePorts(jRule *simplejson.Json) []int {
+	var ports []int
+	for _, p := range jRule.Get("ports").MustStringArray() {
+		if port, err := strconv.Atoi(p); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	if len(ports) > 0 {
+		return ports
+	}
+	portRange := jRule.Get("portRange").MustString()
+	if port, err := strconv.Atoi(strings.Split(portRange, "-")[0]); err == nil {
+		return []int{port}
+	}
+	// allPorts 或未指定端口
+	return []int{0}
+}
+
+// 获取 target pool 和 backend service 对应的后端虚拟机
+func (g *GCP) getLBBackends(project string) (map[string][]string, error) {
+	backendKeyToVMLcuuids := make(map[string][]string)
+	jPools, err := g.getRawData(newRawDataGetContext(g.computeURL(project, "aggregated/targetPools"), "targetPools").setAggregated())
+	if err != nil {
+		return nil, err
+	}
+	for i := range jPools {
+		jPool := jPools[i]
+		key := resourceKey(jPool.Get("selfLink").MustString())
+		for _, instance := range jPool.Get("instances").MustStringArray() {
+			if vmLcuuid, ok := g.toolDataSet.instanceKeyToVMLcuuid[resourceKey(instance)]; ok {
+				backendKeyToVMLcuuids[key] = append(backendKeyToVMLcuuids[key], vmLcuuid)
+			}
+		}
+	}
+
+	jServices, err := g.getRawData(newRawDataGetContext(g.computeURL(project, "aggregated/backendServices"), "backendServices").setAggregated())
+	if err != nil {
+		return nil, err
+	}
+	groupKeyToVMLcuuids := make(map[string][]string)
+	for i := range jServices {
+		jService := jServices[i]
+		key := resourceKey(jService.Get("selfLink").MustString())
+		jBackends := jService.Get("backends")
+		for j := range jBackends.MustArray() {
+			group := jBackends.GetIndex(j).Get("group").MustString()
+			// 仅支持实例组类型的后端，NEG 类型的后端一般为 GKE 的 Pod，由容器子域负责同步
+			if !strings.Contains(group, "/instanceGroups/") {
+				continue
+			}
+			groupKey := resourceKey(group)
+			vmLcuuids, ok := groupKeyToVMLcuuids[groupKey]
+			if !ok {
+				vmLcuuids, err = g.getInstanceGroupVMLcuuids(group)
+				if err != nil {
+					return nil, err
+				}
+				groupKeyToVMLcuuids[groupKey] = vmLcuuids
+			}
+			backendKeyToVMLcuuids[key] = append(backendKeyToVMLcuuids[key], vmLcuuids...)
+		}
+	}
+	return backendKeyToVMLcuuids, nil
+}
+
+func (g *GCP) getInstanceGroupVMLcuuids(group string) ([]string, error) {
+	jInstances, err := g.getRawData(newRawDataGetContext(g.linkURL(group, "listInstances"), "items").setPost())
+	if err != nil {
+		return nil, err
+	}
+	var vmLcuuids []string
+	for i := range jInstances {
+		if vmLcuuid, ok := g.toolDataSet.instanceKeyToVMLcuuid[resourceKey(jInstances[i].Get("instance").MustString())]; ok {
+			vmLcuuids = append(vmLcuuids, vmLcuuid)
+		}
+	}
+	return vmLcuuids, nil
+}
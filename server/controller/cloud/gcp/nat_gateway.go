@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"fmt"
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+)
+
+// Cloud NAT 配置在 Cloud Router 上，一个 router 可包含多个 NAT，每个 NAT 对应 DeepFlow 中的一个 NAT 网关；
+// 手动分配的 NAT IP 以地址资源的 URL 引用，自动分配的 NAT IP 需要通过 router 状态接口获取
+func (g *GCP) getNATGateways() ([]model.NATGateway, []model.VInterface, []model.IP, error) {
+	var natGateways []model.NATGateway
+	var vifs []model.VInterface
+	var ips []model.IP
+
+	requiredAttrs := []string{"id", "name", "network", "region"}
+	for _, project := range g.config.ProjectIDs {
+		err := g.refreshAddresses(project)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		jRouters, err := g.getRawData(newRawDataGetContext(g.computeURL(project, "aggregated/routers"), "routers").setAggregated())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for i := range jRouters {
+			jRouter := jRouters[i]
+			routerName := jRouter.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jRouter, requiredAttrs) {
+				log.Infof("exclude router: %s, missing attr", routerName)
+				continue
+			}
+			jNATs := jRouter.Get("nats")
+			if len(jNATs.MustArray()) == 0 {
+				continue
+			}
+			regionName := lastPart(jRouter.Get("region").MustString())
+			if _, ok := g.toolDataSet.regionNameToLcuuid[regionName]; !ok {
+				log.Debugf("exclude router: %s, region not included", routerName)
+				continue
+			}
+			vpcLcuuid := g.vpcLcuuid(jRouter.Get("network").MustString())
+			if _, ok := g.toolDataSet.vpcLcuuidToRegionLcuuid[vpcLcuuid]; !ok {
+				log.Infof("exclude router: %s, missing vpc info", routerName)
+				continue
+			}
+			regionLcuuid := g.getRegionLcuuid(regionName)
+
+			var autoNATIPs map[string][]string
+			for j := range jNATs.MustArray() {
+				jNAT := jNATs.GetIndex(j)
+				natName := jNAT.Get("name").MustString()
+				var natIPs []string
+				jNATIPs := jNAT.Get("natIps")
+				for k := range jNATIPs.MustArray() {
+					if ip, ok := g.toolDataSet.addressKeyToIP[resourceKey(jNATIPs.GetIndex(k).MustString())]; ok {
+						natIPs = append(natIPs, ip)
+					}
+				}
+				if jNAT.Get("natIpAllocateOption").MustString() == "AUTO_ONLY" {
+					if autoNATIPs == nil {
+						autoNATIPs = g.getAutoAllocatedNATIPs(project, regionName, routerName)
+					}
+					natIPs = append(natIPs, autoNATIPs[natName]...)
+				}
+
+				lcuuid := common.GenerateUUID(jRouter.Get("id").MustString() + natName)
+				natGateways = append(natGateways, model.NATGateway{
+					Lcuuid:       lcuuid,
+					Name:         natName,
+					Label:        routerName + "/" + natName,
+					FloatingIPs:  strings.Join(natIPs, ","),
+					VPCLcuuid:    vpcLcuuid,
+					RegionLcuuid: regionLcuuid,
+				})
+				g.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+
+				if len(natIPs) == 0 {
+					continue
+				}
+				vifLcuuid := common.GenerateUUID(lcuuid)
+				vifs = append(vifs, model.VInterface{
+					Lcuuid:        vifLcuuid,
+					Type:          common.VIF_TYPE_WAN,
+					Mac:           common.VIF_DEFAULT_MAC,
+					DeviceLcuuid:  lcuuid,
+					DeviceType:    common.VIF_DEVICE_TYPE_NAT_GATEWAY,
+					NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+					VPCLcuuid:     vpcLcuuid,
+					RegionLcuuid:  regionLcuuid,
+				})
+				for _, ip := range natIPs {
+					ips = append(ips, model.IP{
+						Lcuuid:           common.GenerateUUID(vifLcuuid + ip),
+						VInterfaceLcuuid: vifLcuuid,
+						IP:               ip,
+						RegionLcuuid:     regionLcuuid,
+					})
+				}
+			}
+		}
+	}
+	return natGateways, vifs, ips, nil
+}
+
+func (g *GCP) refreshAddresses(project string) error {
+	jAddresses, err := g.getRawData(newRawDataGetContext(g.computeURL(project, "aggregated/addresses"), "addresses").setAggregated())
+	if err != nil {
+		return err
+	}
+	for i := range jAddresses {
+		ja := jAddresses[i]
+		if !cloudcommon.CheckJsonAttributes(ja, []string{"address", "selfLink"}) {
+			continue
+		}
+		g.toolDataSet.addressKeyToIP[resourceKey(ja.Get("selfLink").MustString())] = ja.Get("address").MustString()
+	}
+	return nil
+}
+
+// 获取失败时仅记录日志，NAT 网关依然同步，只是缺少 IP 信息
+func (g *GCP) getAutoAllocatedNATIPs(project, regionName, routerName string) map[string][]string {
+	natNameToIPs := make(map[string][]string)
+	jStatus, err := g.getRawData(newRawDataGetContext(
+		g.computeURL(project, fmt.Sprintf("regions/%s/routers/%s/getRouterStatus", regionName, routerName)), "result",
+	).setNotPaged())
+	if err != nil || len(jStatus) == 0 {
+		log.Errorf("get router (%s) status failed: %v", routerName, err)
+		return natNameToIPs
+	}
+	jNATStatus := jStatus[0].Get("natStatus")
+	for i := range jNATStatus.MustArray() {
+		js := jNATStatus.GetIndex(i)
+		natNameToIPs[js.Get("name").MustString()] = js.Get("autoAllocatedNatIps").MustStringArray()
+	}
+	return natNameToIPs
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+)
+
+// GCP 的 subnetwork 对应 DeepFlow 中的子网，其主网段和次级网段（secondaryIpRanges，GKE 的 Pod/Service 网段）
+// 都作为该子网的网段，以便 alias IP 能够匹配到子网
+func (g *GCP) getNetworks() ([]model.Network, []model.Subnet, error) {
+	var networks []model.Network
+	var subnets []model.Subnet
+
+	requiredAttrs := []string{"id", "name", "network", "ipCidrRange", "selfLink"}
+	for _, project := range g.config.ProjectIDs {
+		jNetworks, err := g.getRawData(newRawDataGetContext(g.computeURL(project, "aggregated/subnetworks"), "subnetworks").setAggregated())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range jNetworks {
+			jn := jNetworks[i]
+			name := jn.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jn, requiredAttrs) {
+				log.Infof("exclude network: %s, missing attr", name)
+				continue
+			}
+			regionName := g.scopeToRegionName(jn.Get("_scope").MustString())
+			if regionName == "" {
+				log.Debugf("exclude network: %s, region not included", name)
+				continue
+			}
+			regionLcuuid := g.getRegionLcuuid(regionName)
+			lcuuid := common.GenerateUUID(jn.Get("id").MustString())
+			vpcLcuuid := g.vpcLcuuid(jn.Get("network").MustString())
+			network := model.Network{
+				Lcuuid:         lcuuid,
+				Name:           name,
+				SegmentationID: 1,
+				Shared:         false,
+				External:       false,
+				NetType:        common.NETWORK_TYPE_LAN,
+				VPCLcuuid:      vpcLcuuid,
+				RegionLcuuid:   regionLcuuid,
+			}
+			networks = append(networks, network)
+			g.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+			g.toolDataSet.subnetworkKeyToNetwork[resourceKey(jn.Get("selfLink").MustString())] = network
+			if _, ok := g.toolDataSet.vpcLcuuidToRegionLcuuid[vpcLcuuid]; !ok {
+				g.toolDataSet.vpcLcuuidToRegionLcuuid[vpcLcuuid] = regionLcuuid
+			}
+
+			cidrs := map[string]string{name: jn.Get("ipCidrRange").MustString()}
+			jRanges := jn.Get("secondaryIpRanges")
+			for j := range jRanges.MustArray() {
+				jRange := jRanges.GetIndex(j)
+				cidr := jRange.Get("ipCidrRange").MustString()
+				if cidr == "" {
+					continue
+				}
+				cidrs[name+"_"+jRange.Get("rangeName").MustString()] = cidr
+			}
+			if cidrV6 := jn.Get("ipv6CidrRange").MustString(); cidrV6 != "" {
+				cidrs[name+"_v6"] = cidrV6
+			}
+			for subnetName, cidr := range cidrs {
+				subnet := model.Subnet{
+					Lcuuid:        common.GenerateUUID(lcuuid + cidr),
+					Name:          subnetName,
+					CIDR:          cidr,
+					GatewayIP:     jn.Get("gatewayAddress").MustString(),
+					NetworkLcuuid: lcuuid,
+					VPCLcuuid:     vpcLcuuid,
+				}
+				subnets = append(subnets, subnet)
+				g.toolDataSet.networkLcuuidToSubnets[lcuuid] = append(g.toolDataSet.networkLcuuidToSubnets[lcuuid], subnet)
+			}
+		}
+	}
+	return networks, subnets, nil
+}
+
+func (g *GCP) getSubnetLcuuid(networkLcuuid, ip string) string {
+	for _, subnet := range g.toolDataSet.networkLcuuidToSubnets[networkLcuuid] {
+		if cloudcommon.IsIPInCIDR(ip, subnet.CIDR) {
+			return subnet.Lcuuid
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+)
+
+// GCP 中的 region 和 zone 分别对应 DeepFlow 中的区域和可用区，
+// 各项目的 region 列表相同，按名称去重
+func (g *GCP) getRegionsAndAZs() ([]model.Region, []model.AZ, error) {
+	var regions []model.Region
+	var azs []model.AZ
+	for _, project := range g.config.ProjectIDs {
+		jRegions, err := g.getRawData(newRawDataGetContext(g.computeURL(project, "regions"), "items"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range jRegions {
+			jr := jRegions[i]
+			if !cloudcommon.CheckJsonAttributes(jr, []string{"name", "zones"}) {
+				continue
+			}
+			name := jr.Get("name").MustString()
+			if _, ok := g.toolDataSet.regionNameToLcuuid[name]; ok {
+				continue
+			}
+			if len(g.config.IncludeRegions) > 0 && !common.Contains(g.config.IncludeRegions, name) {
+				log.Infof("exclude region: %s, not included", name)
+				continue
+			}
+			if common.Contains(g.config.ExcludeRegions, name) {
+				log.Infof("exclude region: %s", name)
+				continue
+			}
+
+			regionLcuuid := g.getRegionLcuuid(name)
+			// 配置了 region_uuid 时资源归属到已有区域，不再同步云平台区域
+			if g.config.RegionLcuuid == "" {
+				regions = append(regions, model.Region{
+					Lcuuid: regionLcuuid,
+					Name:   name,
+				})
+			}
+			g.toolDataSet.regionNameToLcuuid[name] = regionLcuuid
+
+			jZones := jr.Get("zones")
+			for j := range jZones.MustArray() {
+				zone := lastPart(jZones.GetIndex(j).MustString())
+				if zone == "" {
+					continue
+				}
+				azLcuuid := common.GenerateUUID(zone + "_" + g.lcuuidGenerate)
+				azs = append(azs, model.AZ{
+					Lcuuid:       azLcuuid,
+					Name:         zone,
+					RegionLcuuid: regionLcuuid,
+				})
+				g.toolDataSet.zoneNameToAZLcuuid[zone] = azLcuuid
+				g.toolDataSet.zoneNameToRegionName[zone] = name
+			}
+		}
+	}
+	return regions, azs, nil
+}
+
+func (g *GCP) getRegionLcuuid(regionName string) string {
+	if g.config.RegionLcuuid != "" {
+		return g.config.RegionLcuuid
+	}
+	return common.GenerateUUID(regionName + "_" + g.lcuuidGenerate)
+}
+
+// 聚合查询返回的 scope 形如 regions/us-central1 或 zones/us-central1-a，
+// 返回对应的 region 名称，region 被过滤时返回空字符串
+func (g *GCP) scopeToRegionName(scope string) string {
+	name := lastPart(scope)
+	if _, ok := g.toolDataSet.regionNameToLcuuid[name]; ok {
+		return name
+	}
+	return g.toolDataSet.zoneNameToRegionName[name]
+}
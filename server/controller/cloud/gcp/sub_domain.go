@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+)
+
+// GKE 集群作为附属容器集群同步，集群 ID 使用 GKE 返回的集群 ID
+func (g *GCP) getSubDomains() ([]model.SubDomain, error) {
+	var subDomains []model.SubDomain
+
+	log.Debug("get sub_domains starting")
+	for _, project := range g.config.ProjectIDs {
+		jClusters, err := g.getRawData(newRawDataGetContext(
+			fmt.Sprintf("%s/projects/%s/locations/-/clusters", g.config.ContainerEndpoint, project), "clusters",
+		).setNotPaged())
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range jClusters {
+			jc := jClusters[i]
+			name := jc.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jc, []string{"id", "name", "location"}) {
+				log.Infof("exclude cluster: %s, missing attr", name)
+				continue
+			}
+			// location 为 region（区域集群）或 zone（可用区集群）
+			regionName := g.scopeToRegionName(jc.Get("location").MustString())
+			if regionName == "" {
+				log.Infof("exclude cluster: %s, region not included", name)
+				continue
+			}
+			network := jc.Get("networkConfig").Get("network").MustString()
+			if network == "" {
+				network = fmt.Sprintf("projects/%s/global/networks/%s", project, jc.Get("network").MustString())
+			}
+			vpcLcuuid := g.vpcLcuuid(network)
+			if _, ok := g.toolDataSet.vpcLcuuidToRegionLcuuid[vpcLcuuid]; !ok {
+				log.Infof("exclude cluster: %s, vpc (%s) not found", name, network)
+				continue
+			}
+
+			clusterID := jc.Get("id").MustString()
+			config := map[string]interface{}{
+				"cluster_id":                 clusterID,
+				"region_uuid":                g.getRegionLcuuid(regionName),
+				"vpc_uuid":                   vpcLcuuid,
+				"port_name_regex":            common.DEFAULT_PORT_NAME_REGEX,
+				"pod_net_ipv4_cidr_max_mask": common.K8S_POD_IPV4_NETMASK,
+				"pod_net_ipv6_cidr_max_mask": common.K8S_POD_IPV6_NETMASK,
+			}
+			configJson, _ := json.Marshal(config)
+			subDomains = append(subDomains, model.SubDomain{
+				Lcuuid:      common.GenerateUUID(clusterID),
+				Name:        name,
+				DisplayName: clusterID,
+				ClusterID:   clusterID,
+				VpcUUID:     vpcLcuuid,
+				Config:      string(configJson),
+			})
+		}
+	}
+	log.Debug("get sub_domains complete")
+	return subDomains, nil
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/url"
+	"time"
+)
+
+const JWT_BEARER_GRANT_TYPE = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+type Token struct {
+	token     string
+	expiresAt time.Time
+}
+
+// 检查token是否过期，离失效时间小于5m即认为已过期
+func (t *Token) isExpired() bool {
+	return time.Now().Add(5 * time.Minute).After(t.expiresAt)
+}
+
+func (g *GCP) getToken() (string, error) {
+	if g.token == nil || g.token.isExpired() {
+		t, err := g.createToken()
+		if err != nil {
+			return "", err
+		}
+		g.token = t
+	}
+	return g.token.token, nil
+}
+
+// 使用服务账号私钥签发 JWT，向 token_uri 换取 access token
+// 参考：https://developers.google.com/identity/protocols/oauth2/service-account#httprest
+func (g *GCP) createToken() (*Token, error) {
+	assertion, err := g.signJWT(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", JWT_BEARER_GRANT_TYPE)
+	form.Set("assertion", assertion)
+	resp, err := RequestPostForm(g.config.TokenURI, time.Duration(g.httpTimeout), form)
+	if err != nil {
+		return nil, err
+	}
+	accessToken := resp.Get("access_token").MustString()
+	if accessToken == "" {
+		return nil, errors.New("access_token not found in token response")
+	}
+	expiresIn := resp.Get("expires_in").MustInt(3600)
+	return &Token{token: accessToken, expiresAt: time.Now().Add(time.Duration(expiresIn) * time.Second)}, nil
+}
+
+func (g *GCP) signJWT(now time.Time) (string, error) {
+	privateKey, err := parsePrivateKey(g.config.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if g.config.PrivateKeyID != "" {
+		header["kid"] = g.config.PrivateKeyID
+	}
+	claims := map[string]interface{}{
+		"iss":   g.config.ClientEmail,
+		"scope": DEFAULT_SCOPE,
+		"aud":   g.config.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	hBytes, _ := json.Marshal(header)
+	cBytes, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(hBytes) + "." + base64.RawURLEncoding.EncodeToString(cBytes)
+	hashed := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func parsePrivateKey(key string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("private_key is not a valid pem")
+	}
+	// 服务账号导出的私钥为 PKCS8 格式，兼容 PKCS1
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private_key is not a rsa key")
+	}
+	return privateKey, nil
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+)
+
+type ToolDataSet struct {
+	regionNameToLcuuid      map[string]string
+	zoneNameToAZLcuuid      map[string]string
+	zoneNameToRegionName    map[string]string
+	vpcLcuuidToRegionLcuuid map[string]string
+	subnetworkKeyToNetwork  map[string]model.Network
+	networkLcuuidToSubnets  map[string][]model.Subnet
+	instanceKeyToVMLcuuid   map[string]string
+	vmLcuuidToIP            map[string]string
+	vmLcuuidToVPCLcuuid     map[string]string
+	addressKeyToIP          map[string]string
+
+	regionLcuuidToResourceNum map[string]int
+	azLcuuidToResourceNum     map[string]int
+}
+
+func NewToolDataSet() *ToolDataSet {
+	return &ToolDataSet{
+		regionNameToLcuuid:        make(map[string]string),
+		zoneNameToAZLcuuid:        make(map[string]string),
+		zoneNameToRegionName:      make(map[string]string),
+		vpcLcuuidToRegionLcuuid:   make(map[string]string),
+		subnetworkKeyToNetwork:    make(map[string]model.Network),
+		networkLcuuidToSubnets:    make(map[string][]model.Subnet),
+		instanceKeyToVMLcuuid:     make(map[string]string),
+		vmLcuuidToIP:              make(map[string]string),
+		vmLcuuidToVPCLcuuid:       make(map[string]string),
+		addressKeyToIP:            make(map[string]string),
+		regionLcuuidToResourceNum: make(map[string]int),
+		azLcuuidToResourceNum:     make(map[string]int),
+	}
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+)
+
+var STATE_CONVERTION = map[string]int{
+	"RUNNING":    common.VM_STATE_RUNNING,
+	"STOPPING":   common.VM_STATE_STOPPED,
+	"STOPPED":    common.VM_STATE_STOPPED,
+	"SUSPENDING": common.VM_STATE_STOPPED,
+	"SUSPENDED":  common.VM_STATE_STOPPED,
+	"TERMINATED": common.VM_STATE_STOPPED,
+}
+
+func (g *GCP) getVMs() ([]model.VM, []model.VInterface, []model.IP, error) {
+	var vms []model.VM
+	var vifs []model.VInterface
+	var ips []model.IP
+
+	requiredAttrs := []string{"id", "name", "status", "zone", "networkInterfaces", "selfLink"}
+	for _, project := range g.config.ProjectIDs {
+		jVMs, err := g.getRawData(newRawDataGetContext(g.computeURL(project, "aggregated/instances"), "instances").setAggregated())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for i := range jVMs {
+			jVM := jVMs[i]
+			name := jVM.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jVM, requiredAttrs) {
+				log.Infof("exclude vm: %s, missing attr", name)
+				continue
+			}
+			zone := lastPart(jVM.Get("zone").MustString())
+			regionName := g.toolDataSet.zoneNameToRegionName[zone]
+			if regionName == "" {
+				log.Debugf("exclude vm: %s, zone (%s) not included", name, zone)
+				continue
+			}
+			jNICs := jVM.Get("networkInterfaces")
+			if len(jNICs.MustArray()) == 0 {
+				log.Infof("exclude vm: %s, has no network interface", name)
+				continue
+			}
+			// 以第一块网卡所在的 VPC 作为虚拟机的 VPC
+			vpcLcuuid := g.vpcLcuuid(jNICs.GetIndex(0).Get("network").MustString())
+			if _, ok := g.toolDataSet.vpcLcuuidToRegionLcuuid[vpcLcuuid]; !ok {
+				log.Infof("exclude vm: %s, missing vpc info", name)
+				continue
+			}
+
+			lcuuid := common.GenerateUUID(jVM.Get("id").MustString())
+			azLcuuid := g.toolDataSet.zoneNameToAZLcuuid[zone]
+			regionLcuuid := g.getRegionLcuuid(regionName)
+			vm := model.VM{
+				Lcuuid:       lcuuid,
+				Name:         name,
+				Label:        jVM.Get("id").MustString(),
+				HType:        common.VM_HTYPE_VM_C,
+				State:        STATE_CONVERTION[jVM.Get("status").MustString()],
+				VPCLcuuid:    vpcLcuuid,
+				AZLcuuid:     azLcuuid,
+				RegionLcuuid: regionLcuuid,
+				CloudTags:    g.formatCloudTags(jVM.Get("labels")),
+			}
+			if created := jVM.Get("creationTimestamp").MustString(); created != "" {
+				createdAt, err := time.Parse(time.RFC3339, created)
+				if err != nil {
+					log.Errorf("parse created failed: %s", created)
+				} else {
+					vm.CreatedAt = createdAt
+				}
+			}
+			if vm.State == 0 {
+				vm.State = common.VM_STATE_EXCEPTION
+			}
+
+			vs, is := g.formatVInterfacesAndIPs(jNICs, lcuuid, regionLcuuid)
+			if len(is) > 0 {
+				vm.IP = is[0].IP
+				g.toolDataSet.vmLcuuidToIP[lcuuid] = is[0].IP
+			}
+			vms = append(vms, vm)
+			vifs = append(vifs, vs...)
+			ips = append(ips, is...)
+			g.toolDataSet.instanceKeyToVMLcuuid[resourceKey(jVM.Get("selfLink").MustString())] = lcuuid
+			g.toolDataSet.vmLcuuidToVPCLcuuid[lcuuid] = vpcLcuuid
+			g.toolDataSet.azLcuuidToResourceNum[azLcuuid]++
+			g.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+		}
+	}
+	return vms, vifs, ips, nil
+}
+
+// GCP 的标签值允许为空，与其他云平台保持一致，直接记录为 key: ""
+func (g *GCP) formatCloudTags(labels *simplejson.Json) map[string]string {
+	tags := make(map[string]string)
+	for k, v := range labels.MustMap() {
+		value, _ := v.(string)
+		tags[k] = value
+	}
+	return tags
+}
+
+// 每块网卡生成一个 LAN 网卡，主 IP 和 /32 的 alias IP 都挂在该网卡上，
+// 更大的 alias 网段一般为 GKE 的 Pod 网段，由容器子域负责同步；
+// accessConfigs 中的外网 IP 生成对应的 WAN 网卡
+func (g *GCP) formatVInterfacesAndIPs(jNICs *simplejson.Json, vmLcuuid, regionLcuuid string) (vifs []model.VInterface, ips []model.IP) {
+	for i := range jNICs.MustArray() {
+		jNIC := jNICs.GetIndex(i)
+		ip := jNIC.Get("networkIP").MustString()
+		network, ok := g.toolDataSet.subnetworkKeyToNetwork[resourceKey(jNIC.Get("subnetwork").MustString())]
+		if ip == "" || !ok {
+			log.Infof("exclude vinterface: vm (%s) nic (%s), missing subnetwork or ip", vmLcuuid, jNIC.Get("name").MustString())
+			continue
+		}
+		mac := generateMac(ip)
+		vif := model.VInterface{
+			Lcuuid:        common.GenerateUUID(vmLcuuid + mac),
+			Name:          jNIC.Get("name").MustString(),
+			Type:          common.VIF_TYPE_LAN,
+			Mac:           mac,
+			DeviceLcuuid:  vmLcuuid,
+			DeviceType:    common.VIF_DEVICE_TYPE_VM,
+			NetworkLcuuid: network.Lcuuid,
+			VPCLcuuid:     network.VPCLcuuid,
+			RegionLcuuid:  regionLcuuid,
+		}
+		vifs = append(vifs, vif)
+
+		lanIPs := []string{ip}
+		jAliases := jNIC.Get("aliasIpRanges")
+		for j := range jAliases.MustArray() {
+			cidr := jAliases.GetIndex(j).Get("ipCidrRange").MustString()
+			if strings.HasSuffix(cidr, "/32") {
+				lanIPs = append(lanIPs, strings.TrimSuffix(cidr, "/32"))
+			} else if !strings.Contains(cidr, "/") && cidr != "" {
+				lanIPs = append(lanIPs, cidr)
+			}
+		}
+		if ipv6 := jNIC.Get("ipv6Address").MustString(); ipv6 != "" {
+			lanIPs = append(lanIPs, ipv6)
+		}
+		for _, lanIP := range lanIPs {
+			ips = append(ips, model.IP{
+				Lcuuid:           common.GenerateUUID(vif.Lcuuid + lanIP),
+				VInterfaceLcuuid: vif.Lcuuid,
+				IP:               lanIP,
+				SubnetLcuuid:     g.getSubnetLcuuid(network.Lcuuid, lanIP),
+				RegionLcuuid:     regionLcuuid,
+			})
+		}
+
+		jAccessConfigs := jNIC.Get("accessConfigs")
+		for j := range jAccessConfigs.MustArray() {
+			natIP := jAccessConfigs.GetIndex(j).Get("natIP").MustString()
+			if natIP == "" {
+				continue
+			}
+			wanVIF := model.VInterface{
+				Lcuuid:        common.GenerateUUID(vmLcuuid + natIP),
+				Type:          common.VIF_TYPE_WAN,
+				Mac:           cloudcommon.GenerateWANVInterfaceMac(mac),
+				DeviceLcuuid:  vmLcuuid,
+				DeviceType:    common.VIF_DEVICE_TYPE_VM,
+				NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+				VPCLcuuid:     network.VPCLcuuid,
+				RegionLcuuid:  regionLcuuid,
+			}
+			vifs = append(vifs, wanVIF)
+			ips = append(ips, model.IP{
+				Lcuuid:           common.GenerateUUID(wanVIF.Lcuuid + natIP),
+				VInterfaceLcuuid: wanVIF.Lcuuid,
+				IP:               natIP,
+				RegionLcuuid:     regionLcuuid,
+			})
+		}
+	}
+	return
+}
+
+// GCE 虚拟机网卡的 MAC 地址由固定前缀 42:01 与网卡的内网 IPv4 地址拼接而成
+func generateMac(ip string) string {
+	ipv4 := net.ParseIP(ip).To4()
+	if ipv4 == nil {
+		return common.VIF_DEFAULT_MAC
+	}
+	return fmt.Sprintf("42:01:%02x:%02x:%02x:%02x", ipv4[0], ipv4[1], ipv4[2], ipv4[3])
+}
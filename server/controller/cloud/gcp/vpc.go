@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+)
+
+// GCP 的 VPC 网络是全局资源，而 DeepFlow 的 VPC 必须属于某个区域，
+// 因此将 VPC 归属到其第一个子网所在的区域，没有子网的 VPC 不做同步
+func (g *GCP) getVPCs() ([]model.VPC, error) {
+	var vpcs []model.VPC
+	for _, project := range g.config.ProjectIDs {
+		jVPCs, err := g.getRawData(newRawDataGetContext(g.computeURL(project, "global/networks"), "items"))
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range jVPCs {
+			jv := jVPCs[i]
+			name := jv.Get("name").MustString()
+			if !cloudcommon.CheckJsonAttributes(jv, []string{"id", "name", "selfLink"}) {
+				log.Infof("exclude vpc: %s, missing attr", name)
+				continue
+			}
+			lcuuid := g.vpcLcuuid(jv.Get("selfLink").MustString())
+			regionLcuuid, ok := g.toolDataSet.vpcLcuuidToRegionLcuuid[lcuuid]
+			if !ok {
+				log.Infof("exclude vpc: %s, has no subnetwork", name)
+				continue
+			}
+			vpcs = append(vpcs, model.VPC{
+				Lcuuid:       lcuuid,
+				Name:         name,
+				Label:        jv.Get("id").MustString(),
+				CIDR:         jv.Get("IPv4Range").MustString(),
+				RegionLcuuid: regionLcuuid,
+			})
+			g.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+		}
+	}
+	return vpcs, nil
+}
+
+// 子网、实例网卡等通过 URL 引用 VPC，直接由 URL 生成 VPC 的 lcuuid，不依赖资源的获取顺序
+func (g *GCP) vpcLcuuid(networkLink string) string {
+	return common.GenerateUUID(resourceKey(networkLink) + "_" + g.lcuuidGenerate)
+}
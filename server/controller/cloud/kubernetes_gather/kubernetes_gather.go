@@ -36,6 +36,9 @@ import (
 const (
 	K8S_VPC_NAME       = "kubernetes_vpc"
 	K8S_VERSION_PREFIX = "Kubernetes"
+
+	GATEWAY_API_GROUP      = "gateway.networking.k8s.io"
+	ISTIO_NETWORKING_GROUP = "networking.istio.io"
 )
 
 var log = logging.MustGetLogger("cloud.kubernetes_gather")
@@ -57,6 +60,7 @@ type KubernetesGather struct {
 	labelRegex                   *regexp.Regexp
 	envRegex                     *regexp.Regexp
 	annotationRegex              *regexp.Regexp
+	workloadCRDKindToType        map[string]int
 	podGroupLcuuids              mapset.Set
 	podNetworkLcuuidCIDRs        networkLcuuidCIDRs
 	nodeNetworkLcuuidCIDRs       networkLcuuidCIDRs
@@ -169,6 +173,22 @@ func NewKubernetesGather(domain *mysql.Domain, subDomain *mysql.SubDomain, cfg c
 		return nil
 	}
 
+	// 作为工作负载的 CRD 类型，如 {"Rollout": "deployment", "CloneSet": "cloneset"}，附属容器集群未配置时使用所属云平台的配置
+	workloadCRDKinds, ok := configJson.CheckGet("workload_crd_kinds")
+	if !ok && domainConfigJson != nil {
+		workloadCRDKinds = domainConfigJson.Get("workload_crd_kinds")
+	}
+	workloadCRDKindToType := map[string]int{}
+	for kind, t := range workloadCRDKinds.MustMap() {
+		typeName, _ := t.(string)
+		typeID, ok := pgNameToTypeID[strings.ToLower(typeName)]
+		if !ok {
+			log.Infof("workload crd kind (%s) pod group type (%s) not support, use deployment", kind, typeName)
+			typeID = common.POD_GROUP_DEPLOYMENT
+		}
+		workloadCRDKindToType[kind] = typeID
+	}
+
 	return &KubernetesGather{
 		// TODO: display_name后期需要修改为uuid_generate
 		Name:                  name,
@@ -183,6 +203,7 @@ func NewKubernetesGather(domain *mysql.Domain, subDomain *mysql.SubDomain, cfg c
 		labelRegex:            labelR,
 		envRegex:              envR,
 		annotationRegex:       annotationR,
+		workloadCRDKindToType: workloadCRDKindToType,
 
 		// 以下属性为获取资源所用的关联关系
 		azLcuuid:                     "",
@@ -330,6 +351,22 @@ func (k *KubernetesGather) GetKubernetesGatherData() (model.KubernetesGatherReso
 	if err != nil {
 		return model.KubernetesGatherResource{}, err
 	}
+
+	gateways, gatewayRules, gatewayRuleBackends, err := k.getPodGateways()
+	if err != nil {
+		return model.KubernetesGatherResource{}, err
+	}
+	ingresses = append(ingresses, gateways...)
+	ingressRules = append(ingressRules, gatewayRules...)
+	ingressRuleBackends = append(ingressRuleBackends, gatewayRuleBackends...)
+
+	virtualServices, virtualServiceRules, virtualServiceRuleBackends, err := k.getPodVirtualServices()
+	if err != nil {
+		return model.KubernetesGatherResource{}, err
+	}
+	ingresses = append(ingresses, virtualServices...)
+	ingressRules = append(ingressRules, virtualServiceRules...)
+	ingressRuleBackends = append(ingressRuleBackends, virtualServiceRuleBackends...)
 	for index, s := range podServices {
 		if ingressLcuuid, ok := k.serviceLcuuidToIngressLcuuid[s.Lcuuid]; ok {
 			podServices[index].PodIngressLcuuid = ingressLcuuid
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/agiledragon/gomonkey/v2"
+	"github.com/bitly/go-simplejson"
 	. "github.com/smartystreets/goconvey/convey"
 
 	cloudconfig "github.com/deepflowio/deepflow/server/controller/cloud/config"
@@ -123,3 +124,135 @@ func TestKubernetes(t *testing.T) {
 		})
 	})
 }
+
+func TestKubernetesRoutesAndWorkloadCRDs(t *testing.T) {
+	Convey("TestKubernetesRoutesAndWorkloadCRDs", t, func() {
+		domain := mysql.Domain{
+			Name:   "test_k8s",
+			Config: `{"workload_crd_kinds": {"Rollout": "deployment", "CloneSet": "cloneset"}}`,
+		}
+		subDomain := mysql.SubDomain{
+			Name:        "test_k8s_sub_domain",
+			DisplayName: "test_k8s_sub_domain",
+			ClusterID:   "d-01LMvvfQPZ",
+			Config:      fmt.Sprintf(`{"region_uuid": "%s","vpc_uuid": ""}`, common.DEFAULT_REGION),
+		}
+		k8s := NewKubernetesGather(&domain, &subDomain, cloudconfig.CloudConfig{}, true)
+		So(k8s.workloadCRDKindToType, ShouldResemble, map[string]int{"Rollout": common.POD_GROUP_DEPLOYMENT, "CloneSet": common.POD_GROUP_CLONESET})
+
+		k8s.namespaceToLcuuid = map[string]string{"default": "ns-default", "prod": "ns-prod"}
+		k8s.nsServiceNameToService = map[string]map[string]map[string]int{
+			"defaultweb":  {"svc-web": {"metrics": 9100, "http": 80, "grpc": 8080}},
+			"prodreviews": {"svc-reviews": {"http": 9080}},
+		}
+		k8s.k8sInfo = map[string][]string{
+			"*v1alpha1.Rollout": {
+				`{"metadata":{"uid":"rollout-web","name":"web","namespace":"default"},"spec":{"replicas":2,"template":{"metadata":{"labels":{"app":"web"}}}}}`,
+			},
+			"*v1.CloneSet": {
+				`{"metadata":{"uid":"cloneset-api","name":"api","namespace":"default"},"spec":{"replicas":1}}`,
+			},
+			// Istio 的 v1 Gateway 不应遮蔽 Gateway API 的 v1beta1 Gateway
+			"*v1.Gateway": {
+				`{"apiVersion":"networking.istio.io/v1","kind":"Gateway","metadata":{"uid":"istio-gateway","name":"istio","namespace":"default"},"spec":{"servers":[]}}`,
+			},
+			"*v1beta1.Gateway": {
+				`{"apiVersion":"gateway.networking.k8s.io/v1beta1","kind":"Gateway","metadata":{"uid":"gateway-public","name":"public","namespace":"default"},"spec":{"gatewayClassName":"envoy","listeners":[{"name":"https","protocol":"HTTPS","port":443}]}}`,
+				`{"metadata":{"uid":"istio-gateway-legacy","name":"istio-legacy","namespace":"default"},"spec":{"servers":[]}}`,
+			},
+			"*v1.HTTPRoute": {
+				`{"metadata":{"uid":"route-web","name":"web","namespace":"default"},"spec":{"parentRefs":[{"name":"public","sectionName":"https"}],"hostnames":["a.example.com","b.example.com"],"rules":[{"matches":[{"path":{"type":"PathPrefix","value":"/api"}}],"backendRefs":[{"name":"web","port":80}]}]}}`,
+				`{"metadata":{"uid":"route-mesh","name":"mesh","namespace":"default"},"spec":{"parentRefs":[{"kind":"Service","name":"web"}],"rules":[{"backendRefs":[{"name":"web"}]}]}}`,
+			},
+			"*v1beta1.DestinationRule": {
+				`{"metadata":{"namespace":"prod"},"spec":{"host":"reviews","subsets":[{"name":"v1"}]}}`,
+			},
+			"*v1beta1.VirtualService": {
+				`{"metadata":{"uid":"vs-reviews","name":"reviews","namespace":"default"},"spec":{"hosts":["reviews.prod.svc.cluster.local"],"http":[{"match":[{"uri":{"prefix":"/reviews"}}],"route":[{"destination":{"host":"reviews.prod.svc.cluster.local","subset":"v1"}},{"destination":{"host":"reviews.prod","subset":"v2"}}]}]}}`,
+			},
+		}
+
+		Convey("configured crd kinds should be synced as pod groups", func() {
+			podGroups, err := k8s.getPodGroups()
+			So(err, ShouldBeNil)
+			So(len(podGroups), ShouldEqual, 2)
+			lcuuidToType := map[string]int{}
+			for _, pg := range podGroups {
+				lcuuidToType[pg.Lcuuid] = pg.Type
+			}
+			So(lcuuidToType["rollout-web"], ShouldEqual, common.POD_GROUP_DEPLOYMENT)
+			So(lcuuidToType["cloneset-api"], ShouldEqual, common.POD_GROUP_CLONESET)
+		})
+
+		Convey("httproutes should be synced as rules of their gateways", func() {
+			ingresses, rules, backends, err := k8s.getPodGateways()
+			So(err, ShouldBeNil)
+			So(len(ingresses), ShouldEqual, 2)
+			So(len(rules), ShouldEqual, 3)
+			So(rules[0].PodIngressLcuuid, ShouldEqual, "gateway-public")
+			So(rules[0].Protocol, ShouldEqual, "HTTPS")
+			So(rules[2].PodIngressLcuuid, ShouldEqual, "route-mesh")
+			So(len(backends), ShouldEqual, 3)
+			So(backends[0].Path, ShouldEqual, "/api")
+			So(backends[2].Port, ShouldEqual, 80)
+		})
+
+		Convey("backends without port should use the lowest service port", func() {
+			for i := 0; i < 20; i++ {
+				backend, ok := k8s.newIngressRuleBackend("route-mesh", "rule-mesh", "default", "web", "", 0)
+				So(ok, ShouldBeTrue)
+				So(backend.Port, ShouldEqual, 80)
+			}
+		})
+
+		Convey("virtualservice destinations should be checked against destinationrule subsets", func() {
+			ingresses, rules, backends, err := k8s.getPodVirtualServices()
+			So(err, ShouldBeNil)
+			So(len(ingresses), ShouldEqual, 1)
+			So(len(rules), ShouldEqual, 1)
+			So(len(backends), ShouldEqual, 1)
+			So(backends[0].PodServiceLcuuid, ShouldEqual, "svc-reviews")
+			So(backends[0].Port, ShouldEqual, 9080)
+		})
+	})
+}
+
+func TestGetPodGroupRolloutState(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want int
+	}{
+		{
+			name: "rolling update in progress",
+			data: `{"metadata": {"generation": 2}, "spec": {"replicas": 3}, "status": {"observedGeneration": 2, "replicas": 3, "updatedReplicas": 1}}`,
+			want: common.POD_GROUP_ROLLOUT_STATE_PROGRESSING,
+		},
+		{
+			name: "rolling update complete",
+			data: `{"metadata": {"generation": 2}, "spec": {"replicas": 3}, "status": {"observedGeneration": 2, "replicas": 3, "updatedReplicas": 3}}`,
+			want: common.POD_GROUP_ROLLOUT_STATE_COMPLETE,
+		},
+		{
+			name: "on delete spec not observed",
+			data: `{"metadata": {"generation": 2}, "spec": {"replicas": 3, "updateStrategy": {"type": "OnDelete"}}, "status": {"observedGeneration": 1, "replicas": 3, "updatedReplicas": 3}}`,
+			want: common.POD_GROUP_ROLLOUT_STATE_PROGRESSING,
+		},
+		{
+			name: "on delete spec observed",
+			data: `{"metadata": {"generation": 2}, "spec": {"replicas": 3, "updateStrategy": {"type": "OnDelete"}}, "status": {"observedGeneration": 2, "replicas": 3, "updatedReplicas": 0}}`,
+			want: common.POD_GROUP_ROLLOUT_STATE_COMPLETE,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := simplejson.NewJson([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := getPodGroupRolloutState(data); got != tt.want {
+				t.Errorf("getPodGroupRolloutState() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
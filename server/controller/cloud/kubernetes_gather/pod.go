@@ -40,6 +40,9 @@ func (k *KubernetesGather) getPods() (pods []model.Pod, err error) {
 		"StatefulSet":           false,
 		"ReplicationController": false,
 	}
+	for kind := range k.workloadCRDKindToType {
+		podTypesMap[kind] = false
+	}
 	for _, p := range k.k8sInfo["*v1.Pod"] {
 		pData, pErr := simplejson.NewJson([]byte(p))
 		if pErr != nil {
@@ -0,0 +1,210 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes_gather
+
+import (
+	"strconv"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Gateway API 资源同步为入口资源：Gateway 作为入口，挂载在其上的 HTTPRoute 作为入口规则，
+// HTTPRoute 的 backendRefs 作为规则后端；未挂载到已知 Gateway 上的 HTTPRoute（如服务网格场景下挂载到 Service 上）
+// 单独作为一个入口资源
+func (k *KubernetesGather) getPodGateways() (ingresses []model.PodIngress, ingressRules []model.PodIngressRule, ingressRuleBackends []model.PodIngressRuleBackend, err error) {
+	log.Debug("get gateways starting")
+	gatewayKeyToLcuuid := map[string]string{}
+	listenerKeyToProtocol := map[string]string{}
+	for _, g := range k.getFirstK8sInfo(GATEWAY_API_GROUP, "Gateway", "v1", "v1beta1") {
+		gData, gErr := simplejson.NewJson([]byte(g))
+		if gErr != nil {
+			err = gErr
+			log.Errorf("gateway initialization simplejson error: (%s)", gErr.Error())
+			return
+		}
+		// 未携带 apiVersion 的 Istio Gateway 通过 spec.listeners 区分
+		listeners, ok := gData.Get("spec").CheckGet("listeners")
+		if !ok {
+			continue
+		}
+		metaData, ok := gData.CheckGet("metadata")
+		if !ok {
+			log.Info("gateway metadata not found")
+			continue
+		}
+		uID := metaData.Get("uid").MustString()
+		if uID == "" {
+			log.Info("gateway uid not found")
+			continue
+		}
+		name := metaData.Get("name").MustString()
+		if name == "" {
+			log.Infof("gateway (%s) name not found", uID)
+			continue
+		}
+		namespace := metaData.Get("namespace").MustString()
+		namespaceLcuuid, ok := k.namespaceToLcuuid[namespace]
+		if !ok {
+			log.Infof("gateway (%s) namespace not found", name)
+			continue
+		}
+		ingresses = append(ingresses, model.PodIngress{
+			Lcuuid:             uID,
+			Name:               name,
+			PodNamespaceLcuuid: namespaceLcuuid,
+			AZLcuuid:           k.azLcuuid,
+			RegionLcuuid:       k.RegionUUID,
+			PodClusterLcuuid:   k.podClusterLcuuid,
+		})
+		gatewayKeyToLcuuid[namespace+"/"+name] = uID
+		for i := range listeners.MustArray() {
+			listener := listeners.GetIndex(i)
+			listenerKeyToProtocol[uID+listener.Get("name").MustString()] = listener.Get("protocol").MustString()
+		}
+	}
+
+	for _, r := range k.getFirstK8sInfo(GATEWAY_API_GROUP, "HTTPRoute", "v1", "v1beta1") {
+		rData, rErr := simplejson.NewJson([]byte(r))
+		if rErr != nil {
+			err = rErr
+			log.Errorf("httproute initialization simplejson error: (%s)", rErr.Error())
+			return
+		}
+		metaData, ok := rData.CheckGet("metadata")
+		if !ok {
+			log.Info("httproute metadata not found")
+			continue
+		}
+		uID := metaData.Get("uid").MustString()
+		if uID == "" {
+			log.Info("httproute uid not found")
+			continue
+		}
+		name := metaData.Get("name").MustString()
+		if name == "" {
+			log.Infof("httproute (%s) name not found", uID)
+			continue
+		}
+		namespace := metaData.Get("namespace").MustString()
+		namespaceLcuuid, ok := k.namespaceToLcuuid[namespace]
+		if !ok {
+			log.Infof("httproute (%s) namespace not found", name)
+			continue
+		}
+
+		spec := rData.Get("spec")
+		ingressLcuuidToProtocol := map[string]string{}
+		var ingressLcuuids []string
+		parentRefs := spec.Get("parentRefs")
+		for i := range parentRefs.MustArray() {
+			parentRef := parentRefs.GetIndex(i)
+			if kind := parentRef.Get("kind").MustString(); kind != "" && kind != "Gateway" {
+				continue
+			}
+			if group := parentRef.Get("group").MustString(); group != "" && group != GATEWAY_API_GROUP {
+				continue
+			}
+			parentNamespace := parentRef.Get("namespace").MustString()
+			if parentNamespace == "" {
+				parentNamespace = namespace
+			}
+			gatewayLcuuid, ok := gatewayKeyToLcuuid[parentNamespace+"/"+parentRef.Get("name").MustString()]
+			if !ok {
+				continue
+			}
+			if _, ok := ingressLcuuidToProtocol[gatewayLcuuid]; !ok {
+				ingressLcuuids = append(ingressLcuuids, gatewayLcuuid)
+			}
+			protocol := listenerKeyToProtocol[gatewayLcuuid+parentRef.Get("sectionName").MustString()]
+			if protocol == "" {
+				protocol = "HTTP"
+			}
+			ingressLcuuidToProtocol[gatewayLcuuid] = protocol
+		}
+		if len(ingressLcuuids) == 0 {
+			ingresses = append(ingresses, model.PodIngress{
+				Lcuuid:             uID,
+				Name:               name,
+				PodNamespaceLcuuid: namespaceLcuuid,
+				AZLcuuid:           k.azLcuuid,
+				RegionLcuuid:       k.RegionUUID,
+				PodClusterLcuuid:   k.podClusterLcuuid,
+			})
+			ingressLcuuids = append(ingressLcuuids, uID)
+			ingressLcuuidToProtocol[uID] = "HTTP"
+		}
+
+		hosts := spec.Get("hostnames").MustStringArray()
+		if len(hosts) == 0 {
+			hosts = []string{""}
+		}
+		for _, ingressLcuuid := range ingressLcuuids {
+			for h, host := range hosts {
+				ruleLcuuid := common.GetUUID(ingressLcuuid+uID+host+"_"+strconv.Itoa(h), uuid.Nil)
+				ingressRules = append(ingressRules, model.PodIngressRule{
+					Lcuuid:           ruleLcuuid,
+					Name:             name,
+					Host:             host,
+					Protocol:         ingressLcuuidToProtocol[ingressLcuuid],
+					PodIngressLcuuid: ingressLcuuid,
+				})
+				ingressRuleBackends = append(ingressRuleBackends, k.getHTTPRouteBackends(spec.Get("rules"), ingressLcuuid, ruleLcuuid, namespace)...)
+			}
+		}
+	}
+	log.Debug("get gateways complete")
+	return
+}
+
+func (k *KubernetesGather) getHTTPRouteBackends(rules *simplejson.Json, ingressLcuuid, ruleLcuuid, namespace string) (backends []model.PodIngressRuleBackend) {
+	backendLcuuids := map[string]bool{}
+	for i := range rules.MustArray() {
+		rule := rules.GetIndex(i)
+		var paths []string
+		matches := rule.Get("matches")
+		for m := range matches.MustArray() {
+			paths = append(paths, matches.GetIndex(m).Get("path").Get("value").MustString())
+		}
+		if len(paths) == 0 {
+			paths = []string{""}
+		}
+		backendRefs := rule.Get("backendRefs")
+		for b := range backendRefs.MustArray() {
+			backendRef := backendRefs.GetIndex(b)
+			// 仅支持 Service 类型的后端
+			if kind := backendRef.Get("kind").MustString(); kind != "" && kind != "Service" {
+				continue
+			}
+			backendNamespace := backendRef.Get("namespace").MustString()
+			if backendNamespace == "" {
+				backendNamespace = namespace
+			}
+			for _, path := range paths {
+				backend, ok := k.newIngressRuleBackend(ingressLcuuid, ruleLcuuid, backendNamespace, backendRef.Get("name").MustString(), path, backendRef.Get("port").MustInt())
+				if !ok || backendLcuuids[backend.Lcuuid] {
+					continue
+				}
+				backendLcuuids[backend.Lcuuid] = true
+				backends = append(backends, backend)
+			}
+		}
+	}
+	return
+}
@@ -17,6 +17,9 @@
 package kubernetes_gather
 
 import (
+	"crypto/md5"
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/bitly/go-simplejson"
@@ -26,22 +29,26 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
+var pgNameToTypeID = map[string]int{
+	"deployment":            common.POD_GROUP_DEPLOYMENT,
+	"statefulset":           common.POD_GROUP_STATEFULSET,
+	"replicaset":            common.POD_GROUP_REPLICASET_CONTROLLER,
+	"daemonset":             common.POD_GROUP_DAEMON_SET,
+	"replicationcontroller": common.POD_GROUP_RC,
+	"cloneset":              common.POD_GROUP_CLONESET,
+}
+
 func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, err error) {
 	log.Debug("get podgroups starting")
-	podControllers := [5][]string{}
+	podControllers := make([][]string, 5)
 	podControllers[0] = k.k8sInfo["*v1.Deployment"]
 	podControllers[1] = k.k8sInfo["*v1.StatefulSet"]
 	podControllers[2] = k.k8sInfo["*v1.DaemonSet"]
 	podControllers[3] = k.k8sInfo["*v1.CloneSet"]
 	podControllers[4] = k.k8sInfo["*v1.Pod"]
-	pgNameToTypeID := map[string]int{
-		"deployment":            common.POD_GROUP_DEPLOYMENT,
-		"statefulset":           common.POD_GROUP_STATEFULSET,
-		"replicaset":            common.POD_GROUP_REPLICASET_CONTROLLER,
-		"daemonset":             common.POD_GROUP_DAEMON_SET,
-		"replicationcontroller": common.POD_GROUP_RC,
-		"cloneset":              common.POD_GROUP_CLONESET,
-	}
+	// 配置为工作负载的 CRD（如 Argo Rollout）排在内置类型之后
+	crdKinds, crdControllers := k.getWorkloadCRDs()
+	podControllers = append(podControllers, crdControllers...)
 	for t, podController := range podControllers {
 		for _, c := range podController {
 			podTargetPorts := map[string]int{}
@@ -135,6 +142,14 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, err error
 					label = typeName + ":" + namespace + ":" + abstractPGName
 					name = abstractPGName
 				}
+			default:
+				kind := crdKinds[t-5]
+				if k.podGroupLcuuids.Contains(uID) {
+					log.Debugf("podgroup (%s) kind (%s) already existed", name, kind)
+					continue
+				}
+				serviceType = k.workloadCRDKindToType[kind]
+				label = strings.ToLower(kind) + ":" + namespace + ":" + name
 			}
 
 			_, ok = k.nsLabelToGroupLcuuids[namespace+label]
@@ -194,10 +209,14 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, err error
 					podTargetPorts[cPortName] = cPort.Get("containerPort").MustInt()
 				}
 			}
+			images, templateHash := getPodGroupTemplate(cData)
 			podGroup := model.PodGroup{
 				Lcuuid:             uID,
 				Name:               name,
 				Label:              k.GetLabel(labels),
+				Images:             images,
+				TemplateHash:       templateHash,
+				RolloutState:       getPodGroupRolloutState(cData),
 				Type:               serviceType,
 				PodNum:             replicas,
 				PodNamespaceLcuuid: namespaceLcuuid,
@@ -214,6 +233,86 @@ func (k *KubernetesGather) getPodGroups() (podGroups []model.PodGroup, err error
 	return
 }
 
+// 获取工作负载 pod 模板中的容器镜像及模板哈希，模板哈希变化（如镜像变更、ConfigMap 校验和注解变更、kubectl rollout restart）意味着开始一次发布
+func getPodGroupTemplate(data *simplejson.Json) (images, templateHash string) {
+	template, ok := data.Get("spec").CheckGet("template")
+	if !ok {
+		return
+	}
+	var containerImages []string
+	containers := template.Get("spec").Get("containers")
+	for i := range containers.MustArray() {
+		container := containers.GetIndex(i)
+		image := container.Get("image").MustString()
+		if image == "" {
+			continue
+		}
+		containerImages = append(containerImages, container.Get("name").MustString()+"="+image)
+	}
+	sort.Strings(containerImages)
+	images = strings.Join(containerImages, ", ")
+
+	tBytes, err := template.Encode()
+	if err != nil {
+		log.Warningf("podgroup template encode error: (%s)", err.Error())
+		return
+	}
+	templateHash = fmt.Sprintf("%x", md5.Sum(tBytes))
+	return
+}
+
+// 根据工作负载的 status 判断发布是否完成，不包含相关字段的资源（如部分 CRD）视为已完成
+func getPodGroupRolloutState(data *simplejson.Json) int {
+	status, ok := data.CheckGet("status")
+	if !ok {
+		return common.POD_GROUP_ROLLOUT_STATE_COMPLETE
+	}
+	generation := data.Get("metadata").Get("generation").MustInt()
+	if observed, ok := status.CheckGet("observedGeneration"); ok && observed.MustInt() < generation {
+		return common.POD_GROUP_ROLLOUT_STATE_PROGRESSING
+	}
+	// OnDelete 策略下 pod 只在被手动删除后才会更新，spec 被观测到即视为发布完成
+	if data.Get("spec").Get("updateStrategy").Get("type").MustString() == "OnDelete" {
+		return common.POD_GROUP_ROLLOUT_STATE_COMPLETE
+	}
+	// DaemonSet
+	if desired, ok := status.CheckGet("desiredNumberScheduled"); ok {
+		if status.Get("updatedNumberScheduled").MustInt() < desired.MustInt() {
+			return common.POD_GROUP_ROLLOUT_STATE_PROGRESSING
+		}
+		return common.POD_GROUP_ROLLOUT_STATE_COMPLETE
+	}
+	// Deployment、StatefulSet、CloneSet 等，updatedReplicas 为 0 时 status 中不包含该字段
+	_, hasReplicas := status.CheckGet("replicas")
+	_, hasUpdated := status.CheckGet("updatedReplicas")
+	if !hasReplicas && !hasUpdated {
+		return common.POD_GROUP_ROLLOUT_STATE_COMPLETE
+	}
+	updated := status.Get("updatedReplicas").MustInt()
+	if updated < data.Get("spec").Get("replicas").MustInt() || status.Get("replicas").MustInt() > updated {
+		return common.POD_GROUP_ROLLOUT_STATE_PROGRESSING
+	}
+	return common.POD_GROUP_ROLLOUT_STATE_COMPLETE
+}
+
+// 获取配置为工作负载的 CRD 资源，资源类型按照 kind 匹配，不区分版本，如 Rollout 匹配 *v1alpha1.Rollout
+func (k *KubernetesGather) getWorkloadCRDs() (kinds []string, controllers [][]string) {
+	var infoKeys []string
+	for key := range k.k8sInfo {
+		infoKeys = append(infoKeys, key)
+	}
+	sort.Strings(infoKeys)
+	for _, key := range infoKeys {
+		kind := key[strings.LastIndex(key, ".")+1:]
+		if _, ok := k.workloadCRDKindToType[kind]; !ok {
+			continue
+		}
+		kinds = append(kinds, kind)
+		controllers = append(controllers, k.k8sInfo[key])
+	}
+	return
+}
+
 func (k *KubernetesGather) getPodReplicationControllers() (podRCs []model.PodGroup, err error) {
 	log.Debug("get replicationcontrollers starting")
 	for _, r := range k.k8sInfo["*v1.ReplicationController"] {
@@ -289,10 +388,14 @@ func (k *KubernetesGather) getPodReplicationControllers() (podRCs []model.PodGro
 		}
 
 		podNum := rData.Get("spec").Get("replicas").MustInt()
+		// ReplicationController 不支持滚动更新，只记录镜像及模板哈希
+		images, templateHash := getPodGroupTemplate(rData)
 		podRC := model.PodGroup{
 			Lcuuid:             uID,
 			Name:               name,
 			Label:              k.GetLabel(labels),
+			Images:             images,
+			TemplateHash:       templateHash,
 			Type:               serviceType,
 			PodNum:             podNum,
 			RegionLcuuid:       k.RegionUUID,
@@ -17,6 +17,7 @@
 package kubernetes_gather
 
 import (
+	"sort"
 	"strconv"
 
 	"github.com/bitly/go-simplejson"
@@ -250,3 +251,75 @@ func (k *KubernetesGather) getPodIngresses() (ingresses []model.PodIngress, ingr
 	log.Debug("get ingresses complete")
 	return
 }
+
+// 按照版本依次查找 k8sInfo 中的资源，返回第一个存在的版本的资源，用于兼容同一资源的多个版本；
+// 不同 API 组下存在同名的资源（如 Istio 与 Gateway API 的 Gateway），只保留 apiVersion 中的组与 group 相同的资源，
+// 未携带 apiVersion 的资源无法区分，同样保留
+func (k *KubernetesGather) getFirstK8sInfo(group, kind string, versions ...string) []string {
+	for _, version := range versions {
+		var resources []string
+		for _, r := range k.k8sInfo["*"+version+"."+kind] {
+			rData, err := simplejson.NewJson([]byte(r))
+			if err != nil {
+				// 交由调用方处理解析错误
+				resources = append(resources, r)
+				continue
+			}
+			apiVersion := rData.Get("apiVersion").MustString()
+			if apiVersion != "" && apiVersion != group+"/"+version {
+				continue
+			}
+			resources = append(resources, r)
+		}
+		if len(resources) != 0 {
+			return resources
+		}
+	}
+	return nil
+}
+
+// 生成 Gateway API、Istio 等路由资源的后端，并关联服务与入口资源
+func (k *KubernetesGather) newIngressRuleBackend(ingressLcuuid, ruleLcuuid, namespace, serviceName, path string, port int) (model.PodIngressRuleBackend, bool) {
+	service, ok := k.nsServiceNameToService[namespace+serviceName]
+	if !ok {
+		log.Infof("ingress (%s) backend service (%s) not found", ingressLcuuid, serviceName)
+		return model.PodIngressRuleBackend{}, false
+	}
+	serviceLcuuid, ports := "", map[string]int{}
+	for key, v := range service {
+		serviceLcuuid = key
+		ports = v
+		break
+	}
+	// 未指定端口时使用服务的端口，服务有多个端口时取最小的端口，保证每次同步结果一致
+	if port == 0 {
+		servicePorts := make([]int, 0, len(ports))
+		for _, p := range ports {
+			if p != 0 {
+				servicePorts = append(servicePorts, p)
+			}
+		}
+		if len(servicePorts) != 0 {
+			sort.Ints(servicePorts)
+			port = servicePorts[0]
+		}
+	}
+	if port == 0 {
+		log.Infof("ingress (%s) backend service (%s) no servicePort", ingressLcuuid, serviceName)
+		return model.PodIngressRuleBackend{}, false
+	}
+	if lcuuid, ok := k.serviceLcuuidToIngressLcuuid[serviceLcuuid]; ok && lcuuid != ingressLcuuid {
+		log.Infof("ingress (%s) is already associated with the service (%s), and ingress (%s) cannot be associated", lcuuid, serviceLcuuid, ingressLcuuid)
+	} else {
+		k.serviceLcuuidToIngressLcuuid[serviceLcuuid] = ingressLcuuid
+	}
+	key := serviceName + "_" + strconv.Itoa(port)
+	return model.PodIngressRuleBackend{
+		Lcuuid:               common.GetUUID(ruleLcuuid+key+path, uuid.Nil),
+		Path:                 path,
+		Port:                 port,
+		PodServiceLcuuid:     serviceLcuuid,
+		PodIngressRuleLcuuid: ruleLcuuid,
+		PodIngressLcuuid:     ingressLcuuid,
+	}, true
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes_gather
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+	mapset "github.com/deckarep/golang-set"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Istio VirtualService 同步为入口资源，hosts 作为入口规则，http 路由的 destination 作为规则后端；
+// DestinationRule 用于校验 destination 中的 subset，引用不存在的 subset 的流量在 Istio 中无法转发，不生成后端
+func (k *KubernetesGather) getPodVirtualServices() (ingresses []model.PodIngress, ingressRules []model.PodIngressRule, ingressRuleBackends []model.PodIngressRuleBackend, err error) {
+	log.Debug("get virtualservices starting")
+	serviceKeyToSubsets, err := k.getDestinationRuleSubsets()
+	if err != nil {
+		return
+	}
+	for _, v := range k.getFirstK8sInfo(ISTIO_NETWORKING_GROUP, "VirtualService", "v1", "v1beta1", "v1alpha3") {
+		vData, vErr := simplejson.NewJson([]byte(v))
+		if vErr != nil {
+			err = vErr
+			log.Errorf("virtualservice initialization simplejson error: (%s)", vErr.Error())
+			return
+		}
+		metaData, ok := vData.CheckGet("metadata")
+		if !ok {
+			log.Info("virtualservice metadata not found")
+			continue
+		}
+		uID := metaData.Get("uid").MustString()
+		if uID == "" {
+			log.Info("virtualservice uid not found")
+			continue
+		}
+		name := metaData.Get("name").MustString()
+		if name == "" {
+			log.Infof("virtualservice (%s) name not found", uID)
+			continue
+		}
+		namespace := metaData.Get("namespace").MustString()
+		namespaceLcuuid, ok := k.namespaceToLcuuid[namespace]
+		if !ok {
+			log.Infof("virtualservice (%s) namespace not found", name)
+			continue
+		}
+		ingresses = append(ingresses, model.PodIngress{
+			Lcuuid:             uID,
+			Name:               name,
+			PodNamespaceLcuuid: namespaceLcuuid,
+			AZLcuuid:           k.azLcuuid,
+			RegionLcuuid:       k.RegionUUID,
+			PodClusterLcuuid:   k.podClusterLcuuid,
+		})
+
+		spec := vData.Get("spec")
+		hosts := spec.Get("hosts").MustStringArray()
+		if len(hosts) == 0 {
+			hosts = []string{""}
+		}
+		for h, host := range hosts {
+			ruleLcuuid := common.GetUUID(uID+host+"_"+strconv.Itoa(h), uuid.Nil)
+			ingressRules = append(ingressRules, model.PodIngressRule{
+				Lcuuid:           ruleLcuuid,
+				Host:             host,
+				Protocol:         "HTTP",
+				PodIngressLcuuid: uID,
+			})
+			backendLcuuids := map[string]bool{}
+			routes := spec.Get("http")
+			for i := range routes.MustArray() {
+				route := routes.GetIndex(i)
+				var paths []string
+				matches := route.Get("match")
+				for m := range matches.MustArray() {
+					uri := matches.GetIndex(m).Get("uri")
+					for _, matchType := range []string{"exact", "prefix", "regex"} {
+						if path := uri.Get(matchType).MustString(); path != "" {
+							paths = append(paths, path)
+							break
+						}
+					}
+				}
+				if len(paths) == 0 {
+					paths = []string{""}
+				}
+				destinations := route.Get("route")
+				for d := range destinations.MustArray() {
+					destination := destinations.GetIndex(d).Get("destination")
+					serviceNamespace, serviceName := parseIstioHost(destination.Get("host").MustString(), namespace)
+					if subset := destination.Get("subset").MustString(); subset != "" {
+						subsets, ok := serviceKeyToSubsets[serviceNamespace+serviceName]
+						if !ok || !subsets.Contains(subset) {
+							log.Infof("virtualservice (%s) destination (%s) subset (%s) not found", name, serviceName, subset)
+							continue
+						}
+					}
+					for _, path := range paths {
+						backend, ok := k.newIngressRuleBackend(uID, ruleLcuuid, serviceNamespace, serviceName, path, destination.Get("port").Get("number").MustInt())
+						if !ok || backendLcuuids[backend.Lcuuid] {
+							continue
+						}
+						backendLcuuids[backend.Lcuuid] = true
+						ingressRuleBackends = append(ingressRuleBackends, backend)
+					}
+				}
+			}
+		}
+	}
+	log.Debug("get virtualservices complete")
+	return
+}
+
+func (k *KubernetesGather) getDestinationRuleSubsets() (map[string]mapset.Set, error) {
+	serviceKeyToSubsets := map[string]mapset.Set{}
+	for _, d := range k.getFirstK8sInfo(ISTIO_NETWORKING_GROUP, "DestinationRule", "v1", "v1beta1", "v1alpha3") {
+		dData, err := simplejson.NewJson([]byte(d))
+		if err != nil {
+			log.Errorf("destinationrule initialization simplejson error: (%s)", err.Error())
+			return nil, err
+		}
+		namespace := dData.Get("metadata").Get("namespace").MustString()
+		serviceNamespace, serviceName := parseIstioHost(dData.Get("spec").Get("host").MustString(), namespace)
+		subsetSet, ok := serviceKeyToSubsets[serviceNamespace+serviceName]
+		if !ok {
+			subsetSet = mapset.NewSet()
+			serviceKeyToSubsets[serviceNamespace+serviceName] = subsetSet
+		}
+		subsets := dData.Get("spec").Get("subsets")
+		for i := range subsets.MustArray() {
+			subsetSet.Add(subsets.GetIndex(i).Get("name").MustString())
+		}
+	}
+	return serviceKeyToSubsets, nil
+}
+
+// Istio 中的 host 可以是短名称（reviews）、带命名空间的名称（reviews.prod）或完整域名（reviews.prod.svc.cluster.local），
+// 短名称使用资源所在的命名空间
+func parseIstioHost(host, namespace string) (string, string) {
+	parts := strings.Split(host, ".")
+	if len(parts) >= 2 {
+		return parts[1], parts[0]
+	}
+	return namespace, host
+}
@@ -398,6 +398,9 @@ type PodGroup struct {
 	Lcuuid             string `json:"lcuuid" binding:"required"`
 	Name               string `json:"name" binding:"required"`
 	Label              string `json:"label"`
+	Images             string `json:"images"`
+	TemplateHash       string `json:"template_hash"`
+	RolloutState       int    `json:"rollout_state"`
 	Type               int    `json:"type" binding:"required"`
 	PodNum             int    `json:"pod_num" binding:"required"`
 	PodNamespaceLcuuid string `json:"pod_namespace_lcuuid" binding:"required"`
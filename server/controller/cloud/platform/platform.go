@@ -27,6 +27,7 @@ import (
 	"github.com/deepflowio/deepflow/server/controller/cloud/baidubce"
 	"github.com/deepflowio/deepflow/server/controller/cloud/config"
 	"github.com/deepflowio/deepflow/server/controller/cloud/filereader"
+	"github.com/deepflowio/deepflow/server/controller/cloud/gcp"
 	"github.com/deepflowio/deepflow/server/controller/cloud/genesis"
 	"github.com/deepflowio/deepflow/server/controller/cloud/huawei"
 	"github.com/deepflowio/deepflow/server/controller/cloud/kubernetes"
@@ -68,6 +69,8 @@ func NewPlatform(domain mysql.Domain, cfg config.CloudConfig) (Platform, error)
 		platform, err = huawei.NewHuaWei(domain, cfg)
 	case common.FILEREADER:
 		platform, err = filereader.NewFileReader(domain)
+	case common.GCP:
+		platform, err = gcp.NewGCP(domain, cfg)
 	// TODO: other platform
 	default:
 		return nil, errors.New(fmt.Sprintf("domain type (%d) not supported", domain.Type))
@@ -229,6 +229,7 @@ const (
 	DEFAULT_REGION        = "ffffffff-ffff-ffff-ffff-ffffffffffff"
 	DEFAULT_AZ            = "ffffffff-ffff-ffff-ffff-ffffffffffff"
 	DEFAULT_VTAP_GROUP_ID = 1
+	DEFAULT_ORG_ID        = 1
 	DEFAULT_DOMAIN_ICON   = -3
 	DEFAULT_REGION_NAME   = "系统默认"
 )
@@ -241,7 +242,8 @@ const (
 )
 
 const (
-	ACL_STATE_ENABLE = 1
+	ACL_STATE_DISABLE = 0
+	ACL_STATE_ENABLE  = 1
 )
 
 const (
@@ -249,6 +251,31 @@ const (
 	NPB_POLICY_FLOW_DISTRIBUTE = 1
 )
 
+const (
+	NPB_POLICY_DIRECTION_ALL      = 1
+	NPB_POLICY_DIRECTION_FORWARD  = 2
+	NPB_POLICY_DIRECTION_BACKWARD = 3
+)
+
+const (
+	POLICY_TAP_SIDE_SRC  = 1
+	POLICY_TAP_SIDE_DST  = 2
+	POLICY_TAP_SIDE_BOTH = 3
+)
+
+const (
+	NPB_TUNNEL_TYPE_VXLAN  = 0
+	NPB_TUNNEL_TYPE_ERSPAN = 1
+
+	NPB_TUNNEL_VNI_INPUT_TYPE_ENTIRE    = 1
+	NPB_TUNNEL_VNI_INPUT_TYPE_TWO_PARTS = 2
+)
+
+const (
+	CUSTOM_IP_TAG_SOURCE_TYPE_UPLOAD = 0
+	CUSTOM_IP_TAG_SOURCE_TYPE_HTTP   = 1
+)
+
 const (
 	DEFAULT_ENCRYPTION_PASSWORD = "******"
 	DEFAULT_ALL_MATCH_REGEX     = ".*"
@@ -282,6 +309,7 @@ const (
 	ESHORE            = 26
 	CLOUD_TOWER       = 27
 	NFVO              = 28
+	GCP               = 29
 
 	OPENSTACK_EN         = "openstack"
 	VSPHERE_EN           = "vsphere"
@@ -311,6 +339,7 @@ const (
 	BAIDU_BCE_EN         = "baidu_bce"
 	CLOUD_TOWER_EN       = "cloudtower"
 	NFVO_EN              = "nfvo"
+	GCP_EN               = "gcp"
 
 	TENCENT_CH          = "腾讯云"
 	PINGAN_CH           = "平安云"
@@ -523,6 +552,13 @@ const (
 	POD_GROUP_CLONESET              = 6
 )
 
+const (
+	POD_GROUP_ROLLOUT_STATE_COMPLETE    = 0
+	POD_GROUP_ROLLOUT_STATE_PROGRESSING = 1
+	// 发布中且已生成发布开始事件，只由 recorder 记录，用于保证发布完成事件与开始事件成对出现
+	POD_GROUP_ROLLOUT_STATE_STARTED = 2
+)
+
 const (
 	POD_STATE_EXCEPTION = 0
 	POD_STATE_RUNNING   = 1
@@ -24,6 +24,7 @@ import (
 	logging "github.com/op/go-logging"
 	yaml "gopkg.in/yaml.v2"
 
+	alert "github.com/deepflowio/deepflow/server/controller/alert/config"
 	"github.com/deepflowio/deepflow/server/controller/db/clickhouse"
 	mysql "github.com/deepflowio/deepflow/server/controller/db/mysql/config"
 	"github.com/deepflowio/deepflow/server/controller/db/redis"
@@ -31,6 +32,7 @@ import (
 	http "github.com/deepflowio/deepflow/server/controller/http/config"
 	manager "github.com/deepflowio/deepflow/server/controller/manager/config"
 	monitor "github.com/deepflowio/deepflow/server/controller/monitor/config"
+	operator "github.com/deepflowio/deepflow/server/controller/operator/config"
 	prometheus "github.com/deepflowio/deepflow/server/controller/prometheus/config"
 	statsd "github.com/deepflowio/deepflow/server/controller/statsd/config"
 	tagrecorder "github.com/deepflowio/deepflow/server/controller/tagrecorder/config"
@@ -83,6 +85,7 @@ type ControllerConfig struct {
 	GrpcNodePort                   string `default:"30035" yaml:"grpc-node-port"`
 	Kubeconfig                     string `yaml:"kubeconfig"`
 	ElectionName                   string `default:"deepflow-server" yaml:"election-name"`
+	ElectionBackend                string `default:"kubernetes" yaml:"election-backend"`
 	ReportingDisabled              bool   `default:"false" yaml:"reporting-disabled"`
 	BillingMethod                  string `default:"license" yaml:"billing-method"`
 	PodClusterInternalIPToIngester int    `default:"0" yaml:"pod-cluster-internal-ip-to-ingester"`
@@ -105,6 +108,8 @@ type ControllerConfig struct {
 	TagRecorderCfg tagrecorder.TagRecorderConfig `yaml:"tagrecorder"`
 	PrometheusCfg  prometheus.Config             `yaml:"prometheus"`
 	HTTPCfg        http.Config                   `yaml:"http"`
+	OperatorCfg    operator.Config               `yaml:"operator"`
+	AlertCfg       alert.Config                  `yaml:"alert"`
 }
 
 type Config struct {
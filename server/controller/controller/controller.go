@@ -27,6 +27,7 @@ import (
 	yaml "gopkg.in/yaml.v2"
 
 	servercommon "github.com/deepflowio/deepflow/server/common"
+	"github.com/deepflowio/deepflow/server/controller/alert"
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/config"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
@@ -36,6 +37,7 @@ import (
 	"github.com/deepflowio/deepflow/server/controller/grpc"
 	"github.com/deepflowio/deepflow/server/controller/http"
 	"github.com/deepflowio/deepflow/server/controller/http/router"
+	"github.com/deepflowio/deepflow/server/controller/http/service/rebalance"
 	"github.com/deepflowio/deepflow/server/controller/manager"
 	"github.com/deepflowio/deepflow/server/controller/monitor"
 	"github.com/deepflowio/deepflow/server/controller/prometheus"
@@ -50,6 +52,7 @@ import (
 	_ "github.com/deepflowio/deepflow/server/controller/trisolaris/services/grpc/debug"
 	_ "github.com/deepflowio/deepflow/server/controller/trisolaris/services/grpc/healthcheck"
 	_ "github.com/deepflowio/deepflow/server/controller/trisolaris/services/http/cache"
+	_ "github.com/deepflowio/deepflow/server/controller/trisolaris/services/http/policy"
 	_ "github.com/deepflowio/deepflow/server/controller/trisolaris/services/http/upgrade"
 )
 
@@ -157,6 +160,8 @@ func Start(ctx context.Context, configPath, serverLogFile string, shared *server
 	go checkAndStartAllRegionMasterFunctions()
 
 	router.SetInitStageForHealthChecker("Master function init")
+	rebalance.SetResourceEventQueue(shared.ResourceEventQueue)
+	alert.SetAlarmEventQueue(shared.AlarmEventQueue)
 	controllerCheck := monitor.NewControllerCheck(cfg, ctx)
 	analyzerCheck := monitor.NewAnalyzerCheck(cfg, ctx)
 	go checkAndStartMasterFunctions(cfg, ctx, controllerCheck, analyzerCheck)
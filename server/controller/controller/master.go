@@ -21,15 +21,18 @@ import (
 	"os"
 	"time"
 
+	"github.com/deepflowio/deepflow/server/controller/alert"
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/config"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql/migrator"
 	"github.com/deepflowio/deepflow/server/controller/election"
 	"github.com/deepflowio/deepflow/server/controller/http"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
 	resoureservice "github.com/deepflowio/deepflow/server/controller/http/service/resource"
 	"github.com/deepflowio/deepflow/server/controller/monitor"
 	"github.com/deepflowio/deepflow/server/controller/monitor/license"
 	"github.com/deepflowio/deepflow/server/controller/monitor/vtap"
+	"github.com/deepflowio/deepflow/server/controller/operator"
 	"github.com/deepflowio/deepflow/server/controller/prometheus"
 	"github.com/deepflowio/deepflow/server/controller/recorder"
 	"github.com/deepflowio/deepflow/server/controller/tagrecorder"
@@ -86,6 +89,9 @@ func checkAndStartMasterFunctions(
 	// - prometheus encoder
 	// - prometheus app label layout updater
 	// - http resource refresh task manager
+	// - custom ip tag syncer
+	// - kubernetes operator
+	// - alert evaluator
 
 	// 从区域控制器无需判断是否为master controller
 	if !IsMasterRegion(cfg) {
@@ -97,6 +103,9 @@ func checkAndStartMasterFunctions(
 	vtapLicenseAllocation := license.NewVTapLicenseAllocation(cfg.MonitorCfg, ctx)
 	recorderResource := recorder.GetSingletonResource()
 	domainChecker := resoureservice.NewDomainCheck(ctx)
+	customIPTagSyncer := service.NewCustomIPTagSyncer(ctx)
+	crdOperator := operator.NewOperator(ctx, cfg)
+	alertEvaluator := alert.NewEvaluator(ctx, cfg)
 	prometheus := prometheus.GetSingleton()
 	tagRecorder := tagrecorder.GetSingleton()
 
@@ -150,6 +159,15 @@ func checkAndStartMasterFunctions(
 				// domain检查及自愈
 				domainChecker.Start()
 
+				// 自定义 IP 标签 http 数据源同步
+				customIPTagSyncer.Start()
+
+				// kubernetes CRD 同步
+				crdOperator.Start()
+
+				// 告警规则计算及通知
+				alertEvaluator.Start()
+
 				prometheus.Encoder.Start()
 				prometheus.APPLabelLayoutUpdater.Start()
 				prometheus.Clear.Start()
@@ -180,6 +198,10 @@ func checkAndStartMasterFunctions(
 
 				domainChecker.Stop()
 
+				customIPTagSyncer.Stop()
+				crdOperator.Stop()
+				alertEvaluator.Stop()
+
 				recorderResource.IDManager.Stop()
 
 				prometheus.Encoder.Stop()
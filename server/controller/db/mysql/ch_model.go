@@ -503,3 +503,8 @@ type ChNpbTunnel struct {
 	ID   int    `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
 	Name string `gorm:"column:name;type:varchar(256)" json:"NAME"`
 }
+
+type ChCustomIPTag struct {
+	Prefix string `gorm:"primaryKey;column:prefix;type:varchar(64);not null" json:"PREFIX"`
+	Tags   string `gorm:"column:tags;type:text" json:"TAGS"`
+}
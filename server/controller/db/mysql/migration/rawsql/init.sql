@@ -5,6 +5,29 @@ CREATE TABLE IF NOT EXISTS db_version (
 )ENGINE=innodb DEFAULT CHARSET=utf8;
 TRUNCATE TABLE db_version;
 
+CREATE TABLE IF NOT EXISTS org (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name                VARCHAR(64) NOT NULL,
+    max_vtap_num        INTEGER DEFAULT 0 COMMENT '0: unlimited',
+    max_vtap_group_num  INTEGER DEFAULT 0 COMMENT '0: unlimited',
+    max_domain_num      INTEGER DEFAULT 0 COMMENT '0: unlimited',
+    created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          DATETIME NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    lcuuid              CHAR(64) DEFAULT '',
+    UNIQUE INDEX name_index(name)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+TRUNCATE TABLE org;
+INSERT INTO org (id, name, lcuuid) VALUES (1, 'default', 'ffffffff-ffff-ffff-ffff-ffffffffffff');
+
+CREATE TABLE IF NOT EXISTS controller_election (
+    name                VARCHAR(64) NOT NULL PRIMARY KEY,
+    holder              VARCHAR(256) NOT NULL DEFAULT '' COMMENT 'node_name/node_ip/pod_name/pod_ip',
+    fencing_token       BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'increase by 1 when leader changes',
+    lease_duration      INTEGER NOT NULL DEFAULT 15 COMMENT 'unit: s',
+    acquire_time        DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    renew_time          DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)
+)ENGINE=innodb DEFAULT CHARSET=utf8;
+
 CREATE TABLE IF NOT EXISTS plugin (
     id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
     name                VARCHAR(256) NOT NULL,
@@ -456,6 +479,7 @@ CREATE TABLE IF NOT EXISTS domain (
     enabled             INTEGER NOT NULL DEFAULT '1' COMMENT '0.false 1.true',
     state               INTEGER NOT NULL DEFAULT '1' COMMENT '1.normal 2.deleting 3.exception',
     controller_ip       CHAR(64),
+    org_id              INTEGER NOT NULL DEFAULT 1,
     lcuuid              CHAR(64) DEFAULT '',
     synced_at           DATETIME DEFAULT NULL,
     created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -852,6 +876,9 @@ CREATE TABLE IF NOT EXISTS pod_group (
     type                INTEGER DEFAULT NULL COMMENT '1: Deployment 2: StatefulSet 3: ReplicationController',
     pod_num             INTEGER DEFAULT 1,
     label               TEXT COMMENT 'separated by ,',
+    images              TEXT COMMENT 'container images, separated by ,',
+    template_hash       CHAR(64) DEFAULT '',
+    rollout_state       INTEGER DEFAULT 0 COMMENT '0: complete 1: progressing',
     pod_namespace_id    INTEGER DEFAULT NULL,
     pod_cluster_id      INTEGER DEFAULT NULL,
     az                  CHAR(64) DEFAULT '',
@@ -1039,6 +1066,7 @@ CREATE TABLE IF NOT EXISTS vtap (
     tap_mode                INTEGER,
     expected_revision       TEXT,
     upgrade_package         TEXT,
+    org_id                  INTEGER NOT NULL DEFAULT 1,
     lcuuid                  CHAR(64)
 ) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
 TRUNCATE TABLE vtap;
@@ -1049,7 +1077,8 @@ CREATE TABLE IF NOT EXISTS vtap_group (
     created_at              DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at              DATETIME NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     lcuuid                  CHAR(64),
-    short_uuid              CHAR(32)
+    short_uuid              CHAR(32),
+    org_id                  INTEGER NOT NULL DEFAULT 1
 ) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
 TRUNCATE TABLE vtap_group;
 
@@ -1119,6 +1148,7 @@ CREATE TABLE IF NOT EXISTS npb_policy (
     state                  INTEGER DEFAULT 1 COMMENT '0-disable; 1-enable',
     business_id            INTEGER NOT NULL,
     direction              TINYINT(1) DEFAULT 1 COMMENT '1-all; 2-forward; 3-backward;',
+    tap_side               TINYINT(1) DEFAULT 1 COMMENT '1-src; 2-dst; 3-both',
     vni                    INTEGER,
     npb_tunnel_id          INTEGER,
     distribute             TINYINT(1) DEFAULT 1 COMMENT '0-drop, 1-distribute',
@@ -1138,6 +1168,7 @@ CREATE TABLE IF NOT EXISTS pcap_policy (
     state                  INTEGER DEFAULT 1 COMMENT '0-disable; 1-enable',
     business_id            INTEGER NOT NULL,
     acl_id                 INTEGER,
+    tap_side               TINYINT(1) DEFAULT 1 COMMENT '1-src; 2-dst; 3-both',
     vtap_ids               TEXT COMMENT 'separated by ,',
     payload_slice          INTEGER,
     policy_acl_group_id    INTEGER,
@@ -1673,6 +1704,70 @@ CREATE TABLE IF NOT EXISTS npb_tunnel (
 ) ENGINE=innodb DEFAULT CHARSET=utf8 AUTO_INCREMENT=1;
 TRUNCATE TABLE npb_tunnel;
 
+CREATE TABLE IF NOT EXISTS custom_ip_tag_set (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name                VARCHAR(256) NOT NULL,
+    source_type         TINYINT(1) DEFAULT 0 COMMENT '0: upload 1: http',
+    source_url          TEXT,
+    sync_interval       INTEGER DEFAULT 3600 COMMENT 'unit: s, only for http source',
+    tag_keys            TEXT COMMENT 'separated by ,',
+    synced_at           DATETIME DEFAULT NULL,
+    sync_error          TEXT,
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    lcuuid              CHAR(64)
+) ENGINE=innodb DEFAULT CHARSET=utf8 AUTO_INCREMENT=1;
+TRUNCATE TABLE custom_ip_tag_set;
+
+CREATE TABLE IF NOT EXISTS custom_ip_tag (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    tag_set_id          INTEGER NOT NULL,
+    l3_epc_id           INTEGER DEFAULT 0 COMMENT '0 means all vpcs',
+    cidr                VARCHAR(64) NOT NULL,
+    tags                TEXT COMMENT 'json map of tag key to value',
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX tag_set_id_index(tag_set_id)
+) ENGINE=innodb DEFAULT CHARSET=utf8 AUTO_INCREMENT=1;
+TRUNCATE TABLE custom_ip_tag;
+
+CREATE TABLE IF NOT EXISTS alert_rule (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name                VARCHAR(256) NOT NULL,
+    description         TEXT,
+    enabled             TINYINT(1) DEFAULT 1 COMMENT '0: disabled 1: enabled',
+    level               TINYINT(1) DEFAULT 1 COMMENT '0: low 1: medium 2: high',
+    db                  VARCHAR(64) NOT NULL,
+    query_sql           TEXT NOT NULL COMMENT 'deepflow sql, support $__timeFilter, $__from and $__to',
+    value_column        VARCHAR(256) DEFAULT 'value',
+    value_unit          VARCHAR(64) DEFAULT '',
+    comparator          VARCHAR(8) DEFAULT '>' COMMENT '>, >=, <, <=, ==, !=',
+    threshold_critical  DOUBLE DEFAULT NULL,
+    threshold_error     DOUBLE DEFAULT NULL,
+    threshold_warning   DOUBLE DEFAULT NULL,
+    eval_interval       INTEGER DEFAULT 60 COMMENT 'unit: s',
+    eval_range          INTEGER DEFAULT 300 COMMENT 'unit: s',
+    for_duration        INTEGER DEFAULT 0 COMMENT 'unit: s',
+    channel_lcuuids     TEXT COMMENT 'separated by ,',
+    last_eval_at        DATETIME DEFAULT NULL,
+    last_eval_error     TEXT,
+    eval_state          TEXT COMMENT 'json, firing and pending series',
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    lcuuid              CHAR(64)
+) ENGINE=innodb DEFAULT CHARSET=utf8 AUTO_INCREMENT=1;
+TRUNCATE TABLE alert_rule;
+
+CREATE TABLE IF NOT EXISTS alert_channel (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name                VARCHAR(256) NOT NULL,
+    type                VARCHAR(32) NOT NULL COMMENT 'email, webhook, slack, pagerduty',
+    config              TEXT COMMENT 'json',
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    lcuuid              CHAR(64)
+) ENGINE=innodb DEFAULT CHARSET=utf8 AUTO_INCREMENT=1;
+TRUNCATE TABLE alert_channel;
+
 CREATE TABLE IF NOT EXISTS tap_type (
     id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
     name                CHAR(64) NOT NULL,
@@ -1885,6 +1980,19 @@ CREATE TABLE IF NOT EXISTS analyzer (
 )ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
 TRUNCATE TABLE analyzer;
 
+CREATE TABLE IF NOT EXISTS analyzer_rebalance_plan (
+    id                      INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    state                   INTEGER NOT NULL DEFAULT 0 COMMENT '0.pending 1.applied 2.discarded 3.expired',
+    source                  VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'manual, schedule',
+    total_switch_vtap_num   INTEGER NOT NULL DEFAULT 0,
+    moves                   MEDIUMTEXT COMMENT 'json of vtap moves',
+    details                 MEDIUMTEXT COMMENT 'json of analyzer loads',
+    created_at              DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at              DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    lcuuid                  CHAR(64)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+TRUNCATE TABLE analyzer_rebalance_plan;
+
 CREATE TABLE IF NOT EXISTS link (
     id                      INTEGER NOT NULL auto_increment PRIMARY KEY,
     name                    CHAR(64),
@@ -2698,3 +2806,10 @@ CREATE TABLE IF NOT EXISTS ch_npb_tunnel (
     `updated_at`      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 )ENGINE=innodb DEFAULT CHARSET=utf8;
 TRUNCATE TABLE ch_npb_tunnel;
+
+CREATE TABLE IF NOT EXISTS ch_custom_ip_tag (
+    `prefix`          VARCHAR(64) NOT NULL PRIMARY KEY,
+    `tags`            TEXT,
+    `updated_at`      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+)ENGINE=innodb DEFAULT CHARSET=utf8;
+TRUNCATE TABLE ch_custom_ip_tag;
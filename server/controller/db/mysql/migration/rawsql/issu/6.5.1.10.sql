@@ -0,0 +1,8 @@
+-- modify start, add upgrade sql
+ALTER TABLE pod_group ADD COLUMN images TEXT COMMENT 'container images, separated by ,' AFTER label;
+ALTER TABLE pod_group ADD COLUMN template_hash CHAR(64) DEFAULT '' AFTER images;
+ALTER TABLE pod_group ADD COLUMN rollout_state INTEGER DEFAULT 0 COMMENT '0: complete 1: progressing' AFTER template_hash;
+
+-- update db_version to latest, remember update DB_VERSION_EXPECTED in migration/version.go
+UPDATE db_version SET version='6.5.1.10';
+-- modify end
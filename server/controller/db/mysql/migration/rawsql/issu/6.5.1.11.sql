@@ -0,0 +1,34 @@
+-- modify start, add upgrade sql
+CREATE TABLE IF NOT EXISTS custom_ip_tag_set (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name                VARCHAR(256) NOT NULL,
+    source_type         TINYINT(1) DEFAULT 0 COMMENT '0: upload 1: http',
+    source_url          TEXT,
+    sync_interval       INTEGER DEFAULT 3600 COMMENT 'unit: s, only for http source',
+    tag_keys            TEXT COMMENT 'separated by ,',
+    synced_at           DATETIME DEFAULT NULL,
+    sync_error          TEXT,
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    lcuuid              CHAR(64)
+) ENGINE=innodb DEFAULT CHARSET=utf8 AUTO_INCREMENT=1;
+
+CREATE TABLE IF NOT EXISTS custom_ip_tag (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    tag_set_id          INTEGER NOT NULL,
+    l3_epc_id           INTEGER DEFAULT 0 COMMENT '0 means all vpcs',
+    cidr                VARCHAR(64) NOT NULL,
+    tags                TEXT COMMENT 'json map of tag key to value',
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX tag_set_id_index(tag_set_id)
+) ENGINE=innodb DEFAULT CHARSET=utf8 AUTO_INCREMENT=1;
+
+CREATE TABLE IF NOT EXISTS ch_custom_ip_tag (
+    `prefix`          VARCHAR(64) NOT NULL PRIMARY KEY,
+    `tags`            TEXT,
+    `updated_at`      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+)ENGINE=innodb DEFAULT CHARSET=utf8;
+
+-- update db_version to latest, remember update DB_VERSION_EXPECTED in migration/version.go
+UPDATE db_version SET version='6.5.1.11';
+-- modify end
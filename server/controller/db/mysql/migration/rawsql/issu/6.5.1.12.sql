@@ -0,0 +1,40 @@
+-- modify start, add upgrade sql
+CREATE TABLE IF NOT EXISTS alert_rule (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name                VARCHAR(256) NOT NULL,
+    description         TEXT,
+    enabled             TINYINT(1) DEFAULT 1 COMMENT '0: disabled 1: enabled',
+    level               TINYINT(1) DEFAULT 1 COMMENT '0: low 1: medium 2: high',
+    db                  VARCHAR(64) NOT NULL,
+    query_sql           TEXT NOT NULL COMMENT 'deepflow sql, support $__timeFilter, $__from and $__to',
+    value_column        VARCHAR(256) DEFAULT 'value',
+    value_unit          VARCHAR(64) DEFAULT '',
+    comparator          VARCHAR(8) DEFAULT '>' COMMENT '>, >=, <, <=, ==, !=',
+    threshold_critical  DOUBLE DEFAULT NULL,
+    threshold_error     DOUBLE DEFAULT NULL,
+    threshold_warning   DOUBLE DEFAULT NULL,
+    eval_interval       INTEGER DEFAULT 60 COMMENT 'unit: s',
+    eval_range          INTEGER DEFAULT 300 COMMENT 'unit: s',
+    for_duration        INTEGER DEFAULT 0 COMMENT 'unit: s',
+    channel_lcuuids     TEXT COMMENT 'separated by ,',
+    last_eval_at        DATETIME DEFAULT NULL,
+    last_eval_error     TEXT,
+    eval_state          TEXT COMMENT 'json, firing and pending series',
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    lcuuid              CHAR(64)
+) ENGINE=innodb DEFAULT CHARSET=utf8 AUTO_INCREMENT=1;
+
+CREATE TABLE IF NOT EXISTS alert_channel (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name                VARCHAR(256) NOT NULL,
+    type                VARCHAR(32) NOT NULL COMMENT 'email, webhook, slack, pagerduty',
+    config              TEXT COMMENT 'json',
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    lcuuid              CHAR(64)
+) ENGINE=innodb DEFAULT CHARSET=utf8 AUTO_INCREMENT=1;
+
+-- update db_version to latest, remember update DB_VERSION_EXPECTED in migration/version.go
+UPDATE db_version SET version='6.5.1.12';
+-- modify end
@@ -0,0 +1,7 @@
+-- modify start, add upgrade sql
+ALTER TABLE npb_policy ADD COLUMN tap_side TINYINT(1) DEFAULT 1 COMMENT '1-src; 2-dst; 3-both' AFTER direction;
+ALTER TABLE pcap_policy ADD COLUMN tap_side TINYINT(1) DEFAULT 1 COMMENT '1-src; 2-dst; 3-both' AFTER acl_id;
+
+-- update db_version to latest, remember update DB_VERSION_EXPECTED in migration/version.go
+UPDATE db_version SET version='6.5.1.13';
+-- modify end
@@ -0,0 +1,21 @@
+-- modify start, add upgrade sql
+CREATE TABLE IF NOT EXISTS org (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name                VARCHAR(64) NOT NULL,
+    max_vtap_num        INTEGER DEFAULT 0 COMMENT '0: unlimited',
+    max_vtap_group_num  INTEGER DEFAULT 0 COMMENT '0: unlimited',
+    max_domain_num      INTEGER DEFAULT 0 COMMENT '0: unlimited',
+    created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          DATETIME NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    lcuuid              CHAR(64) DEFAULT '',
+    UNIQUE INDEX name_index(name)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+INSERT IGNORE INTO org (id, name, lcuuid) VALUES (1, 'default', 'ffffffff-ffff-ffff-ffff-ffffffffffff');
+
+ALTER TABLE domain ADD COLUMN org_id INTEGER NOT NULL DEFAULT 1 AFTER controller_ip;
+ALTER TABLE vtap_group ADD COLUMN org_id INTEGER NOT NULL DEFAULT 1 AFTER short_uuid;
+ALTER TABLE vtap ADD COLUMN org_id INTEGER NOT NULL DEFAULT 1 AFTER upgrade_package;
+
+-- update db_version to latest, remember update DB_VERSION_EXPECTED in migration/version.go
+UPDATE db_version SET version='6.5.1.7';
+-- modify end
@@ -0,0 +1,13 @@
+-- modify start, add upgrade sql
+CREATE TABLE IF NOT EXISTS controller_election (
+    name                VARCHAR(64) NOT NULL PRIMARY KEY,
+    holder              VARCHAR(256) NOT NULL DEFAULT '' COMMENT 'node_name/node_ip/pod_name/pod_ip',
+    fencing_token       BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'increase by 1 when leader changes',
+    lease_duration      INTEGER NOT NULL DEFAULT 15 COMMENT 'unit: s',
+    acquire_time        DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    renew_time          DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)
+)ENGINE=innodb DEFAULT CHARSET=utf8;
+
+-- update db_version to latest, remember update DB_VERSION_EXPECTED in migration/version.go
+UPDATE db_version SET version='6.5.1.8';
+-- modify end
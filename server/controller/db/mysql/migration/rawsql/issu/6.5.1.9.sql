@@ -0,0 +1,16 @@
+-- modify start, add upgrade sql
+CREATE TABLE IF NOT EXISTS analyzer_rebalance_plan (
+    id                      INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    state                   INTEGER NOT NULL DEFAULT 0 COMMENT '0.pending 1.applied 2.discarded 3.expired',
+    source                  VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'manual, schedule',
+    total_switch_vtap_num   INTEGER NOT NULL DEFAULT 0,
+    moves                   MEDIUMTEXT COMMENT 'json of vtap moves',
+    details                 MEDIUMTEXT COMMENT 'json of analyzer loads',
+    created_at              DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at              DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    lcuuid                  CHAR(64)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+
+-- update db_version to latest, remember update DB_VERSION_EXPECTED in migration/version.go
+UPDATE db_version SET version='6.5.1.9';
+-- modify end
@@ -159,6 +159,22 @@ func (AZAnalyzerConnection) TableName() string {
 	return "az_analyzer_connection"
 }
 
+type AnalyzerRebalancePlan struct {
+	ID                 int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
+	State              int       `gorm:"column:state;type:int;not null;default:0" json:"STATE"`            // 0.pending 1.applied 2.discarded 3.expired
+	Source             string    `gorm:"column:source;type:varchar(64);not null;default:''" json:"SOURCE"` // manual, schedule
+	TotalSwitchVTapNum int       `gorm:"column:total_switch_vtap_num;type:int;not null;default:0" json:"TOTAL_SWITCH_VTAP_NUM"`
+	Moves              string    `gorm:"column:moves;type:mediumtext;default:null" json:"MOVES"`     // json of vtap moves
+	Details            string    `gorm:"column:details;type:mediumtext;default:null" json:"DETAILS"` // json of analyzer loads
+	CreatedAt          time.Time `gorm:"column:created_at;type:datetime;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
+	UpdatedAt          time.Time `gorm:"column:updated_at;type:datetime;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
+	Lcuuid             string    `gorm:"column:lcuuid;type:char(64);default:null" json:"LCUUID"`
+}
+
+func (AnalyzerRebalancePlan) TableName() string {
+	return "analyzer_rebalance_plan"
+}
+
 type VTap struct {
 	ID                 int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
 	Name               string    `gorm:"column:name;type:varchar(256);not null" json:"NAME"`
@@ -196,6 +212,7 @@ type VTap struct {
 	TapMode            int       `gorm:"column:tap_mode;type:int;default:null" json:"TAP_MODE"`
 	ExpectedRevision   string    `gorm:"column:expected_revision;type:text;default null" json:"EXPECTED_REVISION"`
 	UpgradePackage     string    `gorm:"column:upgrade_package;type:text;default null" json:"UPGRADE_PACKAGE"`
+	OrgID              int       `gorm:"column:org_id;type:int;not null;default:1" json:"ORG_ID"`
 	Lcuuid             string    `gorm:"column:lcuuid;type:char(64);not null" json:"LCUUID"`
 }
 
@@ -210,12 +227,28 @@ type VTapGroup struct {
 	UpdatedAt time.Time `gorm:"column:updated_at;type:datetime;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
 	Lcuuid    string    `gorm:"column:lcuuid;type:char(64);not null" json:"LCUUID"`
 	ShortUUID string    `gorm:"column:short_uuid;type:char(32);default:null" json:"SHORT_UUID"`
+	OrgID     int       `gorm:"column:org_id;type:int;not null;default:1" json:"ORG_ID"`
 }
 
 func (VTapGroup) TableName() string {
 	return "vtap_group"
 }
 
+type Org struct {
+	ID              int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
+	Name            string    `gorm:"column:name;type:varchar(64);not null" json:"NAME"`
+	MaxVTapNum      int       `gorm:"column:max_vtap_num;type:int;default:0" json:"MAX_VTAP_NUM"`             // 0: unlimited
+	MaxVTapGroupNum int       `gorm:"column:max_vtap_group_num;type:int;default:0" json:"MAX_VTAP_GROUP_NUM"` // 0: unlimited
+	MaxDomainNum    int       `gorm:"column:max_domain_num;type:int;default:0" json:"MAX_DOMAIN_NUM"`         // 0: unlimited
+	CreatedAt       time.Time `gorm:"column:created_at;type:datetime;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;type:datetime;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
+	Lcuuid          string    `gorm:"column:lcuuid;type:char(64);default:''" json:"LCUUID"`
+}
+
+func (Org) TableName() string {
+	return "org"
+}
+
 type DataSource struct {
 	ID                        int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
 	DisplayName               string    `gorm:"column:display_name;type:char(64);default:''" json:"DISPLAY_NAME"`
@@ -437,7 +470,8 @@ type NpbPolicy struct {
 	Name             string    `gorm:"column:name;type:char(64);default:null" json:"NAME"`
 	State            int       `gorm:"column:state;type:int;default:null;default:1" json:"STATE"` // 0-disable; 1-enable
 	BusinessID       int       `gorm:"column:business_id;type:int;not null" json:"BUSINESS_ID"`
-	Direction        int       `gorm:"column:direction;type:int;default:1" json:"DIRECTION"` // 1-two way; 2-server to client
+	Direction        int       `gorm:"column:direction;type:int;default:1" json:"DIRECTION"`      // 1-two way; 2-server to client
+	TapSide          int       `gorm:"column:tap_side;type:tinyint(1);default:1" json:"TAP_SIDE"` // 1-src; 2-dst; 3-both
 	Vni              int       `gorm:"column:vni;type:int;default:null" json:"VNI"`
 	NpbTunnelID      int       `gorm:"column:npb_tunnel_id;type:int;default:null" json:"NPB_TUNNEL_ID"`
 	Distribute       int       `gorm:"column:distribute;type:int;default:null" json:"distribute"` // 0-drop, 1-distribute
@@ -470,6 +504,85 @@ func (NpbTunnel) TableName() string {
 	return "npb_tunnel"
 }
 
+// CustomIPTagSet 自定义 IP 业务标签集合
+type CustomIPTagSet struct {
+	ID           int        `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
+	Name         string     `gorm:"column:name;type:varchar(256);not null" json:"NAME"`
+	SourceType   int        `gorm:"column:source_type;type:tinyint(1);default:0" json:"SOURCE_TYPE"` // 0: upload, 1: http
+	SourceURL    string     `gorm:"column:source_url;type:text;default:null" json:"SOURCE_URL"`
+	SyncInterval int        `gorm:"column:sync_interval;type:int;default:3600" json:"SYNC_INTERVAL"` // unit: s
+	TagKeys      string     `gorm:"column:tag_keys;type:text;default:null" json:"TAG_KEYS"`          // separated by ,
+	SyncedAt     *time.Time `gorm:"column:synced_at;type:datetime;default:null" json:"SYNCED_AT"`
+	SyncError    string     `gorm:"column:sync_error;type:text;default:null" json:"SYNC_ERROR"`
+	CreatedAt    time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
+	Lcuuid       string     `gorm:"column:lcuuid;type:char(64);default:null" json:"LCUUID"`
+}
+
+func (CustomIPTagSet) TableName() string {
+	return "custom_ip_tag_set"
+}
+
+// CustomIPTag 自定义 IP 业务标签条目，以 IP/CIDR 或 VPC + CIDR 为键
+type CustomIPTag struct {
+	ID        int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
+	TagSetID  int       `gorm:"column:tag_set_id;type:int;not null" json:"TAG_SET_ID"`
+	L3EPCID   int       `gorm:"column:l3_epc_id;type:int;default:0" json:"L3_EPC_ID"` // 0 means all vpcs
+	CIDR      string    `gorm:"column:cidr;type:varchar(64);not null" json:"CIDR"`
+	Tags      string    `gorm:"column:tags;type:text;default:null" json:"TAGS"` // json map of tag key to value
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
+}
+
+func (CustomIPTag) TableName() string {
+	return "custom_ip_tag"
+}
+
+// AlertRule 基于 DeepFlow SQL 的告警规则
+type AlertRule struct {
+	ID                int        `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
+	Name              string     `gorm:"column:name;type:varchar(256);not null" json:"NAME"`
+	Description       string     `gorm:"column:description;type:text;default:null" json:"DESCRIPTION"`
+	Enabled           int        `gorm:"column:enabled;type:tinyint(1)" json:"ENABLED"` // 0: disabled, 1: enabled
+	Level             int        `gorm:"column:level;type:tinyint(1)" json:"LEVEL"`     // 0: low, 1: medium, 2: high
+	DB                string     `gorm:"column:db;type:varchar(64);not null" json:"DB"`
+	QuerySQL          string     `gorm:"column:query_sql;type:text;not null" json:"QUERY_SQL"`
+	ValueColumn       string     `gorm:"column:value_column;type:varchar(256);default:'value'" json:"VALUE_COLUMN"`
+	ValueUnit         string     `gorm:"column:value_unit;type:varchar(64);default:''" json:"VALUE_UNIT"`
+	Comparator        string     `gorm:"column:comparator;type:varchar(8);default:'>'" json:"COMPARATOR"`
+	ThresholdCritical *float64   `gorm:"column:threshold_critical;type:double;default:null" json:"THRESHOLD_CRITICAL"`
+	ThresholdError    *float64   `gorm:"column:threshold_error;type:double;default:null" json:"THRESHOLD_ERROR"`
+	ThresholdWarning  *float64   `gorm:"column:threshold_warning;type:double;default:null" json:"THRESHOLD_WARNING"`
+	EvalInterval      int        `gorm:"column:eval_interval;type:int;default:60" json:"EVAL_INTERVAL"`        // unit: s
+	EvalRange         int        `gorm:"column:eval_range;type:int;default:300" json:"EVAL_RANGE"`             // unit: s
+	ForDuration       int        `gorm:"column:for_duration;type:int;default:0" json:"FOR_DURATION"`           // unit: s
+	ChannelLcuuids    string     `gorm:"column:channel_lcuuids;type:text;default:null" json:"CHANNEL_LCUUIDS"` // separated by ,
+	LastEvalAt        *time.Time `gorm:"column:last_eval_at;type:datetime;default:null" json:"LAST_EVAL_AT"`
+	LastEvalError     string     `gorm:"column:last_eval_error;type:text;default:null" json:"LAST_EVAL_ERROR"`
+	EvalState         string     `gorm:"column:eval_state;type:text;default:null" json:"EVAL_STATE"` // json, firing and pending series
+	CreatedAt         time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
+	UpdatedAt         time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
+	Lcuuid            string     `gorm:"column:lcuuid;type:char(64);default:null" json:"LCUUID"`
+}
+
+func (AlertRule) TableName() string {
+	return "alert_rule"
+}
+
+// AlertChannel 告警通知渠道
+type AlertChannel struct {
+	ID        int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
+	Name      string    `gorm:"column:name;type:varchar(256);not null" json:"NAME"`
+	Type      string    `gorm:"column:type;type:varchar(32);not null" json:"TYPE"`  // email, webhook, slack, pagerduty
+	Config    string    `gorm:"column:config;type:text;default:null" json:"CONFIG"` // json
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
+	Lcuuid    string    `gorm:"column:lcuuid;type:char(64);default:null" json:"LCUUID"`
+}
+
+func (AlertChannel) TableName() string {
+	return "alert_channel"
+}
+
 // PcapPolicy [...]
 type PcapPolicy struct {
 	ID               int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
@@ -477,7 +590,8 @@ type PcapPolicy struct {
 	State            int       `gorm:"column:state;type:int;default:null;default:1" json:"STATE"` // 0-disable; 1-enable
 	BusinessID       int       `gorm:"column:business_id;type:int;not null" json:"BUSINESS_ID"`
 	ACLID            int       `gorm:"column:acl_id;type:int;default:null" json:"ACL_ID"`
-	VtapIDs          string    `gorm:"column:vtap_ids;type:text;default:null" json:"VTAP_IDS"` // separated by ,
+	TapSide          int       `gorm:"column:tap_side;type:tinyint(1);default:1" json:"TAP_SIDE"` // 1-src; 2-dst; 3-both
+	VtapIDs          string    `gorm:"column:vtap_ids;type:text;default:null" json:"VTAP_IDS"`    // separated by ,
 	PayloadSlice     *int      `gorm:"column:payload_slice;type:int;default:null" json:"PAYLOAD_SLICE"`
 	PolicyACLGroupID int       `gorm:"column:policy_acl_group_id;type:int;default:null" json:"POLICY_ACL_GROUP_ID"`
 	UserID           int       `gorm:"column:user_id;type:int;default:null" json:"USER_ID"`
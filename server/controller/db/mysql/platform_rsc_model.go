@@ -103,6 +103,7 @@ type Domain struct {
 	Enabled      int        `gorm:"column:enabled;type:int;not null;default:1" json:"ENABLED" mapstructure:"ENABLED"` // 0.false 1.true
 	State        int        `gorm:"column:state;type:int;not null;default:1" json:"STATE" mapstructure:"STATE"`       // 1.normal 2.deleting 3.exception
 	ControllerIP string     `gorm:"column:controller_ip;type:char(64)" json:"CONTROLLER_IP" mapstructure:"CONTROLLER_IP"`
+	OrgID        int        `gorm:"column:org_id;type:int;not null;default:1" json:"ORG_ID" mapstructure:"ORG_ID"`
 }
 
 // TODO 最终可以与cloud模块命名统一，Domain -> DomainLcuuid
@@ -757,7 +758,10 @@ type PodGroup struct {
 	Alias          string `gorm:"column:alias;type:char(64);default:''" json:"ALIAS" mapstructure:"ALIAS"`
 	Type           int    `gorm:"column:type;type:int;default:null" json:"TYPE" mapstructure:"TYPE"` // 1: Deployment 2: StatefulSet 3: ReplicationController
 	PodNum         int    `gorm:"column:pod_num;type:int;default:1" json:"POD_NUM" mapstructure:"POD_NUM"`
-	Label          string `gorm:"column:label;type:text;default:''" json:"LABEL" mapstructure:"LABEL"` // separated by ,
+	Label          string `gorm:"column:label;type:text;default:''" json:"LABEL" mapstructure:"LABEL"`    // separated by ,
+	Images         string `gorm:"column:images;type:text;default:''" json:"IMAGES" mapstructure:"IMAGES"` // separated by ,
+	TemplateHash   string `gorm:"column:template_hash;type:char(64);default:''" json:"TEMPLATE_HASH" mapstructure:"TEMPLATE_HASH"`
+	RolloutState   int    `gorm:"column:rollout_state;type:int;default:0" json:"ROLLOUT_STATE" mapstructure:"ROLLOUT_STATE"` // 0: complete 1: progressing 2: progressing and rollout started event produced
 	PodNamespaceID int    `gorm:"column:pod_namespace_id;type:int;default:null" json:"POD_NAMESPACE_ID" mapstructure:"POD_NAMESPACE_ID"`
 	PodClusterID   int    `gorm:"column:pod_cluster_id;type:int;default:null" json:"POD_CLUSTER_ID" mapstructure:"POD_CLUSTER_ID"`
 	AZ             string `gorm:"column:az;type:char(64);default:''" json:"AZ" mapstructure:"AZ"`
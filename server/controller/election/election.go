@@ -42,6 +42,9 @@ import (
 
 const (
 	ID_ITEM_NUM = 4
+
+	ELECTION_BACKEND_KUBERNETES = "kubernetes"
+	ELECTION_BACKEND_MYSQL      = "mysql"
 )
 
 type LeaderData struct {
@@ -159,11 +162,20 @@ func checkLeaderValid(ctx context.Context, lock *resourcelock.LeaseLock) {
 }
 
 func Start(ctx context.Context, cfg *config.ControllerConfig) {
+	id := getID()
+	log.Infof("election id is %s, backend is %s", id, cfg.ElectionBackend)
+	if cfg.ElectionBackend == ELECTION_BACKEND_MYSQL {
+		// for deployments outside kubernetes, e.g. VM or bare metal, use the mysql database as the lease lock
+		startMySQLElection(ctx, cfg, id)
+		return
+	}
+	startKubernetesElection(ctx, cfg, id)
+}
+
+func startKubernetesElection(ctx context.Context, cfg *config.ControllerConfig, id string) {
 	kubeconfig := cfg.Kubeconfig
 	electionName := cfg.ElectionName
 	electionNamespace := common.GetNameSpace()
-	id := getID()
-	log.Infof("election id is %s", id)
 	// leader election uses the Kubernetes API by writing to a
 	// lock object, which can be a LeaseLock object (preferred),
 	// a ConfigMap, or an Endpoints (deprecated) object.
@@ -0,0 +1,297 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/config"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlcommon "github.com/deepflowio/deepflow/server/controller/db/mysql/common"
+	"github.com/deepflowio/deepflow/server/controller/trisolaris/utils"
+)
+
+// same as controller/db/mysql/migration/rawsql/init.sql, election starts before mysql migration,
+// so the table has to be created here
+const createElectionTableSQL = `CREATE TABLE IF NOT EXISTS controller_election (
+    name                VARCHAR(64) NOT NULL PRIMARY KEY,
+    holder              VARCHAR(256) NOT NULL DEFAULT '' COMMENT 'node_name/node_ip/pod_name/pod_ip',
+    fencing_token       BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'increase by 1 when leader changes',
+    lease_duration      INTEGER NOT NULL DEFAULT 15 COMMENT 'unit: s',
+    acquire_time        DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    renew_time          DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)
+)ENGINE=innodb DEFAULT CHARSET=utf8`
+
+// time expressions of the lease SQL, all times are from the database to avoid clock skew between controllers
+type leaseDialect struct {
+	now     string
+	expired string
+}
+
+var mysqlLeaseDialect = leaseDialect{
+	now:     "NOW(3)",
+	expired: "renew_time < NOW(3) - INTERVAL lease_duration SECOND",
+}
+
+// a lease is acquired when it is held by myself or has expired.
+// single-table UPDATE of mysql assigns columns from left to right, so fencing_token is calculated with the old holder.
+func (d leaseDialect) acquireLeaseSQL() string {
+	return fmt.Sprintf(`UPDATE controller_election SET
+    fencing_token = CASE WHEN holder = ? THEN fencing_token ELSE fencing_token + 1 END,
+    acquire_time = CASE WHEN holder = ? THEN acquire_time ELSE %s END,
+    holder = ?,
+    lease_duration = ?,
+    renew_time = %s
+WHERE name = ? AND (holder = ? OR holder = '' OR %s)`, d.now, d.now, d.expired)
+}
+
+func (d leaseDialect) getRecordSQL() string {
+	return fmt.Sprintf("SELECT holder, fencing_token, %s AS expired FROM controller_election WHERE name = ?", d.expired)
+}
+
+// the lease is still held by the fencing token and has not expired
+func (d leaseDialect) checkFencingTokenSQL() string {
+	return fmt.Sprintf(
+		"SELECT COUNT(*) FROM controller_election WHERE name = ? AND holder = ? AND fencing_token = ? AND NOT (%s)", d.expired,
+	)
+}
+
+var (
+	errConnectMySQL = errors.New("connect mysql failed")
+	ErrStaleLeader  = errors.New("not the leader, or the leader lease has expired or been taken over")
+)
+
+const (
+	MYSQL_LEASE_DURATION = 15 * time.Second
+	MYSQL_RENEW_DEADLINE = 10 * time.Second
+	MYSQL_RETRY_PERIOD   = 2 * time.Second
+)
+
+type leaseRecord struct {
+	Holder       string
+	FencingToken uint64
+	Expired      bool
+}
+
+type mysqlElector struct {
+	db      *gorm.DB
+	dialect leaseDialect
+	// the lease is keyed by election-name in the mysql database of this deployment, which has no namespace,
+	// so deployments sharing one database must use different election-name
+	name string
+	id   string
+
+	isLeader      bool
+	lastRenewTime time.Time
+	// the fencing token of the current leader term, 0 means this controller is not the leader
+	token uint64
+}
+
+// the elector of mysql backend, only it has fencing tokens
+var fencingElector atomic.Value
+
+// CheckFencingToken is called by master-only tasks before writing, it checks the fencing token of this controller
+// against controller_election, and returns ErrStaleLeader when the lease has expired or been taken over by
+// another controller, so that a stale leader stops writing before it notices the leader change.
+// with the kubernetes backend, there is no fencing token and it always returns nil.
+func CheckFencingToken() error {
+	e, ok := fencingElector.Load().(*mysqlElector)
+	if !ok {
+		return nil
+	}
+	// followers have no fencing token, it is expected and checked by every master-only task, so no log
+	if atomic.LoadUint64(&e.token) == 0 {
+		return ErrStaleLeader
+	}
+	if err := e.checkFencingToken(); err != nil {
+		log.Warningf("check fencing token of %s failed, skip master-only task: %s", e.id, err.Error())
+		return err
+	}
+	return nil
+}
+
+func GetFencingToken() uint64 {
+	if e, ok := fencingElector.Load().(*mysqlElector); ok {
+		return atomic.LoadUint64(&e.token)
+	}
+	return 0
+}
+
+func newMySQLElector(cfg *config.ControllerConfig, id string) (*mysqlElector, error) {
+	db := mysql.GetConnectionWithoutDatabase(cfg.MySqlCfg)
+	if db == nil {
+		return nil, errConnectMySQL
+	}
+	if _, err := mysqlcommon.CreateDatabaseIfNotExists(db, cfg.MySqlCfg.Database); err != nil {
+		return nil, err
+	}
+	db = mysql.Gorm(cfg.MySqlCfg)
+	if db == nil {
+		return nil, errConnectMySQL
+	}
+	if err := db.Exec(createElectionTableSQL).Error; err != nil {
+		return nil, err
+	}
+	err := db.Exec(
+		"INSERT IGNORE INTO controller_election (name, lease_duration) VALUES (?, ?)",
+		cfg.ElectionName, int(MYSQL_LEASE_DURATION.Seconds()),
+	).Error
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlElector{db: db, dialect: mysqlLeaseDialect, name: cfg.ElectionName, id: id}, nil
+}
+
+func (e *mysqlElector) tryAcquireOrRenew() (bool, error) {
+	result := e.db.Exec(e.dialect.acquireLeaseSQL(), e.id, e.id, e.id, int(MYSQL_LEASE_DURATION.Seconds()), e.name, e.id)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (e *mysqlElector) getRecord() (*leaseRecord, error) {
+	var record leaseRecord
+	err := e.db.Raw(e.dialect.getRecordSQL(), e.name).Scan(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (e *mysqlElector) release() {
+	if !e.isLeader {
+		return
+	}
+	err := e.db.Exec(
+		"UPDATE controller_election SET holder = '' WHERE name = ? AND holder = ? AND fencing_token = ?",
+		e.name, e.id, atomic.LoadUint64(&e.token),
+	).Error
+	if err != nil {
+		log.Errorf("release leader lease failed: %s", err.Error())
+	}
+	e.stopLeading()
+}
+
+func (e *mysqlElector) checkFencingToken() error {
+	token := atomic.LoadUint64(&e.token)
+	if token == 0 {
+		return ErrStaleLeader
+	}
+	var count int64
+	if err := e.db.Raw(e.dialect.checkFencingTokenSQL(), e.name, e.id, token).Scan(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrStaleLeader
+	}
+	return nil
+}
+
+func (e *mysqlElector) startLeading(token uint64) {
+	e.isLeader = true
+	atomic.StoreUint64(&e.token, token)
+	log.Infof("%s is the leader, fencing token: %d", e.id, token)
+	leaderData.SetLeader(e.id)
+}
+
+func (e *mysqlElector) stopLeading() {
+	e.isLeader = false
+	atomic.StoreUint64(&e.token, 0)
+	log.Infof("leader lost: %s", e.id)
+}
+
+func (e *mysqlElector) observeLeader(record *leaseRecord) {
+	leader := record.Holder
+	if record.Expired {
+		leader = ""
+	}
+	if leader != leaderData.GetLeader() {
+		leaderData.SetLeader(leader)
+		log.Infof("new leader elected: %s", leader)
+	}
+}
+
+func (e *mysqlElector) runOnce() {
+	acquired, err := e.tryAcquireOrRenew()
+	if err != nil {
+		log.Errorf("acquire or renew leader lease failed: %s", err.Error())
+		// the lease may be taken by others after it expires, so step down before that
+		if e.isLeader && time.Since(e.lastRenewTime) > MYSQL_RENEW_DEADLINE {
+			e.stopLeading()
+			leaderData.SetLeader("")
+		}
+		return
+	}
+	if acquired {
+		e.lastRenewTime = time.Now()
+	} else if e.isLeader {
+		e.stopLeading()
+	}
+
+	record, err := e.getRecord()
+	if err != nil {
+		log.Errorf("get leader lease failed: %s", err.Error())
+		return
+	}
+	if acquired && !e.isLeader && record.Holder == e.id {
+		e.startLeading(record.FencingToken)
+		return
+	}
+	e.observeLeader(record)
+}
+
+func startMySQLElection(ctx context.Context, cfg *config.ControllerConfig, id string) {
+	var elector *mysqlElector
+	for {
+		var err error
+		elector, err = newMySQLElector(cfg, id)
+		if err == nil {
+			break
+		}
+		log.Errorf("failed to create mysql election: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(MYSQL_RETRY_PERIOD):
+		}
+	}
+
+	fencingElector.Store(elector)
+
+	wg := utils.GetWaitGroupInCtx(ctx)
+	wg.Add(1)
+	defer wg.Done()
+
+	ticker := time.NewTicker(MYSQL_RETRY_PERIOD)
+	defer ticker.Stop()
+	for {
+		elector.runOnce()
+		select {
+		case <-ctx.Done():
+			elector.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package election
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const sqliteNow = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+
+var sqliteLeaseDialect = leaseDialect{
+	now:     sqliteNow,
+	expired: "renew_time < strftime('%Y-%m-%d %H:%M:%f', 'now', '-' || lease_duration || ' seconds')",
+}
+
+func newTestElectors(t *testing.T) (*mysqlElector, *mysqlElector) {
+	db, err := gorm.Open(sqlite.Open("file:election?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqls := []string{
+		"DROP TABLE IF EXISTS controller_election",
+		`CREATE TABLE controller_election (
+			name           VARCHAR(64) NOT NULL PRIMARY KEY,
+			holder         VARCHAR(256) NOT NULL DEFAULT '',
+			fencing_token  BIGINT NOT NULL DEFAULT 0,
+			lease_duration INTEGER NOT NULL DEFAULT 15,
+			acquire_time   TEXT NOT NULL DEFAULT (` + sqliteNow + `),
+			renew_time     TEXT NOT NULL DEFAULT (` + sqliteNow + `)
+		)`,
+		"INSERT INTO controller_election (name) VALUES ('test')",
+	}
+	for _, sql := range sqls {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := &mysqlElector{db: db, dialect: sqliteLeaseDialect, name: "test", id: "controller-a"}
+	b := &mysqlElector{db: db, dialect: sqliteLeaseDialect, name: "test", id: "controller-b"}
+	return a, b
+}
+
+func expireLease(t *testing.T, e *mysqlElector) {
+	err := e.db.Exec(
+		"UPDATE controller_election SET renew_time = strftime('%Y-%m-%d %H:%M:%f', 'now', '-60 seconds') WHERE name = ?", e.name,
+	).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLElection(t *testing.T) {
+	a, b := newTestElectors(t)
+
+	// acquire
+	a.runOnce()
+	if !a.isLeader || a.token != 1 {
+		t.Fatalf("acquire: isLeader %v, token %d, want true, 1", a.isLeader, a.token)
+	}
+	if err := a.checkFencingToken(); err != nil {
+		t.Fatalf("acquire: check fencing token failed: %v", err)
+	}
+	b.runOnce()
+	if b.isLeader || leaderData.GetLeader() != a.id {
+		t.Fatalf("acquire: b isLeader %v, leader %s, want false, %s", b.isLeader, leaderData.GetLeader(), a.id)
+	}
+	if err := b.checkFencingToken(); err != ErrStaleLeader {
+		t.Fatalf("acquire: check fencing token of follower got %v, want %v", err, ErrStaleLeader)
+	}
+
+	// renew
+	a.runOnce()
+	if !a.isLeader || a.token != 1 {
+		t.Fatalf("renew: isLeader %v, token %d, want true, 1", a.isLeader, a.token)
+	}
+
+	// expire
+	expireLease(t, a)
+	if err := a.checkFencingToken(); err != ErrStaleLeader {
+		t.Fatalf("expire: check fencing token got %v, want %v", err, ErrStaleLeader)
+	}
+
+	// takeover
+	b.runOnce()
+	if !b.isLeader || b.token != 2 {
+		t.Fatalf("takeover: isLeader %v, token %d, want true, 2", b.isLeader, b.token)
+	}
+	if err := b.checkFencingToken(); err != nil {
+		t.Fatalf("takeover: check fencing token failed: %v", err)
+	}
+	// the stale leader is rejected before it notices the leader change
+	if !a.isLeader {
+		t.Fatal("takeover: a should not notice the leader change before next run")
+	}
+	if err := a.checkFencingToken(); err != ErrStaleLeader {
+		t.Fatalf("takeover: check fencing token of stale leader got %v, want %v", err, ErrStaleLeader)
+	}
+	a.runOnce()
+	if a.isLeader || a.token != 0 || leaderData.GetLeader() != b.id {
+		t.Fatalf("takeover: a isLeader %v, token %d, leader %s, want false, 0, %s",
+			a.isLeader, a.token, leaderData.GetLeader(), b.id)
+	}
+
+	// release
+	b.release()
+	a.runOnce()
+	if !a.isLeader || a.token != 3 {
+		t.Fatalf("release: isLeader %v, token %d, want true, 3", a.isLeader, a.token)
+	}
+}
+
+func TestCheckFencingTokenOfFollower(t *testing.T) {
+	// followers return without querying the database, db is nil here
+	fencingElector.Store(&mysqlElector{name: "test", id: "controller-c"})
+	if err := CheckFencingToken(); err != ErrStaleLeader {
+		t.Fatalf("check fencing token of follower got %v, want %v", err, ErrStaleLeader)
+	}
+}
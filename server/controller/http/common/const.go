@@ -31,3 +31,6 @@ const (
 	SERVICE_UNAVAILABLE             = "SERVICE_UNAVAILABLE"
 	K8S_SET_VTAP_FAIL               = "K8S_SET_VTAP_FAIL"
 )
+
+// 请求所属组织，未携带时为默认组织
+const HEADER_KEY_X_ORG_ID = "X-Org-Id"
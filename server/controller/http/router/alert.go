@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/deepflowio/deepflow/server/controller/config"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+type Alert struct {
+	cfg *config.ControllerConfig
+}
+
+func NewAlert(cfg *config.ControllerConfig) *Alert {
+	return &Alert{cfg: cfg}
+}
+
+func (a *Alert) RegisterTo(e *gin.Engine) {
+	e.GET("/v1/alert-rules/", getAlertRules)
+	e.POST("/v1/alert-rules/", createAlertRule)
+	e.PATCH("/v1/alert-rules/:lcuuid/", updateAlertRule)
+	e.DELETE("/v1/alert-rules/:lcuuid/", deleteAlertRule)
+
+	e.GET("/v1/alert-channels/", getAlertChannels)
+	e.POST("/v1/alert-channels/", createAlertChannel)
+	e.PATCH("/v1/alert-channels/:lcuuid/", updateAlertChannel)
+	e.DELETE("/v1/alert-channels/:lcuuid/", deleteAlertChannel)
+	e.POST("/v1/alert-channels/:lcuuid/test/", testAlertChannel(a.cfg))
+}
+
+func getAlertRules(c *gin.Context) {
+	data, err := service.GetAlertRules(getPolicyFilter(c, "enabled"))
+	JsonResponse(c, data, err)
+}
+
+func createAlertRule(c *gin.Context) {
+	var ruleCreate model.AlertRuleCreate
+	if err := c.ShouldBindBodyWith(&ruleCreate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.CreateAlertRule(ruleCreate)
+	JsonResponse(c, data, err)
+}
+
+func updateAlertRule(c *gin.Context) {
+	patchMap, ok := getPolicyPatchMap(c)
+	if !ok {
+		return
+	}
+	data, err := service.UpdateAlertRule(c.Param("lcuuid"), patchMap)
+	JsonResponse(c, data, err)
+}
+
+func deleteAlertRule(c *gin.Context) {
+	data, err := service.DeleteAlertRule(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
+
+func getAlertChannels(c *gin.Context) {
+	data, err := service.GetAlertChannels(getPolicyFilter(c, "type"))
+	JsonResponse(c, data, err)
+}
+
+func createAlertChannel(c *gin.Context) {
+	var channelCreate model.AlertChannelCreate
+	if err := c.ShouldBindBodyWith(&channelCreate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.CreateAlertChannel(channelCreate)
+	JsonResponse(c, data, err)
+}
+
+func updateAlertChannel(c *gin.Context) {
+	patchMap, ok := getPolicyPatchMap(c)
+	if !ok {
+		return
+	}
+	data, err := service.UpdateAlertChannel(c.Param("lcuuid"), patchMap)
+	JsonResponse(c, data, err)
+}
+
+func deleteAlertChannel(c *gin.Context) {
+	data, err := service.DeleteAlertChannel(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
+
+func testAlertChannel(cfg *config.ControllerConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		data, err := service.TestAlertChannel(c.Param("lcuuid"), cfg)
+		JsonResponse(c, data, err)
+	})
+}
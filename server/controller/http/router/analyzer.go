@@ -22,6 +22,7 @@ import (
 	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
 	. "github.com/deepflowio/deepflow/server/controller/http/router/common"
 	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/http/service/rebalance"
 	"github.com/deepflowio/deepflow/server/controller/model"
 	"github.com/deepflowio/deepflow/server/controller/monitor"
 
@@ -43,6 +44,12 @@ func (a *Analyzer) RegisterTo(e *gin.Engine) {
 	e.GET("/v1/analyzers/", getAnalyzers)
 	e.PATCH("/v1/analyzers/:lcuuid/", updateAnalyzer(a.ac, a.cfg))
 	e.DELETE("/v1/analyzers/:lcuuid/", deleteAnalyzer(a.ac, a.cfg))
+
+	e.GET("/v1/rebalance-plans/", getRebalancePlans(a.cfg))
+	e.GET("/v1/rebalance-plans/:lcuuid/", getRebalancePlan(a.cfg))
+	e.POST("/v1/rebalance-plans/", createRebalancePlan(a.cfg))
+	e.POST("/v1/rebalance-plans/:lcuuid/apply/", applyRebalancePlan(a.cfg))
+	e.DELETE("/v1/rebalance-plans/:lcuuid/", discardRebalancePlan(a.cfg))
 }
 
 func getAnalyzer(c *gin.Context) {
@@ -114,3 +121,50 @@ func deleteAnalyzer(m *monitor.AnalyzerCheck, cfg *config.ControllerConfig) gin.
 		JsonResponse(c, data, err)
 	})
 }
+
+func getRebalancePlans(cfg *config.ControllerConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		args := make(map[string]interface{})
+		if value, ok := c.GetQuery("state"); ok {
+			args["state"] = value
+		}
+		data, err := service.GetAnalyzerRebalancePlans(args, cfg.MonitorCfg.IngesterLoadBalancingConfig)
+		JsonResponse(c, data, err)
+	})
+}
+
+func getRebalancePlan(cfg *config.ControllerConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		data, err := service.GetAnalyzerRebalancePlan(c.Param("lcuuid"), cfg.MonitorCfg.IngesterLoadBalancingConfig)
+		JsonResponse(c, data, err)
+	})
+}
+
+func createRebalancePlan(cfg *config.ControllerConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var planCreate model.AnalyzerRebalancePlanCreate
+		// 请求体可以为空，此时使用配置文件中的参数
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindBodyWith(&planCreate, binding.JSON); err != nil {
+				BadRequestResponse(c, httpcommon.INVALID_POST_DATA, err.Error())
+				return
+			}
+		}
+		data, err := service.CreateAnalyzerRebalancePlan(planCreate, rebalance.PLAN_SOURCE_MANUAL, cfg.MonitorCfg.IngesterLoadBalancingConfig)
+		JsonResponse(c, data, err)
+	})
+}
+
+func applyRebalancePlan(cfg *config.ControllerConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		data, err := service.ApplyAnalyzerRebalancePlan(c.Param("lcuuid"), cfg.MonitorCfg.IngesterLoadBalancingConfig)
+		JsonResponse(c, data, err)
+	})
+}
+
+func discardRebalancePlan(cfg *config.ControllerConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		data, err := service.DiscardAnalyzerRebalancePlan(c.Param("lcuuid"), cfg.MonitorCfg.IngesterLoadBalancingConfig)
+		JsonResponse(c, data, err)
+	})
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	servicecommon "github.com/deepflowio/deepflow/server/controller/http/service/common"
+)
+
+// GetOrgID 从请求头 X-Org-Id 中获取组织 ID，未携带时返回默认组织
+func GetOrgID(c *gin.Context) (int, error) {
+	value := c.GetHeader(httpcommon.HEADER_KEY_X_ORG_ID)
+	if value == "" {
+		return common.DEFAULT_ORG_ID, nil
+	}
+	orgID, err := strconv.Atoi(value)
+	if err != nil || orgID <= 0 {
+		return 0, fmt.Errorf("invalid header %s: %s", httpcommon.HEADER_KEY_X_ORG_ID, value)
+	}
+	return orgID, nil
+}
+
+// OrgJsonResponse 用于组织接口，组织不存在时返回 404，其他接口仍沿用 JsonResponse 的 400
+func OrgJsonResponse(c *gin.Context, data interface{}, err error) {
+	if t, ok := err.(*servicecommon.ServiceError); ok && t.Status == httpcommon.RESOURCE_NOT_FOUND {
+		NotFoundResponse(c, t.Status, t.Message)
+		return
+	}
+	JsonResponse(c, data, err)
+}
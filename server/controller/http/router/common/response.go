@@ -46,6 +46,13 @@ func BadRequestResponse(c *gin.Context, optStatus string, description string) {
 	})
 }
 
+func NotFoundResponse(c *gin.Context, optStatus string, description string) {
+	c.JSON(http.StatusNotFound, Response{
+		OptStatus:   optStatus,
+		Description: description,
+	})
+}
+
 func InternalErrorResponse(c *gin.Context, data interface{}, optStatus string, description string) {
 	c.JSON(http.StatusInternalServerError, Response{
 		OptStatus:   optStatus,
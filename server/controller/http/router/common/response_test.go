@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	servicecommon "github.com/deepflowio/deepflow/server/controller/http/service/common"
+)
+
+func TestJsonResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: http.StatusOK},
+		{name: "not found", err: servicecommon.NewError(httpcommon.RESOURCE_NOT_FOUND, "not found"), want: http.StatusBadRequest},
+		{name: "invalid parameters", err: servicecommon.NewError(httpcommon.INVALID_PARAMETERS, "invalid"), want: http.StatusBadRequest},
+		{name: "server error", err: errors.New("failed"), want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			JsonResponse(c, nil, tt.err)
+			if w.Code != tt.want {
+				t.Errorf("JsonResponse() status = %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrgJsonResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: http.StatusOK},
+		{name: "not found", err: servicecommon.NewError(httpcommon.RESOURCE_NOT_FOUND, "not found"), want: http.StatusNotFound},
+		{name: "invalid parameters", err: servicecommon.NewError(httpcommon.INVALID_PARAMETERS, "invalid"), want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			OrgJsonResponse(c, nil, tt.err)
+			if w.Code != tt.want {
+				t.Errorf("OrgJsonResponse() status = %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+}
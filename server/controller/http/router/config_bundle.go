@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+type ConfigBundle struct{}
+
+func NewConfigBundle() *ConfigBundle {
+	return new(ConfigBundle)
+}
+
+func (cb *ConfigBundle) RegisterTo(e *gin.Engine) {
+	e.POST("/v1/config-bundle/dry-run/", dryRunConfigBundle)
+}
+
+// dryRunConfigBundle 供 deepflow-ctl apply/diff 使用，apply 前在服务端校验整个 bundle
+func dryRunConfigBundle(c *gin.Context) {
+	var bundle model.ConfigBundle
+	err := c.ShouldBindBodyWith(&bundle, binding.JSON)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_POST_DATA, err.Error())
+		return
+	}
+	orgID, err := GetOrgID(c)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data := service.ValidateConfigBundle(orgID, bundle)
+	JsonResponse(c, data, nil)
+}
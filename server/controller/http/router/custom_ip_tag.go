@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+type CustomIPTag struct{}
+
+func NewCustomIPTag() *CustomIPTag {
+	return new(CustomIPTag)
+}
+
+func (t *CustomIPTag) RegisterTo(e *gin.Engine) {
+	e.GET("/v1/custom-ip-tag-sets/", getCustomIPTagSets)
+	e.POST("/v1/custom-ip-tag-sets/", createCustomIPTagSet)
+	e.PATCH("/v1/custom-ip-tag-sets/:lcuuid/", updateCustomIPTagSet)
+	e.DELETE("/v1/custom-ip-tag-sets/:lcuuid/", deleteCustomIPTagSet)
+
+	e.GET("/v1/custom-ip-tag-sets/:lcuuid/tags/", getCustomIPTags)
+	e.POST("/v1/custom-ip-tag-sets/:lcuuid/tags/", uploadCustomIPTags)
+	e.POST("/v1/custom-ip-tag-sets/:lcuuid/sync/", syncCustomIPTagSet)
+}
+
+func getCustomIPTagSets(c *gin.Context) {
+	data, err := service.GetCustomIPTagSets(getPolicyFilter(c))
+	JsonResponse(c, data, err)
+}
+
+func createCustomIPTagSet(c *gin.Context) {
+	var tagSetCreate model.CustomIPTagSetCreate
+	if err := c.ShouldBindBodyWith(&tagSetCreate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.CreateCustomIPTagSet(tagSetCreate)
+	JsonResponse(c, data, err)
+}
+
+func updateCustomIPTagSet(c *gin.Context) {
+	patchMap, ok := getPolicyPatchMap(c)
+	if !ok {
+		return
+	}
+	data, err := service.UpdateCustomIPTagSet(c.Param("lcuuid"), patchMap)
+	JsonResponse(c, data, err)
+}
+
+func deleteCustomIPTagSet(c *gin.Context) {
+	data, err := service.DeleteCustomIPTagSet(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
+
+func getCustomIPTags(c *gin.Context) {
+	data, err := service.GetCustomIPTags(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
+
+// uploadCustomIPTags 支持 multipart 表单的 FILE 字段，或直接以 CSV 作为请求体
+func uploadCustomIPTags(c *gin.Context) {
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, _, err := c.Request.FormFile("FILE")
+		if err != nil {
+			BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		defer file.Close()
+		reader = file
+	}
+	data, err := service.UploadCustomIPTags(c.Param("lcuuid"), reader)
+	JsonResponse(c, data, err)
+}
+
+func syncCustomIPTagSet(c *gin.Context) {
+	data, err := service.SyncCustomIPTagSet(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/deepflowio/deepflow/server/libs/stats"
+)
+
+// Metrics 以 Prometheus/OpenMetrics 格式输出 server 自监控指标
+type Metrics struct{}
+
+func NewMetrics() *Metrics {
+	stats.RegisterGcMonitor()
+	return new(Metrics)
+}
+
+func (m *Metrics) RegisterTo(e *gin.Engine) {
+	e.GET("/metrics", gin.WrapH(stats.PrometheusHandler()))
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+type Org struct{}
+
+func NewOrg() *Org {
+	return &Org{}
+}
+
+func (o *Org) RegisterTo(e *gin.Engine) {
+	e.GET("/v1/orgs/:lcuuid/", getOrg)
+	e.GET("/v1/orgs/", getOrgs)
+	e.POST("/v1/orgs/", createOrg)
+	e.PATCH("/v1/orgs/:lcuuid/", updateOrg)
+	e.DELETE("/v1/orgs/:lcuuid/", deleteOrg)
+}
+
+func getOrg(c *gin.Context) {
+	args := make(map[string]interface{})
+	args["lcuuid"] = c.Param("lcuuid")
+	data, err := service.GetOrgs(args)
+	OrgJsonResponse(c, data, err)
+}
+
+func getOrgs(c *gin.Context) {
+	args := make(map[string]interface{})
+	if value, ok := c.GetQuery("name"); ok {
+		args["name"] = value
+	}
+	if value, ok := c.GetQuery("id"); ok {
+		args["id"] = value
+	}
+	data, err := service.GetOrgs(args)
+	OrgJsonResponse(c, data, err)
+}
+
+func createOrg(c *gin.Context) {
+	var err error
+	var orgCreate model.OrgCreate
+
+	// 参数校验
+	err = c.ShouldBindBodyWith(&orgCreate, binding.JSON)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_POST_DATA, err.Error())
+		return
+	}
+
+	data, err := service.CreateOrg(orgCreate)
+	OrgJsonResponse(c, data, err)
+}
+
+func updateOrg(c *gin.Context) {
+	var err error
+	var orgUpdate model.OrgUpdate
+
+	// 参数校验
+	err = c.ShouldBindBodyWith(&orgUpdate, binding.JSON)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+
+	// 避免struct会有默认值，这里转为map作为函数入参
+	patchMap := map[string]interface{}{}
+	c.ShouldBindBodyWith(&patchMap, binding.JSON)
+
+	data, err := service.UpdateOrg(c.Param("lcuuid"), patchMap)
+	OrgJsonResponse(c, data, err)
+}
+
+func deleteOrg(c *gin.Context) {
+	data, err := service.DeleteOrg(c.Param("lcuuid"))
+	OrgJsonResponse(c, data, err)
+}
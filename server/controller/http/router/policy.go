@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+type Policy struct{}
+
+func NewPolicy() *Policy {
+	return new(Policy)
+}
+
+func (p *Policy) RegisterTo(e *gin.Engine) {
+	e.GET("/v1/flow-acls/", getFlowACLs)
+	e.POST("/v1/flow-acls/", createFlowACL)
+	e.PATCH("/v1/flow-acls/:lcuuid/", updateFlowACL)
+	e.DELETE("/v1/flow-acls/:lcuuid/", deleteFlowACL)
+
+	e.GET("/v1/npb-tunnels/", getNpbTunnels)
+	e.POST("/v1/npb-tunnels/", createNpbTunnel)
+	e.PATCH("/v1/npb-tunnels/:lcuuid/", updateNpbTunnel)
+	e.DELETE("/v1/npb-tunnels/:lcuuid/", deleteNpbTunnel)
+
+	e.GET("/v1/npb-policies/", getNpbPolicies)
+	e.POST("/v1/npb-policies/", createNpbPolicy)
+	e.PATCH("/v1/npb-policies/:lcuuid/", updateNpbPolicy)
+	e.DELETE("/v1/npb-policies/:lcuuid/", deleteNpbPolicy)
+
+	e.GET("/v1/pcap-policies/", getPcapPolicies)
+	e.POST("/v1/pcap-policies/", createPcapPolicy)
+	e.PATCH("/v1/pcap-policies/:lcuuid/", updatePcapPolicy)
+	e.DELETE("/v1/pcap-policies/:lcuuid/", deletePcapPolicy)
+}
+
+func getPolicyFilter(c *gin.Context, params ...string) map[string]interface{} {
+	args := make(map[string]interface{})
+	for _, param := range append([]string{"lcuuid", "name", "id"}, params...) {
+		if value, ok := c.GetQuery(param); ok {
+			args[param] = value
+		}
+	}
+	return args
+}
+
+// 避免struct会有默认值，PATCH 参数转为map作为函数入参
+func getPolicyPatchMap(c *gin.Context) (map[string]interface{}, bool) {
+	patchMap := map[string]interface{}{}
+	if err := c.ShouldBindBodyWith(&patchMap, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return nil, false
+	}
+	return patchMap, true
+}
+
+func getFlowACLs(c *gin.Context) {
+	data, err := service.GetFlowACLs(getPolicyFilter(c, "application"))
+	JsonResponse(c, data, err)
+}
+
+func createFlowACL(c *gin.Context) {
+	var aclCreate model.FlowACLCreate
+	if err := c.ShouldBindBodyWith(&aclCreate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.CreateFlowACL(aclCreate)
+	JsonResponse(c, data, err)
+}
+
+func updateFlowACL(c *gin.Context) {
+	patchMap, ok := getPolicyPatchMap(c)
+	if !ok {
+		return
+	}
+	data, err := service.UpdateFlowACL(c.Param("lcuuid"), patchMap)
+	JsonResponse(c, data, err)
+}
+
+func deleteFlowACL(c *gin.Context) {
+	data, err := service.DeleteFlowACL(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
+
+func getNpbTunnels(c *gin.Context) {
+	data, err := service.GetNpbTunnels(getPolicyFilter(c))
+	JsonResponse(c, data, err)
+}
+
+func createNpbTunnel(c *gin.Context) {
+	var npbTunnelCreate model.NpbTunnelCreate
+	if err := c.ShouldBindBodyWith(&npbTunnelCreate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.CreateNpbTunnel(npbTunnelCreate)
+	JsonResponse(c, data, err)
+}
+
+func updateNpbTunnel(c *gin.Context) {
+	patchMap, ok := getPolicyPatchMap(c)
+	if !ok {
+		return
+	}
+	data, err := service.UpdateNpbTunnel(c.Param("lcuuid"), patchMap)
+	JsonResponse(c, data, err)
+}
+
+func deleteNpbTunnel(c *gin.Context) {
+	data, err := service.DeleteNpbTunnel(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
+
+func getNpbPolicies(c *gin.Context) {
+	data, err := service.GetNpbPolicies(getPolicyFilter(c, "acl_id", "npb_tunnel_id"))
+	JsonResponse(c, data, err)
+}
+
+func createNpbPolicy(c *gin.Context) {
+	var npbPolicyCreate model.NpbPolicyCreate
+	if err := c.ShouldBindBodyWith(&npbPolicyCreate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.CreateNpbPolicy(npbPolicyCreate)
+	JsonResponse(c, data, err)
+}
+
+func updateNpbPolicy(c *gin.Context) {
+	patchMap, ok := getPolicyPatchMap(c)
+	if !ok {
+		return
+	}
+	data, err := service.UpdateNpbPolicy(c.Param("lcuuid"), patchMap)
+	JsonResponse(c, data, err)
+}
+
+func deleteNpbPolicy(c *gin.Context) {
+	data, err := service.DeleteNpbPolicy(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
+
+func getPcapPolicies(c *gin.Context) {
+	data, err := service.GetPcapPolicies(getPolicyFilter(c, "acl_id"))
+	JsonResponse(c, data, err)
+}
+
+func createPcapPolicy(c *gin.Context) {
+	var pcapPolicyCreate model.PcapPolicyCreate
+	if err := c.ShouldBindBodyWith(&pcapPolicyCreate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.CreatePcapPolicy(pcapPolicyCreate)
+	JsonResponse(c, data, err)
+}
+
+func updatePcapPolicy(c *gin.Context) {
+	patchMap, ok := getPolicyPatchMap(c)
+	if !ok {
+		return
+	}
+	data, err := service.UpdatePcapPolicy(c.Param("lcuuid"), patchMap)
+	JsonResponse(c, data, err)
+}
+
+func deletePcapPolicy(c *gin.Context) {
+	data, err := service.DeletePcapPolicy(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
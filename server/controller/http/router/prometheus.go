@@ -25,18 +25,49 @@ import (
 	"github.com/gin-gonic/gin/binding"
 
 	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/config"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
 	routercommon "github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
 	"github.com/deepflowio/deepflow/server/controller/prometheus"
 )
 
-type Prometheus struct{}
+type Prometheus struct {
+	cfg *config.ControllerConfig
+}
 
-func NewPrometheus() *Prometheus {
-	return &Prometheus{}
+func NewPrometheus(cfg *config.ControllerConfig) *Prometheus {
+	return &Prometheus{cfg: cfg}
 }
 
 func (p *Prometheus) RegisterTo(e *gin.Engine) {
 	e.POST("/v1/prometheus-cleaner-tasks/", createPrometheusCleanTask)
+	e.GET("/v1/prometheus-cardinality/", getPrometheusCardinality(p.cfg))
+	e.GET("/v1/prometheus-cardinality/growth/", getPrometheusCardinalityGrowth(p.cfg))
+}
+
+func getPrometheusCardinality(cfg *config.ControllerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query model.PrometheusCardinalityQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			routercommon.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		data, err := prometheus.GetCardinality(cfg.ClickHouseCfg, &query)
+		routercommon.JsonResponse(c, data, err)
+	}
+}
+
+func getPrometheusCardinalityGrowth(cfg *config.ControllerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query model.PrometheusCardinalityQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			routercommon.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		data, err := prometheus.GetCardinalityGrowth(cfg.ClickHouseCfg, &query)
+		routercommon.JsonResponse(c, data, err)
+	}
 }
 
 func createPrometheusCleanTask(c *gin.Context) {
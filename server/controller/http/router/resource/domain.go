@@ -31,6 +31,7 @@ import (
 	"github.com/deepflowio/deepflow/server/controller/config"
 	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
 	"github.com/deepflowio/deepflow/server/controller/http/router/common"
+	servicecommon "github.com/deepflowio/deepflow/server/controller/http/service/common"
 	"github.com/deepflowio/deepflow/server/controller/http/service/resource"
 	"github.com/deepflowio/deepflow/server/controller/model"
 )
@@ -68,14 +69,26 @@ func (d *Domain) RegisterTo(e *gin.Engine) {
 }
 
 func getDomain(c *gin.Context) {
+	orgID, err := common.GetOrgID(c)
+	if err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	args := make(map[string]interface{})
+	args["org_id"] = orgID
 	args["lcuuid"] = c.Param("lcuuid")
 	data, err := resource.GetDomains(args)
 	common.JsonResponse(c, data, err)
 }
 
 func getDomains(c *gin.Context) {
+	orgID, err := common.GetOrgID(c)
+	if err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	args := make(map[string]interface{})
+	args["org_id"] = orgID
 	if value, ok := c.GetQuery("name"); ok {
 		args["name"] = value
 	}
@@ -95,6 +108,12 @@ func createDomain(cfg *config.ControllerConfig) gin.HandlerFunc {
 			return
 		}
 
+		domainCreate.OrgID, err = common.GetOrgID(c)
+		if err != nil {
+			common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+
 		data, err := resource.CreateDomain(domainCreate, cfg)
 		common.JsonResponse(c, data, err)
 	})
@@ -122,52 +141,88 @@ func updateDomain(cfg *config.ControllerConfig) gin.HandlerFunc {
 		patchMap := map[string]interface{}{}
 		c.ShouldBindBodyWith(&patchMap, binding.JSON)
 
+		orgID, err := common.GetOrgID(c)
+		if err != nil {
+			common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
 		lcuuid := c.Param("lcuuid")
 
 		// set vtap
-		err = resource.KubernetesSetVtap(lcuuid, vTapValue, false)
+		err = resource.KubernetesSetVtap(orgID, lcuuid, vTapValue, false)
 		if err != nil {
-			common.BadRequestResponse(c, httpcommon.K8S_SET_VTAP_FAIL, err.Error())
+			setVtapFailResponse(c, err)
 			return
 		}
 
-		data, err := resource.UpdateDomain(lcuuid, patchMap, cfg)
+		data, err := resource.UpdateDomain(orgID, lcuuid, patchMap, cfg)
 		common.JsonResponse(c, data, err)
 	})
 }
 
+// setVtapFailResponse 域或子域不属于请求的组织时与更新、删除一样返回未找到
+func setVtapFailResponse(c *gin.Context, err error) {
+	if e, ok := err.(*servicecommon.ServiceError); ok && e.Status == httpcommon.RESOURCE_NOT_FOUND {
+		common.JsonResponse(c, nil, err)
+		return
+	}
+	common.BadRequestResponse(c, httpcommon.K8S_SET_VTAP_FAIL, err.Error())
+}
+
 func deleteDomainByNameOrUUID(c *gin.Context) {
+	orgID, err := common.GetOrgID(c)
+	if err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	nameOrUUID := c.Param("name-or-uuid")
-	data, err := resource.DeleteDomainByNameOrUUID(nameOrUUID)
+	data, err := resource.DeleteDomainByNameOrUUID(orgID, nameOrUUID)
 	common.JsonResponse(c, data, err)
 }
 
 func deleteDomainByName(c *gin.Context) {
+	orgID, err := common.GetOrgID(c)
+	if err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	rawQuery := strings.Split(c.Request.URL.RawQuery, "name=")
 	if len(rawQuery) < 1 {
 		common.JsonResponse(c, nil, fmt.Errorf("please fill in the name parameter: domains/?name={}"))
 		return
 	}
 	name := rawQuery[1]
-	name, err := url.QueryUnescape(name)
+	name, err = url.QueryUnescape(name)
 	if err != nil {
 		log.Warning(err)
 		name = rawQuery[1]
 	}
 	log.Infof("delete domain by name(%v)", name)
-	data, err := resource.DeleteDomainByNameOrUUID(name)
+	data, err := resource.DeleteDomainByNameOrUUID(orgID, name)
 	common.JsonResponse(c, data, err)
 }
 
 func getSubDomain(c *gin.Context) {
+	orgID, err := common.GetOrgID(c)
+	if err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	args := make(map[string]interface{})
+	args["org_id"] = orgID
 	args["lcuuid"] = c.Param("lcuuid")
 	data, err := resource.GetSubDomains(args)
 	common.JsonResponse(c, data, err)
 }
 
 func getSubDomains(c *gin.Context) {
+	orgID, err := common.GetOrgID(c)
+	if err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	args := make(map[string]interface{})
+	args["org_id"] = orgID
 	if value, ok := c.GetQuery("domain"); ok {
 		args["domain"] = value
 	}
@@ -189,15 +244,24 @@ func createSubDomain(c *gin.Context) {
 		return
 	}
 
+	subDomainCreate.OrgID, err = common.GetOrgID(c)
+	if err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+
 	data, err := resource.CreateSubDomain(subDomainCreate)
 	common.JsonResponse(c, data, err)
 }
 
 func deleteSubDomain(c *gin.Context) {
-	var err error
-
+	orgID, err := common.GetOrgID(c)
+	if err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	lcuuid := c.Param("lcuuid")
-	data, err := resource.DeleteSubDomain(lcuuid)
+	data, err := resource.DeleteSubDomain(orgID, lcuuid)
 	common.JsonResponse(c, data, err)
 }
 
@@ -223,15 +287,20 @@ func updateSubDomain(c *gin.Context) {
 	patchMap := map[string]interface{}{}
 	c.ShouldBindBodyWith(&patchMap, binding.JSON)
 
+	orgID, err := common.GetOrgID(c)
+	if err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	lcuuid := c.Param("lcuuid")
 
-	err = resource.KubernetesSetVtap(lcuuid, vTapValue, true)
+	err = resource.KubernetesSetVtap(orgID, lcuuid, vTapValue, true)
 	if err != nil {
-		common.BadRequestResponse(c, httpcommon.K8S_SET_VTAP_FAIL, err.Error())
+		setVtapFailResponse(c, err)
 		return
 	}
 
-	data, err := resource.UpdateSubDomain(lcuuid, patchMap)
+	data, err := resource.UpdateSubDomain(orgID, lcuuid, patchMap)
 	common.JsonResponse(c, data, err)
 }
 
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/deepflowio/deepflow/server/controller/config"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+)
+
+func TestCrossOrgDomainMutation(t *testing.T) {
+	db, err := gorm.Open(
+		sqlite.Open("file:cross_org_domain?mode=memory&cache=shared"),
+		&gorm.Config{NamingStrategy: schema.NamingStrategy{SingularTable: true}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mysql.Db = db
+	if err := db.AutoMigrate(&mysql.Domain{}, &mysql.SubDomain{}); err != nil {
+		t.Fatal(err)
+	}
+	domain := mysql.Domain{Base: mysql.Base{Lcuuid: uuid.NewString()}, Name: "org2-domain", ClusterID: "d-org2", OrgID: 2}
+	db.Create(&domain)
+	subDomain := mysql.SubDomain{Base: mysql.Base{Lcuuid: uuid.NewString()}, Name: "org2-sub-domain", ClusterID: "d-org2-sub", Domain: domain.Lcuuid}
+	db.Create(&subDomain)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	NewDomain(&config.ControllerConfig{}).RegisterTo(e)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+	}{
+		{name: "update domain", method: "PATCH", url: "/v1/domains/" + domain.Lcuuid + "/", body: `{"NAME": "renamed"}`},
+		{name: "update domain vtap", method: "PATCH", url: "/v1/domains/" + domain.Lcuuid + "/", body: `{"CONFIG": {"vtap_id": "1.1.1.1-00:00:00:00:00:01"}}`},
+		{name: "delete domain by uuid", method: "DELETE", url: "/v1/domains/" + domain.Lcuuid + "/"},
+		{name: "delete domain by name", method: "DELETE", url: "/v1/domains/?name=" + domain.Name},
+		{name: "update sub domain", method: "PATCH", url: "/v2/sub-domains/" + subDomain.Lcuuid + "/", body: `{"CONFIG": {}}`},
+		{name: "delete sub domain", method: "DELETE", url: "/v2/sub-domains/" + subDomain.Lcuuid + "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(httpcommon.HEADER_KEY_X_ORG_ID, "3")
+			e.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s %s status = %v, want %v, body: %s", tt.method, tt.url, w.Code, http.StatusBadRequest, w.Body.String())
+			}
+		})
+	}
+
+	var got mysql.Domain
+	if err := db.Where("lcuuid = ?", domain.Lcuuid).First(&got).Error; err != nil || got.Name != domain.Name {
+		t.Errorf("domain of org 2 is changed: %+v, %v", got, err)
+	}
+	var count int64
+	db.Model(&mysql.SubDomain{}).Where("lcuuid = ?", subDomain.Lcuuid).Count(&count)
+	if count != 1 {
+		t.Errorf("sub_domain of org 2 is deleted")
+	}
+}
@@ -68,14 +68,26 @@ func (v *Vtap) RegisterTo(e *gin.Engine) {
 }
 
 func getVtap(c *gin.Context) {
+	orgID, err := GetOrgID(c)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	args := make(map[string]interface{})
+	args["org_id"] = orgID
 	args["lcuuid"] = c.Param("lcuuid")
 	data, err := service.GetVtaps(args)
 	JsonResponse(c, data, err)
 }
 
 func getVtaps(c *gin.Context) {
+	orgID, err := GetOrgID(c)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	args := make(map[string]interface{})
+	args["org_id"] = orgID
 	args["names"] = c.QueryArray("name")
 	if value, ok := c.GetQuery("type"); ok {
 		args["type"] = value
@@ -279,7 +291,12 @@ func getVtapCSV(c *gin.Context) {
 		return
 	}
 
-	vtaps, err := service.GetVtaps(nil)
+	orgID, err := GetOrgID(c)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	vtaps, err := service.GetVtaps(map[string]interface{}{"org_id": orgID})
 	if err != nil {
 		BadRequestResponse(c, httpcommon.SERVER_ERROR, "get vtaps failed")
 		return
@@ -44,14 +44,26 @@ func (v *VtapGroup) RegisterTo(e *gin.Engine) {
 }
 
 func getVtapGroup(c *gin.Context) {
+	orgID, err := GetOrgID(c)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	args := make(map[string]interface{})
+	args["org_id"] = orgID
 	args["lcuuid"] = c.Param("lcuuid")
 	data, err := service.GetVtapGroups(args)
 	JsonResponse(c, data, err)
 }
 
 func getVtapGroups(c *gin.Context) {
+	orgID, err := GetOrgID(c)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 	args := make(map[string]interface{})
+	args["org_id"] = orgID
 	if value, ok := c.GetQuery("name"); ok {
 		args["name"] = value
 	}
@@ -74,7 +86,13 @@ func createVtapGroup(cfg *config.ControllerConfig) gin.HandlerFunc {
 			return
 		}
 
-		data, err := service.CreateVtapGroup(vtapGroupCreate, cfg)
+		orgID, err := GetOrgID(c)
+		if err != nil {
+			BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+
+		data, err := service.CreateVtapGroup(orgID, vtapGroupCreate, cfg)
 		JsonResponse(c, data, err)
 	})
 }
@@ -96,16 +114,26 @@ func updateVtapGroup(cfg *config.ControllerConfig) gin.HandlerFunc {
 		patchMap := map[string]interface{}{}
 		c.ShouldBindBodyWith(&patchMap, binding.JSON)
 
+		orgID, err := GetOrgID(c)
+		if err != nil {
+			BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+
 		lcuuid := c.Param("lcuuid")
-		data, err := service.UpdateVtapGroup(lcuuid, patchMap, cfg)
+		data, err := service.UpdateVtapGroup(orgID, lcuuid, patchMap, cfg)
 		JsonResponse(c, data, err)
 	})
 }
 
 func deleteVtapGroup(c *gin.Context) {
-	var err error
+	orgID, err := GetOrgID(c)
+	if err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
 
 	lcuuid := c.Param("lcuuid")
-	data, err := service.DeleteVtapGroup(lcuuid)
+	data, err := service.DeleteVtapGroup(orgID, lcuuid)
 	JsonResponse(c, data, err)
 }
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/deepflowio/deepflow/server/controller/config"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+)
+
+func TestCrossOrgVtapGroupMutation(t *testing.T) {
+	db, err := gorm.Open(
+		sqlite.Open("file:cross_org_vtap_group?mode=memory&cache=shared"),
+		&gorm.Config{NamingStrategy: schema.NamingStrategy{SingularTable: true}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mysql.Db = db
+	if err := db.AutoMigrate(&mysql.VTapGroup{}, &mysql.VTap{}); err != nil {
+		t.Fatal(err)
+	}
+	vtapGroup := mysql.VTapGroup{Lcuuid: uuid.NewString(), Name: "org2-group", ShortUUID: "g-org2", OrgID: 2}
+	db.Create(&vtapGroup)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	NewVtapGroup(&config.ControllerConfig{}).RegisterTo(e)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+	}{
+		{name: "update vtap group", method: "PATCH", body: `{"NAME": "renamed"}`},
+		{name: "delete vtap group", method: "DELETE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/v1/vtap-groups/"+vtapGroup.Lcuuid+"/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(httpcommon.HEADER_KEY_X_ORG_ID, "3")
+			e.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s status = %v, want %v, body: %s", tt.method, w.Code, http.StatusBadRequest, w.Body.String())
+			}
+		})
+	}
+
+	var got mysql.VTapGroup
+	if err := db.Where("lcuuid = ?", vtapGroup.Lcuuid).First(&got).Error; err != nil || got.Name != vtapGroup.Name {
+		t.Errorf("vtap_group of org 2 is changed: %+v, %v", got, err)
+	}
+}
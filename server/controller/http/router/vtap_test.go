@@ -17,69 +17,75 @@
 package router
 
 import (
-	"reflect"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
-	"github.com/deepflowio/deepflow/server/controller/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/deepflowio/deepflow/server/controller/config"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
 )
 
-func Test_getVtapCSVData(t *testing.T) {
-	type args struct {
-		headerMap map[string]int
-		vtap      *model.Vtap
+func TestGetVtapsOfOrg(t *testing.T) {
+	db, err := gorm.Open(
+		sqlite.Open("file:org_vtaps?mode=memory&cache=shared"),
+		&gorm.Config{NamingStrategy: schema.NamingStrategy{SingularTable: true}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mysql.Db = db
+	if err := db.AutoMigrate(&mysql.VTap{}, &mysql.VTapGroup{}, &mysql.Region{}, &mysql.AZ{}, &mysql.VTapRepo{}); err != nil {
+		t.Fatal(err)
 	}
+	org2VTap := mysql.VTap{Lcuuid: uuid.NewString(), Name: "org2-vtap", OrgID: 2}
+	org3VTap := mysql.VTap{Lcuuid: uuid.NewString(), Name: "org3-vtap", OrgID: 3}
+	db.Create(&org2VTap)
+	db.Create(&org3VTap)
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	NewVtap(&config.ControllerConfig{}).RegisterTo(e)
+
 	tests := []struct {
 		name string
-		args args
+		url  string
 		want []string
 	}{
-		{
-			name: "two headers",
-			args: args{
-				headerMap: map[string]int{
-					"NAME":        0,
-					"REGION_NAME": 1,
-				},
-				vtap: &model.Vtap{Name: "name", RegionName: "region name"},
-			},
-			want: []string{"name", "region name"},
-		},
-		{
-			name: "special headers",
-			args: args{
-				headerMap: map[string]int{
-					"TYPE":       0,
-					"TAP_MODE":   1,
-					"STATE":      2,
-					"BOOT_TIME":  3,
-					"EXCEPTIONS": 4,
-				},
-				vtap: &model.Vtap{
-					Type:       1,
-					TapMode:    1,
-					State:      1,
-					BootTime:   1676022891,
-					Exceptions: []int64{268435456, 1073741824},
-				},
-			},
-			want: []string{"KVM", "镜像", "运行", "2023-02-10 17:54:51", "采集器授权个数不足、分配数据节点失败"},
-		},
-		{
-			name: "boot time header",
-			args: args{
-				headerMap: map[string]int{
-					"BOOT_TIME":  0,
-					"EXCEPTIONS": 0,
-				},
-				vtap: &model.Vtap{BootTime: 0, Exceptions: []int64{}},
-			},
-			want: []string{"", ""},
-		},
+		{name: "vtaps of org", url: "/v1/vtaps/", want: []string{org2VTap.Lcuuid}},
+		{name: "vtap of org", url: "/v1/vtaps/" + org2VTap.Lcuuid + "/", want: []string{org2VTap.Lcuuid}},
+		{name: "vtap of other org", url: "/v1/vtaps/" + org3VTap.Lcuuid + "/", want: []string{}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := getVtapCSVData(tt.args.headerMap, tt.args.vtap); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getVtapCSVData() = %v, want %v", got, tt.want)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.Header.Set(httpcommon.HEADER_KEY_X_ORG_ID, "2")
+			e.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %v, want %v, body: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+			var resp struct {
+				Data []struct {
+					Lcuuid string `json:"LCUUID"`
+				} `json:"DATA"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, vtap := range resp.Data {
+				got = append(got, vtap.Lcuuid)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("GET %s = %v, want %v", tt.url, got, tt.want)
 			}
 		})
 	}
@@ -64,6 +64,7 @@ func NewServer(logFile string, cfg *config.ControllerConfig) *Server {
 
 func (s *Server) Start() {
 	router.NewHealth().RegisterTo(s.engine)
+	router.NewMetrics().RegisterTo(s.engine)
 	go func() {
 		if err := s.engine.Run(fmt.Sprintf(":%d", s.controllerConfig.ListenPort)); err != nil {
 			log.Errorf("startup service failed, err:%v\n", err)
@@ -105,13 +106,18 @@ func (s *Server) appendRegistrant() []registrant.Registrant {
 		router.NewAnalyzer(s.controllerConfig, s.analyzerChecker),
 		router.NewVtap(s.controllerConfig),
 		router.NewVtapGroup(s.controllerConfig),
+		router.NewOrg(),
 		router.NewDataSource(s.controllerConfig),
 		router.NewVTapGroupConfig(),
 		router.NewVTapInterface(),
 		router.NewVtapRepo(),
 		router.NewPlugin(),
+		router.NewConfigBundle(),
 		router.NewMail(),
-		router.NewPrometheus(),
+		router.NewPolicy(),
+		router.NewCustomIPTag(),
+		router.NewAlert(s.controllerConfig),
+		router.NewPrometheus(s.controllerConfig),
 
 		// resource
 		resource.NewDomain(s.controllerConfig),
@@ -0,0 +1,358 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/deepflowio/deepflow/server/controller/alert"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/config"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+func GetAlertRules(filter map[string]interface{}) ([]model.AlertRule, error) {
+	var rules []mysql.AlertRule
+	db := mysql.Db
+	for _, param := range []string{"lcuuid", "name", "id", "enabled"} {
+		if value, ok := filter[param]; ok {
+			db = db.Where(fmt.Sprintf("%s = ?", param), value)
+		}
+	}
+	if err := db.Order("id").Find(&rules).Error; err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+
+	response := make([]model.AlertRule, 0, len(rules))
+	for _, rule := range rules {
+		channelLcuuids := []string{}
+		if rule.ChannelLcuuids != "" {
+			channelLcuuids = strings.Split(rule.ChannelLcuuids, ",")
+		}
+		lastEvalAt := ""
+		if rule.LastEvalAt != nil {
+			lastEvalAt = rule.LastEvalAt.Format(common.GO_BIRTHDAY)
+		}
+		response = append(response, model.AlertRule{
+			ID:                rule.ID,
+			Name:              rule.Name,
+			Description:       rule.Description,
+			Enabled:           rule.Enabled,
+			Level:             rule.Level,
+			DB:                rule.DB,
+			SQL:               rule.QuerySQL,
+			ValueColumn:       rule.ValueColumn,
+			ValueUnit:         rule.ValueUnit,
+			Comparator:        rule.Comparator,
+			ThresholdCritical: rule.ThresholdCritical,
+			ThresholdError:    rule.ThresholdError,
+			ThresholdWarning:  rule.ThresholdWarning,
+			EvalInterval:      rule.EvalInterval,
+			EvalRange:         rule.EvalRange,
+			ForDuration:       rule.ForDuration,
+			ChannelLcuuids:    channelLcuuids,
+			LastEvalAt:        lastEvalAt,
+			LastEvalError:     rule.LastEvalError,
+			CreatedAt:         rule.CreatedAt.Format(common.GO_BIRTHDAY),
+			UpdatedAt:         rule.UpdatedAt.Format(common.GO_BIRTHDAY),
+			Lcuuid:            rule.Lcuuid,
+		})
+	}
+	return response, nil
+}
+
+func getAlertRule(lcuuid string) (model.AlertRule, error) {
+	rules, err := GetAlertRules(map[string]interface{}{"lcuuid": lcuuid})
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	if len(rules) == 0 {
+		return model.AlertRule{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("alert rule (%s) not found", lcuuid))
+	}
+	return rules[0], nil
+}
+
+func validateAlertRule(ruleCreate *model.AlertRuleCreate) error {
+	if err := alert.ValidateRule(ruleCreate); err != nil {
+		return NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if len(ruleCreate.ChannelLcuuids) == 0 {
+		return nil
+	}
+	var count int64
+	if err := mysql.Db.Model(&mysql.AlertChannel{}).Where("lcuuid IN (?)", ruleCreate.ChannelLcuuids).Count(&count).Error; err != nil {
+		return NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	if int(count) != len(ruleCreate.ChannelLcuuids) {
+		return NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf("alert channels (%v) not all found", ruleCreate.ChannelLcuuids))
+	}
+	return nil
+}
+
+func setAlertRule(rule *mysql.AlertRule, ruleCreate *model.AlertRuleCreate) {
+	rule.Name = ruleCreate.Name
+	rule.Description = ruleCreate.Description
+	rule.Enabled = *ruleCreate.Enabled
+	rule.Level = *ruleCreate.Level
+	rule.DB = ruleCreate.DB
+	rule.QuerySQL = ruleCreate.SQL
+	rule.ValueColumn = ruleCreate.ValueColumn
+	rule.ValueUnit = ruleCreate.ValueUnit
+	rule.Comparator = ruleCreate.Comparator
+	rule.ThresholdCritical = ruleCreate.ThresholdCritical
+	rule.ThresholdError = ruleCreate.ThresholdError
+	rule.ThresholdWarning = ruleCreate.ThresholdWarning
+	rule.EvalInterval = ruleCreate.EvalInterval
+	rule.EvalRange = ruleCreate.EvalRange
+	rule.ForDuration = ruleCreate.ForDuration
+	rule.ChannelLcuuids = strings.Join(ruleCreate.ChannelLcuuids, ",")
+}
+
+func CreateAlertRule(ruleCreate model.AlertRuleCreate) (model.AlertRule, error) {
+	if err := validateAlertRule(&ruleCreate); err != nil {
+		return model.AlertRule{}, err
+	}
+	if err := checkPolicyNameExist(&mysql.AlertRule{}, "alert rule", ruleCreate.Name, 0); err != nil {
+		return model.AlertRule{}, err
+	}
+
+	rule := mysql.AlertRule{Lcuuid: uuid.New().String()}
+	setAlertRule(&rule, &ruleCreate)
+	if err := mysql.Db.Create(&rule).Error; err != nil {
+		return model.AlertRule{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("create alert rule (%s) %+v", rule.Name, ruleCreate)
+	return getAlertRule(rule.Lcuuid)
+}
+
+func UpdateAlertRule(lcuuid string, patchMap map[string]interface{}) (model.AlertRule, error) {
+	var rule mysql.AlertRule
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&rule); ret.Error != nil {
+		return model.AlertRule{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("alert rule (%s) not found", lcuuid))
+	}
+	ruleCreate := model.AlertRuleCreate{
+		Name:              rule.Name,
+		Description:       rule.Description,
+		Enabled:           &rule.Enabled,
+		Level:             &rule.Level,
+		DB:                rule.DB,
+		SQL:               rule.QuerySQL,
+		ValueColumn:       rule.ValueColumn,
+		ValueUnit:         rule.ValueUnit,
+		Comparator:        rule.Comparator,
+		ThresholdCritical: rule.ThresholdCritical,
+		ThresholdError:    rule.ThresholdError,
+		ThresholdWarning:  rule.ThresholdWarning,
+		EvalInterval:      rule.EvalInterval,
+		EvalRange:         rule.EvalRange,
+		ForDuration:       rule.ForDuration,
+	}
+	if rule.ChannelLcuuids != "" {
+		ruleCreate.ChannelLcuuids = strings.Split(rule.ChannelLcuuids, ",")
+	}
+	if err := patchPolicy(&ruleCreate, patchMap); err != nil {
+		return model.AlertRule{}, err
+	}
+	if err := validateAlertRule(&ruleCreate); err != nil {
+		return model.AlertRule{}, err
+	}
+	if err := checkPolicyNameExist(&mysql.AlertRule{}, "alert rule", ruleCreate.Name, rule.ID); err != nil {
+		return model.AlertRule{}, err
+	}
+
+	setAlertRule(&rule, &ruleCreate)
+	// 计算结果和告警状态由 master controller 更新
+	if err := mysql.Db.Omit("last_eval_at", "last_eval_error", "eval_state").Save(&rule).Error; err != nil {
+		return model.AlertRule{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("update alert rule (%s) config %v", rule.Name, patchMap)
+	return getAlertRule(rule.Lcuuid)
+}
+
+func DeleteAlertRule(lcuuid string) (map[string]string, error) {
+	var rule mysql.AlertRule
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&rule); ret.Error != nil {
+		return map[string]string{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("alert rule (%s) not found", lcuuid))
+	}
+	log.Infof("delete alert rule (%s)", rule.Name)
+	if err := mysql.Db.Delete(&rule).Error; err != nil {
+		return map[string]string{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	return map[string]string{"LCUUID": lcuuid}, nil
+}
+
+func GetAlertChannels(filter map[string]interface{}) ([]model.AlertChannel, error) {
+	var channels []mysql.AlertChannel
+	db := mysql.Db
+	for _, param := range []string{"lcuuid", "name", "id", "type"} {
+		if value, ok := filter[param]; ok {
+			db = db.Where(fmt.Sprintf("%s = ?", param), value)
+		}
+	}
+	if err := db.Order("id").Find(&channels).Error; err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+
+	response := make([]model.AlertChannel, 0, len(channels))
+	for _, channel := range channels {
+		channelConfig := make(map[string]interface{})
+		if channel.Config != "" {
+			if err := json.Unmarshal([]byte(channel.Config), &channelConfig); err != nil {
+				log.Warningf("unmarshal config of alert channel (%s) failed: %s", channel.Name, err.Error())
+			}
+		}
+		response = append(response, model.AlertChannel{
+			ID:        channel.ID,
+			Name:      channel.Name,
+			Type:      channel.Type,
+			Config:    channelConfig,
+			CreatedAt: channel.CreatedAt.Format(common.GO_BIRTHDAY),
+			UpdatedAt: channel.UpdatedAt.Format(common.GO_BIRTHDAY),
+			Lcuuid:    channel.Lcuuid,
+		})
+	}
+	return response, nil
+}
+
+func getAlertChannel(lcuuid string) (model.AlertChannel, error) {
+	channels, err := GetAlertChannels(map[string]interface{}{"lcuuid": lcuuid})
+	if err != nil {
+		return model.AlertChannel{}, err
+	}
+	if len(channels) == 0 {
+		return model.AlertChannel{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("alert channel (%s) not found", lcuuid))
+	}
+	return channels[0], nil
+}
+
+func validateAlertChannel(channelCreate *model.AlertChannelCreate) (string, error) {
+	if err := alert.ValidateChannel(channelCreate.Type, channelCreate.Config); err != nil {
+		return "", NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	channelConfig, err := json.Marshal(channelCreate.Config)
+	if err != nil {
+		return "", NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	return string(channelConfig), nil
+}
+
+func CreateAlertChannel(channelCreate model.AlertChannelCreate) (model.AlertChannel, error) {
+	channelConfig, err := validateAlertChannel(&channelCreate)
+	if err != nil {
+		return model.AlertChannel{}, err
+	}
+	if err := checkPolicyNameExist(&mysql.AlertChannel{}, "alert channel", channelCreate.Name, 0); err != nil {
+		return model.AlertChannel{}, err
+	}
+
+	channel := mysql.AlertChannel{
+		Name:   channelCreate.Name,
+		Type:   channelCreate.Type,
+		Config: channelConfig,
+		Lcuuid: uuid.New().String(),
+	}
+	if err := mysql.Db.Create(&channel).Error; err != nil {
+		return model.AlertChannel{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("create alert channel (%s) type %s", channel.Name, channel.Type)
+	return getAlertChannel(channel.Lcuuid)
+}
+
+func UpdateAlertChannel(lcuuid string, patchMap map[string]interface{}) (model.AlertChannel, error) {
+	var channel mysql.AlertChannel
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&channel); ret.Error != nil {
+		return model.AlertChannel{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("alert channel (%s) not found", lcuuid))
+	}
+	channelCreate := model.AlertChannelCreate{
+		Name: channel.Name,
+		Type: channel.Type,
+	}
+	if channel.Config != "" {
+		json.Unmarshal([]byte(channel.Config), &channelCreate.Config)
+	}
+	// CONFIG 整体替换，避免切换类型后残留其他类型的配置项
+	if _, ok := patchMap["CONFIG"]; ok {
+		channelCreate.Config = nil
+	}
+	if err := patchPolicy(&channelCreate, patchMap); err != nil {
+		return model.AlertChannel{}, err
+	}
+	channelConfig, err := validateAlertChannel(&channelCreate)
+	if err != nil {
+		return model.AlertChannel{}, err
+	}
+	if err := checkPolicyNameExist(&mysql.AlertChannel{}, "alert channel", channelCreate.Name, channel.ID); err != nil {
+		return model.AlertChannel{}, err
+	}
+
+	channel.Name = channelCreate.Name
+	channel.Type = channelCreate.Type
+	channel.Config = channelConfig
+	if err := mysql.Db.Save(&channel).Error; err != nil {
+		return model.AlertChannel{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("update alert channel (%s) type %s", channel.Name, channel.Type)
+	return getAlertChannel(channel.Lcuuid)
+}
+
+// DeleteAlertChannel 通知渠道被告警规则引用时不允许删除
+func DeleteAlertChannel(lcuuid string) (map[string]string, error) {
+	var channel mysql.AlertChannel
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&channel); ret.Error != nil {
+		return map[string]string{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("alert channel (%s) not found", lcuuid))
+	}
+	var rules []mysql.AlertRule
+	if err := mysql.Db.Where("FIND_IN_SET(?, channel_lcuuids)", lcuuid).Find(&rules).Error; err != nil {
+		return map[string]string{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	if len(rules) > 0 {
+		ruleNames := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			ruleNames = append(ruleNames, rule.Name)
+		}
+		return map[string]string{}, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf(
+			"alert channel (%s) is used by alert rules (%s)", channel.Name, strings.Join(ruleNames, ", ")))
+	}
+
+	log.Infof("delete alert channel (%s)", channel.Name)
+	if err := mysql.Db.Delete(&channel).Error; err != nil {
+		return map[string]string{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	return map[string]string{"LCUUID": lcuuid}, nil
+}
+
+// TestAlertChannel 向通知渠道发送一条测试告警
+func TestAlertChannel(lcuuid string, cfg *config.ControllerConfig) (model.AlertChannel, error) {
+	var channel mysql.AlertChannel
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&channel); ret.Error != nil {
+		return model.AlertChannel{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("alert channel (%s) not found", lcuuid))
+	}
+	timeout := time.Duration(cfg.AlertCfg.NotifyTimeout) * time.Second
+	if err := alert.SendTestNotification(context.Background(), &channel, timeout); err != nil {
+		return model.AlertChannel{}, NewError(httpcommon.SERVER_ERROR, fmt.Sprintf("send test notification failed: %s", err.Error()))
+	}
+	return getAlertChannel(lcuuid)
+}
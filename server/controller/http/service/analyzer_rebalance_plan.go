@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service/rebalance"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	"github.com/deepflowio/deepflow/server/controller/monitor/config"
+	"github.com/deepflowio/deepflow/server/controller/trisolaris/refresh"
+)
+
+// 已执行、已丢弃及过期的计划保留时长
+const analyzerRebalancePlanRetention = 7 * 24 * time.Hour
+
+func GetAnalyzerRebalancePlans(filter map[string]interface{}, cfg config.IngesterLoadBalancingStrategy) ([]*model.AnalyzerRebalancePlan, error) {
+	if err := expireAnalyzerRebalancePlans(cfg.CostAware); err != nil {
+		return nil, err
+	}
+
+	db := mysql.Db
+	if lcuuid, ok := filter["lcuuid"]; ok {
+		db = db.Where("lcuuid = ?", lcuuid)
+	}
+	if state, ok := filter["state"]; ok {
+		db = db.Where("state = ?", state)
+	}
+	var plans []mysql.AnalyzerRebalancePlan
+	if err := db.Order("id DESC").Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	response := make([]*model.AnalyzerRebalancePlan, 0, len(plans))
+	for _, plan := range plans {
+		response = append(response, convertAnalyzerRebalancePlan(plan))
+	}
+	return response, nil
+}
+
+// CreateAnalyzerRebalancePlan 生成并保存均衡计划，新计划生成后同一来源之前待执行的计划过期，
+// 定时生成的计划不需要迁移采集器时不保存，返回 nil
+func CreateAnalyzerRebalancePlan(create model.AnalyzerRebalancePlanCreate, source string, cfg config.IngesterLoadBalancingStrategy) (*model.AnalyzerRebalancePlan, error) {
+	if cfg.Algorithm != common.ANALYZER_ALLOC_BY_INGESTED_DATA {
+		return nil, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf(
+			"rebalance plan only supports algorithm %s, current algorithm is %s", common.ANALYZER_ALLOC_BY_INGESTED_DATA, cfg.Algorithm))
+	}
+	dataDuration := create.DataDuration
+	if dataDuration <= 0 {
+		dataDuration = cfg.DataDuration
+	}
+	maxMoves := create.MaxMovesPerRound
+	if maxMoves <= 0 {
+		maxMoves = cfg.CostAware.MaxMovesPerRound
+	}
+
+	moves, loads, err := rebalance.NewAnalyzerInfo().RebalanceAnalyzerByCost(cfg.CostAware, dataDuration, maxMoves)
+	if err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	// 手动生成的计划需要人工审核，不能被定时生成的计划覆盖
+	err = mysql.Db.Model(&mysql.AnalyzerRebalancePlan{}).Where("state = ? AND source = ?", rebalance.PLAN_STATE_PENDING, source).
+		Update("state", rebalance.PLAN_STATE_EXPIRED).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(moves) == 0 && source == rebalance.PLAN_SOURCE_SCHEDULE {
+		return nil, nil
+	}
+
+	movesJson, _ := json.Marshal(moves)
+	loadsJson, _ := json.Marshal(loads)
+	plan := mysql.AnalyzerRebalancePlan{
+		State:              rebalance.PLAN_STATE_PENDING,
+		Source:             source,
+		TotalSwitchVTapNum: len(moves),
+		Moves:              string(movesJson),
+		Details:            string(loadsJson),
+		Lcuuid:             uuid.New().String(),
+	}
+
+	if err = mysql.Db.Create(&plan).Error; err != nil {
+		return nil, err
+	}
+	mysql.Db.Where("state != ? AND created_at < ?", rebalance.PLAN_STATE_PENDING, time.Now().Add(-analyzerRebalancePlanRetention)).
+		Delete(&mysql.AnalyzerRebalancePlan{})
+
+	rebalance.SendRebalanceEvent(
+		fmt.Sprintf("rebalance-plan-%d", plan.ID),
+		fmt.Sprintf("analyzer rebalance plan(%d) created by %s, %d agents to switch", plan.ID, source, plan.TotalSwitchVTapNum),
+	)
+	return GetAnalyzerRebalancePlan(plan.Lcuuid, cfg)
+}
+
+func GetAnalyzerRebalancePlan(lcuuid string, cfg config.IngesterLoadBalancingStrategy) (*model.AnalyzerRebalancePlan, error) {
+	plans, err := GetAnalyzerRebalancePlans(map[string]interface{}{"lcuuid": lcuuid}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return nil, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("rebalance plan (%s) not found", lcuuid))
+	}
+	return plans[0], nil
+}
+
+// ApplyAnalyzerRebalancePlan 执行待执行的均衡计划，执行结果记录在计划的 MOVES 中
+func ApplyAnalyzerRebalancePlan(lcuuid string, cfg config.IngesterLoadBalancingStrategy) (*model.AnalyzerRebalancePlan, error) {
+	if err := expireAnalyzerRebalancePlans(cfg.CostAware); err != nil {
+		return nil, err
+	}
+	var plan mysql.AnalyzerRebalancePlan
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&plan); ret.Error != nil {
+		return nil, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("rebalance plan (%s) not found", lcuuid))
+	}
+	if plan.State != rebalance.PLAN_STATE_PENDING {
+		return nil, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf("rebalance plan (%d) is not pending", plan.ID))
+	}
+	// 先更新状态，避免同一计划被并发执行
+	ret := mysql.Db.Model(&mysql.AnalyzerRebalancePlan{}).Where("id = ? AND state = ?", plan.ID, rebalance.PLAN_STATE_PENDING).
+		Update("state", rebalance.PLAN_STATE_APPLIED)
+	if ret.Error != nil {
+		return nil, ret.Error
+	}
+	if ret.RowsAffected == 0 {
+		return nil, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf("rebalance plan (%d) is not pending", plan.ID))
+	}
+
+	var moves []*model.AnalyzerRebalanceMove
+	if plan.Moves != "" {
+		if err := json.Unmarshal([]byte(plan.Moves), &moves); err != nil {
+			return nil, err
+		}
+	}
+	appliedNum := rebalance.ApplyRebalanceMoves(fmt.Sprintf("%d", plan.ID), moves)
+	movesJson, _ := json.Marshal(moves)
+	if err := mysql.Db.Model(&plan).Update("moves", string(movesJson)).Error; err != nil {
+		return nil, err
+	}
+	if appliedNum > 0 {
+		refresh.RefreshCache([]common.DataChanged{common.DATA_CHANGED_VTAP})
+	}
+	log.Infof("apply analyzer rebalance plan(%d), %d of %d agents switched", plan.ID, appliedNum, len(moves))
+	return GetAnalyzerRebalancePlan(lcuuid, cfg)
+}
+
+func DiscardAnalyzerRebalancePlan(lcuuid string, cfg config.IngesterLoadBalancingStrategy) (*model.AnalyzerRebalancePlan, error) {
+	var plan mysql.AnalyzerRebalancePlan
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&plan); ret.Error != nil {
+		return nil, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("rebalance plan (%s) not found", lcuuid))
+	}
+	if plan.State != rebalance.PLAN_STATE_PENDING {
+		return nil, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf("rebalance plan (%d) is not pending", plan.ID))
+	}
+	if err := mysql.Db.Model(&plan).Update("state", rebalance.PLAN_STATE_DISCARDED).Error; err != nil {
+		return nil, err
+	}
+	return GetAnalyzerRebalancePlan(lcuuid, cfg)
+}
+
+func expireAnalyzerRebalancePlans(cfg config.CostAwareRebalance) error {
+	if cfg.PlanExpireTime <= 0 {
+		return nil
+	}
+	return mysql.Db.Model(&mysql.AnalyzerRebalancePlan{}).
+		Where("state = ? AND created_at < ?", rebalance.PLAN_STATE_PENDING, time.Now().Add(-time.Duration(cfg.PlanExpireTime)*time.Second)).
+		Update("state", rebalance.PLAN_STATE_EXPIRED).Error
+}
+
+func convertAnalyzerRebalancePlan(plan mysql.AnalyzerRebalancePlan) *model.AnalyzerRebalancePlan {
+	resp := &model.AnalyzerRebalancePlan{
+		ID:                 plan.ID,
+		State:              plan.State,
+		Source:             plan.Source,
+		TotalSwitchVTapNum: plan.TotalSwitchVTapNum,
+		Moves:              []*model.AnalyzerRebalanceMove{},
+		Details:            []*model.AnalyzerRebalanceLoad{},
+		CreatedAt:          plan.CreatedAt.Format(common.GO_BIRTHDAY),
+		UpdatedAt:          plan.UpdatedAt.Format(common.GO_BIRTHDAY),
+		Lcuuid:             plan.Lcuuid,
+	}
+	if plan.Moves != "" {
+		if err := json.Unmarshal([]byte(plan.Moves), &resp.Moves); err != nil {
+			log.Errorf("unmarshal rebalance plan(%d) moves failed: %s", plan.ID, err)
+		}
+	}
+	if plan.Details != "" {
+		if err := json.Unmarshal([]byte(plan.Details), &resp.Details); err != nil {
+			log.Errorf("unmarshal rebalance plan(%d) details failed: %s", plan.ID, err)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+)
+
+// CheckOrgExist 校验组织是否存在
+func CheckOrgExist(orgID int) (*mysql.Org, error) {
+	var org mysql.Org
+	if ret := mysql.Db.Where("id = ?", orgID).First(&org); ret.Error != nil {
+		return nil, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("org (%d) not found", orgID))
+	}
+	return &org, nil
+}
+
+// CheckOrgQuota 校验组织内资源数量是否超过配额，配额为 0 时不限制。
+// 需与资源的创建在同一事务中调用，组织记录加锁后并发的创建请求依次校验，避免超过配额
+func CheckOrgQuota(tx *gorm.DB, orgID int, model interface{}, resourceName string) error {
+	var org mysql.Org
+	if ret := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orgID).First(&org); ret.Error != nil {
+		return NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("org (%d) not found", orgID))
+	}
+	var limit int
+	switch model.(type) {
+	case *mysql.VTap:
+		limit = org.MaxVTapNum
+	case *mysql.VTapGroup:
+		limit = org.MaxVTapGroupNum
+	case *mysql.Domain:
+		limit = org.MaxDomainNum
+	}
+	if limit <= 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(model).Where("org_id = ?", orgID).Count(&count).Error; err != nil {
+		return NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	if int(count) >= limit {
+		return NewError(
+			httpcommon.RESOURCE_NUM_EXCEEDED,
+			fmt.Sprintf("%s count exceeds org (%s) quota (limit %d)", resourceName, org.Name, limit),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+)
+
+func TestCheckOrgQuota(t *testing.T) {
+	db, err := gorm.Open(
+		sqlite.Open("file:org_quota?mode=memory&cache=shared"),
+		&gorm.Config{NamingStrategy: schema.NamingStrategy{SingularTable: true}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&mysql.Org{}, &mysql.VTapGroup{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&mysql.Org{ID: 2, Name: "org2", MaxVTapGroupNum: 1})
+
+	create := func(name string) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := CheckOrgQuota(tx, 2, &mysql.VTapGroup{}, "vtap_group"); err != nil {
+				return err
+			}
+			return tx.Create(&mysql.VTapGroup{Name: name, ShortUUID: name, Lcuuid: name, OrgID: 2}).Error
+		})
+	}
+	if err := create("g-1"); err != nil {
+		t.Fatalf("create first vtap_group failed: %v", err)
+	}
+	err = create("g-2")
+	if serviceErr, ok := err.(*ServiceError); !ok || serviceErr.Status != httpcommon.RESOURCE_NUM_EXCEEDED {
+		t.Fatalf("create second vtap_group = %v, want %s", err, httpcommon.RESOURCE_NUM_EXCEEDED)
+	}
+	var count int64
+	db.Model(&mysql.VTapGroup{}).Where("org_id = ?", 2).Count(&count)
+	if count != 1 {
+		t.Errorf("vtap_group count = %d, want 1", count)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return CheckOrgQuota(tx, 3, &mysql.VTapGroup{}, "vtap_group")
+	})
+	if serviceErr, ok := err.(*ServiceError); !ok || serviceErr.Status != httpcommon.RESOURCE_NOT_FOUND {
+		t.Errorf("check quota of missing org = %v, want %s", err, httpcommon.RESOURCE_NOT_FOUND)
+	}
+}
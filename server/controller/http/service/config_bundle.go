@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin/binding"
+	"gopkg.in/yaml.v2"
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/http/service/resource"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+const (
+	CONFIG_BUNDLE_KIND_AGENT_GROUP                = "AgentGroup"
+	CONFIG_BUNDLE_KIND_AGENT_GROUP_CONFIG         = "AgentGroupConfig"
+	CONFIG_BUNDLE_KIND_DOMAIN                     = "Domain"
+	CONFIG_BUNDLE_KIND_SUB_DOMAIN                 = "SubDomain"
+	CONFIG_BUNDLE_KIND_PLUGIN                     = "Plugin"
+	CONFIG_BUNDLE_KIND_DOMAIN_ADDITIONAL_RESOURCE = "DomainAdditionalResource"
+)
+
+// configBundleContext 记录 bundle 中声明的对象，用于校验对象间的引用关系，
+// 被引用的对象不存在于数据库时，只要在同一个 bundle 中声明即可
+type configBundleContext struct {
+	orgID        int
+	agentGroups  map[string]string // short uuid -> name
+	domainNames  map[string]bool
+	objectsCount map[string]int
+}
+
+// ValidateConfigBundle 按照各资源创建/更新接口的规则校验 bundle 中的对象，不修改数据库，
+// 返回每个对象的校验结果
+func ValidateConfigBundle(orgID int, bundle model.ConfigBundle) []model.ConfigBundleValidation {
+	ctx := &configBundleContext{
+		orgID:        orgID,
+		agentGroups:  make(map[string]string),
+		domainNames:  make(map[string]bool),
+		objectsCount: make(map[string]int),
+	}
+	for _, object := range bundle.Objects {
+		ctx.objectsCount[object.Kind+"/"+object.Name]++
+		switch object.Kind {
+		case CONFIG_BUNDLE_KIND_AGENT_GROUP:
+			if groupID, ok := object.Spec["GROUP_ID"].(string); ok && groupID != "" {
+				ctx.agentGroups[groupID] = object.Name
+			}
+		case CONFIG_BUNDLE_KIND_DOMAIN:
+			ctx.domainNames[object.Name] = true
+		}
+	}
+
+	result := make([]model.ConfigBundleValidation, 0, len(bundle.Objects))
+	for _, object := range bundle.Objects {
+		validation := model.ConfigBundleValidation{Kind: object.Kind, Name: object.Name}
+		var err error
+		if ctx.objectsCount[object.Kind+"/"+object.Name] > 1 {
+			err = fmt.Errorf("duplicate object %s/%s", object.Kind, object.Name)
+		} else {
+			err = ctx.validate(object)
+		}
+		if err != nil {
+			validation.Error = err.Error()
+		}
+		result = append(result, validation)
+	}
+	return result
+}
+
+func (ctx *configBundleContext) validate(object model.ConfigBundleObject) error {
+	switch object.Kind {
+	case CONFIG_BUNDLE_KIND_AGENT_GROUP:
+		return ctx.validateAgentGroup(object)
+	case CONFIG_BUNDLE_KIND_AGENT_GROUP_CONFIG:
+		return ctx.validateAgentGroupConfig(object)
+	case CONFIG_BUNDLE_KIND_DOMAIN:
+		return ctx.validateDomain(object)
+	case CONFIG_BUNDLE_KIND_SUB_DOMAIN:
+		return ctx.validateSubDomain(object)
+	case CONFIG_BUNDLE_KIND_PLUGIN:
+		return ctx.validatePlugin(object)
+	case CONFIG_BUNDLE_KIND_DOMAIN_ADDITIONAL_RESOURCE:
+		return ctx.validateDomainAdditionalResource(object)
+	default:
+		return fmt.Errorf("unknown kind %s", object.Kind)
+	}
+}
+
+func (ctx *configBundleContext) orgDomainLcuuids() *gorm.DB {
+	return mysql.Db.Model(&mysql.Domain{}).Select("lcuuid").Where("org_id = ?", ctx.orgID)
+}
+
+// convertSpec 将 SPEC 转换为接口请求体结构，并按照 binding tag 校验
+func convertSpec(spec map[string]interface{}, obj interface{}) error {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(b, obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+func (ctx *configBundleContext) validateAgentGroup(object model.ConfigBundleObject) error {
+	var groupCreate model.VtapGroupCreate
+	if err := convertSpec(object.Spec, &groupCreate); err != nil {
+		return err
+	}
+	if groupCreate.Name != "" && groupCreate.Name != object.Name {
+		return fmt.Errorf("NAME (%s) conflicts with object name (%s)", groupCreate.Name, object.Name)
+	}
+	if groupCreate.GroupID == "" {
+		return nil
+	}
+	if !IsVtapGroupShortUUID(groupCreate.GroupID) {
+		return fmt.Errorf("GROUP_ID (%s) invalid, requires %s prefix, number and letter length %d, such as g-1yhIguXABC",
+			groupCreate.GroupID, VTAP_GROUP_SHORT_UUID_PREFIX, common.SHORT_UUID_LENGTH)
+	}
+	// 已存在的采集器组不能修改 id，也不能与其它采集器组的 id 冲突
+	var vtapGroup mysql.VTapGroup
+	if err := mysql.Db.Where("short_uuid = ? AND org_id = ?", groupCreate.GroupID, ctx.orgID).Find(&vtapGroup).Error; err != nil {
+		return err
+	}
+	if vtapGroup.ID != 0 && vtapGroup.Name != object.Name {
+		return fmt.Errorf("GROUP_ID (%s) already used by agent group (%s)", groupCreate.GroupID, vtapGroup.Name)
+	}
+	vtapGroup = mysql.VTapGroup{}
+	if err := mysql.Db.Where("name = ? AND org_id = ?", object.Name, ctx.orgID).Find(&vtapGroup).Error; err != nil {
+		return err
+	}
+	if vtapGroup.ID != 0 && vtapGroup.ShortUUID != groupCreate.GroupID {
+		return fmt.Errorf("agent group (%s) already exists with id (%s), id can not be changed", object.Name, vtapGroup.ShortUUID)
+	}
+	return nil
+}
+
+func (ctx *configBundleContext) validateAgentGroupConfig(object model.ConfigBundleObject) error {
+	if _, ok := ctx.agentGroups[object.Name]; !ok {
+		var count int64
+		mysql.Db.Model(&mysql.VTapGroup{}).Where("short_uuid = ? AND org_id = ?", object.Name, ctx.orgID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("agent group (%s) not found", object.Name)
+		}
+	}
+	b, err := yaml.Marshal(object.Spec)
+	if err != nil {
+		return err
+	}
+	// 使用严格模式以发现拼写错误的配置项
+	config := &model.VTapGroupConfiguration{}
+	if err = yaml.UnmarshalStrict(b, config); err != nil {
+		return err
+	}
+	if config.VTapGroupID != nil && *config.VTapGroupID != object.Name {
+		return fmt.Errorf("vtap_group_id (%s) conflicts with object name (%s)", *config.VTapGroupID, object.Name)
+	}
+	return nil
+}
+
+func (ctx *configBundleContext) validateDomain(object model.ConfigBundleObject) error {
+	spec := make(map[string]interface{}, len(object.Spec)+1)
+	for k, v := range object.Spec {
+		spec[k] = v
+	}
+	spec["NAME"] = object.Name
+	var domainCreate model.DomainCreate
+	if err := convertSpec(spec, &domainCreate); err != nil {
+		return err
+	}
+
+	// 子域没有组织字段，通过所属的域归属到组织
+	var count int64
+	mysql.Db.Model(&mysql.SubDomain{}).Where("name = ? AND domain IN (?)", object.Name, ctx.orgDomainLcuuids()).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("sub_domain (%s) already exist", object.Name)
+	}
+	var domain mysql.Domain
+	if err := mysql.Db.Where("name = ? AND org_id = ?", object.Name, ctx.orgID).Find(&domain).Error; err != nil {
+		return err
+	}
+	if domain.ID != 0 && domain.Type != domainCreate.Type {
+		return fmt.Errorf("domain (%s) already exists with type (%d), type can not be changed", object.Name, domain.Type)
+	}
+	if domainCreate.KubernetesClusterID != "" {
+		mysql.Db.Model(&mysql.Domain{}).Where("cluster_id = ? AND name != ? AND org_id = ?", domainCreate.KubernetesClusterID, object.Name, ctx.orgID).Count(&count)
+		if count > 0 {
+			return fmt.Errorf("domain cluster_id (%s) already exist", domainCreate.KubernetesClusterID)
+		}
+	}
+	return nil
+}
+
+func (ctx *configBundleContext) validateSubDomain(object model.ConfigBundleObject) error {
+	spec := make(map[string]interface{}, len(object.Spec)+1)
+	for k, v := range object.Spec {
+		spec[k] = v
+	}
+	spec["NAME"] = object.Name
+	var subDomainCreate model.SubDomainCreate
+	if err := convertSpec(spec, &subDomainCreate); err != nil {
+		return err
+	}
+	if _, ok := subDomainCreate.Config["vpc_uuid"].(string); !ok {
+		return fmt.Errorf("CONFIG.vpc_uuid is required")
+	}
+	// DOMAIN 可以是已存在 domain 的 lcuuid，或者 bundle 中声明的 domain 名称
+	if ctx.domainNames[subDomainCreate.Domain] {
+		return nil
+	}
+	var count int64
+	mysql.Db.Model(&mysql.Domain{}).Where("(lcuuid = ? OR name = ?) AND org_id = ?", subDomainCreate.Domain, subDomainCreate.Domain, ctx.orgID).Count(&count)
+	if count == 0 {
+		return fmt.Errorf("domain (%s) not found", subDomainCreate.Domain)
+	}
+	return nil
+}
+
+func (ctx *configBundleContext) validatePlugin(object model.ConfigBundleObject) error {
+	pluginType, ok := object.Spec["TYPE"].(float64)
+	if !ok {
+		return fmt.Errorf("TYPE is required")
+	}
+	if _, ok := common.PluginTypeName[int(pluginType)]; !ok {
+		return fmt.Errorf("plugin type (%v) not supported", pluginType)
+	}
+	return nil
+}
+
+func (ctx *configBundleContext) validateDomainAdditionalResource(object model.ConfigBundleObject) error {
+	var additionalResource model.AdditionalResource
+	if err := convertSpec(object.Spec, &additionalResource); err != nil {
+		return err
+	}
+	return resource.CheckDomainAddtionalResource(additionalResource)
+}
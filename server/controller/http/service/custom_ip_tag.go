@@ -0,0 +1,543 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/election"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+const (
+	CUSTOM_IP_TAG_MAX_ENTRIES           = 100000
+	CUSTOM_IP_TAG_MAX_KEYS              = 64
+	CUSTOM_IP_TAG_MAX_VALUE_LENGTH      = 256
+	CUSTOM_IP_TAG_MAX_SOURCE_SIZE       = 64 << 20
+	CUSTOM_IP_TAG_DEFAULT_SYNC_INTERVAL = 3600
+	CUSTOM_IP_TAG_MIN_SYNC_INTERVAL     = 60
+	CUSTOM_IP_TAG_SYNC_TIMEOUT          = 30 * time.Second
+
+	// CSV 表头中的保留列，ip 列为 IP 或 CIDR，l3_epc_id 列可选，其余列均为标签
+	CUSTOM_IP_TAG_COLUMN_IP   = "ip"
+	CUSTOM_IP_TAG_COLUMN_CIDR = "cidr"
+	CUSTOM_IP_TAG_COLUMN_VPC  = "l3_epc_id"
+)
+
+// 标签名用于 SQL 中的 tag.<key>_0/tag.<key>_1，只允许字母、数字和下划线
+var customIPTagKeyRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,63}$`)
+
+// normalizeCIDR 将 IP 或 CIDR 统一为网络地址形式的前缀，IP 视为 /32 或 /128
+func normalizeCIDR(s string) (string, error) {
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid cidr (%s)", s)
+		}
+		return ipNet.String(), nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return "", fmt.Errorf("invalid ip (%s)", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String() + "/32", nil
+	}
+	return ip.String() + "/128", nil
+}
+
+// parseCustomIPTagCSV 解析自定义 IP 标签 CSV，返回标签条目及按表头顺序排列的标签名
+//
+//	ip,l3_epc_id,team,env
+//	10.1.0.0/16,,payment,prod
+//	192.168.1.10,3,order,test
+func parseCustomIPTagCSV(r io.Reader) ([]model.CustomIPTag, []string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("csv header is required")
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	ipIndex, vpcIndex := -1, -1
+	keys := []string{}
+	keyIndexes := []int{}
+	columns := make(map[string]bool, len(header))
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if columns[strings.ToLower(column)] {
+			return nil, nil, fmt.Errorf("duplicate column (%s)", column)
+		}
+		columns[strings.ToLower(column)] = true
+		switch strings.ToLower(column) {
+		case CUSTOM_IP_TAG_COLUMN_IP, CUSTOM_IP_TAG_COLUMN_CIDR:
+			if ipIndex >= 0 {
+				return nil, nil, errors.New("only one of ip and cidr column is allowed")
+			}
+			ipIndex = i
+		case CUSTOM_IP_TAG_COLUMN_VPC:
+			vpcIndex = i
+		default:
+			if !customIPTagKeyRegexp.MatchString(column) {
+				return nil, nil, fmt.Errorf("invalid tag key (%s), only letters, digits and _ are allowed", column)
+			}
+			keys = append(keys, column)
+			keyIndexes = append(keyIndexes, i)
+		}
+	}
+	if ipIndex < 0 {
+		return nil, nil, errors.New("ip or cidr column is required")
+	}
+	if len(keys) == 0 {
+		return nil, nil, errors.New("at least one tag column is required")
+	}
+	if len(keys) > CUSTOM_IP_TAG_MAX_KEYS {
+		return nil, nil, fmt.Errorf("tag keys exceed the limit (%d)", CUSTOM_IP_TAG_MAX_KEYS)
+	}
+
+	tags := []model.CustomIPTag{}
+	existed := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		cidr, err := normalizeCIDR(strings.TrimSpace(record[ipIndex]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		vpcID := 0
+		if vpcIndex >= 0 && strings.TrimSpace(record[vpcIndex]) != "" {
+			vpcID, err = strconv.Atoi(strings.TrimSpace(record[vpcIndex]))
+			if err != nil || vpcID < 0 {
+				return nil, nil, fmt.Errorf("line %d: invalid l3_epc_id (%s)", line, record[vpcIndex])
+			}
+		}
+		entryKey := fmt.Sprintf("%d-%s", vpcID, cidr)
+		if existedLine, ok := existed[entryKey]; ok {
+			return nil, nil, fmt.Errorf("line %d: duplicate entry of line %d (l3_epc_id: %d, cidr: %s)", line, existedLine, vpcID, cidr)
+		}
+		existed[entryKey] = line
+
+		tag := model.CustomIPTag{L3EPCID: vpcID, CIDR: cidr, Tags: make(map[string]string)}
+		for i, key := range keys {
+			value := strings.TrimSpace(record[keyIndexes[i]])
+			if value == "" {
+				continue
+			}
+			if len(value) > CUSTOM_IP_TAG_MAX_VALUE_LENGTH {
+				return nil, nil, fmt.Errorf("line %d: value of %s exceeds the max length (%d)", line, key, CUSTOM_IP_TAG_MAX_VALUE_LENGTH)
+			}
+			tag.Tags[key] = value
+		}
+		if len(tag.Tags) == 0 {
+			continue
+		}
+		tags = append(tags, tag)
+		if len(tags) > CUSTOM_IP_TAG_MAX_ENTRIES {
+			return nil, nil, fmt.Errorf("entries exceed the limit (%d)", CUSTOM_IP_TAG_MAX_ENTRIES)
+		}
+	}
+	return tags, keys, nil
+}
+
+func validateCustomIPTagSet(tagSet *model.CustomIPTagSetCreate) error {
+	switch tagSet.SourceType {
+	case common.CUSTOM_IP_TAG_SOURCE_TYPE_UPLOAD:
+		tagSet.SourceURL = ""
+		tagSet.SyncInterval = 0
+	case common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP:
+		sourceURL, err := url.Parse(tagSet.SourceURL)
+		if err != nil || (sourceURL.Scheme != "http" && sourceURL.Scheme != "https") || sourceURL.Host == "" {
+			return fmt.Errorf("invalid source url (%s), http or https url is required", tagSet.SourceURL)
+		}
+		if tagSet.SyncInterval == 0 {
+			tagSet.SyncInterval = CUSTOM_IP_TAG_DEFAULT_SYNC_INTERVAL
+		}
+		if tagSet.SyncInterval < CUSTOM_IP_TAG_MIN_SYNC_INTERVAL {
+			return fmt.Errorf("sync interval (%d) should be at least %ds", tagSet.SyncInterval, CUSTOM_IP_TAG_MIN_SYNC_INTERVAL)
+		}
+	default:
+		return fmt.Errorf("invalid source type (%d)", tagSet.SourceType)
+	}
+	return nil
+}
+
+func GetCustomIPTagSets(filter map[string]interface{}) ([]model.CustomIPTagSet, error) {
+	var tagSets []mysql.CustomIPTagSet
+	db := mysql.Db
+	for _, param := range []string{"lcuuid", "name", "id"} {
+		if value, ok := filter[param]; ok {
+			db = db.Where(fmt.Sprintf("%s = ?", param), value)
+		}
+	}
+	if err := db.Order("id").Find(&tagSets).Error; err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+
+	var entryCounts []struct {
+		TagSetID int
+		Count    int
+	}
+	if err := mysql.Db.Model(&mysql.CustomIPTag{}).Select("tag_set_id, COUNT(*) AS count").Group("tag_set_id").Scan(&entryCounts).Error; err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	tagSetIDToEntryCount := make(map[int]int, len(entryCounts))
+	for _, entryCount := range entryCounts {
+		tagSetIDToEntryCount[entryCount.TagSetID] = entryCount.Count
+	}
+
+	response := make([]model.CustomIPTagSet, 0, len(tagSets))
+	for _, tagSet := range tagSets {
+		tagKeys := []string{}
+		if tagSet.TagKeys != "" {
+			tagKeys = strings.Split(tagSet.TagKeys, ",")
+		}
+		syncedAt := ""
+		if tagSet.SyncedAt != nil {
+			syncedAt = tagSet.SyncedAt.Format(common.GO_BIRTHDAY)
+		}
+		response = append(response, model.CustomIPTagSet{
+			ID:           tagSet.ID,
+			Name:         tagSet.Name,
+			SourceType:   tagSet.SourceType,
+			SourceURL:    tagSet.SourceURL,
+			SyncInterval: tagSet.SyncInterval,
+			TagKeys:      tagKeys,
+			EntryCount:   tagSetIDToEntryCount[tagSet.ID],
+			SyncedAt:     syncedAt,
+			SyncError:    tagSet.SyncError,
+			CreatedAt:    tagSet.CreatedAt.Format(common.GO_BIRTHDAY),
+			UpdatedAt:    tagSet.UpdatedAt.Format(common.GO_BIRTHDAY),
+			Lcuuid:       tagSet.Lcuuid,
+		})
+	}
+	return response, nil
+}
+
+func getCustomIPTagSet(lcuuid string) (model.CustomIPTagSet, error) {
+	tagSets, err := GetCustomIPTagSets(map[string]interface{}{"lcuuid": lcuuid})
+	if err != nil {
+		return model.CustomIPTagSet{}, err
+	}
+	if len(tagSets) == 0 {
+		return model.CustomIPTagSet{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("custom ip tag set (%s) not found", lcuuid))
+	}
+	return tagSets[0], nil
+}
+
+func CreateCustomIPTagSet(tagSetCreate model.CustomIPTagSetCreate) (model.CustomIPTagSet, error) {
+	if err := validateCustomIPTagSet(&tagSetCreate); err != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err := checkPolicyNameExist(&mysql.CustomIPTagSet{}, "custom ip tag set", tagSetCreate.Name, 0); err != nil {
+		return model.CustomIPTagSet{}, err
+	}
+
+	tagSet := mysql.CustomIPTagSet{
+		Name:         tagSetCreate.Name,
+		SourceType:   tagSetCreate.SourceType,
+		SourceURL:    tagSetCreate.SourceURL,
+		SyncInterval: tagSetCreate.SyncInterval,
+		Lcuuid:       uuid.New().String(),
+	}
+	if err := mysql.Db.Create(&tagSet).Error; err != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("create custom ip tag set (%s) %+v", tagSet.Name, tagSetCreate)
+	// http 数据源创建后立即同步一次，失败时记录在 sync_error 中，由定时任务重试
+	if tagSet.SourceType == common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP {
+		syncCustomIPTagSet(&tagSet)
+	}
+	return getCustomIPTagSet(tagSet.Lcuuid)
+}
+
+func UpdateCustomIPTagSet(lcuuid string, patchMap map[string]interface{}) (model.CustomIPTagSet, error) {
+	var tagSet mysql.CustomIPTagSet
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&tagSet); ret.Error != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("custom ip tag set (%s) not found", lcuuid))
+	}
+	tagSetCreate := model.CustomIPTagSetCreate{
+		Name:         tagSet.Name,
+		SourceType:   tagSet.SourceType,
+		SourceURL:    tagSet.SourceURL,
+		SyncInterval: tagSet.SyncInterval,
+	}
+	if err := patchPolicy(&tagSetCreate, patchMap); err != nil {
+		return model.CustomIPTagSet{}, err
+	}
+	if err := validateCustomIPTagSet(&tagSetCreate); err != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err := checkPolicyNameExist(&mysql.CustomIPTagSet{}, "custom ip tag set", tagSetCreate.Name, tagSet.ID); err != nil {
+		return model.CustomIPTagSet{}, err
+	}
+
+	sourceChanged := tagSet.SourceType != tagSetCreate.SourceType || tagSet.SourceURL != tagSetCreate.SourceURL
+	tagSet.Name = tagSetCreate.Name
+	tagSet.SourceType = tagSetCreate.SourceType
+	tagSet.SourceURL = tagSetCreate.SourceURL
+	tagSet.SyncInterval = tagSetCreate.SyncInterval
+	if sourceChanged {
+		tagSet.SyncedAt = nil
+		tagSet.SyncError = ""
+	}
+	if err := mysql.Db.Save(&tagSet).Error; err != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("update custom ip tag set (%s) config %v", tagSet.Name, patchMap)
+	if sourceChanged && tagSet.SourceType == common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP {
+		syncCustomIPTagSet(&tagSet)
+	}
+	return getCustomIPTagSet(tagSet.Lcuuid)
+}
+
+func DeleteCustomIPTagSet(lcuuid string) (map[string]string, error) {
+	var tagSet mysql.CustomIPTagSet
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&tagSet); ret.Error != nil {
+		return map[string]string{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("custom ip tag set (%s) not found", lcuuid))
+	}
+
+	log.Infof("delete custom ip tag set (%s)", tagSet.Name)
+	err := mysql.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_set_id = ?", tagSet.ID).Delete(&mysql.CustomIPTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tagSet).Error
+	})
+	if err != nil {
+		return map[string]string{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	return map[string]string{"LCUUID": lcuuid}, nil
+}
+
+func GetCustomIPTags(lcuuid string) ([]model.CustomIPTag, error) {
+	var tagSet mysql.CustomIPTagSet
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&tagSet); ret.Error != nil {
+		return nil, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("custom ip tag set (%s) not found", lcuuid))
+	}
+	var tags []mysql.CustomIPTag
+	if err := mysql.Db.Where("tag_set_id = ?", tagSet.ID).Order("id").Find(&tags).Error; err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	response := make([]model.CustomIPTag, 0, len(tags))
+	for _, tag := range tags {
+		tagMap := make(map[string]string)
+		if err := json.Unmarshal([]byte(tag.Tags), &tagMap); err != nil {
+			log.Warningf("unmarshal tags of custom ip tag (%d) failed: %s", tag.ID, err.Error())
+		}
+		response = append(response, model.CustomIPTag{
+			L3EPCID: tag.L3EPCID,
+			CIDR:    tag.CIDR,
+			Tags:    tagMap,
+		})
+	}
+	return response, nil
+}
+
+// UploadCustomIPTags 以上传的 CSV 全量替换标签集合中的条目
+func UploadCustomIPTags(lcuuid string, r io.Reader) (model.CustomIPTagSet, error) {
+	var tagSet mysql.CustomIPTagSet
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&tagSet); ret.Error != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("custom ip tag set (%s) not found", lcuuid))
+	}
+	if tagSet.SourceType != common.CUSTOM_IP_TAG_SOURCE_TYPE_UPLOAD {
+		return model.CustomIPTagSet{}, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf(
+			"custom ip tag set (%s) is synced from %s, upload is not allowed", tagSet.Name, tagSet.SourceURL))
+	}
+	tags, keys, err := readCustomIPTagCSV(r, "uploaded file")
+	if err != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err := replaceCustomIPTags(&tagSet, tags, keys); err != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("upload %d entries to custom ip tag set (%s)", len(tags), tagSet.Name)
+	return getCustomIPTagSet(tagSet.Lcuuid)
+}
+
+// SyncCustomIPTagSet 立即从 http 数据源同步标签集合
+func SyncCustomIPTagSet(lcuuid string) (model.CustomIPTagSet, error) {
+	var tagSet mysql.CustomIPTagSet
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&tagSet); ret.Error != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("custom ip tag set (%s) not found", lcuuid))
+	}
+	if tagSet.SourceType != common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP {
+		return model.CustomIPTagSet{}, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf(
+			"custom ip tag set (%s) has no http source", tagSet.Name))
+	}
+	if err := syncCustomIPTagSet(&tagSet); err != nil {
+		return model.CustomIPTagSet{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	return getCustomIPTagSet(tagSet.Lcuuid)
+}
+
+func replaceCustomIPTags(tagSet *mysql.CustomIPTagSet, tags []model.CustomIPTag, keys []string) error {
+	dbTags := make([]mysql.CustomIPTag, 0, len(tags))
+	for _, tag := range tags {
+		tagsJson, err := json.Marshal(tag.Tags)
+		if err != nil {
+			return err
+		}
+		dbTags = append(dbTags, mysql.CustomIPTag{
+			TagSetID: tagSet.ID,
+			L3EPCID:  tag.L3EPCID,
+			CIDR:     tag.CIDR,
+			Tags:     string(tagsJson),
+		})
+	}
+	now := time.Now()
+	tagSet.TagKeys = strings.Join(keys, ",")
+	tagSet.SyncedAt = &now
+	tagSet.SyncError = ""
+	return mysql.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_set_id = ?", tagSet.ID).Delete(&mysql.CustomIPTag{}).Error; err != nil {
+			return err
+		}
+		if len(dbTags) > 0 {
+			if err := tx.CreateInBatches(dbTags, 1000).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(tagSet).Updates(map[string]interface{}{
+			"tag_keys":   tagSet.TagKeys,
+			"synced_at":  tagSet.SyncedAt,
+			"sync_error": tagSet.SyncError,
+		}).Error
+	})
+}
+
+func fetchCustomIPTagCSV(sourceURL string) ([]model.CustomIPTag, []string, error) {
+	client := &http.Client{Timeout: CUSTOM_IP_TAG_SYNC_TIMEOUT}
+	resp, err := client.Get(sourceURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("get %s failed, status code: %d", sourceURL, resp.StatusCode)
+	}
+	return readCustomIPTagCSV(resp.Body, sourceURL)
+}
+
+// readCustomIPTagCSV 读取并解析 CSV，超过大小限制时报错而不是截断
+func readCustomIPTagCSV(r io.Reader, source string) ([]model.CustomIPTag, []string, error) {
+	body, err := io.ReadAll(io.LimitReader(r, CUSTOM_IP_TAG_MAX_SOURCE_SIZE+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) > CUSTOM_IP_TAG_MAX_SOURCE_SIZE {
+		return nil, nil, fmt.Errorf("content of %s exceeds the max size (%d bytes)", source, CUSTOM_IP_TAG_MAX_SOURCE_SIZE)
+	}
+	return parseCustomIPTagCSV(bytes.NewReader(body))
+}
+
+// syncCustomIPTagSet 拉取 http 数据源，失败时保留已有条目并记录错误
+func syncCustomIPTagSet(tagSet *mysql.CustomIPTagSet) error {
+	tags, keys, err := fetchCustomIPTagCSV(tagSet.SourceURL)
+	if err == nil {
+		err = replaceCustomIPTags(tagSet, tags, keys)
+	}
+	if err != nil {
+		log.Errorf("sync custom ip tag set (%s) from %s failed: %s", tagSet.Name, tagSet.SourceURL, err.Error())
+		tagSet.SyncError = err.Error()
+		mysql.Db.Model(tagSet).Update("sync_error", tagSet.SyncError)
+		return err
+	}
+	log.Infof("sync %d entries to custom ip tag set (%s) from %s", len(tags), tagSet.Name, tagSet.SourceURL)
+	return nil
+}
+
+// CustomIPTagSyncer 定时同步 http 数据源的自定义 IP 标签集合，仅在 master controller 运行
+type CustomIPTagSyncer struct {
+	pCtx   context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewCustomIPTagSyncer(ctx context.Context) *CustomIPTagSyncer {
+	return &CustomIPTagSyncer{pCtx: ctx}
+}
+
+func (s *CustomIPTagSyncer) Start() {
+	s.ctx, s.cancel = context.WithCancel(s.pCtx)
+	log.Info("custom ip tag syncer started")
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if election.CheckFencingToken() != nil {
+					continue
+				}
+				s.syncExpired()
+			}
+		}
+	}()
+}
+
+func (s *CustomIPTagSyncer) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	log.Info("custom ip tag syncer stopped")
+}
+
+func (s *CustomIPTagSyncer) syncExpired() {
+	var tagSets []mysql.CustomIPTagSet
+	if err := mysql.Db.Where("source_type = ?", common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP).Find(&tagSets).Error; err != nil {
+		log.Error(err)
+		return
+	}
+	now := time.Now()
+	for i := range tagSets {
+		tagSet := &tagSets[i]
+		if tagSet.SyncedAt != nil && now.Sub(*tagSet.SyncedAt) < time.Duration(tagSet.SyncInterval)*time.Second {
+			continue
+		}
+		syncCustomIPTagSet(tagSet)
+	}
+}
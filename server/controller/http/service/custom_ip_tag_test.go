@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+func TestNormalizeCIDR(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{s: "10.1.2.3", want: "10.1.2.3/32"},
+		{s: "10.1.2.3/16", want: "10.1.0.0/16"},
+		{s: "2001:db8::1", want: "2001:db8::1/128"},
+		{s: "2001:db8::1/32", want: "2001:db8::/32"},
+		{s: "10.1.2", wantErr: true},
+		{s: "10.1.2.3/33", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeCIDR(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeCIDR(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeCIDR(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseCustomIPTagCSV(t *testing.T) {
+	content := "\ufeffip, l3_epc_id, team, env\n" +
+		"# comment\n" +
+		"10.1.0.0/16,,payment,prod\n" +
+		"192.168.1.10,3,order,\n" +
+		"192.168.1.11,3,,\n"
+	tags, keys, err := parseCustomIPTagCSV(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("parseCustomIPTagCSV() error = %v", err)
+	}
+	wantTags := []model.CustomIPTag{
+		{L3EPCID: 0, CIDR: "10.1.0.0/16", Tags: map[string]string{"team": "payment", "env": "prod"}},
+		{L3EPCID: 3, CIDR: "192.168.1.10/32", Tags: map[string]string{"team": "order"}},
+	}
+	if !reflect.DeepEqual(tags, wantTags) {
+		t.Errorf("parseCustomIPTagCSV() tags = %+v, want %+v", tags, wantTags)
+	}
+	if !reflect.DeepEqual(keys, []string{"team", "env"}) {
+		t.Errorf("parseCustomIPTagCSV() keys = %v, want [team env]", keys)
+	}
+
+	invalids := map[string]string{
+		"empty":          "",
+		"no ip column":   "team\npayment\n",
+		"no tag column":  "ip,l3_epc_id\n10.1.1.1,1\n",
+		"ip and cidr":    "ip,cidr,team\n10.1.1.1,10.1.1.1,a\n",
+		"invalid key":    "ip,team-name\n10.1.1.1,a\n",
+		"duplicate key":  "ip,team,Team\n10.1.1.1,a,b\n",
+		"invalid ip":     "ip,team\n10.1.1,a\n",
+		"invalid vpc":    "ip,l3_epc_id,team\n10.1.1.1,-1,a\n",
+		"duplicate cidr": "cidr,team\n10.1.1.1/24,a\n10.1.1.0/24,b\n",
+		"field count":    "ip,team\n10.1.1.1,a,b\n",
+	}
+	for name, content := range invalids {
+		if _, _, err := parseCustomIPTagCSV(strings.NewReader(content)); err == nil {
+			t.Errorf("parseCustomIPTagCSV() %s: expect error", name)
+		}
+	}
+}
+
+func TestReadCustomIPTagCSV(t *testing.T) {
+	content := "ip,team\n10.1.1.1,a\n"
+	if tags, _, err := readCustomIPTagCSV(strings.NewReader(content), "test"); err != nil || len(tags) != 1 {
+		t.Errorf("readCustomIPTagCSV() = %v, %v, want 1 entry", tags, err)
+	}
+
+	// 超过大小限制时报错，而不是截断后导入部分条目
+	padding := strings.Repeat("#", CUSTOM_IP_TAG_MAX_SOURCE_SIZE-len(content)+1)
+	if _, _, err := readCustomIPTagCSV(strings.NewReader(content+padding), "test"); err == nil {
+		t.Errorf("readCustomIPTagCSV() with oversize content: expect error")
+	}
+	if _, _, err := readCustomIPTagCSV(strings.NewReader(content+padding[1:]), "test"); err != nil {
+		t.Errorf("readCustomIPTagCSV() with max size content error = %v", err)
+	}
+}
+
+func TestValidateCustomIPTagSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		tagSet  model.CustomIPTagSetCreate
+		want    model.CustomIPTagSetCreate
+		wantErr bool
+	}{
+		{
+			name:   "upload",
+			tagSet: model.CustomIPTagSetCreate{Name: "a", SourceURL: "http://a", SyncInterval: 60},
+			want:   model.CustomIPTagSetCreate{Name: "a"},
+		},
+		{
+			name:   "http with default interval",
+			tagSet: model.CustomIPTagSetCreate{Name: "a", SourceType: common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP, SourceURL: "https://cmdb/ip.csv"},
+			want:   model.CustomIPTagSetCreate{Name: "a", SourceType: common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP, SourceURL: "https://cmdb/ip.csv", SyncInterval: CUSTOM_IP_TAG_DEFAULT_SYNC_INTERVAL},
+		},
+		{
+			name:    "http without url",
+			tagSet:  model.CustomIPTagSetCreate{Name: "a", SourceType: common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP},
+			wantErr: true,
+		},
+		{
+			name:    "ftp url",
+			tagSet:  model.CustomIPTagSetCreate{Name: "a", SourceType: common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP, SourceURL: "ftp://cmdb/ip.csv"},
+			wantErr: true,
+		},
+		{
+			name:    "short interval",
+			tagSet:  model.CustomIPTagSetCreate{Name: "a", SourceType: common.CUSTOM_IP_TAG_SOURCE_TYPE_HTTP, SourceURL: "http://cmdb/ip.csv", SyncInterval: 10},
+			wantErr: true,
+		},
+		{
+			name:    "invalid source type",
+			tagSet:  model.CustomIPTagSetCreate{Name: "a", SourceType: 2},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCustomIPTagSet(&tt.tagSet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateCustomIPTagSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.tagSet != tt.want {
+				t.Errorf("validateCustomIPTagSet() = %+v, want %+v", tt.tagSet, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+func GetOrgs(filter map[string]interface{}) (resp []model.Org, err error) {
+	var response []model.Org
+	var orgs []mysql.Org
+
+	Db := mysql.Db
+	if _, ok := filter["lcuuid"]; ok {
+		Db = Db.Where("lcuuid = ?", filter["lcuuid"])
+	}
+	if _, ok := filter["id"]; ok {
+		Db = Db.Where("id = ?", filter["id"])
+	}
+	if _, ok := filter["name"]; ok {
+		Db = Db.Where("name = ?", filter["name"])
+	}
+	Db.Order("id ASC").Find(&orgs)
+
+	orgIDToVTapCount := getOrgIDToCount(&mysql.VTap{})
+	orgIDToVTapGroupCount := getOrgIDToCount(&mysql.VTapGroup{})
+	orgIDToDomainCount := getOrgIDToCount(&mysql.Domain{})
+	for _, org := range orgs {
+		response = append(response, model.Org{
+			ID:              org.ID,
+			Name:            org.Name,
+			MaxVTapNum:      org.MaxVTapNum,
+			MaxVTapGroupNum: org.MaxVTapGroupNum,
+			MaxDomainNum:    org.MaxDomainNum,
+			VTapCount:       orgIDToVTapCount[org.ID],
+			VTapGroupCount:  orgIDToVTapGroupCount[org.ID],
+			DomainCount:     orgIDToDomainCount[org.ID],
+			UpdatedAt:       org.UpdatedAt.Format(common.GO_BIRTHDAY),
+			Lcuuid:          org.Lcuuid,
+		})
+	}
+	return response, nil
+}
+
+func getOrgIDToCount(table interface{}) map[int]int {
+	var rows []struct {
+		OrgID int
+		Count int
+	}
+	mysql.Db.Model(table).Select("org_id, COUNT(*) AS count").Group("org_id").Scan(&rows)
+	orgIDToCount := make(map[int]int, len(rows))
+	for _, row := range rows {
+		orgIDToCount[row.OrgID] = row.Count
+	}
+	return orgIDToCount
+}
+
+func CreateOrg(orgCreate model.OrgCreate) (resp model.Org, err error) {
+	var orgCount int64
+	mysql.Db.Model(&mysql.Org{}).Where("name = ?", orgCreate.Name).Count(&orgCount)
+	if orgCount > 0 {
+		return model.Org{}, NewError(httpcommon.RESOURCE_ALREADY_EXIST, fmt.Sprintf("org (%s) already exist", orgCreate.Name))
+	}
+	if orgCreate.MaxVTapNum < 0 || orgCreate.MaxVTapGroupNum < 0 || orgCreate.MaxDomainNum < 0 {
+		return model.Org{}, NewError(httpcommon.INVALID_POST_DATA, "org quota must not be negative")
+	}
+
+	log.Infof("create org (%s)", orgCreate.Name)
+
+	lcuuid := uuid.New().String()
+	org := mysql.Org{
+		Name:            orgCreate.Name,
+		MaxVTapNum:      orgCreate.MaxVTapNum,
+		MaxVTapGroupNum: orgCreate.MaxVTapGroupNum,
+		MaxDomainNum:    orgCreate.MaxDomainNum,
+		Lcuuid:          lcuuid,
+	}
+	if ret := mysql.Db.Create(&org); ret.Error != nil {
+		return model.Org{}, NewError(httpcommon.SERVER_ERROR, ret.Error.Error())
+	}
+
+	response, _ := GetOrgs(map[string]interface{}{"lcuuid": lcuuid})
+	return response[0], nil
+}
+
+func UpdateOrg(lcuuid string, orgUpdate map[string]interface{}) (resp model.Org, err error) {
+	var org mysql.Org
+	var dbUpdateMap = make(map[string]interface{})
+
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&org); ret.Error != nil {
+		return model.Org{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("org (%s) not found", lcuuid))
+	}
+
+	log.Infof("update org (%s) config %v", org.Name, orgUpdate)
+
+	for key, column := range map[string]string{
+		"NAME":               "name",
+		"MAX_VTAP_NUM":       "max_vtap_num",
+		"MAX_VTAP_GROUP_NUM": "max_vtap_group_num",
+		"MAX_DOMAIN_NUM":     "max_domain_num",
+	} {
+		if value, ok := orgUpdate[key]; ok {
+			if num, ok := value.(float64); ok && num < 0 {
+				return model.Org{}, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf("%s must not be negative", key))
+			}
+			dbUpdateMap[column] = value
+		}
+	}
+	mysql.Db.Model(&org).Updates(dbUpdateMap)
+
+	response, _ := GetOrgs(map[string]interface{}{"lcuuid": lcuuid})
+	return response[0], nil
+}
+
+func DeleteOrg(lcuuid string) (resp map[string]string, err error) {
+	var org mysql.Org
+
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&org); ret.Error != nil {
+		return map[string]string{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("org (%s) not found", lcuuid))
+	}
+	if org.ID == common.DEFAULT_ORG_ID {
+		return map[string]string{}, NewError(httpcommon.INVALID_PARAMETERS, "default org can not be deleted")
+	}
+	// 组织下仍有资源时不允许删除，避免资源失去归属
+	for _, table := range []interface{}{&mysql.VTap{}, &mysql.VTapGroup{}, &mysql.Domain{}} {
+		var count int64
+		mysql.Db.Model(table).Where("org_id = ?", org.ID).Count(&count)
+		if count > 0 {
+			return map[string]string{}, NewError(
+				httpcommon.INVALID_PARAMETERS,
+				fmt.Sprintf("org (%s) is not empty, please delete its domains, vtap groups and vtaps first", org.Name),
+			)
+		}
+	}
+
+	log.Infof("delete org (%s)", org.Name)
+
+	mysql.Db.Delete(&org)
+	return map[string]string{"LCUUID": lcuuid}, nil
+}
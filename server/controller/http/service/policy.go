@@ -0,0 +1,929 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	tcommon "github.com/deepflowio/deepflow/server/controller/trisolaris/common"
+	"github.com/deepflowio/deepflow/server/controller/trisolaris/refresh"
+)
+
+const (
+	ACL_TYPE_CUSTOM      = 2
+	DEFAULT_ACL_TAP_TYPE = 3 // 云网络
+
+	PROTOCOL_TCP = 6
+	PROTOCOL_UDP = 17
+
+	MAX_PORT          = 65535
+	MAX_VLAN          = 4095
+	MAX_VXLAN_VNI     = 1<<24 - 1
+	MAX_ERSPAN_ID     = 1<<10 - 1
+	MAX_PAYLOAD_SLICE = 65535
+)
+
+// policyRefs 为策略校验时引用的资源，每次变更前从数据库加载
+type policyRefs struct {
+	tapTypes            map[int]bool
+	groupIDToBusinessID map[int]int
+	aclIDToApplication  map[int]int
+	idToNpbTunnel       map[int]mysql.NpbTunnel
+	vtapIDs             map[int]bool
+}
+
+func loadPolicyRefs() (*policyRefs, error) {
+	var tapTypes []mysql.TapType
+	var groups []mysql.ResourceGroup
+	var acls []mysql.ACL
+	var npbTunnels []mysql.NpbTunnel
+	var vtaps []mysql.VTap
+	for _, query := range []func() error{
+		func() error { return mysql.Db.Select("value").Find(&tapTypes).Error },
+		func() error { return mysql.Db.Select("id", "business_id").Find(&groups).Error },
+		func() error { return mysql.Db.Select("id", "applications").Find(&acls).Error },
+		func() error { return mysql.Db.Find(&npbTunnels).Error },
+		func() error { return mysql.Db.Select("id").Find(&vtaps).Error },
+	} {
+		if err := query(); err != nil {
+			return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+		}
+	}
+
+	refs := &policyRefs{
+		tapTypes:            make(map[int]bool, len(tapTypes)),
+		groupIDToBusinessID: make(map[int]int, len(groups)),
+		aclIDToApplication:  make(map[int]int, len(acls)),
+		idToNpbTunnel:       make(map[int]mysql.NpbTunnel, len(npbTunnels)),
+		vtapIDs:             make(map[int]bool, len(vtaps)),
+	}
+	for _, tapType := range tapTypes {
+		refs.tapTypes[tapType.Value] = true
+	}
+	for _, group := range groups {
+		refs.groupIDToBusinessID[group.ID] = group.BusinessID
+	}
+	for _, acl := range acls {
+		application, _ := strconv.Atoi(acl.Applications)
+		refs.aclIDToApplication[acl.ID] = application
+	}
+	for _, npbTunnel := range npbTunnels {
+		refs.idToNpbTunnel[npbTunnel.ID] = npbTunnel
+	}
+	for _, vtap := range vtaps {
+		refs.vtapIDs[vtap.ID] = true
+	}
+	return refs, nil
+}
+
+// normalizePorts 校验端口列表，如 80,8000-8080，返回去除空白后的结果
+func normalizePorts(ports string) (string, error) {
+	if strings.TrimSpace(ports) == "" {
+		return "", nil
+	}
+	items := strings.Split(ports, ",")
+	for i, item := range items {
+		item = strings.TrimSpace(item)
+		bounds := strings.Split(item, "-")
+		if len(bounds) > 2 {
+			return "", fmt.Errorf("invalid port range (%s)", item)
+		}
+		values := make([]int, 0, len(bounds))
+		for _, bound := range bounds {
+			value, err := strconv.Atoi(strings.TrimSpace(bound))
+			if err != nil || value < 0 || value > MAX_PORT {
+				return "", fmt.Errorf("invalid port (%s), port should be in [0, %d]", item, MAX_PORT)
+			}
+			values = append(values, value)
+		}
+		if len(values) == 2 {
+			if values[0] > values[1] {
+				return "", fmt.Errorf("invalid port range (%s), start is greater than end", item)
+			}
+			items[i] = fmt.Sprintf("%d-%d", values[0], values[1])
+		} else {
+			items[i] = strconv.Itoa(values[0])
+		}
+	}
+	return strings.Join(items, ","), nil
+}
+
+func validateState(state *int) (int, error) {
+	if state == nil {
+		return common.ACL_STATE_ENABLE, nil
+	}
+	if *state != common.ACL_STATE_ENABLE && *state != common.ACL_STATE_DISABLE {
+		return 0, fmt.Errorf("invalid state (%d)", *state)
+	}
+	return *state, nil
+}
+
+func validatePayloadSlice(payloadSlice *int) error {
+	if payloadSlice != nil && (*payloadSlice < 0 || *payloadSlice > MAX_PAYLOAD_SLICE) {
+		return fmt.Errorf("invalid payload slice (%d), should be in [0, %d]", *payloadSlice, MAX_PAYLOAD_SLICE)
+	}
+	return nil
+}
+
+// validateVTapIDs 校验并去重采集器 ID，为空表示下发给所有采集器
+func (r *policyRefs) validateVTapIDs(vtapIDs []int) ([]int, error) {
+	result := make([]int, 0, len(vtapIDs))
+	seen := make(map[int]bool, len(vtapIDs))
+	for _, vtapID := range vtapIDs {
+		if !r.vtapIDs[vtapID] {
+			return nil, fmt.Errorf("vtap (%d) not found", vtapID)
+		}
+		if seen[vtapID] {
+			continue
+		}
+		seen[vtapID] = true
+		result = append(result, vtapID)
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+// validateGroups 校验并去重资源组 ID，仅 NPB/PCAP 业务的资源组会下发给采集器
+func (r *policyRefs) validateGroups(groupIDs []int) ([]int, error) {
+	result := make([]int, 0, len(groupIDs))
+	seen := make(map[int]bool, len(groupIDs))
+	for _, groupID := range groupIDs {
+		businessID, ok := r.groupIDToBusinessID[groupID]
+		if !ok {
+			return nil, fmt.Errorf("resource group (%d) not found", groupID)
+		}
+		if businessID != tcommon.NPB_BUSINESS_ID && businessID != tcommon.PCAP_BUSINESS_ID {
+			return nil, fmt.Errorf("resource group (%d) of business (%d) is not sent to vtaps, only npb (%d) or pcap (%d) groups can be used",
+				groupID, businessID, tcommon.NPB_BUSINESS_ID, tcommon.PCAP_BUSINESS_ID)
+		}
+		if seen[groupID] {
+			continue
+		}
+		seen[groupID] = true
+		result = append(result, groupID)
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+func validateTapSide(tapSide *int) error {
+	if *tapSide == 0 {
+		*tapSide = common.POLICY_TAP_SIDE_SRC
+	}
+	if *tapSide < common.POLICY_TAP_SIDE_SRC || *tapSide > common.POLICY_TAP_SIDE_BOTH {
+		return fmt.Errorf("invalid tap side (%d), only src (%d), dst (%d) and both (%d) are supported",
+			*tapSide, common.POLICY_TAP_SIDE_SRC, common.POLICY_TAP_SIDE_DST, common.POLICY_TAP_SIDE_BOTH)
+	}
+	return nil
+}
+
+func (r *policyRefs) validateFlowACL(acl *model.FlowACLCreate) (err error) {
+	if acl.Application != tcommon.APPLICATION_NPB && acl.Application != tcommon.APPLICATION_PCAP {
+		return fmt.Errorf("invalid application (%d), only npb (%d) and pcap (%d) are supported",
+			acl.Application, tcommon.APPLICATION_NPB, tcommon.APPLICATION_PCAP)
+	}
+	if _, err = validateState(acl.State); err != nil {
+		return err
+	}
+	if acl.TapType == 0 {
+		acl.TapType = DEFAULT_ACL_TAP_TYPE
+	}
+	if !r.tapTypes[acl.TapType] {
+		return fmt.Errorf("tap type (%d) not found", acl.TapType)
+	}
+	if acl.SrcGroupIDs, err = r.validateGroups(acl.SrcGroupIDs); err != nil {
+		return err
+	}
+	if acl.DstGroupIDs, err = r.validateGroups(acl.DstGroupIDs); err != nil {
+		return err
+	}
+	if acl.Protocol != nil && (*acl.Protocol < 0 || *acl.Protocol > 255) {
+		return fmt.Errorf("invalid protocol (%d)", *acl.Protocol)
+	}
+	if acl.SrcPorts, err = normalizePorts(acl.SrcPorts); err != nil {
+		return err
+	}
+	if acl.DstPorts, err = normalizePorts(acl.DstPorts); err != nil {
+		return err
+	}
+	if (acl.SrcPorts != "" || acl.DstPorts != "") && acl.Protocol != nil &&
+		*acl.Protocol != PROTOCOL_TCP && *acl.Protocol != PROTOCOL_UDP {
+		return fmt.Errorf("ports are only supported by tcp (%d) and udp (%d), protocol is %d", PROTOCOL_TCP, PROTOCOL_UDP, *acl.Protocol)
+	}
+	if acl.Vlan < 0 || acl.Vlan > MAX_VLAN {
+		return fmt.Errorf("invalid vlan (%d), should be in [0, %d]", acl.Vlan, MAX_VLAN)
+	}
+	return nil
+}
+
+func validateNpbTunnel(npbTunnel *model.NpbTunnelCreate) error {
+	if net.ParseIP(npbTunnel.IP) == nil {
+		return fmt.Errorf("invalid tunnel ip (%s)", npbTunnel.IP)
+	}
+	if npbTunnel.Type != common.NPB_TUNNEL_TYPE_VXLAN && npbTunnel.Type != common.NPB_TUNNEL_TYPE_ERSPAN {
+		return fmt.Errorf("invalid tunnel type (%d), only VXLAN (%d) and ERSPAN (%d) are supported",
+			npbTunnel.Type, common.NPB_TUNNEL_TYPE_VXLAN, common.NPB_TUNNEL_TYPE_ERSPAN)
+	}
+	if npbTunnel.VNIInputType == 0 {
+		npbTunnel.VNIInputType = common.NPB_TUNNEL_VNI_INPUT_TYPE_ENTIRE
+	}
+	if npbTunnel.VNIInputType != common.NPB_TUNNEL_VNI_INPUT_TYPE_ENTIRE &&
+		npbTunnel.VNIInputType != common.NPB_TUNNEL_VNI_INPUT_TYPE_TWO_PARTS {
+		return fmt.Errorf("invalid vni input type (%d)", npbTunnel.VNIInputType)
+	}
+	return nil
+}
+
+func (r *policyRefs) validateNpbPolicy(npbPolicy *model.NpbPolicyCreate) (err error) {
+	if _, err = validateState(npbPolicy.State); err != nil {
+		return err
+	}
+	if application, ok := r.aclIDToApplication[npbPolicy.ACLID]; !ok {
+		return fmt.Errorf("flow acl (%d) not found", npbPolicy.ACLID)
+	} else if application != tcommon.APPLICATION_NPB {
+		return fmt.Errorf("application of flow acl (%d) is %d, not npb", npbPolicy.ACLID, application)
+	}
+	npbTunnel, ok := r.idToNpbTunnel[npbPolicy.NpbTunnelID]
+	if !ok {
+		return fmt.Errorf("npb tunnel (%d) not found", npbPolicy.NpbTunnelID)
+	}
+	maxVni := MAX_VXLAN_VNI
+	if npbTunnel.Type == common.NPB_TUNNEL_TYPE_ERSPAN {
+		maxVni = MAX_ERSPAN_ID
+	}
+	if npbPolicy.Vni < 0 || npbPolicy.Vni > maxVni {
+		return fmt.Errorf("invalid vni (%d) of tunnel (%s), should be in [0, %d]", npbPolicy.Vni, npbTunnel.Name, maxVni)
+	}
+	if npbPolicy.Direction == 0 {
+		npbPolicy.Direction = common.NPB_POLICY_DIRECTION_ALL
+	}
+	if npbPolicy.Direction < common.NPB_POLICY_DIRECTION_ALL || npbPolicy.Direction > common.NPB_POLICY_DIRECTION_BACKWARD {
+		return fmt.Errorf("invalid direction (%d)", npbPolicy.Direction)
+	}
+	if err = validateTapSide(&npbPolicy.TapSide); err != nil {
+		return err
+	}
+	if npbPolicy.Distribute != nil && *npbPolicy.Distribute != common.NPB_POLICY_FLOW_DROP &&
+		*npbPolicy.Distribute != common.NPB_POLICY_FLOW_DISTRIBUTE {
+		return fmt.Errorf("invalid distribute (%d)", *npbPolicy.Distribute)
+	}
+	if err = validatePayloadSlice(npbPolicy.PayloadSlice); err != nil {
+		return err
+	}
+	npbPolicy.VTapIDs, err = r.validateVTapIDs(npbPolicy.VTapIDs)
+	return err
+}
+
+func (r *policyRefs) validatePcapPolicy(pcapPolicy *model.PcapPolicyCreate) (err error) {
+	if _, err = validateState(pcapPolicy.State); err != nil {
+		return err
+	}
+	if application, ok := r.aclIDToApplication[pcapPolicy.ACLID]; !ok {
+		return fmt.Errorf("flow acl (%d) not found", pcapPolicy.ACLID)
+	} else if application != tcommon.APPLICATION_PCAP {
+		return fmt.Errorf("application of flow acl (%d) is %d, not pcap", pcapPolicy.ACLID, application)
+	}
+	if err = validateTapSide(&pcapPolicy.TapSide); err != nil {
+		return err
+	}
+	if err = validatePayloadSlice(pcapPolicy.PayloadSlice); err != nil {
+		return err
+	}
+	pcapPolicy.VTapIDs, err = r.validateVTapIDs(pcapPolicy.VTapIDs)
+	return err
+}
+
+// patchPolicy 将 PATCH 请求中的字段覆盖到完整的创建参数上，再整体校验
+func patchPolicy(dst interface{}, patchMap map[string]interface{}) error {
+	patchJson, err := json.Marshal(patchMap)
+	if err != nil {
+		return NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err = json.Unmarshal(patchJson, dst); err != nil {
+		return NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	return nil
+}
+
+func checkPolicyNameExist(table interface{}, resourceType, name string, id int) error {
+	var count int64
+	mysql.Db.Model(table).Where("name = ? AND id != ?", name, id).Count(&count)
+	if count > 0 {
+		return NewError(httpcommon.RESOURCE_ALREADY_EXIST, fmt.Sprintf("%s (%s) already exist", resourceType, name))
+	}
+	return nil
+}
+
+func intsToString(ints []int) string {
+	items := make([]string, 0, len(ints))
+	for _, i := range ints {
+		items = append(items, strconv.Itoa(i))
+	}
+	return strings.Join(items, ",")
+}
+
+func stringToInts(s string) []int {
+	ints := []int{}
+	for _, item := range strings.Split(s, ",") {
+		if i, err := strconv.Atoi(strings.TrimSpace(item)); err == nil {
+			ints = append(ints, i)
+		}
+	}
+	return ints
+}
+
+// createPolicy 在同一事务中创建策略并分配 policy_acl_group_id，避免留下未分配的策略
+// policy_acl_group_id 用于在流量统计中区分策略，每个策略独立分配
+func createPolicy(policy interface{}, id *int) error {
+	return mysql.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(policy).Error; err != nil {
+			return err
+		}
+		return tx.Model(policy).Update("policy_acl_group_id", *id).Error
+	})
+}
+
+func refreshFlowACL(dataChanged ...common.DataChanged) {
+	refresh.RefreshCache(append([]common.DataChanged{common.DATA_CHANGED_FLOW_ACL}, dataChanged...))
+}
+
+// flow acl
+
+func aclToFlowACLCreate(acl *mysql.ACL) model.FlowACLCreate {
+	application, _ := strconv.Atoi(acl.Applications)
+	state := acl.State
+	return model.FlowACLCreate{
+		Name:        acl.Name,
+		Application: application,
+		State:       &state,
+		TapType:     acl.TapType,
+		SrcGroupIDs: stringToInts(acl.SrcGroupIDs),
+		DstGroupIDs: stringToInts(acl.DstGroupIDs),
+		Protocol:    acl.Protocol,
+		SrcPorts:    acl.SrcPorts,
+		DstPorts:    acl.DstPorts,
+		Vlan:        acl.Vlan,
+	}
+}
+
+func fillACL(acl *mysql.ACL, aclCreate model.FlowACLCreate) {
+	state, _ := validateState(aclCreate.State)
+	acl.Name = aclCreate.Name
+	acl.Type = ACL_TYPE_CUSTOM
+	// 采集器仅会收到 NPB/PCAP 业务的资源组
+	acl.BusinessID = tcommon.NPB_BUSINESS_ID
+	if aclCreate.Application == tcommon.APPLICATION_PCAP {
+		acl.BusinessID = tcommon.PCAP_BUSINESS_ID
+	}
+	acl.Applications = strconv.Itoa(aclCreate.Application)
+	acl.State = state
+	acl.TapType = aclCreate.TapType
+	acl.SrcGroupIDs = intsToString(aclCreate.SrcGroupIDs)
+	acl.DstGroupIDs = intsToString(aclCreate.DstGroupIDs)
+	acl.Protocol = aclCreate.Protocol
+	acl.SrcPorts = aclCreate.SrcPorts
+	acl.DstPorts = aclCreate.DstPorts
+	acl.Vlan = aclCreate.Vlan
+}
+
+func GetFlowACLs(filter map[string]interface{}) ([]model.FlowACL, error) {
+	var acls []mysql.ACL
+	db := mysql.Db.Where("applications IN (?)",
+		[]string{strconv.Itoa(tcommon.APPLICATION_NPB), strconv.Itoa(tcommon.APPLICATION_PCAP)})
+	for _, param := range []string{"lcuuid", "name", "id"} {
+		if value, ok := filter[param]; ok {
+			db = db.Where(fmt.Sprintf("%s = ?", param), value)
+		}
+	}
+	if value, ok := filter["application"]; ok {
+		db = db.Where("applications = ?", value)
+	}
+	if err := db.Order("id").Find(&acls).Error; err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	response := make([]model.FlowACL, 0, len(acls))
+	for i := range acls {
+		acl := aclToFlowACLCreate(&acls[i])
+		response = append(response, model.FlowACL{
+			ID:          acls[i].ID,
+			Name:        acl.Name,
+			Application: acl.Application,
+			State:       *acl.State,
+			TapType:     acl.TapType,
+			SrcGroupIDs: acl.SrcGroupIDs,
+			DstGroupIDs: acl.DstGroupIDs,
+			Protocol:    acl.Protocol,
+			SrcPorts:    acl.SrcPorts,
+			DstPorts:    acl.DstPorts,
+			Vlan:        acl.Vlan,
+			CreatedAt:   acls[i].CreatedAt.Format(common.GO_BIRTHDAY),
+			UpdatedAt:   acls[i].UpdatedAt.Format(common.GO_BIRTHDAY),
+			Lcuuid:      acls[i].Lcuuid,
+		})
+	}
+	return response, nil
+}
+
+func getFlowACL(lcuuid string) (model.FlowACL, error) {
+	acls, err := GetFlowACLs(map[string]interface{}{"lcuuid": lcuuid})
+	if err != nil {
+		return model.FlowACL{}, err
+	}
+	if len(acls) == 0 {
+		return model.FlowACL{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("flow acl (%s) not found", lcuuid))
+	}
+	return acls[0], nil
+}
+
+func CreateFlowACL(aclCreate model.FlowACLCreate) (model.FlowACL, error) {
+	refs, err := loadPolicyRefs()
+	if err != nil {
+		return model.FlowACL{}, err
+	}
+	if err = refs.validateFlowACL(&aclCreate); err != nil {
+		return model.FlowACL{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err = checkPolicyNameExist(&mysql.ACL{}, "flow acl", aclCreate.Name, 0); err != nil {
+		return model.FlowACL{}, err
+	}
+
+	acl := mysql.ACL{Lcuuid: uuid.New().String()}
+	fillACL(&acl, aclCreate)
+	if err = mysql.Db.Create(&acl).Error; err != nil {
+		return model.FlowACL{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("create flow acl (%s) %+v", acl.Name, aclCreate)
+	refreshFlowACL(common.DATA_CHANGED_GROUP)
+	return getFlowACL(acl.Lcuuid)
+}
+
+func UpdateFlowACL(lcuuid string, patchMap map[string]interface{}) (model.FlowACL, error) {
+	var acl mysql.ACL
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&acl); ret.Error != nil {
+		return model.FlowACL{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("flow acl (%s) not found", lcuuid))
+	}
+	aclCreate := aclToFlowACLCreate(&acl)
+	application := aclCreate.Application
+	if err := patchPolicy(&aclCreate, patchMap); err != nil {
+		return model.FlowACL{}, err
+	}
+	// 策略通过 acl_id 引用 acl，修改用途会导致已有策略失效
+	if aclCreate.Application != application {
+		return model.FlowACL{}, NewError(httpcommon.INVALID_PARAMETERS, "application of flow acl can not be modified")
+	}
+	refs, err := loadPolicyRefs()
+	if err != nil {
+		return model.FlowACL{}, err
+	}
+	if err = refs.validateFlowACL(&aclCreate); err != nil {
+		return model.FlowACL{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err = checkPolicyNameExist(&mysql.ACL{}, "flow acl", aclCreate.Name, acl.ID); err != nil {
+		return model.FlowACL{}, err
+	}
+
+	fillACL(&acl, aclCreate)
+	if err = mysql.Db.Save(&acl).Error; err != nil {
+		return model.FlowACL{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("update flow acl (%s) config %v", acl.Name, patchMap)
+	refreshFlowACL(common.DATA_CHANGED_GROUP)
+	return getFlowACL(acl.Lcuuid)
+}
+
+func DeleteFlowACL(lcuuid string) (map[string]string, error) {
+	var acl mysql.ACL
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&acl); ret.Error != nil {
+		return map[string]string{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("flow acl (%s) not found", lcuuid))
+	}
+	var npbPolicyCount, pcapPolicyCount int64
+	mysql.Db.Model(&mysql.NpbPolicy{}).Where("acl_id = ?", acl.ID).Count(&npbPolicyCount)
+	mysql.Db.Model(&mysql.PcapPolicy{}).Where("acl_id = ?", acl.ID).Count(&pcapPolicyCount)
+	if npbPolicyCount+pcapPolicyCount > 0 {
+		return map[string]string{}, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf(
+			"flow acl (%s) is used by %d npb policies and %d pcap policies", acl.Name, npbPolicyCount, pcapPolicyCount))
+	}
+
+	log.Infof("delete flow acl (%s)", acl.Name)
+	if err := mysql.Db.Delete(&acl).Error; err != nil {
+		return map[string]string{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	refreshFlowACL(common.DATA_CHANGED_GROUP)
+	return map[string]string{"LCUUID": lcuuid}, nil
+}
+
+// npb tunnel
+
+func GetNpbTunnels(filter map[string]interface{}) ([]model.NpbTunnel, error) {
+	var npbTunnels []mysql.NpbTunnel
+	db := mysql.Db
+	for _, param := range []string{"lcuuid", "name", "id"} {
+		if value, ok := filter[param]; ok {
+			db = db.Where(fmt.Sprintf("%s = ?", param), value)
+		}
+	}
+	if err := db.Order("id").Find(&npbTunnels).Error; err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	response := make([]model.NpbTunnel, 0, len(npbTunnels))
+	for _, npbTunnel := range npbTunnels {
+		response = append(response, model.NpbTunnel{
+			ID:           npbTunnel.ID,
+			Name:         npbTunnel.Name,
+			IP:           npbTunnel.IP,
+			Type:         npbTunnel.Type,
+			VNIInputType: npbTunnel.VNIInputType,
+			CreatedAt:    npbTunnel.CreatedAt.Format(common.GO_BIRTHDAY),
+			UpdatedAt:    npbTunnel.UpdatedAt.Format(common.GO_BIRTHDAY),
+			Lcuuid:       npbTunnel.Lcuuid,
+		})
+	}
+	return response, nil
+}
+
+func getNpbTunnel(lcuuid string) (model.NpbTunnel, error) {
+	npbTunnels, err := GetNpbTunnels(map[string]interface{}{"lcuuid": lcuuid})
+	if err != nil {
+		return model.NpbTunnel{}, err
+	}
+	if len(npbTunnels) == 0 {
+		return model.NpbTunnel{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("npb tunnel (%s) not found", lcuuid))
+	}
+	return npbTunnels[0], nil
+}
+
+func CreateNpbTunnel(npbTunnelCreate model.NpbTunnelCreate) (model.NpbTunnel, error) {
+	if err := validateNpbTunnel(&npbTunnelCreate); err != nil {
+		return model.NpbTunnel{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err := checkPolicyNameExist(&mysql.NpbTunnel{}, "npb tunnel", npbTunnelCreate.Name, 0); err != nil {
+		return model.NpbTunnel{}, err
+	}
+
+	npbTunnel := mysql.NpbTunnel{
+		Name:         npbTunnelCreate.Name,
+		IP:           npbTunnelCreate.IP,
+		Type:         npbTunnelCreate.Type,
+		VNIInputType: npbTunnelCreate.VNIInputType,
+		Lcuuid:       uuid.New().String(),
+	}
+	if err := mysql.Db.Create(&npbTunnel).Error; err != nil {
+		return model.NpbTunnel{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("create npb tunnel (%s) %+v", npbTunnel.Name, npbTunnelCreate)
+	refreshFlowACL()
+	return getNpbTunnel(npbTunnel.Lcuuid)
+}
+
+func UpdateNpbTunnel(lcuuid string, patchMap map[string]interface{}) (model.NpbTunnel, error) {
+	var npbTunnel mysql.NpbTunnel
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&npbTunnel); ret.Error != nil {
+		return model.NpbTunnel{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("npb tunnel (%s) not found", lcuuid))
+	}
+	npbTunnelCreate := model.NpbTunnelCreate{
+		Name:         npbTunnel.Name,
+		IP:           npbTunnel.IP,
+		Type:         npbTunnel.Type,
+		VNIInputType: npbTunnel.VNIInputType,
+	}
+	if err := patchPolicy(&npbTunnelCreate, patchMap); err != nil {
+		return model.NpbTunnel{}, err
+	}
+	if err := validateNpbTunnel(&npbTunnelCreate); err != nil {
+		return model.NpbTunnel{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	// 隧道类型决定了 vni 的取值范围，改为 ERSPAN 时需校验引用该隧道的策略
+	if npbTunnelCreate.Type == common.NPB_TUNNEL_TYPE_ERSPAN && npbTunnel.Type != common.NPB_TUNNEL_TYPE_ERSPAN {
+		var npbPolicy mysql.NpbPolicy
+		if ret := mysql.Db.Where("npb_tunnel_id = ? AND vni > ?", npbTunnel.ID, MAX_ERSPAN_ID).First(&npbPolicy); ret.Error == nil {
+			return model.NpbTunnel{}, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf(
+				"vni of npb policy (%s) exceeds the max erspan session id (%d)", npbPolicy.Name, MAX_ERSPAN_ID))
+		}
+	}
+	if err := checkPolicyNameExist(&mysql.NpbTunnel{}, "npb tunnel", npbTunnelCreate.Name, npbTunnel.ID); err != nil {
+		return model.NpbTunnel{}, err
+	}
+
+	npbTunnel.Name = npbTunnelCreate.Name
+	npbTunnel.IP = npbTunnelCreate.IP
+	npbTunnel.Type = npbTunnelCreate.Type
+	npbTunnel.VNIInputType = npbTunnelCreate.VNIInputType
+	if err := mysql.Db.Save(&npbTunnel).Error; err != nil {
+		return model.NpbTunnel{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("update npb tunnel (%s) config %v", npbTunnel.Name, patchMap)
+	refreshFlowACL()
+	return getNpbTunnel(npbTunnel.Lcuuid)
+}
+
+func DeleteNpbTunnel(lcuuid string) (map[string]string, error) {
+	var npbTunnel mysql.NpbTunnel
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&npbTunnel); ret.Error != nil {
+		return map[string]string{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("npb tunnel (%s) not found", lcuuid))
+	}
+	var count int64
+	mysql.Db.Model(&mysql.NpbPolicy{}).Where("npb_tunnel_id = ?", npbTunnel.ID).Count(&count)
+	if count > 0 {
+		return map[string]string{}, NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf(
+			"npb tunnel (%s) is used by %d npb policies", npbTunnel.Name, count))
+	}
+
+	log.Infof("delete npb tunnel (%s)", npbTunnel.Name)
+	if err := mysql.Db.Delete(&npbTunnel).Error; err != nil {
+		return map[string]string{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	refreshFlowACL()
+	return map[string]string{"LCUUID": lcuuid}, nil
+}
+
+// npb policy
+
+func GetNpbPolicies(filter map[string]interface{}) ([]model.NpbPolicy, error) {
+	var npbPolicies []mysql.NpbPolicy
+	db := mysql.Db
+	for _, param := range []string{"lcuuid", "name", "id", "acl_id", "npb_tunnel_id"} {
+		if value, ok := filter[param]; ok {
+			db = db.Where(fmt.Sprintf("%s = ?", param), value)
+		}
+	}
+	if err := db.Order("id").Find(&npbPolicies).Error; err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	response := make([]model.NpbPolicy, 0, len(npbPolicies))
+	for _, npbPolicy := range npbPolicies {
+		response = append(response, model.NpbPolicy{
+			ID:               npbPolicy.ID,
+			Name:             npbPolicy.Name,
+			State:            npbPolicy.State,
+			ACLID:            npbPolicy.ACLID,
+			NpbTunnelID:      npbPolicy.NpbTunnelID,
+			Vni:              npbPolicy.Vni,
+			Direction:        npbPolicy.Direction,
+			TapSide:          npbPolicy.TapSide,
+			Distribute:       npbPolicy.Distribute,
+			PayloadSlice:     npbPolicy.PayloadSlice,
+			PolicyACLGroupID: npbPolicy.PolicyACLGroupID,
+			VTapIDs:          stringToInts(npbPolicy.VtapIDs),
+			CreatedAt:        npbPolicy.CreatedAt.Format(common.GO_BIRTHDAY),
+			UpdatedAt:        npbPolicy.UpdatedAt.Format(common.GO_BIRTHDAY),
+			Lcuuid:           npbPolicy.Lcuuid,
+		})
+	}
+	return response, nil
+}
+
+func getNpbPolicy(lcuuid string) (model.NpbPolicy, error) {
+	npbPolicies, err := GetNpbPolicies(map[string]interface{}{"lcuuid": lcuuid})
+	if err != nil {
+		return model.NpbPolicy{}, err
+	}
+	if len(npbPolicies) == 0 {
+		return model.NpbPolicy{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("npb policy (%s) not found", lcuuid))
+	}
+	return npbPolicies[0], nil
+}
+
+func fillNpbPolicy(npbPolicy *mysql.NpbPolicy, npbPolicyCreate model.NpbPolicyCreate) {
+	npbPolicy.Name = npbPolicyCreate.Name
+	npbPolicy.State, _ = validateState(npbPolicyCreate.State)
+	npbPolicy.BusinessID = tcommon.NPB_BUSINESS_ID
+	npbPolicy.ACLID = npbPolicyCreate.ACLID
+	npbPolicy.NpbTunnelID = npbPolicyCreate.NpbTunnelID
+	npbPolicy.Vni = npbPolicyCreate.Vni
+	npbPolicy.Direction = npbPolicyCreate.Direction
+	npbPolicy.TapSide = npbPolicyCreate.TapSide
+	npbPolicy.Distribute = common.NPB_POLICY_FLOW_DISTRIBUTE
+	if npbPolicyCreate.Distribute != nil {
+		npbPolicy.Distribute = *npbPolicyCreate.Distribute
+	}
+	npbPolicy.PayloadSlice = npbPolicyCreate.PayloadSlice
+	npbPolicy.VtapIDs = intsToString(npbPolicyCreate.VTapIDs)
+}
+
+func CreateNpbPolicy(npbPolicyCreate model.NpbPolicyCreate) (model.NpbPolicy, error) {
+	refs, err := loadPolicyRefs()
+	if err != nil {
+		return model.NpbPolicy{}, err
+	}
+	if err = refs.validateNpbPolicy(&npbPolicyCreate); err != nil {
+		return model.NpbPolicy{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err = checkPolicyNameExist(&mysql.NpbPolicy{}, "npb policy", npbPolicyCreate.Name, 0); err != nil {
+		return model.NpbPolicy{}, err
+	}
+
+	npbPolicy := mysql.NpbPolicy{Lcuuid: uuid.New().String()}
+	fillNpbPolicy(&npbPolicy, npbPolicyCreate)
+	if err = createPolicy(&npbPolicy, &npbPolicy.ID); err != nil {
+		return model.NpbPolicy{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("create npb policy (%s) %+v", npbPolicy.Name, npbPolicyCreate)
+	refreshFlowACL()
+	return getNpbPolicy(npbPolicy.Lcuuid)
+}
+
+func UpdateNpbPolicy(lcuuid string, patchMap map[string]interface{}) (model.NpbPolicy, error) {
+	var npbPolicy mysql.NpbPolicy
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&npbPolicy); ret.Error != nil {
+		return model.NpbPolicy{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("npb policy (%s) not found", lcuuid))
+	}
+	state, distribute := npbPolicy.State, npbPolicy.Distribute
+	npbPolicyCreate := model.NpbPolicyCreate{
+		Name:         npbPolicy.Name,
+		State:        &state,
+		ACLID:        npbPolicy.ACLID,
+		NpbTunnelID:  npbPolicy.NpbTunnelID,
+		Vni:          npbPolicy.Vni,
+		Direction:    npbPolicy.Direction,
+		TapSide:      npbPolicy.TapSide,
+		Distribute:   &distribute,
+		PayloadSlice: npbPolicy.PayloadSlice,
+		VTapIDs:      stringToInts(npbPolicy.VtapIDs),
+	}
+	if err := patchPolicy(&npbPolicyCreate, patchMap); err != nil {
+		return model.NpbPolicy{}, err
+	}
+	refs, err := loadPolicyRefs()
+	if err != nil {
+		return model.NpbPolicy{}, err
+	}
+	if err = refs.validateNpbPolicy(&npbPolicyCreate); err != nil {
+		return model.NpbPolicy{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err = checkPolicyNameExist(&mysql.NpbPolicy{}, "npb policy", npbPolicyCreate.Name, npbPolicy.ID); err != nil {
+		return model.NpbPolicy{}, err
+	}
+
+	fillNpbPolicy(&npbPolicy, npbPolicyCreate)
+	if err = mysql.Db.Save(&npbPolicy).Error; err != nil {
+		return model.NpbPolicy{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("update npb policy (%s) config %v", npbPolicy.Name, patchMap)
+	refreshFlowACL()
+	return getNpbPolicy(npbPolicy.Lcuuid)
+}
+
+func DeleteNpbPolicy(lcuuid string) (map[string]string, error) {
+	var npbPolicy mysql.NpbPolicy
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&npbPolicy); ret.Error != nil {
+		return map[string]string{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("npb policy (%s) not found", lcuuid))
+	}
+	log.Infof("delete npb policy (%s)", npbPolicy.Name)
+	if err := mysql.Db.Delete(&npbPolicy).Error; err != nil {
+		return map[string]string{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	refreshFlowACL()
+	return map[string]string{"LCUUID": lcuuid}, nil
+}
+
+// pcap policy
+
+func GetPcapPolicies(filter map[string]interface{}) ([]model.PcapPolicy, error) {
+	var pcapPolicies []mysql.PcapPolicy
+	db := mysql.Db
+	for _, param := range []string{"lcuuid", "name", "id", "acl_id"} {
+		if value, ok := filter[param]; ok {
+			db = db.Where(fmt.Sprintf("%s = ?", param), value)
+		}
+	}
+	if err := db.Order("id").Find(&pcapPolicies).Error; err != nil {
+		return nil, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	response := make([]model.PcapPolicy, 0, len(pcapPolicies))
+	for _, pcapPolicy := range pcapPolicies {
+		response = append(response, model.PcapPolicy{
+			ID:               pcapPolicy.ID,
+			Name:             pcapPolicy.Name,
+			State:            pcapPolicy.State,
+			ACLID:            pcapPolicy.ACLID,
+			TapSide:          pcapPolicy.TapSide,
+			PayloadSlice:     pcapPolicy.PayloadSlice,
+			PolicyACLGroupID: pcapPolicy.PolicyACLGroupID,
+			VTapIDs:          stringToInts(pcapPolicy.VtapIDs),
+			CreatedAt:        pcapPolicy.CreatedAt.Format(common.GO_BIRTHDAY),
+			UpdatedAt:        pcapPolicy.UpdatedAt.Format(common.GO_BIRTHDAY),
+			Lcuuid:           pcapPolicy.Lcuuid,
+		})
+	}
+	return response, nil
+}
+
+func getPcapPolicy(lcuuid string) (model.PcapPolicy, error) {
+	pcapPolicies, err := GetPcapPolicies(map[string]interface{}{"lcuuid": lcuuid})
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	if len(pcapPolicies) == 0 {
+		return model.PcapPolicy{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("pcap policy (%s) not found", lcuuid))
+	}
+	return pcapPolicies[0], nil
+}
+
+func fillPcapPolicy(pcapPolicy *mysql.PcapPolicy, pcapPolicyCreate model.PcapPolicyCreate) {
+	pcapPolicy.Name = pcapPolicyCreate.Name
+	pcapPolicy.State, _ = validateState(pcapPolicyCreate.State)
+	pcapPolicy.BusinessID = tcommon.PCAP_BUSINESS_ID
+	pcapPolicy.ACLID = pcapPolicyCreate.ACLID
+	pcapPolicy.TapSide = pcapPolicyCreate.TapSide
+	pcapPolicy.PayloadSlice = pcapPolicyCreate.PayloadSlice
+	pcapPolicy.VtapIDs = intsToString(pcapPolicyCreate.VTapIDs)
+}
+
+func CreatePcapPolicy(pcapPolicyCreate model.PcapPolicyCreate) (model.PcapPolicy, error) {
+	refs, err := loadPolicyRefs()
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	if err = refs.validatePcapPolicy(&pcapPolicyCreate); err != nil {
+		return model.PcapPolicy{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err = checkPolicyNameExist(&mysql.PcapPolicy{}, "pcap policy", pcapPolicyCreate.Name, 0); err != nil {
+		return model.PcapPolicy{}, err
+	}
+
+	pcapPolicy := mysql.PcapPolicy{Lcuuid: uuid.New().String()}
+	fillPcapPolicy(&pcapPolicy, pcapPolicyCreate)
+	if err = createPolicy(&pcapPolicy, &pcapPolicy.ID); err != nil {
+		return model.PcapPolicy{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("create pcap policy (%s) %+v", pcapPolicy.Name, pcapPolicyCreate)
+	refreshFlowACL()
+	return getPcapPolicy(pcapPolicy.Lcuuid)
+}
+
+func UpdatePcapPolicy(lcuuid string, patchMap map[string]interface{}) (model.PcapPolicy, error) {
+	var pcapPolicy mysql.PcapPolicy
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&pcapPolicy); ret.Error != nil {
+		return model.PcapPolicy{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("pcap policy (%s) not found", lcuuid))
+	}
+	state := pcapPolicy.State
+	pcapPolicyCreate := model.PcapPolicyCreate{
+		Name:         pcapPolicy.Name,
+		State:        &state,
+		ACLID:        pcapPolicy.ACLID,
+		TapSide:      pcapPolicy.TapSide,
+		PayloadSlice: pcapPolicy.PayloadSlice,
+		VTapIDs:      stringToInts(pcapPolicy.VtapIDs),
+	}
+	if err := patchPolicy(&pcapPolicyCreate, patchMap); err != nil {
+		return model.PcapPolicy{}, err
+	}
+	refs, err := loadPolicyRefs()
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	if err = refs.validatePcapPolicy(&pcapPolicyCreate); err != nil {
+		return model.PcapPolicy{}, NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	if err = checkPolicyNameExist(&mysql.PcapPolicy{}, "pcap policy", pcapPolicyCreate.Name, pcapPolicy.ID); err != nil {
+		return model.PcapPolicy{}, err
+	}
+
+	fillPcapPolicy(&pcapPolicy, pcapPolicyCreate)
+	if err = mysql.Db.Save(&pcapPolicy).Error; err != nil {
+		return model.PcapPolicy{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	log.Infof("update pcap policy (%s) config %v", pcapPolicy.Name, patchMap)
+	refreshFlowACL()
+	return getPcapPolicy(pcapPolicy.Lcuuid)
+}
+
+func DeletePcapPolicy(lcuuid string) (map[string]string, error) {
+	var pcapPolicy mysql.PcapPolicy
+	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&pcapPolicy); ret.Error != nil {
+		return map[string]string{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("pcap policy (%s) not found", lcuuid))
+	}
+	log.Infof("delete pcap policy (%s)", pcapPolicy.Name)
+	if err := mysql.Db.Delete(&pcapPolicy).Error; err != nil {
+		return map[string]string{}, NewError(httpcommon.SERVER_ERROR, err.Error())
+	}
+	refreshFlowACL()
+	return map[string]string{"LCUUID": lcuuid}, nil
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	tcommon "github.com/deepflowio/deepflow/server/controller/trisolaris/common"
+)
+
+func newTestPolicyRefs() *policyRefs {
+	return &policyRefs{
+		tapTypes: map[int]bool{3: true},
+		groupIDToBusinessID: map[int]int{
+			1: tcommon.NPB_BUSINESS_ID,
+			2: tcommon.PCAP_BUSINESS_ID,
+			3: 100,
+		},
+		aclIDToApplication: map[int]int{
+			1: tcommon.APPLICATION_NPB,
+			2: tcommon.APPLICATION_PCAP,
+		},
+		idToNpbTunnel: map[int]mysql.NpbTunnel{
+			1: {ID: 1, Name: "vxlan", Type: common.NPB_TUNNEL_TYPE_VXLAN},
+			2: {ID: 2, Name: "erspan", Type: common.NPB_TUNNEL_TYPE_ERSPAN},
+		},
+		vtapIDs: map[int]bool{1: true, 2: true},
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestNormalizePorts(t *testing.T) {
+	tests := []struct {
+		ports   string
+		want    string
+		wantErr bool
+	}{
+		{ports: "", want: ""},
+		{ports: "80", want: "80"},
+		{ports: " 80, 8000 - 8080 ", want: "80,8000-8080"},
+		{ports: "0,65535", want: "0,65535"},
+		{ports: "65536", wantErr: true},
+		{ports: "8080-80", wantErr: true},
+		{ports: "1-2-3", wantErr: true},
+		{ports: "80,,81", wantErr: true},
+		{ports: "http", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := normalizePorts(tt.ports)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizePorts(%q) error = %v, wantErr %v", tt.ports, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizePorts(%q) = %q, want %q", tt.ports, got, tt.want)
+		}
+	}
+}
+
+func TestValidateFlowACL(t *testing.T) {
+	refs := newTestPolicyRefs()
+	tests := []struct {
+		name    string
+		acl     model.FlowACLCreate
+		wantErr bool
+	}{
+		{name: "npb", acl: model.FlowACLCreate{Application: tcommon.APPLICATION_NPB, SrcGroupIDs: []int{1}, DstGroupIDs: []int{2}}},
+		{name: "pcap with tcp ports", acl: model.FlowACLCreate{Application: tcommon.APPLICATION_PCAP, Protocol: intPtr(6), DstPorts: "80"}},
+		{name: "invalid application", acl: model.FlowACLCreate{Application: 1}, wantErr: true},
+		{name: "invalid state", acl: model.FlowACLCreate{Application: tcommon.APPLICATION_NPB, State: intPtr(2)}, wantErr: true},
+		{name: "unknown tap type", acl: model.FlowACLCreate{Application: tcommon.APPLICATION_NPB, TapType: 1}, wantErr: true},
+		{name: "unknown group", acl: model.FlowACLCreate{Application: tcommon.APPLICATION_NPB, SrcGroupIDs: []int{1, 4}}, wantErr: true},
+		{name: "group of other business", acl: model.FlowACLCreate{Application: tcommon.APPLICATION_NPB, DstGroupIDs: []int{3}}, wantErr: true},
+		{name: "ports with icmp", acl: model.FlowACLCreate{Application: tcommon.APPLICATION_NPB, Protocol: intPtr(1), SrcPorts: "80"}, wantErr: true},
+		{name: "invalid protocol", acl: model.FlowACLCreate{Application: tcommon.APPLICATION_NPB, Protocol: intPtr(256)}, wantErr: true},
+		{name: "invalid vlan", acl: model.FlowACLCreate{Application: tcommon.APPLICATION_NPB, Vlan: 4096}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := refs.validateFlowACL(&tt.acl)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateFlowACL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.acl.TapType != DEFAULT_ACL_TAP_TYPE {
+				t.Errorf("validateFlowACL() tap type = %d, want default %d", tt.acl.TapType, DEFAULT_ACL_TAP_TYPE)
+			}
+		})
+	}
+}
+
+func TestFlowACLGroups(t *testing.T) {
+	refs := newTestPolicyRefs()
+	aclCreate := model.FlowACLCreate{Application: tcommon.APPLICATION_NPB, SrcGroupIDs: []int{2, 1, 2}}
+	if err := refs.validateFlowACL(&aclCreate); err != nil {
+		t.Fatalf("validateFlowACL() error = %v", err)
+	}
+	var acl mysql.ACL
+	fillACL(&acl, aclCreate)
+	if acl.SrcGroupIDs != "1,2" || acl.DstGroupIDs != "" {
+		t.Errorf("fillACL() groups = (%q, %q), want (\"1,2\", \"\")", acl.SrcGroupIDs, acl.DstGroupIDs)
+	}
+
+	// 其他入口写入的 acl 可能引用多个资源组，读取和修改时都要保留
+	got := aclToFlowACLCreate(&mysql.ACL{Applications: "6", SrcGroupIDs: "1,2", DstGroupIDs: "2"})
+	if !reflect.DeepEqual(got.SrcGroupIDs, []int{1, 2}) || !reflect.DeepEqual(got.DstGroupIDs, []int{2}) {
+		t.Errorf("aclToFlowACLCreate() groups = (%v, %v), want ([1 2], [2])", got.SrcGroupIDs, got.DstGroupIDs)
+	}
+	got = aclToFlowACLCreate(&mysql.ACL{Applications: "6"})
+	if len(got.SrcGroupIDs) != 0 || len(got.DstGroupIDs) != 0 {
+		t.Errorf("aclToFlowACLCreate() groups = (%v, %v), want any", got.SrcGroupIDs, got.DstGroupIDs)
+	}
+}
+
+func TestValidateNpbPolicy(t *testing.T) {
+	refs := newTestPolicyRefs()
+	tests := []struct {
+		name    string
+		policy  model.NpbPolicyCreate
+		wantErr bool
+	}{
+		{name: "vxlan", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 1, Vni: MAX_VXLAN_VNI, VTapIDs: []int{2, 1, 2}}},
+		{name: "erspan", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 2, Vni: MAX_ERSPAN_ID, PayloadSlice: intPtr(128)}},
+		{name: "erspan vni overflow", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 2, Vni: MAX_ERSPAN_ID + 1}, wantErr: true},
+		{name: "pcap acl", policy: model.NpbPolicyCreate{ACLID: 2, NpbTunnelID: 1}, wantErr: true},
+		{name: "unknown acl", policy: model.NpbPolicyCreate{ACLID: 3, NpbTunnelID: 1}, wantErr: true},
+		{name: "unknown tunnel", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 3}, wantErr: true},
+		{name: "invalid direction", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 1, Direction: 4}, wantErr: true},
+		{name: "invalid distribute", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 1, Distribute: intPtr(2)}, wantErr: true},
+		{name: "invalid payload slice", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 1, PayloadSlice: intPtr(-1)}, wantErr: true},
+		{name: "unknown vtap", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 1, VTapIDs: []int{3}}, wantErr: true},
+		{name: "tap side both", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 1, TapSide: common.POLICY_TAP_SIDE_BOTH}},
+		{name: "invalid tap side", policy: model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 1, TapSide: 4}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := refs.validateNpbPolicy(&tt.policy); (err != nil) != tt.wantErr {
+				t.Errorf("validateNpbPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	policy := model.NpbPolicyCreate{ACLID: 1, NpbTunnelID: 1, VTapIDs: []int{2, 1, 2}}
+	if err := refs.validateNpbPolicy(&policy); err != nil {
+		t.Fatalf("validateNpbPolicy() error = %v", err)
+	}
+	if policy.Direction != common.NPB_POLICY_DIRECTION_ALL || policy.TapSide != common.POLICY_TAP_SIDE_SRC ||
+		!reflect.DeepEqual(policy.VTapIDs, []int{1, 2}) {
+		t.Errorf("validateNpbPolicy() = %+v, want default direction, default tap side and sorted unique vtap ids", policy)
+	}
+}
+
+func TestValidatePcapPolicy(t *testing.T) {
+	refs := newTestPolicyRefs()
+	tests := []struct {
+		name    string
+		policy  model.PcapPolicyCreate
+		wantErr bool
+	}{
+		{name: "pcap", policy: model.PcapPolicyCreate{ACLID: 2, PayloadSlice: intPtr(MAX_PAYLOAD_SLICE)}},
+		{name: "npb acl", policy: model.PcapPolicyCreate{ACLID: 1}, wantErr: true},
+		{name: "invalid payload slice", policy: model.PcapPolicyCreate{ACLID: 2, PayloadSlice: intPtr(MAX_PAYLOAD_SLICE + 1)}, wantErr: true},
+		{name: "unknown vtap", policy: model.PcapPolicyCreate{ACLID: 2, VTapIDs: []int{1, 3}}, wantErr: true},
+		{name: "tap side dst", policy: model.PcapPolicyCreate{ACLID: 2, TapSide: common.POLICY_TAP_SIDE_DST}},
+		{name: "invalid tap side", policy: model.PcapPolicyCreate{ACLID: 2, TapSide: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := refs.validatePcapPolicy(&tt.policy); (err != nil) != tt.wantErr {
+				t.Errorf("validatePcapPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreatePolicy(t *testing.T) {
+	db, err := gorm.Open(
+		sqlite.Open("file:create_policy?mode=memory&cache=shared"),
+		&gorm.Config{NamingStrategy: schema.NamingStrategy{SingularTable: true}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&mysql.PcapPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	origDb := mysql.Db
+	defer func() { mysql.Db = origDb }()
+	mysql.Db = db
+
+	pcapPolicy := mysql.PcapPolicy{Name: "pcap", Lcuuid: "pcap"}
+	if err := createPolicy(&pcapPolicy, &pcapPolicy.ID); err != nil {
+		t.Fatalf("createPolicy() error = %v", err)
+	}
+	var created mysql.PcapPolicy
+	db.Where("lcuuid = ?", "pcap").First(&created)
+	if created.ID == 0 || created.PolicyACLGroupID != created.ID {
+		t.Errorf("createPolicy() policy_acl_group_id = %d, want %d", created.PolicyACLGroupID, created.ID)
+	}
+
+	// 分配 policy_acl_group_id 失败时不应留下策略
+	db.Callback().Update().Before("gorm:update").Register("fail_update", func(tx *gorm.DB) {
+		tx.AddError(gorm.ErrInvalidData)
+	})
+	defer db.Callback().Update().Remove("fail_update")
+	failed := mysql.PcapPolicy{Name: "failed", Lcuuid: "failed"}
+	if err := createPolicy(&failed, &failed.ID); err == nil {
+		t.Fatal("createPolicy() error = nil, want update error")
+	}
+	var count int64
+	db.Model(&mysql.PcapPolicy{}).Where("lcuuid = ?", "failed").Count(&count)
+	if count != 0 {
+		t.Errorf("createPolicy() left %d policies after failure, want 0", count)
+	}
+}
+
+func TestValidateNpbTunnel(t *testing.T) {
+	tests := []struct {
+		name    string
+		tunnel  model.NpbTunnelCreate
+		wantErr bool
+	}{
+		{name: "ipv4", tunnel: model.NpbTunnelCreate{IP: "10.1.1.1"}},
+		{name: "ipv6 erspan", tunnel: model.NpbTunnelCreate{IP: "fe80::1", Type: common.NPB_TUNNEL_TYPE_ERSPAN, VNIInputType: 2}},
+		{name: "invalid ip", tunnel: model.NpbTunnelCreate{IP: "10.1.1"}, wantErr: true},
+		{name: "invalid type", tunnel: model.NpbTunnelCreate{IP: "10.1.1.1", Type: 2}, wantErr: true},
+		{name: "invalid vni input type", tunnel: model.NpbTunnelCreate{IP: "10.1.1.1", VNIInputType: 3}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateNpbTunnel(&tt.tunnel); (err != nil) != tt.wantErr {
+				t.Errorf("validateNpbTunnel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
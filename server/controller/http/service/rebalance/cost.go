@@ -0,0 +1,649 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rebalance
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	"github.com/deepflowio/deepflow/server/controller/monitor/config"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+	querierconfig "github.com/deepflowio/deepflow/server/querier/config"
+)
+
+const (
+	PLAN_STATE_PENDING = iota
+	PLAN_STATE_APPLIED
+	PLAN_STATE_DISCARDED
+	PLAN_STATE_EXPIRED
+)
+
+const (
+	PLAN_SOURCE_MANUAL   = "manual"
+	PLAN_SOURCE_SCHEDULE = "schedule"
+)
+
+const (
+	MOVE_REASON_UNASSIGNED = "analyzer unavailable"
+	MOVE_REASON_OVERLOAD   = "analyzer overloaded"
+
+	MOVE_RESULT_APPLIED = "applied"
+)
+
+// stats names of GetAnalyzerStats
+const (
+	statsCPUPercent       = "cpu_percent"
+	statsMemory           = "memory"
+	statsQueueIn          = "queue_in"
+	statsQueueOverwritten = "queue_overwritten"
+	statsDiskUsedPercent  = "disk_used_percent"
+)
+
+const (
+	// 资源压力只关注数据节点最近的状态
+	analyzerStatsDuration = 600 // unit: s
+	// 压力达到 1 的数据节点仍保留的容量，避免期望流量为 0
+	minAnalyzerCapacity = 0.1
+	// 迁移收益低于该值时不迁移，避免采集器来回切换
+	minMoveGain = 0.01
+)
+
+var analyzerStatsSQLs = []string{
+	"SELECT `tag.host`, Avg(`metrics.cpu_percent`) AS `" + statsCPUPercent + "`, Avg(`metrics.memory`) AS `" + statsMemory + "`" +
+		" FROM deepflow_server_monitor WHERE `time`>%d AND `time`<%d GROUP BY tag.host",
+	"SELECT `tag.host`, Sum(`metrics.in`) AS `" + statsQueueIn + "`, Sum(`metrics.overwritten`) AS `" + statsQueueOverwritten + "`" +
+		" FROM deepflow_server_ingester_queue WHERE `time`>%d AND `time`<%d GROUP BY tag.host",
+	"SELECT `tag.host`, Max(`metrics.disk_used_percent`) AS `" + statsDiskUsedPercent + "`" +
+		" FROM deepflow_server_ingester_ckmonitor WHERE `time`>%d AND `time`<%d GROUP BY tag.host",
+}
+
+var resourceEventQueue *queue.OverwriteQueue
+
+// SetResourceEventQueue 设置均衡事件的发送队列，未设置时只记录日志
+func SetResourceEventQueue(q *queue.OverwriteQueue) {
+	resourceEventQueue = q
+}
+
+// RebalanceAnalyzerByCost 与 RebalanceAnalyzerByTraffic 一样在可用区内均衡采集器，区别在于：
+//   - 数据节点的期望流量按容量分配，容量 = 1 - 压力，压力由 CPU、内存、队列丢弃及 ClickHouse 磁盘使用率加权得出
+//   - 迁移到非本可用区的数据节点需要付出跨可用区代价
+//   - 磁盘使用率超过水位的数据节点不再接收采集器
+//   - 每轮最多迁移 maxMoves 个采集器，所在数据节点不可用的采集器不受限制
+//
+// 只生成迁移计划，不修改数据库
+func (r *AnalyzerInfo) RebalanceAnalyzerByCost(cfg config.CostAwareRebalance, dataDuration, maxMoves int) (
+	[]*model.AnalyzerRebalanceMove, []*model.AnalyzerRebalanceLoad, error) {
+	if r.dbInfo == nil {
+		r.dbInfo = &DBInfo{}
+		err := r.dbInfo.Get()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	info := r.dbInfo
+
+	regionToAZLcuuids := make(map[string][]string)
+	for _, az := range info.AZs {
+		regionToAZLcuuids[az.Region] = append(regionToAZLcuuids[az.Region], az.Lcuuid)
+	}
+	azToVTaps := make(map[string][]*mysql.VTap)
+	for i, vtap := range info.VTaps {
+		azToVTaps[vtap.AZ] = append(azToVTaps[vtap.AZ], &info.VTaps[i])
+	}
+	ipToAnalyzer := make(map[string]*mysql.Analyzer)
+	for i, analyzer := range info.Analyzers {
+		ipToAnalyzer[analyzer.IP] = &info.Analyzers[i]
+	}
+	azToAnalyzers := GetAZToAnalyzers(info.AZAnalyzerConns, regionToAZLcuuids, ipToAnalyzer)
+	azToLocalIPs := make(map[string]map[string]bool)
+	for _, conn := range info.AZAnalyzerConns {
+		if conn.AZ == "ALL" {
+			continue
+		}
+		if _, ok := azToLocalIPs[conn.AZ]; !ok {
+			azToLocalIPs[conn.AZ] = make(map[string]bool)
+		}
+		azToLocalIPs[conn.AZ][conn.AnalyzerIP] = true
+	}
+
+	if r.regionToVTapNameToTraffic == nil {
+		regionToVTapNameToTraffic, err := r.getVTapTraffic(dataDuration, regionToAZLcuuids)
+		if err != nil {
+			return nil, nil, fmt.Errorf("get traffic data failed: %v", err)
+		}
+		r.regionToVTapNameToTraffic = regionToVTapNameToTraffic
+	}
+	hostToStats := r.getAnalyzerStats()
+
+	if maxMoves <= 0 {
+		maxMoves = len(info.VTaps)
+	}
+	moves := []*model.AnalyzerRebalanceMove{}
+	loads := []*model.AnalyzerRebalanceLoad{}
+	for _, az := range info.AZs {
+		azVTaps, ok := azToVTaps[az.Lcuuid]
+		if !ok {
+			continue
+		}
+		azAnalyzers, ok := azToAnalyzers[az.Lcuuid]
+		if !ok {
+			continue
+		}
+		vTapIDToTraffic := make(map[int]int64, len(azVTaps))
+		vtapNameToID := make(map[string]int, len(azVTaps))
+		for _, vtap := range azVTaps {
+			vtapNameToID[vtap.Name] = vtap.ID
+		}
+		for vtapName, traffic := range r.regionToVTapNameToTraffic[az.Region] {
+			if vtapID, ok := vtapNameToID[vtapName]; ok {
+				vTapIDToTraffic[vtapID] = traffic
+			}
+		}
+
+		p := newAZCostPlanner(cfg, az.Lcuuid, azVTaps, vTapIDToTraffic, azAnalyzers, azToLocalIPs[az.Lcuuid], hostToStats)
+		azMoves, balanceMoveNum := p.plan(maxMoves)
+		maxMoves -= balanceMoveNum
+		moves = append(moves, azMoves...)
+		loads = append(loads, p.loads()...)
+	}
+	for _, move := range moves {
+		log.Infof("az(%s) vtap(%s) rebalance plan: %s -> %s, reason: %s", move.AZ, move.VTapName, move.OldIP, move.NewIP, move.Reason)
+	}
+	return moves, loads, nil
+}
+
+func (r *AnalyzerInfo) getAnalyzerStats() map[string]map[string]float64 {
+	hostToStats := make(map[string]map[string]float64)
+	for region, domainPrefix := range r.getRegionToDomainPrefix() {
+		stats, err := r.query.GetAnalyzerStats(domainPrefix, analyzerStatsDuration)
+		if err != nil {
+			log.Errorf("get analyzer stats failed, region(%s), err: %s", region, err)
+			continue
+		}
+		for host, s := range stats {
+			hostToStats[host] = s
+		}
+	}
+	return hostToStats
+}
+
+type analyzerCost struct {
+	analyzer *mysql.Analyzer
+	local    bool
+	complete bool
+	diskFull bool
+	capacity float64
+	target   float64 // 期望接收的流量
+	traffic  int64
+	vtaps    []*vtapCost
+	load     *model.AnalyzerRebalanceLoad
+}
+
+type vtapCost struct {
+	vtap    *mysql.VTap
+	traffic int64
+	move    *model.AnalyzerRebalanceMove
+}
+
+type azCostPlanner struct {
+	cfg          config.CostAwareRebalance
+	az           string
+	vtaps        []*vtapCost
+	unassigned   []*vtapCost
+	analyzers    []*analyzerCost
+	completeNum  int
+	meanTarget   float64
+	totalTraffic int64
+}
+
+func newAZCostPlanner(cfg config.CostAwareRebalance, az string, vtaps []*mysql.VTap, vTapIDToTraffic map[int]int64,
+	analyzers []*mysql.Analyzer, localIPs map[string]bool, hostToStats map[string]map[string]float64) *azCostPlanner {
+	p := &azCostPlanner{cfg: cfg, az: az}
+
+	// 没有流量数据的采集器(如新增采集器)按已有采集器的平均流量估算
+	var sumTraffic, trafficNum int64
+	for _, vtap := range vtaps {
+		if traffic := vTapIDToTraffic[vtap.ID]; traffic > 0 {
+			sumTraffic += traffic
+			trafficNum++
+		}
+	}
+	virtualTraffic := int64(100)
+	if trafficNum > 0 {
+		virtualTraffic = int64(math.Max(float64(sumTraffic/trafficNum), 1))
+	}
+	for _, vtap := range vtaps {
+		traffic := vTapIDToTraffic[vtap.ID]
+		if traffic <= 0 {
+			traffic = virtualTraffic
+		}
+		p.vtaps = append(p.vtaps, &vtapCost{vtap: vtap, traffic: traffic})
+		p.totalTraffic += traffic
+	}
+	sort.Slice(p.vtaps, func(i, j int) bool { return p.vtaps[i].vtap.ID < p.vtaps[j].vtap.ID })
+
+	// 可用区没有显式关联的数据节点时，所有数据节点都视为本可用区
+	hasLocal := false
+	for _, analyzer := range analyzers {
+		if localIPs[analyzer.IP] {
+			hasLocal = true
+			break
+		}
+	}
+	ipToAnalyzer := make(map[string]*analyzerCost, len(analyzers))
+	var capacitySum float64
+	for _, analyzer := range analyzers {
+		if _, ok := ipToAnalyzer[analyzer.IP]; ok {
+			continue
+		}
+		load := &model.AnalyzerRebalanceLoad{IP: analyzer.IP, AZ: az, State: analyzer.State}
+		pressure := analyzerPressure(cfg, analyzer, getHostStats(hostToStats, analyzer), load)
+		a := &analyzerCost{
+			analyzer: analyzer,
+			local:    !hasLocal || localIPs[analyzer.IP],
+			complete: analyzer.State == common.HOST_STATE_COMPLETE,
+			diskFull: cfg.DiskHighWatermark > 0 && load.DiskUsage*100 >= cfg.DiskHighWatermark,
+			load:     load,
+		}
+		if a.complete {
+			a.capacity = math.Max(minAnalyzerCapacity, 1-pressure)
+			capacitySum += a.capacity
+			p.completeNum++
+		}
+		ipToAnalyzer[analyzer.IP] = a
+		p.analyzers = append(p.analyzers, a)
+	}
+	sort.Slice(p.analyzers, func(i, j int) bool { return p.analyzers[i].analyzer.IP < p.analyzers[j].analyzer.IP })
+	if p.completeNum == 0 {
+		return p
+	}
+	p.meanTarget = float64(p.totalTraffic) / float64(p.completeNum)
+	for _, a := range p.analyzers {
+		if a.complete {
+			a.target = float64(p.totalTraffic) * a.capacity / capacitySum
+		}
+	}
+
+	for _, v := range p.vtaps {
+		a, ok := ipToAnalyzer[v.vtap.AnalyzerIP]
+		if ok {
+			a.load.BeforeVTapNum++
+		}
+		if !ok || !a.complete {
+			p.unassigned = append(p.unassigned, v)
+			continue
+		}
+		a.traffic += v.traffic
+		a.vtaps = append(a.vtaps, v)
+	}
+	for _, a := range p.analyzers {
+		a.load.BeforeLoad = round2(p.ratio(a, 0))
+	}
+	return p
+}
+
+// analyzerPressure 计算数据节点压力(0~1)，并记录各项资源使用率
+func analyzerPressure(cfg config.CostAwareRebalance, analyzer *mysql.Analyzer, stats map[string]float64,
+	load *model.AnalyzerRebalanceLoad) float64 {
+	cpuNum := analyzer.CPUNum
+	if cpuNum <= 0 {
+		cpuNum = 1
+	}
+	load.CPUUsage = round2(clamp(stats[statsCPUPercent] / float64(100*cpuNum)))
+	if analyzer.MemorySize > 0 {
+		load.MemoryUsage = round2(clamp(stats[statsMemory] / float64(analyzer.MemorySize)))
+	}
+	if stats[statsQueueIn] > 0 {
+		load.QueueDropRatio = round2(clamp(stats[statsQueueOverwritten] / stats[statsQueueIn]))
+	}
+	load.DiskUsage = round2(clamp(stats[statsDiskUsedPercent] / 100))
+
+	weightSum := cfg.CPUWeight + cfg.MemoryWeight + cfg.QueueDropWeight + cfg.DiskWeight
+	if weightSum <= 0 {
+		return 0
+	}
+	pressure := (cfg.CPUWeight*load.CPUUsage + cfg.MemoryWeight*load.MemoryUsage +
+		cfg.QueueDropWeight*load.QueueDropRatio + cfg.DiskWeight*load.DiskUsage) / weightSum
+	load.Pressure = round2(pressure)
+	return pressure
+}
+
+// getHostStats 统计数据中的 host 为 deepflow-server 所在主机名，与数据节点名称或 pod 名称一致
+func getHostStats(hostToStats map[string]map[string]float64, analyzer *mysql.Analyzer) map[string]float64 {
+	if stats, ok := hostToStats[analyzer.Name]; ok {
+		return stats
+	}
+	if stats, ok := hostToStats[analyzer.PodName]; ok && analyzer.PodName != "" {
+		return stats
+	}
+	return nil
+}
+
+// ratio 返回数据节点增加 delta 流量后的负载，即实际流量 / 期望流量
+func (p *azCostPlanner) ratio(a *analyzerCost, delta int64) float64 {
+	if a.target <= 0 {
+		return 0
+	}
+	return float64(a.traffic+delta) / a.target
+}
+
+// crossAZCost 将跨可用区代价换算为负载，迁移流量占平均期望流量的比例越大代价越高
+func (p *azCostPlanner) crossAZCost(v *vtapCost, a *analyzerCost) float64 {
+	if a.local || p.meanTarget <= 0 {
+		return 0
+	}
+	return p.cfg.CrossAZWeight * float64(v.traffic) / p.meanTarget
+}
+
+func (p *azCostPlanner) canReceive(a *analyzerCost) bool {
+	return a.complete && !a.diskFull
+}
+
+// plan 返回迁移计划及其中因负载不均衡产生的迁移次数
+func (p *azCostPlanner) plan(maxMoves int) ([]*model.AnalyzerRebalanceMove, int) {
+	if len(p.vtaps) == 0 {
+		return nil, 0
+	}
+	if p.completeNum == 0 {
+		log.Warningf("no complete analyzer to rebalance vtaps, az(%v)", p.az)
+		return nil, 0
+	}
+
+	// 所在数据节点不可用的采集器必须迁移，按流量从大到小分配
+	sort.SliceStable(p.unassigned, func(i, j int) bool { return p.unassigned[i].traffic > p.unassigned[j].traffic })
+	for _, v := range p.unassigned {
+		if dst := p.bestDestination(v); dst != nil {
+			p.move(v, nil, dst, MOVE_REASON_UNASSIGNED)
+		}
+	}
+
+	balanceMoveNum := 0
+	for balanceMoveNum < maxMoves {
+		v, src, dst := p.bestMove()
+		if v == nil {
+			break
+		}
+		p.move(v, src, dst, MOVE_REASON_OVERLOAD)
+		balanceMoveNum++
+	}
+
+	var moves []*model.AnalyzerRebalanceMove
+	for _, v := range p.vtaps {
+		if v.move != nil && v.move.OldIP != v.move.NewIP {
+			moves = append(moves, v.move)
+		}
+	}
+	return moves, balanceMoveNum
+}
+
+func (p *azCostPlanner) bestDestination(v *vtapCost) *analyzerCost {
+	// 所有数据节点磁盘都超过水位时，仍需为采集器分配数据节点
+	hasReceiver := false
+	for _, a := range p.analyzers {
+		if p.canReceive(a) {
+			hasReceiver = true
+			break
+		}
+	}
+	var best *analyzerCost
+	var bestCost float64
+	for _, a := range p.analyzers {
+		if !a.complete || (hasReceiver && a.diskFull) {
+			continue
+		}
+		cost := p.ratio(a, v.traffic) + p.crossAZCost(v, a)
+		if best == nil || cost < bestCost {
+			best, bestCost = a, cost
+		}
+	}
+	return best
+}
+
+// bestMove 在负载超过 1 + tolerance 的数据节点上选择一个采集器迁出，使迁移双方的最大负载下降最多，
+// 数据节点只剩一个采集器时不再迁出
+func (p *azCostPlanner) bestMove() (*vtapCost, *analyzerCost, *analyzerCost) {
+	var bestVTap *vtapCost
+	var bestSrc, bestDst *analyzerCost
+	bestGain := minMoveGain
+	for _, src := range p.analyzers {
+		if !src.complete || len(src.vtaps) < 2 {
+			continue
+		}
+		srcRatio := p.ratio(src, 0)
+		if srcRatio <= 1+p.cfg.Tolerance {
+			continue
+		}
+		for _, v := range src.vtaps {
+			for _, dst := range p.analyzers {
+				if dst == src || !p.canReceive(dst) {
+					continue
+				}
+				after := math.Max(p.ratio(src, -v.traffic), p.ratio(dst, v.traffic))
+				gain := srcRatio - after - (p.crossAZCost(v, dst) - p.crossAZCost(v, src))
+				if gain > bestGain {
+					bestGain, bestVTap, bestSrc, bestDst = gain, v, src, dst
+				}
+			}
+		}
+	}
+	return bestVTap, bestSrc, bestDst
+}
+
+func (p *azCostPlanner) move(v *vtapCost, src, dst *analyzerCost, reason string) {
+	if src != nil {
+		src.traffic -= v.traffic
+		for i := range src.vtaps {
+			if src.vtaps[i] == v {
+				src.vtaps = append(src.vtaps[:i], src.vtaps[i+1:]...)
+				break
+			}
+		}
+	}
+	dst.traffic += v.traffic
+	dst.vtaps = append(dst.vtaps, v)
+
+	// 同一采集器在一轮中多次迁移时，只保留最终结果
+	if v.move == nil {
+		v.move = &model.AnalyzerRebalanceMove{
+			VTapID:   v.vtap.ID,
+			VTapName: v.vtap.Name,
+			AZ:       p.az,
+			OldIP:    v.vtap.AnalyzerIP,
+			Traffic:  v.traffic,
+			Reason:   reason,
+		}
+	}
+	v.move.NewIP = dst.analyzer.IP
+	v.move.CrossAZ = !dst.local
+}
+
+func (p *azCostPlanner) loads() []*model.AnalyzerRebalanceLoad {
+	loads := make([]*model.AnalyzerRebalanceLoad, 0, len(p.analyzers))
+	for _, a := range p.analyzers {
+		a.load.AfterVTapNum = len(a.vtaps)
+		a.load.AfterLoad = round2(p.ratio(a, 0))
+		loads = append(loads, a.load)
+	}
+	return loads
+}
+
+// ApplyRebalanceMoves 按计划修改采集器的数据节点并发送事件，返回实际迁移的采集器个数。
+// 采集器当前的数据节点与生成计划时不一致，或目标数据节点不可用时跳过
+func ApplyRebalanceMoves(planName string, moves []*model.AnalyzerRebalanceMove) int {
+	var analyzers []mysql.Analyzer
+	if err := mysql.Db.Find(&analyzers).Error; err != nil {
+		log.Error(err)
+		return 0
+	}
+	ipToState := make(map[string]int, len(analyzers))
+	for _, analyzer := range analyzers {
+		ipToState[analyzer.IP] = analyzer.State
+	}
+
+	appliedNum := 0
+	for _, move := range moves {
+		if move.Result != "" {
+			continue
+		}
+		if state, ok := ipToState[move.NewIP]; !ok || state != common.HOST_STATE_COMPLETE {
+			move.Result = fmt.Sprintf("skipped, analyzer(%s) is not available", move.NewIP)
+			continue
+		}
+		result := mysql.Db.Model(&mysql.VTap{}).Where("id = ? AND analyzer_ip = ?", move.VTapID, move.OldIP).
+			Update("analyzer_ip", move.NewIP)
+		if result.Error != nil {
+			move.Result = fmt.Sprintf("failed, %s", result.Error.Error())
+			continue
+		}
+		if result.RowsAffected == 0 {
+			move.Result = "skipped, vtap was deleted or its analyzer changed after the plan was created"
+			continue
+		}
+		move.Result = MOVE_RESULT_APPLIED
+		appliedNum++
+
+		description := fmt.Sprintf("agent(%s) analyzer changed from %s to %s by rebalance plan(%s), reason: %s",
+			move.VTapName, move.OldIP, move.NewIP, planName, move.Reason)
+		if move.CrossAZ {
+			description += ", cross az"
+		}
+		SendRebalanceEvent(move.VTapName, description)
+	}
+	return appliedNum
+}
+
+// SendRebalanceEvent 将均衡结果作为资源事件发送
+func SendRebalanceEvent(instanceName, description string) {
+	log.Info(description)
+	if resourceEventQueue == nil {
+		return
+	}
+	now := time.Now()
+	event := eventapi.AcquireResourceEvent()
+	event.Time = now.Unix()
+	event.TimeMilli = now.UnixMilli()
+	event.Type = eventapi.RESOURCE_EVENT_TYPE_ANALYZER_REBALANCE
+	event.InstanceName = instanceName
+	event.Description = description
+	if err := resourceEventQueue.Put(event); err != nil {
+		log.Warningf("put analyzer rebalance event failed: %s", err)
+		event.Release()
+	}
+}
+
+func (q *Query) GetAnalyzerStats(domainPrefix string, dataDuration int) (map[string]map[string]float64, error) {
+	now := time.Now()
+	before := now.UTC().Add(time.Second * -1 * time.Duration(dataDuration))
+	hostToStats := make(map[string]map[string]float64)
+	for _, sqlFormat := range analyzerStatsSQLs {
+		sql := fmt.Sprintf(sqlFormat, before.Unix(), now.Unix())
+		body, err := querySystemDB(domainPrefix, sql)
+		if err == nil {
+			err = parseHostStats(body, hostToStats)
+		}
+		// 部分统计数据缺失时(如未启用 ClickHouse 磁盘监控)，仍使用其余数据
+		if err != nil {
+			log.Warningf("query analyzer stats failed, sql: %s, err: %s", sql, err)
+		}
+	}
+	return hostToStats, nil
+}
+
+func querySystemDB(domainPrefix, sql string) ([]byte, error) {
+	if domainPrefix == "master-" {
+		domainPrefix = ""
+	}
+	queryURL := fmt.Sprintf("http://%sdeepflow-server:%d/v1/query", domainPrefix, querierconfig.Cfg.ListenPort)
+	values := url.Values{}
+	values.Add("db", "deepflow_system")
+	values.Add("sql", sql)
+	resp, err := http.PostForm(queryURL, values)
+	if err != nil {
+		return nil, fmt.Errorf("curl (%s) failed, err: %s", queryURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("curl (%s) failed, status code: %d, body: %s", queryURL, resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// parseHostStats 解析按 tag.host 分组的查询结果，其余列按列名记录到 hostToStats
+func parseHostStats(data []byte, hostToStats map[string]map[string]float64) error {
+	respJson, err := simplejson.NewJson(data)
+	if err != nil {
+		return err
+	}
+	optStatus := respJson.Get("OPT_STATUS").MustString()
+	if optStatus != "" && optStatus != "SUCCESS" {
+		return errors.New(respJson.Get("DESCRIPTION").MustString())
+	}
+
+	result := respJson.Get("result")
+	columns := result.Get("columns").MustArray()
+	hostIndex := -1
+	for i := range columns {
+		if result.Get("columns").GetIndex(i).MustString() == tagHost {
+			hostIndex = i
+			break
+		}
+	}
+	if hostIndex < 0 {
+		return fmt.Errorf("column %s not found", tagHost)
+	}
+	values := result.Get("values")
+	for i := range values.MustArray() {
+		value := values.GetIndex(i)
+		host := value.GetIndex(hostIndex).MustString()
+		if _, ok := hostToStats[host]; !ok {
+			hostToStats[host] = make(map[string]float64)
+		}
+		for j := range columns {
+			if j == hostIndex {
+				continue
+			}
+			hostToStats[host][result.Get("columns").GetIndex(j).MustString()] = value.GetIndex(j).MustFloat64()
+		}
+	}
+	return nil
+}
+
+func clamp(v float64) float64 {
+	return math.Min(math.Max(v, 0), 1)
+}
+
+func round2(v float64) float64 {
+	w, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", v), 64)
+	return w
+}
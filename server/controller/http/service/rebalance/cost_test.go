@@ -0,0 +1,231 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rebalance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	"github.com/deepflowio/deepflow/server/controller/monitor/config"
+)
+
+var testCostAwareConfig = config.CostAwareRebalance{
+	MaxMovesPerRound:  10,
+	Tolerance:         0.1,
+	CPUWeight:         1,
+	MemoryWeight:      1,
+	QueueDropWeight:   2,
+	DiskWeight:        1,
+	DiskHighWatermark: 85,
+	CrossAZWeight:     0.5,
+}
+
+type moveResult struct {
+	vtapID int
+	newIP  string
+}
+
+func TestAZCostPlanner_plan(t *testing.T) {
+	type fields struct {
+		vTapIDToTraffic map[int]int64
+		vtaps           []*mysql.VTap
+		analyzers       []*mysql.Analyzer
+		localIPs        map[string]bool
+		hostToStats     map[string]map[string]float64
+	}
+	tests := []struct {
+		name     string
+		cfg      func(config.CostAwareRebalance) config.CostAwareRebalance
+		maxMoves int
+		fields   fields
+		want     []moveResult
+	}{
+		{
+			name:     "balanced",
+			maxMoves: 10,
+			fields: fields{
+				vTapIDToTraffic: map[int]int64{1: 100, 2: 100},
+				vtaps: []*mysql.VTap{
+					{ID: 1, AnalyzerIP: "192.168.0.1"},
+					{ID: 2, AnalyzerIP: "192.168.0.2"},
+				},
+				analyzers: []*mysql.Analyzer{
+					{IP: "192.168.0.1", State: common.HOST_STATE_COMPLETE},
+					{IP: "192.168.0.2", State: common.HOST_STATE_COMPLETE},
+				},
+			},
+			want: nil,
+		},
+		{
+			name:     "move from high pressure analyzer",
+			maxMoves: 10,
+			fields: fields{
+				vTapIDToTraffic: map[int]int64{1: 100, 2: 100, 3: 100, 4: 100},
+				vtaps: []*mysql.VTap{
+					{ID: 1, AnalyzerIP: "192.168.0.1"},
+					{ID: 2, AnalyzerIP: "192.168.0.1"},
+					{ID: 3, AnalyzerIP: "192.168.0.2"},
+					{ID: 4, AnalyzerIP: "192.168.0.2"},
+				},
+				analyzers: []*mysql.Analyzer{
+					{Name: "analyzer-1", IP: "192.168.0.1", State: common.HOST_STATE_COMPLETE, CPUNum: 4},
+					{Name: "analyzer-2", IP: "192.168.0.2", State: common.HOST_STATE_COMPLETE, CPUNum: 4},
+				},
+				hostToStats: map[string]map[string]float64{
+					"analyzer-1": {statsCPUPercent: 360, statsQueueIn: 100, statsQueueOverwritten: 50},
+				},
+			},
+			want: []moveResult{{vtapID: 1, newIP: "192.168.0.2"}},
+		},
+		{
+			name:     "limit moves per round",
+			maxMoves: 1,
+			fields: fields{
+				vTapIDToTraffic: map[int]int64{1: 100, 2: 100, 3: 100, 4: 100},
+				vtaps: []*mysql.VTap{
+					{ID: 1, AnalyzerIP: "192.168.0.1"},
+					{ID: 2, AnalyzerIP: "192.168.0.1"},
+					{ID: 3, AnalyzerIP: "192.168.0.1"},
+					{ID: 4, AnalyzerIP: "192.168.0.1"},
+				},
+				analyzers: []*mysql.Analyzer{
+					{IP: "192.168.0.1", State: common.HOST_STATE_COMPLETE},
+					{IP: "192.168.0.2", State: common.HOST_STATE_COMPLETE},
+				},
+			},
+			want: []moveResult{{vtapID: 1, newIP: "192.168.0.2"}},
+		},
+		{
+			name:     "unassigned vtaps are not limited",
+			maxMoves: 0,
+			fields: fields{
+				vTapIDToTraffic: map[int]int64{1: 100, 2: 100},
+				vtaps: []*mysql.VTap{
+					{ID: 1, AnalyzerIP: "192.168.0.3"},
+					{ID: 2, AnalyzerIP: ""},
+				},
+				analyzers: []*mysql.Analyzer{
+					{IP: "192.168.0.1", State: common.HOST_STATE_COMPLETE},
+					{IP: "192.168.0.2", State: common.HOST_STATE_COMPLETE},
+					{IP: "192.168.0.3", State: common.HOST_STATE_EXCEPTION},
+				},
+			},
+			want: []moveResult{{vtapID: 1, newIP: "192.168.0.1"}, {vtapID: 2, newIP: "192.168.0.2"}},
+		},
+		{
+			name:     "disk full analyzer does not receive vtaps",
+			maxMoves: 10,
+			fields: fields{
+				vTapIDToTraffic: map[int]int64{1: 100, 2: 100, 3: 100},
+				vtaps: []*mysql.VTap{
+					{ID: 1, AnalyzerIP: "192.168.0.1"},
+					{ID: 2, AnalyzerIP: "192.168.0.1"},
+					{ID: 3, AnalyzerIP: "192.168.0.1"},
+				},
+				analyzers: []*mysql.Analyzer{
+					{Name: "analyzer-1", IP: "192.168.0.1", State: common.HOST_STATE_COMPLETE},
+					{Name: "analyzer-2", IP: "192.168.0.2", State: common.HOST_STATE_COMPLETE},
+				},
+				hostToStats: map[string]map[string]float64{
+					"analyzer-2": {statsDiskUsedPercent: 90},
+				},
+			},
+			want: nil,
+		},
+		{
+			name:     "cross az cost prevents small gain",
+			maxMoves: 10,
+			cfg: func(cfg config.CostAwareRebalance) config.CostAwareRebalance {
+				cfg.CrossAZWeight = 5
+				return cfg
+			},
+			fields: fields{
+				vTapIDToTraffic: map[int]int64{1: 100, 2: 100, 3: 100},
+				vtaps: []*mysql.VTap{
+					{ID: 1, AnalyzerIP: "192.168.0.1"},
+					{ID: 2, AnalyzerIP: "192.168.0.1"},
+					{ID: 3, AnalyzerIP: "192.168.0.2"},
+				},
+				analyzers: []*mysql.Analyzer{
+					{IP: "192.168.0.1", State: common.HOST_STATE_COMPLETE},
+					{IP: "192.168.0.2", State: common.HOST_STATE_COMPLETE},
+					{IP: "192.168.0.3", State: common.HOST_STATE_COMPLETE},
+				},
+				localIPs: map[string]bool{"192.168.0.1": true, "192.168.0.2": true},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testCostAwareConfig
+			if tt.cfg != nil {
+				cfg = tt.cfg(cfg)
+			}
+			p := newAZCostPlanner(cfg, "az-1", tt.fields.vtaps, tt.fields.vTapIDToTraffic,
+				tt.fields.analyzers, tt.fields.localIPs, tt.fields.hostToStats)
+			maxMoves := tt.maxMoves
+			if maxMoves <= 0 {
+				maxMoves = len(tt.fields.vtaps)
+			}
+			moves, _ := p.plan(maxMoves)
+			var got []moveResult
+			for _, move := range moves {
+				got = append(got, moveResult{vtapID: move.VTapID, newIP: move.NewIP})
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAnalyzerPressure(t *testing.T) {
+	analyzer := &mysql.Analyzer{CPUNum: 4, MemorySize: 1000}
+	stats := map[string]float64{
+		statsCPUPercent:       200,
+		statsMemory:           500,
+		statsQueueIn:          100,
+		statsQueueOverwritten: 10,
+		statsDiskUsedPercent:  60,
+	}
+	load := &model.AnalyzerRebalanceLoad{}
+	pressure := analyzerPressure(testCostAwareConfig, analyzer, stats, load)
+	assert.Equal(t, 0.5, load.CPUUsage)
+	assert.Equal(t, 0.5, load.MemoryUsage)
+	assert.Equal(t, 0.1, load.QueueDropRatio)
+	assert.Equal(t, 0.6, load.DiskUsage)
+	// (0.5 + 0.5 + 2*0.1 + 0.6) / 5
+	assert.InDelta(t, 0.36, pressure, 0.0001)
+}
+
+func TestParseHostStats(t *testing.T) {
+	body := []byte(`{"OPT_STATUS":"SUCCESS","result":{"columns":["cpu_percent","tag.host","memory"],` +
+		`"values":[[120.5,"analyzer-1",1024],[10,"analyzer-2",2048]]}}`)
+	hostToStats := map[string]map[string]float64{"analyzer-1": {statsDiskUsedPercent: 30}}
+	err := parseHostStats(body, hostToStats)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]map[string]float64{
+		"analyzer-1": {statsCPUPercent: 120.5, statsMemory: 1024, statsDiskUsedPercent: 30},
+		"analyzer-2": {statsCPUPercent: 10, statsMemory: 2048},
+	}, hostToStats)
+
+	err = parseHostStats([]byte(`{"OPT_STATUS":"FAILED","DESCRIPTION":"table not found"}`), hostToStats)
+	assert.EqualError(t, err, "table not found")
+}
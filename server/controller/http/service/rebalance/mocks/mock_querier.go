@@ -47,3 +47,18 @@ func (mr *MockQuerierMockRecorder) GetAgentDispatcher(domainPrefix, dataDuration
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentDispatcher", reflect.TypeOf((*MockQuerier)(nil).GetAgentDispatcher), domainPrefix, dataDuration)
 }
+
+// GetAnalyzerStats mocks base method.
+func (m *MockQuerier) GetAnalyzerStats(domainPrefix string, dataDuration int) (map[string]map[string]float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAnalyzerStats", domainPrefix, dataDuration)
+	ret0, _ := ret[0].(map[string]map[string]float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAnalyzerStats indicates an expected call of GetAnalyzerStats.
+func (mr *MockQuerierMockRecorder) GetAnalyzerStats(domainPrefix, dataDuration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAnalyzerStats", reflect.TypeOf((*MockQuerier)(nil).GetAnalyzerStats), domainPrefix, dataDuration)
+}
@@ -19,4 +19,6 @@ package rebalance
 // //go:generate mockgen -source=query.go -destination=./mocks/mock_querier.go -package=mocks Querier
 type Querier interface {
 	GetAgentDispatcher(domainPrefix string, dataDuration int) (map[string]int64, error)
+	// GetAnalyzerStats returns host -> stats name -> value, stats names see analyzerStatsNames
+	GetAnalyzerStats(domainPrefix string, dataDuration int) (map[string]map[string]float64, error)
 }
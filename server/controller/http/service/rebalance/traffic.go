@@ -417,17 +417,8 @@ func (r *AnalyzerInfo) getVTapTraffic(dataDuration int, regionToAZLcuuids map[st
 		}
 	}
 
-	regionToRegionDomainPrefix := make(map[string]string)
-	for _, conn := range r.dbInfo.AZControllerConns {
-		if _, ok := regionToRegionDomainPrefix[conn.Region]; ok {
-			continue
-		}
-		if controller, ok := ipToController[conn.ControllerIP]; ok {
-			regionToRegionDomainPrefix[conn.Region] = controller.RegionDomainPrefix
-		}
-	}
 	regionToVTapNameToTraffic := make(map[string]map[string]int64)
-	for region, domainPrefix := range regionToRegionDomainPrefix {
+	for region, domainPrefix := range r.getRegionToDomainPrefix() {
 		vtapNameToTraffic, err := r.query.GetAgentDispatcher(domainPrefix, dataDuration)
 		if err != nil {
 			log.Errorf("get query data failed, region(%s), err: %s", region, err)
@@ -442,6 +433,23 @@ func (r *AnalyzerInfo) getVTapTraffic(dataDuration int, regionToAZLcuuids map[st
 	return regionToVTapNameToTraffic, nil
 }
 
+func (r *AnalyzerInfo) getRegionToDomainPrefix() map[string]string {
+	ipToController := make(map[string]*mysql.Controller)
+	for i, controller := range r.dbInfo.Controllers {
+		ipToController[controller.IP] = &r.dbInfo.Controllers[i]
+	}
+	regionToRegionDomainPrefix := make(map[string]string)
+	for _, conn := range r.dbInfo.AZControllerConns {
+		if _, ok := regionToRegionDomainPrefix[conn.Region]; ok {
+			continue
+		}
+		if controller, ok := ipToController[conn.ControllerIP]; ok {
+			regionToRegionDomainPrefix[conn.Region] = controller.RegionDomainPrefix
+		}
+	}
+	return regionToRegionDomainPrefix
+}
+
 type Query struct{}
 
 func (q *Query) GetAgentDispatcher(domainPrefix string, dataDuration int) (map[string]int64, error) {
@@ -28,6 +28,7 @@ import (
 
 	logging "github.com/op/go-logging"
 	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
 	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
@@ -51,6 +52,7 @@ var DOMAIN_PASSWORD_KEYS = map[string]bool{
 	"boss_secret_key":     false,
 	"manage_one_password": false,
 	"token":               false,
+	"service_account_key": false,
 }
 
 func getGrpcServerAndPort(controllerIP string, cfg *config.ControllerConfig) (string, string) {
@@ -106,6 +108,9 @@ func GetDomains(filter map[string]interface{}) (resp []model.Domain, err error)
 	if _, ok := filter["name"]; ok {
 		Db = Db.Where("name = ?", filter["name"])
 	}
+	if _, ok := filter["org_id"]; ok {
+		Db = Db.Where("org_id = ?", filter["org_id"])
+	}
 	Db.Order("created_at DESC").Find(&domains)
 
 	for _, domain := range domains {
@@ -158,6 +163,7 @@ func GetDomains(filter map[string]interface{}) (resp []model.Domain, err error)
 			IconID:       domain.IconID, // 后续与前端沟通icon作为默认配置
 			CreatedAt:    domain.CreatedAt.Format(common.GO_BIRTHDAY),
 			SyncedAt:     syncedAt,
+			OrgID:        domain.OrgID,
 			Lcuuid:       domain.Lcuuid,
 		}
 
@@ -244,6 +250,9 @@ func CreateDomain(domainCreate model.DomainCreate, cfg *config.ControllerConfig)
 		}
 	}
 
+	if domainCreate.OrgID == 0 {
+		domainCreate.OrgID = common.DEFAULT_ORG_ID
+	}
 	log.Infof("create domain (%v)", maskDomainInfo(domainCreate))
 
 	domain := mysql.Domain{}
@@ -255,6 +264,7 @@ func CreateDomain(domainCreate model.DomainCreate, cfg *config.ControllerConfig)
 	domain.Type = domainCreate.Type
 	domain.IconID = domainCreate.IconID
 	domain.State = common.DOMAIN_STATE_NORMAL
+	domain.OrgID = domainCreate.OrgID
 
 	// set region and controller ip if not specified
 	if domainCreate.Config == nil {
@@ -322,9 +332,19 @@ func CreateDomain(domainCreate model.DomainCreate, cfg *config.ControllerConfig)
 		} else {
 			domain.ClusterID = "d-" + common.GenerateShortUUID()
 		}
+	}
+	err := mysql.Db.Transaction(func(tx *gorm.DB) error {
+		if err := servicecommon.CheckOrgQuota(tx, domain.OrgID, &mysql.Domain{}, "domain"); err != nil {
+			return err
+		}
+		return tx.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&domain).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if domainCreate.Type == common.KUBERNETES {
 		createKubernetesRelatedResources(domain, regionLcuuid)
 	}
-	mysql.Db.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&domain)
 
 	response, _ := GetDomains(map[string]interface{}{"lcuuid": lcuuid})
 	return &response[0], nil
@@ -358,12 +378,12 @@ func createKubernetesRelatedResources(domain mysql.Domain, regionLcuuid string)
 }
 
 func UpdateDomain(
-	lcuuid string, domainUpdate map[string]interface{}, cfg *config.ControllerConfig,
+	orgID int, lcuuid string, domainUpdate map[string]interface{}, cfg *config.ControllerConfig,
 ) (*model.Domain, error) {
 	var domain mysql.Domain
 	var dbUpdateMap = make(map[string]interface{})
 
-	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&domain); ret.Error != nil {
+	if ret := mysql.Db.Where("lcuuid = ? AND org_id = ?", lcuuid, orgID).First(&domain); ret.Error != nil {
 		return nil, servicecommon.NewError(
 			httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("domain (%s) not found", lcuuid),
 		)
@@ -478,11 +498,11 @@ func cleanSoftDeletedResource(lcuuid string) {
 	log.Info("clean soft deleted resources completed")
 }
 
-func DeleteDomainByNameOrUUID(nameOrUUID string) (map[string]string, error) {
+func DeleteDomainByNameOrUUID(orgID int, nameOrUUID string) (map[string]string, error) {
 	var domain mysql.Domain
-	err1 := mysql.Db.Where("lcuuid = ?", nameOrUUID).First(&domain).Error
+	err1 := mysql.Db.Where("lcuuid = ? AND org_id = ?", nameOrUUID, orgID).First(&domain).Error
 	var domains []mysql.Domain
-	err2 := mysql.Db.Where("name = ?", nameOrUUID).Find(&domains).Error
+	err2 := mysql.Db.Where("name = ? AND org_id = ?", nameOrUUID, orgID).Find(&domains).Error
 	if err1 == nil && err2 == nil && len(domains) > 0 {
 		return nil, servicecommon.NewError(
 			httpcommon.PARAMETER_ILLEGAL, fmt.Sprintf("remove domain (name: %s, uuid: %s) conflict", nameOrUUID, nameOrUUID),
@@ -592,7 +612,7 @@ func deleteDomain(domain *mysql.Domain) (map[string]string, error) { // TODO whe
 	return map[string]string{"LCUUID": lcuuid}, nil
 }
 
-func KubernetesSetVtap(lcuuid, value string, isSubDomain bool) error {
+func KubernetesSetVtap(orgID int, lcuuid, value string, isSubDomain bool) error {
 	if value == "" {
 		return nil
 	}
@@ -601,18 +621,22 @@ func KubernetesSetVtap(lcuuid, value string, isSubDomain bool) error {
 	var clusterID, domainLcuuid, subDomainLcuuid string
 	if isSubDomain {
 		var subDomain mysql.SubDomain
-		err = mysql.Db.Where("lcuuid = ?", lcuuid).First(&subDomain).Error
+		err = orgSubDomains(orgID).Where("lcuuid = ?", lcuuid).First(&subDomain).Error
 		if err != nil {
-			return err
+			return servicecommon.NewError(
+				httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("sub_domain (%s) not found", lcuuid),
+			)
 		}
 		clusterID = subDomain.ClusterID
 		domainLcuuid = subDomain.Domain
 		subDomainLcuuid = lcuuid
 	} else {
 		var domain mysql.Domain
-		err = mysql.Db.Where("lcuuid = ?", lcuuid).First(&domain).Error
+		err = mysql.Db.Where("lcuuid = ? AND org_id = ?", lcuuid, orgID).First(&domain).Error
 		if err != nil {
-			return err
+			return servicecommon.NewError(
+				httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("domain (%s) not found", lcuuid),
+			)
 		}
 		clusterID = domain.ClusterID
 		domainLcuuid = lcuuid
@@ -676,12 +700,20 @@ func KubernetesSetVtap(lcuuid, value string, isSubDomain bool) error {
 	return nil
 }
 
+// orgSubDomains 子域没有组织字段，通过所属的域归属到组织
+func orgSubDomains(orgID int) *gorm.DB {
+	return mysql.Db.Where("domain IN (?)", mysql.Db.Model(&mysql.Domain{}).Select("lcuuid").Where("org_id = ?", orgID))
+}
+
 func GetSubDomains(filter map[string]interface{}) ([]*model.SubDomain, error) {
 	var response []*model.SubDomain
 	var subDomains []mysql.SubDomain
 	var vpcs []mysql.VPC
 
 	Db := mysql.Db
+	if orgID, ok := filter["org_id"]; ok {
+		Db = orgSubDomains(orgID.(int))
+	}
 	if _, ok := filter["lcuuid"]; ok {
 		Db = Db.Where("lcuuid = ?", filter["lcuuid"])
 	}
@@ -753,7 +785,10 @@ func GetSubDomains(filter map[string]interface{}) ([]*model.SubDomain, error) {
 
 func CreateSubDomain(subDomainCreate model.SubDomainCreate) (*model.SubDomain, error) {
 	var domainCount int64
-	if err := mysql.Db.Model(&mysql.Domain{}).Where("lcuuid = ?", subDomainCreate.Domain).Count(&domainCount).Error; err != nil {
+	if subDomainCreate.OrgID == 0 {
+		subDomainCreate.OrgID = common.DEFAULT_ORG_ID
+	}
+	if err := mysql.Db.Model(&mysql.Domain{}).Where("lcuuid = ? AND org_id = ?", subDomainCreate.Domain, subDomainCreate.OrgID).Count(&domainCount).Error; err != nil {
 		return nil, err
 	}
 	if domainCount == 0 {
@@ -785,7 +820,7 @@ func CreateSubDomain(subDomainCreate model.SubDomainCreate) (*model.SubDomain, e
 	return response[0], nil
 }
 
-func UpdateSubDomain(lcuuid string, subDomainUpdate map[string]interface{}) (*model.SubDomain, error) {
+func UpdateSubDomain(orgID int, lcuuid string, subDomainUpdate map[string]interface{}) (*model.SubDomain, error) {
 	if _, ok := subDomainUpdate["NAME"]; ok {
 		return nil, errors.New("name field cannot be modified")
 	}
@@ -795,7 +830,7 @@ func UpdateSubDomain(lcuuid string, subDomainUpdate map[string]interface{}) (*mo
 	var subDomain mysql.SubDomain
 	var dbUpdateMap = make(map[string]interface{})
 
-	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&subDomain); ret.Error != nil {
+	if ret := orgSubDomains(orgID).Where("lcuuid = ?", lcuuid).First(&subDomain); ret.Error != nil {
 		return nil, servicecommon.NewError(
 			httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("sub_domain (%s) not found", lcuuid),
 		)
@@ -816,9 +851,9 @@ func UpdateSubDomain(lcuuid string, subDomainUpdate map[string]interface{}) (*mo
 	return response[0], nil
 }
 
-func DeleteSubDomain(lcuuid string) (map[string]string, error) {
+func DeleteSubDomain(orgID int, lcuuid string) (map[string]string, error) {
 	var subDomain mysql.SubDomain
-	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&subDomain); ret.Error != nil {
+	if ret := orgSubDomains(orgID).Where("lcuuid = ?", lcuuid).First(&subDomain); ret.Error != nil {
 		return nil, servicecommon.NewError(
 			httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("sub_domain (%s) not found", lcuuid),
 		)
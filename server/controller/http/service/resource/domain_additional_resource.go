@@ -90,6 +90,16 @@ func ApplyDomainAddtionalResource(reqData model.AdditionalResource) error {
 	return err
 }
 
+// CheckDomainAddtionalResource 与 ApplyDomainAddtionalResource 校验逻辑一致，但不写入数据库
+func CheckDomainAddtionalResource(reqData model.AdditionalResource) error {
+	domainUUIDToToolDataSet, err := generateToolDataSet(reqData)
+	if err != nil {
+		return err
+	}
+	_, err = generateCloudModelData(domainUUIDToToolDataSet)
+	return err
+}
+
 func fullUpdateDB(dbItems []mysql.DomainAdditionalResource) error {
 	err := mysql.Db.Transaction(func(tx *gorm.DB) error {
 		// Full update, delete all data before inserting
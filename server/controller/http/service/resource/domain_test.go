@@ -30,6 +30,7 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
 
+	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
 )
 
@@ -315,7 +316,9 @@ func (t *SuiteTest) TestDeleteDomain() {
 
 func (t *SuiteTest) TestDeleteSubDomain() {
 	lcuuid := uuid.NewString()
-	subDomain := mysql.SubDomain{Base: mysql.Base{Lcuuid: lcuuid}}
+	domain := mysql.Domain{Base: mysql.Base{Lcuuid: uuid.NewString()}, OrgID: common.DEFAULT_ORG_ID}
+	t.db.Create(&domain)
+	subDomain := mysql.SubDomain{Base: mysql.Base{Lcuuid: lcuuid}, Domain: domain.Lcuuid}
 	t.db.Create(&subDomain)
 	podCluster := mysql.PodCluster{Base: mysql.Base{Lcuuid: lcuuid}}
 	t.db.Create(&podCluster)
@@ -356,7 +359,7 @@ func (t *SuiteTest) TestDeleteSubDomain() {
 	r = t.db.Create(&mysql.Pod{Base: mysql.Base{Lcuuid: uuid.NewString()}, SubDomain: lcuuid})
 	assert.Equal(t.T(), r.RowsAffected, int64(1))
 
-	DeleteSubDomain(lcuuid)
+	DeleteSubDomain(common.DEFAULT_ORG_ID, lcuuid)
 
 	var networks []mysql.Network
 	t.db.Unscoped().Where("sub_domain = ?", lcuuid).Find(&networks)
@@ -54,7 +54,7 @@ func GetVtaps(filter map[string]interface{}) (resp []model.Vtap, err error) {
 
 	Db := mysql.Db
 	for _, param := range []string{
-		"lcuuid", "name", "type", "vtap_group_lcuuid", "controller_ip", "analyzer_ip",
+		"lcuuid", "name", "type", "vtap_group_lcuuid", "controller_ip", "analyzer_ip", "org_id",
 	} {
 		where := fmt.Sprintf("%s = ?", param)
 		if _, ok := filter[param]; ok {
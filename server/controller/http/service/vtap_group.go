@@ -22,6 +22,7 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/config"
@@ -55,6 +56,9 @@ func GetVtapGroups(filter map[string]interface{}) (resp []model.VtapGroup, err e
 	if _, ok := filter["short_uuid"]; ok {
 		Db = Db.Where("short_uuid = ?", filter["short_uuid"])
 	}
+	if _, ok := filter["org_id"]; ok {
+		Db = Db.Where("org_id = ?", filter["org_id"])
+	}
 	Db.Order("created_at DESC").Find(&vtapGroups)
 
 	for _, vtapGroup := range vtapGroups {
@@ -82,6 +86,7 @@ func GetVtapGroups(filter map[string]interface{}) (resp []model.VtapGroup, err e
 			Name:               vtapGroup.Name,
 			ShortUUID:          vtapGroup.ShortUUID,
 			Lcuuid:             vtapGroup.Lcuuid,
+			OrgID:              vtapGroup.OrgID,
 			UpdatedAt:          vtapGroup.UpdatedAt.Format(common.GO_BIRTHDAY),
 			VtapLcuuids:        []string{},
 			PendingVtapLcuuids: []string{},
@@ -107,7 +112,7 @@ func GetVtapGroups(filter map[string]interface{}) (resp []model.VtapGroup, err e
 	return response, nil
 }
 
-func CreateVtapGroup(vtapGroupCreate model.VtapGroupCreate, cfg *config.ControllerConfig) (resp model.VtapGroup, err error) {
+func CreateVtapGroup(orgID int, vtapGroupCreate model.VtapGroupCreate, cfg *config.ControllerConfig) (resp model.VtapGroup, err error) {
 	var vtapGroupCount int64
 
 	mysql.Db.Model(&mysql.VTapGroup{}).Where("name = ?", vtapGroupCreate.Name).Count(&vtapGroupCount)
@@ -145,12 +150,22 @@ func CreateVtapGroup(vtapGroupCreate model.VtapGroupCreate, cfg *config.Controll
 	vtapGroup.Lcuuid = lcuuid
 	vtapGroup.ShortUUID = shortUUID
 	vtapGroup.Name = vtapGroupCreate.Name
-	mysql.Db.Create(&vtapGroup)
+	vtapGroup.OrgID = orgID
+	err = mysql.Db.Transaction(func(tx *gorm.DB) error {
+		if err := CheckOrgQuota(tx, orgID, &mysql.VTapGroup{}, "vtap_group"); err != nil {
+			return err
+		}
+		return tx.Create(&vtapGroup).Error
+	})
+	if err != nil {
+		return model.VtapGroup{}, err
+	}
 
+	// 采集器随采集器组归属到同一组织
 	var vtaps []mysql.VTap
 	mysql.Db.Where("lcuuid IN (?)", vtapGroupCreate.VtapLcuuids).Find(&vtaps)
 	for _, vtap := range vtaps {
-		mysql.Db.Model(&vtap).Update("vtap_group_lcuuid", lcuuid)
+		mysql.Db.Model(&vtap).Updates(map[string]interface{}{"vtap_group_lcuuid": lcuuid, "org_id": orgID})
 	}
 
 	response, _ := GetVtapGroups(map[string]interface{}{"lcuuid": lcuuid})
@@ -184,11 +199,11 @@ func verifyGroupID(groupID string) error {
 	return nil
 }
 
-func UpdateVtapGroup(lcuuid string, vtapGroupUpdate map[string]interface{}, cfg *config.ControllerConfig) (resp model.VtapGroup, err error) {
+func UpdateVtapGroup(orgID int, lcuuid string, vtapGroupUpdate map[string]interface{}, cfg *config.ControllerConfig) (resp model.VtapGroup, err error) {
 	var vtapGroup mysql.VTapGroup
 	var dbUpdateMap = make(map[string]interface{})
 
-	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&vtapGroup); ret.Error != nil {
+	if ret := mysql.Db.Where("lcuuid = ? AND org_id = ?", lcuuid, orgID).First(&vtapGroup); ret.Error != nil {
 		return model.VtapGroup{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("vtap_group (%s) not found", lcuuid))
 	}
 
@@ -249,13 +264,13 @@ func UpdateVtapGroup(lcuuid string, vtapGroupUpdate map[string]interface{}, cfg
 		for _, lcuuid := range delVtapLcuuids.ToSlice() {
 			vtap := lcuuidToOldVtap[lcuuid.(string)]
 			// TODO：记录操作日志
-			mysql.Db.Model(vtap).Update("vtap_group_lcuuid", defaultVtapGroup.Lcuuid)
+			mysql.Db.Model(vtap).Updates(map[string]interface{}{"vtap_group_lcuuid": defaultVtapGroup.Lcuuid, "org_id": defaultVtapGroup.OrgID})
 		}
 
 		for _, lcuuid := range addVtapLcuuids.ToSlice() {
 			vtap := lcuuidToNewVtap[lcuuid.(string)]
 			// TODO：记录操作日志
-			mysql.Db.Model(vtap).Update("vtap_group_lcuuid", vtapGroup.Lcuuid)
+			mysql.Db.Model(vtap).Updates(map[string]interface{}{"vtap_group_lcuuid": vtapGroup.Lcuuid, "org_id": vtapGroup.OrgID})
 		}
 	}
 
@@ -267,10 +282,10 @@ func UpdateVtapGroup(lcuuid string, vtapGroupUpdate map[string]interface{}, cfg
 	return response[0], nil
 }
 
-func DeleteVtapGroup(lcuuid string) (resp map[string]string, err error) {
+func DeleteVtapGroup(orgID int, lcuuid string) (resp map[string]string, err error) {
 	var vtapGroup mysql.VTapGroup
 
-	if ret := mysql.Db.Where("lcuuid = ?", lcuuid).First(&vtapGroup); ret.Error != nil {
+	if ret := mysql.Db.Where("lcuuid = ? AND org_id = ?", lcuuid, orgID).First(&vtapGroup); ret.Error != nil {
 		return map[string]string{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("vtap_group (%s) not found", lcuuid))
 	}
 
@@ -281,7 +296,9 @@ func DeleteVtapGroup(lcuuid string) (resp map[string]string, err error) {
 
 	log.Infof("delete vtap_group (%s)", vtapGroup.Name)
 
-	mysql.Db.Model(&mysql.VTap{}).Where("vtap_group_lcuuid = ?", lcuuid).Update("vtap_group_lcuuid", defaultVtapGroup.Lcuuid)
+	mysql.Db.Model(&mysql.VTap{}).Where("vtap_group_lcuuid = ?", lcuuid).Updates(
+		map[string]interface{}{"vtap_group_lcuuid": defaultVtapGroup.Lcuuid, "org_id": defaultVtapGroup.OrgID},
+	)
 	mysql.Db.Delete(&vtapGroup)
 	mysql.Db.Where("vtap_group_lcuuid = ?", lcuuid).Delete(&mysql.VTapGroupConfiguration{})
 	refresh.RefreshCache([]common.DataChanged{common.DATA_CHANGED_VTAP})
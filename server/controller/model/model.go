@@ -207,12 +207,57 @@ type VTapRebalanceResult struct {
 	Details            []*HostVTapRebalanceResult `json:"DETAILS"`
 }
 
+type AnalyzerRebalanceMove struct {
+	VTapID   int    `json:"VTAP_ID"`
+	VTapName string `json:"VTAP_NAME"`
+	AZ       string `json:"AZ"`
+	OldIP    string `json:"OLD_ANALYZER_IP"`
+	NewIP    string `json:"NEW_ANALYZER_IP"`
+	Traffic  int64  `json:"TRAFFIC"`
+	CrossAZ  bool   `json:"CROSS_AZ"`
+	Reason   string `json:"REASON"`
+	Result   string `json:"RESULT"` // empty means not applied
+}
+
+type AnalyzerRebalanceLoad struct {
+	IP             string  `json:"IP"`
+	AZ             string  `json:"AZ"`
+	State          int     `json:"STATE"`
+	CPUUsage       float64 `json:"CPU_USAGE"`        // 0~1
+	MemoryUsage    float64 `json:"MEMORY_USAGE"`     // 0~1
+	QueueDropRatio float64 `json:"QUEUE_DROP_RATIO"` // 0~1
+	DiskUsage      float64 `json:"DISK_USAGE"`       // 0~1
+	Pressure       float64 `json:"PRESSURE"`         // 0~1
+	BeforeVTapNum  int     `json:"BEFORE_VTAP_NUM"`
+	AfterVTapNum   int     `json:"AFTER_VTAP_NUM"`
+	BeforeLoad     float64 `json:"BEFORE_LOAD"` // traffic / expected traffic
+	AfterLoad      float64 `json:"AFTER_LOAD"`
+}
+
+type AnalyzerRebalancePlanCreate struct {
+	DataDuration     int `json:"DATA_DURATION"`       // unit: s, default: data-duration in config
+	MaxMovesPerRound int `json:"MAX_MOVES_PER_ROUND"` // default: max-moves-per-round in config
+}
+
+type AnalyzerRebalancePlan struct {
+	ID                 int                      `json:"ID"`
+	State              int                      `json:"STATE"` // 0.pending 1.applied 2.discarded 3.expired
+	Source             string                   `json:"SOURCE"`
+	TotalSwitchVTapNum int                      `json:"TOTAL_SWITCH_VTAP_NUM"`
+	Moves              []*AnalyzerRebalanceMove `json:"MOVES"`
+	Details            []*AnalyzerRebalanceLoad `json:"DETAILS"`
+	CreatedAt          string                   `json:"CREATED_AT"`
+	UpdatedAt          string                   `json:"UPDATED_AT"`
+	Lcuuid             string                   `json:"LCUUID"`
+}
+
 type VtapGroup struct {
 	ID                 int      `json:"ID"`
 	Name               string   `json:"NAME"`
 	UpdatedAt          string   `json:"UPDATED_AT"`
 	ShortUUID          string   `json:"SHORT_UUID"`
 	Lcuuid             string   `json:"LCUUID"`
+	OrgID              int      `json:"ORG_ID"`
 	VtapLcuuids        []string `json:"VTAP_LCUUIDS"`
 	DisableVtapLcuuids []string `json:"DISABLE_VTAP_LCUUIDS"`
 	PendingVtapLcuuids []string `json:"PENDING_VTAP_LCUUIDS"`
@@ -226,6 +271,33 @@ type VtapGroupCreate struct {
 	GroupID     string   `json:"GROUP_ID"`
 }
 
+type Org struct {
+	ID              int    `json:"ID"`
+	Name            string `json:"NAME"`
+	MaxVTapNum      int    `json:"MAX_VTAP_NUM"`
+	MaxVTapGroupNum int    `json:"MAX_VTAP_GROUP_NUM"`
+	MaxDomainNum    int    `json:"MAX_DOMAIN_NUM"`
+	VTapCount       int    `json:"VTAP_COUNT"`
+	VTapGroupCount  int    `json:"VTAP_GROUP_COUNT"`
+	DomainCount     int    `json:"DOMAIN_COUNT"`
+	UpdatedAt       string `json:"UPDATED_AT"`
+	Lcuuid          string `json:"LCUUID"`
+}
+
+type OrgCreate struct {
+	Name            string `json:"NAME" binding:"required"`
+	MaxVTapNum      int    `json:"MAX_VTAP_NUM"`
+	MaxVTapGroupNum int    `json:"MAX_VTAP_GROUP_NUM"`
+	MaxDomainNum    int    `json:"MAX_DOMAIN_NUM"`
+}
+
+type OrgUpdate struct {
+	Name            string `json:"NAME"`
+	MaxVTapNum      int    `json:"MAX_VTAP_NUM"`
+	MaxVTapGroupNum int    `json:"MAX_VTAP_GROUP_NUM"`
+	MaxDomainNum    int    `json:"MAX_DOMAIN_NUM"`
+}
+
 type VtapGroupUpdate struct {
 	Name        string   `json:"NAME"`
 	State       int      `json:"STATE"`
@@ -307,6 +379,7 @@ type Domain struct {
 	PodClusters    []string               `json:"POD_CLUSTERS"`
 	CreatedAt      string                 `json:"CREATED_AT"`
 	SyncedAt       string                 `json:"SYNCED_AT"`
+	OrgID          int                    `json:"ORG_ID"`
 	Lcuuid         string                 `json:"LCUUID"`
 }
 
@@ -317,6 +390,7 @@ type DomainCreate struct {
 	IconID              int                    `json:"ICON_ID"`       // TODO: 修改为required
 	ControllerIP        string                 `json:"CONTROLLER_IP"` // TODO: 修改为required
 	Config              map[string]interface{} `json:"CONFIG"`
+	OrgID               int                    `json:"-"` // 由请求头 X-Org-Id 指定，为 0 时归属默认组织
 }
 
 type DomainUpdate struct {
@@ -348,6 +422,7 @@ type SubDomainCreate struct {
 	Name   string                 `json:"NAME" binding:"required"`
 	Config map[string]interface{} `json:"CONFIG" binding:"required"`
 	Domain string                 `json:"DOMAIN" binding:"required"`
+	OrgID  int                    `json:"-"` // 由请求头 X-Org-Id 指定，所属的域需属于该组织
 }
 
 type SubDomainUpdate struct {
@@ -879,3 +954,285 @@ type MailServer struct {
 	NtlmPassword string `json:"NTLM_PASSWORD"`
 	Lcuuid       string `json:"LCUUID"`
 }
+
+type PrometheusCardinalityQuery struct {
+	StartTime  int64  `form:"start_time"`
+	EndTime    int64  `form:"end_time"`
+	MetricName string `form:"metric_name"`
+	Limit      int    `form:"limit"`
+	Interval   int    `form:"interval"`
+}
+
+type PrometheusMetricCardinality struct {
+	MetricName  string `json:"METRIC_NAME"`
+	SeriesCount uint64 `json:"SERIES_COUNT"`
+	TargetCount uint64 `json:"TARGET_COUNT"`
+}
+
+type PrometheusLabelCardinality struct {
+	LabelName  string `json:"LABEL_NAME"`
+	ValueCount uint64 `json:"VALUE_COUNT"`
+}
+
+type PrometheusCardinality struct {
+	StartTime     int64                         `json:"START_TIME"`
+	EndTime       int64                         `json:"END_TIME"`
+	MetricName    string                        `json:"METRIC_NAME"`
+	SeriesCount   uint64                        `json:"SERIES_COUNT"`
+	TopMetrics    []PrometheusMetricCardinality `json:"TOP_METRICS"`
+	TopLabelNames []PrometheusLabelCardinality  `json:"TOP_LABEL_NAMES"`
+}
+
+type PrometheusCardinalityPoint struct {
+	Time           int64  `json:"TIME"`
+	SeriesCount    uint64 `json:"SERIES_COUNT"`
+	NewMetricNames uint64 `json:"NEW_METRIC_NAMES"`
+	NewLabelValues uint64 `json:"NEW_LABEL_VALUES"`
+}
+
+type PrometheusCardinalityGrowth struct {
+	StartTime  int64                        `json:"START_TIME"`
+	EndTime    int64                        `json:"END_TIME"`
+	MetricName string                       `json:"METRIC_NAME"`
+	Interval   int                          `json:"INTERVAL"`
+	Points     []PrometheusCardinalityPoint `json:"POINTS"`
+}
+
+// ConfigBundleObject SPEC 与对应资源创建接口的请求体一致，
+// AgentGroupConfig 的 SPEC 为采集器组高级配置（yaml 格式）
+type ConfigBundleObject struct {
+	Kind string                 `json:"KIND" binding:"required"`
+	Name string                 `json:"NAME" binding:"required"`
+	Spec map[string]interface{} `json:"SPEC"`
+}
+
+type ConfigBundle struct {
+	Objects []ConfigBundleObject `json:"OBJECTS" binding:"required,dive"`
+}
+
+type ConfigBundleValidation struct {
+	Kind  string `json:"KIND"`
+	Name  string `json:"NAME"`
+	Error string `json:"ERROR"`
+}
+
+type FlowACLCreate struct {
+	Name        string `json:"NAME" binding:"required"`
+	Application int    `json:"APPLICATION" binding:"required"` // 4: pcap, 6: npb
+	State       *int   `json:"STATE"`                          // 0: disable, 1: enable
+	TapType     int    `json:"TAP_TYPE"`                       // value of tap_type, default is 3 (cloud network)
+	SrcGroupIDs []int  `json:"SRC_GROUP_IDS"`                  // resource group ids, empty means any
+	DstGroupIDs []int  `json:"DST_GROUP_IDS"`
+	Protocol    *int   `json:"PROTOCOL"`  // ip protocol number, null means any
+	SrcPorts    string `json:"SRC_PORTS"` // e.g.: 80,8000-8080
+	DstPorts    string `json:"DST_PORTS"`
+	Vlan        int    `json:"VLAN"`
+}
+
+type FlowACL struct {
+	ID          int    `json:"ID"`
+	Name        string `json:"NAME"`
+	Application int    `json:"APPLICATION"`
+	State       int    `json:"STATE"`
+	TapType     int    `json:"TAP_TYPE"`
+	SrcGroupIDs []int  `json:"SRC_GROUP_IDS"`
+	DstGroupIDs []int  `json:"DST_GROUP_IDS"`
+	Protocol    *int   `json:"PROTOCOL"`
+	SrcPorts    string `json:"SRC_PORTS"`
+	DstPorts    string `json:"DST_PORTS"`
+	Vlan        int    `json:"VLAN"`
+	CreatedAt   string `json:"CREATED_AT"`
+	UpdatedAt   string `json:"UPDATED_AT"`
+	Lcuuid      string `json:"LCUUID"`
+}
+
+type NpbTunnelCreate struct {
+	Name         string `json:"NAME" binding:"required"`
+	IP           string `json:"IP" binding:"required"`
+	Type         int    `json:"TYPE"`           // 0: VXLAN, 1: ERSPAN
+	VNIInputType int    `json:"VNI_INPUT_TYPE"` // 1: entire one, 2: two parts
+}
+
+type NpbTunnel struct {
+	ID           int    `json:"ID"`
+	Name         string `json:"NAME"`
+	IP           string `json:"IP"`
+	Type         int    `json:"TYPE"`
+	VNIInputType int    `json:"VNI_INPUT_TYPE"`
+	CreatedAt    string `json:"CREATED_AT"`
+	UpdatedAt    string `json:"UPDATED_AT"`
+	Lcuuid       string `json:"LCUUID"`
+}
+
+type NpbPolicyCreate struct {
+	Name         string `json:"NAME" binding:"required"`
+	State        *int   `json:"STATE"`
+	ACLID        int    `json:"ACL_ID" binding:"required"`
+	NpbTunnelID  int    `json:"NPB_TUNNEL_ID" binding:"required"`
+	Vni          int    `json:"VNI"`           // vni of VXLAN or session id of ERSPAN
+	Direction    int    `json:"DIRECTION"`     // 1: all, 2: forward, 3: backward
+	TapSide      int    `json:"TAP_SIDE"`      // 1: src, 2: dst, 3: both, default is 1
+	Distribute   *int   `json:"DISTRIBUTE"`    // 0: drop, 1: distribute
+	PayloadSlice *int   `json:"PAYLOAD_SLICE"` // null means the whole packet
+	VTapIDs      []int  `json:"VTAP_IDS"`      // empty means all vtaps
+}
+
+type NpbPolicy struct {
+	ID               int    `json:"ID"`
+	Name             string `json:"NAME"`
+	State            int    `json:"STATE"`
+	ACLID            int    `json:"ACL_ID"`
+	NpbTunnelID      int    `json:"NPB_TUNNEL_ID"`
+	Vni              int    `json:"VNI"`
+	Direction        int    `json:"DIRECTION"`
+	TapSide          int    `json:"TAP_SIDE"`
+	Distribute       int    `json:"DISTRIBUTE"`
+	PayloadSlice     *int   `json:"PAYLOAD_SLICE"`
+	PolicyACLGroupID int    `json:"POLICY_ACL_GROUP_ID"`
+	VTapIDs          []int  `json:"VTAP_IDS"`
+	CreatedAt        string `json:"CREATED_AT"`
+	UpdatedAt        string `json:"UPDATED_AT"`
+	Lcuuid           string `json:"LCUUID"`
+}
+
+type PcapPolicyCreate struct {
+	Name         string `json:"NAME" binding:"required"`
+	State        *int   `json:"STATE"`
+	ACLID        int    `json:"ACL_ID" binding:"required"`
+	TapSide      int    `json:"TAP_SIDE"` // 1: src, 2: dst, 3: both, default is 1
+	PayloadSlice *int   `json:"PAYLOAD_SLICE"`
+	VTapIDs      []int  `json:"VTAP_IDS"`
+}
+
+type PcapPolicy struct {
+	ID               int    `json:"ID"`
+	Name             string `json:"NAME"`
+	State            int    `json:"STATE"`
+	ACLID            int    `json:"ACL_ID"`
+	TapSide          int    `json:"TAP_SIDE"`
+	PayloadSlice     *int   `json:"PAYLOAD_SLICE"`
+	PolicyACLGroupID int    `json:"POLICY_ACL_GROUP_ID"`
+	VTapIDs          []int  `json:"VTAP_IDS"`
+	CreatedAt        string `json:"CREATED_AT"`
+	UpdatedAt        string `json:"UPDATED_AT"`
+	Lcuuid           string `json:"LCUUID"`
+}
+
+type CustomIPTagSetCreate struct {
+	Name         string `json:"NAME" binding:"required"`
+	SourceType   int    `json:"SOURCE_TYPE"`   // 0: upload, 1: http
+	SourceURL    string `json:"SOURCE_URL"`    // csv url, only for http source
+	SyncInterval int    `json:"SYNC_INTERVAL"` // unit: s, only for http source
+}
+
+type CustomIPTagSet struct {
+	ID           int      `json:"ID"`
+	Name         string   `json:"NAME"`
+	SourceType   int      `json:"SOURCE_TYPE"`
+	SourceURL    string   `json:"SOURCE_URL"`
+	SyncInterval int      `json:"SYNC_INTERVAL"`
+	TagKeys      []string `json:"TAG_KEYS"`
+	EntryCount   int      `json:"ENTRY_COUNT"`
+	SyncedAt     string   `json:"SYNCED_AT"`
+	SyncError    string   `json:"SYNC_ERROR"`
+	CreatedAt    string   `json:"CREATED_AT"`
+	UpdatedAt    string   `json:"UPDATED_AT"`
+	Lcuuid       string   `json:"LCUUID"`
+}
+
+type CustomIPTag struct {
+	L3EPCID int               `json:"L3_EPC_ID"`
+	CIDR    string            `json:"CIDR"`
+	Tags    map[string]string `json:"TAGS"`
+}
+
+type AlertRuleCreate struct {
+	Name              string   `json:"NAME" binding:"required"`
+	Description       string   `json:"DESCRIPTION"`
+	Enabled           *int     `json:"ENABLED"` // 0: disabled, 1: enabled, default: 1
+	Level             *int     `json:"LEVEL"`   // 0: low, 1: medium, 2: high, default: 1
+	DB                string   `json:"DB" binding:"required"`
+	SQL               string   `json:"SQL" binding:"required"` // support $__timeFilter, $__from and $__to
+	ValueColumn       string   `json:"VALUE_COLUMN"`           // default: value
+	ValueUnit         string   `json:"VALUE_UNIT"`
+	Comparator        string   `json:"COMPARATOR"` // >, >=, <, <=, ==, !=, default: >
+	ThresholdCritical *float64 `json:"THRESHOLD_CRITICAL"`
+	ThresholdError    *float64 `json:"THRESHOLD_ERROR"`
+	ThresholdWarning  *float64 `json:"THRESHOLD_WARNING"`
+	EvalInterval      int      `json:"EVAL_INTERVAL"` // unit: s, default: 60
+	EvalRange         int      `json:"EVAL_RANGE"`    // unit: s, default: 300
+	ForDuration       int      `json:"FOR_DURATION"`  // unit: s
+	ChannelLcuuids    []string `json:"CHANNEL_LCUUIDS"`
+}
+
+type AlertRule struct {
+	ID                int      `json:"ID"`
+	Name              string   `json:"NAME"`
+	Description       string   `json:"DESCRIPTION"`
+	Enabled           int      `json:"ENABLED"`
+	Level             int      `json:"LEVEL"`
+	DB                string   `json:"DB"`
+	SQL               string   `json:"SQL"`
+	ValueColumn       string   `json:"VALUE_COLUMN"`
+	ValueUnit         string   `json:"VALUE_UNIT"`
+	Comparator        string   `json:"COMPARATOR"`
+	ThresholdCritical *float64 `json:"THRESHOLD_CRITICAL"`
+	ThresholdError    *float64 `json:"THRESHOLD_ERROR"`
+	ThresholdWarning  *float64 `json:"THRESHOLD_WARNING"`
+	EvalInterval      int      `json:"EVAL_INTERVAL"`
+	EvalRange         int      `json:"EVAL_RANGE"`
+	ForDuration       int      `json:"FOR_DURATION"`
+	ChannelLcuuids    []string `json:"CHANNEL_LCUUIDS"`
+	LastEvalAt        string   `json:"LAST_EVAL_AT"`
+	LastEvalError     string   `json:"LAST_EVAL_ERROR"`
+	CreatedAt         string   `json:"CREATED_AT"`
+	UpdatedAt         string   `json:"UPDATED_AT"`
+	Lcuuid            string   `json:"LCUUID"`
+}
+
+type AlertChannelCreate struct {
+	Name   string                 `json:"NAME" binding:"required"`
+	Type   string                 `json:"TYPE" binding:"required"` // email, webhook, slack, pagerduty
+	Config map[string]interface{} `json:"CONFIG"`
+}
+
+type AlertChannel struct {
+	ID        int                    `json:"ID"`
+	Name      string                 `json:"NAME"`
+	Type      string                 `json:"TYPE"`
+	Config    map[string]interface{} `json:"CONFIG"`
+	CreatedAt string                 `json:"CREATED_AT"`
+	UpdatedAt string                 `json:"UPDATED_AT"`
+	Lcuuid    string                 `json:"LCUUID"`
+}
+
+// EffectivePolicy 为 trisolaris 编译后实际下发给采集器的策略
+type EffectivePolicy struct {
+	VTapID   int                `json:"VTAP_ID"`
+	VTapName string             `json:"VTAP_NAME"`
+	Version  uint64             `json:"VERSION"`
+	FlowACLs []EffectiveFlowACL `json:"FLOW_ACLS"`
+}
+
+type EffectiveFlowACL struct {
+	ACLID       int                  `json:"ACL_ID"`
+	ACLName     string               `json:"ACL_NAME"`
+	TapType     int                  `json:"TAP_TYPE"`
+	Protocol    int                  `json:"PROTOCOL"` // 256 means any
+	SrcGroupIDs []int                `json:"SRC_GROUP_IDS"`
+	DstGroupIDs []int                `json:"DST_GROUP_IDS"`
+	SrcPorts    string               `json:"SRC_PORTS"`
+	DstPorts    string               `json:"DST_PORTS"`
+	Vlan        int                  `json:"VLAN"`
+	NpbActions  []EffectiveNpbAction `json:"NPB_ACTIONS"`
+}
+
+type EffectiveNpbAction struct {
+	PolicyName   string `json:"POLICY_NAME"`
+	TunnelType   string `json:"TUNNEL_TYPE"`
+	TunnelIP     string `json:"TUNNEL_IP"`
+	TunnelID     int    `json:"TUNNEL_ID"`
+	TapSide      string `json:"TAP_SIDE"`
+	Direction    string `json:"DIRECTION"`
+	PayloadSlice int    `json:"PAYLOAD_SLICE"`
+}
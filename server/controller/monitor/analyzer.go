@@ -26,6 +26,7 @@ import (
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/config"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/election"
 	"github.com/deepflowio/deepflow/server/controller/http/service/rebalance"
 	mconfig "github.com/deepflowio/deepflow/server/controller/monitor/config"
 	"github.com/deepflowio/deepflow/server/controller/trisolaris/refresh"
@@ -60,6 +61,9 @@ func (c *AnalyzerCheck) Start() {
 	log.Info("analyzer check start")
 	go func() {
 		for range time.Tick(time.Duration(c.cfg.HealthCheckInterval) * time.Second) {
+			if election.CheckFencingToken() != nil {
+				continue
+			}
 			// 数据节点健康检查
 			c.healthCheck()
 			// 检查没有分配数据节点的采集器，并进行分配
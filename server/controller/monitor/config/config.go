@@ -40,4 +40,21 @@ type IngesterLoadBalancingStrategy struct {
 	Algorithm         string `default:"by-ingested-data" yaml:"algorithm"` // options: by-ingested-data, by-agent-count
 	DataDuration      int    `default:"86400" yaml:"data-duration"`        // default: 1d
 	RebalanceInterval int    `default:"3600" yaml:"rebalance-interval"`    // default: 1h
+
+	CostAware CostAwareRebalance `yaml:"cost-aware"`
+}
+
+// CostAwareRebalance 在采集器流量之外，结合数据节点 CPU/内存/队列丢弃、ClickHouse 磁盘使用率及跨可用区代价生成均衡计划
+type CostAwareRebalance struct {
+	Enabled           bool    `default:"false" yaml:"enabled"`
+	AutoApply         bool    `default:"false" yaml:"auto-apply"` // 定时生成的计划是否自动执行，否则需审核后通过接口执行
+	MaxMovesPerRound  int     `default:"10" yaml:"max-moves-per-round"`
+	Tolerance         float64 `default:"0.1" yaml:"tolerance"` // 数据节点负载超过期望值的比例，超过后才迁移采集器
+	CPUWeight         float64 `default:"1" yaml:"cpu-weight"`
+	MemoryWeight      float64 `default:"1" yaml:"memory-weight"`
+	QueueDropWeight   float64 `default:"2" yaml:"queue-drop-weight"`
+	DiskWeight        float64 `default:"1" yaml:"disk-weight"`
+	DiskHighWatermark float64 `default:"85" yaml:"disk-high-watermark"` // unit: %, 超过后数据节点不再接收迁入的采集器
+	CrossAZWeight     float64 `default:"0.5" yaml:"cross-az-weight"`
+	PlanExpireTime    int     `default:"3600" yaml:"plan-expire-time"` // unit: s
 }
@@ -26,6 +26,7 @@ import (
 
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/election"
 	"github.com/deepflowio/deepflow/server/controller/monitor/config"
 )
 
@@ -59,6 +60,9 @@ func (v *VTapLicenseAllocation) Start() {
 	log.Info("vtap license allocation and check start")
 	go func() {
 		for range time.Tick(time.Duration(v.cfg.LicenseCheckInterval) * time.Second) {
+			if election.CheckFencingToken() != nil {
+				continue
+			}
 			v.allocLicense()
 		}
 	}()
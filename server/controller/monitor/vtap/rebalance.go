@@ -22,8 +22,10 @@ import (
 	"time"
 
 	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/election"
 	"github.com/deepflowio/deepflow/server/controller/http/service"
 	"github.com/deepflowio/deepflow/server/controller/http/service/rebalance"
+	"github.com/deepflowio/deepflow/server/controller/model"
 	"github.com/deepflowio/deepflow/server/controller/monitor/config"
 )
 
@@ -50,6 +52,9 @@ func (r *RebalanceCheck) Start() {
 		}
 
 		for range time.Tick(time.Duration(r.cfg.RebalanceCheckInterval) * time.Second) {
+			if election.CheckFencingToken() != nil {
+				continue
+			}
 			r.controllerRebalance()
 			if r.cfg.IngesterLoadBalancingConfig.Algorithm == common.ANALYZER_ALLOC_BY_AGENT_COUNT {
 				r.analyzerRebalance()
@@ -63,9 +68,22 @@ func (r *RebalanceCheck) Start() {
 		}
 
 		if r.cfg.IngesterLoadBalancingConfig.Algorithm == common.ANALYZER_ALLOC_BY_INGESTED_DATA {
+			if r.cfg.IngesterLoadBalancingConfig.CostAware.Enabled {
+				r.analyzerRebalanceByCost()
+				for range time.Tick(time.Duration(r.cfg.IngesterLoadBalancingConfig.RebalanceInterval) * time.Second) {
+					if election.CheckFencingToken() != nil {
+						continue
+					}
+					r.analyzerRebalanceByCost()
+				}
+				return
+			}
 			duration := r.cfg.IngesterLoadBalancingConfig.DataDuration
 			r.analyzerRebalanceByTraffic(duration)
 			for range time.Tick(time.Duration(r.cfg.IngesterLoadBalancingConfig.RebalanceInterval) * time.Second) {
+				if election.CheckFencingToken() != nil {
+					continue
+				}
 				r.analyzerRebalanceByTraffic(duration)
 			}
 		}
@@ -148,3 +166,24 @@ func (r *RebalanceCheck) analyzerRebalanceByTraffic(dataDuration int) {
 		return
 	}
 }
+
+// analyzerRebalanceByCost 生成均衡计划，开启 auto-apply 时直接执行，否则等待通过接口审核执行
+func (r *RebalanceCheck) analyzerRebalanceByCost() {
+	cfg := r.cfg.IngesterLoadBalancingConfig
+	plan, err := service.CreateAnalyzerRebalancePlan(model.AnalyzerRebalancePlanCreate{}, rebalance.PLAN_SOURCE_SCHEDULE, cfg)
+	if err != nil {
+		log.Errorf("create analyzer rebalance plan failed: %v", err)
+		return
+	}
+	if plan == nil {
+		log.Info("analyzer is balanced, no need to create rebalance plan")
+		return
+	}
+	log.Infof("analyzer rebalance plan(%d) created, total switch vtap num(%d)", plan.ID, plan.TotalSwitchVTapNum)
+	if !cfg.CostAware.AutoApply {
+		return
+	}
+	if _, err := service.ApplyAnalyzerRebalancePlan(plan.Lcuuid, cfg); err != nil {
+		log.Errorf("apply analyzer rebalance plan(%d) failed: %v", plan.ID, err)
+	}
+}
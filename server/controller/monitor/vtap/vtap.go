@@ -26,6 +26,7 @@ import (
 
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/election"
 	"github.com/deepflowio/deepflow/server/controller/monitor/config"
 	"github.com/deepflowio/deepflow/server/controller/trisolaris/utils"
 )
@@ -51,6 +52,9 @@ func (v *VTapCheck) Start() {
 	log.Info("controller check start")
 	go func() {
 		for range time.Tick(time.Duration(v.cfg.VTapCheckInterval) * time.Second) {
+			if election.CheckFencingToken() != nil {
+				continue
+			}
 			// check launch_server resource if exist
 			v.launchServerCheck()
 			// check vtap type
@@ -60,6 +64,9 @@ func (v *VTapCheck) Start() {
 
 	go func() {
 		for range time.Tick(time.Second * time.Duration(v.cfg.VTapAutoDeleteInterval)) {
+			if election.CheckFencingToken() != nil {
+				continue
+			}
 			v.deleteLostVTap()
 		}
 	}()
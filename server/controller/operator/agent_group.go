@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operator
+
+import (
+	"errors"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+// agentGroupReconciler 同步 AgentGroup，metadata.name 作为采集器组名称，
+// 同名采集器组已存在时直接接管，与 deepflow-ctl apply 一致
+type agentGroupReconciler struct {
+	*Operator
+}
+
+func (r *agentGroupReconciler) kind() string {
+	return service.CONFIG_BUNDLE_KIND_AGENT_GROUP
+}
+
+func (r *agentGroupReconciler) resource() string {
+	return "agentgroups"
+}
+
+func (r *agentGroupReconciler) sync(obj *unstructured.Unstructured, status map[string]interface{}, apply bool) error {
+	vtapGroup, err := r.getVTapGroup(obj.GetName(), status)
+	if err != nil {
+		return err
+	}
+	if apply || vtapGroup.ID == 0 {
+		if vtapGroup, err = r.apply(obj, vtapGroup); err != nil {
+			return err
+		}
+	}
+
+	var agentCount int64
+	if err := mysql.Db.Model(&mysql.VTap{}).Where("vtap_group_lcuuid = ?", vtapGroup.Lcuuid).Count(&agentCount).Error; err != nil {
+		return err
+	}
+	status["lcuuid"] = vtapGroup.Lcuuid
+	status["groupId"] = vtapGroup.ShortUUID
+	status["agentCount"] = agentCount
+	return nil
+}
+
+// getVTapGroup 优先使用 status 中记录的 lcuuid 查找，以便识别在接口中被重命名的采集器组
+func (r *agentGroupReconciler) getVTapGroup(name string, status map[string]interface{}) (*mysql.VTapGroup, error) {
+	vtapGroup := &mysql.VTapGroup{}
+	if lcuuid, _ := status["lcuuid"].(string); lcuuid != "" {
+		if err := mysql.Db.Where("lcuuid = ?", lcuuid).Find(vtapGroup).Error; err != nil {
+			return nil, err
+		}
+		if vtapGroup.ID != 0 {
+			return vtapGroup, nil
+		}
+	}
+	if err := mysql.Db.Where("name = ? AND org_id = ?", name, common.DEFAULT_ORG_ID).Find(vtapGroup).Error; err != nil {
+		return nil, err
+	}
+	return vtapGroup, nil
+}
+
+func (r *agentGroupReconciler) apply(obj *unstructured.Unstructured, vtapGroup *mysql.VTapGroup) (*mysql.VTapGroup, error) {
+	spec := upperKeys(specOf(obj))
+	bundle := model.ConfigBundle{Objects: []model.ConfigBundleObject{
+		{Kind: r.kind(), Name: obj.GetName(), Spec: spec},
+	}}
+	// 采集器组在接口中被重命名时，按名称的校验不再适用
+	if vtapGroup.ID == 0 || vtapGroup.Name == obj.GetName() {
+		if validation := service.ValidateConfigBundle(common.DEFAULT_ORG_ID, bundle)[0]; validation.Error != "" {
+			return nil, errors.New(validation.Error)
+		}
+	}
+	var groupCreate model.VtapGroupCreate
+	if err := convertSpec(spec, &groupCreate); err != nil {
+		return nil, err
+	}
+
+	var lcuuid string
+	if vtapGroup.ID == 0 {
+		groupCreate.Name = obj.GetName()
+		group, err := service.CreateVtapGroup(common.DEFAULT_ORG_ID, groupCreate, r.cfg)
+		if err != nil {
+			return nil, err
+		}
+		lcuuid = group.Lcuuid
+	} else {
+		if groupCreate.GroupID != "" && groupCreate.GroupID != vtapGroup.ShortUUID {
+			return nil, errors.New("group_id can not be changed")
+		}
+		groupUpdate := map[string]interface{}{"NAME": obj.GetName()}
+		// 未指定 vtap_lcuuids 时不管理组内采集器
+		if groupCreate.VtapLcuuids != nil {
+			vtapLcuuids := make([]interface{}, 0, len(groupCreate.VtapLcuuids))
+			for _, vtapLcuuid := range groupCreate.VtapLcuuids {
+				vtapLcuuids = append(vtapLcuuids, vtapLcuuid)
+			}
+			groupUpdate["VTAP_LCUUIDS"] = vtapLcuuids
+		}
+		if _, err := service.UpdateVtapGroup(common.DEFAULT_ORG_ID, vtapGroup.Lcuuid, groupUpdate, r.cfg); err != nil {
+			return nil, err
+		}
+		lcuuid = vtapGroup.Lcuuid
+	}
+
+	vtapGroup = &mysql.VTapGroup{}
+	if err := mysql.Db.Where("lcuuid = ?", lcuuid).First(vtapGroup).Error; err != nil {
+		return nil, err
+	}
+	return vtapGroup, nil
+}
+
+func (r *agentGroupReconciler) delete(obj *unstructured.Unstructured, status map[string]interface{}) error {
+	lcuuid, _ := status["lcuuid"].(string)
+	if lcuuid == "" {
+		return nil
+	}
+	var vtapGroup mysql.VTapGroup
+	if err := mysql.Db.Where("lcuuid = ?", lcuuid).Find(&vtapGroup).Error; err != nil {
+		return err
+	}
+	// 默认采集器组不能删除，仅解除管理
+	if vtapGroup.ID == 0 || vtapGroup.ID == common.DEFAULT_VTAP_GROUP_ID {
+		return nil
+	}
+	if _, err := service.DeleteVtapGroup(common.DEFAULT_ORG_ID, lcuuid); err != nil && !isNotFoundError(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operator
+
+import (
+	"errors"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+// agentGroupConfigReconciler 同步 AgentGroupConfig，spec 与采集器组高级配置的 yaml 一致，
+// 由于 short uuid 不符合对象名称规范，通过 spec.vtap_group_id 指定所属采集器组
+type agentGroupConfigReconciler struct {
+	*Operator
+}
+
+func (r *agentGroupConfigReconciler) kind() string {
+	return service.CONFIG_BUNDLE_KIND_AGENT_GROUP_CONFIG
+}
+
+func (r *agentGroupConfigReconciler) resource() string {
+	return "agentgroupconfigs"
+}
+
+func (r *agentGroupConfigReconciler) sync(obj *unstructured.Unstructured, status map[string]interface{}, apply bool) error {
+	spec := specOf(obj)
+	vtapGroupID, _ := spec["vtap_group_id"].(string)
+	if vtapGroupID == "" {
+		return errors.New("spec.vtap_group_id is required")
+	}
+	// 修改所属采集器组时清理原采集器组的配置
+	if oldVTapGroupID, _ := status["vtapGroupId"].(string); oldVTapGroupID != "" && oldVTapGroupID != vtapGroupID {
+		if err := deleteVTapGroupConfig(oldVTapGroupID); err != nil {
+			return err
+		}
+		delete(status, "vtap_group_id")
+		delete(status, "lcuuid")
+	}
+
+	dbConfig, err := getVTapGroupConfig(vtapGroupID)
+	if err != nil {
+		return err
+	}
+	if apply || dbConfig.ID == 0 {
+		if err = r.apply(vtapGroupID, spec, dbConfig); err != nil {
+			return err
+		}
+		if dbConfig, err = getVTapGroupConfig(vtapGroupID); err != nil {
+			return err
+		}
+	}
+	status["vtapGroupId"] = vtapGroupID
+	if dbConfig.Lcuuid != nil {
+		status["lcuuid"] = *dbConfig.Lcuuid
+	}
+	return nil
+}
+
+func (r *agentGroupConfigReconciler) apply(vtapGroupID string, spec map[string]interface{}, dbConfig *mysql.VTapGroupConfiguration) error {
+	bundle := model.ConfigBundle{Objects: []model.ConfigBundleObject{
+		{Kind: r.kind(), Name: vtapGroupID, Spec: spec},
+	}}
+	if validation := service.ValidateConfigBundle(common.DEFAULT_ORG_ID, bundle)[0]; validation.Error != "" {
+		return errors.New(validation.Error)
+	}
+	b, err := yaml.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	config := &model.VTapGroupConfiguration{}
+	if err = yaml.Unmarshal(b, config); err != nil {
+		return err
+	}
+	config.VTapGroupID = &vtapGroupID
+	if dbConfig.ID == 0 {
+		_, err = service.CreateVTapGroupAdvancedConfig(config)
+	} else {
+		// 全量覆盖，spec 中未指定的配置项恢复为默认值
+		_, err = service.UpdateVTapGroupAdvancedConfig(*dbConfig.Lcuuid, config)
+	}
+	return err
+}
+
+func (r *agentGroupConfigReconciler) delete(obj *unstructured.Unstructured, status map[string]interface{}) error {
+	vtapGroupID, _ := status["vtapGroupId"].(string)
+	if vtapGroupID == "" {
+		return nil
+	}
+	return deleteVTapGroupConfig(vtapGroupID)
+}
+
+// getVTapGroupConfig 采集器组或其配置不存在时返回 ID 为 0 的配置
+func getVTapGroupConfig(vtapGroupID string) (*mysql.VTapGroupConfiguration, error) {
+	dbConfig := &mysql.VTapGroupConfiguration{}
+	var vtapGroup mysql.VTapGroup
+	if err := mysql.Db.Where("short_uuid = ?", vtapGroupID).Find(&vtapGroup).Error; err != nil {
+		return nil, err
+	}
+	if vtapGroup.ID == 0 {
+		return dbConfig, nil
+	}
+	if err := mysql.Db.Where("vtap_group_lcuuid = ?", vtapGroup.Lcuuid).Find(dbConfig).Error; err != nil {
+		return nil, err
+	}
+	return dbConfig, nil
+}
+
+func deleteVTapGroupConfig(vtapGroupID string) error {
+	dbConfig, err := getVTapGroupConfig(vtapGroupID)
+	if err != nil || dbConfig.ID == 0 {
+		return err
+	}
+	_, err = service.DeleteVTapGroupConfigByFilter(map[string]string{"vtap_group_id": vtapGroupID})
+	return err
+}
@@ -0,0 +1,24 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+type Config struct {
+	Enabled        bool `default:"false" yaml:"enabled"`
+	InstallCRDs    bool `default:"false" yaml:"install-crds"`       // 启动时创建/更新 CRD，需要 customresourcedefinitions 的写权限
+	ResyncInterval int  `default:"30" yaml:"resync-interval"`       // unit: second
+	PluginMaxSize  int  `default:"67108864" yaml:"plugin-max-size"` // unit: byte, Plugin 镜像的最大下载大小
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operator
+
+import (
+	"bytes"
+	"embed"
+	"path"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+//go:embed crds/*.yaml
+var crdFS embed.FS
+
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// loadCRDs 读取内置的 CRD 定义，也可以直接使用 kubectl apply -f crds/ 安装
+func loadCRDs() ([]*unstructured.Unstructured, error) {
+	entries, err := crdFS.ReadDir("crds")
+	if err != nil {
+		return nil, err
+	}
+	crds := make([]*unstructured.Unstructured, 0, len(entries))
+	for _, entry := range entries {
+		content, err := crdFS.ReadFile(path.Join("crds", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		crd := &unstructured.Unstructured{}
+		if err = yaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), len(content)).Decode(&crd.Object); err != nil {
+			return nil, err
+		}
+		crds = append(crds, crd)
+	}
+	return crds, nil
+}
+
+// installCRDs 创建不存在的 CRD，已存在的 CRD 使用内置定义更新 spec
+func (o *Operator) installCRDs() {
+	crds, err := loadCRDs()
+	if err != nil {
+		log.Errorf("operator load crds failed: %s", err.Error())
+		return
+	}
+	client := o.dynamicClient.Resource(crdGVR)
+	for _, crd := range crds {
+		existing, err := client.Get(o.ctx, crd.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if _, err = client.Create(o.ctx, crd, metav1.CreateOptions{}); err != nil {
+				log.Errorf("operator create crd (%s) failed: %s", crd.GetName(), err.Error())
+			} else {
+				log.Infof("operator create crd (%s)", crd.GetName())
+			}
+			continue
+		} else if err != nil {
+			log.Errorf("operator get crd (%s) failed: %s", crd.GetName(), err.Error())
+			continue
+		}
+		existing.Object["spec"] = crd.Object["spec"]
+		if _, err = client.Update(o.ctx, existing, metav1.UpdateOptions{}); err != nil {
+			log.Errorf("operator update crd (%s) failed: %s", crd.GetName(), err.Error())
+		}
+	}
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/http/service/resource"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+// domainTypes 与 deepflow-ctl 中的云平台类型名称一致
+var domainTypes = map[string]int{
+	"openstack":         common.OPENSTACK,
+	"vsphere":           common.VSPHERE,
+	"tencent":           common.TENCENT,
+	"filereader":        common.FILEREADER,
+	"aws":               common.AWS,
+	"aliyun":            common.ALIYUN,
+	"huawei_private":    common.HUAWEI_PRIVATE,
+	"kubernetes":        common.KUBERNETES,
+	"simulation":        common.SIMULATION,
+	"huawei":            common.HUAWEI,
+	"qingcloud":         common.QINGCLOUD,
+	"qingcloud_private": common.QINGCLOUD_PRIVATE,
+	"azure":             common.AZURE,
+	"apsara_stack":      common.APSARA_STACK,
+	"tencent_tce":       common.TENCENT_TCE,
+	"agent_sync":        common.AGENT_SYNC,
+	"microsoft_acs":     common.MICROSOFT_ACS,
+	"baidu_bce":         common.BAIDU_BCE,
+	"eshore":            common.ESHORE,
+	"cloudtower":        common.CLOUD_TOWER,
+	"nfvo":              common.NFVO,
+	"gcp":               common.GCP,
+}
+
+var domainStateNames = map[int]string{
+	1: "NORMAL",
+	2: "DELETING",
+	3: "EXCEPTION",
+	4: "WARNING",
+}
+
+// domainReconciler 同步 Domain，metadata.name 作为云平台名称，spec 与 deepflow-ctl domain example 一致，
+// config_secret_ref 指定的 Secret 中的数据合并到 config 中，用于保存 AK/SK 等敏感配置
+type domainReconciler struct {
+	*Operator
+}
+
+func (r *domainReconciler) kind() string {
+	return service.CONFIG_BUNDLE_KIND_DOMAIN
+}
+
+func (r *domainReconciler) resource() string {
+	return "domains"
+}
+
+func (r *domainReconciler) sync(obj *unstructured.Unstructured, status map[string]interface{}, apply bool) error {
+	domain, err := r.getDomain(obj.GetName(), status)
+	if err != nil {
+		return err
+	}
+	domainCreate, err := r.getDomainCreate(obj)
+	if err != nil {
+		return err
+	}
+	// Secret 的变更不会修改对象的 generation，通过配置的摘要判断是否需要更新
+	configHash, err := hashConfig(domainCreate)
+	if err != nil {
+		return err
+	}
+	if apply || domain.ID == 0 || status["configHash"] != configHash {
+		if domain, err = r.apply(obj, domain, domainCreate); err != nil {
+			return err
+		}
+		status["configHash"] = configHash
+	}
+
+	status["lcuuid"] = domain.Lcuuid
+	status["clusterId"] = domain.ClusterID
+	status["controllerIp"] = domain.ControllerIP
+	status["state"] = domainStateNames[domain.State]
+	status["errorMessage"] = domain.ErrorMsg
+	status["syncedAt"] = ""
+	if domain.SyncedAt != nil {
+		status["syncedAt"] = domain.SyncedAt.Format(common.GO_BIRTHDAY)
+	}
+	return nil
+}
+
+func (r *domainReconciler) getDomain(name string, status map[string]interface{}) (*mysql.Domain, error) {
+	domain := &mysql.Domain{}
+	if lcuuid, _ := status["lcuuid"].(string); lcuuid != "" {
+		if err := mysql.Db.Where("lcuuid = ?", lcuuid).Find(domain).Error; err != nil {
+			return nil, err
+		}
+		if domain.ID != 0 {
+			return domain, nil
+		}
+	}
+	if err := mysql.Db.Where("name = ?", name).Find(domain).Error; err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+func (r *domainReconciler) getDomainCreate(obj *unstructured.Unstructured) (model.DomainCreate, error) {
+	var domainCreate model.DomainCreate
+	spec := specOf(obj)
+	domainTypeName, _ := spec["type"].(string)
+	domainType, ok := domainTypes[domainTypeName]
+	if !ok {
+		return domainCreate, fmt.Errorf("domain type (%s) not supported", domainTypeName)
+	}
+	secretRef, _ := spec["config_secret_ref"].(map[string]interface{})
+	delete(spec, "config_secret_ref")
+
+	apiSpec := upperKeys(spec)
+	apiSpec["NAME"] = obj.GetName()
+	apiSpec["TYPE"] = domainType
+	if err := convertSpec(apiSpec, &domainCreate); err != nil {
+		return domainCreate, err
+	}
+	if domainCreate.Config == nil {
+		domainCreate.Config = make(map[string]interface{})
+	}
+	if secretRef != nil {
+		namespace, _ := secretRef["namespace"].(string)
+		name, _ := secretRef["name"].(string)
+		secret, err := r.kubeClient.CoreV1().Secrets(namespace).Get(r.ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return domainCreate, fmt.Errorf("get secret (%s/%s) failed: %s", namespace, name, err.Error())
+		}
+		for key, value := range secret.Data {
+			domainCreate.Config[key] = string(value)
+		}
+	}
+	return domainCreate, nil
+}
+
+func hashConfig(domainCreate model.DomainCreate) (string, error) {
+	b, err := json.Marshal(domainCreate)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (r *domainReconciler) apply(obj *unstructured.Unstructured, domain *mysql.Domain, domainCreate model.DomainCreate) (*mysql.Domain, error) {
+	// 校验不包含 Secret 中的数据，避免在错误信息中泄露
+	spec := upperKeys(specOf(obj))
+	delete(spec, "CONFIG_SECRET_REF")
+	spec["TYPE"] = domainCreate.Type
+	bundle := model.ConfigBundle{Objects: []model.ConfigBundleObject{
+		{Kind: r.kind(), Name: obj.GetName(), Spec: spec},
+	}}
+	if domain.ID == 0 || domain.Name == obj.GetName() {
+		if validation := service.ValidateConfigBundle(common.DEFAULT_ORG_ID, bundle)[0]; validation.Error != "" {
+			return nil, errors.New(validation.Error)
+		}
+	}
+
+	var lcuuid string
+	if domain.ID == 0 {
+		resp, err := resource.CreateDomain(domainCreate, r.cfg)
+		if err != nil {
+			return nil, err
+		}
+		lcuuid = resp.Lcuuid
+	} else {
+		if domain.Type != domainCreate.Type {
+			return nil, fmt.Errorf("domain (%s) already exists with type (%d), type can not be changed", domain.Name, domain.Type)
+		}
+		// 创建时自动选择的区域和控制器，未在 spec 中指定时保持不变
+		var oldConfig map[string]interface{}
+		json.Unmarshal([]byte(domain.Config), &oldConfig)
+		for _, key := range []string{"region_uuid", "controller_ip"} {
+			if _, ok := domainCreate.Config[key]; !ok && oldConfig[key] != nil {
+				domainCreate.Config[key] = oldConfig[key]
+			}
+		}
+		domainUpdate := map[string]interface{}{
+			"NAME":   domainCreate.Name,
+			"CONFIG": domainCreate.Config,
+		}
+		if domainCreate.IconID != 0 {
+			domainUpdate["ICON_ID"] = domainCreate.IconID
+		}
+		if domainCreate.ControllerIP != "" {
+			domainUpdate["CONTROLLER_IP"] = domainCreate.ControllerIP
+		}
+		if _, err := resource.UpdateDomain(common.DEFAULT_ORG_ID, domain.Lcuuid, domainUpdate, r.cfg); err != nil {
+			return nil, err
+		}
+		lcuuid = domain.Lcuuid
+	}
+
+	domain = &mysql.Domain{}
+	if err := mysql.Db.Where("lcuuid = ?", lcuuid).First(domain).Error; err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+func (r *domainReconciler) delete(obj *unstructured.Unstructured, status map[string]interface{}) error {
+	lcuuid, _ := status["lcuuid"].(string)
+	if lcuuid == "" || lcuuid == common.DEFAULT_DOMAIN {
+		return nil
+	}
+	if _, err := resource.DeleteDomainByNameOrUUID(common.DEFAULT_ORG_ID, lcuuid); err != nil && !isNotFoundError(err) {
+		return err
+	}
+	return nil
+}
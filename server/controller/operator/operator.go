@@ -0,0 +1,368 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	logging "github.com/op/go-logging"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/deepflowio/deepflow/server/controller/config"
+	"github.com/deepflowio/deepflow/server/controller/election"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	servicecommon "github.com/deepflowio/deepflow/server/controller/http/service/common"
+)
+
+var log = logging.MustGetLogger("operator")
+
+const (
+	GROUP     = "deepflow.io"
+	VERSION   = "v1alpha1"
+	FINALIZER = "deepflow.io/finalizer"
+
+	SYNC_STATE_SYNCED = "Synced"
+	SYNC_STATE_ERROR  = "Error"
+)
+
+// reconciler 将一类 CR 同步到数据库，status 为对象当前的状态，同步结果直接写入 status
+type reconciler interface {
+	kind() string
+	resource() string
+	// sync 在 spec 变更或上次同步失败时 apply 为 true，需要将 spec 写入数据库；
+	// 否则仅刷新状态，发现数据库中的资源被删除时应重新创建
+	sync(obj *unstructured.Unstructured, status map[string]interface{}, apply bool) error
+	// delete 在对象删除时清理数据库中的资源，资源不存在时不应返回错误
+	delete(obj *unstructured.Unstructured, status map[string]interface{}) error
+}
+
+// Operator 将 deepflow.io 下的 AgentGroup、AgentGroupConfig、Domain、SubDomain、Plugin 等 CR 同步到数据库，
+// 并将同步结果写回对象的 status，仅在 master controller 上运行。
+// CR 变更通过 watch 触发同步，另外每隔 resync-interval 全量同步一次，
+// 用于处理 watch 中断期间的变更，以及数据库中被修改或删除的资源
+type Operator struct {
+	cfg    *config.ControllerConfig
+	pCtx   context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+	reconcilers   []reconciler
+}
+
+func NewOperator(ctx context.Context, cfg *config.ControllerConfig) *Operator {
+	return newOperator(ctx, cfg, nil, nil)
+}
+
+func newOperator(ctx context.Context, cfg *config.ControllerConfig, dynamicClient dynamic.Interface, kubeClient kubernetes.Interface) *Operator {
+	o := &Operator{
+		cfg:           cfg,
+		pCtx:          ctx,
+		dynamicClient: dynamicClient,
+		kubeClient:    kubeClient,
+	}
+	// 按依赖顺序同步，SubDomain 依赖 Domain，AgentGroupConfig 依赖 AgentGroup
+	o.reconcilers = []reconciler{
+		&domainReconciler{o},
+		&subDomainReconciler{o},
+		&agentGroupReconciler{o},
+		&agentGroupConfigReconciler{o},
+		&pluginReconciler{o},
+	}
+	return o
+}
+
+func (o *Operator) Start() {
+	if !o.cfg.OperatorCfg.Enabled {
+		return
+	}
+	if o.dynamicClient == nil {
+		if err := o.initClients(); err != nil {
+			log.Errorf("operator init kubernetes client failed: %s", err.Error())
+			return
+		}
+	}
+	o.ctx, o.cancel = context.WithCancel(o.pCtx)
+	if o.cfg.OperatorCfg.InstallCRDs {
+		o.installCRDs()
+	}
+	log.Info("operator started")
+	resyncInterval := time.Duration(o.cfg.OperatorCfg.ResyncInterval) * time.Second
+	trigger := make(chan struct{}, 1)
+	for _, r := range o.reconcilers {
+		go o.watch(r, trigger, resyncInterval)
+	}
+	go func() {
+		o.reconcileAll()
+		ticker := time.NewTicker(resyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-o.ctx.Done():
+				return
+			case <-ticker.C:
+			case <-trigger:
+			}
+			if election.CheckFencingToken() != nil {
+				continue
+			}
+			o.reconcileAll()
+		}
+	}()
+}
+
+// watch 监听一类 CR 的变更，有变更时触发一次全量同步，多次变更合并为一次；
+// watch 失败（如 CRD 未安装）或中断时等待 resyncInterval 后重新 watch，期间的变更由定时同步处理
+func (o *Operator) watch(r reconciler, trigger chan<- struct{}, resyncInterval time.Duration) {
+	for {
+		w, err := o.dynamicClient.Resource(gvr(r)).Watch(o.ctx, metav1.ListOptions{})
+		if err != nil {
+			log.Debugf("operator watch %s failed: %s", r.kind(), err.Error())
+		} else {
+			o.waitEvents(w, trigger)
+			w.Stop()
+		}
+		select {
+		case <-o.ctx.Done():
+			return
+		case <-time.After(resyncInterval):
+		}
+	}
+}
+
+func (o *Operator) waitEvents(w watch.Interface, trigger chan<- struct{}) {
+	for {
+		select {
+		case <-o.ctx.Done():
+			return
+		case _, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			select {
+			case trigger <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (o *Operator) Stop() {
+	if o.cancel != nil {
+		o.cancel()
+		log.Info("operator stopped")
+	}
+}
+
+func (o *Operator) initClients() error {
+	var restConfig *rest.Config
+	var err error
+	if o.cfg.Kubeconfig != "" {
+		restConfig, err = clientcmd.BuildConfigFromFlags("", o.cfg.Kubeconfig)
+	} else {
+		restConfig, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return err
+	}
+	if o.dynamicClient, err = dynamic.NewForConfig(restConfig); err != nil {
+		return err
+	}
+	o.kubeClient, err = kubernetes.NewForConfig(restConfig)
+	return err
+}
+
+func gvr(r reconciler) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: GROUP, Version: VERSION, Resource: r.resource()}
+}
+
+func (o *Operator) reconcileAll() {
+	for _, r := range o.reconcilers {
+		list, err := o.dynamicClient.Resource(gvr(r)).List(o.ctx, metav1.ListOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				log.Debugf("operator list %s failed, crd may be not installed: %s", r.kind(), err.Error())
+			} else {
+				log.Errorf("operator list %s failed: %s", r.kind(), err.Error())
+			}
+			continue
+		}
+		for i := range list.Items {
+			o.reconcile(r, &list.Items[i])
+		}
+	}
+}
+
+func (o *Operator) reconcile(r reconciler, obj *unstructured.Unstructured) {
+	client := o.dynamicClient.Resource(gvr(r))
+	status, _, _ := unstructured.NestedMap(obj.Object, "status")
+	if status == nil {
+		status = make(map[string]interface{})
+	}
+
+	if obj.GetDeletionTimestamp() != nil {
+		if !hasFinalizer(obj) {
+			return
+		}
+		if err := r.delete(obj, status); err != nil {
+			log.Errorf("operator delete %s (%s) failed: %s", r.kind(), obj.GetName(), errorMessage(err))
+			status["syncState"] = SYNC_STATE_ERROR
+			status["message"] = errorMessage(err)
+			o.updateStatus(r, obj, status)
+			return
+		}
+		log.Infof("operator delete %s (%s)", r.kind(), obj.GetName())
+		removeFinalizer(obj)
+		if _, err := client.Update(o.ctx, obj, metav1.UpdateOptions{}); err != nil {
+			log.Errorf("operator remove finalizer of %s (%s) failed: %s", r.kind(), obj.GetName(), err.Error())
+		}
+		return
+	}
+
+	// 先添加 finalizer，保证对象删除时能够清理数据库中的资源
+	if !hasFinalizer(obj) {
+		obj.SetFinalizers(append(obj.GetFinalizers(), FINALIZER))
+		updated, err := client.Update(o.ctx, obj, metav1.UpdateOptions{})
+		if err != nil {
+			log.Errorf("operator add finalizer to %s (%s) failed: %s", r.kind(), obj.GetName(), err.Error())
+			return
+		}
+		obj = updated
+	}
+
+	oldStatus := runtime.DeepCopyJSON(status)
+	camelStatusKeys(status)
+	observedGeneration, _, _ := unstructured.NestedInt64(status, "observedGeneration")
+	apply := observedGeneration != obj.GetGeneration() || status["syncState"] != SYNC_STATE_SYNCED
+	if err := r.sync(obj, status, apply); err != nil {
+		log.Errorf("operator sync %s (%s) failed: %s", r.kind(), obj.GetName(), errorMessage(err))
+		status["syncState"] = SYNC_STATE_ERROR
+		status["message"] = errorMessage(err)
+	} else {
+		if apply {
+			log.Infof("operator sync %s (%s) generation %d", r.kind(), obj.GetName(), obj.GetGeneration())
+			status["lastSyncTime"] = time.Now().UTC().Format(time.RFC3339)
+		}
+		status["syncState"] = SYNC_STATE_SYNCED
+		status["message"] = ""
+		status["observedGeneration"] = obj.GetGeneration()
+	}
+	if !reflect.DeepEqual(oldStatus, status) {
+		o.updateStatus(r, obj, status)
+	}
+}
+
+func (o *Operator) updateStatus(r reconciler, obj *unstructured.Unstructured, status map[string]interface{}) {
+	obj.Object["status"] = status
+	if _, err := o.dynamicClient.Resource(gvr(r)).UpdateStatus(o.ctx, obj, metav1.UpdateOptions{}); err != nil {
+		// 冲突时等待下次同步重试
+		log.Warningf("operator update status of %s (%s) failed: %s", r.kind(), obj.GetName(), err.Error())
+	}
+}
+
+// camelStatusKeys 将早期版本写入的下划线形式的 status 字段转换为 camelCase
+func camelStatusKeys(status map[string]interface{}) {
+	for key, value := range status {
+		if !strings.Contains(key, "_") {
+			continue
+		}
+		parts := strings.Split(key, "_")
+		for i := 1; i < len(parts); i++ {
+			if parts[i] != "" {
+				parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+			}
+		}
+		if camelKey := strings.Join(parts, ""); status[camelKey] == nil {
+			status[camelKey] = value
+		}
+		delete(status, key)
+	}
+}
+
+func hasFinalizer(obj *unstructured.Unstructured) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == FINALIZER {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(obj *unstructured.Unstructured) {
+	finalizers := []string{}
+	for _, f := range obj.GetFinalizers() {
+		if f != FINALIZER {
+			finalizers = append(finalizers, f)
+		}
+	}
+	obj.SetFinalizers(finalizers)
+}
+
+// errorMessage 返回 service 错误中的 Message，避免在 status 中写入 json
+func errorMessage(err error) string {
+	var serviceErr *servicecommon.ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Message
+	}
+	return err.Error()
+}
+
+func isNotFoundError(err error) bool {
+	var serviceErr *servicecommon.ServiceError
+	return errors.As(err, &serviceErr) && serviceErr.Status == httpcommon.RESOURCE_NOT_FOUND
+}
+
+func specOf(obj *unstructured.Unstructured) map[string]interface{} {
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	if spec == nil {
+		spec = make(map[string]interface{})
+	}
+	return spec
+}
+
+// upperKeys 将 spec 的第一层字段转换为接口请求体中的大写字段，与 deepflow-ctl apply 一致
+func upperKeys(spec map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(spec))
+	for k, v := range spec {
+		result[strings.ToUpper(k)] = v
+	}
+	return result
+}
+
+// convertSpec 将 spec 转换为接口请求体结构
+func convertSpec(spec map[string]interface{}, obj interface{}) error {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, obj)
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/config"
+)
+
+type fakeReconciler struct {
+	syncErr   error
+	applied   int
+	deleted   int
+	lastApply bool
+}
+
+func (r *fakeReconciler) kind() string     { return "Fake" }
+func (r *fakeReconciler) resource() string { return "fakes" }
+
+func (r *fakeReconciler) sync(obj *unstructured.Unstructured, status map[string]interface{}, apply bool) error {
+	r.lastApply = apply
+	if r.syncErr != nil {
+		return r.syncErr
+	}
+	if apply {
+		r.applied++
+	}
+	status["lcuuid"] = "fake-lcuuid"
+	return nil
+}
+
+func (r *fakeReconciler) delete(obj *unstructured.Unstructured, status map[string]interface{}) error {
+	r.deleted++
+	return nil
+}
+
+func newFakeObject(name string, generation int64) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(GROUP + "/" + VERSION)
+	obj.SetKind("Fake")
+	obj.SetName(name)
+	obj.SetGeneration(generation)
+	return obj
+}
+
+func newFakeOperator(r reconciler, objects ...runtime.Object) *Operator {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr(r): "FakeList"},
+		objects...,
+	)
+	o := newOperator(context.Background(), &config.ControllerConfig{}, client, nil)
+	o.ctx = context.Background()
+	o.reconcilers = []reconciler{r}
+	return o
+}
+
+func getFakeObject(t *testing.T, o *Operator, r reconciler, name string) *unstructured.Unstructured {
+	obj, err := o.dynamicClient.Resource(gvr(r)).Get(o.ctx, name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get %s failed: %s", name, err.Error())
+	}
+	return obj
+}
+
+func TestReconcile(t *testing.T) {
+	r := &fakeReconciler{}
+	o := newFakeOperator(r, newFakeObject("test", 1))
+
+	o.reconcileAll()
+	obj := getFakeObject(t, o, r, "test")
+	if !hasFinalizer(obj) {
+		t.Errorf("finalizer not added")
+	}
+	status, _, _ := unstructured.NestedMap(obj.Object, "status")
+	if status["syncState"] != SYNC_STATE_SYNCED || status["lcuuid"] != "fake-lcuuid" {
+		t.Errorf("unexpected status: %v", status)
+	}
+	if generation, _, _ := unstructured.NestedInt64(status, "observedGeneration"); generation != 1 {
+		t.Errorf("observedGeneration = %d, want 1", generation)
+	}
+
+	// generation 未变化时不再执行变更
+	o.reconcileAll()
+	if r.applied != 1 || r.lastApply {
+		t.Errorf("applied = %d, lastApply = %v, want 1, false", r.applied, r.lastApply)
+	}
+
+	// 同步失败时记录错误，下次同步重新执行变更
+	r.syncErr = errors.New("sync failed")
+	obj = getFakeObject(t, o, r, "test")
+	obj.SetGeneration(2)
+	if _, err := o.dynamicClient.Resource(gvr(r)).Update(o.ctx, obj, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	o.reconcileAll()
+	status, _, _ = unstructured.NestedMap(getFakeObject(t, o, r, "test").Object, "status")
+	if status["syncState"] != SYNC_STATE_ERROR || status["message"] != "sync failed" {
+		t.Errorf("unexpected status: %v", status)
+	}
+	r.syncErr = nil
+	o.reconcileAll()
+	if r.applied != 2 {
+		t.Errorf("applied = %d, want 2", r.applied)
+	}
+
+	// 删除时清理资源并移除 finalizer
+	obj = getFakeObject(t, o, r, "test")
+	now := metav1.Now()
+	obj.SetDeletionTimestamp(&now)
+	o.reconcile(r, obj)
+	if r.deleted != 1 {
+		t.Errorf("deleted = %d, want 1", r.deleted)
+	}
+	if hasFinalizer(getFakeObject(t, o, r, "test")) {
+		t.Errorf("finalizer not removed")
+	}
+}
+
+func TestCamelStatusKeys(t *testing.T) {
+	r := &fakeReconciler{}
+	obj := newFakeObject("test", 1)
+	obj.SetFinalizers([]string{FINALIZER})
+	obj.Object["status"] = map[string]interface{}{
+		"sync_state":          SYNC_STATE_SYNCED,
+		"observed_generation": int64(1),
+		"lcuuid":              "fake-lcuuid",
+	}
+	o := newFakeOperator(r, obj)
+
+	// 早期版本写入的状态转换后无需重新执行变更
+	o.reconcileAll()
+	if r.applied != 0 {
+		t.Errorf("applied = %d, want 0", r.applied)
+	}
+	status, _, _ := unstructured.NestedMap(getFakeObject(t, o, r, "test").Object, "status")
+	if status["syncState"] != SYNC_STATE_SYNCED || status["observedGeneration"] != int64(1) ||
+		status["sync_state"] != nil || status["observed_generation"] != nil {
+		t.Errorf("unexpected status: %v", status)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	r := &fakeReconciler{}
+	o := newFakeOperator(r)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	o.ctx = ctx
+	trigger := make(chan struct{}, 1)
+	go o.watch(r, trigger, time.Hour)
+
+	// 等待 watch 建立后再创建对象
+	time.Sleep(100 * time.Millisecond)
+	if _, err := o.dynamicClient.Resource(gvr(r)).Create(ctx, newFakeObject("test", 1), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-trigger:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch event does not trigger reconcile")
+	}
+}
+
+func TestLoadCRDs(t *testing.T) {
+	crds, err := loadCRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]bool{}
+	for _, crd := range crds {
+		if group, _, _ := unstructured.NestedString(crd.Object, "spec", "group"); group != GROUP {
+			t.Errorf("crd %s group = %s, want %s", crd.GetName(), group, GROUP)
+		}
+		kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+		plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+		if crd.GetName() != plural+"."+GROUP {
+			t.Errorf("crd name = %s, want %s", crd.GetName(), plural+"."+GROUP)
+		}
+		kinds[kind] = true
+	}
+	for _, r := range newOperator(context.Background(), &config.ControllerConfig{}, nil, nil).reconcilers {
+		if !kinds[r.kind()] {
+			t.Errorf("crd of %s not found", r.kind())
+		}
+	}
+}
+
+func TestUpperKeys(t *testing.T) {
+	spec := map[string]interface{}{"type": "kubernetes", "config": map[string]interface{}{"region_uuid": "x"}}
+	result := upperKeys(spec)
+	if result["TYPE"] != "kubernetes" {
+		t.Errorf("TYPE = %v, want kubernetes", result["TYPE"])
+	}
+	if config, _ := result["CONFIG"].(map[string]interface{}); config["region_uuid"] != "x" {
+		t.Errorf("nested keys should not be converted: %v", result["CONFIG"])
+	}
+	if domainTypes["kubernetes"] != common.KUBERNETES {
+		t.Errorf("kubernetes domain type = %d, want %d", domainTypes["kubernetes"], common.KUBERNETES)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+)
+
+const PLUGIN_DOWNLOAD_TIMEOUT = 60 * time.Second
+
+// pluginReconciler 同步 Plugin，metadata.name 作为插件名称，镜像从 spec.url 下载，
+// 仅在 spec 变更或插件被删除时重新下载
+type pluginReconciler struct {
+	*Operator
+}
+
+func (r *pluginReconciler) kind() string {
+	return service.CONFIG_BUNDLE_KIND_PLUGIN
+}
+
+func (r *pluginReconciler) resource() string {
+	return "plugins"
+}
+
+func (r *pluginReconciler) sync(obj *unstructured.Unstructured, status map[string]interface{}, apply bool) error {
+	name := obj.GetName()
+	if !apply {
+		var count int64
+		if err := mysql.Db.Model(&mysql.Plugin{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	spec := specOf(obj)
+	pluginTypeName, _ := spec["type"].(string)
+	pluginType := 0
+	for t, n := range common.PluginTypeName {
+		if n == pluginTypeName {
+			pluginType = t
+		}
+	}
+	if pluginType == 0 {
+		return fmt.Errorf("unknown plugin type %s, support: wasm, so", pluginTypeName)
+	}
+	url, _ := spec["url"].(string)
+	image, err := r.download(url)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(image)
+	checksum := hex.EncodeToString(sum[:])
+	if expected, _ := spec["sha256"].(string); expected != "" && !strings.EqualFold(expected, checksum) {
+		return fmt.Errorf("sha256 of plugin image (%s) mismatch, expected %s", checksum, expected)
+	}
+
+	if _, err = service.CreatePlugin(&mysql.Plugin{Name: name, Type: pluginType, Image: image}); err != nil {
+		return err
+	}
+	status["sha256"] = checksum
+	status["size"] = int64(len(image))
+	return nil
+}
+
+func (r *pluginReconciler) download(url string) ([]byte, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("url (%s) invalid, only support http and https", url)
+	}
+	client := &http.Client{Timeout: PLUGIN_DOWNLOAD_TIMEOUT}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download plugin image from %s failed, status: %s", url, resp.Status)
+	}
+	maxSize := int64(r.cfg.OperatorCfg.PluginMaxSize)
+	image, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(image)) > maxSize {
+		return nil, fmt.Errorf("plugin image exceeds %d bytes", maxSize)
+	}
+	return image, nil
+}
+
+func (r *pluginReconciler) delete(obj *unstructured.Unstructured, status map[string]interface{}) error {
+	if err := service.DeletePlugin(obj.GetName()); err != nil && !isNotFoundError(err) {
+		return err
+	}
+	return nil
+}
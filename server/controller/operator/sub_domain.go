@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operator
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/http/service/resource"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+// subDomainReconciler 同步 SubDomain，metadata.name 作为子域名称，
+// spec.domain 为所属云平台的名称或 lcuuid，可以引用 Domain CR，创建后不能修改
+type subDomainReconciler struct {
+	*Operator
+}
+
+func (r *subDomainReconciler) kind() string {
+	return service.CONFIG_BUNDLE_KIND_SUB_DOMAIN
+}
+
+func (r *subDomainReconciler) resource() string {
+	return "subdomains"
+}
+
+func (r *subDomainReconciler) sync(obj *unstructured.Unstructured, status map[string]interface{}, apply bool) error {
+	subDomain, err := r.getSubDomain(obj.GetName(), status)
+	if err != nil {
+		return err
+	}
+	if apply || subDomain.ID == 0 {
+		if subDomain, err = r.apply(obj, subDomain); err != nil {
+			return err
+		}
+	}
+
+	status["lcuuid"] = subDomain.Lcuuid
+	status["clusterId"] = subDomain.ClusterID
+	status["domain"] = subDomain.Domain
+	status["state"] = domainStateNames[subDomain.State]
+	status["errorMessage"] = subDomain.ErrorMsg
+	status["syncedAt"] = ""
+	if subDomain.SyncedAt != nil {
+		status["syncedAt"] = subDomain.SyncedAt.Format(common.GO_BIRTHDAY)
+	}
+	return nil
+}
+
+func (r *subDomainReconciler) getSubDomain(name string, status map[string]interface{}) (*mysql.SubDomain, error) {
+	subDomain := &mysql.SubDomain{}
+	if lcuuid, _ := status["lcuuid"].(string); lcuuid != "" {
+		if err := mysql.Db.Where("lcuuid = ?", lcuuid).Find(subDomain).Error; err != nil {
+			return nil, err
+		}
+		if subDomain.ID != 0 {
+			return subDomain, nil
+		}
+	}
+	if err := mysql.Db.Where("name = ?", name).Find(subDomain).Error; err != nil {
+		return nil, err
+	}
+	return subDomain, nil
+}
+
+// getDomainLcuuid 查找 spec.domain 对应的云平台，Domain CR 尚未同步时返回错误，等待下次同步
+func getDomainLcuuid(domain string) (string, error) {
+	var domains []mysql.Domain
+	if err := mysql.Db.Where("(lcuuid = ? OR name = ?) AND org_id = ?", domain, domain, common.DEFAULT_ORG_ID).Find(&domains).Error; err != nil {
+		return "", err
+	}
+	if len(domains) == 0 {
+		return "", fmt.Errorf("domain (%s) not found", domain)
+	}
+	return domains[0].Lcuuid, nil
+}
+
+func (r *subDomainReconciler) apply(obj *unstructured.Unstructured, subDomain *mysql.SubDomain) (*mysql.SubDomain, error) {
+	spec := upperKeys(specOf(obj))
+	bundle := model.ConfigBundle{Objects: []model.ConfigBundleObject{
+		{Kind: r.kind(), Name: obj.GetName(), Spec: spec},
+	}}
+	if validation := service.ValidateConfigBundle(common.DEFAULT_ORG_ID, bundle)[0]; validation.Error != "" {
+		return nil, errors.New(validation.Error)
+	}
+	var subDomainCreate model.SubDomainCreate
+	if err := convertSpec(spec, &subDomainCreate); err != nil {
+		return nil, err
+	}
+	domainLcuuid, err := getDomainLcuuid(subDomainCreate.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var lcuuid string
+	if subDomain.ID == 0 {
+		subDomainCreate.Name = obj.GetName()
+		subDomainCreate.Domain = domainLcuuid
+		resp, err := resource.CreateSubDomain(subDomainCreate)
+		if err != nil {
+			return nil, err
+		}
+		lcuuid = resp.Lcuuid
+	} else {
+		if subDomain.Domain != domainLcuuid {
+			return nil, fmt.Errorf("sub_domain (%s) already exists in domain (%s), domain can not be changed", subDomain.Name, subDomain.Domain)
+		}
+		subDomainUpdate := map[string]interface{}{"CONFIG": subDomainCreate.Config}
+		if _, err := resource.UpdateSubDomain(common.DEFAULT_ORG_ID, subDomain.Lcuuid, subDomainUpdate); err != nil {
+			return nil, err
+		}
+		lcuuid = subDomain.Lcuuid
+	}
+
+	subDomain = &mysql.SubDomain{}
+	if err := mysql.Db.Where("lcuuid = ?", lcuuid).First(subDomain).Error; err != nil {
+		return nil, err
+	}
+	return subDomain, nil
+}
+
+func (r *subDomainReconciler) delete(obj *unstructured.Unstructured, status map[string]interface{}) error {
+	lcuuid, _ := status["lcuuid"].(string)
+	if lcuuid == "" {
+		return nil
+	}
+	if _, err := resource.DeleteSubDomain(common.DEFAULT_ORG_ID, lcuuid); err != nil && !isNotFoundError(err) {
+		return err
+	}
+	return nil
+}
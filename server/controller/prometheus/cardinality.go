@@ -0,0 +1,362 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/deepflowio/deepflow/server/controller/db/clickhouse"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+const (
+	cardinalitySampleTable  = "prometheus.samples"
+	appLabelValueIDPrefix   = "app_label_value_id_"
+	defaultCardinalityLimit = 20
+	defaultCardinalityRange = 3600 // second
+	maxGrowthPoints         = 1440
+)
+
+type cardinalityAnalyzer struct {
+	ckDB *sqlx.DB
+	// 时序由 target_id 与所有 app_label_value_id 列唯一确定
+	seriesColumns []string
+}
+
+func newCardinalityAnalyzer(cfg clickhouse.ClickHouseConfig) (*cardinalityAnalyzer, error) {
+	ckDB, err := clickhouse.Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a := &cardinalityAnalyzer{ckDB: ckDB}
+	if err := a.loadSeriesColumns(); err != nil {
+		ckDB.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *cardinalityAnalyzer) close() {
+	a.ckDB.Close()
+}
+
+func (a *cardinalityAnalyzer) loadSeriesColumns() error {
+	var columns []string
+	err := a.ckDB.Select(&columns, fmt.Sprintf(
+		"SELECT name FROM system.columns WHERE database='prometheus' AND table='samples' AND name LIKE '%s%%'", appLabelValueIDPrefix))
+	if err != nil {
+		return err
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return appLabelColumnIndex(columns[i]) < appLabelColumnIndex(columns[j])
+	})
+	a.seriesColumns = append([]string{"target_id"}, columns...)
+	return nil
+}
+
+func appLabelColumnIndex(column string) int {
+	index, _ := strconv.Atoi(strings.TrimPrefix(column, appLabelValueIDPrefix))
+	return index
+}
+
+func (a *cardinalityAnalyzer) seriesExpr(withMetric bool) string {
+	if withMetric {
+		return fmt.Sprintf("uniq(metric_id, %s)", strings.Join(a.seriesColumns, ", "))
+	}
+	return fmt.Sprintf("uniq(%s)", strings.Join(a.seriesColumns, ", "))
+}
+
+func timeCondition(query *model.PrometheusCardinalityQuery, metricID int) string {
+	condition := fmt.Sprintf("time >= toDateTime(%d) AND time < toDateTime(%d)", query.StartTime, query.EndTime)
+	if metricID > 0 {
+		condition += fmt.Sprintf(" AND metric_id = %d", metricID)
+	}
+	return condition
+}
+
+func normalizeCardinalityQuery(query *model.PrometheusCardinalityQuery) error {
+	if query.EndTime <= 0 {
+		query.EndTime = time.Now().Unix()
+	}
+	if query.StartTime <= 0 {
+		query.StartTime = query.EndTime - defaultCardinalityRange
+	}
+	if query.StartTime >= query.EndTime {
+		return fmt.Errorf("start_time(%d) should be less than end_time(%d)", query.StartTime, query.EndTime)
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultCardinalityLimit
+	}
+	// 默认按 60 个点聚合，且不超过 maxGrowthPoints 个点
+	if query.Interval <= 0 {
+		query.Interval = int((query.EndTime - query.StartTime + 59) / 60)
+	}
+	if minInterval := int((query.EndTime - query.StartTime + maxGrowthPoints - 1) / maxGrowthPoints); query.Interval < minInterval {
+		query.Interval = minInterval
+	}
+	return nil
+}
+
+func getMetricID(metricName string) (int, error) {
+	if metricName == "" {
+		return 0, nil
+	}
+	var metric mysql.PrometheusMetricName
+	if err := mysql.Db.Where("name = ?", metricName).First(&metric).Error; err != nil {
+		return 0, fmt.Errorf("metric %s not found: %s", metricName, err.Error())
+	}
+	return metric.ID, nil
+}
+
+// GetCardinality 返回时间范围内时序数最多的指标，及取值最多的标签名。
+// 指定 metric_name 时，标签取值数按该指标在 ClickHouse 中实际写入的数据统计，否则按 MySQL 中已编码的全部标签统计
+func GetCardinality(cfg clickhouse.ClickHouseConfig, query *model.PrometheusCardinalityQuery) (*model.PrometheusCardinality, error) {
+	if err := normalizeCardinalityQuery(query); err != nil {
+		return nil, err
+	}
+	metricID, err := getMetricID(query.MetricName)
+	if err != nil {
+		return nil, err
+	}
+	a, err := newCardinalityAnalyzer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer a.close()
+
+	result := &model.PrometheusCardinality{
+		StartTime:  query.StartTime,
+		EndTime:    query.EndTime,
+		MetricName: query.MetricName,
+	}
+	err = a.ckDB.Get(&result.SeriesCount, fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		a.seriesExpr(true), cardinalitySampleTable, timeCondition(query, metricID)))
+	if err != nil {
+		return nil, err
+	}
+	if result.TopMetrics, err = a.topMetrics(query, metricID); err != nil {
+		return nil, err
+	}
+	if metricID > 0 {
+		result.TopLabelNames, err = a.metricTopLabelNames(query, metricID)
+	} else {
+		result.TopLabelNames, err = topLabelNames(query.Limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *cardinalityAnalyzer) topMetrics(query *model.PrometheusCardinalityQuery, metricID int) ([]model.PrometheusMetricCardinality, error) {
+	rows, err := a.ckDB.Query(fmt.Sprintf(
+		"SELECT metric_id, %s AS series_count, uniq(target_id) AS target_count FROM %s WHERE %s GROUP BY metric_id ORDER BY series_count DESC LIMIT %d",
+		a.seriesExpr(false), cardinalitySampleTable, timeCondition(query, metricID), query.Limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := make([]model.PrometheusMetricCardinality, 0, query.Limit)
+	metricIDs := make([]uint32, 0, query.Limit)
+	for rows.Next() {
+		var id uint32
+		var metric model.PrometheusMetricCardinality
+		if err := rows.Scan(&id, &metric.SeriesCount, &metric.TargetCount); err != nil {
+			return nil, err
+		}
+		metricIDs = append(metricIDs, id)
+		metrics = append(metrics, metric)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(metricIDs) == 0 {
+		return metrics, nil
+	}
+
+	var metricNames []mysql.PrometheusMetricName
+	if err := mysql.Db.Where("id IN (?)", metricIDs).Find(&metricNames).Error; err != nil {
+		return nil, err
+	}
+	idToName := make(map[uint32]string, len(metricNames))
+	for _, m := range metricNames {
+		idToName[uint32(m.ID)] = m.Name
+	}
+	for i, id := range metricIDs {
+		if name, ok := idToName[id]; ok {
+			metrics[i].MetricName = name
+		} else {
+			// 已被清理的指标名
+			metrics[i].MetricName = fmt.Sprintf("unknown(%d)", id)
+		}
+	}
+	return metrics, nil
+}
+
+func (a *cardinalityAnalyzer) metricTopLabelNames(query *model.PrometheusCardinalityQuery, metricID int) ([]model.PrometheusLabelCardinality, error) {
+	var layouts []mysql.PrometheusMetricAPPLabelLayout
+	if err := mysql.Db.Where("metric_name = ?", query.MetricName).Find(&layouts).Error; err != nil {
+		return nil, err
+	}
+	existColumns := make(map[string]bool, len(a.seriesColumns))
+	for _, column := range a.seriesColumns {
+		existColumns[column] = true
+	}
+	labels := make([]model.PrometheusLabelCardinality, 0, len(layouts))
+	exprs := make([]string, 0, len(layouts))
+	for _, layout := range layouts {
+		column := fmt.Sprintf("%s%d", appLabelValueIDPrefix, layout.APPLabelColumnIndex)
+		if !existColumns[column] {
+			continue
+		}
+		labels = append(labels, model.PrometheusLabelCardinality{LabelName: layout.APPLabelName})
+		// 0 表示时序中没有该标签
+		exprs = append(exprs, fmt.Sprintf("uniqIf(%s, %s != 0)", column, column))
+	}
+	if len(exprs) == 0 {
+		return labels, nil
+	}
+
+	values := make([]interface{}, len(labels))
+	for i := range labels {
+		values[i] = &labels[i].ValueCount
+	}
+	row := a.ckDB.QueryRow(fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		strings.Join(exprs, ", "), cardinalitySampleTable, timeCondition(query, metricID)))
+	if err := row.Scan(values...); err != nil {
+		return nil, err
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].ValueCount > labels[j].ValueCount })
+	if len(labels) > query.Limit {
+		labels = labels[:query.Limit]
+	}
+	return labels, nil
+}
+
+func topLabelNames(limit int) ([]model.PrometheusLabelCardinality, error) {
+	var labels []model.PrometheusLabelCardinality
+	err := mysql.Db.Model(&mysql.PrometheusLabel{}).
+		Select("name AS label_name, COUNT(*) AS value_count").
+		Group("name").Order("value_count DESC").Limit(limit).
+		Scan(&labels).Error
+	return labels, err
+}
+
+// GetCardinalityGrowth 返回每个时间间隔内的时序数，及新增的指标名、标签值数量
+func GetCardinalityGrowth(cfg clickhouse.ClickHouseConfig, query *model.PrometheusCardinalityQuery) (*model.PrometheusCardinalityGrowth, error) {
+	if err := normalizeCardinalityQuery(query); err != nil {
+		return nil, err
+	}
+	metricID, err := getMetricID(query.MetricName)
+	if err != nil {
+		return nil, err
+	}
+	a, err := newCardinalityAnalyzer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer a.close()
+
+	interval := int64(query.Interval)
+	startTime := query.StartTime - query.StartTime%interval
+	points := make([]model.PrometheusCardinalityPoint, 0, (query.EndTime-startTime)/interval+1)
+	for t := startTime; t < query.EndTime; t += interval {
+		points = append(points, model.PrometheusCardinalityPoint{Time: t})
+	}
+	pointIndex := func(t int64) int {
+		index := int((t - startTime) / interval)
+		if index < 0 || index >= len(points) {
+			return -1
+		}
+		return index
+	}
+
+	rows, err := a.ckDB.Query(fmt.Sprintf(
+		"SELECT toUnixTimestamp(toStartOfInterval(time, INTERVAL %d SECOND)) AS t, %s FROM %s WHERE %s GROUP BY t",
+		interval, a.seriesExpr(true), cardinalitySampleTable, timeCondition(query, metricID)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t uint32
+		var count uint64
+		if err := rows.Scan(&t, &count); err != nil {
+			return nil, err
+		}
+		if i := pointIndex(int64(t)); i >= 0 {
+			points[i].SeriesCount = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	newMetricNames, err := countCreatedByInterval(&mysql.PrometheusMetricName{}, query)
+	if err != nil {
+		return nil, err
+	}
+	newLabelValues, err := countCreatedByInterval(&mysql.PrometheusLabelValue{}, query)
+	if err != nil {
+		return nil, err
+	}
+	for t, count := range newMetricNames {
+		if i := pointIndex(t); i >= 0 {
+			points[i].NewMetricNames = count
+		}
+	}
+	for t, count := range newLabelValues {
+		if i := pointIndex(t); i >= 0 {
+			points[i].NewLabelValues = count
+		}
+	}
+
+	return &model.PrometheusCardinalityGrowth{
+		StartTime:  query.StartTime,
+		EndTime:    query.EndTime,
+		MetricName: query.MetricName,
+		Interval:   query.Interval,
+		Points:     points,
+	}, nil
+}
+
+func countCreatedByInterval(table interface{}, query *model.PrometheusCardinalityQuery) (map[int64]uint64, error) {
+	var results []struct {
+		T     int64
+		Count uint64
+	}
+	err := mysql.Db.Model(table).
+		Select("FLOOR(UNIX_TIMESTAMP(created_at) / ?) * ? AS t, COUNT(*) AS count", query.Interval, query.Interval).
+		Where("created_at >= FROM_UNIXTIME(?) AND created_at < FROM_UNIXTIME(?)", query.StartTime, query.EndTime).
+		Group("t").Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]uint64, len(results))
+	for _, r := range results {
+		counts[r.T] = r.Count
+	}
+	return counts, nil
+}
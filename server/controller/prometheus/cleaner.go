@@ -26,6 +26,7 @@ import (
 
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/election"
 	prometheuscfg "github.com/deepflowio/deepflow/server/controller/prometheus/config"
 	"github.com/deepflowio/deepflow/server/controller/prometheus/encoder"
 )
@@ -76,6 +77,9 @@ func (c *Cleaner) Start() error {
 			case <-c.ctx.Done():
 				return
 			case <-ticker.C:
+				if election.CheckFencingToken() != nil {
+					continue
+				}
 				c.clear(time.Time{})
 			}
 		}
@@ -24,6 +24,7 @@ import (
 	mapset "github.com/deckarep/golang-set/v2"
 
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/election"
 	"github.com/deepflowio/deepflow/server/controller/prometheus/cache"
 	"github.com/deepflowio/deepflow/server/controller/prometheus/common"
 	prometheuscfg "github.com/deepflowio/deepflow/server/controller/prometheus/config"
@@ -69,6 +70,9 @@ func (e *APPLabelLayoutUpdater) Start() error {
 			case <-e.ctx.Done():
 				return
 			case <-ticker.C:
+				if election.CheckFencingToken() != nil {
+					continue
+				}
 				e.refresh()
 			}
 		}
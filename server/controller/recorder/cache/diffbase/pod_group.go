@@ -30,6 +30,9 @@ func (b *DataSet) AddPodGroup(dbItem *mysql.PodGroup, seq int) {
 		},
 		Name:            dbItem.Name,
 		Label:           dbItem.Label,
+		Images:          dbItem.Images,
+		TemplateHash:    dbItem.TemplateHash,
+		RolloutState:    dbItem.RolloutState,
 		PodNum:          dbItem.PodNum,
 		Type:            dbItem.Type,
 		RegionLcuuid:    dbItem.Region,
@@ -48,6 +51,9 @@ type PodGroup struct {
 	DiffBase
 	Name            string `json:"name"`
 	Label           string `json:"label"`
+	Images          string `json:"images"`
+	TemplateHash    string `json:"template_hash"`
+	RolloutState    int    `json:"rollout_state"`
 	PodNum          int    `json:"pod_num"`
 	Type            int    `json:"type"`
 	RegionLcuuid    string `json:"region_lcuuid"`
@@ -55,9 +61,28 @@ type PodGroup struct {
 	SubDomainLcuuid string `json:"sub_domain_lcuuid"`
 }
 
+// IsTemplateChanged 判断是否开始了一次新的发布，升级后首次同步时 diff base 中没有模板信息，不视为发布
+func (p *PodGroup) IsTemplateChanged(cloudItem *cloudmodel.PodGroup) bool {
+	return p.TemplateHash != "" && cloudItem.TemplateHash != "" && p.TemplateHash != cloudItem.TemplateHash
+}
+
+// NextRolloutState 返回需要记录的发布状态，发布开始后直到完成前记录为 POD_GROUP_ROLLOUT_STATE_STARTED
+func (p *PodGroup) NextRolloutState(cloudItem *cloudmodel.PodGroup) int {
+	if cloudItem.RolloutState != ctrlrcommon.POD_GROUP_ROLLOUT_STATE_PROGRESSING {
+		return cloudItem.RolloutState
+	}
+	if p.IsTemplateChanged(cloudItem) || p.RolloutState == ctrlrcommon.POD_GROUP_ROLLOUT_STATE_STARTED {
+		return ctrlrcommon.POD_GROUP_ROLLOUT_STATE_STARTED
+	}
+	return cloudItem.RolloutState
+}
+
 func (p *PodGroup) Update(cloudItem *cloudmodel.PodGroup) {
 	p.Name = cloudItem.Name
 	p.Label = cloudItem.Label
+	p.Images = cloudItem.Images
+	p.RolloutState = p.NextRolloutState(cloudItem)
+	p.TemplateHash = cloudItem.TemplateHash
 	p.PodNum = cloudItem.PodNum
 	p.Type = cloudItem.Type
 	p.RegionLcuuid = cloudItem.RegionLcuuid
@@ -24,6 +24,7 @@ import (
 
 	ctrlrcommon "github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/election"
 	. "github.com/deepflowio/deepflow/server/controller/recorder/config"
 	"github.com/deepflowio/deepflow/server/controller/recorder/constraint"
 )
@@ -78,6 +79,9 @@ func (c *Cleaner) timedCleanDeletedData(cleanInterval, retentionInterval int) {
 		for {
 			select {
 			case <-ticker.C:
+				if election.CheckFencingToken() != nil {
+					continue
+				}
 				c.cleanDeletedData(retentionInterval)
 			case <-c.ctx.Done():
 				break LOOP
@@ -144,6 +148,9 @@ func (c *Cleaner) timedCleanDirtyData() {
 		for {
 			select {
 			case <-ticker.C:
+				if election.CheckFencingToken() != nil {
+					continue
+				}
 				c.cleanDirtyData()
 			case <-c.ctx.Done():
 				break LOOP
@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"fmt"
+
+	cloudmodel "github.com/deepflowio/deepflow/server/controller/cloud/model"
+	ctrlrcommon "github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/recorder/cache/diffbase"
+	"github.com/deepflowio/deepflow/server/controller/recorder/cache/tool"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+)
+
+var (
+	DESCRolloutStartedFormat  = "%s rollout started, template changes from %s to %s."
+	DESCRolloutFinishedFormat = "%s rollout finished."
+	DESCImageChangeFormat     = "%s image changes from %s to %s."
+	DESCScaleFormat           = "%s scales from %d to %d replicas."
+	DESCLabelChangeFormat     = "%s label changes from %s to %s."
+)
+
+var podGroupTypeToDeviceType = map[int]int{
+	ctrlrcommon.POD_GROUP_DEPLOYMENT:            ctrlrcommon.VIF_DEVICE_TYPE_POD_GROUP_DEPLOYMENT,
+	ctrlrcommon.POD_GROUP_STATEFULSET:           ctrlrcommon.VIF_DEVICE_TYPE_POD_GROUP_STATEFULSET,
+	ctrlrcommon.POD_GROUP_RC:                    ctrlrcommon.VIF_DEVICE_TYPE_POD_GROUP_RC,
+	ctrlrcommon.POD_GROUP_DAEMON_SET:            ctrlrcommon.VIF_DEVICE_TYPE_POD_GROUP_DAEMON_SET,
+	ctrlrcommon.POD_GROUP_REPLICASET_CONTROLLER: ctrlrcommon.VIF_DEVICE_TYPE_POD_GROUP_REPLICASET_CONTROLLER,
+	ctrlrcommon.POD_GROUP_CLONESET:              ctrlrcommon.VIF_DEVICE_TYPE_POD_GROUP_CLONESET,
+}
+
+// PodGroup 生成工作负载级别的变更事件：发布开始/完成、镜像变更、扩缩容及标签变更
+type PodGroup struct {
+	EventManagerBase
+}
+
+func NewPodGroup(toolDS *tool.DataSet, eq *queue.OverwriteQueue) *PodGroup {
+	mng := &PodGroup{
+		EventManagerBase{
+			resourceType: ctrlrcommon.RESOURCE_TYPE_POD_GROUP_EN,
+			ToolDataSet:  toolDS,
+			Queue:        eq,
+		},
+	}
+	return mng
+}
+
+func (p *PodGroup) ProduceByUpdate(cloudItem *cloudmodel.PodGroup, diffBase *diffbase.PodGroup) {
+	templateChanged := diffBase.IsTemplateChanged(cloudItem)
+	if templateChanged {
+		p.createAndEnqueueByCloudItem(cloudItem, eventapi.RESOURCE_EVENT_TYPE_ROLLOUT_STARTED,
+			fmt.Sprintf(DESCRolloutStartedFormat, cloudItem.Name, diffBase.TemplateHash, cloudItem.TemplateHash))
+	}
+	if diffBase.Images != "" && cloudItem.Images != "" && diffBase.Images != cloudItem.Images {
+		p.createAndEnqueueByCloudItem(cloudItem, eventapi.RESOURCE_EVENT_TYPE_IMAGE_CHANGED,
+			fmt.Sprintf(DESCImageChangeFormat, cloudItem.Name, diffBase.Images, cloudItem.Images))
+	}
+	// 发布在两次同步之间完成时，同时生成开始及完成事件；
+	// 扩缩容等未生成开始事件的 progressing 状态结束时不生成完成事件
+	if cloudItem.RolloutState == ctrlrcommon.POD_GROUP_ROLLOUT_STATE_COMPLETE &&
+		(templateChanged || diffBase.RolloutState == ctrlrcommon.POD_GROUP_ROLLOUT_STATE_STARTED) {
+		p.createAndEnqueueByCloudItem(cloudItem, eventapi.RESOURCE_EVENT_TYPE_ROLLOUT_FINISHED,
+			fmt.Sprintf(DESCRolloutFinishedFormat, cloudItem.Name))
+	}
+	if diffBase.PodNum < cloudItem.PodNum {
+		p.createAndEnqueueByCloudItem(cloudItem, eventapi.RESOURCE_EVENT_TYPE_SCALE_UP,
+			fmt.Sprintf(DESCScaleFormat, cloudItem.Name, diffBase.PodNum, cloudItem.PodNum))
+	} else if diffBase.PodNum > cloudItem.PodNum {
+		p.createAndEnqueueByCloudItem(cloudItem, eventapi.RESOURCE_EVENT_TYPE_SCALE_DOWN,
+			fmt.Sprintf(DESCScaleFormat, cloudItem.Name, diffBase.PodNum, cloudItem.PodNum))
+	}
+	if diffBase.Label != cloudItem.Label {
+		p.createAndEnqueueByCloudItem(cloudItem, eventapi.RESOURCE_EVENT_TYPE_LABEL_CHANGED,
+			fmt.Sprintf(DESCLabelChangeFormat, cloudItem.Name, diffBase.Label, cloudItem.Label))
+	}
+}
+
+// ingester 无法根据 pod group 补充标签，事件中直接填充所需标签
+func (p *PodGroup) createAndEnqueueByCloudItem(cloudItem *cloudmodel.PodGroup, eventType, description string) {
+	id, ok := p.ToolDataSet.GetPodGroupIDByLcuuid(cloudItem.Lcuuid)
+	if !ok {
+		log.Error(idByLcuuidNotFound(p.resourceType, cloudItem.Lcuuid))
+		return
+	}
+	deviceType, ok := podGroupTypeToDeviceType[cloudItem.Type]
+	if !ok {
+		deviceType = ctrlrcommon.VIF_DEVICE_TYPE_POD_GROUP
+	}
+	opts := []eventapi.TagFieldOption{
+		eventapi.TagDescription(description),
+		eventapi.TagPodGroupID(id),
+		eventapi.TagPodGroupType(deviceType),
+	}
+	if podClusterID, ok := p.ToolDataSet.GetPodClusterIDByLcuuid(cloudItem.PodClusterLcuuid); ok {
+		opts = append(opts, eventapi.TagPodClusterID(podClusterID))
+	}
+	if podNSID, ok := p.ToolDataSet.GetPodNamespaceIDByLcuuid(cloudItem.PodNamespaceLcuuid); ok {
+		opts = append(opts, eventapi.TagPodNSID(podNSID))
+	}
+	if regionID, ok := p.ToolDataSet.GetRegionIDByLcuuid(cloudItem.RegionLcuuid); ok {
+		opts = append(opts, eventapi.TagRegionID(regionID))
+	}
+	if azID, ok := p.ToolDataSet.GetAZIDByLcuuid(cloudItem.AZLcuuid); ok {
+		opts = append(opts, eventapi.TagAZID(azID))
+	}
+
+	event := eventapi.AcquireResourceEvent()
+	p.fillEvent(event, eventType, cloudItem.Name, deviceType, id, opts...)
+	event.IfNeedTagged = false
+	p.enqueue(cloudItem.Lcuuid, event)
+}
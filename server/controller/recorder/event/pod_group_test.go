@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+
+	cloudmodel "github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/recorder/cache/diffbase"
+	"github.com/deepflowio/deepflow/server/controller/recorder/cache/tool"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+)
+
+func TestUpdatePodGroup(t *testing.T) {
+	ds := tool.NewDataSet()
+	id := RandID()
+	monkey := gomonkey.ApplyPrivateMethod(reflect.TypeOf(ds), "GetPodGroupIDByLcuuid", func(_ *tool.DataSet, _ string) (int, bool) {
+		return id, true
+	})
+	defer monkey.Reset()
+	monkey1 := gomonkey.ApplyPrivateMethod(reflect.TypeOf(ds), "GetPodClusterIDByLcuuid", func(_ *tool.DataSet, _ string) (int, bool) {
+		return 0, false
+	})
+	defer monkey1.Reset()
+	monkey2 := gomonkey.ApplyPrivateMethod(reflect.TypeOf(ds), "GetPodNamespaceIDByLcuuid", func(_ *tool.DataSet, _ string) (int, bool) {
+		return 0, false
+	})
+	defer monkey2.Reset()
+	monkey3 := gomonkey.ApplyPrivateMethod(reflect.TypeOf(ds), "GetRegionIDByLcuuid", func(_ *tool.DataSet, _ string) (int, bool) {
+		return 0, false
+	})
+	defer monkey3.Reset()
+	monkey4 := gomonkey.ApplyPrivateMethod(reflect.TypeOf(ds), "GetAZIDByLcuuid", func(_ *tool.DataSet, _ string) (int, bool) {
+		return 0, false
+	})
+	defer monkey4.Reset()
+
+	name := RandName()
+	base := diffbase.PodGroup{
+		Name:         name,
+		PodNum:       2,
+		Images:       "app=app:v1",
+		TemplateHash: "hash-1",
+		RolloutState: common.POD_GROUP_ROLLOUT_STATE_COMPLETE,
+	}
+	item := cloudmodel.PodGroup{
+		Lcuuid:       RandLcuuid(),
+		Name:         name,
+		Type:         common.POD_GROUP_DEPLOYMENT,
+		PodNum:       2,
+		Images:       "app=app:v1",
+		TemplateHash: "hash-1",
+		RolloutState: common.POD_GROUP_ROLLOUT_STATE_COMPLETE,
+	}
+
+	tests := []struct {
+		name       string
+		update     func(b *diffbase.PodGroup, c *cloudmodel.PodGroup)
+		wantEvents []string
+	}{
+		{
+			name:       "no change",
+			update:     func(b *diffbase.PodGroup, c *cloudmodel.PodGroup) {},
+			wantEvents: nil,
+		},
+		{
+			name: "image changed and rollout in progress",
+			update: func(b *diffbase.PodGroup, c *cloudmodel.PodGroup) {
+				c.Images = "app=app:v2"
+				c.TemplateHash = "hash-2"
+				c.RolloutState = common.POD_GROUP_ROLLOUT_STATE_PROGRESSING
+			},
+			wantEvents: []string{eventapi.RESOURCE_EVENT_TYPE_ROLLOUT_STARTED, eventapi.RESOURCE_EVENT_TYPE_IMAGE_CHANGED},
+		},
+		{
+			name: "config driven restart finished between two syncs",
+			update: func(b *diffbase.PodGroup, c *cloudmodel.PodGroup) {
+				c.TemplateHash = "hash-2"
+			},
+			wantEvents: []string{eventapi.RESOURCE_EVENT_TYPE_ROLLOUT_STARTED, eventapi.RESOURCE_EVENT_TYPE_ROLLOUT_FINISHED},
+		},
+		{
+			name: "rollout finished",
+			update: func(b *diffbase.PodGroup, c *cloudmodel.PodGroup) {
+				b.RolloutState = common.POD_GROUP_ROLLOUT_STATE_STARTED
+			},
+			wantEvents: []string{eventapi.RESOURCE_EVENT_TYPE_ROLLOUT_FINISHED},
+		},
+		{
+			name: "progressing finished without rollout started",
+			update: func(b *diffbase.PodGroup, c *cloudmodel.PodGroup) {
+				b.RolloutState = common.POD_GROUP_ROLLOUT_STATE_PROGRESSING
+			},
+			wantEvents: nil,
+		},
+		{
+			name: "template hash first synced",
+			update: func(b *diffbase.PodGroup, c *cloudmodel.PodGroup) {
+				b.Images = ""
+				b.TemplateHash = ""
+			},
+			wantEvents: nil,
+		},
+		{
+			name: "scale up",
+			update: func(b *diffbase.PodGroup, c *cloudmodel.PodGroup) {
+				c.PodNum = 3
+			},
+			wantEvents: []string{eventapi.RESOURCE_EVENT_TYPE_SCALE_UP},
+		},
+		{
+			name: "scale down and label changed",
+			update: func(b *diffbase.PodGroup, c *cloudmodel.PodGroup) {
+				c.PodNum = 1
+				c.Label = "app:web"
+			},
+			wantEvents: []string{eventapi.RESOURCE_EVENT_TYPE_SCALE_DOWN, eventapi.RESOURCE_EVENT_TYPE_LABEL_CHANGED},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := base
+			c := item
+			tt.update(&b, &c)
+
+			eq := NewEventQueue()
+			NewPodGroup(ds, eq).ProduceByUpdate(&c, &b)
+			var gotEvents []string
+			for eq.Len() > 0 {
+				e := eq.Get().(*eventapi.ResourceEvent)
+				assert.Equal(t, uint32(common.VIF_DEVICE_TYPE_POD_GROUP_DEPLOYMENT), e.InstanceType)
+				assert.Equal(t, uint32(id), e.InstanceID)
+				assert.Equal(t, uint32(id), e.PodGroupID)
+				assert.Equal(t, name, e.InstanceName)
+				assert.False(t, e.IfNeedTagged)
+				gotEvents = append(gotEvents, e.Type)
+			}
+			assert.Equal(t, tt.wantEvents, gotEvents)
+		})
+	}
+}
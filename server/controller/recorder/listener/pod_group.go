@@ -21,15 +21,19 @@ import (
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
 	"github.com/deepflowio/deepflow/server/controller/recorder/cache"
 	"github.com/deepflowio/deepflow/server/controller/recorder/cache/diffbase"
+	"github.com/deepflowio/deepflow/server/controller/recorder/event"
+	"github.com/deepflowio/deepflow/server/libs/queue"
 )
 
 type PodGroup struct {
-	cache *cache.Cache
+	cache         *cache.Cache
+	eventProducer *event.PodGroup
 }
 
-func NewPodGroup(c *cache.Cache) *PodGroup {
+func NewPodGroup(c *cache.Cache, eq *queue.OverwriteQueue) *PodGroup {
 	listener := &PodGroup{
-		cache: c,
+		cache:         c,
+		eventProducer: event.NewPodGroup(c.ToolDataSet, eq),
 	}
 	return listener
 }
@@ -39,6 +43,7 @@ func (p *PodGroup) OnUpdaterAdded(addedDBItems []*mysql.PodGroup) {
 }
 
 func (p *PodGroup) OnUpdaterUpdated(cloudItem *cloudmodel.PodGroup, diffBase *diffbase.PodGroup) {
+	p.eventProducer.ProduceByUpdate(cloudItem, diffBase)
 	diffBase.Update(cloudItem)
 }
 
@@ -690,6 +690,9 @@ type PodGroupFieldsUpdate struct {
 	Key
 	Name         fieldDetail[string]
 	Label        fieldDetail[string]
+	Images       fieldDetail[string]
+	TemplateHash fieldDetail[string]
+	RolloutState fieldDetail[int]
 	Type         fieldDetail[int]
 	PodNum       fieldDetail[int]
 	AZLcuuid     fieldDetail[string]
@@ -213,7 +213,7 @@ func (r *Recorder) getDomainUpdatersInOrder(cloudData cloudmodel.Resource) []upd
 		updater.NewPodServicePort(r.cacheMng.DomainCache, cloudData.PodServicePorts).RegisterListener(
 			listener.NewPodServicePort(r.cacheMng.DomainCache)),
 		updater.NewPodGroup(r.cacheMng.DomainCache, cloudData.PodGroups).RegisterListener(
-			listener.NewPodGroup(r.cacheMng.DomainCache)),
+			listener.NewPodGroup(r.cacheMng.DomainCache, r.eventQueue)),
 		updater.NewPodGroupPort(r.cacheMng.DomainCache, cloudData.PodGroupPorts).RegisterListener(
 			listener.NewPodGroupPort(r.cacheMng.DomainCache)),
 		updater.NewPodReplicaSet(r.cacheMng.DomainCache, cloudData.PodReplicaSets).RegisterListener(
@@ -346,7 +346,7 @@ func (r *Recorder) getSubDomainUpdatersInOrder(subDomainLcuuid string, cloudData
 		updater.NewPodServicePort(subDomainCache, cloudData.PodServicePorts).RegisterListener(
 			listener.NewPodServicePort(subDomainCache)),
 		updater.NewPodGroup(subDomainCache, cloudData.PodGroups).RegisterListener(
-			listener.NewPodGroup(subDomainCache)),
+			listener.NewPodGroup(subDomainCache, r.eventQueue)),
 		updater.NewPodGroupPort(subDomainCache, cloudData.PodGroupPorts).RegisterListener(
 			listener.NewPodGroupPort(subDomainCache)),
 		updater.NewPodReplicaSet(subDomainCache, cloudData.PodReplicaSets).RegisterListener(
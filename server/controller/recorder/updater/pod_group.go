@@ -92,6 +92,9 @@ func (p *PodGroup) generateDBItemToAdd(cloudItem *cloudmodel.PodGroup) (*mysql.P
 		Name:           cloudItem.Name,
 		Type:           cloudItem.Type,
 		Label:          cloudItem.Label,
+		Images:         cloudItem.Images,
+		TemplateHash:   cloudItem.TemplateHash,
+		RolloutState:   cloudItem.RolloutState,
 		PodNum:         cloudItem.PodNum,
 		PodNamespaceID: podNamespaceID,
 		PodClusterID:   podClusterID,
@@ -123,6 +126,18 @@ func (p *PodGroup) generateUpdateInfo(diffBase *diffbase.PodGroup, cloudItem *cl
 		mapInfo["label"] = cloudItem.Label
 		structInfo.Label.Set(diffBase.Label, cloudItem.Label)
 	}
+	if diffBase.Images != cloudItem.Images {
+		mapInfo["images"] = cloudItem.Images
+		structInfo.Images.Set(diffBase.Images, cloudItem.Images)
+	}
+	if diffBase.TemplateHash != cloudItem.TemplateHash {
+		mapInfo["template_hash"] = cloudItem.TemplateHash
+		structInfo.TemplateHash.Set(diffBase.TemplateHash, cloudItem.TemplateHash)
+	}
+	if rolloutState := diffBase.NextRolloutState(cloudItem); diffBase.RolloutState != rolloutState {
+		mapInfo["rollout_state"] = rolloutState
+		structInfo.RolloutState.Set(diffBase.RolloutState, rolloutState)
+	}
 	if diffBase.RegionLcuuid != cloudItem.RegionLcuuid {
 		mapInfo["region"] = cloudItem.RegionLcuuid
 		structInfo.RegionLcuuid.Set(diffBase.RegionLcuuid, cloudItem.RegionLcuuid)
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tagrecorder
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+)
+
+type ChCustomIPTag struct {
+	UpdaterComponent[mysql.ChCustomIPTag, CustomIPTagKey]
+}
+
+func NewChCustomIPTag() *ChCustomIPTag {
+	updater := &ChCustomIPTag{
+		newUpdaterComponent[mysql.ChCustomIPTag, CustomIPTagKey](
+			RESOURCE_TYPE_CH_CUSTOM_IP_TAG,
+		),
+	}
+	updater.updaterDG = updater
+	return updater
+}
+
+func (c *ChCustomIPTag) generateNewData() (map[CustomIPTagKey]mysql.ChCustomIPTag, bool) {
+	var customIPTags []mysql.CustomIPTag
+	err := mysql.Db.Unscoped().Order("tag_set_id").Order("id").Find(&customIPTags).Error
+	if err != nil {
+		log.Errorf(dbQueryResourceFailed(c.resourceTypeName, err))
+		return nil, false
+	}
+
+	keyToItem := make(map[CustomIPTagKey]mysql.ChCustomIPTag)
+	for prefix, tags := range mergeCustomIPTags(customIPTags) {
+		keyToItem[CustomIPTagKey{Prefix: prefix}] = mysql.ChCustomIPTag{
+			Prefix: prefix,
+			Tags:   tags,
+		}
+	}
+	return keyToItem, true
+}
+
+func (c *ChCustomIPTag) generateKey(dbItem mysql.ChCustomIPTag) CustomIPTagKey {
+	return CustomIPTagKey{Prefix: dbItem.Prefix}
+}
+
+func (c *ChCustomIPTag) generateUpdateInfo(oldItem, newItem mysql.ChCustomIPTag) (map[string]interface{}, bool) {
+	updateInfo := make(map[string]interface{})
+	if oldItem.Tags != newItem.Tags {
+		updateInfo["tags"] = newItem.Tags
+	}
+	if len(updateInfo) > 0 {
+		return updateInfo, true
+	}
+	return nil, false
+}
+
+type customIPTagRule struct {
+	l3EPCID int
+	tags    map[string]string
+}
+
+// mergeCustomIPTags 将每个前缀的标签与覆盖它的所有更短前缀合并，使 IP_TRIE 字典最长前缀匹配到的
+// 一条记录即包含该 IP 的全部标签，结果为前缀到 {"<l3_epc_id>": {"<key>": "<value>"}} 的 json。
+// 同一标签键的优先级：前缀越长越优先；前缀相同时指定 VPC 的条目优先于所有 VPC 的条目；
+// 其余情况下靠后的条目（ID 更大的标签集合）优先。
+func mergeCustomIPTags(customIPTags []mysql.CustomIPTag) map[string]string {
+	prefixToNet := make(map[string]*net.IPNet)
+	prefixToRules := make(map[string][]customIPTagRule)
+	bitsToPrefixLens := make(map[int][]int)
+	for _, customIPTag := range customIPTags {
+		_, ipNet, err := net.ParseCIDR(customIPTag.CIDR)
+		if err != nil {
+			log.Warningf("invalid cidr (%s) of custom ip tag (%d)", customIPTag.CIDR, customIPTag.ID)
+			continue
+		}
+		tags := make(map[string]string)
+		if err := json.Unmarshal([]byte(customIPTag.Tags), &tags); err != nil {
+			log.Warningf("unmarshal tags of custom ip tag (%d) failed: %s", customIPTag.ID, err.Error())
+			continue
+		}
+		prefix := ipNet.String()
+		if _, ok := prefixToNet[prefix]; !ok {
+			prefixToNet[prefix] = ipNet
+			ones, bits := ipNet.Mask.Size()
+			bitsToPrefixLens[bits] = append(bitsToPrefixLens[bits], ones)
+		}
+		prefixToRules[prefix] = append(prefixToRules[prefix], customIPTagRule{l3EPCID: customIPTag.L3EPCID, tags: tags})
+	}
+	for bits, prefixLens := range bitsToPrefixLens {
+		sort.Ints(prefixLens)
+		bitsToPrefixLens[bits] = prefixLens
+	}
+
+	prefixToTags := make(map[string]string, len(prefixToNet))
+	for prefix, ipNet := range prefixToNet {
+		ones, bits := ipNet.Mask.Size()
+		// 按优先级从低到高收集覆盖当前前缀的条目
+		coveringRules := []customIPTagRule{}
+		for _, prefixLen := range bitsToPrefixLens[bits] {
+			if prefixLen > ones {
+				break
+			}
+			mask := net.CIDRMask(prefixLen, bits)
+			rules := prefixToRules[(&net.IPNet{IP: ipNet.IP.Mask(mask), Mask: mask}).String()]
+			for _, rule := range rules {
+				if rule.l3EPCID == 0 {
+					coveringRules = append(coveringRules, rule)
+				}
+			}
+			for _, rule := range rules {
+				if rule.l3EPCID != 0 {
+					coveringRules = append(coveringRules, rule)
+				}
+			}
+		}
+
+		// 所有 VPC 的条目同时合并到已出现的各 VPC 中，VPC 首次出现时继承此前所有 VPC 的标签
+		epcIDToTags := map[int]map[string]string{0: {}}
+		for _, rule := range coveringRules {
+			if rule.l3EPCID == 0 {
+				for _, tags := range epcIDToTags {
+					for key, value := range rule.tags {
+						tags[key] = value
+					}
+				}
+				continue
+			}
+			tags, ok := epcIDToTags[rule.l3EPCID]
+			if !ok {
+				tags = make(map[string]string, len(epcIDToTags[0])+len(rule.tags))
+				for key, value := range epcIDToTags[0] {
+					tags[key] = value
+				}
+				epcIDToTags[rule.l3EPCID] = tags
+			}
+			for key, value := range rule.tags {
+				tags[key] = value
+			}
+		}
+
+		epcIDStrToTags := make(map[string]map[string]string, len(epcIDToTags))
+		for epcID, tags := range epcIDToTags {
+			if len(tags) > 0 {
+				epcIDStrToTags[strconv.Itoa(epcID)] = tags
+			}
+		}
+		if len(epcIDStrToTags) == 0 {
+			continue
+		}
+		tagsJson, err := json.Marshal(epcIDStrToTags)
+		if err != nil {
+			log.Warningf("marshal tags of prefix (%s) failed: %s", prefix, err.Error())
+			continue
+		}
+		prefixToTags[prefix] = string(tagsJson)
+	}
+	return prefixToTags
+}
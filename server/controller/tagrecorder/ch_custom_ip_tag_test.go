@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tagrecorder
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	trconfig "github.com/deepflowio/deepflow/server/controller/tagrecorder/config"
+)
+
+func (t *SuiteTest) TestMergeCustomIPTags() {
+	customIPTags := []mysql.CustomIPTag{
+		{ID: 1, TagSetID: 1, CIDR: "10.0.0.0/8", Tags: `{"team":"infra","env":"prod"}`},
+		{ID: 2, TagSetID: 1, L3EPCID: 3, CIDR: "10.0.0.0/8", Tags: `{"env":"test"}`},
+		{ID: 3, TagSetID: 1, CIDR: "10.1.0.0/16", Tags: `{"team":"payment"}`},
+		{ID: 4, TagSetID: 2, L3EPCID: 5, CIDR: "10.1.2.3/32", Tags: `{"owner":"alice"}`},
+		{ID: 5, TagSetID: 2, CIDR: "2001:db8::/32", Tags: `{"team":"v6"}`},
+		{ID: 6, TagSetID: 2, CIDR: "invalid", Tags: `{"team":"invalid"}`},
+	}
+	assert.Equal(t.T(), map[string]string{
+		"10.0.0.0/8":    `{"0":{"env":"prod","team":"infra"},"3":{"env":"test","team":"infra"}}`,
+		"10.1.0.0/16":   `{"0":{"env":"prod","team":"payment"},"3":{"env":"test","team":"payment"}}`,
+		"10.1.2.3/32":   `{"0":{"env":"prod","team":"payment"},"3":{"env":"test","team":"payment"},"5":{"env":"prod","owner":"alice","team":"payment"}}`,
+		"2001:db8::/32": `{"0":{"team":"v6"}}`,
+	}, mergeCustomIPTags(customIPTags))
+}
+
+func (t *SuiteTest) TestRefreshChCustomIPTag() {
+	updater := NewChCustomIPTag()
+	updater.SetConfig(trconfig.TagRecorderConfig{MySQLBatchSize: 1000})
+	customIPTag := mysql.CustomIPTag{TagSetID: 1, CIDR: "192.168.0.0/24", Tags: `{"team":"order"}`}
+	t.db.Create(&customIPTag)
+	updater.Refresh()
+	var addedItem mysql.ChCustomIPTag
+	t.db.Where("prefix = ?", customIPTag.CIDR).Unscoped().Find(&addedItem)
+	assert.Equal(t.T(), `{"0":{"team":"order"}}`, addedItem.Tags)
+
+	customIPTag.Tags = `{"team":"payment"}`
+	t.db.Save(&customIPTag)
+	updater.Refresh()
+	var updatedItem mysql.ChCustomIPTag
+	t.db.Where("prefix = ?", customIPTag.CIDR).Unscoped().Find(&updatedItem)
+	assert.Equal(t.T(), `{"0":{"team":"payment"}}`, updatedItem.Tags)
+
+	t.db.Delete(&customIPTag)
+	updater.Refresh()
+	var deletedItem mysql.ChCustomIPTag
+	result := t.db.Unscoped().Find(&deletedItem)
+	assert.Equal(t.T(), result.RowsAffected, int64(0))
+
+	t.db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&mysql.CustomIPTag{})
+}
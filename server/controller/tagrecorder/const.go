@@ -102,6 +102,7 @@ const (
 	RESOURCE_TYPE_CH_CHOST          = "ch_chost"
 	RESOURCE_TYPE_CH_POLICY         = "ch_policy"
 	RESOURCE_TYPE_CH_NPB_TUNNEL     = "ch_npb_tunnel"
+	RESOURCE_TYPE_CH_CUSTOM_IP_TAG  = "ch_custom_ip_tag"
 
 	RESOURCE_TYPE_CH_POD_GROUP_DEPLOYMENT            = "pod_group_deployment"
 	RESOURCE_TYPE_CH_POD_GROUP_STATEFULSET           = "pod_group_statefulset"
@@ -177,6 +178,8 @@ const (
 	CH_DICTIONARY_POLICY     = "policy_map"
 	CH_DICTIONARY_NPB_TUNNEL = "npb_tunnel_map"
 
+	CH_DICTIONARY_CUSTOM_IP_TAG = "custom_ip_tag_map"
+
 	CH_TARGET_LABEL                       = "target_label_map"
 	CH_APP_LABEL                          = "app_label_map"
 	CH_PROMETHEUS_LABEL_NAME              = "prometheus_label_name_map"
@@ -693,6 +696,16 @@ const (
 		"SOURCE(MYSQL(PORT %s USER '%s' PASSWORD '%s' %s DB %s TABLE %s INVALIDATE_QUERY 'select(select updated_at from %s order by updated_at desc limit 1) as updated_at'))\n" +
 		"LIFETIME(MIN 30 MAX %d)\n" +
 		"LAYOUT(COMPLEX_KEY_HASHED())"
+	// prefix 为 IP/CIDR，按最长前缀匹配；tags 为 l3_epc_id 到标签键值的 json，0 表示所有 VPC
+	CREATE_CUSTOM_IP_TAG_DICTIONARY_SQL = "CREATE DICTIONARY %s.%s\n" +
+		"(\n" +
+		"    `prefix` String,\n" +
+		"    `tags` String\n" +
+		")\n" +
+		"PRIMARY KEY prefix\n" +
+		"SOURCE(MYSQL(PORT %s USER '%s' PASSWORD '%s' %s DB %s TABLE %s INVALIDATE_QUERY 'select(select updated_at from %s order by updated_at desc limit 1) as updated_at'))\n" +
+		"LIFETIME(MIN 30 MAX %d)\n" +
+		"LAYOUT(IP_TRIE())"
 )
 
 const (
@@ -808,6 +821,8 @@ var CREATE_SQL_MAP = map[string]string{
 	CH_DICTIONARY_POLICY:     CREATE_POLICY_DICTIONARY_SQL,
 	CH_DICTIONARY_NPB_TUNNEL: CREATE_ID_NAME_DICTIONARY_SQL,
 
+	CH_DICTIONARY_CUSTOM_IP_TAG: CREATE_CUSTOM_IP_TAG_DICTIONARY_SQL,
+
 	CH_PROMETHEUS_LABEL_NAME:              CREATE_PROMETHEUS_LABEL_NAME_DICTIONARY_SQL,
 	CH_PROMETHEUS_METRIC_NAME:             CREATE_PROMETHEUS_LABEL_NAME_DICTIONARY_SQL,
 	CH_PROMETHEUS_METRIC_APP_LABEL_LAYOUT: CREATE_PROMETHEUS_METRIC_APP_LABEL_LAYOUT_DICTIONARY_SQL,
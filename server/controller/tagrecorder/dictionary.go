@@ -245,6 +245,8 @@ func (c *Dictionary) update(clickHouseCfg *clickhouse.ClickHouseConfig) {
 
 		CH_DICTIONARY_POLICY,
 		CH_DICTIONARY_NPB_TUNNEL,
+
+		CH_DICTIONARY_CUSTOM_IP_TAG,
 	)
 	chDicts := mapset.NewSet()
 	for _, dictionary := range dictionaries {
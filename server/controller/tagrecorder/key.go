@@ -138,3 +138,7 @@ type PolicyKey struct {
 	ACLGID     int
 	TunnelType int
 }
+
+type CustomIPTagKey struct {
+	Prefix string
+}
@@ -87,5 +87,6 @@ func getModels() []interface{} {
 		&mysql.LBVMConnection{}, &mysql.PodIngress{}, &mysql.PodService{}, mysql.PodGroup{},
 		&mysql.PodGroupPort{}, &mysql.Pod{},
 		&mysql.ChRegion{}, &mysql.ChAZ{}, &mysql.ChVPC{}, &mysql.ChIPRelation{},
+		&mysql.CustomIPTag{}, &mysql.ChCustomIPTag{},
 	}
 }
@@ -23,6 +23,7 @@ import (
 	"github.com/deepflowio/deepflow/server/controller/config"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql/query"
+	"github.com/deepflowio/deepflow/server/controller/election"
 	trconfig "github.com/deepflowio/deepflow/server/controller/tagrecorder/config"
 )
 
@@ -50,6 +51,9 @@ func (c *UpdaterManager) Start() {
 	log.Info("tagrecorder updater manager started")
 	go func() {
 		for range time.Tick(time.Duration(c.cfg.TagRecorderCfg.Interval) * time.Second) {
+			if election.CheckFencingToken() != nil {
+				continue
+			}
 			c.run()
 		}
 	}()
@@ -117,6 +121,7 @@ func (c *UpdaterManager) refresh() {
 
 		NewChPolicy(),
 		NewChNpbTunnel(),
+		NewChCustomIPTag(),
 	}
 	if c.cfg.RedisCfg.Enabled {
 		updaters = append(updaters, NewChIPResource(c.tCtx))
@@ -345,6 +345,16 @@ func (op *PolicyDataOP) getVTapPolicyString(vtapID int, functions mapset.Set) []
 	return policyStr
 }
 
+// getVTapFlowACLs returns all flow acls sent to the vtap regardless of the billing method
+func (op *PolicyDataOP) getVTapFlowACLs(vtapID int) (uint64, []*trident.FlowAcl) {
+	vtapIDToPolicy := op.getVTapIDToPolicy()
+	if policy, ok := vtapIDToPolicy[vtapID]; ok {
+		return policy.version, policy.flowACLs
+	}
+	allVTapSharePolicy := op.getAllVTapSharePolicy()
+	return allVTapSharePolicy.version, allVTapSharePolicy.flowACLs
+}
+
 func (op *PolicyDataOP) generatePolicyData() {
 	op.generateRawData()
 	op.generatePolicies()
@@ -556,13 +566,18 @@ func (op *PolicyDataOP) generateProtoPorts(acl *models.ACL, flowACL *trident.Flo
 }
 
 var (
-	tapSideSRC  = trident.TapSide_SRC
-	tapSideDST  = trident.TapSide_DST
-	tapSideBOTH = trident.TapSide_BOTH
-
 	tunnelTypePCAP = trident.TunnelType_PCAP
 )
 
+// protoTapSide 策略中的 tap_side 与 trident.TapSide 取值一致，未设置时（旧数据）按 SRC 下发
+func protoTapSide(tapSide int) *trident.TapSide {
+	side := trident.TapSide(tapSide)
+	if _, ok := trident.TapSide_name[int32(side)]; !ok {
+		side = trident.TapSide_SRC
+	}
+	return &side
+}
+
 func (op *PolicyDataOP) generateProtoActions(acl *models.ACL) (map[int][]*trident.NpbAction, []*trident.NpbAction) {
 	vtapIDToNpbActions := make(map[int][]*trident.NpbAction)
 	allVTapNpbActions := []*trident.NpbAction{}
@@ -595,7 +610,7 @@ func (op *PolicyDataOP) generateProtoActions(acl *models.ACL) (map[int][]*triden
 			npbAction := &trident.NpbAction{
 				TunnelId:      proto.Uint32(uint32(npbPolicy.Vni)),
 				TunnelIp:      proto.String(npbTunnel.IP),
-				TapSide:       &tapSideSRC,
+				TapSide:       protoTapSide(npbPolicy.TapSide),
 				TunnelType:    &tunnelType,
 				PayloadSlice:  proto.Uint32(uint32(payloadSlice)),
 				TunnelIpId:    proto.Uint32(uint32(npbTunnel.ID)),
@@ -624,7 +639,7 @@ func (op *PolicyDataOP) generateProtoActions(acl *models.ACL) (map[int][]*triden
 				payloadSlice = *pcapPolicy.PayloadSlice
 			}
 			npbAction := &trident.NpbAction{
-				TapSide:       &tapSideSRC,
+				TapSide:       protoTapSide(pcapPolicy.TapSide),
 				TunnelType:    &tunnelTypePCAP,
 				PayloadSlice:  proto.Uint32(uint32(payloadSlice)),
 				NpbAclGroupId: proto.Uint32(uint32(pcapPolicy.PolicyACLGroupID)),
@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import (
+	"fmt"
+
+	"github.com/deepflowio/deepflow/message/trident"
+	"github.com/gin-gonic/gin"
+	"github.com/op/go-logging"
+
+	models "github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	"github.com/deepflowio/deepflow/server/controller/trisolaris"
+	"github.com/deepflowio/deepflow/server/controller/trisolaris/dbmgr"
+	"github.com/deepflowio/deepflow/server/controller/trisolaris/server/http"
+	"github.com/deepflowio/deepflow/server/controller/trisolaris/server/http/common"
+)
+
+var log = logging.MustGetLogger("trisolaris/policy")
+
+func init() {
+	http.Register(NewPolicyService())
+}
+
+type PolicyService struct{}
+
+func NewPolicyService() *PolicyService {
+	return &PolicyService{}
+}
+
+// policyNames 用于将下发给采集器的策略 ID 转换为名称
+type policyNames struct {
+	aclIDToName          map[int]string
+	npbACLGroupIDToName  map[int]string
+	pcapACLGroupIDToName map[int]string
+}
+
+func getPolicyNames() (*policyNames, error) {
+	db := trisolaris.GetDB()
+	acls, err := dbmgr.DBMgr[models.ACL](db).GetFields([]string{"id", "name"})
+	if err != nil {
+		return nil, err
+	}
+	npbPolicies, err := dbmgr.DBMgr[models.NpbPolicy](db).GetFields([]string{"name", "policy_acl_group_id"})
+	if err != nil {
+		return nil, err
+	}
+	pcapPolicies, err := dbmgr.DBMgr[models.PcapPolicy](db).GetFields([]string{"name", "policy_acl_group_id"})
+	if err != nil {
+		return nil, err
+	}
+
+	names := &policyNames{
+		aclIDToName:          make(map[int]string, len(acls)),
+		npbACLGroupIDToName:  make(map[int]string, len(npbPolicies)),
+		pcapACLGroupIDToName: make(map[int]string, len(pcapPolicies)),
+	}
+	for _, acl := range acls {
+		names.aclIDToName[acl.ID] = acl.Name
+	}
+	for _, npbPolicy := range npbPolicies {
+		names.npbACLGroupIDToName[npbPolicy.PolicyACLGroupID] = npbPolicy.Name
+	}
+	for _, pcapPolicy := range pcapPolicies {
+		names.pcapACLGroupIDToName[pcapPolicy.PolicyACLGroupID] = pcapPolicy.Name
+	}
+	return names, nil
+}
+
+func int32sToInts(int32s []int32) []int {
+	ints := make([]int, 0, len(int32s))
+	for _, i := range int32s {
+		ints = append(ints, int(i))
+	}
+	return ints
+}
+
+func convertFlowACLs(flowACLs []*trident.FlowAcl, names *policyNames) []model.EffectiveFlowACL {
+	result := make([]model.EffectiveFlowACL, 0, len(flowACLs))
+	for _, flowACL := range flowACLs {
+		effectiveFlowACL := model.EffectiveFlowACL{
+			ACLID:       int(flowACL.GetId()),
+			ACLName:     names.aclIDToName[int(flowACL.GetId())],
+			TapType:     int(flowACL.GetTapType()),
+			Protocol:    int(flowACL.GetProtocol()),
+			SrcGroupIDs: int32sToInts(flowACL.GetSrcGroupIds()),
+			DstGroupIDs: int32sToInts(flowACL.GetDstGroupIds()),
+			SrcPorts:    flowACL.GetSrcPorts(),
+			DstPorts:    flowACL.GetDstPorts(),
+			Vlan:        int(flowACL.GetVlan()),
+			NpbActions:  make([]model.EffectiveNpbAction, 0, len(flowACL.GetNpbActions())),
+		}
+		for _, npbAction := range flowACL.GetNpbActions() {
+			policyName := names.npbACLGroupIDToName[int(npbAction.GetNpbAclGroupId())]
+			if npbAction.GetTunnelType() == trident.TunnelType_PCAP {
+				policyName = names.pcapACLGroupIDToName[int(npbAction.GetNpbAclGroupId())]
+			}
+			effectiveFlowACL.NpbActions = append(effectiveFlowACL.NpbActions, model.EffectiveNpbAction{
+				PolicyName:   policyName,
+				TunnelType:   npbAction.GetTunnelType().String(),
+				TunnelIP:     npbAction.GetTunnelIp(),
+				TunnelID:     int(npbAction.GetTunnelId()),
+				TapSide:      npbAction.GetTapSide().String(),
+				Direction:    npbAction.GetDirection().String(),
+				PayloadSlice: int(npbAction.GetPayloadSlice()),
+			})
+		}
+		result = append(result, effectiveFlowACL)
+	}
+	return result
+}
+
+// GetEffectivePolicies 返回当前控制器编译后下发给采集器的流量策略
+func GetEffectivePolicies(c *gin.Context) {
+	lcuuid := c.Param("lcuuid")
+	if lcuuid == "" {
+		common.Response(c, nil, common.NewReponse("FAILED", "", nil, "not find lcuuid param"))
+		return
+	}
+	vtap, err := dbmgr.DBMgr[models.VTap](trisolaris.GetDB()).GetFromLcuuid(lcuuid)
+	if err != nil {
+		log.Errorf("vtap(%s) not found, err: %s", lcuuid, err)
+		common.Response(c, nil, common.NewReponse("FAILED", "", nil, fmt.Sprintf("vtap(%s) not found", lcuuid)))
+		return
+	}
+	names, err := getPolicyNames()
+	if err != nil {
+		log.Error(err)
+		common.Response(c, nil, common.NewReponse("FAILED", "", nil, fmt.Sprintf("%s", err)))
+		return
+	}
+
+	version, flowACLs := trisolaris.GetMetaData().GetVTapFlowACLs(vtap.ID)
+	data := model.EffectivePolicy{
+		VTapID:   vtap.ID,
+		VTapName: vtap.Name,
+		Version:  version,
+		FlowACLs: convertFlowACLs(flowACLs, names),
+	}
+	common.Response(c, nil, common.NewReponse("SUCCESS", "", data, ""))
+}
+
+func (*PolicyService) Register(mux *gin.Engine) {
+	mux.GET("v1/effective-policies/vtap/:lcuuid/", GetEffectivePolicies)
+}
@@ -687,6 +687,10 @@ func (v *VTapInfo) updateVTapIPs(data []*trident.VtapIp) {
 
 func (v *VTapInfo) generateVTapIP() {
 	vTapIPs := make([]*trident.VtapIp, 0, v.vTapCaches.GetCount())
+	vtapGroupLcuuidToShortID := make(map[string]string, len(v.vtapGroupShortIDToLcuuid))
+	for shortID, lcuuid := range v.vtapGroupShortIDToLcuuid {
+		vtapGroupLcuuidToShortID[lcuuid] = shortID
+	}
 	cacheKeys := v.vTapCaches.List()
 	for _, cacheKey := range cacheKeys {
 		cacheVTap := v.GetVTapCache(cacheKey)
@@ -699,6 +703,8 @@ func (v *VTapInfo) generateVTapIP() {
 			EpcId:        proto.Uint32(uint32(cacheVTap.GetVPCID())),
 			Ip:           proto.String(cacheVTap.GetLaunchServer()),
 			PodClusterId: proto.Uint32(uint32(cacheVTap.GetPodClusterID())),
+			OrgId:        proto.Uint32(uint32(cacheVTap.GetOrgID())),
+			VtapGroupId:  proto.String(vtapGroupLcuuidToShortID[cacheVTap.GetVTapGroupLcuuid()]),
 		}
 		vTapIPs = append(vTapIPs, data)
 	}
@@ -200,6 +200,8 @@ type VTapCache struct {
 	tsdbSyncFlag       atomicbool.Bool // bool
 	// ID of the container cluster where the container type vtap resides
 	podClusterID int
+	// ID of the organization the vtap belongs to
+	orgID int
 	// vtap vtap id
 	VPCID int
 	// vtap platform data
@@ -243,6 +245,7 @@ func NewVTapCache(vtap *models.VTap) *VTapCache {
 	vTapCache.licenseType = vtap.LicenseType
 	vTapCache.tapMode = vtap.TapMode
 	vTapCache.lcuuid = proto.String(vtap.Lcuuid)
+	vTapCache.orgID = vtap.OrgID
 	vTapCache.licenseFunctions = proto.String(vtap.LicenseFunctions)
 	vTapCache.licenseFunctionSet = mapset.NewSet()
 	vTapCache.enabledTrafficDistribution = atomicbool.NewBool(false)
@@ -576,6 +579,10 @@ func (c *VTapCache) GetPodClusterID() int {
 	return c.podClusterID
 }
 
+func (c *VTapCache) GetOrgID() int {
+	return c.orgID
+}
+
 func (c *VTapCache) GetVPCID() int {
 	return c.VPCID
 }
@@ -1003,6 +1010,7 @@ func (c *VTapCache) updateVTapCacheFromDB(vtap *models.VTap, v *VTapInfo) {
 	c.updateCtrlMacFromDB(vtap.CtrlMac)
 	c.state = vtap.State
 	c.enable = vtap.Enable
+	c.orgID = vtap.OrgID
 	if v.config.BillingMethod == BILLING_METHOD_LICENSE {
 		c.updateLicenseFunctions(vtap.LicenseFunctions)
 	}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	. "github.com/deepflowio/deepflow/server/controller/common"
 	models "github.com/deepflowio/deepflow/server/controller/db/mysql"
@@ -122,12 +123,37 @@ func (r *VTapRegister) getVTapGroupLcuuid(db *gorm.DB) string {
 	return r.defaultVTapGroup
 }
 
+// bindOrg 采集器注册时归属到采集器组所在的组织，并校验组织的采集器配额，
+// 需在插入采集器的事务中调用，组织记录加锁避免并发注册超过配额
+func (r *VTapRegister) bindOrg(dbVTap *models.VTap, db *gorm.DB) bool {
+	dbVTap.OrgID = DEFAULT_ORG_ID
+	vtapGroup := &models.VTapGroup{}
+	if ret := db.Where("lcuuid = ?", dbVTap.VtapGroupLcuuid).First(vtapGroup); ret.Error == nil {
+		dbVTap.OrgID = vtapGroup.OrgID
+	}
+	org := &models.Org{}
+	if ret := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", dbVTap.OrgID).First(org); ret.Error != nil {
+		log.Errorf("agent(%s) org(id=%d) not found", r, dbVTap.OrgID)
+		return false
+	}
+	if org.MaxVTapNum <= 0 {
+		return true
+	}
+	var count int64
+	db.Model(&models.VTap{}).Where("org_id = ?", org.ID).Count(&count)
+	if int(count) >= org.MaxVTapNum {
+		log.Errorf("failed to register agent(%s), vtap count exceeds org(%s) quota (limit %d)", r, org.Name, org.MaxVTapNum)
+		return false
+	}
+	return true
+}
+
 func finishLog(dbVTap *models.VTap) {
 	log.Infof(
 		"finish register vtap (type: %d tap_mode:%d, name:%s ctrl_ip: %s ctrl_mac: %s "+
-			"launch_server: %s launch_server_id: %d vtap_group_lcuuid: %s az: %s lcuuid: %s)",
+			"launch_server: %s launch_server_id: %d vtap_group_lcuuid: %s org_id: %d az: %s lcuuid: %s)",
 		dbVTap.Type, dbVTap.TapMode, dbVTap.Name, dbVTap.CtrlIP, dbVTap.CtrlMac, dbVTap.LaunchServer,
-		dbVTap.LaunchServerID, dbVTap.VtapGroupLcuuid, dbVTap.AZ, dbVTap.Lcuuid)
+		dbVTap.LaunchServerID, dbVTap.VtapGroupLcuuid, dbVTap.OrgID, dbVTap.AZ, dbVTap.Lcuuid)
 }
 
 // 采集器名称不支持空格和:
@@ -164,6 +190,9 @@ func (r *VTapRegister) insertToDB(dbVTap *models.VTap, db *gorm.DB) bool {
 		dbVTap.State = VTAP_STATE_NORMAL
 	}
 	err = db.Transaction(func(tx *gorm.DB) error {
+		if !r.bindOrg(dbVTap, tx) {
+			return fmt.Errorf("agent(%s) bind org(id=%d) failed", r, dbVTap.OrgID)
+		}
 		if err := tx.Create(dbVTap).Error; err != nil {
 			log.Errorf("insert agent(%s) to DB faild, err: %s", r, err)
 			return err
@@ -1037,6 +1037,16 @@ var ColumnAdd65 = []*ColumnAdds{
 	},
 }
 
+var ColumnAdd651 = []*ColumnAdds{
+	&ColumnAdds{
+		Dbs:          []string{"flow_log"},
+		Tables:       []string{"l7_flow_log", "l7_flow_log_local"},
+		ColumnNames:  []string{"sampling_rate"},
+		ColumnType:   ckdb.Float64,
+		DefaultValue: "1",
+	},
+}
+
 func getTables(connect *sql.DB, db, tableName string) ([]string, error) {
 	sql := fmt.Sprintf("SHOW TABLES IN %s", db)
 	rows, err := connect.Query(sql)
@@ -1417,7 +1427,7 @@ func NewCKIssu(cfg *config.Config) (*Issu, error) {
 		datasourceInfo: make(map[string]*DatasourceInfo),
 	}
 
-	allVersionAdds := [][]*ColumnAdds{ColumnAdd610, ColumnAdd611, ColumnAdd612, ColumnAdd613, ColumnAdd615, ColumnAdd618, ColumnAdd620, ColumnAdd623, ColumnAdd625, ColumnAdd626, ColumnAdd633, ColumnAdd635, ColumnAdd64, ColumnAdd65}
+	allVersionAdds := [][]*ColumnAdds{ColumnAdd610, ColumnAdd611, ColumnAdd612, ColumnAdd613, ColumnAdd615, ColumnAdd618, ColumnAdd620, ColumnAdd623, ColumnAdd625, ColumnAdd626, ColumnAdd633, ColumnAdd635, ColumnAdd64, ColumnAdd65, ColumnAdd651}
 	i.columnAdds = []*ColumnAdd{}
 	for _, versionAdd := range allVersionAdds {
 		for _, adds := range versionAdd {
@@ -19,6 +19,7 @@ package ckmonitor
 import (
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	logging "github.com/op/go-logging"
@@ -34,6 +35,7 @@ var log = logging.MustGetLogger("monitor")
 type Monitor struct {
 	cfg           *config.Config
 	checkInterval int
+	counter       atomic.Value // Counter, 由 stats 模块在其他 goroutine 中读取
 
 	Conns              common.DBs
 	Addrs              []string
@@ -41,6 +43,12 @@ type Monitor struct {
 	exit               bool
 }
 
+// Counter 记录最近一次检查时 clickhouse 数据盘的最大使用率，控制器据此评估数据节点写入的 clickhouse 分片负载
+type Counter struct {
+	DiskUsedPercent float64 `statsd:"disk_used_percent,gauge"`
+	DiskFreeSpace   uint64  `statsd:"disk_free_space,gauge"` // bytes
+}
+
 type DiskInfo struct {
 	name, path                                      string
 	freeSpace, totalSpace, keepFreeSpace, usedSpace uint64
@@ -65,10 +73,20 @@ func NewCKMonitor(cfg *config.Config) (*Monitor, error) {
 	if err != nil {
 		return nil, err
 	}
+	m.counter.Store(Counter{})
+	common.RegisterCountableForIngester("ckmonitor", m)
 
 	return m, nil
 }
 
+func (m *Monitor) GetCounter() interface{} {
+	return m.counter.Load().(Counter)
+}
+
+func (m *Monitor) Closed() bool {
+	return m.exit
+}
+
 // 如果clickhouse重启等，需要自动更新连接
 func (m *Monitor) updateConnections() {
 	if len(m.Addrs) == 0 {
@@ -259,6 +277,7 @@ func (m *Monitor) start() {
 		}
 
 		m.updateConnections()
+		counter := Counter{}
 		for _, connect := range m.Conns {
 			if connect == nil {
 				continue
@@ -269,6 +288,13 @@ func (m *Monitor) start() {
 				continue
 			}
 			for _, diskInfo := range diskInfos {
+				if diskInfo.totalSpace > 0 {
+					usedPercent := float64(diskInfo.usedSpace) * 100 / float64(diskInfo.totalSpace)
+					if usedPercent > counter.DiskUsedPercent {
+						counter.DiskUsedPercent = usedPercent
+						counter.DiskFreeSpace = diskInfo.freeSpace
+					}
+				}
 				if m.isDisksNeedClean(diskInfo) {
 					if err := m.dropMinPartitions(connect, diskInfo); err != nil {
 						log.Warning("drop partition failed.", err)
@@ -276,6 +302,7 @@ func (m *Monitor) start() {
 				}
 			}
 		}
+		m.counter.Store(counter)
 	}
 }
 
@@ -29,6 +29,8 @@ import (
 	yaml "gopkg.in/yaml.v2"
 
 	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/quota"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/relabel"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 )
 
@@ -60,6 +62,9 @@ const (
 	FormatDecimal                   = "decimal"
 	EnvRunningMode                  = "DEEPFLOW_SERVER_RUNNING_MODE"
 	RunningModeStandalone           = "STANDALONE"
+	AgentAuthNone                   = "none"
+	AgentAuthCert                   = "cert"
+	AgentAuthToken                  = "token"
 )
 
 type DatabaseTable struct {
@@ -107,6 +112,55 @@ type Auth struct {
 	Password string `yaml:"password"`
 }
 
+// ReceiverTLS 采集器数据端口（TCP）的加密及采集器身份认证
+type ReceiverTLS struct {
+	Enabled      bool   `yaml:"enabled"`
+	CertFile     string `yaml:"cert-file"`
+	KeyFile      string `yaml:"key-file"`
+	AgentAuth    string `yaml:"agent-auth"`     // none, cert or token
+	ClientCAFile string `yaml:"client-ca-file"` // for cert agent auth
+	TokenSecret  string `yaml:"token-secret"`   // for token agent auth
+}
+
+// MarshalYAML 打印配置时隐藏令牌密钥
+func (t ReceiverTLS) MarshalYAML() (interface{}, error) {
+	type plain ReceiverTLS
+	p := plain(t)
+	if p.TokenSecret != "" {
+		p.TokenSecret = "******"
+	}
+	return p, nil
+}
+
+func (t *ReceiverTLS) Validate() error {
+	if t.AgentAuth == "" {
+		t.AgentAuth = AgentAuthNone
+	}
+	if !t.Enabled {
+		if t.AgentAuth != AgentAuthNone {
+			return fmt.Errorf("'agent-auth'(%s) of 'receiver-tls' requires 'enabled' to be true", t.AgentAuth)
+		}
+		return nil
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		return errors.New("'cert-file' and 'key-file' of 'receiver-tls' are required")
+	}
+	switch t.AgentAuth {
+	case AgentAuthNone:
+	case AgentAuthCert:
+		if t.ClientCAFile == "" {
+			return errors.New("'client-ca-file' of 'receiver-tls' is required by cert agent auth")
+		}
+	case AgentAuthToken:
+		if t.TokenSecret == "" {
+			return errors.New("'token-secret' of 'receiver-tls' is required by token agent auth")
+		}
+	default:
+		return fmt.Errorf("invalid 'agent-auth'(%s) of 'receiver-tls', must be '%s', '%s' or '%s'", t.AgentAuth, AgentAuthNone, AgentAuthCert, AgentAuthToken)
+	}
+	return nil
+}
+
 type CKWriterConfig struct {
 	QueueCount   int `yaml:"queue-count"`
 	QueueSize    int `yaml:"queue-size"`
@@ -138,15 +192,18 @@ type Config struct {
 	UDPReadBuffer            int             `yaml:"udp-read-buffer"`
 	TCPReadBuffer            int             `yaml:"tcp-read-buffer"`
 	TCPReaderBuffer          int             `yaml:"tcp-reader-buffer"`
+	ReceiverTLS              ReceiverTLS     `yaml:"receiver-tls"`
 	CKDiskMonitor            CKDiskMonitor   `yaml:"ck-disk-monitor"`
 	ColdStorage              CKDBColdStorage `yaml:"ckdb-cold-storage"`
 	ckdbColdStorages         map[string]*ckdb.ColdStorage
-	NodeIP                   string `yaml:"node-ip"`
-	GrpcBufferSize           int    `yaml:"grpc-buffer-size"`
-	ServiceLabelerLruCap     int    `yaml:"service-labeler-lru-cap"`
-	StatsInterval            int    `yaml:"stats-interval"`
-	FlowTagCacheFlushTimeout uint32 `yaml:"flow-tag-cache-flush-timeout"`
-	FlowTagCacheMaxSize      uint32 `yaml:"flow-tag-cache-max-size"`
+	NodeIP                   string               `yaml:"node-ip"`
+	GrpcBufferSize           int                  `yaml:"grpc-buffer-size"`
+	ServiceLabelerLruCap     int                  `yaml:"service-labeler-lru-cap"`
+	StatsInterval            int                  `yaml:"stats-interval"`
+	FlowTagCacheFlushTimeout uint32               `yaml:"flow-tag-cache-flush-timeout"`
+	FlowTagCacheMaxSize      uint32               `yaml:"flow-tag-cache-max-size"`
+	MetricRelabelRules       []relabel.RuleConfig `yaml:"metric-relabel-rules"`
+	IngestionQuota           quota.Config         `yaml:"ingestion-quota"`
 	LogFile                  string
 	LogLevel                 string
 	MyNodeName               string
@@ -219,6 +276,16 @@ func (c *Config) Validate() error {
 	if c.FlowTagCacheMaxSize == 0 {
 		c.FlowTagCacheMaxSize = DefaultFlowTagCacheMaxSize
 	}
+
+	if err := relabel.ValidateRules(c.MetricRelabelRules); err != nil {
+		return err
+	}
+	if err := c.ReceiverTLS.Validate(); err != nil {
+		return err
+	}
+	if err := c.IngestionQuota.Validate(); err != nil {
+		return err
+	}
 	if c.FlowTagCacheFlushTimeout == 0 {
 		c.FlowTagCacheFlushTimeout = DefaultFlowTagCacheFlushTimeout
 	}
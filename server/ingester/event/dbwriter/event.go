@@ -86,6 +86,12 @@ type EventStore struct {
 	HasMetrics bool
 	Bytes      uint32
 	Duration   uint64
+
+	OrgId uint16 // no need to store, used to select the org database
+}
+
+func (e *EventStore) GetOrgId() uint16 {
+	return e.OrgId
 }
 
 func (e *EventStore) WriteBlock(block *ckdb.Block) {
@@ -142,17 +142,19 @@ func (d *Decoder) Run() {
 				d.handlePerfEvent(recvBytes.VtapID, decoder)
 				receiver.ReleaseRecvBuffer(recvBytes)
 			case common.ALARM_EVENT:
-				if buffer[i] == nil {
-					continue
-				}
-				recvBytes, ok := buffer[i].(*receiver.RecvBuffer)
-				if !ok {
+				// alarm events come from receiver, or from controller alert evaluator in the same process
+				switch event := buffer[i].(type) {
+				case *receiver.RecvBuffer:
+					decoder.Init(event.Buffer[event.Begin:event.End])
+					d.handleAlarmEvent(decoder)
+					receiver.ReleaseRecvBuffer(event)
+				case *eventapi.AlarmEvent:
+					d.counter.OutCount++
+					d.writeAPIAlarmEvent(event)
+					event.Release()
+				default:
 					log.Warning("get alarm event decode queue data type wrong")
-					continue
 				}
-				decoder.Init(recvBytes.Buffer[recvBytes.Begin:recvBytes.End])
-				d.handleAlarmEvent(decoder)
-				receiver.ReleaseRecvBuffer(recvBytes)
 			}
 		}
 	}
@@ -185,6 +187,7 @@ func (d *Decoder) WritePerfEvent(vtapId uint16, e *pb.ProcEvent) {
 	}
 	s.VTAPID = vtapId
 	s.L3EpcID = d.platformData.QueryVtapEpc0(uint32(vtapId))
+	s.OrgId = d.platformData.QueryVtapOrgId(uint32(vtapId))
 
 	var info *grpc.Info
 	if e.PodId != 0 {
@@ -449,3 +452,37 @@ func (d *Decoder) writeAlarmEvent(event *alarm_event.AlarmEvent) {
 
 	d.eventWriter.WriteAlarmEvent(s)
 }
+
+func (d *Decoder) writeAPIAlarmEvent(event *eventapi.AlarmEvent) {
+	s := dbwriter.AcquireAlarmEventStore()
+	s.Time = event.Time
+	s.Lcuuid = event.Lcuuid
+	s.User = event.User
+	s.UserId = event.UserId
+
+	s.PolicyId = event.PolicyId
+	s.PolicyName = event.PolicyName
+	s.PolicyLevel = event.PolicyLevel
+	s.PolicyAppType = event.PolicyAppType
+	s.PolicySubType = event.PolicySubType
+	s.PolicyContrastType = event.PolicyContrastType
+	s.PolicyDataLevel = event.PolicyDataLevel
+	s.PolicyTargetUid = event.PolicyTargetUid
+	s.PolicyTargetName = event.PolicyTargetName
+	s.PolicyGoTo = event.PolicyGoTo
+	s.PolicyTargetField = event.PolicyTargetField
+	s.PolicyEndpoints = event.PolicyEndpoints
+	s.TriggerCondition = event.TriggerCondition
+	s.TriggerValue = event.TriggerValue
+	s.ValueUnit = event.ValueUnit
+	s.EventLevel = event.EventLevel
+	s.AlarmTarget = event.AlarmTarget
+	s.RegionId = uint16(d.platformData.QueryRegionID())
+	s.PolicyQueryUrl = event.PolicyQueryUrl
+	s.PolicyQueryConditions = event.PolicyQueryConditions
+	s.PolicyThresholdCritical = event.PolicyThresholdCritical
+	s.PolicyThresholdError = event.PolicyThresholdError
+	s.PolicyThresholdWarning = event.PolicyThresholdWarning
+
+	d.eventWriter.WriteAlarmEvent(s)
+}
@@ -49,7 +49,7 @@ type Eventor struct {
 	PlatformDatas []*grpc.PlatformInfoTable
 }
 
-func NewEvent(config *config.Config, resourceEventQueue *queue.OverwriteQueue, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager) (*Event, error) {
+func NewEvent(config *config.Config, resourceEventQueue, alarmEventQueue *queue.OverwriteQueue, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager) (*Event, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_EVENT_QUEUE)
 	resourceEventor, err := NewResouceEventor(resourceEventQueue, common.RESOURCE_EVENT, config)
 	if err != nil {
@@ -61,7 +61,10 @@ func NewEvent(config *config.Config, resourceEventQueue *queue.OverwriteQueue, r
 		return nil, err
 	}
 
-	alarmEventor, err := NewAlarmEventor(config, recv, manager, platformDataManager.GetMasterPlatformInfoTable())
+	alarmEventor, err := NewAlarmEventor(config, alarmEventQueue, recv, manager, platformDataManager.GetMasterPlatformInfoTable())
+	if err != nil {
+		return nil, err
+	}
 
 	return &Event{
 		Config:          config,
@@ -89,7 +92,7 @@ func NewResouceEventor(eventQueue *queue.OverwriteQueue, eventType common.EventT
 	}, nil
 }
 
-func NewAlarmEventor(config *config.Config, recv *receiver.Receiver, manager *dropletqueue.Manager, platformTable *grpc.PlatformInfoTable) (*Eventor, error) {
+func NewAlarmEventor(config *config.Config, alarmEventQueue *queue.OverwriteQueue, recv *receiver.Receiver, manager *dropletqueue.Manager, platformTable *grpc.PlatformInfoTable) (*Eventor, error) {
 	eventMsg := datatype.MESSAGE_TYPE_ALARM_EVENT
 	decodeQueues := manager.NewQueues(
 		"1-receive-to-decode-"+eventMsg.String(),
@@ -111,9 +114,17 @@ func NewAlarmEventor(config *config.Config, recv *receiver.Receiver, manager *dr
 		platformTable,
 		config,
 	)
+	// alarm events generated by controller share the writer with alarm events from receiver
+	apiDecoder := decoder.NewDecoder(
+		common.ALARM_EVENT,
+		queue.QueueReader(alarmEventQueue),
+		eventWriter,
+		platformTable,
+		config,
+	)
 	return &Eventor{
 		Config:   config,
-		Decoders: []*decoder.Decoder{d},
+		Decoders: []*decoder.Decoder{d, apiDecoder},
 	}, nil
 }
 
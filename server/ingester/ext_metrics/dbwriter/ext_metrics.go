@@ -43,6 +43,12 @@ type ExtMetrics struct {
 
 	MetricsFloatNames  []string
 	MetricsFloatValues []float64
+
+	OrgId uint16 // no need to store, used to select the org database
+}
+
+func (m *ExtMetrics) GetOrgId() uint16 {
+	return m.OrgId
 }
 
 func (m *ExtMetrics) DatabaseName() string {
@@ -217,6 +223,7 @@ var emptyUniversalTag = zerodoc.UniversalTag{}
 
 func ReleaseExtMetrics(m *ExtMetrics) {
 	m.UniversalTag = emptyUniversalTag
+	m.OrgId = 0
 	m.TagNames = m.TagNames[:0]
 	m.TagValues = m.TagValues[:0]
 	m.MetricsFloatNames = m.MetricsFloatNames[:0]
@@ -19,15 +19,19 @@ package decoder
 import (
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/influxdata/influxdb/models"
 	logging "github.com/op/go-logging"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/deepflowio/deepflow/server/ingester/common"
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/config"
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/dbwriter"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/relabel"
 	"github.com/deepflowio/deepflow/server/libs/codec"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
 	"github.com/deepflowio/deepflow/server/libs/grpc"
@@ -53,6 +57,7 @@ type Counter struct {
 	ErrorCount             int64 `statsd:"err-count"`
 	ErrMetrics             int64 `statsd:"err-metrics"`
 	DropUnsupportedMetrics int64 `statsd:"drop-unsupported-metrics"`
+	RelabelDrop            int64 `statsd:"relabel-drop"`
 }
 
 type Decoder struct {
@@ -63,6 +68,8 @@ type Decoder struct {
 	extMetricsWriter *dbwriter.ExtMetricsWriter
 	debugEnabled     bool
 	config           *config.Config
+	relabeler        *relabel.Relabeler
+	labelsBuffer     labels.Labels
 
 	// universal tag cache
 	podNameToUniversalTag    map[string]*zerodoc.UniversalTag
@@ -81,6 +88,10 @@ func NewDecoder(
 	extMetricsWriter *dbwriter.ExtMetricsWriter,
 	config *config.Config,
 ) *Decoder {
+	var relabeler *relabel.Relabeler
+	if msgType == datatype.MESSAGE_TYPE_TELEGRAF {
+		relabeler = relabel.NewRelabeler(relabel.DATA_SOURCE_TELEGRAF, index, config.Base.MetricRelabelRules)
+	}
 	return &Decoder{
 		index:                    index,
 		msgType:                  msgType,
@@ -89,6 +100,7 @@ func NewDecoder(
 		debugEnabled:             log.IsEnabledFor(logging.DEBUG),
 		extMetricsWriter:         extMetricsWriter,
 		config:                   config,
+		relabeler:                relabeler,
 		podNameToUniversalTag:    make(map[string]*zerodoc.UniversalTag),
 		instanceIPToUniversalTag: make(map[string]*zerodoc.UniversalTag),
 		vtapIDToUniversalTag:     make(map[uint16]*zerodoc.UniversalTag),
@@ -124,11 +136,13 @@ func (d *Decoder) Run() {
 				continue
 			}
 			decoder.Init(recvBytes.Buffer[recvBytes.Begin:recvBytes.End])
+			counter, outCount := d.counter, d.counter.OutCount
 			if d.msgType == datatype.MESSAGE_TYPE_TELEGRAF {
 				d.handleTelegraf(recvBytes.VtapID, decoder)
 			} else if d.msgType == datatype.MESSAGE_TYPE_DFSTATS {
 				d.handleDeepflowStats(recvBytes.VtapID, decoder)
 			}
+			receiver.ConsumeRows(d.msgType, recvBytes.VtapID, counter.OutCount-outCount)
 			receiver.ReleaseRecvBuffer(recvBytes)
 		}
 	}
@@ -178,6 +192,10 @@ func (d *Decoder) sendTelegraf(vtapID uint16, point models.Point) {
 	if d.debugEnabled {
 		log.Debugf("decoder %d vtap %d recv telegraf point: %v", d.index, vtapID, point)
 	}
+	if d.relabeler != nil && !d.relabelPoint(vtapID, point) {
+		d.counter.RelabelDrop++
+		return
+	}
 	extMetrics, err := d.PointToExtMetrics(vtapID, point)
 	if err != nil {
 		if d.counter.ErrMetrics == 0 {
@@ -190,6 +208,46 @@ func (d *Decoder) sendTelegraf(vtapID uint16, point models.Point) {
 	d.counter.OutCount++
 }
 
+// relabelPoint executes the metric relabel rules on the measurement (as __name__) and tags of the point,
+// returns false if the point is dropped
+func (d *Decoder) relabelPoint(vtapID uint16, point models.Point) bool {
+	agentGroupID := ""
+	if vtapInfo := d.platformData.QueryVtapInfo(uint32(vtapID)); vtapInfo != nil {
+		agentGroupID = vtapInfo.VtapGroupId
+	}
+	if !d.relabeler.Matched(agentGroupID) {
+		return true
+	}
+
+	lset := append(d.labelsBuffer[:0], labels.Label{Name: model.MetricNameLabel, Value: string(point.Name())})
+	for _, tag := range point.Tags() {
+		lset = append(lset, labels.Label{Name: string(tag.Key), Value: string(tag.Value)})
+	}
+	sort.Sort(lset)
+	d.labelsBuffer = lset
+
+	result := d.relabeler.Process(agentGroupID, lset)
+	if result == nil {
+		return false
+	}
+	if labels.Equal(lset, result) {
+		return true
+	}
+	tags := make(models.Tags, 0, len(result))
+	for _, l := range result {
+		if l.Name == model.MetricNameLabel {
+			// 不允许删除 measurement
+			if l.Value != "" {
+				point.SetName(l.Value)
+			}
+			continue
+		}
+		tags = append(tags, models.NewTag([]byte(l.Name), []byte(l.Value)))
+	}
+	point.SetTags(tags)
+	return true
+}
+
 func (d *Decoder) handleDeepflowStats(vtapID uint16, decoder *codec.SimpleDecoder) {
 	for !decoder.IsEnd() {
 		pbStats := &pb.Stats{}
@@ -212,7 +270,9 @@ func (d *Decoder) handleDeepflowStats(vtapID uint16, decoder *codec.SimpleDecode
 		if d.debugEnabled {
 			log.Debugf("decoder %d vtap %d recv deepflow stats: %v", d.index, vtapID, pbStats)
 		}
-		d.extMetricsWriter.Write(StatsToExtMetrics(vtapID, pbStats))
+		m := StatsToExtMetrics(vtapID, pbStats)
+		m.OrgId = d.platformData.QueryVtapOrgId(uint32(vtapID))
+		d.extMetricsWriter.Write(m)
 		d.counter.OutCount++
 	}
 }
@@ -352,6 +412,7 @@ func (d *Decoder) PointToExtMetrics(vtapID uint16, point models.Point) (*dbwrite
 		}
 	}
 	d.fillExtMetricsBase(m, vtapID, podName, true)
+	m.OrgId = d.platformData.QueryVtapOrgId(uint32(vtapID))
 
 	iter := point.FieldIterator()
 	for iter.Next() {
@@ -36,6 +36,11 @@ const (
 	DefaultDecoderQueueSize  = 1 << 14
 	DefaultBrokerQueueSize   = 1 << 14
 	DefaultFlowLogTTL        = 72 // hour
+
+	DefaultTailSamplingSlowThreshold    = 1000000 // us
+	DefaultTailSamplingOutlierFactor    = 5
+	DefaultTailSamplingTraceRatio       = 0.3
+	DefaultTailSamplingKeptTraceTimeout = 60 // second
 )
 
 type FlowLogTTL struct {
@@ -44,6 +49,17 @@ type FlowLogTTL struct {
 	L4Packet  int `yaml:"l4-packet"`
 }
 
+// L7TailSampling 控制 L7 日志超出 throttle 后的采样策略:
+// 异常、慢调用及其所属 trace 全量保留, 带 trace_id 的数据按 trace 一致采样, 其余按服务/协议/端点公平分配
+type L7TailSampling struct {
+	Enabled          bool    `yaml:"enabled"`
+	SlowThreshold    int     `yaml:"slow-threshold-us"`  // 时延超过此值一定保留
+	OutlierFactor    float64 `yaml:"outlier-factor"`     // 时延超过同 key 平均时延的倍数时保留
+	TraceRatio       float64 `yaml:"trace-ratio"`        // throttle 中分配给 trace 一致采样的比例
+	PriorityThrottle int     `yaml:"priority-throttle"`  // 每秒全量保留的数据条数上限, 为 0 时与 l7-throttle 相同
+	KeptTraceTimeout int     `yaml:"kept-trace-timeout"` // 包含异常的 trace 保留多久, 单位秒
+}
+
 type Config struct {
 	Base              *config.Config
 	CKWriterConfig    config.CKWriterConfig      `yaml:"flowlog-ck-writer"`
@@ -51,6 +67,7 @@ type Config struct {
 	ThrottleBucket    int                        `yaml:"throttle-bucket"`
 	L4Throttle        int                        `yaml:"l4-throttle"`
 	L7Throttle        int                        `yaml:"l7-throttle"`
+	L7TailSampling    L7TailSampling             `yaml:"l7-tail-sampling"`
 	FlowLogTTL        FlowLogTTL                 `yaml:"flow-log-ttl-hour"`
 	DecoderQueueCount int                        `yaml:"flow-log-decoder-queue-count"`
 	DecoderQueueSize  int                        `yaml:"flow-log-decoder-queue-size"`
@@ -114,6 +131,18 @@ func (c *Config) Validate() error {
 		c.FlowLogTTL.L4Packet = DefaultFlowLogTTL
 	}
 
+	if c.L7TailSampling.OutlierFactor <= 1 {
+		c.L7TailSampling.OutlierFactor = DefaultTailSamplingOutlierFactor
+	}
+
+	if c.L7TailSampling.TraceRatio < 0 || c.L7TailSampling.TraceRatio > 1 {
+		c.L7TailSampling.TraceRatio = DefaultTailSamplingTraceRatio
+	}
+
+	if c.L7TailSampling.KeptTraceTimeout <= 0 {
+		c.L7TailSampling.KeptTraceTimeout = DefaultTailSamplingKeptTraceTimeout
+	}
+
 	if c.ExportersCfg.Enabled {
 		if err := c.ExportersCfg.Validate(); err != nil {
 			return err
@@ -133,8 +162,15 @@ func Load(base *config.Config, path string) *Config {
 			DecoderQueueSize:  DefaultDecoderQueueSize,
 			CKWriterConfig:    config.CKWriterConfig{QueueCount: 1, QueueSize: 1000000, BatchSize: 512000, FlushTimeout: 10},
 			FlowLogTTL:        FlowLogTTL{DefaultFlowLogTTL, DefaultFlowLogTTL, DefaultFlowLogTTL},
-			ExportersCfg:      exporters_cfg.NewDefaultExportersCfg(),
-			OtlpDeprecated:    exporters_cfg.NewOtlpDefaultConfigDeprecated(),
+			L7TailSampling: L7TailSampling{
+				Enabled:          true,
+				SlowThreshold:    DefaultTailSamplingSlowThreshold,
+				OutlierFactor:    DefaultTailSamplingOutlierFactor,
+				TraceRatio:       DefaultTailSamplingTraceRatio,
+				KeptTraceTimeout: DefaultTailSamplingKeptTraceTimeout,
+			},
+			ExportersCfg:   exporters_cfg.NewDefaultExportersCfg(),
+			OtlpDeprecated: exporters_cfg.NewOtlpDefaultConfigDeprecated(),
 		},
 	}
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dbwriter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	querier "github.com/deepflowio/deepflow/server/querier/common"
+)
+
+// querier 替换的组织数据库需要与 ingester 实际写入的数据库一致
+func TestOrgDatabase(t *testing.T) {
+	const orgID = 2
+	for _, table := range GetFlowLogTables(ckdb.MergeTree, "", "", 1, 1, 1, nil) {
+		orgTable := ckdb.OrgTable(table, orgID)
+		sql := fmt.Sprintf("SELECT 1 FROM %s.`%s` WHERE a = '%s.%s'", table.Database, table.GlobalName, table.Database, table.GlobalName)
+		want := fmt.Sprintf("SELECT 1 FROM %s.`%s` WHERE a = '%s.%s'", orgTable.Database, table.GlobalName, table.Database, table.GlobalName)
+		if got := querier.OrgSql(sql, orgID); got != want {
+			t.Errorf("OrgSql() = %v, want %v", got, want)
+		}
+		sql = fmt.Sprintf("SELECT 1 FROM %s.`%s`", table.Database, table.GlobalName)
+		if got := querier.OrgSql(sql, ckdb.DEFAULT_ORG_ID); got != sql {
+			t.Errorf("OrgSql() = %v, want %v", got, sql)
+		}
+	}
+}
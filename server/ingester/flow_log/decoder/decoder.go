@@ -67,6 +67,8 @@ type Counter struct {
 	ErrorCount       int64 `statsd:"err-count"`
 	Count            int64 `statsd:"count"`
 	DropCount        int64 `statsd:"drop-count"`
+	// 已认证连接的数据中携带的采集器 ID 与认证的不一致，丢弃
+	VtapMismatchCount int64 `statsd:"vtap-mismatch-count"`
 
 	TotalTime int64 `statsd:"total-time"`
 	AvgTime   int64 `statsd:"avg-time"`
@@ -152,11 +154,12 @@ func (d *Decoder) Run() {
 				continue
 			}
 			decoder.Init(recvBytes.Buffer[recvBytes.Begin:recvBytes.End])
+			counter, count := d.counter, d.counter.Count
 			switch d.msgType {
 			case datatype.MESSAGE_TYPE_PROTOCOLLOG:
-				d.handleProtoLog(decoder)
+				d.handleProtoLog(recvBytes, decoder)
 			case datatype.MESSAGE_TYPE_TAGGEDFLOW:
-				d.handleTaggedFlow(decoder, pbTaggedFlow)
+				d.handleTaggedFlow(recvBytes, decoder, pbTaggedFlow)
 			case datatype.MESSAGE_TYPE_OPENTELEMETRY:
 				d.handleOpenTelemetry(recvBytes.VtapID, decoder, pbTracesData, false)
 			case datatype.MESSAGE_TYPE_OPENTELEMETRY_COMPRESSED:
@@ -167,13 +170,25 @@ func (d *Decoder) Run() {
 				log.Warningf("unknown msg type: %d", d.msgType)
 
 			}
+			receiver.ConsumeRows(d.msgType, recvBytes.VtapID, counter.Count-count)
 			receiver.ReleaseRecvBuffer(recvBytes)
 		}
 		d.counter.TotalTime += int64(time.Since(start))
 	}
 }
 
-func (d *Decoder) handleTaggedFlow(decoder *codec.SimpleDecoder, pbTaggedFlow *pb.TaggedFlow) {
+func (d *Decoder) vtapMismatch(recvBytes *receiver.RecvBuffer, vtapID uint32) bool {
+	if !recvBytes.Authenticated || vtapID == uint32(recvBytes.VtapID) {
+		return false
+	}
+	if d.counter.VtapMismatchCount == 0 {
+		log.Warningf("decoder %d recv data of vtap %d from connection authenticated as vtap %d, drop it", d.index, vtapID, recvBytes.VtapID)
+	}
+	d.counter.VtapMismatchCount++
+	return true
+}
+
+func (d *Decoder) handleTaggedFlow(recvBytes *receiver.RecvBuffer, decoder *codec.SimpleDecoder, pbTaggedFlow *pb.TaggedFlow) {
 	for !decoder.IsEnd() {
 		pbTaggedFlow.ResetAll()
 		decoder.ReadPB(pbTaggedFlow)
@@ -187,11 +202,14 @@ func (d *Decoder) handleTaggedFlow(decoder *codec.SimpleDecoder, pbTaggedFlow *p
 			log.Warningf("invalid flow %s", pbTaggedFlow.Flow)
 			continue
 		}
+		if d.vtapMismatch(recvBytes, pbTaggedFlow.Flow.FlowKey.VtapId) {
+			continue
+		}
 		d.sendFlow(pbTaggedFlow)
 	}
 }
 
-func (d *Decoder) handleProtoLog(decoder *codec.SimpleDecoder) {
+func (d *Decoder) handleProtoLog(recvBytes *receiver.RecvBuffer, decoder *codec.SimpleDecoder) {
 	for !decoder.IsEnd() {
 		protoLog := pb.AcquirePbAppProtoLogsData()
 
@@ -202,6 +220,10 @@ func (d *Decoder) handleProtoLog(decoder *codec.SimpleDecoder) {
 			log.Errorf("proto log decode failed, offset=%d len=%d", decoder.Offset(), len(decoder.Bytes()))
 			return
 		}
+		if d.vtapMismatch(recvBytes, protoLog.Base.VtapId) {
+			pb.ReleasePbAppProtoLogsData(protoLog)
+			continue
+		}
 		d.sendProto(protoLog)
 	}
 }
@@ -272,6 +294,8 @@ func (d *Decoder) handleL4Packet(vtapID uint16, decoder *codec.SimpleDecoder) {
 			return
 		}
 
+		l4Packet.OrgId = d.platformData.QueryVtapOrgId(uint32(vtapID))
+
 		if d.debugEnabled {
 			log.Debugf("decoder %d vtap %d recv l4 packet: %s", d.index, vtapID, l4Packet)
 		}
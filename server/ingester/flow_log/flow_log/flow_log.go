@@ -67,10 +67,16 @@ type Logger struct {
 func NewFlowLog(config *config.Config, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager) (*FlowLog, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_FLOW_LOG_QUEUE)
 
+	// L7 流日志和 OTel 数据共享同一个尾部采样器，保证同一 trace 的采样决策一致
+	var tailSampler *throttler.TailSampler
+	if config.L7TailSampling.Enabled {
+		tailSampler = throttler.NewTailSampler(config.L7TailSampling, config.ThrottleBucket)
+	}
+
 	if config.Base.StorageDisabled {
 		exporters := exporters.NewExporters(config)
 
-		l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, nil, exporters)
+		l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, nil, exporters, tailSampler)
 		if err != nil {
 			return nil, err
 		}
@@ -92,19 +98,19 @@ func NewFlowLog(config *config.Config, recv *receiver.Receiver, platformDataMana
 	l4FlowLogger := NewL4FlowLogger(config, platformDataManager, manager, recv, flowLogWriter)
 
 	exporters := exporters.NewExporters(config)
-	l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, flowLogWriter, exporters)
+	l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, flowLogWriter, exporters, tailSampler)
 	if err != nil {
 		return nil, err
 	}
-	otelLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, exporters)
+	otelLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, exporters, tailSampler)
 	if err != nil {
 		return nil, err
 	}
-	otelCompressedLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY_COMPRESSED, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, exporters)
+	otelCompressedLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY_COMPRESSED, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, exporters, tailSampler)
 	if err != nil {
 		return nil, err
 	}
-	l4PacketLogger, err := NewLogger(datatype.MESSAGE_TYPE_PACKETSEQUENCE, config, nil, manager, recv, flowLogWriter, common.L4_PACKET_ID, nil)
+	l4PacketLogger, err := NewLogger(datatype.MESSAGE_TYPE_PACKETSEQUENCE, config, nil, manager, recv, flowLogWriter, common.L4_PACKET_ID, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +125,7 @@ func NewFlowLog(config *config.Config, recv *receiver.Receiver, platformDataMana
 	}, nil
 }
 
-func NewLogger(msgType datatype.MessageType, config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, flowLogId common.FlowLogID, exporters *exporters.Exporters) (*Logger, error) {
+func NewLogger(msgType datatype.MessageType, config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, flowLogId common.FlowLogID, exporters *exporters.Exporters, tailSampler *throttler.TailSampler) (*Logger, error) {
 	queueCount := config.DecoderQueueCount
 	decodeQueues := manager.NewQueues(
 		"1-receive-to-decode-"+datatype.MessageTypeString[msgType],
@@ -145,6 +151,7 @@ func NewLogger(msgType datatype.MessageType, config *config.Config, platformData
 			flowLogWriter,
 			int(flowLogId),
 		)
+		throttlers[i].SetTailSampler(tailSampler, priorityThrottle(config, queueCount))
 		if platformDataManager != nil {
 			platformDatas[i], _ = platformDataManager.NewPlatformInfoTable("flow-log-" + datatype.MessageTypeString[msgType] + "-" + strconv.Itoa(i))
 			if i == 0 {
@@ -223,7 +230,7 @@ func NewL4FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDa
 	}
 }
 
-func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, exporters *exporters.Exporters) (*Logger, error) {
+func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, exporters *exporters.Exporters, tailSampler *throttler.TailSampler) (*Logger, error) {
 	queueSuffix := "-l7"
 	queueCount := config.DecoderQueueCount
 	msgType := datatype.MESSAGE_TYPE_PROTOCOLLOG
@@ -262,6 +269,7 @@ func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDa
 			flowLogWriter,
 			int(common.L7_FLOW_ID),
 		)
+		throttlers[i].SetTailSampler(tailSampler, priorityThrottle(config, queueCount))
 		platformDatas[i], _ = platformDataManager.NewPlatformInfoTable("l7-flow-log-" + strconv.Itoa(i))
 		if i == 0 {
 			debug.ServerRegisterSimple(ingesterctl.CMD_PLATFORMDATA_FLOW_LOG, platformDatas[i])
@@ -287,6 +295,16 @@ func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDa
 	return l, nil
 }
 
+func priorityThrottle(config *config.Config, queueCount int) int {
+	if config.L7TailSampling.PriorityThrottle != 0 {
+		return config.L7TailSampling.PriorityThrottle / queueCount
+	}
+	if config.L7Throttle != 0 {
+		return config.L7Throttle / queueCount
+	}
+	return config.Throttle / queueCount
+}
+
 func (l *Logger) HandleSimpleCommand(op uint16, arg string) string {
 	sb := &strings.Builder{}
 	sb.WriteString("last 10s counter:\n")
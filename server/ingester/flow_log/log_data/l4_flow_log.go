@@ -267,6 +267,12 @@ type KnowledgeGraph struct {
 
 	TagSource0 uint8
 	TagSource1 uint8
+
+	OrgId uint16 // no need to store, used to select the org database
+}
+
+func (k *KnowledgeGraph) GetOrgId() uint16 {
+	return k.OrgId
 }
 
 var KnowledgeGraphColumns = []*ckdb.Column{
@@ -710,6 +716,8 @@ func (k *KnowledgeGraph) fill(
 
 	var info0, info1 *grpc.Info
 
+	k.OrgId = platformData.QueryVtapOrgId(vtapId)
+
 	// 对于VIP的流量，需要使用MAC来匹配
 	lookupByMac0, lookupByMac1 := isVipInterface0, isVipInterface1
 	// 对于本地的流量，也需要使用MAC来匹配
@@ -34,6 +34,12 @@ type L4Packet struct {
 	VtapID      uint16
 	PacketCount uint32
 	PacketBatch []byte
+
+	OrgId uint16 // no need to store, used to select the org database
+}
+
+func (p *L4Packet) GetOrgId() uint16 {
+	return p.OrgId
 }
 
 func L4PacketColumns() []*ckdb.Column {
@@ -237,6 +237,9 @@ type L7FlowLog struct {
 
 	MetricsNames  []string
 	MetricsValues []float64
+
+	// 入库时的采样率, 查询时用 1/sampling_rate 还原真实数量
+	SamplingRate float64
 }
 
 func L7FlowLogColumns() []*ckdb.Column {
@@ -282,6 +285,7 @@ func L7FlowLogColumns() []*ckdb.Column {
 		ckdb.NewColumn("attribute_values", ckdb.ArrayString).SetComment("额外的属性对应的值"),
 		ckdb.NewColumn("metrics_names", ckdb.ArrayLowCardinalityString).SetComment("额外的指标"),
 		ckdb.NewColumn("metrics_values", ckdb.ArrayFloat64).SetComment("额外的指标对应的值"),
+		ckdb.NewColumn("sampling_rate", ckdb.Float64).SetIndex(ckdb.IndexNone).SetComment("采样率"),
 	)
 	return l7Columns
 }
@@ -327,7 +331,8 @@ func (h *L7FlowLog) WriteBlock(block *ckdb.Block) {
 		h.AttributeNames,
 		h.AttributeValues,
 		h.MetricsNames,
-		h.MetricsValues)
+		h.MetricsValues,
+		h.SamplingRate)
 
 }
 
@@ -498,6 +503,26 @@ func (h *L7FlowLog) Release() {
 	ReleaseL7FlowLog(h)
 }
 
+func (h *L7FlowLog) SamplingKey() (uint32, uint8, string) {
+	return h.AutoServiceID1, h.L7Protocol, h.Endpoint
+}
+
+func (h *L7FlowLog) IsError() bool {
+	return h.ResponseStatus == uint8(datatype.STATUS_SERVER_ERROR) || h.ResponseStatus == uint8(datatype.STATUS_CLIENT_ERROR)
+}
+
+func (h *L7FlowLog) GetResponseDuration() uint64 {
+	return h.ResponseDuration
+}
+
+func (h *L7FlowLog) GetTraceId() string {
+	return h.TraceId
+}
+
+func (h *L7FlowLog) SetSamplingRate(rate float64) {
+	h.SamplingRate = rate
+}
+
 func (h *L7FlowLog) StartTime() time.Duration {
 	return time.Duration(h.L7Base.StartTime) * time.Microsecond
 }
@@ -603,6 +628,7 @@ var poolL7FlowLog = pool.NewLockFreePool(func() interface{} {
 func AcquireL7FlowLog() *L7FlowLog {
 	l := poolL7FlowLog.Get().(*L7FlowLog)
 	l.ReferenceCount.Reset()
+	l.SamplingRate = 1
 	return l
 }
 
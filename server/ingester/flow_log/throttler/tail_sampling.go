@@ -0,0 +1,390 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package throttler
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+)
+
+const (
+	// 预热样本数不足时不判定时延离群
+	OUTLIER_MIN_SAMPLES = 16
+	OUTLIER_EWMA_ALPHA  = 0.05
+	// 每个 key 的蓄水池最小容量，避免 key 数量突增时小 key 一条都留不下
+	MIN_KEY_RESERVOIR = 8
+	// 被标记为需要完整保留的 trace 数量上限
+	MAX_KEPT_TRACES = 1 << 18
+)
+
+// TailSamplingItem 是可按尾部采样策略处理的日志，目前只有 L7FlowLog 实现
+type TailSamplingItem interface {
+	throttleItem
+	// 采样公平分配的维度：服务、协议、端点
+	SamplingKey() (service uint32, protocol uint8, endpoint string)
+	IsError() bool
+	GetResponseDuration() uint64 // us
+	GetTraceId() string
+	SetSamplingRate(rate float64)
+}
+
+type samplingRateItem interface {
+	SetSamplingRate(rate float64)
+}
+
+// TailSampler 在所有 L7 解码队列间共享，保证同一 trace 在不同 decoder 上得到一致的采样决策
+type TailSampler struct {
+	cfg            config.L7TailSampling
+	throttleBucket int64
+
+	// trace 采样比例，按 trace_id 的哈希值判定，同一周期内对同一 trace 结果一致
+	traceRatio   uint64 // math.Float64bits
+	traceBudget  int64
+	tracedCount  int64
+	periodBucket int64
+
+	// 包含异常或慢调用的 trace，其余 span 也需保留
+	keptTracesLock sync.Mutex
+	keptTraces     map[string]int64 // trace_id -> 过期时间
+}
+
+func NewTailSampler(cfg config.L7TailSampling, throttleBucket int) *TailSampler {
+	return &TailSampler{
+		cfg:            cfg,
+		throttleBucket: int64(throttleBucket),
+		traceRatio:     math.Float64bits(1),
+		keptTraces:     make(map[string]int64),
+	}
+}
+
+func (s *TailSampler) TraceRatio() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.traceRatio))
+}
+
+func (s *TailSampler) addTraceBudget(budget int) {
+	atomic.AddInt64(&s.traceBudget, int64(budget))
+}
+
+// tick 在周期切换时由各队列调用，只有第一个进入新周期的队列会重新计算 trace 采样比例
+func (s *TailSampler) tick(now int64) {
+	bucket := now / s.throttleBucket
+	last := atomic.LoadInt64(&s.periodBucket)
+	if bucket <= last || !atomic.CompareAndSwapInt64(&s.periodBucket, last, bucket) {
+		return
+	}
+	traced := atomic.SwapInt64(&s.tracedCount, 0)
+	budget := atomic.LoadInt64(&s.traceBudget)
+	ratio := 1.0
+	if traced > budget {
+		ratio = float64(budget) / float64(traced)
+	}
+	// 流量增大时立即下调比例，流量减小时缓慢回升，避免比例大幅震荡
+	if last := s.TraceRatio(); ratio > last {
+		ratio = (last + ratio) / 2
+	}
+	atomic.StoreUint64(&s.traceRatio, math.Float64bits(ratio))
+
+	s.keptTracesLock.Lock()
+	for traceId, expire := range s.keptTraces {
+		if expire < now {
+			delete(s.keptTraces, traceId)
+		}
+	}
+	s.keptTracesLock.Unlock()
+}
+
+func (s *TailSampler) keepTrace(traceId string, now int64) {
+	s.keptTracesLock.Lock()
+	if _, ok := s.keptTraces[traceId]; ok || len(s.keptTraces) < MAX_KEPT_TRACES {
+		s.keptTraces[traceId] = now + int64(s.cfg.KeptTraceTimeout)
+	}
+	s.keptTracesLock.Unlock()
+}
+
+func (s *TailSampler) isTraceKept(traceId string) bool {
+	s.keptTracesLock.Lock()
+	_, ok := s.keptTraces[traceId]
+	s.keptTracesLock.Unlock()
+	return ok
+}
+
+func traceHashHit(traceId string, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(traceId))
+	// fnv 的高位分布不均匀，需先用 murmur3 的 fmix64 打散，再取高 53 位映射到 [0, 1)
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return float64(x>>11)/(1<<53) < ratio
+}
+
+type samplingKey struct {
+	service  uint32
+	protocol uint8
+	endpoint string
+}
+
+type keyState struct {
+	latencyEWMA  float64
+	latencyCount int
+
+	active      bool
+	periodCount int
+	reservoir   []interface{}
+}
+
+// tailSampling 是单个 ThrottlingQueue 内的尾部采样状态，只在 decoder 协程中访问，无需加锁
+type tailSampling struct {
+	sampler *TailSampler
+
+	throttle         int
+	priorityThrottle int
+	traceThrottle    int
+	fairThrottle     int
+
+	priorityItems []interface{}
+	traceItems    []interface{}
+	traceRatio    float64 // 本周期 trace 一致采样使用的比例
+	keys          map[samplingKey]*keyState
+	keyCapacity   int
+	storedCount   int
+}
+
+func newTailSampling(sampler *TailSampler, throttle, priorityThrottle int) *tailSampling {
+	traceThrottle := int(float64(throttle) * sampler.cfg.TraceRatio)
+	sampler.addTraceBudget(traceThrottle)
+	t := &tailSampling{
+		sampler:          sampler,
+		throttle:         throttle,
+		priorityThrottle: priorityThrottle,
+		traceThrottle:    traceThrottle,
+		fairThrottle:     throttle - traceThrottle,
+		priorityItems:    make([]interface{}, 0, priorityThrottle),
+		traceItems:       make([]interface{}, 0, traceThrottle),
+		keys:             make(map[samplingKey]*keyState),
+	}
+	t.keyCapacity = t.fairThrottle
+	return t
+}
+
+func (t *tailSampling) isOutlier(state *keyState, duration uint64) bool {
+	cfg := &t.sampler.cfg
+	if cfg.SlowThreshold > 0 && duration >= uint64(cfg.SlowThreshold) {
+		return true
+	}
+	return state.latencyCount >= OUTLIER_MIN_SAMPLES && state.latencyEWMA > 0 &&
+		float64(duration) > state.latencyEWMA*cfg.OutlierFactor
+}
+
+// send 返回值含义与 SendWithThrottling 相同：true 表示数据已被接收
+func (t *tailSampling) send(item TailSamplingItem, now int64) bool {
+	service, protocol, endpoint := item.SamplingKey()
+	key := samplingKey{service, protocol, endpoint}
+	state, ok := t.keys[key]
+	if !ok {
+		state = &keyState{}
+		t.keys[key] = state
+	}
+	state.active = true
+
+	duration := item.GetResponseDuration()
+	priority := item.IsError() || t.isOutlier(state, duration)
+	if duration > 0 {
+		if state.latencyCount == 0 {
+			state.latencyEWMA = float64(duration)
+		} else {
+			state.latencyEWMA += OUTLIER_EWMA_ALPHA * (float64(duration) - state.latencyEWMA)
+		}
+		state.latencyCount++
+	}
+
+	traceId := item.GetTraceId()
+	if traceId != "" {
+		if priority {
+			t.sampler.keepTrace(traceId, now)
+		} else {
+			priority = t.sampler.isTraceKept(traceId)
+		}
+	}
+
+	// 异常、慢调用及其所属 trace 全量保留，超出优先配额后参与普通采样
+	if priority && len(t.priorityItems) < t.priorityThrottle {
+		item.SetSamplingRate(1)
+		t.priorityItems = append(t.priorityItems, item)
+		return true
+	}
+
+	if traceId != "" && !priority {
+		atomic.AddInt64(&t.sampler.tracedCount, 1)
+		ratio := t.sampler.TraceRatio()
+		if !traceHashHit(traceId, ratio) {
+			item.Release()
+			return false
+		}
+		// 命中的 trace 按哈希一致保留，超出配额(两倍)时才退化为普通采样
+		if len(t.traceItems) < t.traceThrottle*2 {
+			item.SetSamplingRate(ratio)
+			t.traceItems = append(t.traceItems, item)
+			t.traceRatio = ratio
+			return true
+		}
+	}
+
+	return t.reservoirSample(state, item)
+}
+
+// 每个 key 独立做蓄水池采样，周期结束时再按公平份额裁剪，替换进蓄水池的数据视为已接收
+func (t *tailSampling) reservoirSample(state *keyState, item TailSamplingItem) bool {
+	state.periodCount++
+	capacity := t.keyCapacity
+	if len(state.reservoir) < capacity && t.storedCount < t.fairThrottle*2 {
+		state.reservoir = append(state.reservoir, item)
+		t.storedCount++
+		return true
+	}
+	if len(state.reservoir) == 0 {
+		item.Release()
+		return false
+	}
+	r := rand.Intn(state.periodCount)
+	if r < len(state.reservoir) {
+		state.reservoir[r].(throttleItem).Release()
+		state.reservoir[r] = item
+		return true
+	}
+	item.Release()
+	return false
+}
+
+// fairShares 按 max-min 公平原则把 budget 分给各 key，需求少的 key 全部保留，剩余份额均分给其它 key
+func fairShares(demands []int, budget int) []int {
+	shares := make([]int, len(demands))
+	order := make([]int, len(demands))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return demands[order[i]] < demands[order[j]] })
+	for n, i := range order {
+		share := budget / (len(order) - n)
+		if demands[i] < share {
+			share = demands[i]
+		}
+		shares[i] = share
+		budget -= share
+	}
+	return shares
+}
+
+// limitItems 随机保留 limit 条数据并释放其余数据，被裁剪时按 rate*limit/len(items) 重新记录采样率
+func limitItems(items []interface{}, limit int, rate float64) []interface{} {
+	if len(items) <= limit {
+		return items
+	}
+	rand.Shuffle(len(items), func(a, b int) {
+		items[a], items[b] = items[b], items[a]
+	})
+	for i, item := range items[limit:] {
+		item.(throttleItem).Release()
+		items[limit+i] = nil
+	}
+	rate = rate * float64(limit) / float64(len(items))
+	for _, item := range items[:limit] {
+		item.(samplingRateItem).SetSamplingRate(rate)
+	}
+	return items[:limit]
+}
+
+// flush 把本周期保留的数据交给 emit，并为普通采样的数据记录采样率，
+// 按异常慢调用、trace 一致采样、公平采样的顺序分配 throttle，保证每个周期写入的数据总数不超过 throttle
+func (t *tailSampling) flush(emit func([]interface{})) {
+	budget := t.throttle
+	if len(t.priorityItems) > 0 {
+		items := limitItems(t.priorityItems, budget, 1)
+		emit(items)
+		budget -= len(items)
+		t.priorityItems = t.priorityItems[:0]
+	}
+	if len(t.traceItems) > 0 {
+		items := limitItems(t.traceItems, budget, t.traceRatio)
+		if len(items) > 0 {
+			emit(items)
+		}
+		budget -= len(items)
+		t.traceItems = t.traceItems[:0]
+	}
+
+	states := make([]*keyState, 0, len(t.keys))
+	demands := make([]int, 0, len(t.keys))
+	for key, state := range t.keys {
+		// 本周期没有数据的 key 不再保留，避免 key 无限增长
+		if !state.active {
+			delete(t.keys, key)
+			continue
+		}
+		states = append(states, state)
+		demands = append(demands, len(state.reservoir))
+	}
+	// trace 一致采样未用完的配额也分给公平采样
+	shares := fairShares(demands, budget)
+	for i, state := range states {
+		share := shares[i]
+		// 随机挑选 share 条，其余释放
+		rand.Shuffle(len(state.reservoir), func(a, b int) {
+			state.reservoir[a], state.reservoir[b] = state.reservoir[b], state.reservoir[a]
+		})
+		for _, item := range state.reservoir[share:] {
+			item.(throttleItem).Release()
+		}
+		if share > 0 {
+			rate := float64(share) / float64(state.periodCount)
+			for _, item := range state.reservoir[:share] {
+				item.(samplingRateItem).SetSamplingRate(rate)
+			}
+			emit(state.reservoir[:share])
+		}
+		for j := range state.reservoir {
+			state.reservoir[j] = nil
+		}
+		state.reservoir = state.reservoir[:0]
+		state.periodCount = 0
+		state.active = false
+	}
+	t.storedCount = 0
+
+	// 下个周期每个 key 的蓄水池容量为公平份额的两倍，给流量变化留出余量
+	if len(states) > 0 {
+		t.keyCapacity = t.fairThrottle * 2 / len(states)
+	}
+	if t.keyCapacity < MIN_KEY_RESERVOIR {
+		t.keyCapacity = MIN_KEY_RESERVOIR
+	}
+	if t.keyCapacity > t.fairThrottle {
+		t.keyCapacity = t.fairThrottle
+	}
+}
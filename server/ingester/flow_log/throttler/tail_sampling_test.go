@@ -0,0 +1,243 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package throttler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+)
+
+type testItem struct {
+	service  uint32
+	endpoint string
+	isError  bool
+	duration uint64
+	traceId  string
+	rate     float64
+	released bool
+}
+
+func (i *testItem) Release() {
+	i.released = true
+}
+
+func (i *testItem) SamplingKey() (uint32, uint8, string) {
+	return i.service, 20, i.endpoint
+}
+
+func (i *testItem) IsError() bool {
+	return i.isError
+}
+
+func (i *testItem) GetResponseDuration() uint64 {
+	return i.duration
+}
+
+func (i *testItem) GetTraceId() string {
+	return i.traceId
+}
+
+func (i *testItem) SetSamplingRate(rate float64) {
+	i.rate = rate
+}
+
+func newTestTailSampling(throttle, priorityThrottle int) *tailSampling {
+	sampler := NewTailSampler(config.L7TailSampling{
+		Enabled:          true,
+		SlowThreshold:    1000000,
+		OutlierFactor:    5,
+		TraceRatio:       0,
+		KeptTraceTimeout: 60,
+	}, 1)
+	return newTailSampling(sampler, throttle, priorityThrottle)
+}
+
+func collect(t *tailSampling) []*testItem {
+	emitted := []*testItem{}
+	t.flush(func(items []interface{}) {
+		for _, item := range items {
+			emitted = append(emitted, item.(*testItem))
+		}
+	})
+	return emitted
+}
+
+func TestTailSamplingKeepsErrorsAndOutliers(t *testing.T) {
+	ts := newTestTailSampling(30, 100)
+	items := []*testItem{}
+	for i := 0; i < 1000; i++ {
+		item := &testItem{service: 1, endpoint: "/api", duration: 100}
+		switch {
+		case i%100 == 50:
+			item.isError = true
+		case i%100 == 99:
+			item.duration = 10000
+		}
+		items = append(items, item)
+		ts.send(item, 0)
+	}
+	emitted := collect(ts)
+
+	errors, outliers := 0, 0
+	for _, item := range emitted {
+		if item.released {
+			t.Errorf("emitted item is released")
+		}
+		if item.isError {
+			errors++
+			if item.rate != 1 {
+				t.Errorf("error item sampling rate %f, expected 1", item.rate)
+			}
+		} else if item.duration == 10000 {
+			outliers++
+		}
+	}
+	if errors != 10 || outliers != 10 {
+		t.Errorf("expected 10 errors and 10 outliers kept, got %d and %d", errors, outliers)
+	}
+	if len(emitted) != 30 {
+		t.Errorf("expected 30 items emitted, got %d", len(emitted))
+	}
+	for _, item := range items {
+		if item.isError && item.released {
+			t.Errorf("error item is dropped")
+		}
+	}
+}
+
+func TestTailSamplingFairShare(t *testing.T) {
+	ts := newTestTailSampling(100, 0)
+	// 一个大流量 key 和两个小流量 key
+	accepted := 0
+	for i := 0; i < 10000; i++ {
+		item := &testItem{service: 1, endpoint: "/big"}
+		if ts.send(item, 0) {
+			accepted++
+		} else if !item.released {
+			t.Errorf("item is neither accepted nor released")
+		}
+	}
+	// 替换进蓄水池的数据也计为已接收
+	if accepted <= ts.keyCapacity {
+		t.Errorf("accepted %d items, expected more than reservoir capacity %d", accepted, ts.keyCapacity)
+	}
+	for i := 0; i < 10; i++ {
+		ts.send(&testItem{service: 2, endpoint: "/small"}, 0)
+		ts.send(&testItem{service: 3, endpoint: "/small"}, 0)
+	}
+	emitted := collect(ts)
+
+	counts := map[uint32]int{}
+	rates := map[uint32]float64{}
+	for _, item := range emitted {
+		counts[item.service]++
+		rates[item.service] = item.rate
+	}
+	if counts[2] != 10 || counts[3] != 10 || counts[1] != 80 {
+		t.Errorf("unexpected fair share %v", counts)
+	}
+	if rates[2] != 1 || rates[1] != 80.0/10000 {
+		t.Errorf("unexpected sampling rate %v", rates)
+	}
+}
+
+func TestTailSamplingThrottleLimit(t *testing.T) {
+	sampler := NewTailSampler(config.L7TailSampling{SlowThreshold: 1000000, OutlierFactor: 5, TraceRatio: 0.5, KeptTraceTimeout: 60}, 1)
+	ts := newTailSampling(sampler, 20, 100)
+	for i := 0; i < 50; i++ {
+		ts.send(&testItem{service: 1, isError: true}, 0)
+		ts.send(&testItem{service: 1, traceId: fmt.Sprintf("trace-%d", i)}, 0)
+		ts.send(&testItem{service: 2}, 0)
+	}
+	emitted := collect(ts)
+	if len(emitted) != 20 {
+		t.Fatalf("expected 20 items emitted, got %d", len(emitted))
+	}
+	for _, item := range emitted {
+		if !item.isError {
+			t.Errorf("priority items should use up the throttle, got %+v", item)
+		} else if item.rate != 20.0/50 {
+			t.Errorf("sampling rate %f, expected %f", item.rate, 20.0/50)
+		}
+	}
+
+	ts.send(&testItem{service: 1, isError: true}, 1)
+	for i := 0; i < 50; i++ {
+		ts.send(&testItem{service: 1, traceId: fmt.Sprintf("trace-%d", i)}, 1)
+		ts.send(&testItem{service: 2}, 1)
+	}
+	if emitted = collect(ts); len(emitted) != 20 {
+		t.Errorf("expected 20 items emitted, got %d", len(emitted))
+	}
+}
+
+func TestTailSamplingTraceConsistency(t *testing.T) {
+	sampler := NewTailSampler(config.L7TailSampling{OutlierFactor: 5, TraceRatio: 1, KeptTraceTimeout: 60}, 1)
+	// 两个队列模拟两个 decoder
+	ts1 := newTailSampling(sampler, 100, 100)
+	ts2 := newTailSampling(sampler, 100, 100)
+	for i := 0; i < 10000; i++ {
+		ts1.send(&testItem{service: 1, traceId: fmt.Sprintf("trace-%d", i)}, 0)
+	}
+	collect(ts1)
+	sampler.tick(1)
+	ratio := sampler.TraceRatio()
+	if ratio >= 1 || ratio <= 0 {
+		t.Fatalf("trace ratio %f not adjusted", ratio)
+	}
+
+	kept1, kept2 := map[string]bool{}, map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		traceId := fmt.Sprintf("trace-%d", i)
+		if ts1.send(&testItem{service: 1, traceId: traceId}, 1) {
+			kept1[traceId] = true
+		}
+		if ts2.send(&testItem{service: 2, traceId: traceId}, 1) {
+			kept2[traceId] = true
+		}
+	}
+	if len(kept1) == 0 {
+		t.Errorf("no trace kept")
+	}
+	for traceId := range kept1 {
+		if !kept2[traceId] {
+			t.Errorf("trace %s kept inconsistently", traceId)
+		}
+	}
+
+	// trace 中出现异常后，其它 span 都需要保留
+	ts1.send(&testItem{service: 1, traceId: "error-trace", isError: true}, 1)
+	for i := 0; i < 10; i++ {
+		item := &testItem{service: 2, traceId: "error-trace"}
+		if !ts2.send(item, 1) || item.rate != 1 {
+			t.Errorf("span of error trace is not kept")
+		}
+	}
+}
+
+func TestFairShares(t *testing.T) {
+	shares := fairShares([]int{100, 5, 30, 0}, 60)
+	expected := []int{28, 5, 27, 0}
+	for i := range expected {
+		if shares[i] != expected[i] {
+			t.Errorf("fair shares %v, expected %v", shares, expected)
+			break
+		}
+	}
+}
@@ -43,6 +43,8 @@ type ThrottlingQueue struct {
 
 	sampleItems    []interface{}
 	nonSampleItems []interface{}
+
+	tailSampling *tailSampling
 }
 
 func NewThrottlingQueue(throttle, throttleBucket int, flowLogWriter *dbwriter.FlowLogWriter, index int) *ThrottlingQueue {
@@ -60,21 +62,46 @@ func NewThrottlingQueue(throttle, throttleBucket int, flowLogWriter *dbwriter.Fl
 	return thq
 }
 
+// SetTailSampler 开启尾部采样, 实现了 TailSamplingItem 的数据不再做均匀的蓄水池采样
+// priorityThrottle 为每秒全量保留的异常、慢调用数据条数上限
+func (thq *ThrottlingQueue) SetTailSampler(sampler *TailSampler, priorityThrottle int) {
+	if thq.SampleDisabled() || sampler == nil {
+		return
+	}
+	thq.tailSampling = newTailSampling(sampler, thq.Throttle, priorityThrottle*int(thq.throttleBucket))
+}
+
 func (thq *ThrottlingQueue) SampleDisabled() bool {
 	return thq.Throttle <= 0
 }
 
+func (thq *ThrottlingQueue) emit(items []interface{}) {
+	if thq.flowLogWriter != nil {
+		thq.flowLogWriter.Put(thq.index, items...)
+	} else {
+		for i := range items {
+			if tItem, ok := items[i].(throttleItem); ok {
+				tItem.Release()
+			}
+		}
+	}
+}
+
 func (thq *ThrottlingQueue) flush() {
 	if thq.periodEmitCount > 0 {
-		if thq.flowLogWriter != nil {
-			thq.flowLogWriter.Put(thq.index, thq.sampleItems[:thq.periodEmitCount]...)
-		} else {
+		// 记录采样率，便于查询时还原真实数量
+		if thq.periodCount > thq.Throttle {
+			rate := float64(thq.Throttle) / float64(thq.periodCount)
 			for i := range thq.sampleItems[:thq.periodEmitCount] {
-				if tItem, ok := thq.sampleItems[i].(throttleItem); ok {
-					tItem.Release()
+				if rItem, ok := thq.sampleItems[i].(samplingRateItem); ok {
+					rItem.SetSamplingRate(rate)
 				}
 			}
 		}
+		thq.emit(thq.sampleItems[:thq.periodEmitCount])
+	}
+	if thq.tailSampling != nil {
+		thq.tailSampling.flush(thq.emit)
 	}
 }
 
@@ -90,11 +117,20 @@ func (thq *ThrottlingQueue) SendWithThrottling(flow interface{}) bool {
 		thq.lastFlush = now
 		thq.periodCount = 0
 		thq.periodEmitCount = 0
+		if thq.tailSampling != nil {
+			thq.tailSampling.sampler.tick(now)
+		}
 	}
 	if flow == nil {
 		return false
 	}
 
+	if thq.tailSampling != nil {
+		if item, ok := flow.(TailSamplingItem); ok {
+			return thq.tailSampling.send(item, now)
+		}
+	}
+
 	// Reservoir Sampling
 	thq.periodCount++
 	if thq.periodEmitCount < thq.Throttle {
@@ -120,15 +156,7 @@ func (thq *ThrottlingQueue) SendWithThrottling(flow interface{}) bool {
 func (thq *ThrottlingQueue) SendWithoutThrottling(flow interface{}) {
 	if flow == nil || len(thq.nonSampleItems) >= QUEUE_BATCH {
 		if len(thq.nonSampleItems) > 0 {
-			if thq.flowLogWriter != nil {
-				thq.flowLogWriter.Put(thq.index, thq.nonSampleItems...)
-			} else {
-				for i := range thq.nonSampleItems {
-					if tItem, ok := thq.nonSampleItems[i].(throttleItem); ok {
-						tItem.Release()
-					}
-				}
-			}
+			thq.emit(thq.nonSampleItems)
 			thq.nonSampleItems = thq.nonSampleItems[:0]
 		}
 	}
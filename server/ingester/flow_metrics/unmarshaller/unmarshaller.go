@@ -60,8 +60,10 @@ type Counter struct {
 	ExpiredDocCount int64 `statsd:"expired-doc-count"`
 	FutureDocCount  int64 `statsd:"future-doc-count"`
 	DropDocCount    int64 `statsd:"drop-doc-count"`
-	TotalTime       int64 `statsd:"total-time"`
-	AvgTime         int64 `statsd:"avg-time"`
+	// 已认证连接的数据中携带的采集器 ID 与认证的不一致，丢弃
+	VtapMismatchCount int64 `statsd:"vtap-mismatch-count"`
+	TotalTime         int64 `statsd:"total-time"`
+	AvgTime           int64 `statsd:"avg-time"`
 
 	FlowPortCount       int64 `statsd:"vtap-flow-port"`
 	FlowPort1sCount     int64 `statsd:"vtap-flow-port-1s"`
@@ -237,6 +239,12 @@ func (u *Unmarshaller) QueueProcess() {
 					}
 					u.isGoodDocument(int64(doc.Timestamp))
 
+					if recvBytes.Authenticated && doc.Tagger.(*zerodoc.Tag).VTAPID != recvBytes.VtapID {
+						u.counter.VtapMismatchCount++
+						app.ReleaseDocument(doc)
+						continue
+					}
+
 					// 秒级数据是否写入
 					if u.disableSecondWrite &&
 						doc.Flags&app.FLAG_PER_SECOND_METRICS != 0 {
@@ -51,6 +51,7 @@ import (
 	flowmetrics "github.com/deepflowio/deepflow/server/ingester/flow_metrics/flow_metrics"
 	pcapcfg "github.com/deepflowio/deepflow/server/ingester/pcap/config"
 	"github.com/deepflowio/deepflow/server/ingester/pcap/pcap"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/quota"
 	profilecfg "github.com/deepflowio/deepflow/server/ingester/profile/config"
 	"github.com/deepflowio/deepflow/server/ingester/profile/profile"
 	prometheuscfg "github.com/deepflowio/deepflow/server/ingester/prometheus/config"
@@ -95,7 +96,25 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 	bytes, _ = yaml.Marshal(dropletConfig)
 	log.Infof("droplet config:\n%s", string(bytes))
 
+	var receiverTLSConfig *receiver.TLSConfig
+	if cfg.ReceiverTLS.Enabled {
+		agentAuth, _ := receiver.ParseAgentAuthType(cfg.ReceiverTLS.AgentAuth)
+		receiverTLSConfig = &receiver.TLSConfig{
+			CertFile:     cfg.ReceiverTLS.CertFile,
+			KeyFile:      cfg.ReceiverTLS.KeyFile,
+			ClientCAFile: cfg.ReceiverTLS.ClientCAFile,
+			AgentAuth:    agentAuth,
+			TokenSecret:  cfg.ReceiverTLS.TokenSecret,
+		}
+	}
 	receiver := receiver.NewReceiver(int(cfg.ListenPort), cfg.UDPReadBuffer, cfg.TCPReadBuffer, cfg.TCPReaderBuffer)
+	if receiverTLSConfig != nil {
+		if err := receiver.EnableTLS(receiverTLSConfig); err != nil {
+			log.Error(err)
+			time.Sleep(time.Second)
+			os.Exit(1)
+		}
+	}
 
 	closers := droplet.Start(dropletConfig, receiver)
 
@@ -160,6 +179,14 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 			cfg.NodeIP,
 			receiver)
 
+		// 按采集器组和消息类型限制采集器写入的数据量
+		if cfg.IngestionQuota.Enabled {
+			limiter := quota.NewLimiter(&cfg.IngestionQuota, receiver, platformDataManager.GetMasterPlatformInfoTable(), shared.ResourceEventQueue)
+			receiver.SetIngestionLimiter(limiter)
+			limiter.Start()
+			closers = append(closers, limiter)
+		}
+
 		// 写流日志数据
 		flowLog, err := flowlog.NewFlowLog(flowLogConfig, receiver, platformDataManager)
 		checkError(err)
@@ -180,13 +207,13 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 			closers = append(closers, flowMetrics)
 
 			// write event data
-			event, err := event.NewEvent(eventConfig, shared.ResourceEventQueue, receiver, platformDataManager)
+			event, err := event.NewEvent(eventConfig, shared.ResourceEventQueue, shared.AlarmEventQueue, receiver, platformDataManager)
 			checkError(err)
 			event.Start()
 			closers = append(closers, event)
 
 			// write pcap data
-			pcaper, err := pcap.NewPcaper(receiver, pcapConfig)
+			pcaper, err := pcap.NewPcaper(receiver, platformDataManager.GetMasterPlatformInfoTable(), pcapConfig)
 			checkError(err)
 			pcaper.Start()
 			closers = append(closers, pcaper)
@@ -198,7 +225,7 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 			closers = append(closers, profile)
 
 			// write prometheus data
-			prometheus, err := prometheus.NewPrometheusHandler(prometheusConfig, receiver, platformDataManager)
+			prometheus, err := prometheus.NewPrometheusHandler(prometheusConfig, receiver, platformDataManager, shared.ResourceEventQueue)
 			checkError(err)
 			prometheus.Start()
 			closers = append(closers, prometheus)
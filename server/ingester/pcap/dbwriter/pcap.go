@@ -38,6 +38,12 @@ type PcapStore struct {
 	PacketCount uint32
 	PacketBatch []byte
 	AclGids     []uint16
+
+	OrgId uint16 // no need to store, used to select the org database
+}
+
+func (s *PcapStore) GetOrgId() uint16 {
+	return s.OrgId
 }
 
 func PcapStoreColumns() []*ckdb.Column {
@@ -28,6 +28,8 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/pcap/config"
 	"github.com/deepflowio/deepflow/server/ingester/pcap/dbwriter"
 	"github.com/deepflowio/deepflow/server/libs/codec"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/grpc"
 	"github.com/deepflowio/deepflow/server/libs/queue"
 	"github.com/deepflowio/deepflow/server/libs/receiver"
 	"github.com/deepflowio/deepflow/server/libs/stats"
@@ -73,10 +75,11 @@ type Counter struct {
 }
 
 type Decoder struct {
-	index      int
-	inQueue    queue.QueueReader
-	pcapWriter *dbwriter.PcapWriter
-	config     *config.Config
+	index        int
+	inQueue      queue.QueueReader
+	pcapWriter   *dbwriter.PcapWriter
+	platformData *grpc.PlatformInfoTable
+	config       *config.Config
 
 	counter *Counter
 	utils.Closable
@@ -86,14 +89,16 @@ func NewDecoder(
 	index int,
 	inQueue queue.QueueReader,
 	pcapWriter *dbwriter.PcapWriter,
+	platformData *grpc.PlatformInfoTable,
 	config *config.Config,
 ) *Decoder {
 	return &Decoder{
-		index:      index,
-		inQueue:    inQueue,
-		pcapWriter: pcapWriter,
-		config:     config,
-		counter:    &Counter{},
+		index:        index,
+		inQueue:      inQueue,
+		pcapWriter:   pcapWriter,
+		platformData: platformData,
+		config:       config,
+		counter:      &Counter{},
 	}
 }
 
@@ -134,7 +139,9 @@ func (d *Decoder) Run() {
 				continue
 			}
 			decoder.Init(recvBytes.Buffer[recvBytes.Begin:recvBytes.End])
+			counter, outCount := d.counter, d.counter.OutCount
 			d.handlePcap(recvBytes.VtapID, decoder, encoder, pcapHeader, pcapBatch)
+			receiver.ConsumeRows(datatype.MESSAGE_TYPE_RAW_PCAP, recvBytes.VtapID, counter.OutCount-outCount)
 			receiver.ReleaseRecvBuffer(recvBytes)
 		}
 	}
@@ -158,7 +165,9 @@ func (d *Decoder) handlePcap(vtapID uint16, decoder *codec.SimpleDecoder, encode
 		pcapHeader.Encode(encoder)
 		for _, pcap := range pcapBatch.Batches {
 			d.counter.OutCount++
-			d.pcapWriter.Write(pcapToStore(vtapID, encoder.Bytes(), pcap))
+			s := pcapToStore(vtapID, encoder.Bytes(), pcap)
+			s.OrgId = d.platformData.QueryVtapOrgId(uint32(vtapID))
+			d.pcapWriter.Write(s)
 		}
 	}
 }
@@ -25,6 +25,7 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/pcap/dbwriter"
 	"github.com/deepflowio/deepflow/server/ingester/pcap/decoder"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/grpc"
 	"github.com/deepflowio/deepflow/server/libs/queue"
 	libqueue "github.com/deepflowio/deepflow/server/libs/queue"
 	"github.com/deepflowio/deepflow/server/libs/receiver"
@@ -36,7 +37,7 @@ type Pcaper struct {
 	Writer   *dbwriter.PcapWriter
 }
 
-func NewPcaper(recv *receiver.Receiver, config *config.Config) (*Pcaper, error) {
+func NewPcaper(recv *receiver.Receiver, platformData *grpc.PlatformInfoTable, config *config.Config) (*Pcaper, error) {
 	msgType := datatype.MESSAGE_TYPE_RAW_PCAP
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_PCAP_QUEUE)
 	queueCount := config.PcapQueueCount
@@ -60,6 +61,7 @@ func NewPcaper(recv *receiver.Receiver, config *config.Config) (*Pcaper, error)
 			i,
 			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
 			pcapWriter,
+			platformData,
 			config,
 		)
 	}
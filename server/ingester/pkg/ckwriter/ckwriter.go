@@ -41,6 +41,8 @@ var log = logging.MustGetLogger("ckwriter")
 const (
 	FLUSH_TIMEOUT  = 10 * time.Second
 	SQL_LOG_LENGTH = 256
+
+	ORG_WRITER_RETRY_INTERVAL = time.Minute // 组织的 writer 创建失败后的重试间隔
 )
 
 type CKWriter struct {
@@ -61,13 +63,25 @@ type CKWriter struct {
 	connCount    uint64
 	dataQueues   queue.FixedMultiQueue
 	counters     []Counter
-	putCounter   int
+	putCounter   uint32 // 多个 goroutine 可能共用一个 writer, 需原子操作
 	writeCounter uint64
+	timeZone     string
+
+	// 非默认组织的数据写入独立数据库，首次收到该组织数据时创建 writer
+	orgWriters       map[uint16]*CKWriter
+	orgWriterFailure map[uint16]time.Time
+	orgWritersLock   sync.Mutex
 
 	wg   sync.WaitGroup
 	exit bool
 }
 
+// OrgItem 可区分组织的数据，非默认组织的数据写入 org<org_id>_ 前缀的独立数据库，
+// 未实现该接口的数据写入默认组织
+type OrgItem interface {
+	GetOrgId() uint16
+}
+
 type CKItem interface {
 	WriteBlock(block *ckdb.Block)
 	Release()
@@ -174,6 +188,7 @@ func NewCKWriter(addrs []string, user, password, counterName, timeZone string, t
 		batchSize:    batchSize,
 		flushTimeout: flushTimeout,
 		counterName:  counterName,
+		timeZone:     timeZone,
 
 		name:       name,
 		prepare:    table.MakePrepareTableInsertSQL(),
@@ -182,6 +197,9 @@ func NewCKWriter(addrs []string, user, password, counterName, timeZone string, t
 		connCount:  uint64(len(conns)),
 		dataQueues: dataQueues,
 		counters:   make([]Counter, queueCount),
+
+		orgWriters:       make(map[uint16]*CKWriter),
+		orgWriterFailure: make(map[uint16]time.Time),
 	}, nil
 }
 
@@ -207,16 +225,78 @@ func (i *Counter) GetCounter() interface{} {
 }
 
 func (w *CKWriter) Put(items ...interface{}) {
-	if w.queueSize == 0 {
-		for _, item := range items {
-			if ck, ok := item.(CKItem); ok {
-				ck.Release()
+	// 绝大多数情况下只有默认组织，无需拆分
+	var orgItems map[uint16][]interface{}
+	for i, item := range items {
+		orgItem, ok := item.(OrgItem)
+		if !ok || ckdb.IsDefaultOrgID(orgItem.GetOrgId()) {
+			if orgItems != nil {
+				orgItems[ckdb.DEFAULT_ORG_ID] = append(orgItems[ckdb.DEFAULT_ORG_ID], item)
 			}
+			continue
 		}
+		if orgItems == nil {
+			orgItems = make(map[uint16][]interface{})
+			orgItems[ckdb.DEFAULT_ORG_ID] = append(orgItems[ckdb.DEFAULT_ORG_ID], items[:i]...)
+		}
+		orgItems[orgItem.GetOrgId()] = append(orgItems[orgItem.GetOrgId()], item)
+	}
+	if orgItems == nil {
+		w.put(items...)
 		return
 	}
-	w.putCounter++
-	w.dataQueues.Put(queue.HashKey(w.putCounter%w.queueCount), items...)
+	for orgID, items := range orgItems {
+		if len(items) == 0 {
+			continue
+		}
+		if ckdb.IsDefaultOrgID(orgID) {
+			w.put(items...)
+		} else if orgWriter := w.getOrgWriter(orgID); orgWriter != nil {
+			orgWriter.put(items...)
+		} else {
+			// 不能写入默认组织的数据库，否则其他组织可以查询到
+			releaseItems(items)
+		}
+	}
+}
+
+func (w *CKWriter) getOrgWriter(orgID uint16) *CKWriter {
+	w.orgWritersLock.Lock()
+	defer w.orgWritersLock.Unlock()
+	if orgWriter, ok := w.orgWriters[orgID]; ok {
+		return orgWriter
+	}
+	if time.Since(w.orgWriterFailure[orgID]) < ORG_WRITER_RETRY_INTERVAL {
+		return nil
+	}
+	orgWriter, err := NewCKWriter(w.addrs, w.user, w.password, w.counterName, w.timeZone, ckdb.OrgTable(w.table, orgID),
+		w.queueCount, w.queueSize, w.batchSize, w.flushTimeout)
+	if err != nil {
+		log.Errorf("create writer of org %d for table %s failed: %s", orgID, w.name, err)
+		w.orgWriterFailure[orgID] = time.Now()
+		return nil
+	}
+	delete(w.orgWriterFailure, orgID)
+	orgWriter.Run()
+	w.orgWriters[orgID] = orgWriter
+	return orgWriter
+}
+
+func releaseItems(items []interface{}) {
+	for _, item := range items {
+		if ck, ok := item.(CKItem); ok {
+			ck.Release()
+		}
+	}
+}
+
+func (w *CKWriter) put(items ...interface{}) {
+	if w.queueSize == 0 {
+		releaseItems(items)
+		return
+	}
+	putCounter := atomic.AddUint32(&w.putCounter, 1)
+	w.dataQueues.Put(queue.HashKey(putCounter%uint32(w.queueCount)), items...)
 }
 
 func (w *CKWriter) queueProcess(queueID int) {
@@ -383,5 +463,11 @@ func (w *CKWriter) Close() {
 		q.Close()
 	}
 
+	w.orgWritersLock.Lock()
+	for _, orgWriter := range w.orgWriters {
+		orgWriter.Close()
+	}
+	w.orgWritersLock.Unlock()
+
 	log.Infof("ckwriter %s closed", w.name)
 }
@@ -0,0 +1,664 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quota
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+	"github.com/deepflowio/deepflow/server/libs/grpc"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+	"github.com/deepflowio/deepflow/server/libs/receiver"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+)
+
+var log = logging.MustGetLogger("quota")
+
+const (
+	OVERFLOW_POLICY_DROP   = "drop"
+	OVERFLOW_POLICY_SAMPLE = "sample"
+	OVERFLOW_POLICY_BUFFER = "buffer"
+
+	SCOPE_AGENT       = "agent"
+	SCOPE_AGENT_GROUP = "agent-group"
+
+	DEFAULT_SAMPLE_RATE    = 10
+	DEFAULT_BUFFER_SIZE    = 64 << 20 // 64M
+	DEFAULT_EVENT_INTERVAL = 300      // second
+
+	RELEASE_INTERVAL = 100 * time.Millisecond
+	EVICT_INTERVAL   = time.Minute
+	BUCKET_IDLE_TIME = 10 * time.Minute // 限额超过该时间没有数据时回收
+)
+
+// Config 采集器写入限额。按采集器组和消息类型匹配第一条规则，每种消息类型单独计算限额，
+// scope 为 agent 时组内每个采集器单独计算，为 agent-group 时组内采集器共享限额，限额为 0 表示不限制
+type Config struct {
+	Enabled        bool         `yaml:"enabled"`
+	OverflowPolicy string       `yaml:"overflow-policy"` // drop, sample or buffer
+	SampleRate     int          `yaml:"sample-rate"`     // 超限后每 sample-rate 帧保留 1 帧
+	BufferSize     int          `yaml:"buffer-size"`     // byte, 超限后每个限额最多缓存的数据量
+	EventInterval  int          `yaml:"event-interval"`  // second, 同一限额两次限流事件的最小间隔
+	Rules          []RuleConfig `yaml:"rules"`
+}
+
+// RuleConfig agent-group-ids、message-types 为空时对所有采集器组、消息类型生效，
+// overflow-policy 为空时使用全局配置
+type RuleConfig struct {
+	Name           string   `yaml:"name"`
+	AgentGroupIDs  []string `yaml:"agent-group-ids"`
+	MessageTypes   []string `yaml:"message-types"`
+	Scope          string   `yaml:"scope"`
+	RowsPerSecond  int64    `yaml:"rows-per-second"`
+	BytesPerSecond int64    `yaml:"bytes-per-second"`
+	BytesPerDay    int64    `yaml:"bytes-per-day"`
+	OverflowPolicy string   `yaml:"overflow-policy"`
+}
+
+func validatePolicy(policy string) error {
+	switch policy {
+	case OVERFLOW_POLICY_DROP, OVERFLOW_POLICY_SAMPLE, OVERFLOW_POLICY_BUFFER:
+		return nil
+	}
+	return fmt.Errorf("invalid overflow policy %s, should be %s, %s or %s",
+		policy, OVERFLOW_POLICY_DROP, OVERFLOW_POLICY_SAMPLE, OVERFLOW_POLICY_BUFFER)
+}
+
+func parseMessageType(s string) (datatype.MessageType, error) {
+	for i, name := range datatype.MessageTypeString {
+		if name == s && datatype.MessageType(i).HeaderType() == datatype.HEADER_TYPE_LT_VTAP {
+			return datatype.MessageType(i), nil
+		}
+	}
+	return datatype.MESSAGE_TYPE_MAX, fmt.Errorf("invalid message type %s", s)
+}
+
+func (c *Config) Validate() error {
+	if c.OverflowPolicy == "" {
+		c.OverflowPolicy = OVERFLOW_POLICY_DROP
+	}
+	if err := validatePolicy(c.OverflowPolicy); err != nil {
+		return fmt.Errorf("ingestion-quota: %s", err)
+	}
+	if c.SampleRate <= 0 {
+		c.SampleRate = DEFAULT_SAMPLE_RATE
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = DEFAULT_BUFFER_SIZE
+	}
+	if c.EventInterval <= 0 {
+		c.EventInterval = DEFAULT_EVENT_INTERVAL
+	}
+	names := make(map[string]bool, len(c.Rules))
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			rule.Name = strconv.Itoa(i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate ingestion quota rule name %s", rule.Name)
+		}
+		names[rule.Name] = true
+		switch rule.Scope {
+		case "":
+			rule.Scope = SCOPE_AGENT
+		case SCOPE_AGENT, SCOPE_AGENT_GROUP:
+		default:
+			return fmt.Errorf("invalid scope %s of ingestion quota rule %s, should be %s or %s",
+				rule.Scope, rule.Name, SCOPE_AGENT, SCOPE_AGENT_GROUP)
+		}
+		if rule.OverflowPolicy != "" {
+			if err := validatePolicy(rule.OverflowPolicy); err != nil {
+				return fmt.Errorf("ingestion quota rule %s: %s", rule.Name, err)
+			}
+		}
+		for _, t := range rule.MessageTypes {
+			if _, err := parseMessageType(t); err != nil {
+				return fmt.Errorf("ingestion quota rule %s: %s", rule.Name, err)
+			}
+		}
+		if rule.RowsPerSecond < 0 || rule.BytesPerSecond < 0 || rule.BytesPerDay < 0 {
+			return fmt.Errorf("quota of ingestion quota rule %s should not be negative", rule.Name)
+		}
+		if rule.RowsPerSecond == 0 && rule.BytesPerSecond == 0 && rule.BytesPerDay == 0 {
+			return fmt.Errorf("ingestion quota rule %s has no quota", rule.Name)
+		}
+	}
+	return nil
+}
+
+type rule struct {
+	*RuleConfig
+	index         int
+	agentGroupIDs map[string]bool
+	messageTypes  map[datatype.MessageType]bool
+	policy        string
+}
+
+func (r *rule) match(agentGroupID string, msgType datatype.MessageType) bool {
+	if len(r.agentGroupIDs) > 0 && !r.agentGroupIDs[agentGroupID] {
+		return false
+	}
+	return len(r.messageTypes) == 0 || r.messageTypes[msgType]
+}
+
+type Counter struct {
+	AdmitFrames  int64 `statsd:"admit-frames"`
+	AdmitBytes   int64 `statsd:"admit-bytes"`
+	Rows         int64 `statsd:"rows"`
+	DropFrames   int64 `statsd:"drop-frames"`
+	DropBytes    int64 `statsd:"drop-bytes"`
+	SampleFrames int64 `statsd:"sample-frames"` // 超限后抽样保留的帧数，已计入 admit-frames
+	BufferFrames int64 `statsd:"buffer-frames"` // 超限后放入缓存的帧数
+	BufferBytes  int64 `statsd:"buffer-bytes"`  // 当前缓存的数据量
+	DayBytes     int64 `statsd:"day-bytes"`     // 当天已接收的数据量
+
+	// 统计周期内已接收的数据量占限额的百分比
+	RowsUtilization  float64 `statsd:"rows-utilization"`
+	BytesUtilization float64 `statsd:"bytes-utilization"`
+	DayUtilization   float64 `statsd:"day-utilization"`
+}
+
+type exceedReason uint8
+
+const (
+	NOT_EXCEEDED exceedReason = iota
+	ROWS_EXCEEDED
+	BYTES_EXCEEDED
+	DAY_EXCEEDED
+)
+
+func (r exceedReason) String() string {
+	switch r {
+	case ROWS_EXCEEDED:
+		return "rows per second"
+	case BYTES_EXCEEDED:
+		return "bytes per second"
+	case DAY_EXCEEDED:
+		return "bytes per day"
+	}
+	return "none"
+}
+
+// bucket 为一个限额的令牌桶，令牌数可以为负，欠下的令牌在之后的时间里补齐，
+// 因此一帧数据或一次上报的行数超过每秒限额时也不会被永久拒绝
+type bucket struct {
+	sync.Mutex
+	rule         *rule
+	msgType      datatype.MessageType
+	vtapID       uint16 // scope 为 agent-group 时为 0
+	agentGroupID string
+
+	rowTokens   float64
+	byteTokens  float64
+	lastRefill  time.Time
+	day         int
+	dayBytes    int64
+	sampleCount int
+	buffered    []*receiver.RecvBuffer
+	bufferBytes int
+	lastEvent   time.Time
+	lastActive  time.Time
+	closed      bool
+
+	counter     *Counter
+	lastCounter time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	if elapsed > 0 {
+		b.rowTokens = refillTokens(b.rowTokens, float64(b.rule.RowsPerSecond), elapsed)
+		b.byteTokens = refillTokens(b.byteTokens, float64(b.rule.BytesPerSecond), elapsed)
+		b.lastRefill = now
+	}
+	if day := dayOf(now); day != b.day {
+		b.day = day
+		b.dayBytes = 0
+	}
+}
+
+// refillTokens 令牌桶的容量为 1 秒的限额
+func refillTokens(tokens, rate, elapsed float64) float64 {
+	tokens += rate * elapsed
+	if tokens > rate {
+		tokens = rate
+	}
+	return tokens
+}
+
+func dayOf(t time.Time) int {
+	return t.Year()*1000 + t.YearDay()
+}
+
+func (b *bucket) exceeded() exceedReason {
+	if b.rule.BytesPerDay > 0 && b.dayBytes >= b.rule.BytesPerDay {
+		return DAY_EXCEEDED
+	}
+	if b.rule.BytesPerSecond > 0 && b.byteTokens <= 0 {
+		return BYTES_EXCEEDED
+	}
+	if b.rule.RowsPerSecond > 0 && b.rowTokens <= 0 {
+		return ROWS_EXCEEDED
+	}
+	return NOT_EXCEEDED
+}
+
+func (b *bucket) consume(size int) {
+	b.byteTokens -= float64(size)
+	b.dayBytes += int64(size)
+	b.counter.AdmitFrames++
+	b.counter.AdmitBytes += int64(size)
+}
+
+func (b *bucket) drop(buffer *receiver.RecvBuffer) {
+	b.counter.DropFrames++
+	b.counter.DropBytes += int64(buffer.End - buffer.Begin)
+	receiver.ReleaseRecvBuffer(buffer)
+}
+
+func utilization(value, quota int64, seconds float64) float64 {
+	if quota <= 0 || seconds <= 0 {
+		return 0
+	}
+	return float64(value) * 100 / (float64(quota) * seconds)
+}
+
+func (b *bucket) Closed() bool {
+	b.Lock()
+	defer b.Unlock()
+	return b.closed
+}
+
+// close 丢弃缓存的数据并注销统计，调用者需持有锁
+func (b *bucket) close() {
+	for _, buffer := range b.buffered {
+		b.drop(buffer)
+	}
+	b.buffered, b.bufferBytes = nil, 0
+	b.closed = true
+}
+
+func (b *bucket) GetCounter() interface{} {
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now()
+	counter := b.counter
+	b.counter = &Counter{}
+	seconds := now.Sub(b.lastCounter).Seconds()
+	b.lastCounter = now
+
+	counter.BufferBytes = int64(b.bufferBytes)
+	counter.DayBytes = b.dayBytes
+	counter.RowsUtilization = utilization(counter.Rows, b.rule.RowsPerSecond, seconds)
+	counter.BytesUtilization = utilization(counter.AdmitBytes, b.rule.BytesPerSecond, seconds)
+	counter.DayUtilization = utilization(b.dayBytes, b.rule.BytesPerDay, 1)
+	return counter
+}
+
+type agentKey struct {
+	msgType datatype.MessageType
+	vtapID  uint16
+}
+
+type agentEntry struct {
+	agentGroupID string
+	bucket       *bucket // 未匹配到规则时为 nil
+}
+
+type bucketKey struct {
+	rule         int
+	msgType      datatype.MessageType
+	vtapID       uint16
+	agentGroupID string
+}
+
+// bufferPutter 为 receiver.Receiver，用于将缓存的数据放回队列
+type bufferPutter interface {
+	PutBuffer(msgType datatype.MessageType, buffer *receiver.RecvBuffer)
+}
+
+// Limiter 实现 receiver.IngestionLimiter，在 receiver 中按字节数和当天的数据量限制采集器写入的数据，
+// 行数由 decoder 解析后上报，超出行数限额后 receiver 对之后的数据执行超限策略
+type Limiter struct {
+	config     *Config
+	rules      []*rule
+	recv       bufferPutter
+	eventQueue queue.QueueWriter
+
+	queryAgentGroupID func(vtapID uint16) string
+	now               func() time.Time
+
+	sync.RWMutex
+	agents  map[agentKey]*agentEntry
+	buckets map[bucketKey]*bucket
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func NewLimiter(cfg *Config, recv *receiver.Receiver, platformData *grpc.PlatformInfoTable, eventQueue queue.QueueWriter) *Limiter {
+	return newLimiter(cfg, recv, platformData, eventQueue)
+}
+
+func newLimiter(cfg *Config, recv bufferPutter, platformData *grpc.PlatformInfoTable, eventQueue queue.QueueWriter) *Limiter {
+	l := &Limiter{
+		config:     cfg,
+		recv:       recv,
+		eventQueue: eventQueue,
+		queryAgentGroupID: func(vtapID uint16) string {
+			if info := platformData.QueryVtapInfo(uint32(vtapID)); info != nil {
+				return info.VtapGroupId
+			}
+			return ""
+		},
+		now:     time.Now,
+		agents:  make(map[agentKey]*agentEntry),
+		buckets: make(map[bucketKey]*bucket),
+		done:    make(chan struct{}),
+	}
+	for i := range cfg.Rules {
+		ruleConfig := &cfg.Rules[i]
+		r := &rule{
+			RuleConfig:    ruleConfig,
+			index:         i,
+			agentGroupIDs: make(map[string]bool),
+			messageTypes:  make(map[datatype.MessageType]bool),
+			policy:        ruleConfig.OverflowPolicy,
+		}
+		if r.policy == "" {
+			r.policy = cfg.OverflowPolicy
+		}
+		for _, id := range ruleConfig.AgentGroupIDs {
+			r.agentGroupIDs[id] = true
+		}
+		for _, t := range ruleConfig.MessageTypes {
+			msgType, _ := parseMessageType(t)
+			r.messageTypes[msgType] = true
+		}
+		l.rules = append(l.rules, r)
+	}
+	return l
+}
+
+func (l *Limiter) Start() {
+	go l.run()
+}
+
+// Close 停止放回缓存的数据，丢弃所有缓存并注销各限额的统计
+func (l *Limiter) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+		l.Lock()
+		defer l.Unlock()
+		for key, b := range l.buckets {
+			b.Lock()
+			b.close()
+			b.Unlock()
+			delete(l.buckets, key)
+		}
+		l.agents = make(map[agentKey]*agentEntry)
+	})
+	return nil
+}
+
+// run 定期将缓存的数据在令牌足够时放回 receiver 的队列，并回收长时间没有数据的限额
+func (l *Limiter) run() {
+	ticker := time.NewTicker(RELEASE_INTERVAL)
+	defer ticker.Stop()
+	lastEvict := l.now()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+		}
+		l.RLock()
+		buckets := make([]*bucket, 0, len(l.buckets))
+		for _, b := range l.buckets {
+			buckets = append(buckets, b)
+		}
+		l.RUnlock()
+		for _, b := range buckets {
+			l.release(b)
+		}
+		if now := l.now(); now.Sub(lastEvict) >= EVICT_INTERVAL {
+			l.evict(now)
+			lastEvict = now
+		}
+	}
+}
+
+// evict 回收超过 BUCKET_IDLE_TIME 没有数据且没有缓存的限额，采集器被删除或切换采集器组后旧的限额不再有数据
+func (l *Limiter) evict(now time.Time) {
+	l.Lock()
+	defer l.Unlock()
+	evicted := make(map[*bucket]bool)
+	for key, b := range l.buckets {
+		b.Lock()
+		if len(b.buffered) == 0 && now.Sub(b.lastActive) >= BUCKET_IDLE_TIME {
+			b.close()
+			delete(l.buckets, key)
+			evicted[b] = true
+		}
+		b.Unlock()
+	}
+	for key, entry := range l.agents {
+		if entry.bucket == nil || evicted[entry.bucket] {
+			delete(l.agents, key)
+		}
+	}
+}
+
+func (l *Limiter) release(b *bucket) {
+	b.Lock()
+	defer b.Unlock()
+	if b.closed || len(b.buffered) == 0 {
+		return
+	}
+	b.refill(l.now())
+	for len(b.buffered) > 0 {
+		reason := b.exceeded()
+		if reason == DAY_EXCEEDED {
+			// 当天的限额不会在短时间内恢复，缓存的数据直接丢弃
+			for _, buffer := range b.buffered {
+				b.drop(buffer)
+			}
+			b.buffered, b.bufferBytes = b.buffered[:0], 0
+			return
+		}
+		if reason != NOT_EXCEEDED {
+			return
+		}
+		buffer := b.buffered[0]
+		b.buffered[0] = nil
+		b.buffered = b.buffered[1:]
+		size := buffer.End - buffer.Begin
+		b.bufferBytes -= size
+		b.consume(size)
+		// 在锁内放入队列，保证同一限额的数据保持接收顺序
+		l.recv.PutBuffer(b.msgType, buffer)
+	}
+}
+
+func (l *Limiter) getBucket(msgType datatype.MessageType, vtapID uint16) *bucket {
+	agentGroupID := l.queryAgentGroupID(vtapID)
+	key := agentKey{msgType: msgType, vtapID: vtapID}
+	l.RLock()
+	entry, ok := l.agents[key]
+	l.RUnlock()
+	// 采集器切换采集器组后重新匹配规则
+	if ok && entry.agentGroupID == agentGroupID {
+		return entry.bucket
+	}
+
+	entry = &agentEntry{agentGroupID: agentGroupID}
+	var matched *rule
+	for _, r := range l.rules {
+		if r.match(agentGroupID, msgType) {
+			matched = r
+			break
+		}
+	}
+	l.Lock()
+	defer l.Unlock()
+	select {
+	case <-l.done:
+		// 已关闭时不再限制
+		return nil
+	default:
+	}
+	if matched != nil {
+		bKey := bucketKey{rule: matched.index, msgType: msgType, agentGroupID: agentGroupID}
+		if matched.Scope == SCOPE_AGENT {
+			bKey.vtapID = vtapID
+		}
+		b, ok := l.buckets[bKey]
+		if !ok {
+			now := l.now()
+			b = &bucket{
+				rule:         matched,
+				msgType:      msgType,
+				vtapID:       bKey.vtapID,
+				agentGroupID: agentGroupID,
+				rowTokens:    float64(matched.RowsPerSecond),
+				byteTokens:   float64(matched.BytesPerSecond),
+				lastRefill:   now,
+				day:          dayOf(now),
+				lastActive:   now,
+				counter:      &Counter{},
+				lastCounter:  now,
+			}
+			l.buckets[bKey] = b
+			common.RegisterCountableForIngester("ingestion_quota", b, stats.OptionStatTags{
+				"rule":        matched.Name,
+				"msg_type":    msgType.String(),
+				"vtap_id":     strconv.Itoa(int(bKey.vtapID)),
+				"agent_group": agentGroupID,
+			})
+		}
+		entry.bucket = b
+	}
+	l.agents[key] = entry
+	return entry.bucket
+}
+
+// lockBucket 返回加锁后的限额，限额在获取后被回收时重新获取，未匹配到规则时返回 nil
+func (l *Limiter) lockBucket(msgType datatype.MessageType, vtapID uint16) *bucket {
+	for {
+		b := l.getBucket(msgType, vtapID)
+		if b == nil {
+			return nil
+		}
+		b.Lock()
+		if !b.closed {
+			return b
+		}
+		b.Unlock()
+	}
+}
+
+func (l *Limiter) Admit(msgType datatype.MessageType, buffer *receiver.RecvBuffer) bool {
+	b := l.lockBucket(msgType, buffer.VtapID)
+	if b == nil {
+		return true
+	}
+	defer b.Unlock()
+	size := buffer.End - buffer.Begin
+	now := l.now()
+	b.lastActive = now
+	b.refill(now)
+	reason := b.exceeded()
+	if reason == NOT_EXCEEDED && len(b.buffered) == 0 {
+		b.consume(size)
+		return true
+	}
+	if reason != NOT_EXCEEDED {
+		l.throttled(b, buffer.VtapID, reason, now)
+	}
+
+	switch b.rule.policy {
+	case OVERFLOW_POLICY_SAMPLE:
+		b.sampleCount++
+		if reason != DAY_EXCEEDED && b.sampleCount%l.config.SampleRate == 0 {
+			b.consume(size)
+			b.counter.SampleFrames++
+			return true
+		}
+	case OVERFLOW_POLICY_BUFFER:
+		// 已有缓存时，新数据也放入缓存，保证接收顺序
+		if reason != DAY_EXCEEDED && b.bufferBytes+size <= l.config.BufferSize {
+			b.buffered = append(b.buffered, buffer)
+			b.bufferBytes += size
+			b.counter.BufferFrames++
+			return false
+		}
+	}
+	b.drop(buffer)
+	return false
+}
+
+func (l *Limiter) ConsumeRows(msgType datatype.MessageType, vtapID uint16, rows int64) {
+	b := l.lockBucket(msgType, vtapID)
+	if b == nil {
+		return
+	}
+	now := l.now()
+	b.lastActive = now
+	b.refill(now)
+	b.rowTokens -= float64(rows)
+	b.counter.Rows += rows
+	b.Unlock()
+}
+
+// throttled 在限额开始限流时发送资源事件，同一限额的两次事件至少间隔 event-interval
+func (l *Limiter) throttled(b *bucket, vtapID uint16, reason exceedReason, now time.Time) {
+	if now.Sub(b.lastEvent) < time.Duration(l.config.EventInterval)*time.Second {
+		return
+	}
+	b.lastEvent = now
+
+	target := fmt.Sprintf("agent %d", vtapID)
+	if b.rule.Scope == SCOPE_AGENT_GROUP {
+		target = fmt.Sprintf("agent group %s (agent %d)", b.agentGroupID, vtapID)
+	}
+	description := fmt.Sprintf("%s data of %s exceed the %s quota of ingestion quota rule %s, overflow policy is %s",
+		b.msgType, target, reason, b.rule.Name, b.rule.policy)
+	log.Warning(description)
+	if l.eventQueue == nil {
+		return
+	}
+
+	event := eventapi.AcquireResourceEvent()
+	event.Time = now.Unix()
+	event.TimeMilli = now.UnixMilli()
+	event.Type = eventapi.RESOURCE_EVENT_TYPE_INGESTION_THROTTLED
+	event.InstanceID = uint32(vtapID)
+	event.InstanceName = target
+	event.Description = description
+	if err := l.eventQueue.Put(event); err != nil {
+		log.Warningf("put ingestion throttled event failed: %s", err)
+		event.Release()
+	}
+}
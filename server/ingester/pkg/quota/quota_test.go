@@ -0,0 +1,292 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+	"github.com/deepflowio/deepflow/server/libs/receiver"
+)
+
+type testEventQueue struct {
+	events []*eventapi.ResourceEvent
+}
+
+func (q *testEventQueue) Put(items ...interface{}) error {
+	for _, item := range items {
+		q.events = append(q.events, item.(*eventapi.ResourceEvent))
+	}
+	return nil
+}
+
+func (q *testEventQueue) Len() int {
+	return len(q.events)
+}
+
+func (q *testEventQueue) Close() error {
+	return nil
+}
+
+type testPutter struct {
+	buffers []*receiver.RecvBuffer
+}
+
+func (p *testPutter) PutBuffer(msgType datatype.MessageType, buffer *receiver.RecvBuffer) {
+	p.buffers = append(p.buffers, buffer)
+}
+
+type testLimiter struct {
+	*Limiter
+	now        time.Time
+	putter     *testPutter
+	eventQueue *testEventQueue
+	groups     map[uint16]string
+}
+
+func newTestLimiter(t *testing.T, cfg *Config) *testLimiter {
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tl := &testLimiter{
+		now:        time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local),
+		putter:     &testPutter{},
+		eventQueue: &testEventQueue{},
+		groups:     map[uint16]string{1: "g-1", 2: "g-1", 3: "g-2"},
+	}
+	tl.Limiter = newLimiter(cfg, tl.putter, nil, tl.eventQueue)
+	tl.Limiter.now = func() time.Time { return tl.now }
+	tl.Limiter.queryAgentGroupID = func(vtapID uint16) string { return tl.groups[vtapID] }
+	return tl
+}
+
+func (tl *testLimiter) admit(msgType datatype.MessageType, vtapID uint16, size int) bool {
+	buffer, _ := receiver.AcquireRecvBuffer(size, receiver.TCP)
+	buffer.Begin, buffer.End, buffer.VtapID = 0, size, vtapID
+	return tl.Admit(msgType, buffer)
+}
+
+func (tl *testLimiter) bucket(msgType datatype.MessageType, vtapID uint16) *bucket {
+	return tl.getBucket(msgType, vtapID)
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := &Config{Rules: []RuleConfig{{MessageTypes: []string{"prometheus"}, RowsPerSecond: 1}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if cfg.OverflowPolicy != OVERFLOW_POLICY_DROP || cfg.SampleRate != DEFAULT_SAMPLE_RATE || cfg.BufferSize != DEFAULT_BUFFER_SIZE ||
+		cfg.EventInterval != DEFAULT_EVENT_INTERVAL || cfg.Rules[0].Name != "0" || cfg.Rules[0].Scope != SCOPE_AGENT {
+		t.Errorf("Validate() defaults not filled: %+v", cfg)
+	}
+
+	invalids := map[string]Config{
+		"policy":         {OverflowPolicy: "block"},
+		"rule policy":    {Rules: []RuleConfig{{RowsPerSecond: 1, OverflowPolicy: "block"}}},
+		"scope":          {Rules: []RuleConfig{{RowsPerSecond: 1, Scope: "region"}}},
+		"message type":   {Rules: []RuleConfig{{RowsPerSecond: 1, MessageTypes: []string{"syslog"}}}},
+		"no quota":       {Rules: []RuleConfig{{Name: "a"}}},
+		"negative quota": {Rules: []RuleConfig{{BytesPerDay: -1}}},
+		"duplicate name": {Rules: []RuleConfig{{Name: "a", RowsPerSecond: 1}, {Name: "a", RowsPerSecond: 1}}},
+	}
+	for name, c := range invalids {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate() with invalid %s should fail", name)
+		}
+	}
+}
+
+func TestLimiterMatchRule(t *testing.T) {
+	tl := newTestLimiter(t, &Config{Rules: []RuleConfig{
+		{Name: "g-1-prometheus", AgentGroupIDs: []string{"g-1"}, MessageTypes: []string{"prometheus"}, Scope: SCOPE_AGENT_GROUP, BytesPerSecond: 100},
+		{Name: "prometheus", MessageTypes: []string{"prometheus"}, BytesPerSecond: 100},
+	}})
+
+	// 同组采集器共享 agent-group 限额，其他组的采集器单独计算
+	if tl.bucket(datatype.MESSAGE_TYPE_PROMETHEUS, 1) != tl.bucket(datatype.MESSAGE_TYPE_PROMETHEUS, 2) {
+		t.Error("agents of g-1 should share the agent group quota")
+	}
+	b3 := tl.bucket(datatype.MESSAGE_TYPE_PROMETHEUS, 3)
+	if b3 == nil || b3.rule.Name != "prometheus" || b3.vtapID != 3 {
+		t.Errorf("agent 3 should match rule prometheus, got %+v", b3)
+	}
+	if tl.bucket(datatype.MESSAGE_TYPE_TELEGRAF, 1) != nil {
+		t.Error("telegraf should not be limited")
+	}
+	if !tl.admit(datatype.MESSAGE_TYPE_TELEGRAF, 1, 1000) {
+		t.Error("data without quota should be admitted")
+	}
+
+	// 采集器切换采集器组后重新匹配规则
+	tl.groups[1] = "g-2"
+	if b := tl.bucket(datatype.MESSAGE_TYPE_PROMETHEUS, 1); b == nil || b.rule.Name != "prometheus" {
+		t.Errorf("agent 1 should match rule prometheus after moving to g-2, got %+v", b)
+	}
+}
+
+func TestLimiterDrop(t *testing.T) {
+	tl := newTestLimiter(t, &Config{Rules: []RuleConfig{{BytesPerSecond: 100, RowsPerSecond: 10}}})
+	msgType := datatype.MESSAGE_TYPE_PROMETHEUS
+
+	if !tl.admit(msgType, 1, 80) || !tl.admit(msgType, 1, 80) {
+		t.Fatal("frames within the quota should be admitted")
+	}
+	if tl.admit(msgType, 1, 80) {
+		t.Fatal("frame exceeding bytes per second should be dropped")
+	}
+	b := tl.bucket(msgType, 1)
+	if b.counter.AdmitFrames != 2 || b.counter.DropFrames != 1 || b.counter.DropBytes != 80 {
+		t.Errorf("unexpected counter %+v", b.counter)
+	}
+	if len(tl.eventQueue.events) != 1 || tl.eventQueue.events[0].Type != eventapi.RESOURCE_EVENT_TYPE_INGESTION_THROTTLED ||
+		tl.eventQueue.events[0].InstanceID != 1 {
+		t.Fatalf("expect one throttled event, got %+v", tl.eventQueue.events)
+	}
+
+	// 欠下的令牌补齐后恢复接收
+	tl.now = tl.now.Add(time.Second)
+	if !tl.admit(msgType, 1, 10) {
+		t.Fatal("frame should be admitted after refilling")
+	}
+
+	// decoder 上报的行数超限后拒绝之后的数据
+	tl.now = tl.now.Add(2 * time.Second)
+	tl.ConsumeRows(msgType, 1, 15)
+	if tl.admit(msgType, 1, 10) {
+		t.Fatal("frame exceeding rows per second should be dropped")
+	}
+	if len(tl.eventQueue.events) != 1 {
+		t.Errorf("events should be sent at most once in event interval, got %d", len(tl.eventQueue.events))
+	}
+	tl.now = tl.now.Add(time.Second)
+	if !tl.admit(msgType, 1, 10) {
+		t.Fatal("frame should be admitted after rows refilling")
+	}
+}
+
+func TestLimiterSample(t *testing.T) {
+	tl := newTestLimiter(t, &Config{OverflowPolicy: OVERFLOW_POLICY_SAMPLE, SampleRate: 2, Rules: []RuleConfig{{BytesPerSecond: 10}}})
+	msgType := datatype.MESSAGE_TYPE_TELEGRAF
+
+	admitted := 0
+	for i := 0; i < 5; i++ {
+		if tl.admit(msgType, 1, 10) {
+			admitted++
+		}
+	}
+	// 第 1 帧在限额内，之后的 4 帧抽样保留 2 帧
+	if b := tl.bucket(msgType, 1); admitted != 3 || b.counter.SampleFrames != 2 || b.counter.DropFrames != 2 {
+		t.Errorf("admitted = %d, counter = %+v", admitted, b.counter)
+	}
+}
+
+func TestLimiterBuffer(t *testing.T) {
+	tl := newTestLimiter(t, &Config{BufferSize: 130, Rules: []RuleConfig{{BytesPerSecond: 50, OverflowPolicy: OVERFLOW_POLICY_BUFFER}}})
+	msgType := datatype.MESSAGE_TYPE_PROFILE
+
+	if !tl.admit(msgType, 1, 50) {
+		t.Fatal("first frame should be admitted")
+	}
+	for i := 0; i < 3; i++ {
+		if tl.admit(msgType, 1, 60) {
+			t.Fatalf("frame %d should be buffered or dropped", i)
+		}
+	}
+	b := tl.bucket(msgType, 1)
+	if len(b.buffered) != 2 || b.bufferBytes != 120 || b.counter.DropFrames != 1 {
+		t.Fatalf("buffered = %d, bytes = %d, counter = %+v", len(b.buffered), b.bufferBytes, b.counter)
+	}
+	first := b.buffered[0]
+
+	tl.now = tl.now.Add(time.Second)
+	tl.release(b)
+	if len(tl.putter.buffers) != 1 || tl.putter.buffers[0] != first || len(b.buffered) != 1 {
+		t.Fatalf("expect the first buffered frame released, got %d released and %d buffered", len(tl.putter.buffers), len(b.buffered))
+	}
+	// 有缓存时新数据也放入缓存，保证接收顺序
+	tl.now = tl.now.Add(time.Second)
+	if tl.admit(msgType, 1, 10) {
+		t.Fatal("frame should be buffered while buffer is not empty")
+	}
+	tl.release(b)
+	tl.now = tl.now.Add(time.Second)
+	tl.release(b)
+	if len(tl.putter.buffers) != 3 || len(b.buffered) != 0 || b.bufferBytes != 0 {
+		t.Errorf("expect all buffered frames released, got %d released and %d buffered", len(tl.putter.buffers), len(b.buffered))
+	}
+}
+
+func TestLimiterBytesPerDay(t *testing.T) {
+	tl := newTestLimiter(t, &Config{Rules: []RuleConfig{{BytesPerDay: 100, OverflowPolicy: OVERFLOW_POLICY_BUFFER}}})
+	msgType := datatype.MESSAGE_TYPE_TAGGEDFLOW
+
+	if !tl.admit(msgType, 1, 60) || !tl.admit(msgType, 1, 60) {
+		t.Fatal("frames should be admitted before reaching bytes per day")
+	}
+	// 超出当天限额的数据不缓存
+	if tl.admit(msgType, 1, 10) {
+		t.Fatal("frame exceeding bytes per day should be dropped")
+	}
+	b := tl.bucket(msgType, 1)
+	if len(b.buffered) != 0 || b.counter.DropFrames != 1 {
+		t.Errorf("buffered = %d, counter = %+v", len(b.buffered), b.counter)
+	}
+	counter := b.GetCounter().(*Counter)
+	if counter.DayBytes != 120 || counter.DayUtilization != 120 {
+		t.Errorf("unexpected counter %+v", counter)
+	}
+
+	tl.now = tl.now.Add(24 * time.Hour)
+	if !tl.admit(msgType, 1, 10) {
+		t.Fatal("frame should be admitted in the next day")
+	}
+}
+
+func TestLimiterEvictAndClose(t *testing.T) {
+	tl := newTestLimiter(t, &Config{Rules: []RuleConfig{{BytesPerSecond: 50, OverflowPolicy: OVERFLOW_POLICY_BUFFER}}})
+	msgType := datatype.MESSAGE_TYPE_PROFILE
+
+	tl.admit(msgType, 1, 50)
+	tl.admit(msgType, 3, 50)
+	idle := tl.bucket(msgType, 1)
+	tl.now = tl.now.Add(BUCKET_IDLE_TIME)
+	if !tl.admit(msgType, 3, 50) || tl.admit(msgType, 3, 50) {
+		t.Fatal("the second frame should be buffered")
+	}
+	buffering := tl.bucket(msgType, 3)
+
+	// 长时间没有数据的限额被回收，有缓存的限额保留
+	tl.evict(tl.now)
+	if !idle.Closed() || buffering.Closed() || len(tl.buckets) != 1 {
+		t.Fatalf("expect idle bucket evicted, idle closed %v, buffering closed %v, buckets %d",
+			idle.Closed(), buffering.Closed(), len(tl.buckets))
+	}
+	if b := tl.bucket(msgType, 1); b == idle || b.Closed() {
+		t.Fatal("expect a new bucket after eviction")
+	}
+
+	// 关闭时释放缓存的数据
+	tl.Close()
+	if !buffering.Closed() || len(buffering.buffered) != 0 || buffering.counter.DropFrames != 1 || len(tl.buckets) != 0 {
+		t.Fatalf("expect buffered frames dropped on close, buffered %d, counter %+v", len(buffering.buffered), buffering.counter)
+	}
+	if !tl.admit(msgType, 3, 50) {
+		t.Error("frame should be admitted after close")
+	}
+}
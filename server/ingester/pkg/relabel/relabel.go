@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package relabel
+
+import (
+	"fmt"
+	"strconv"
+
+	logging "github.com/op/go-logging"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+
+	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+var log = logging.MustGetLogger("relabel")
+
+const (
+	DATA_SOURCE_PROMETHEUS = "prometheus"
+	DATA_SOURCE_TELEGRAF   = "telegraf"
+)
+
+// RuleConfig 与 Prometheus 的 metric_relabel_configs 兼容，在 ingester 对 label 编码前执行，
+// agent-group-ids、data-sources 为空时对所有采集器组、数据源生效
+type RuleConfig struct {
+	Name                 string            `yaml:"name"`
+	AgentGroupIDs        []string          `yaml:"agent-group-ids"`
+	DataSources          []string          `yaml:"data-sources"`
+	MetricRelabelConfigs []*relabel.Config `yaml:"metric-relabel-configs"`
+}
+
+func ValidateRules(rules []RuleConfig) error {
+	names := make(map[string]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			rule.Name = strconv.Itoa(i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate metric relabel rule name %s", rule.Name)
+		}
+		names[rule.Name] = true
+		for _, source := range rule.DataSources {
+			if source != DATA_SOURCE_PROMETHEUS && source != DATA_SOURCE_TELEGRAF {
+				return fmt.Errorf("invalid data source %s of metric relabel rule %s, should be %s or %s",
+					source, rule.Name, DATA_SOURCE_PROMETHEUS, DATA_SOURCE_TELEGRAF)
+			}
+		}
+		if len(rule.MetricRelabelConfigs) == 0 {
+			return fmt.Errorf("metric relabel rule %s has no metric-relabel-configs", rule.Name)
+		}
+	}
+	return nil
+}
+
+type Counter struct {
+	In        int64 `statsd:"in"`
+	Drop      int64 `statsd:"drop"`
+	Rewritten int64 `statsd:"rewritten"`
+}
+
+type rule struct {
+	agentGroupIDs map[string]bool
+	configs       []*relabel.Config
+
+	counter *Counter
+	utils.Closable
+}
+
+func (r *rule) GetCounter() interface{} {
+	var counter *Counter
+	counter, r.counter = r.counter, &Counter{}
+	return counter
+}
+
+func (r *rule) match(agentGroupID string) bool {
+	return len(r.agentGroupIDs) == 0 || r.agentGroupIDs[agentGroupID]
+}
+
+// Relabeler 只在单个 decoder 协程中使用
+type Relabeler struct {
+	rules []*rule
+}
+
+// NewRelabeler 返回对指定数据源生效的规则，没有规则时返回 nil
+func NewRelabeler(dataSource string, index int, configs []RuleConfig) *Relabeler {
+	r := &Relabeler{}
+	for _, cfg := range configs {
+		if len(cfg.DataSources) > 0 && !contains(cfg.DataSources, dataSource) {
+			continue
+		}
+		rule := &rule{
+			configs: cfg.MetricRelabelConfigs,
+			counter: &Counter{},
+		}
+		if len(cfg.AgentGroupIDs) > 0 {
+			rule.agentGroupIDs = make(map[string]bool, len(cfg.AgentGroupIDs))
+			for _, id := range cfg.AgentGroupIDs {
+				rule.agentGroupIDs[id] = true
+			}
+		}
+		common.RegisterCountableForIngester("metric_relabel", rule, stats.OptionStatTags{
+			"thread":      strconv.Itoa(index),
+			"data_source": dataSource,
+			"rule":        cfg.Name,
+		})
+		r.rules = append(r.rules, rule)
+	}
+	if len(r.rules) == 0 {
+		return nil
+	}
+	log.Infof("%s decoder %d loaded %d metric relabel rules", dataSource, index, len(r.rules))
+	return r
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Matched 判断是否有规则对采集器组生效，调用方可据此跳过 label 转换
+func (r *Relabeler) Matched(agentGroupID string) bool {
+	if r == nil {
+		return false
+	}
+	for _, rule := range r.rules {
+		if rule.match(agentGroupID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Process 依次执行对采集器组生效的规则，lset 需按 label name 排序。
+// 返回 nil 表示数据被丢弃，lset 不会被修改
+func (r *Relabeler) Process(agentGroupID string, lset labels.Labels) labels.Labels {
+	for _, rule := range r.rules {
+		if !rule.match(agentGroupID) {
+			continue
+		}
+		rule.counter.In++
+		result := relabel.Process(lset, rule.configs...)
+		if result == nil {
+			rule.counter.Drop++
+			return nil
+		}
+		if !labels.Equal(lset, result) {
+			rule.counter.Rewritten++
+		}
+		lset = result
+	}
+	return lset
+}
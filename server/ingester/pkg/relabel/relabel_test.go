@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package relabel
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const testRules = `
+- name: drop-go-metrics
+  data-sources: [prometheus]
+  metric-relabel-configs:
+  - source_labels: [__name__]
+    regex: go_.*
+    action: drop
+- name: group-a
+  agent-group-ids: [g-a]
+  metric-relabel-configs:
+  - regex: pod_template_hash
+    action: labeldrop
+  - source_labels: [instance]
+    target_label: shard
+    modulus: 4
+    action: hashmod
+- name: telegraf-only
+  data-sources: [telegraf]
+  metric-relabel-configs:
+  - source_labels: [cpu]
+    regex: cpu-total
+    action: keep
+`
+
+func loadTestRules(t *testing.T) []RuleConfig {
+	var rules []RuleConfig
+	if err := yaml.Unmarshal([]byte(testRules), &rules); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestRelabelerProcess(t *testing.T) {
+	r := NewRelabeler(DATA_SOURCE_PROMETHEUS, 0, loadTestRules(t))
+	if r == nil || len(r.rules) != 2 {
+		t.Fatalf("expected 2 rules for prometheus")
+	}
+
+	if r.Process("g-b", labels.FromStrings("__name__", "go_goroutines", "job", "app")) != nil {
+		t.Errorf("go_goroutines should be dropped")
+	}
+
+	lset := labels.FromStrings("__name__", "up", "instance", "1.1.1.1:80", "pod_template_hash", "abc")
+	result := r.Process("g-a", lset)
+	if result.Has("pod_template_hash") || result.Get("shard") == "" || result.Get("instance") != "1.1.1.1:80" {
+		t.Errorf("unexpected relabel result %s", result)
+	}
+	if !lset.Has("pod_template_hash") {
+		t.Errorf("input labels should not be modified")
+	}
+
+	// group-a 的规则不对其它采集器组生效
+	result = r.Process("g-b", lset)
+	if !labels.Equal(lset, result) {
+		t.Errorf("labels of g-b should not be changed, got %s", result)
+	}
+	if r.Matched("") != true {
+		t.Errorf("rules without agent group should match all agents")
+	}
+
+	dropCounter := r.rules[0].GetCounter().(*Counter)
+	if dropCounter.In != 3 || dropCounter.Drop != 1 {
+		t.Errorf("unexpected counter of drop-go-metrics %+v", dropCounter)
+	}
+	groupCounter := r.rules[1].GetCounter().(*Counter)
+	if groupCounter.In != 1 || groupCounter.Rewritten != 1 {
+		t.Errorf("unexpected counter of group-a %+v", groupCounter)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	rules := []RuleConfig{{DataSources: []string{"statsd"}, MetricRelabelConfigs: loadTestRules(t)[0].MetricRelabelConfigs}}
+	if err := ValidateRules(rules); err == nil {
+		t.Errorf("invalid data source should fail")
+	}
+	if NewRelabeler(DATA_SOURCE_TELEGRAF, 0, loadTestRules(t)[:1]) != nil {
+		t.Errorf("no rule for telegraf, relabeler should be nil")
+	}
+}
@@ -90,6 +90,12 @@ type InProcessProfile struct {
 	L3DeviceType uint8
 	L3DeviceID   uint32
 	ServiceID    uint32
+
+	OrgId uint16 // no need to store, used to select the org database
+}
+
+func (p *InProcessProfile) GetOrgId() uint16 {
+	return p.OrgId
 }
 
 // profile_event_type <-> profile_value_unit relation
@@ -296,6 +302,7 @@ func (p *InProcessProfile) FillProfile(input *storage.PutInput,
 	p.ProcessID = pid
 	p.ProcessStartTime = stime
 	p.GPID = platformData.QueryProcessInfo(uint32(vtapID), pid)
+	p.OrgId = platformData.QueryVtapOrgId(uint32(vtapID))
 	tagNames = append(tagNames, LabelAppService, LabelLanguageType, LabelTraceID, LabelSpanName, LabelAppInstance)
 	tagValues = append(tagValues, p.AppService, p.ProfileLanguageType, p.TraceID, p.SpanName, p.AppInstance)
 	p.TagNames = tagNames
@@ -67,6 +67,10 @@ type Counter struct {
 	AvgTime   int64 `statsd:"avg-time"`
 }
 
+func (c *Counter) profileCount() int64 {
+	return atomic.LoadInt64(&c.JavaProfileCount) + atomic.LoadInt64(&c.GolangProfileCount) + atomic.LoadInt64(&c.EBPFProfileCount)
+}
+
 var spyMap = map[string]string{
 	"gospy":     "Golang",
 	"javaspy":   "Java",
@@ -141,7 +145,10 @@ func (d *Decoder) Run() {
 			}
 			decoder.Init(recvBytes.Buffer[recvBytes.Begin:recvBytes.End])
 			if d.msgType == datatype.MESSAGE_TYPE_PROFILE {
+				counter := d.counter
+				profileCount := counter.profileCount()
 				d.handleProfileData(recvBytes.VtapID, decoder)
+				receiver.ConsumeRows(d.msgType, recvBytes.VtapID, counter.profileCount()-profileCount)
 			}
 			receiver.ReleaseRecvBuffer(recvBytes)
 		}
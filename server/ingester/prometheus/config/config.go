@@ -17,6 +17,7 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 
@@ -37,8 +38,28 @@ const (
 	DefaultAppLabelColumnIncrement      = 4
 	DefaultAppLabelColumnMinCount       = 8
 	DefaultLabelCacheExpiration         = 86400 // 1 day
+	DefaultCardinalityWindow            = 3600  // 1 hour
 )
 
+const (
+	CARDINALITY_ACTION_DROP    = "drop"
+	CARDINALITY_ACTION_RELABEL = "relabel"
+)
+
+// 限制单个指标、target(job+instance)、采集器组在一个统计周期内写入的时序数，0 表示不限制。
+// 超限后新出现的时序按 action 丢弃，或去掉 relabel-keep-labels 以外的标签后合并写入
+type CardinalityLimit struct {
+	Enabled           bool           `yaml:"enabled"`
+	Action            string         `yaml:"action"`
+	RelabelKeepLabels []string       `yaml:"relabel-keep-labels"`
+	Window            int            `yaml:"window"` // second
+	MetricLimit       int            `yaml:"metric-limit"`
+	MetricLimits      map[string]int `yaml:"metric-limits"` // metric name -> limit
+	TargetLimit       int            `yaml:"target-limit"`
+	AgentGroupLimit   int            `yaml:"agent-group-limit"`
+	AgentGroupLimits  map[string]int `yaml:"agent-group-limits"` // agent group id -> limit
+}
+
 type Config struct {
 	Base                         *config.Config
 	CKWriterConfig               config.CKWriterConfig `yaml:"prometheus-ck-writer"`
@@ -51,6 +72,7 @@ type Config struct {
 	AppLabelColumnMinCount       int                   `yaml:"prometheus-app-label-column-min-count"`
 	IgnoreUniversalTag           bool                  `yaml:"prometheus-sample-ignore-universal-tag"`
 	LabelCacheExpiration         int                   `yaml:"prometheus-label-cache-expiration"`
+	CardinalityLimit             CardinalityLimit      `yaml:"prometheus-cardinality-limit"`
 }
 
 type PrometheusConfig struct {
@@ -79,6 +101,20 @@ func (c *Config) Validate() error {
 	if c.LabelCacheExpiration <= 0 {
 		c.LabelCacheExpiration = DefaultLabelCacheExpiration
 	}
+	if c.CardinalityLimit.Window <= 0 {
+		c.CardinalityLimit.Window = DefaultCardinalityWindow
+	}
+	switch c.CardinalityLimit.Action {
+	case CARDINALITY_ACTION_DROP, CARDINALITY_ACTION_RELABEL:
+	case "":
+		c.CardinalityLimit.Action = CARDINALITY_ACTION_DROP
+	default:
+		return fmt.Errorf("invalid prometheus-cardinality-limit action: %s, should be %s or %s",
+			c.CardinalityLimit.Action, CARDINALITY_ACTION_DROP, CARDINALITY_ACTION_RELABEL)
+	}
+	if len(c.CardinalityLimit.RelabelKeepLabels) == 0 {
+		c.CardinalityLimit.RelabelKeepLabels = []string{"job", "instance"}
+	}
 
 	return nil
 }
@@ -96,6 +132,11 @@ func Load(base *config.Config, path string) *Config {
 			AppLabelColumnIncrement:      DefaultAppLabelColumnIncrement,
 			AppLabelColumnMinCount:       DefaultAppLabelColumnMinCount,
 			LabelCacheExpiration:         DefaultLabelCacheExpiration,
+			CardinalityLimit: CardinalityLimit{
+				Action:            CARDINALITY_ACTION_DROP,
+				RelabelKeepLabels: []string{"job", "instance"},
+				Window:            DefaultCardinalityWindow,
+			},
 		},
 	}
 	if _, err := os.Stat(path); os.IsNotExist(err) {
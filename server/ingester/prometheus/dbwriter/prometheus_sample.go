@@ -59,6 +59,12 @@ type PrometheusSampleMini struct {
 	AppLabelValueIDs []uint32
 
 	Value float64
+
+	OrgId uint16 // no need to store, used to select the org database
+}
+
+func (m *PrometheusSampleMini) GetOrgId() uint16 {
+	return m.OrgId
 }
 
 func (m *PrometheusSampleMini) DatabaseName() string {
@@ -272,6 +278,7 @@ func AcquirePrometheusSampleMini() *PrometheusSampleMini {
 }
 
 func ReleasePrometheusSampleMini(p *PrometheusSampleMini) {
+	p.OrgId = 0
 	p.AppLabelValueIDs = p.AppLabelValueIDs[:0]
 	prometheusSampleMiniPool.Put(p)
 }
@@ -287,6 +294,7 @@ func AcquirePrometheusSample() *PrometheusSample {
 var emptyUniversalTag = zerodoc.UniversalTag{}
 
 func ReleasePrometheusSample(p *PrometheusSample) {
+	p.OrgId = 0
 	p.UniversalTag = emptyUniversalTag
 	p.AppLabelValueIDs = p.AppLabelValueIDs[:0]
 	prometheusSamplePool.Put(p)
@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/ingester/prometheus/config"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+)
+
+const (
+	// relabel 后的时序带上此标签，便于查询时区分被合并的数据
+	CARDINALITY_LIMITED_LABEL       = "deepflow_cardinality_limited"
+	CARDINALITY_LIMITED_LABEL_VALUE = "true"
+
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+type CardinalityScope uint8
+
+const (
+	CARDINALITY_SCOPE_METRIC CardinalityScope = iota
+	CARDINALITY_SCOPE_TARGET
+	CARDINALITY_SCOPE_AGENT_GROUP
+	CARDINALITY_SCOPE_MAX
+)
+
+var cardinalityScopeNames = [CARDINALITY_SCOPE_MAX]string{"metric", "target", "agent group"}
+
+func (s CardinalityScope) String() string {
+	if s < CARDINALITY_SCOPE_MAX {
+		return cardinalityScopeNames[s]
+	}
+	return "unknown"
+}
+
+type seriesSet struct {
+	series  map[uint64]struct{}
+	tripped bool // 本周期内是否已上报过超限事件
+}
+
+// CardinalityLimiter 在所有 decoder 间共享，统计每个指标、target、采集器组在一个周期内已接收的时序
+type CardinalityLimiter struct {
+	config     *config.CardinalityLimit
+	keepLabels map[string]bool
+	eventQueue queue.QueueWriter
+
+	sync.Mutex
+	windowStart int64
+	sets        [CARDINALITY_SCOPE_MAX]map[string]*seriesSet
+}
+
+func NewCardinalityLimiter(cfg *config.CardinalityLimit, eventQueue queue.QueueWriter) *CardinalityLimiter {
+	l := &CardinalityLimiter{
+		config:     cfg,
+		keepLabels: make(map[string]bool),
+		eventQueue: eventQueue,
+	}
+	for _, name := range cfg.RelabelKeepLabels {
+		l.keepLabels[name] = true
+	}
+	for i := range l.sets {
+		l.sets[i] = make(map[string]*seriesSet)
+	}
+	return l
+}
+
+func (l *CardinalityLimiter) limit(scope CardinalityScope, key string) int {
+	switch scope {
+	case CARDINALITY_SCOPE_METRIC:
+		if limit, ok := l.config.MetricLimits[key]; ok {
+			return limit
+		}
+		return l.config.MetricLimit
+	case CARDINALITY_SCOPE_TARGET:
+		return l.config.TargetLimit
+	case CARDINALITY_SCOPE_AGENT_GROUP:
+		if key == "" {
+			return 0
+		}
+		if limit, ok := l.config.AgentGroupLimits[key]; ok {
+			return limit
+		}
+		return l.config.AgentGroupLimit
+	}
+	return 0
+}
+
+func (l *CardinalityLimiter) targetLimited() bool {
+	return l.config.TargetLimit > 0
+}
+
+// Admit 判断时序是否可以写入。已接收过的时序总是可以写入，新时序只有在各维度都未超限时才会被接收，
+// 否则返回第一个超限的维度
+func (l *CardinalityLimiter) Admit(now int64, keys *[CARDINALITY_SCOPE_MAX]string, seriesHash uint64) (CardinalityScope, bool) {
+	var admitted [CARDINALITY_SCOPE_MAX]*seriesSet
+	l.Lock()
+	window := int64(l.config.Window)
+	if now >= l.windowStart+window {
+		l.windowStart = now - now%window
+		for i := range l.sets {
+			l.sets[i] = make(map[string]*seriesSet)
+		}
+	}
+	for scope := CARDINALITY_SCOPE_METRIC; scope < CARDINALITY_SCOPE_MAX; scope++ {
+		key := keys[scope]
+		limit := l.limit(scope, key)
+		if limit <= 0 {
+			continue
+		}
+		set, ok := l.sets[scope][key]
+		if !ok {
+			set = &seriesSet{series: make(map[uint64]struct{})}
+			l.sets[scope][key] = set
+		}
+		if _, ok := set.series[seriesHash]; ok {
+			continue
+		}
+		if len(set.series) >= limit {
+			needEvent := !set.tripped
+			set.tripped = true
+			l.Unlock()
+			if needEvent {
+				l.sendEvent(scope, key, limit)
+			}
+			return scope, false
+		}
+		admitted[scope] = set
+	}
+	for _, set := range admitted {
+		if set != nil {
+			set.series[seriesHash] = struct{}{}
+		}
+	}
+	l.Unlock()
+	return 0, true
+}
+
+func (l *CardinalityLimiter) sendEvent(scope CardinalityScope, key string, limit int) {
+	handle := "dropped"
+	if l.config.Action == config.CARDINALITY_ACTION_RELABEL {
+		handle = "relabeled"
+	}
+	description := fmt.Sprintf("prometheus series of %s %s exceed the limit %d in %ds, new series are %s",
+		scope, key, limit, l.config.Window, handle)
+	log.Warning(description)
+	if l.eventQueue == nil {
+		return
+	}
+
+	now := time.Now()
+	event := eventapi.AcquireResourceEvent()
+	event.Time = now.Unix()
+	event.TimeMilli = now.UnixMilli()
+	event.Type = eventapi.RESOURCE_EVENT_TYPE_CARDINALITY_LIMIT
+	event.InstanceName = key
+	event.Description = description
+	if err := l.eventQueue.Put(event); err != nil {
+		log.Warningf("put cardinality limit event failed: %s", err)
+		event.Release()
+	}
+}
+
+func fnvHashString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	// 分隔符，避免 "ab"+"c" 与 "a"+"bc" 冲突
+	h ^= 0xff
+	h *= fnvPrime64
+	return h
+}
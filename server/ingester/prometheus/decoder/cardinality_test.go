@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/ingester/prometheus/config"
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+	"github.com/deepflowio/deepflow/server/libs/eventapi"
+)
+
+type testEventQueue struct {
+	events []*eventapi.ResourceEvent
+}
+
+func (q *testEventQueue) Put(items ...interface{}) error {
+	for _, item := range items {
+		q.events = append(q.events, item.(*eventapi.ResourceEvent))
+	}
+	return nil
+}
+
+func (q *testEventQueue) Len() int {
+	return len(q.events)
+}
+
+func (q *testEventQueue) Close() error {
+	return nil
+}
+
+func TestCardinalityLimiterAdmit(t *testing.T) {
+	cfg := &config.CardinalityLimit{
+		Enabled:          true,
+		Action:           config.CARDINALITY_ACTION_DROP,
+		Window:           60,
+		MetricLimit:      2,
+		MetricLimits:     map[string]int{"unlimited": 0},
+		AgentGroupLimits: map[string]int{"g-1": 3},
+	}
+	eventQueue := &testEventQueue{}
+	limiter := NewCardinalityLimiter(cfg, eventQueue)
+
+	keys := [CARDINALITY_SCOPE_MAX]string{"metric_a", "", "g-1"}
+	for hash := uint64(1); hash <= 2; hash++ {
+		if _, ok := limiter.Admit(100, &keys, hash); !ok {
+			t.Fatalf("series %d should be admitted", hash)
+		}
+	}
+	if scope, ok := limiter.Admit(100, &keys, 3); ok || scope != CARDINALITY_SCOPE_METRIC {
+		t.Errorf("series 3 should exceed metric limit, got scope %s admitted %v", scope, ok)
+	}
+	if _, ok := limiter.Admit(101, &keys, 1); !ok {
+		t.Errorf("known series should always be admitted")
+	}
+	limiter.Admit(101, &keys, 4)
+	if len(eventQueue.events) != 1 || eventQueue.events[0].InstanceName != "metric_a" {
+		t.Errorf("expected one event for metric_a, got %d", len(eventQueue.events))
+	}
+
+	// metric_a 超限的时序不计入采集器组
+	unlimitedKeys := [CARDINALITY_SCOPE_MAX]string{"unlimited", "", "g-1"}
+	if _, ok := limiter.Admit(102, &unlimitedKeys, 5); !ok {
+		t.Errorf("series 5 should be admitted")
+	}
+	if scope, ok := limiter.Admit(102, &unlimitedKeys, 6); ok || scope != CARDINALITY_SCOPE_AGENT_GROUP {
+		t.Errorf("series 6 should exceed agent group limit, got scope %s admitted %v", scope, ok)
+	}
+
+	// 进入新的周期后重新计数
+	if _, ok := limiter.Admit(120, &keys, 3); !ok {
+		t.Errorf("series should be admitted in new window")
+	}
+}
+
+func TestRelabelLimitedSeries(t *testing.T) {
+	labels := []prompb.Label{
+		{Name: "__name__", Value: "http_requests_total"},
+		{Name: "job", Value: "app"},
+		{Name: "instance", Value: "1.1.1.1:80"},
+		{Name: "path", Value: "/user/1"},
+	}
+	ts := &prompb.TimeSeries{Labels: labels[:4:4]}
+	relabelLimitedSeries(ts, map[string]bool{"job": true, "instance": true})
+	expected := []prompb.Label{
+		{Name: "__name__", Value: "http_requests_total"},
+		{Name: "job", Value: "app"},
+		{Name: "instance", Value: "1.1.1.1:80"},
+		{Name: CARDINALITY_LIMITED_LABEL, Value: CARDINALITY_LIMITED_LABEL_VALUE},
+	}
+	if len(ts.Labels) != len(expected) {
+		t.Fatalf("unexpected labels %v", ts.Labels)
+	}
+	for i := range expected {
+		if ts.Labels[i].Name != expected[i].Name || ts.Labels[i].Value != expected[i].Value {
+			t.Errorf("label %d is %v, expected %v", i, ts.Labels[i], expected[i])
+		}
+	}
+	if labels[3].Name != "path" {
+		t.Errorf("original labels should not be modified")
+	}
+}
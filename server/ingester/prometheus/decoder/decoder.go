@@ -20,14 +20,18 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang/snappy"
 	logging "github.com/op/go-logging"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/relabel"
 	"github.com/deepflowio/deepflow/server/ingester/prometheus/config"
 	"github.com/deepflowio/deepflow/server/ingester/prometheus/dbwriter"
 	"github.com/deepflowio/deepflow/server/libs/codec"
@@ -59,6 +63,8 @@ type Counter struct {
 	TimeSeriesErr  int64 `statsd:"time-series-err"`
 	TimeSeriesSlow int64 `statsd:"time-series-slow"`
 	TimeSeriesOut  int64 `statsd:"time-series-out"` // count the number of TimeSeries (not Samples)
+
+	TimeSeriesRelabelDrop int64 `statsd:"time-series-relabel-drop"`
 }
 
 type BuilderCounter struct {
@@ -74,6 +80,12 @@ type BuilderCounter struct {
 	TargetMiss        int64 `statsd:"target-miss"`
 	MetricTargetMiss  int64 `statsd:"metric-target-miss"`
 	Sample            int64 `statsd:"sample-out"`
+
+	MetricCardinalityExceeded     int64 `statsd:"metric-cardinality-exceeded"`
+	TargetCardinalityExceeded     int64 `statsd:"target-cardinality-exceeded"`
+	AgentGroupCardinalityExceeded int64 `statsd:"agent-group-cardinality-exceeded"`
+	CardinalityDrop               int64 `statsd:"cardinality-drop"`
+	CardinalityRelabel            int64 `statsd:"cardinality-relabel"`
 }
 
 type PrometheusSamplesBuilder struct {
@@ -83,6 +95,7 @@ type PrometheusSamplesBuilder struct {
 	platformDataVersion uint64
 	appLabelColumnAlign int
 	ignoreUniversalTag  bool
+	cardinalityLimiter  *CardinalityLimiter
 
 	// temporary buffers
 	metricName              string
@@ -108,7 +121,7 @@ func (d *PrometheusSamplesBuilder) GetCounter() interface{} {
 	return counter
 }
 
-func NewPrometheusSamplesBuilder(name string, index int, platformData *grpc.PlatformInfoTable, labelTable *PrometheusLabelTable, appLabelColumnAlign int, ignoreUniversalTag bool) *PrometheusSamplesBuilder {
+func NewPrometheusSamplesBuilder(name string, index int, platformData *grpc.PlatformInfoTable, labelTable *PrometheusLabelTable, appLabelColumnAlign int, ignoreUniversalTag bool, cardinalityLimiter *CardinalityLimiter) *PrometheusSamplesBuilder {
 	p := &PrometheusSamplesBuilder{
 		name:                     name,
 		platformData:             platformData,
@@ -118,6 +131,7 @@ func NewPrometheusSamplesBuilder(name string, index int, platformData *grpc.Plat
 		vtapIDToUniversalTag:     make(map[uint16]zerodoc.UniversalTag),
 		appLabelColumnAlign:      appLabelColumnAlign,
 		ignoreUniversalTag:       ignoreUniversalTag,
+		cardinalityLimiter:       cardinalityLimiter,
 		counter:                  &BuilderCounter{},
 	}
 	common.RegisterCountableForIngester("decoder", p, stats.OptionStatTags{
@@ -135,6 +149,8 @@ type Decoder struct {
 	config           *config.Config
 
 	samplesBuilder *PrometheusSamplesBuilder
+	relabeler      *relabel.Relabeler
+	labelsBuffer   labels.Labels
 
 	counter *Counter
 	utils.Closable
@@ -148,10 +164,12 @@ func NewDecoder(
 	slowDecodeQueue queue.QueueWriter,
 	prometheusWriter *dbwriter.PrometheusWriter,
 	config *config.Config,
+	cardinalityLimiter *CardinalityLimiter,
 ) *Decoder {
 	return &Decoder{
 		index:            index,
-		samplesBuilder:   NewPrometheusSamplesBuilder("prometheus-builder", index, platformData, prometheusLabelTable, config.AppLabelColumnIncrement, config.IgnoreUniversalTag),
+		samplesBuilder:   NewPrometheusSamplesBuilder("prometheus-builder", index, platformData, prometheusLabelTable, config.AppLabelColumnIncrement, config.IgnoreUniversalTag, cardinalityLimiter),
+		relabeler:        relabel.NewRelabeler(relabel.DATA_SOURCE_PROMETHEUS, index, config.Base.MetricRelabelRules),
 		inQueue:          inQueue,
 		slowDecodeQueue:  slowDecodeQueue,
 		debugEnabled:     log.IsEnabledFor(logging.DEBUG),
@@ -190,7 +208,9 @@ func (d *Decoder) Run() {
 				continue
 			}
 			decoder.Init(recvBytes.Buffer[recvBytes.Begin:recvBytes.End])
+			counter, timeSeriesIn := d.counter, d.counter.TimeSeriesIn
 			d.handlePrometheusData(recvBytes.VtapID, decoder, &decodeBuffer, promWriteRequest, prometheusMetric, extraLabels)
+			receiver.ConsumeRows(datatype.MESSAGE_TYPE_PROMETHEUS, recvBytes.VtapID, counter.TimeSeriesIn-timeSeriesIn)
 			receiver.ReleaseRecvBuffer(recvBytes)
 		}
 	}
@@ -278,6 +298,19 @@ func (d *Decoder) sendPrometheus(vtapID uint16, ts *prompb.TimeSeries, extraLabe
 		return
 	}
 
+	if d.relabeler != nil {
+		var keep bool
+		if extraLabels, keep = d.relabel(vtapID, ts, extraLabels); !keep {
+			d.counter.TimeSeriesRelabelDrop++
+			return
+		}
+	}
+
+	// 在分配 label ID 之前限制时序数，避免 controller 中的 label 表膨胀
+	if !d.samplesBuilder.ApplyCardinalityLimit(vtapID, ts, extraLabels) {
+		return
+	}
+
 	isSlowItem, err := d.samplesBuilder.TimeSeriesToStore(vtapID, epcId, podClusterId, ts, extraLabels)
 	if !isSlowItem && err != nil {
 		if d.counter.TimeSeriesErr == 0 {
@@ -297,6 +330,43 @@ func (d *Decoder) sendPrometheus(vtapID uint16, ts *prompb.TimeSeries, extraLabe
 	d.counter.TimeSeriesOut++
 }
 
+// relabel executes the metric relabel rules of the agent group of vtapID, returns false if the time series is dropped.
+// extraLabels are merged into ts.Labels if the labels are rewritten, and the returned extraLabels is nil.
+func (d *Decoder) relabel(vtapID uint16, ts *prompb.TimeSeries, extraLabels []prompb.Label) ([]prompb.Label, bool) {
+	agentGroupID := ""
+	if vtapInfo := d.samplesBuilder.platformData.QueryVtapInfo(uint32(vtapID)); vtapInfo != nil {
+		agentGroupID = vtapInfo.VtapGroupId
+	}
+	if !d.relabeler.Matched(agentGroupID) {
+		return extraLabels, true
+	}
+
+	lset := d.labelsBuffer[:0]
+	for _, l := range ts.Labels {
+		lset = append(lset, labels.Label{Name: l.Name, Value: l.Value})
+	}
+	for _, l := range extraLabels {
+		lset = append(lset, labels.Label{Name: l.Name, Value: l.Value})
+	}
+	sort.Sort(lset)
+	d.labelsBuffer = lset
+
+	result := d.relabeler.Process(agentGroupID, lset)
+	if result == nil {
+		return nil, false
+	}
+	if labels.Equal(lset, result) {
+		return extraLabels, true
+	}
+	// ts.Labels 引用了 WriteRequest 的公共缓冲区，不能原地修改
+	newLabels := make([]prompb.Label, 0, len(result))
+	for _, l := range result {
+		newLabels = append(newLabels, prompb.Label{Name: l.Name, Value: l.Value})
+	}
+	ts.Labels = newLabels
+	return nil, true
+}
+
 func (b *PrometheusSamplesBuilder) GetEpcPodClusterId(vtapID uint16) (uint16, uint16, error) {
 	epcId, podClusterId := int32(0), uint16(0)
 	if vtapInfo := b.platformData.QueryVtapInfo(uint32(vtapID)); vtapInfo != nil {
@@ -309,6 +379,84 @@ func (b *PrometheusSamplesBuilder) GetEpcPodClusterId(vtapID uint16) (uint16, ui
 	return uint16(epcId), podClusterId, nil
 }
 
+// ApplyCardinalityLimit returns false if the time series is dropped because of the cardinality limit,
+// if the limit action is relabel, the labels of ts are modified in place
+func (b *PrometheusSamplesBuilder) ApplyCardinalityLimit(vtapID uint16, ts *prompb.TimeSeries, extraLabels []prompb.Label) bool {
+	limiter := b.cardinalityLimiter
+	if limiter == nil {
+		return true
+	}
+
+	var keys [CARDINALITY_SCOPE_MAX]string
+	var job, instance string
+	hash := uint64(fnvOffset64)
+	var l *prompb.Label
+	tsLen, extraLen := len(ts.Labels), len(extraLabels)
+	for i := 0; i < tsLen+extraLen; i++ {
+		if i < tsLen {
+			l = &ts.Labels[i]
+		} else {
+			l = &extraLabels[i-tsLen]
+		}
+		switch l.Name {
+		case model.MetricNameLabel:
+			if keys[CARDINALITY_SCOPE_METRIC] == "" {
+				keys[CARDINALITY_SCOPE_METRIC] = l.Value
+			}
+		case model.JobLabel:
+			if job == "" {
+				job = l.Value
+			}
+		case model.InstanceLabel:
+			if instance == "" {
+				instance = l.Value
+			}
+		case CARDINALITY_LIMITED_LABEL:
+			// 已经 relabel 过的时序不再限制
+			return true
+		}
+		hash = fnvHashString(hash, l.Name)
+		hash = fnvHashString(hash, l.Value)
+	}
+	if limiter.targetLimited() {
+		keys[CARDINALITY_SCOPE_TARGET] = job + "/" + instance
+	}
+	if vtapInfo := b.platformData.QueryVtapInfo(uint32(vtapID)); vtapInfo != nil {
+		keys[CARDINALITY_SCOPE_AGENT_GROUP] = vtapInfo.VtapGroupId
+	}
+
+	scope, ok := limiter.Admit(time.Now().Unix(), &keys, hash)
+	if ok {
+		return true
+	}
+	switch scope {
+	case CARDINALITY_SCOPE_METRIC:
+		b.counter.MetricCardinalityExceeded++
+	case CARDINALITY_SCOPE_TARGET:
+		b.counter.TargetCardinalityExceeded++
+	case CARDINALITY_SCOPE_AGENT_GROUP:
+		b.counter.AgentGroupCardinalityExceeded++
+	}
+	if limiter.config.Action != config.CARDINALITY_ACTION_RELABEL {
+		b.counter.CardinalityDrop++
+		return false
+	}
+	relabelLimitedSeries(ts, limiter.keepLabels)
+	b.counter.CardinalityRelabel++
+	return true
+}
+
+// 只保留指标名和 keepLabels 中的标签，使超限的时序合并为少量时序写入
+func relabelLimitedSeries(ts *prompb.TimeSeries, keepLabels map[string]bool) {
+	labels := make([]prompb.Label, 0, len(keepLabels)+2)
+	for _, l := range ts.Labels {
+		if l.Name == model.MetricNameLabel || keepLabels[l.Name] {
+			labels = append(labels, l)
+		}
+	}
+	ts.Labels = append(labels, prompb.Label{Name: CARDINALITY_LIMITED_LABEL, Value: CARDINALITY_LIMITED_LABEL_VALUE})
+}
+
 // if success,return false,nil
 // if failed, return false,err
 // if isSlow, return true,slowReason
@@ -443,6 +591,7 @@ func (b *PrometheusSamplesBuilder) TimeSeriesToStore(vtapID, epcId, podClusterId
 	}
 
 	var universalTag *zerodoc.UniversalTag
+	orgId := b.platformData.QueryVtapOrgId(uint32(vtapID))
 	for i, s := range ts.Samples {
 		v := float64(s.Value)
 		if math.IsNaN(v) || math.IsInf(v, 0) {
@@ -456,6 +605,7 @@ func (b *PrometheusSamplesBuilder) TimeSeriesToStore(vtapID, epcId, podClusterId
 			m.TargetID = targetID
 			m.AppLabelValueIDs = append(m.AppLabelValueIDs, b.appLabelValueIDsBuffer...)
 			m.Value = v
+			m.OrgId = orgId
 			b.samplesBuffer = append(b.samplesBuffer, m)
 		} else {
 			m := dbwriter.AcquirePrometheusSample()
@@ -464,6 +614,7 @@ func (b *PrometheusSamplesBuilder) TimeSeriesToStore(vtapID, epcId, podClusterId
 			m.TargetID = targetID
 			m.AppLabelValueIDs = append(m.AppLabelValueIDs, b.appLabelValueIDsBuffer...)
 			m.Value = v
+			m.OrgId = orgId
 
 			if i == 0 {
 				b.fillUniversalTag(m, vtapID, podName, instance, podNameID, instanceID, false)
@@ -45,7 +45,7 @@ type PrometheusHandler struct {
 	prometheusLabelTable *decoder.PrometheusLabelTable
 }
 
-func NewPrometheusHandler(config *config.Config, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager) (*PrometheusHandler, error) {
+func NewPrometheusHandler(config *config.Config, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager, eventQueue queue.QueueWriter) (*PrometheusHandler, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_PROMETHEUS_QUEUE)
 	queueCount := config.DecoderQueueCount
 	msgType := datatype.MESSAGE_TYPE_PROMETHEUS
@@ -75,6 +75,11 @@ func NewPrometheusHandler(config *config.Config, recv *receiver.Receiver, platfo
 		initAppLabelColumnCount = currentColumnIndexMax
 	}
 
+	var cardinalityLimiter *decoder.CardinalityLimiter
+	if config.CardinalityLimit.Enabled {
+		cardinalityLimiter = decoder.NewCardinalityLimiter(&config.CardinalityLimit, eventQueue)
+	}
+
 	decoders := make([]*decoder.Decoder, queueCount)
 	platformDatas := make([]*grpc.PlatformInfoTable, queueCount)
 	slowDecoders := make([]*decoder.SlowDecoder, queueCount)
@@ -100,6 +105,7 @@ func NewPrometheusHandler(config *config.Config, recv *receiver.Receiver, platfo
 			queue.QueueWriter(slowDecodeQueues.FixedMultiQueue[i]),
 			metricsWriter,
 			config,
+			cardinalityLimiter,
 		)
 		slowMetricsWriter, err := dbwriter.NewPrometheusWriter(i, initAppLabelColumnCount, "slow-prometheus", dbwriter.PROMETHEUS_DB, config)
 		if err != nil {
@@ -41,6 +41,12 @@ type Document struct {
 	zerodoc.Tagger
 	zerodoc.Meter
 	Flags DocumentFlag
+
+	OrgId uint16 // no need to store, used to select the org database
+}
+
+func (d *Document) GetOrgId() uint16 {
+	return d.OrgId
 }
 
 const (
@@ -83,6 +89,7 @@ func CloneDocument(doc *Document) *Document {
 	newDoc.Tagger = doc.Tagger.Clone()
 	newDoc.Meter = doc.Meter.Clone()
 	newDoc.Flags = doc.Flags
+	newDoc.OrgId = doc.OrgId
 	return newDoc
 }
 
@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckdb
+
+import "fmt"
+
+const (
+	DEFAULT_ORG_ID = 1
+	// org_id 存储为 uint16
+	MAX_ORG_ID = 1<<16 - 1
+)
+
+// OrgDatabasePrefix 返回组织数据库名前缀，默认组织沿用原有数据库（flow_log、flow_metrics 等），
+// 其他组织使用 `org<4位org_id>_` 前缀的独立数据库，如 org0002_flow_log，以字母开头的库名在 SQL 中无需转义
+func OrgDatabasePrefix(orgID uint16) string {
+	if IsDefaultOrgID(orgID) {
+		return ""
+	}
+	return fmt.Sprintf("org%04d_", orgID)
+}
+
+// ORG_DATABASES 按组织写入独立数据库的库名，ingester 将非默认组织的数据写入组织的数据库，
+// querier 查询非默认组织时替换为组织的数据库，flow_tag 等元数据库为所有组织共享
+var ORG_DATABASES = []string{"flow_log", "flow_metrics", "ext_metrics", "deepflow_system", "event", "profile", "prometheus"}
+
+func IsDefaultOrgID(orgID uint16) bool {
+	return orgID == DEFAULT_ORG_ID || orgID == 0
+}
+
+// OrgTable 返回组织数据库中的同名表
+func OrgTable(t *Table, orgID uint16) *Table {
+	if IsDefaultOrgID(orgID) {
+		return t
+	}
+	orgTable := *t
+	orgTable.Database = OrgDatabasePrefix(orgID) + t.Database
+	return &orgTable
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventapi
+
+import "github.com/deepflowio/deepflow/server/libs/pool"
+
+// the values are the same as enum file event_level
+const (
+	ALARM_EVENT_LEVEL_CRITICAL = 1
+	ALARM_EVENT_LEVEL_ERROR    = 2
+	ALARM_EVENT_LEVEL_WARNING  = 3
+	ALARM_EVENT_LEVEL_NO_DATA  = 4
+	ALARM_EVENT_LEVEL_NORMAL   = 5
+)
+
+// AlarmEvent has the same fields as message alarm_event.AlarmEvent, it is used to
+// write alarm events generated by controller to ingester in the same process
+type AlarmEvent struct {
+	Time   uint32
+	Lcuuid string
+	User   string
+	UserId uint32
+
+	PolicyId                uint32
+	PolicyName              string
+	PolicyLevel             uint32
+	PolicyAppType           uint32
+	PolicySubType           uint32
+	PolicyContrastType      uint32
+	PolicyDataLevel         string
+	PolicyTargetUid         string
+	PolicyTargetName        string
+	PolicyGoTo              string
+	PolicyTargetField       string
+	PolicyEndpoints         string
+	TriggerCondition        string
+	TriggerValue            float64
+	ValueUnit               string
+	EventLevel              uint32
+	AlarmTarget             string
+	PolicyQueryUrl          string
+	PolicyQueryConditions   string
+	PolicyThresholdCritical string
+	PolicyThresholdError    string
+	PolicyThresholdWarning  string
+}
+
+func (e *AlarmEvent) Release() {
+	ReleaseAlarmEvent(e)
+}
+
+var poolAlarmEvent = pool.NewLockFreePool(func() interface{} {
+	return new(AlarmEvent)
+})
+
+func AcquireAlarmEvent() *AlarmEvent {
+	return poolAlarmEvent.Get().(*AlarmEvent)
+}
+
+func ReleaseAlarmEvent(event *AlarmEvent) {
+	if event == nil {
+		return
+	}
+	*event = AlarmEvent{}
+	poolAlarmEvent.Put(event)
+}
@@ -26,6 +26,18 @@ const (
 	RESOURCE_EVENT_TYPE_RECREATE     = "recreate"
 	RESOURCE_EVENT_TYPE_ADD_IP       = "add-ip"
 	RESOURCE_EVENT_TYPE_REMOVE_IP    = "remove-ip"
+
+	// workload(pod group) changes
+	RESOURCE_EVENT_TYPE_ROLLOUT_STARTED  = "rollout-started"
+	RESOURCE_EVENT_TYPE_ROLLOUT_FINISHED = "rollout-finished"
+	RESOURCE_EVENT_TYPE_IMAGE_CHANGED    = "image-changed"
+	RESOURCE_EVENT_TYPE_SCALE_UP         = "scale-up"
+	RESOURCE_EVENT_TYPE_SCALE_DOWN       = "scale-down"
+	RESOURCE_EVENT_TYPE_LABEL_CHANGED    = "label-changed"
+
+	RESOURCE_EVENT_TYPE_CARDINALITY_LIMIT   = "cardinality-limit"
+	RESOURCE_EVENT_TYPE_ANALYZER_REBALANCE  = "analyzer-rebalance"
+	RESOURCE_EVENT_TYPE_INGESTION_THROTTLED = "ingestion-throttled"
 )
 
 type ResourceEvent struct {
@@ -145,6 +157,12 @@ func TagPodGroupID(id int) TagFieldOption {
 	}
 }
 
+func TagPodGroupType(t int) TagFieldOption {
+	return func(r *ResourceEvent) {
+		r.PodGroupType = uint8(t)
+	}
+}
+
 func TagPodID(id int) TagFieldOption {
 	return func(r *ResourceEvent) {
 		r.PodID = uint32(id)
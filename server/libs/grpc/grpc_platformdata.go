@@ -34,6 +34,7 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/deepflowio/deepflow/message/trident"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
 	"github.com/deepflowio/deepflow/server/libs/hmap/lru"
 	"github.com/deepflowio/deepflow/server/libs/receiver"
@@ -111,6 +112,8 @@ type VtapInfo struct {
 	EpcId        int32
 	Ip           string
 	PodClusterId uint32
+	OrgId        uint16
+	VtapGroupId  string
 }
 
 type Counter struct {
@@ -1400,6 +1403,14 @@ func (t *PlatformInfoTable) QueryVtapEpc0(vtapId uint32) int32 {
 	return datatype.EPC_FROM_INTERNET
 }
 
+// QueryVtapOrgId 查询采集器所属组织，未知采集器归属默认组织
+func (t *PlatformInfoTable) QueryVtapOrgId(vtapId uint32) uint16 {
+	if vtapInfo, ok := t.vtapIdInfos[vtapId]; ok {
+		return vtapInfo.OrgId
+	}
+	return ckdb.DEFAULT_ORG_ID
+}
+
 func (t *PlatformInfoTable) QueryVtapInfo(vtapId uint32) *VtapInfo {
 	if vtapInfo, ok := t.vtapIdInfos[vtapId]; ok {
 		return vtapInfo
@@ -1473,6 +1484,11 @@ func (t *PlatformInfoTable) updateVtapIps(vtapIps []*trident.VtapIp) {
 			EpcId:        epcId,
 			Ip:           vtapIp.GetIp(),
 			PodClusterId: vtapIp.GetPodClusterId(),
+			OrgId:        uint16(vtapIp.GetOrgId()),
+			VtapGroupId:  vtapIp.GetVtapGroupId(),
+		}
+		if vtapIdInfos[vtapIp.GetVtapId()].OrgId == 0 {
+			vtapIdInfos[vtapIp.GetVtapId()].OrgId = ckdb.DEFAULT_ORG_ID
 		}
 	}
 	t.vtapIdInfos = vtapIdInfos
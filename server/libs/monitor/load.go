@@ -29,9 +29,9 @@ type LoadMonitor struct {
 
 func (m *LoadMonitor) GetCounter() interface{} {
 	if loadInfo, err := load.Avg(); err != nil {
-		return []stats.StatItem{stats.StatItem{Name: "load1", Value: 0}}
+		return []stats.StatItem{stats.StatItem{Name: "load1", Value: 0, Type: stats.STAT_TYPE_GAUGE}}
 	} else {
-		return []stats.StatItem{stats.StatItem{Name: "load1", Value: loadInfo.Load1}}
+		return []stats.StatItem{stats.StatItem{Name: "load1", Value: loadInfo.Load1, Type: stats.STAT_TYPE_GAUGE}}
 	}
 }
 
@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package receiver
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type AgentAuthType uint8
+
+const (
+	AGENT_AUTH_NONE  AgentAuthType = iota // TLS only encrypts the data, vtap id in the frame header and the data is trusted, TCP and UDP can be spoofed
+	AGENT_AUTH_CERT                       // vtap id is taken from the common name of the client certificate
+	AGENT_AUTH_TOKEN                      // vtap id is taken from the token frame sent after the TLS handshake
+)
+
+const (
+	TLS_HANDSHAKE_TIMEOUT = 10 * time.Second
+
+	// 令牌认证帧: | magic(4B) "DFAT" | version(1B) | vtap id(2B, big endian) | token length(1B) | token |
+	AUTH_TOKEN_MAGIC        = "DFAT"
+	AUTH_TOKEN_VERSION      = 1
+	AUTH_TOKEN_HEADER_LEN   = 8
+	CERT_COMMON_NAME_PREFIX = "vtap-"
+)
+
+func (t AgentAuthType) String() string {
+	switch t {
+	case AGENT_AUTH_NONE:
+		return "none"
+	case AGENT_AUTH_CERT:
+		return "cert"
+	case AGENT_AUTH_TOKEN:
+		return "token"
+	}
+	return "unknown"
+}
+
+func ParseAgentAuthType(s string) (AgentAuthType, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return AGENT_AUTH_NONE, nil
+	case "cert":
+		return AGENT_AUTH_CERT, nil
+	case "token":
+		return AGENT_AUTH_TOKEN, nil
+	}
+	return AGENT_AUTH_NONE, fmt.Errorf("unknown agent auth type %s, should be one of none, cert and token", s)
+}
+
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // required by AGENT_AUTH_CERT
+	AgentAuth    AgentAuthType
+	TokenSecret  string // required by AGENT_AUTH_TOKEN
+}
+
+// EnableTLS 使 TCP 接收改为 TLS，需在 Start 之前调用. UDP 无法认证，开启采集器认证后丢弃非本机的带 vtap id 的 UDP 数据
+func (r *Receiver) EnableTLS(config *TLSConfig) error {
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load tls certificate failed: %s", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	switch config.AgentAuth {
+	case AGENT_AUTH_CERT:
+		caPEM, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client ca file failed: %s", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificate found in client ca file %s", config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	case AGENT_AUTH_TOKEN:
+		if config.TokenSecret == "" {
+			return errors.New("token secret is required by token agent auth")
+		}
+	}
+
+	r.tlsConfig = tlsConfig
+	r.agentAuth = config.AgentAuth
+	r.tokenSecret = []byte(config.TokenSecret)
+	log.Infof("receiver tcp tls enabled, agent auth: %s", config.AgentAuth)
+	return nil
+}
+
+// GenerateAgentToken 生成采集器认证令牌，令牌与 vtap id 绑定，无法冒用其他采集器的 vtap id
+func GenerateAgentToken(secret string, vtapID uint16) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.Itoa(int(vtapID))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyAgentToken(secret []byte, vtapID uint16, token []byte) bool {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.Itoa(int(vtapID))))
+	expected := make([]byte, hex.EncodedLen(mac.Size()))
+	hex.Encode(expected, mac.Sum(nil))
+	return hmac.Equal(expected, token)
+}
+
+// EncodeAgentToken 编码采集器在 TLS 握手后发送的令牌认证帧
+func EncodeAgentToken(vtapID uint16, token string) []byte {
+	buffer := make([]byte, AUTH_TOKEN_HEADER_LEN, AUTH_TOKEN_HEADER_LEN+len(token))
+	copy(buffer, AUTH_TOKEN_MAGIC)
+	buffer[4] = AUTH_TOKEN_VERSION
+	binary.BigEndian.PutUint16(buffer[5:], vtapID)
+	buffer[7] = byte(len(token))
+	return append(buffer, token...)
+}
+
+func readAgentToken(reader *bufio.Reader, secret []byte) (uint16, error) {
+	header := make([]byte, AUTH_TOKEN_HEADER_LEN)
+	if err := ReadN(reader, header); err != nil {
+		return 0, err
+	}
+	if string(header[:4]) != AUTH_TOKEN_MAGIC {
+		return 0, errors.New("token frame not found")
+	}
+	if header[4] != AUTH_TOKEN_VERSION {
+		return 0, fmt.Errorf("unsupported token frame version %d", header[4])
+	}
+	vtapID := binary.BigEndian.Uint16(header[5:])
+	token := make([]byte, header[7])
+	if err := ReadN(reader, token); err != nil {
+		return 0, err
+	}
+	if !verifyAgentToken(secret, vtapID, token) {
+		return 0, fmt.Errorf("invalid token of vtap %d", vtapID)
+	}
+	return vtapID, nil
+}
+
+// parseCertVtapID 证书的 common name 为 vtap-<id> 或 <id>
+func parseCertVtapID(commonName string) (uint16, error) {
+	vtapID, err := strconv.ParseUint(strings.TrimPrefix(commonName, CERT_COMMON_NAME_PREFIX), 10, 16)
+	if err != nil || vtapID == 0 {
+		return 0, fmt.Errorf("invalid vtap id in certificate common name %s", commonName)
+	}
+	return uint16(vtapID), nil
+}
+
+// authenticate 完成 TLS 握手和采集器认证，返回认证后的连接、读取器及 vtap id（未开启认证时为 0）
+func (r *Receiver) authenticate(conn net.Conn) (net.Conn, *bufio.Reader, uint16, error) {
+	if r.tlsConfig == nil {
+		return conn, bufio.NewReaderSize(conn, r.TCPReaderBuffer), 0, nil
+	}
+	tlsConn := tls.Server(conn, r.tlsConfig)
+	tlsConn.SetDeadline(time.Now().Add(TLS_HANDSHAKE_TIMEOUT))
+	defer tlsConn.SetDeadline(time.Time{})
+	if err := tlsConn.Handshake(); err != nil {
+		atomic.AddUint64(&r.counter.TLSHandshakeFailed, 1)
+		return nil, nil, 0, fmt.Errorf("tls handshake failed: %s", err)
+	}
+	reader := bufio.NewReaderSize(tlsConn, r.TCPReaderBuffer)
+
+	var vtapID uint16
+	var err error
+	switch r.agentAuth {
+	case AGENT_AUTH_CERT:
+		// RequireAndVerifyClientCert 保证了握手成功时有已校验的客户端证书
+		vtapID, err = parseCertVtapID(tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+	case AGENT_AUTH_TOKEN:
+		vtapID, err = readAgentToken(reader, r.tokenSecret)
+	}
+	if err != nil {
+		atomic.AddUint64(&r.counter.RejectedAuthFailed, 1)
+		return nil, nil, 0, err
+	}
+	return tlsConn, reader, vtapID, nil
+}
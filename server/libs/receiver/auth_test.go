@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package receiver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAgentToken(t *testing.T) {
+	secret := "secret"
+	frame := EncodeAgentToken(10, GenerateAgentToken(secret, 10))
+	vtapID, err := readAgentToken(bufio.NewReader(bytes.NewReader(frame)), []byte(secret))
+	if err != nil || vtapID != 10 {
+		t.Errorf("readAgentToken() = %d, %v, expected 10", vtapID, err)
+	}
+
+	for name, frame := range map[string][]byte{
+		"other vtap":   EncodeAgentToken(11, GenerateAgentToken(secret, 10)),
+		"other secret": EncodeAgentToken(10, GenerateAgentToken("other", 10)),
+		"no magic":     append([]byte("XXXX"), frame[4:]...),
+		"truncated":    frame[:len(frame)-1],
+	} {
+		if _, err := readAgentToken(bufio.NewReader(bytes.NewReader(frame)), []byte(secret)); err == nil {
+			t.Errorf("readAgentToken(%s) expected error", name)
+		}
+	}
+}
+
+func TestParseCertVtapID(t *testing.T) {
+	for commonName, expected := range map[string]uint16{"vtap-12": 12, "65535": 65535} {
+		if vtapID, err := parseCertVtapID(commonName); err != nil || vtapID != expected {
+			t.Errorf("parseCertVtapID(%s) = %d, %v, expected %d", commonName, vtapID, err, expected)
+		}
+	}
+	for _, commonName := range []string{"", "vtap-0", "vtap-65536", "agent-1", "vtap-1a"} {
+		if _, err := parseCertVtapID(commonName); err == nil {
+			t.Errorf("parseCertVtapID(%s) expected error", commonName)
+		}
+	}
+}
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	parentCert, parentKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestFile(t *testing.T, name string, content []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// authenticatePipe 通过内存连接完成服务端认证，client 为采集器侧的 TLS 握手及数据发送
+func authenticatePipe(r *Receiver, client func(conn net.Conn) error) (uint16, error) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		defer clientConn.Close()
+		client(clientConn)
+	}()
+	_, _, vtapID, err := r.authenticate(serverConn)
+	return vtapID, err
+}
+
+func TestAuthenticate(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "server", ca)
+	caFile := writeTestFile(t, "ca.crt", ca.certPEM)
+	certFile := writeTestFile(t, "server.crt", server.certPEM)
+	keyFile := writeTestFile(t, "server.key", server.keyPEM)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	clientTLSConfig := func(commonName string) *tls.Config {
+		config := &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}
+		if commonName != "" {
+			c := newTestCert(t, commonName, ca)
+			cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+		return config
+	}
+
+	// cert
+	r := &Receiver{counter: &ReceiverCounter{}, TCPReaderBuffer: 1024}
+	if err := r.EnableTLS(&TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, AgentAuth: AGENT_AUTH_CERT}); err != nil {
+		t.Fatal(err)
+	}
+	vtapID, err := authenticatePipe(r, func(conn net.Conn) error {
+		return tls.Client(conn, clientTLSConfig("vtap-3")).Handshake()
+	})
+	if err != nil || vtapID != 3 {
+		t.Errorf("cert authenticate = %d, %v, expected 3", vtapID, err)
+	}
+	if _, err = authenticatePipe(r, func(conn net.Conn) error {
+		return tls.Client(conn, clientTLSConfig("")).Handshake()
+	}); err == nil || r.counter.TLSHandshakeFailed != 1 {
+		t.Errorf("cert authenticate without client certificate expected handshake failure, err: %v", err)
+	}
+	if _, err = authenticatePipe(r, func(conn net.Conn) error {
+		return tls.Client(conn, clientTLSConfig("agent")).Handshake()
+	}); err == nil || r.counter.RejectedAuthFailed != 1 {
+		t.Errorf("cert authenticate with invalid common name expected auth failure, err: %v", err)
+	}
+
+	// token
+	r = &Receiver{counter: &ReceiverCounter{}, TCPReaderBuffer: 1024}
+	if err := r.EnableTLS(&TLSConfig{CertFile: certFile, KeyFile: keyFile, AgentAuth: AGENT_AUTH_TOKEN, TokenSecret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	for token, expected := range map[string]uint16{GenerateAgentToken("secret", 5): 5, GenerateAgentToken("secret", 6): 0} {
+		vtapID, err = authenticatePipe(r, func(conn net.Conn) error {
+			tlsConn := tls.Client(conn, clientTLSConfig(""))
+			_, err := tlsConn.Write(EncodeAgentToken(5, token))
+			return err
+		})
+		if vtapID != expected || (expected == 0) != (err != nil) {
+			t.Errorf("token authenticate = %d, %v, expected %d", vtapID, err, expected)
+		}
+	}
+	if r.counter.RejectedAuthFailed != 1 {
+		t.Errorf("RejectedAuthFailed = %d, expected 1", r.counter.RejectedAuthFailed)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package receiver
+
+import (
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+)
+
+// IngestionLimiter 限制采集器写入的数据量。receiver 在数据放入队列前调用 Admit，返回 false 时数据
+// 已由 IngestionLimiter 接管（丢弃后释放，或缓存后通过 Receiver.PutBuffer 放入队列）；
+// decoder 解析数据后调用 ConsumeRows 上报行数
+type IngestionLimiter interface {
+	Admit(msgType datatype.MessageType, buffer *RecvBuffer) bool
+	ConsumeRows(msgType datatype.MessageType, vtapID uint16, rows int64)
+}
+
+// decoder 不持有 receiver，通过包级函数 ConsumeRows 上报行数
+var ingestionLimiter IngestionLimiter
+
+func (r *Receiver) SetIngestionLimiter(limiter IngestionLimiter) {
+	r.ingestionLimiter = limiter
+	ingestionLimiter = limiter
+}
+
+// PutBuffer 将 IngestionLimiter 缓存的数据放入队列，可能被多个协程调用，使用 TCP 的队列缓存
+func (r *Receiver) PutBuffer(msgType datatype.MessageType, buffer *RecvBuffer) {
+	handler := r.handlers[msgType]
+	if handler == nil {
+		ReleaseRecvBuffer(buffer)
+		return
+	}
+	r.putTCPQueue(int(buffer.VtapID), handler, buffer)
+}
+
+func (r *Receiver) admit(msgType datatype.MessageType, buffer *RecvBuffer) bool {
+	if r.ingestionLimiter == nil || buffer.VtapID == 0 {
+		return true
+	}
+	return r.ingestionLimiter.Admit(msgType, buffer)
+}
+
+// ConsumeRows 上报 decoder 从采集器数据中解析出的行数，未开启写入限额时忽略
+func ConsumeRows(msgType datatype.MessageType, vtapID uint16, rows int64) {
+	if ingestionLimiter == nil || vtapID == 0 || rows <= 0 {
+		return
+	}
+	ingestionLimiter.ConsumeRows(msgType, vtapID, rows)
+}
@@ -18,6 +18,7 @@ package receiver
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -67,6 +68,9 @@ type RecvBuffer struct {
 	IP         net.IP // 保存消息的发送方IP
 	VtapID     uint16
 	SocketType ServerType
+	// 数据来自已认证采集器的连接，VtapID 为认证的采集器 ID，
+	// 解码时数据中携带的采集器 ID 与之不一致的需丢弃
+	Authenticated bool
 }
 
 // 实现空接口，仅用于队列调试打印
@@ -141,6 +145,7 @@ func ReleaseRecvBuffer(b *RecvBuffer) {
 	b.End = 0
 	b.IP = nil
 	b.VtapID = 0
+	b.Authenticated = false
 	recvBufferPools[getBufferPoolIndex(len(b.Buffer))].Put(b)
 }
 
@@ -391,6 +396,12 @@ type Receiver struct {
 	counter *ReceiverCounter
 
 	status *AdapterStatus
+
+	tlsConfig   *tls.Config
+	agentAuth   AgentAuthType
+	tokenSecret []byte
+
+	ingestionLimiter IngestionLimiter
 }
 
 type ReceiverCounter struct {
@@ -403,6 +414,11 @@ type ReceiverCounter struct {
 	UDPDisorder     uint64 `statsd:"udp_disorder"`      // 乱序个数
 	UDPDisorderSize uint64 `statsd:"udp_disorder_size"` // 乱序最大范围
 	NewBufferCount  uint64 `statsd:"new_buffer_count"`  // If the received data is large, you need to alloc memory, record the times.
+
+	TLSHandshakeFailed      uint64 `statsd:"tls_handshake_failed"`
+	RejectedAuthFailed      uint64 `statsd:"rejected_auth_failed"`     // 证书或令牌中无法得到合法的 vtap id
+	RejectedVtapMismatch    uint64 `statsd:"rejected_vtap_mismatch"`   // 帧头中的 vtap id 与认证的 vtap id 不一致
+	RejectedUnauthenticated uint64 `statsd:"rejected_unauthenticated"` // 开启采集器认证后，UDP 收到的带 vtap id 的帧
 }
 
 func NewReceiver(
@@ -715,6 +731,13 @@ func (r *Receiver) ProcessUDPServer() {
 				}
 			}
 
+			// UDP 无法认证采集器身份，仅接收本机 deepflow-server 自身的统计数据
+			if r.agentAuth != AGENT_AUTH_NONE && !remoteAddr.IP.IsLoopback() {
+				ReleaseRecvBuffer(recvBuffer)
+				atomic.AddUint64(&r.counter.RejectedUnauthenticated, 1)
+				continue
+			}
+
 			vtapID = flowHeader.VTAPID
 			sequence = flowHeader.Sequence
 			if baseHeader.Type == datatype.MESSAGE_TYPE_METRICS {
@@ -737,7 +760,9 @@ func (r *Receiver) ProcessUDPServer() {
 			}
 			recvBuffer.IP = remoteAddr.IP
 			recvBuffer.VtapID = vtapID
-			r.putUDPQueue(int(r.counter.RxPackets), r.handlers[baseHeader.Type], recvBuffer)
+			if r.admit(baseHeader.Type, recvBuffer) {
+				r.putUDPQueue(int(r.counter.RxPackets), r.handlers[baseHeader.Type], recvBuffer)
+			}
 		}
 	}
 }
@@ -820,11 +845,22 @@ func (r *Receiver) handleTCPConnection(conn net.Conn) {
 	defer r.flushPutTCPQueues()
 	ip := parseRemoteIP(conn)
 
+	conn, reader, authVtapID, err := r.authenticate(conn)
+	if err != nil {
+		log.Warningf("TCP client(%s) authenticate failed: %s", ip, err)
+		return
+	}
+	if r.tlsConfig != nil {
+		defer conn.Close()
+	}
+	if authVtapID != 0 {
+		log.Infof("TCP client(%s) authenticated as vtap %d by %s", ip, authVtapID, r.agentAuth)
+	}
+
 	baseHeader := &datatype.BaseHeader{}
 	baseHeaderBuffer := make([]byte, datatype.MESSAGE_HEADER_LEN)
 	flowHeader := &datatype.FlowHeader{}
 	flowHeaderBuffer := make([]byte, datatype.FLOW_HEADER_LEN)
-	reader := bufio.NewReaderSize(conn, r.TCPReaderBuffer)
 	for !r.exit {
 		if err := ReadN(reader, baseHeaderBuffer); err != nil {
 			log.Warningf("TCP client(%s) connection read error.%s", conn.RemoteAddr().String(), err.Error())
@@ -887,6 +923,19 @@ func (r *Receiver) handleTCPConnection(conn net.Conn) {
 			r.logTCPReceiveInvalidData(fmt.Sprintf("TCP client(%s) wrong frame size(%d)", conn.RemoteAddr().String(), baseHeader.FrameSize))
 			return
 		}
+		// 已认证的连接只接收认证的 vtap id 的数据，丢弃冒用其他 vtap id 的帧
+		if r.agentAuth != AGENT_AUTH_NONE && baseHeader.Type.HeaderType() == datatype.HEADER_TYPE_LT_VTAP && vtapID != authVtapID {
+			atomic.AddUint64(&r.counter.RejectedVtapMismatch, 1)
+			if r.timeNow-r.lastTCPLogTime > LOG_INTERVAL {
+				log.Warningf("TCP client(%s) authenticated as vtap %d, but received %s from vtap %d", conn.RemoteAddr().String(), authVtapID, baseHeader.Type, vtapID)
+				r.lastTCPLogTime = r.timeNow
+			}
+			if _, err := reader.Discard(dataLen); err != nil {
+				log.Warningf("TCP client(%s) connection read error.%s", conn.RemoteAddr().String(), err.Error())
+				return
+			}
+			continue
+		}
 		recvBuffer, isNew := AcquireRecvBuffer(dataLen, TCP)
 		if isNew {
 			r.counter.NewBufferCount++
@@ -914,7 +963,10 @@ func (r *Receiver) handleTCPConnection(conn net.Conn) {
 			recvBuffer.End = int(baseHeader.FrameSize) - headerLen
 			recvBuffer.IP = ip
 			recvBuffer.VtapID = vtapID
-			r.putTCPQueue(int(r.counter.RxPackets), r.handlers[baseHeader.Type], recvBuffer)
+			recvBuffer.Authenticated = r.agentAuth != AGENT_AUTH_NONE
+			if r.admit(baseHeader.Type, recvBuffer) {
+				r.putTCPQueue(int(r.counter.RxPackets), r.handlers[baseHeader.Type], recvBuffer)
+			}
 		}
 	}
 }
@@ -18,6 +18,7 @@ package stats
 
 import (
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/deepflowio/deepflow/server/libs/utils"
@@ -27,6 +28,7 @@ type GcMonitor struct {
 	utils.Closable
 
 	lastPauseDuration uint64
+	lastNumGC         uint32
 }
 
 func (t *GcMonitor) GetCounter() interface{} {
@@ -34,9 +36,24 @@ func (t *GcMonitor) GetCounter() interface{} {
 	runtime.ReadMemStats(&memStats)
 	gcDuration := memStats.PauseTotalNs - t.lastPauseDuration
 	t.lastPauseDuration = memStats.PauseTotalNs
-	return []StatItem{{"duration", gcDuration}}
+	numGC := memStats.NumGC - t.lastNumGC
+	t.lastNumGC = memStats.NumGC
+	return []StatItem{
+		{Name: "duration", Value: gcDuration},
+		{Name: "count", Value: numGC},
+		{Name: "goroutines", Value: runtime.NumGoroutine(), Type: STAT_TYPE_GAUGE},
+		{Name: "heap_alloc", Value: memStats.HeapAlloc, Type: STAT_TYPE_GAUGE},
+		{Name: "heap_inuse", Value: memStats.HeapInuse, Type: STAT_TYPE_GAUGE},
+		{Name: "heap_objects", Value: memStats.HeapObjects, Type: STAT_TYPE_GAUGE},
+		{Name: "sys", Value: memStats.Sys, Type: STAT_TYPE_GAUGE},
+	}
 }
 
+var gcMonitorOnce sync.Once
+
+// controller、ingester、querier 运行在同一进程中，只需注册一次
 func RegisterGcMonitor() {
-	registerCountable("", "gc", &GcMonitor{}, OptionInterval(time.Second))
+	gcMonitorOnce.Do(func() {
+		registerCountable("", "gc", &GcMonitor{}, OptionInterval(time.Second))
+	})
 }
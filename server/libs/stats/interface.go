@@ -36,6 +36,16 @@ const (
 	TICK_CYCLE = 10 * time.Second
 )
 
+type StatType uint8
+
+const (
+	// GetCounter 返回的是两次读取之间的增量，默认类型
+	STAT_TYPE_COUNTER StatType = iota
+	// GetCounter 返回的是瞬时值，如队列长度、内存占用
+	// 结构体字段通过 `statsd:"name,gauge"` 指定
+	STAT_TYPE_GAUGE
+)
+
 type Option = interface{}
 type OptionStatTags = map[string]string
 type OptionInterval time.Duration // must be time.Second, time.Minute or time.Hour
@@ -0,0 +1,286 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+const (
+	PROMETHEUS_TEXT_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+	OPENMETRICS_CONTENT_TYPE     = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Countable.GetCounter 读取后会清零，不能在 scrape 时直接调用，
+// 因此在每个 TICK_CYCLE 收集数据时同时更新此处的值：counter 累加增量，gauge 保存最新值
+type promSource struct {
+	name       string
+	module     string
+	tags       map[string]string
+	values     map[string]*promValue
+	generation uint64
+}
+
+type promValue struct {
+	isGauge bool
+	value   float64
+}
+
+var (
+	promLock       sync.Mutex
+	promSources    = make(map[*StatSource]*promSource)
+	promGeneration uint64 // 仅在持有 lock 时修改
+)
+
+// 未显式指定类型时，平均值、最大值、最小值按 gauge 处理，累加没有意义
+func isGaugeField(statsOpts []string) bool {
+	for _, opt := range statsOpts[1:] {
+		switch opt {
+		case "gauge":
+			return true
+		case "counter", "count":
+			return false
+		}
+	}
+	name := statsOpts[0]
+	return strings.HasSuffix(name, "_avg") || strings.HasSuffix(name, "_max") || strings.HasSuffix(name, "_min")
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func promKeep(source *StatSource) {
+	promLock.Lock()
+	if s, ok := promSources[source]; ok {
+		s.generation = promGeneration
+	}
+	promLock.Unlock()
+}
+
+func promUpdate(source *StatSource, name string, fields models.Fields, gauges map[string]bool) {
+	promLock.Lock()
+	defer promLock.Unlock()
+	s, ok := promSources[source]
+	if !ok {
+		s = &promSource{values: make(map[string]*promValue)}
+		promSources[source] = s
+	}
+	s.name = name
+	s.module = source.modulePrefix + source.module
+	s.generation = promGeneration
+	// hostname 可能被修改，每次都复制一份
+	s.tags = make(map[string]string, len(source.tags))
+	for k, v := range source.tags {
+		s.tags[k] = v
+	}
+	for field, value := range fields {
+		v, ok := toFloat64(value)
+		if !ok {
+			continue
+		}
+		pv, ok := s.values[field]
+		if !ok {
+			pv = &promValue{}
+			s.values[field] = pv
+		}
+		pv.isGauge = gauges[field]
+		if pv.isGauge {
+			pv.value = v
+		} else {
+			pv.value += v
+		}
+	}
+}
+
+// 已关闭或被替换的 Countable 不再输出
+func promRemoveStale() {
+	promLock.Lock()
+	for source, s := range promSources {
+		if s.generation != promGeneration {
+			delete(promSources, source)
+		}
+	}
+	promLock.Unlock()
+}
+
+func sanitizeMetricName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || c >= '0' && c <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func sanitizeLabelName(name string) string {
+	return strings.ReplaceAll(sanitizeMetricName(name), ":", "_")
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type promSample struct {
+	labels string
+	value  float64
+}
+
+type promFamily struct {
+	isGauge bool
+	samples []promSample
+}
+
+func renderLabels(module string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if sanitizeLabelName(k) != "module" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	sb := strings.Builder{}
+	sb.WriteString(`{module="`)
+	sb.WriteString(labelValueReplacer.Replace(module))
+	sb.WriteByte('"')
+	for _, k := range keys {
+		sb.WriteByte(',')
+		sb.WriteString(sanitizeLabelName(k))
+		sb.WriteString(`="`)
+		sb.WriteString(labelValueReplacer.Replace(tags[k]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func collectPromFamilies() map[string]*promFamily {
+	families := make(map[string]*promFamily)
+	promLock.Lock()
+	defer promLock.Unlock()
+	for _, s := range promSources {
+		labels := renderLabels(s.module, s.tags)
+		for field, v := range s.values {
+			name := sanitizeMetricName(s.name + "_" + field)
+			// counter 统一以 _total 结尾，保证两种格式下 Prometheus 存储的指标名一致
+			if !v.isGauge && !strings.HasSuffix(name, "_total") {
+				name += "_total"
+			}
+			family, ok := families[name]
+			if !ok {
+				family = &promFamily{isGauge: v.isGauge}
+				families[name] = family
+			}
+			family.samples = append(family.samples, promSample{labels, v.value})
+		}
+	}
+	return families
+}
+
+// WritePrometheus 以 Prometheus 文本格式或 OpenMetrics 格式输出所有已注册的 Countable
+func WritePrometheus(w io.Writer, openMetrics bool) error {
+	families := collectPromFamilies()
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		family := families[name]
+		typeName, familyName := "counter", name
+		if family.isGauge {
+			typeName = "gauge"
+		} else if openMetrics {
+			// OpenMetrics 中 counter 的 family 名不带 _total 后缀
+			familyName = strings.TrimSuffix(name, "_total")
+		}
+		bw.WriteString("# TYPE ")
+		bw.WriteString(familyName)
+		bw.WriteByte(' ')
+		bw.WriteString(typeName)
+		bw.WriteByte('\n')
+		sort.Slice(family.samples, func(i, j int) bool {
+			return family.samples[i].labels < family.samples[j].labels
+		})
+		for _, sample := range family.samples {
+			bw.WriteString(name)
+			bw.WriteString(sample.labels)
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatFloat(sample.value, 'g', -1, 64))
+			bw.WriteByte('\n')
+		}
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// PrometheusHandler 用于 Prometheus 拉取 server 自监控指标，请求头 Accept 中包含 OpenMetrics 时以 OpenMetrics 格式输出
+func PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+		if openMetrics {
+			w.Header().Set("Content-Type", OPENMETRICS_CONTENT_TYPE)
+		} else {
+			w.Header().Set("Content-Type", PROMETHEUS_TEXT_CONTENT_TYPE)
+		}
+		if err := WritePrometheus(w, openMetrics); err != nil {
+			log.Warningf("write prometheus metrics failed: %s", err)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type testCounter struct {
+	Rx      uint64  `statsd:"rx"`
+	Pending uint64  `statsd:"pending,gauge"`
+	TimeAvg float64 `statsd:"time_avg"`
+	Ignored uint64
+}
+
+type testCountable struct {
+	counter testCounter
+	closed  bool
+}
+
+func (c *testCountable) GetCounter() interface{} {
+	counter := c.counter
+	c.counter = testCounter{}
+	return &counter
+}
+
+func (c *testCountable) Closed() bool {
+	return c.closed
+}
+
+func TestWritePrometheus(t *testing.T) {
+	processName = "deepflow-server"
+	hostname = "node1"
+	countable := &testCountable{}
+	registerCountable("ingester_", "queue", countable, OptionStatTags{"index": "0", "bad-key": "a\"b"})
+
+	countable.counter = testCounter{Rx: 10, Pending: 5, TimeAvg: 1.5, Ignored: 3}
+	collectBatchPoints()
+	countable.counter = testCounter{Rx: 20, Pending: 2, TimeAvg: 2.5}
+	collectBatchPoints()
+
+	buf := &bytes.Buffer{}
+	WritePrometheus(buf, false)
+	labels := `{module="ingester_queue",bad_key="a\"b",host="node1",index="0"}`
+	for _, expected := range []string{
+		"# TYPE deepflow_server_ingester_queue_rx_total counter\n",
+		"deepflow_server_ingester_queue_rx_total" + labels + " 30\n",
+		"# TYPE deepflow_server_ingester_queue_pending gauge\n",
+		"deepflow_server_ingester_queue_pending" + labels + " 2\n",
+		"deepflow_server_ingester_queue_time_avg" + labels + " 2.5\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "ignored") {
+		t.Errorf("field without statsd tag should be ignored:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	WritePrometheus(buf, true)
+	if !strings.Contains(buf.String(), "# TYPE deepflow_server_ingester_queue_rx counter\n") || !strings.HasSuffix(buf.String(), "# EOF\n") {
+		t.Errorf("unexpected openmetrics output:\n%s", buf.String())
+	}
+
+	countable.closed = true
+	collectBatchPoints()
+	buf.Reset()
+	WritePrometheus(buf, false)
+	if strings.Contains(buf.String(), "ingester_queue") {
+		t.Errorf("closed countable should be removed:\n%s", buf.String())
+	}
+}
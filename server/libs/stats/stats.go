@@ -78,6 +78,7 @@ var (
 type StatItem struct {
 	Name  string
 	Value interface{}
+	Type  StatType
 }
 
 func registerCountable(modulePrefix, module string, countable Countable, opts ...Option) error {
@@ -117,8 +118,16 @@ func registerCountable(modulePrefix, module string, countable Countable, opts ..
 	return nil
 }
 
-func counterToFields(counter interface{}) models.Fields {
+// 返回的 gauges 为瞬时值字段，其余字段均为两次读取之间的增量
+func counterToFields(counter interface{}) (models.Fields, map[string]bool) {
 	fields := models.Fields{}
+	var gauges map[string]bool
+	addGauge := func(name string) {
+		if gauges == nil {
+			gauges = make(map[string]bool)
+		}
+		gauges[name] = true
+	}
 	if items, ok := counter.([]StatItem); ok {
 		for _, item := range items {
 			switch item.Value.(type) {
@@ -127,6 +136,9 @@ func counterToFields(counter interface{}) models.Fields {
 			default:
 				fields[item.Name] = item.Value
 			}
+			if item.Type == STAT_TYPE_GAUGE {
+				addGauge(item.Name)
+			}
 		}
 	} else {
 		val := reflect.Indirect(reflect.ValueOf(counter))
@@ -146,9 +158,12 @@ func counterToFields(counter interface{}) models.Fields {
 			default:
 				fields[statsOpts[0]] = val.Field(i).Interface()
 			}
+			if isGaugeField(statsOpts) {
+				addGauge(statsOpts[0])
+			}
 		}
 	}
-	return fields
+	return fields, gauges
 }
 
 func collectBatchPoints() client.BatchPoints {
@@ -158,8 +173,10 @@ func collectBatchPoints() client.BatchPoints {
 	statSources.Remove(func(x interface{}) bool {
 		return x.(*StatSource).countable.Closed()
 	})
+	promGeneration++
 	for it := statSources.Iterator(); !it.Empty(); it.Next() {
 		statSource := it.Value().(*StatSource)
+		promKeep(statSource)
 		max := func(x, y time.Duration) time.Duration {
 			if x > y {
 				return x
@@ -173,10 +190,13 @@ func collectBatchPoints() client.BatchPoints {
 		}
 		statSource.skip = int(max(statSource.interval, MinInterval) / TICK_CYCLE)
 
-		fields := counterToFields(statSource.countable.GetCounter())
-		point, _ := client.NewPoint(processName+processNameJoiner+statSource.modulePrefix+statSource.module, statSource.tags, fields, timestamp)
+		fields, gauges := counterToFields(statSource.countable.GetCounter())
+		name := processName + processNameJoiner + statSource.modulePrefix + statSource.module
+		promUpdate(statSource, name, fields, gauges)
+		point, _ := client.NewPoint(name, statSource.tags, fields, timestamp)
 		bp.AddPoint(point)
 	}
+	promRemoveStale()
 	lock.Unlock()
 	return bp
 }
@@ -51,9 +51,11 @@ type CacheKeyGenerator struct {
 }
 
 // generate key without query time (start/end) for cache query
+// org id is part of the key, so that results will not be shared between orgs
 func (k *CacheKeyGenerator) GenerateCacheKey(req *model.DeepFlowPromRequest) string {
 	return fmt.Sprintf(
-		"df:%s:%d:%d:%d:%s",
+		"df:%d:%s:%d:%d:%d:%s",
+		req.OrgID,
 		req.Query,
 		req.Step,
 		req.Start%int64(req.Step.Seconds()), // real interval for data
@@ -28,6 +28,7 @@ type Prometheus struct {
 	ThanosReplicaLabels     []string        `yaml:"thanos-replica-labels"`
 	OperatorOffloading      bool            `default:"false" yaml:"operator-offloading"`
 	Cache                   PrometheusCache `yaml:"cache"`
+	QuerySplit              QuerySplit      `yaml:"query-split"`
 }
 
 type PrometheusCache struct {
@@ -38,3 +39,11 @@ type PrometheusCache struct {
 	CacheFirstTimeout  int    `default:"10" yaml:"cache-first-timeout"`    // time out for first cache item load, unit: s, default: 10s
 	CacheCleanInterval int    `default:"3600" yaml:"cache-clean-interval"` // clean interval for cache, unit: s, default: 1h
 }
+
+type QuerySplit struct {
+	Enabled        bool `default:"false" yaml:"enabled"`
+	SplitInterval  int  `default:"86400" yaml:"split-interval"` // split range query by time interval, aligned to step, unit: s, default: 1d
+	SeriesShards   int  `default:"1" yaml:"series-shards"`      // shards by series for sum/count/avg/min/max/group of prometheus metrics, 1 means no sharding
+	MaxParallelism int  `default:"4" yaml:"max-parallelism"`    // max concurrent sub queries for one query
+	CompleteDelay  int  `default:"300" yaml:"complete-delay"`   // interval ended before now - complete-delay is completed and cached by response cache, unit: s
+}
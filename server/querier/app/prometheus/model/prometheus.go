@@ -83,4 +83,5 @@ type DeepFlowPromRequest struct {
 	Step     time.Duration
 	Query    string
 	Matchers []string
+	OrgID    uint16
 }
@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// PromQL extension functions for anomaly detection, equivalent to ZScore/MADScore/Changepoint in DeepFlow SQL.
+// They take a range vector and score the LAST point against the rest of the range, i.e.:
+// zscore_over_time(rate(http_requests_total[1m])[1h:1m])
+// seasonal baselines need no extension, use `offset`, i.e.: avg_over_time(x[1h] offset 1w)
+var anomalyFunctions = map[string]func([]promql.Point) float64{
+	"zscore_over_time":      zScore,
+	"mad_score_over_time":   madScore,
+	"changepoint_over_time": changepointScore,
+}
+
+var registerAnomalyFunctionsOnce sync.Once
+
+// RegisterAnomalyFunctions adds the anomaly functions to the global function tables of the prometheus parser and engine,
+// it must be called at querier startup before any PromQL is parsed, calling it more than once has no effect
+func RegisterAnomalyFunctions() {
+	registerAnomalyFunctionsOnce.Do(func() {
+		for name, fn := range anomalyFunctions {
+			parser.Functions[name] = &parser.Function{
+				Name:       name,
+				ArgTypes:   []parser.ValueType{parser.ValueTypeMatrix},
+				ReturnType: parser.ValueTypeVector,
+			}
+			promql.FunctionCalls[name] = anomalyFunctionCall(fn)
+		}
+	})
+}
+
+func anomalyFunctionCall(fn func([]promql.Point) float64) promql.FunctionCall {
+	return func(vals []parser.Value, args parser.Expressions, enh *promql.EvalNodeHelper) promql.Vector {
+		points := vals[0].(promql.Matrix)[0].Points
+		score := fn(points)
+		if math.IsNaN(score) {
+			return enh.Out
+		}
+		return append(enh.Out, promql.Sample{Point: promql.Point{V: score}})
+	}
+}
+
+func meanStddev(points []promql.Point) (float64, float64) {
+	var sum, sqSum float64
+	for _, p := range points {
+		sum += p.V
+	}
+	mean := sum / float64(len(points))
+	for _, p := range points {
+		sqSum += (p.V - mean) * (p.V - mean)
+	}
+	return mean, math.Sqrt(sqSum / float64(len(points)))
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// (last - mean(history)) / stddev(history)
+func zScore(points []promql.Point) float64 {
+	if len(points) < 3 {
+		return math.NaN()
+	}
+	last := points[len(points)-1].V
+	mean, stddev := meanStddev(points[:len(points)-1])
+	if stddev == 0 {
+		return math.NaN()
+	}
+	return (last - mean) / stddev
+}
+
+// (last - median(history)) / (1.4826 * MAD(history)), 1.4826 makes it consistent with z-score for normal distribution
+func madScore(points []promql.Point) float64 {
+	if len(points) < 3 {
+		return math.NaN()
+	}
+	last := points[len(points)-1].V
+	history := make([]float64, 0, len(points)-1)
+	for _, p := range points[:len(points)-1] {
+		history = append(history, p.V)
+	}
+	m := median(history)
+	deviations := make([]float64, 0, len(history))
+	for _, v := range history {
+		deviations = append(deviations, math.Abs(v-m))
+	}
+	mad := median(deviations)
+	if mad == 0 {
+		return math.NaN()
+	}
+	return (last - m) / (1.4826 * mad)
+}
+
+// |mean(second half) - mean(first half)| / stddev(all), a larger score means a level shift around the middle of the range
+func changepointScore(points []promql.Point) float64 {
+	if len(points) < 4 {
+		return math.NaN()
+	}
+	half := len(points) / 2
+	before, _ := meanStddev(points[:half])
+	after, _ := meanStddev(points[half:])
+	_, stddev := meanStddev(points)
+	if stddev == 0 {
+		return math.NaN()
+	}
+	return math.Abs(after-before) / stddev
+}
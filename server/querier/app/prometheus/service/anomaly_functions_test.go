@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func toPoints(values ...float64) []promql.Point {
+	points := make([]promql.Point, 0, len(values))
+	for i, v := range values {
+		points = append(points, promql.Point{T: int64(i) * 1000, V: v})
+	}
+	return points
+}
+
+func TestAnomalyFunctions(t *testing.T) {
+	// normal series: last point in line with history
+	assert.InDelta(t, 0, zScore(toPoints(1, 2, 3, 2, 1, 2, 3, 2)), 0.5)
+	assert.True(t, zScore(toPoints(10, 11, 9, 10, 11, 9, 10, 50)) > 3)
+	assert.True(t, math.IsNaN(zScore(toPoints(1, 1, 1, 1))))
+
+	assert.True(t, madScore(toPoints(10, 11, 9, 10, 11, 9, 10, 50)) > 3)
+	assert.InDelta(t, 0, madScore(toPoints(10, 11, 9, 10, 11, 9, 10, 10)), 0.01)
+
+	assert.True(t, changepointScore(toPoints(1, 1, 1, 1, 10, 10, 10, 10)) > 1.5)
+	assert.InDelta(t, 0, changepointScore(toPoints(1, 2, 1, 2, 1, 2, 1, 2)), 0.01)
+
+	for name := range anomalyFunctions {
+		_, err := parser.ParseExpr(name + "(up[1h])")
+		assert.NotNil(t, err, name)
+	}
+	RegisterAnomalyFunctions()
+	RegisterAnomalyFunctions()
+	for name := range anomalyFunctions {
+		_, err := parser.ParseExpr(name + "(up[1h])")
+		assert.Nil(t, err, name)
+	}
+}
@@ -346,6 +346,11 @@ func (p *prometheusReader) promReaderTransToSQL(ctx context.Context, req *prompb
 		}
 	}
 
+	// query one shard of series when executed by query splitter, see query_split.go
+	if shard, ok := ctx.Value(ctxKeySeriesShard{}).(seriesShard); ok && (db == "" || db == chCommon.DB_NAME_PROMETHEUS) {
+		filters = append(filters, shard.filter())
+	}
+
 	// append query field: 4. append DeepFlow native tags for Prometheus metrics
 	for tagName, tagAlias := range expectedDeepFlowNativeTags {
 		// reduce Prometheus query DeepFlow tags
@@ -992,6 +997,9 @@ func (p *prometheusReader) parseQueryRequestToSQL(ctx context.Context, queryReq
 			expectedQueryTags[tagName] = tagAlias
 		}
 	}
+	if shard, ok := ctx.Value(ctxKeySeriesShard{}).(seriesShard); ok {
+		filters = append(filters, shard.filter())
+	}
 
 	// order
 	orderBy := []string{fmt.Sprintf("%s desc", PROMETHEUS_TIME_COLUMNS)}
@@ -40,6 +40,7 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/lru"
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/cache"
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/common"
 	"github.com/deepflowio/deepflow/server/querier/config"
 	chCommon "github.com/deepflowio/deepflow/server/querier/engine/clickhouse/common"
 	tagdescription "github.com/deepflowio/deepflow/server/querier/engine/clickhouse/tag"
@@ -263,11 +264,14 @@ func (p *prometheusExecutor) offloadRangeQueryExecute(ctx context.Context, args
 		End:    end.UnixMilli(),
 		Step:   step,
 		Query:  args.Promql,
+		OrgID:  common.GetOrgID(ctx),
 	}
 
 	var cached promql.Result
 	var cachedKey string
-	if config.Cfg.Prometheus.Cache.ResponseCache {
+	// sub query of query splitter caches completed intervals by itself
+	responseCache := config.Cfg.Prometheus.Cache.ResponseCache && ctx.Value(ctxKeySplitQuery{}) == nil
+	if responseCache {
 		cachedKey = p.cacheKeyGenerator.GenerateCacheKey(promRequest)
 		var fixedStart, fixedEnd int64
 		queryRequired := true
@@ -337,7 +341,7 @@ func (p *prometheusExecutor) offloadRangeQueryExecute(ctx context.Context, args
 		result.Stats = queriable.GetSQLQuery()
 	}
 
-	if config.Cfg.Prometheus.Cache.ResponseCache {
+	if responseCache {
 		if mergeResult, err := p.cacher.Merge(cachedKey, promRequest.Start, promRequest.End, promRequest.Step.Microseconds(), *res); err == nil {
 			result.Data = &model.PromQueryData{ResultType: mergeResult.Value.Type(), Result: mergeResult.Value}
 		} else {
@@ -397,6 +401,7 @@ func (p *prometheusExecutor) offloadInstantQueryExecute(ctx context.Context, arg
 		End:    maxEnd,
 		Step:   1 * time.Second,
 		Query:  args.Promql,
+		OrgID:  common.GetOrgID(ctx),
 	}
 
 	var cached promql.Result
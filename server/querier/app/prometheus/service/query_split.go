@@ -0,0 +1,565 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/cache"
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	chCommon "github.com/deepflowio/deepflow/server/querier/engine/clickhouse/common"
+)
+
+// a range query is split into sub queries by time interval and by shards of series,
+// sub queries are executed concurrently and their results are merged:
+// - time interval: boundaries are aligned to absolute time on the step grid, so completed intervals can be reused
+//   from response cache when the query window slides
+// - series shard: for sum/count/avg/min/max/group of Prometheus metrics, each shard only queries the series whose
+//   target_id%count=index, partial aggregations of shards are merged by the aggregate operator
+
+// mark sub query executed by query splitter, sub query will not use response cache of the whole query
+type ctxKeySplitQuery struct{}
+
+// shard of series queried by sub query, append as `series_shard(count, index)` filter in querier sql
+type ctxKeySeriesShard struct{}
+
+type seriesShard struct {
+	index int
+	count int
+}
+
+func (s seriesShard) filter() string {
+	return fmt.Sprintf("series_shard(%d, %d)", s.count, s.index)
+}
+
+// timeRange is [start, end] in milliseconds, start is on the step grid of query
+type timeRange struct {
+	start int64
+	end   int64
+	// interval start aligned to absolute time, used as cache key
+	intervalStart int64
+	// range covers the whole interval
+	full bool
+}
+
+// splitTimeRange split [start, end] by interval, interval is rounded up to multiple of step
+func splitTimeRange(start, end, step, interval int64) []timeRange {
+	if step <= 0 || interval <= 0 || end-start < interval {
+		return []timeRange{{start: start, end: end}}
+	}
+	interval = (interval + step - 1) / step * step
+	offset := start % step
+	ranges := make([]timeRange, 0, (end-start)/interval+2)
+	for s := start; s <= end; {
+		intervalStart := (s-offset)/interval*interval + offset
+		next := intervalStart + interval
+		r := timeRange{start: s, end: next - step, intervalStart: intervalStart, full: s == intervalStart}
+		if r.end > end {
+			r.end = end
+			r.full = false
+		}
+		ranges = append(ranges, r)
+		s = next
+	}
+	return ranges
+}
+
+// shardPlan is the plan of executing an aggregation by series shards
+type shardPlan struct {
+	op parser.ItemType
+	// queries executed for each shard, avg is executed as sum and count
+	queries []string
+}
+
+var shardableAggregateOps = map[parser.ItemType]bool{
+	parser.SUM:   true,
+	parser.COUNT: true,
+	parser.AVG:   true,
+	parser.MIN:   true,
+	parser.MAX:   true,
+	parser.GROUP: true,
+}
+
+// the result of these functions does not depend on the series of the shard,
+// every shard would return the same series and the merged result is wrong
+var unshardableFunctions = map[string]bool{
+	"absent":           true,
+	"absent_over_time": true,
+	"vector":           true,
+	"scalar":           true,
+}
+
+func unwrapParenExpr(expr parser.Expr) parser.Expr {
+	for {
+		paren, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.Expr
+	}
+}
+
+func selectorMetricName(vs *parser.VectorSelector) string {
+	if vs.Name != "" {
+		return vs.Name
+	}
+	for _, matcher := range vs.LabelMatchers {
+		if matcher.Name == labels.MetricName && matcher.Type == labels.MatchEqual {
+			return matcher.Value
+		}
+	}
+	return ""
+}
+
+// isPrometheusMetric only Prometheus metrics in `prometheus`.`samples` have target_id for sharding
+func isPrometheusMetric(metric string) bool {
+	if metric == "" {
+		return false
+	}
+	return !strings.Contains(metric, "__") || strings.HasPrefix(metric, chCommon.DB_NAME_PROMETHEUS+"__")
+}
+
+// planSeriesShards returns nil when query can not be executed by series shards:
+// the outermost expression should be sum/count/avg/min/max/group, and the aggregated expression should keep
+// series independent, i.e. no nested aggregation, no binary operation between vectors
+// and no absent/absent_over_time/vector/scalar call
+func planSeriesShards(query string, shards int) *shardPlan {
+	if shards <= 1 {
+		return nil
+	}
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return nil
+	}
+	agg, ok := unwrapParenExpr(expr).(*parser.AggregateExpr)
+	if !ok || !shardableAggregateOps[agg.Op] {
+		return nil
+	}
+	shardable := true
+	parser.Inspect(agg.Expr, func(node parser.Node, path []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.AggregateExpr:
+			shardable = false
+		case *parser.BinaryExpr:
+			if n.LHS.Type() == parser.ValueTypeVector && n.RHS.Type() == parser.ValueTypeVector {
+				shardable = false
+			}
+		case *parser.Call:
+			if unshardableFunctions[n.Func.Name] {
+				shardable = false
+			}
+		case *parser.VectorSelector:
+			if !isPrometheusMetric(selectorMetricName(n)) {
+				shardable = false
+			}
+		}
+		return nil
+	})
+	if !shardable {
+		return nil
+	}
+	plan := &shardPlan{op: agg.Op}
+	if agg.Op == parser.AVG {
+		for _, op := range []parser.ItemType{parser.SUM, parser.COUNT} {
+			variant := *agg
+			variant.Op = op
+			plan.queries = append(plan.queries, variant.String())
+		}
+	} else {
+		plan.queries = []string{agg.String()}
+	}
+	return plan
+}
+
+// timeSplittable @ modifier with start()/end() or timestamp depends on the whole query range
+func timeSplittable(query string) bool {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return false
+	}
+	splittable := true
+	parser.Inspect(expr, func(node parser.Node, path []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			if n.Timestamp != nil || n.StartOrEnd != 0 {
+				splittable = false
+			}
+		case *parser.SubqueryExpr:
+			if n.Timestamp != nil || n.StartOrEnd != 0 {
+				splittable = false
+			}
+		}
+		return nil
+	})
+	return splittable
+}
+
+func seriesKey(metric labels.Labels) string {
+	return metric.String()
+}
+
+func sortMatrix(matrix promql.Matrix) promql.Matrix {
+	sort.Slice(matrix, func(i, j int) bool {
+		return labels.Compare(matrix[i].Metric, matrix[j].Metric) < 0
+	})
+	return matrix
+}
+
+// mergeShards merge partial aggregations of all shards with the aggregate operator
+func mergeShards(op parser.ItemType, shards []promql.Matrix) promql.Matrix {
+	keyToSeries := map[string]map[int64]float64{}
+	keyToMetric := map[string]labels.Labels{}
+	for _, matrix := range shards {
+		for _, series := range matrix {
+			key := seriesKey(series.Metric)
+			points, ok := keyToSeries[key]
+			if !ok {
+				points = make(map[int64]float64, len(series.Points))
+				keyToSeries[key] = points
+				keyToMetric[key] = series.Metric
+			}
+			for _, point := range series.Points {
+				value, ok := points[point.T]
+				if !ok {
+					points[point.T] = point.V
+					continue
+				}
+				switch op {
+				case parser.SUM, parser.COUNT:
+					points[point.T] = value + point.V
+				case parser.MIN:
+					points[point.T] = math.Min(value, point.V)
+				case parser.MAX:
+					points[point.T] = math.Max(value, point.V)
+				}
+			}
+		}
+	}
+	matrix := make(promql.Matrix, 0, len(keyToSeries))
+	for key, points := range keyToSeries {
+		matrix = append(matrix, promql.Series{Metric: keyToMetric[key], Points: sortedPoints(points)})
+	}
+	return sortMatrix(matrix)
+}
+
+func sortedPoints(points map[int64]float64) []promql.Point {
+	sorted := make([]promql.Point, 0, len(points))
+	for t, v := range points {
+		sorted = append(sorted, promql.Point{T: t, V: v})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].T < sorted[j].T })
+	return sorted
+}
+
+// divideMatrix calculate avg by sum / count of the same series and timestamp
+func divideMatrix(sum, count promql.Matrix) promql.Matrix {
+	keyToCount := make(map[string]map[int64]float64, len(count))
+	for _, series := range count {
+		points := make(map[int64]float64, len(series.Points))
+		for _, point := range series.Points {
+			points[point.T] = point.V
+		}
+		keyToCount[seriesKey(series.Metric)] = points
+	}
+	matrix := make(promql.Matrix, 0, len(sum))
+	for _, series := range sum {
+		counts := keyToCount[seriesKey(series.Metric)]
+		avg := promql.Series{Metric: series.Metric, Points: make([]promql.Point, 0, len(series.Points))}
+		for _, point := range series.Points {
+			if c, ok := counts[point.T]; ok && c > 0 {
+				avg.Points = append(avg.Points, promql.Point{T: point.T, V: point.V / c})
+			}
+		}
+		if len(avg.Points) > 0 {
+			matrix = append(matrix, avg)
+		}
+	}
+	return matrix
+}
+
+// concatMatrices concat results of time ranges in time order
+func concatMatrices(ranges []promql.Matrix) promql.Matrix {
+	keyToSeries := map[string]*promql.Series{}
+	for _, matrix := range ranges {
+		for _, series := range matrix {
+			key := seriesKey(series.Metric)
+			s, ok := keyToSeries[key]
+			if !ok {
+				s = &promql.Series{Metric: series.Metric}
+				keyToSeries[key] = s
+			}
+			s.Points = append(s.Points, series.Points...)
+		}
+	}
+	matrix := make(promql.Matrix, 0, len(keyToSeries))
+	for _, series := range keyToSeries {
+		matrix = append(matrix, *series)
+	}
+	return sortMatrix(matrix)
+}
+
+func formatSplitTime(ms int64) string {
+	return strconv.FormatFloat(float64(ms)/1e3, 'f', 3, 64)
+}
+
+type subQueryExecutor func(ctx context.Context, args *model.PromQueryParams, engine *promql.Engine) (*model.PromQueryResponse, error)
+
+// splitRangeQueryExecute returns split = false when query needs neither time splitting nor series sharding
+func (p *prometheusExecutor) splitRangeQueryExecute(ctx context.Context, args *model.PromQueryParams, engine *promql.Engine) (result *model.PromQueryResponse, split bool, err error) {
+	splitConfig := config.Cfg.Prometheus.QuerySplit
+	start, err := parseTime(args.StartTime)
+	if err != nil {
+		return nil, false, nil
+	}
+	end, err := parseTime(args.EndTime)
+	if err != nil {
+		return nil, false, nil
+	}
+	step, err := parseDuration(args.Step)
+	if err != nil || step <= 0 {
+		return nil, false, nil
+	}
+	startMs, endMs, stepMs := start.UnixMilli(), end.UnixMilli(), step.Milliseconds()
+
+	ranges := []timeRange{{start: startMs, end: endMs}}
+	if timeSplittable(args.Promql) {
+		ranges = splitTimeRange(startMs, endMs, stepMs, int64(splitConfig.SplitInterval)*1e3)
+	}
+	plan := planSeriesShards(args.Promql, splitConfig.SeriesShards)
+	if len(ranges) <= 1 && plan == nil {
+		return nil, false, nil
+	}
+
+	execute := subQueryExecutor(p.promQueryRangeExecute)
+	if args.Offloading {
+		execute = p.offloadRangeQueryExecute
+	}
+	splitter := &querySplitter{
+		args:        args,
+		engine:      engine,
+		execute:     execute,
+		step:        step,
+		plan:        plan,
+		parallelism: splitConfig.MaxParallelism,
+	}
+	if config.Cfg.Prometheus.Cache.ResponseCache {
+		splitter.cacher = p.cacher
+		splitter.cacheKey = p.cacheKeyGenerator.GenerateCacheKey(&model.DeepFlowPromRequest{
+			Slimit: args.Slimit,
+			Start:  startMs,
+			End:    endMs,
+			Step:   step,
+			Query:  args.Promql,
+			OrgID:  common.GetOrgID(ctx),
+		})
+		splitter.completedBefore = time.Now().Add(-time.Duration(splitConfig.CompleteDelay) * time.Second).UnixMilli()
+	}
+	matrix, stats, err := splitter.run(ctx, ranges)
+	if err != nil {
+		log.Error(err)
+		return nil, true, err
+	}
+	result = &model.PromQueryResponse{
+		Data:   &model.PromQueryData{ResultType: parser.ValueTypeMatrix, Result: matrix},
+		Status: _SUCCESS,
+	}
+	if args.Debug {
+		result.Stats = stats
+	}
+	return result, true, nil
+}
+
+type querySplitter struct {
+	args        *model.PromQueryParams
+	engine      *promql.Engine
+	execute     subQueryExecutor
+	plan        *shardPlan
+	parallelism int
+
+	step            time.Duration
+	cacher          *cache.Cacher
+	cacheKey        string
+	completedBefore int64
+
+	statsLock sync.Mutex
+	stats     []model.PromQueryStats
+}
+
+type subQuery struct {
+	rangeIndex int
+	queryIndex int
+	shard      *seriesShard
+}
+
+func (s *querySplitter) rangeCacheKey(r timeRange) string {
+	return fmt.Sprintf("%s:split:%d", s.cacheKey, r.intervalStart)
+}
+
+// cacheable only completed intervals are cached, data of the latest interval may still be changing
+func (s *querySplitter) cacheable(r timeRange) bool {
+	return s.cacher != nil && r.full && r.end < s.completedBefore
+}
+
+func (s *querySplitter) run(ctx context.Context, ranges []timeRange) (promql.Matrix, []model.PromQueryStats, error) {
+	queries := []string{s.args.Promql}
+	shards := 1
+	if s.plan != nil {
+		queries = s.plan.queries
+		shards = config.Cfg.Prometheus.QuerySplit.SeriesShards
+	}
+
+	// results[range][query][shard]
+	results := make([][][]promql.Matrix, len(ranges))
+	cached := make([]promql.Matrix, len(ranges))
+	subQueries := []subQuery{}
+	for i, r := range ranges {
+		if s.cacheable(r) {
+			res, _, _, queryRequired := s.cacher.Fetch(s.rangeCacheKey(r), r.start, r.end)
+			if !queryRequired && res.Err == nil {
+				if matrix, err := res.Matrix(); err == nil {
+					cached[i] = matrix
+					continue
+				}
+			}
+		}
+		results[i] = make([][]promql.Matrix, len(queries))
+		for j := range queries {
+			results[i][j] = make([]promql.Matrix, shards)
+			for k := 0; k < shards; k++ {
+				q := subQuery{rangeIndex: i, queryIndex: j}
+				if s.plan != nil {
+					q.shard = &seriesShard{index: k, count: shards}
+				}
+				subQueries = append(subQueries, q)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	parallelism := s.parallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	semaphore := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for _, q := range subQueries {
+		semaphore <- struct{}{}
+		if ctx.Err() != nil {
+			<-semaphore
+			break
+		}
+		wg.Add(1)
+		go func(q subQuery) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			matrix, err := s.executeSubQuery(ctx, ranges[q.rangeIndex], queries[q.queryIndex], q.shard)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			shardIndex := 0
+			if q.shard != nil {
+				shardIndex = q.shard.index
+			}
+			results[q.rangeIndex][q.queryIndex][shardIndex] = matrix
+		}(q)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+
+	rangeMatrices := make([]promql.Matrix, len(ranges))
+	for i, r := range ranges {
+		if results[i] == nil {
+			rangeMatrices[i] = cached[i]
+			continue
+		}
+		rangeMatrices[i] = s.mergeRange(results[i])
+		if s.cacheable(r) {
+			if _, err := s.cacher.Merge(s.rangeCacheKey(r), r.start, r.end, s.step.Microseconds(), promql.Result{Value: rangeMatrices[i]}); err != nil {
+				log.Errorf("cache merge error: %v", err)
+			}
+		}
+	}
+	return concatMatrices(rangeMatrices), s.stats, nil
+}
+
+func (s *querySplitter) mergeRange(results [][]promql.Matrix) promql.Matrix {
+	if s.plan == nil {
+		return results[0][0]
+	}
+	if s.plan.op == parser.AVG {
+		return divideMatrix(mergeShards(parser.SUM, results[0]), mergeShards(parser.SUM, results[1]))
+	}
+	op := s.plan.op
+	if op == parser.COUNT {
+		op = parser.SUM
+	}
+	return mergeShards(op, results[0])
+}
+
+func (s *querySplitter) executeSubQuery(ctx context.Context, r timeRange, query string, shard *seriesShard) (promql.Matrix, error) {
+	ctx = context.WithValue(ctx, ctxKeySplitQuery{}, true)
+	if shard != nil {
+		ctx = context.WithValue(ctx, ctxKeySeriesShard{}, *shard)
+	}
+	args := *s.args
+	args.Promql = query
+	args.StartTime = formatSplitTime(r.start)
+	args.EndTime = formatSplitTime(r.end)
+	args.Context = ctx
+	resp, err := s.execute(ctx, &args, s.engine)
+	if err != nil {
+		return nil, err
+	}
+	if args.Debug {
+		s.statsLock.Lock()
+		s.stats = append(s.stats, resp.Stats...)
+		s.statsLock.Unlock()
+	}
+	data, ok := resp.Data.(*model.PromQueryData)
+	if !ok || data.Result == nil {
+		return promql.Matrix{}, nil
+	}
+	matrix, ok := data.Result.(promql.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type %s of range query", data.ResultType)
+	}
+	return matrix, nil
+}
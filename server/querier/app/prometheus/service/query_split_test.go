@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package service
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitTimeRange(t *testing.T) {
+	t.Run("range shorter than interval", func(t *testing.T) {
+		ranges := splitTimeRange(1000, 5000, 1000, 10000)
+		assert.Equal(t, []timeRange{{start: 1000, end: 5000}}, ranges)
+	})
+
+	t.Run("aligned to absolute interval", func(t *testing.T) {
+		ranges := splitTimeRange(15000, 45000, 5000, 20000)
+		assert.Equal(t, []timeRange{
+			{start: 15000, end: 15000, intervalStart: 0, full: false},
+			{start: 20000, end: 35000, intervalStart: 20000, full: true},
+			{start: 40000, end: 45000, intervalStart: 40000, full: false},
+		}, ranges)
+	})
+
+	t.Run("keep step offset and round interval up to step", func(t *testing.T) {
+		ranges := splitTimeRange(1000, 31000, 3000, 10000)
+		// interval rounded up to 12000, boundaries keep offset 1000 of step grid
+		assert.Equal(t, []timeRange{
+			{start: 1000, end: 10000, intervalStart: 1000, full: true},
+			{start: 13000, end: 22000, intervalStart: 13000, full: true},
+			{start: 25000, end: 31000, intervalStart: 25000, full: false},
+		}, ranges)
+		// all points of the step grid are covered exactly once
+		points := 0
+		for _, r := range ranges {
+			points += int((r.end-r.start)/3000) + 1
+		}
+		assert.Equal(t, 11, points)
+	})
+}
+
+func TestPlanSeriesShards(t *testing.T) {
+	shardable := map[string]parser.ItemType{
+		`sum(rate(node_cpu_seconds_total[5m]))`:                 parser.SUM,
+		`(count by (pod) (kube_pod_info{namespace="default"}))`: parser.COUNT,
+		`max without (cpu) (node_cpu_seconds_total * 100)`:      parser.MAX,
+		`avg by (instance) (prometheus__node_load1)`:            parser.AVG,
+	}
+	for query, op := range shardable {
+		plan := planSeriesShards(query, 4)
+		if assert.NotNil(t, plan, query) {
+			assert.Equal(t, op, plan.op, query)
+		}
+	}
+
+	plan := planSeriesShards(`avg by (instance) (node_load1)`, 4)
+	assert.Equal(t, []string{`sum by(instance) (node_load1)`, `count by(instance) (node_load1)`}, plan.queries)
+
+	notShardable := []string{
+		`rate(node_cpu_seconds_total[5m])`,
+		`topk(5, node_load1)`,
+		`sum(max by (pod) (kube_pod_info))`,
+		`sum(node_memory_free_bytes / node_memory_total_bytes)`,
+		`sum(flow_metrics__network__1m__byte)`,
+		`sum(absent(node_load1{instance="a"}))`,
+		`count(absent_over_time(node_load1[5m]))`,
+		`sum(node_load1 + vector(1))`,
+		`max(node_load1 * scalar(node_cpu_seconds_total{cpu="0"}))`,
+	}
+	for _, query := range notShardable {
+		assert.Nil(t, planSeriesShards(query, 4), query)
+	}
+	assert.Nil(t, planSeriesShards(`sum(node_load1)`, 1))
+}
+
+func TestTimeSplittable(t *testing.T) {
+	assert.True(t, timeSplittable(`sum(rate(node_cpu_seconds_total[5m]))`))
+	assert.False(t, timeSplittable(`node_load1 @ 1700000000`))
+	assert.False(t, timeSplittable(`rate(node_cpu_seconds_total[5m] @ end())`))
+}
+
+func TestMergeSplitResults(t *testing.T) {
+	metricA := labels.FromStrings("pod", "a")
+	metricB := labels.FromStrings("pod", "b")
+	shard0 := promql.Matrix{
+		{Metric: metricA, Points: []promql.Point{{T: 1000, V: 1}, {T: 2000, V: 4}}},
+	}
+	shard1 := promql.Matrix{
+		{Metric: metricB, Points: []promql.Point{{T: 1000, V: 5}}},
+		{Metric: metricA, Points: []promql.Point{{T: 2000, V: 2}, {T: 3000, V: 3}}},
+	}
+
+	sum := mergeShards(parser.SUM, []promql.Matrix{shard0, shard1})
+	assert.Equal(t, promql.Matrix{
+		{Metric: metricA, Points: []promql.Point{{T: 1000, V: 1}, {T: 2000, V: 6}, {T: 3000, V: 3}}},
+		{Metric: metricB, Points: []promql.Point{{T: 1000, V: 5}}},
+	}, sum)
+
+	min := mergeShards(parser.MIN, []promql.Matrix{shard0, shard1})
+	assert.Equal(t, []promql.Point{{T: 1000, V: 1}, {T: 2000, V: 2}, {T: 3000, V: 3}}, min[0].Points)
+
+	count := promql.Matrix{
+		{Metric: metricA, Points: []promql.Point{{T: 1000, V: 1}, {T: 2000, V: 3}, {T: 3000, V: 0}}},
+	}
+	avg := divideMatrix(sum, count)
+	assert.Equal(t, promql.Matrix{
+		{Metric: metricA, Points: []promql.Point{{T: 1000, V: 1}, {T: 2000, V: 2}}},
+	}, avg)
+
+	range0 := promql.Matrix{
+		{Metric: metricB, Points: []promql.Point{{T: 1000, V: 5}}},
+		{Metric: metricA, Points: []promql.Point{{T: 1000, V: 1}}},
+	}
+	range1 := promql.Matrix{
+		{Metric: metricA, Points: []promql.Point{{T: 2000, V: 2}, {T: 3000, V: 3}}},
+	}
+	concat := concatMatrices([]promql.Matrix{range0, range1})
+	assert.Equal(t, promql.Matrix{
+		{Metric: metricA, Points: []promql.Point{{T: 1000, V: 1}, {T: 2000, V: 2}, {T: 3000, V: 3}}},
+		{Metric: metricB, Points: []promql.Point{{T: 1000, V: 5}}},
+	}, concat)
+}
@@ -61,7 +61,11 @@ func (p *prometheusReader) promReaderExecute(ctx context.Context, req *prompb.Re
 
 	// should get cache result immediately
 	// for DeepFlow Native metrics, don't use cache
-	cacheAvailable := config.Cfg.Prometheus.Cache.RemoteReadCache && !strings.Contains(metricName, "__")
+	// remote read cache is keyed by matchers only, so it's only available for default org
+	// and it does not know which shard of series is queried by query splitter
+	_, sharded := ctx.Value(ctxKeySeriesShard{}).(seriesShard)
+	cacheAvailable := config.Cfg.Prometheus.Cache.RemoteReadCache && !strings.Contains(metricName, "__") &&
+		common.GetOrgID(ctx) == common.DEFAULT_ORG_ID && !sharded
 	if cacheAvailable {
 		var hit cache.CacheHit
 		var cacheItem *cache.CacheItem
@@ -85,6 +85,11 @@ func (s *PrometheusService) PromInstantQueryService(args *model.PromQueryParams,
 }
 
 func (s *PrometheusService) PromRangeQueryService(args *model.PromQueryParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	if config.Cfg.Prometheus.QuerySplit.Enabled {
+		if result, split, err := s.executor.splitRangeQueryExecute(ctx, args, s.engine); split {
+			return result, err
+		}
+	}
 	if args.Offloading {
 		return s.executor.offloadRangeQueryExecute(ctx, args, s.engine)
 	} else {
@@ -20,11 +20,13 @@ import (
 	"bytes"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
@@ -40,6 +42,36 @@ func DefaultContentTypeHeader() map[string]string {
 	}
 }
 
+// AppendAuthHeader sets the authorization headers of the external apm
+func AppendAuthHeader(header map[string]string, auth *config.AuthConfig) map[string]string {
+	if auth == nil {
+		return header
+	}
+	switch strings.ToLower(auth.Type) {
+	case config.AUTH_TYPE_BASIC:
+		header["Authorization"] = fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password)))
+	case config.AUTH_TYPE_BEARER:
+		header["Authorization"] = fmt.Sprintf("Bearer %s", auth.Token)
+	}
+	for k, v := range auth.Headers {
+		header[k] = v
+	}
+	return header
+}
+
+// BuildURL joins the address of the external apm and the api path, the scheme is optional in the address
+func BuildURL(c *config.ExternalAPM, path string) string {
+	addr := strings.TrimSuffix(c.Addr, "/")
+	if !strings.Contains(addr, "://") {
+		scheme := "http"
+		if c.TLS != nil {
+			scheme = "https"
+		}
+		addr = fmt.Sprintf("%s://%s", scheme, addr)
+	}
+	return fmt.Sprintf("%s/%s", addr, strings.TrimPrefix(path, "/"))
+}
+
 func Serialize[T any](obj T) ([]byte, error) {
 	result, err := json.Marshal(obj)
 	return result, err
@@ -23,9 +23,23 @@ type ExternalAPM struct {
 	Addr        string            `yaml:"addr"` // e.g.: http://host:port
 	Timeout     time.Duration     `default:"60s" yaml:"timeout"`
 	TLS         *TLSConfig        `yaml:"tls_config"`
+	Auth        *AuthConfig       `yaml:"auth"`
 	ExtraConfig map[string]string `yaml:"extra_config"`
 }
 
+const (
+	AUTH_TYPE_BASIC  = "basic"
+	AUTH_TYPE_BEARER = "bearer"
+)
+
+type AuthConfig struct {
+	Type     string            `yaml:"type"` // basic or bearer
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+	Token    string            `yaml:"token"`
+	Headers  map[string]string `yaml:"headers"` // extra headers, e.g. tenant id of a multi-tenant apm
+}
+
 type TLSConfig struct {
 	CAFile   string `yaml:"ca-file"`
 	CertFile string `yaml:"cert-file"`
@@ -17,6 +17,12 @@
 package service
 
 import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+
 	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/model"
 	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/service/packet_service"
 	"github.com/op/go-logging"
@@ -32,6 +38,8 @@ func Register() error {
 		Adapters = make(map[string]model.TraceAdapter, 0)
 	}
 	Adapters["skywalking"] = &SkyWalkingAdapter{}
+	Adapters["jaeger"] = &JaegerAdapter{}
+	Adapters["zipkin"] = &ZipkinAdapter{}
 	subServices := packet_service.GetPacketServices()
 	if subServices != nil {
 		for k, v := range subServices {
@@ -41,3 +49,73 @@ func Register() error {
 	}
 	return nil
 }
+
+// opentelemetry semantic conventions shared by jaeger and zipkin tags
+const (
+	AttributeHTTPRequestMethod      = "http.request.method"
+	AttributeHTTPResponseStatusCode = "http.response.status_code"
+	AttributeHTTPURL                = "http.url"
+	AttributeHTTPTarget             = "http.target"
+	AttributeHTTPPath               = "http.path"
+	AttributeURLFull                = "url.full"
+)
+
+// generateExSpanID generates the unique id of a span in one trace, index is used to
+// distinguish spans with the same span id, e.g.: zipkin shared client and server spans
+func generateExSpanID(spanID string, index int) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(spanID))
+	h.Write([]byte(strconv.Itoa(index)))
+	return h.Sum64() | 1
+}
+
+// otelSpanKind converts the span kind in opentracing/zipkin format (e.g.: server, CLIENT) to opentelemetry span kind
+func otelSpanKind(kind string) int {
+	switch strings.ToLower(kind) {
+	case "server":
+		return int(v1.Span_SPAN_KIND_SERVER)
+	case "client":
+		return int(v1.Span_SPAN_KIND_CLIENT)
+	case "producer":
+		return int(v1.Span_SPAN_KIND_PRODUCER)
+	case "consumer":
+		return int(v1.Span_SPAN_KIND_CONSUMER)
+	case "internal":
+		return int(v1.Span_SPAN_KIND_INTERNAL)
+	default:
+		return int(v1.Span_SPAN_KIND_UNSPECIFIED)
+	}
+}
+
+func spanKindToTapSide(spanKind int) string {
+	switch v1.Span_SpanKind(spanKind) {
+	case v1.Span_SPAN_KIND_CLIENT, v1.Span_SPAN_KIND_PRODUCER:
+		return "c-app"
+	case v1.Span_SPAN_KIND_SERVER, v1.Span_SPAN_KIND_CONSUMER:
+		return "s-app"
+	default:
+		return "app"
+	}
+}
+
+// fillSpanRequestInfo fills request info of the span by well-known tags
+func fillSpanRequestInfo(attributes map[string]string, span *model.ExSpan) {
+	for _, key := range []string{AttributeHTTPMethod, AttributeHTTPRequestMethod} {
+		if v, ok := attributes[key]; ok {
+			span.RequestType = v // http method
+			// the only protocol can get from span now
+			span.L7Protocol, span.L7ProtocolStr = 20, "HTTP"
+		}
+	}
+	for _, key := range []string{AttributeHTTPStatus_Code, AttributeHTTPResponseStatusCode} {
+		if code, err := strconv.Atoi(attributes[key]); err == nil {
+			span.ResponseStatus = code
+		}
+	}
+	for _, key := range []string{AttributeHTTPPath, AttributeHTTPTarget, AttributeHTTPURL, AttributeURLFull, AttributeDbStatement} {
+		if v, ok := attributes[key]; ok && v != "" {
+			span.RequestResource = v
+			break
+		}
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/model"
+)
+
+const (
+	// jaeger query api, see: https://www.jaegertracing.io/docs/latest/apis/#http-json-internal
+	jaeger_trace_url = "api/traces/%s"
+
+	JaegerRefTypeChildOf     = "CHILD_OF"
+	JaegerRefTypeFollowsFrom = "FOLLOWS_FROM"
+
+	JaegerTagSpanKind = "span.kind"
+)
+
+type jaegerTraceResponse struct {
+	Data   []jaegerTrace `json:"data"`
+	Errors []struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"errors"`
+}
+
+type jaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []jaegerSpan             `json:"spans"`
+	Processes map[string]jaegerProcess `json:"processes"`
+}
+
+type jaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	StartTime     int64             `json:"startTime"` // microseconds
+	Duration      int64             `json:"duration"`  // microseconds
+	Tags          []jaegerKeyValue  `json:"tags"`
+	ProcessID     string            `json:"processID"`
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerKeyValue struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type jaegerProcess struct {
+	ServiceName string           `json:"serviceName"`
+	Tags        []jaegerKeyValue `json:"tags"`
+}
+
+type jaegerConfig struct {
+	BasePath string `mapstructure:"base_path"` // e.g.: /jaeger when query-service runs with --query.base-path
+}
+
+type JaegerAdapter struct {
+}
+
+var log_jaeger = logging.MustGetLogger("tracing-adapter.jaeger")
+
+func (j *JaegerAdapter) GetTrace(traceID string, c *config.ExternalAPM) (*model.ExTrace, error) {
+	jaegerConfig := &jaegerConfig{}
+	err := mapstructure.Decode(c.ExtraConfig, jaegerConfig)
+	if err != nil {
+		log_jaeger.Errorf("cannot decode jaeger extra config %v, err: %s", c.ExtraConfig, err)
+		return nil, err
+	}
+	path := fmt.Sprintf(jaeger_trace_url, url.PathEscape(traceID))
+	if jaegerConfig.BasePath != "" {
+		path = fmt.Sprintf("%s/%s", jaegerConfig.BasePath, path)
+	}
+	header := common.AppendAuthHeader(common.DefaultContentTypeHeader(), c.Auth)
+	result, err := common.DoRequest(http.MethodGet, common.BuildURL(c, path), nil, header, c.Timeout, c.TLS)
+	if err != nil || result == nil {
+		log_jaeger.Errorf("query jaeger trace %s at %s failed! err: %s", traceID, c.Addr, err)
+		return nil, err
+	}
+	resp, err := common.Deserialize[jaegerTraceResponse](result)
+	if err != nil {
+		log_jaeger.Errorf("deserialize failed! err: %s", err)
+		return nil, err
+	}
+	if len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("query jaeger trace %s failed: %s", traceID, resp.Errors[0].Msg)
+	}
+	return j.jaegerTracesToExTraces(resp.Data), nil
+}
+
+func (j *JaegerAdapter) jaegerTracesToExTraces(traces []jaegerTrace) *model.ExTrace {
+	exTrace := &model.ExTrace{Spans: make([]model.ExSpan, 0)}
+	for _, trace := range traces {
+		for i, jaegerSpan := range trace.Spans {
+			process := trace.Processes[jaegerSpan.ProcessID]
+			attributes := make(map[string]string, len(jaegerSpan.Tags))
+			for _, tag := range jaegerSpan.Tags {
+				attributes[tag.Key] = j.jaegerTagValue(tag)
+			}
+			spanKind := otelSpanKind(attributes[JaegerTagSpanKind])
+			span := model.ExSpan{
+				Name:            jaegerSpan.OperationName,
+				ID:              generateExSpanID(jaegerSpan.SpanID, i),
+				StartTimeUs:     jaegerSpan.StartTime,
+				EndTimeUs:       jaegerSpan.StartTime + jaegerSpan.Duration,
+				TapSide:         spanKindToTapSide(spanKind),
+				TraceID:         jaegerSpan.TraceID,
+				SpanID:          jaegerSpan.SpanID,
+				ParentSpanID:    j.jaegerParentSpanID(jaegerSpan.References),
+				SpanKind:        spanKind,
+				Endpoint:        jaegerSpan.OperationName,
+				AppService:      process.ServiceName,
+				AppInstance:     j.jaegerProcessInstance(process),
+				ServiceUname:    process.ServiceName,
+				RequestResource: jaegerSpan.OperationName, // maybe overwrite by tags
+				Attribute:       attributes,
+			}
+			fillSpanRequestInfo(attributes, &span)
+			exTrace.Spans = append(exTrace.Spans, span)
+		}
+	}
+	return exTrace
+}
+
+func (j *JaegerAdapter) jaegerTagValue(tag jaegerKeyValue) string {
+	switch v := tag.Value.(type) {
+	case string:
+		return v
+	case float64:
+		// json numbers are decoded as float64, int64 tags should not be printed in exponent format
+		if tag.Type == "int64" || v == float64(int64(v)) {
+			return fmt.Sprintf("%d", int64(v))
+		}
+	}
+	return fmt.Sprint(tag.Value)
+}
+
+func (j *JaegerAdapter) jaegerParentSpanID(refs []jaegerReference) string {
+	// CHILD_OF takes precedence over FOLLOWS_FROM
+	parentSpanID := ""
+	for _, ref := range refs {
+		if ref.RefType == JaegerRefTypeChildOf {
+			return ref.SpanID
+		}
+		if parentSpanID == "" && ref.RefType == JaegerRefTypeFollowsFrom {
+			parentSpanID = ref.SpanID
+		}
+	}
+	return parentSpanID
+}
+
+func (j *JaegerAdapter) jaegerProcessInstance(process jaegerProcess) string {
+	for _, key := range []string{"service.instance.id", "hostname", "ip"} {
+		for _, tag := range process.Tags {
+			if tag.Key == key {
+				return j.jaegerTagValue(tag)
+			}
+		}
+	}
+	return ""
+}
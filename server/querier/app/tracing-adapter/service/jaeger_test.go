@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+)
+
+func TestGetJaegerTrace(t *testing.T) {
+	jaegerAdapter := &JaegerAdapter{}
+	data, err := os.ReadFile("testdata/jaeger_trace.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("TestGetJaegerTrace_Convert", t, func() {
+		resp, err := common.Deserialize[jaegerTraceResponse](data)
+		So(err, ShouldBeNil)
+		result := jaegerAdapter.jaegerTracesToExTraces(resp.Data)
+		So(len(result.Spans), ShouldEqual, 2)
+
+		server := result.Spans[0]
+		So(server.TraceID, ShouldEqual, "5b8aa5a2d2c872e8321cf37308d69df2")
+		So(server.SpanID, ShouldEqual, "051581bf3cb55c13")
+		So(server.ParentSpanID, ShouldEqual, "")
+		So(server.Name, ShouldEqual, "HTTP GET /dispatch")
+		So(server.StartTimeUs, ShouldEqual, 1694428678774000)
+		So(server.EndTimeUs, ShouldEqual, 1694428678827000)
+		So(server.SpanKind, ShouldEqual, int(v1.Span_SPAN_KIND_SERVER))
+		So(server.TapSide, ShouldEqual, "s-app")
+		So(server.AppService, ShouldEqual, "frontend")
+		So(server.AppInstance, ShouldEqual, "frontend-5d9f7b7c4-xk2lp")
+		So(server.RequestType, ShouldEqual, "GET")
+		So(server.RequestResource, ShouldEqual, "/dispatch?customer=123")
+		So(server.ResponseStatus, ShouldEqual, 200)
+		So(server.L7ProtocolStr, ShouldEqual, "HTTP")
+		So(server.Attribute["http.status_code"], ShouldEqual, "200")
+		So(server.Attribute["sampler.param"], ShouldEqual, "true")
+
+		client := result.Spans[1]
+		So(client.ParentSpanID, ShouldEqual, "051581bf3cb55c13")
+		So(client.SpanKind, ShouldEqual, int(v1.Span_SPAN_KIND_CLIENT))
+		So(client.TapSide, ShouldEqual, "c-app")
+		So(client.AppService, ShouldEqual, "customer")
+		So(client.AppInstance, ShouldEqual, "10.1.0.13")
+		So(client.RequestResource, ShouldEqual, "SELECT * FROM customer WHERE customer_id=123")
+		So(client.L7Protocol, ShouldEqual, 0)
+
+		So(server.ID, ShouldNotEqual, 0)
+		So(server.ID, ShouldNotEqual, client.ID)
+	})
+
+	Convey("TestGetJaegerTrace_Request", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/jaeger/api/traces/5b8aa5a2d2c872e8321cf37308d69df2" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("X-Scope-OrgID") != "tenant" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write(data)
+		}))
+		defer ts.Close()
+
+		c := &config.ExternalAPM{
+			Name:        "jaeger",
+			Addr:        ts.URL,
+			Auth:        &config.AuthConfig{Type: config.AUTH_TYPE_BEARER, Token: "token", Headers: map[string]string{"X-Scope-OrgID": "tenant"}},
+			ExtraConfig: map[string]string{"base_path": "/jaeger"},
+		}
+		result, err := jaegerAdapter.GetTrace("5b8aa5a2d2c872e8321cf37308d69df2", c)
+		So(err, ShouldBeNil)
+		So(len(result.Spans), ShouldEqual, 2)
+
+		c.Auth = nil
+		_, err = jaegerAdapter.GetTrace("5b8aa5a2d2c872e8321cf37308d69df2", c)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -109,8 +109,11 @@ func (s *SkyWalkingAdapter) GetTrace(traceID string, c *config.ExternalAPM) (*mo
 	return s.skywalkingTracesToExTraces(traces), nil
 }
 
-func (s *SkyWalkingAdapter) appendAuthHeader(auth string) map[string]string {
+func (s *SkyWalkingAdapter) appendAuthHeader(auth string, authConfig *config.AuthConfig) map[string]string {
 	header := common.DefaultContentTypeHeader()
+	if authConfig != nil {
+		return common.AppendAuthHeader(header, authConfig)
+	}
 	if auth == "" {
 		return header
 	}
@@ -132,7 +135,7 @@ func (s *SkyWalkingAdapter) getTrace(traceID string, c *config.ExternalAPM, swCo
 	if c.TLS != nil {
 		scheme = "https"
 	}
-	result, err := common.DoRequest(http.MethodPost, fmt.Sprintf("%s://%s/%s", scheme, c.Addr, query_url), post_data, s.appendAuthHeader(swConfig.Auth), c.Timeout, c.TLS)
+	result, err := common.DoRequest(http.MethodPost, fmt.Sprintf("%s://%s/%s", scheme, c.Addr, query_url), post_data, s.appendAuthHeader(swConfig.Auth, c.Auth), c.Timeout, c.TLS)
 	if err != nil || result == nil {
 		log_sw.Errorf("query skywalking trace %s at %s failed! addr: %s, err: %s", traceID, c.Addr, err)
 		return nil, err
@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/model"
+)
+
+const (
+	// zipkin v2 api, see: https://zipkin.io/zipkin-api/#/default/get_trace__traceId_
+	zipkin_trace_url = "api/v2/trace/%s"
+)
+
+type zipkinSpan struct {
+	TraceID        string            `json:"traceId"`
+	ID             string            `json:"id"`
+	ParentID       string            `json:"parentId"`
+	Name           string            `json:"name"`
+	Kind           string            `json:"kind"`      // CLIENT, SERVER, PRODUCER or CONSUMER
+	Timestamp      int64             `json:"timestamp"` // microseconds
+	Duration       int64             `json:"duration"`  // microseconds
+	LocalEndpoint  *zipkinEndpoint   `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint   `json:"remoteEndpoint"`
+	Tags           map[string]string `json:"tags"`
+	Shared         bool              `json:"shared"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type zipkinConfig struct {
+	BasePath string `mapstructure:"base_path"` // e.g.: /zipkin when zipkin runs behind a reverse proxy
+}
+
+type ZipkinAdapter struct {
+}
+
+var log_zipkin = logging.MustGetLogger("tracing-adapter.zipkin")
+
+func (z *ZipkinAdapter) GetTrace(traceID string, c *config.ExternalAPM) (*model.ExTrace, error) {
+	zipkinConfig := &zipkinConfig{}
+	err := mapstructure.Decode(c.ExtraConfig, zipkinConfig)
+	if err != nil {
+		log_zipkin.Errorf("cannot decode zipkin extra config %v, err: %s", c.ExtraConfig, err)
+		return nil, err
+	}
+	path := fmt.Sprintf(zipkin_trace_url, url.PathEscape(traceID))
+	if zipkinConfig.BasePath != "" {
+		path = fmt.Sprintf("%s/%s", zipkinConfig.BasePath, path)
+	}
+	header := common.AppendAuthHeader(common.DefaultContentTypeHeader(), c.Auth)
+	result, err := common.DoRequest(http.MethodGet, common.BuildURL(c, path), nil, header, c.Timeout, c.TLS)
+	if err != nil || result == nil {
+		log_zipkin.Errorf("query zipkin trace %s at %s failed! err: %s", traceID, c.Addr, err)
+		return nil, err
+	}
+	spans, err := common.Deserialize[[]zipkinSpan](result)
+	if err != nil || spans == nil {
+		log_zipkin.Errorf("deserialize failed! err: %s", err)
+		return nil, err
+	}
+	return z.zipkinSpansToExTraces(*spans), nil
+}
+
+func (z *ZipkinAdapter) zipkinSpansToExTraces(spans []zipkinSpan) *model.ExTrace {
+	exTrace := &model.ExTrace{Spans: make([]model.ExSpan, 0, len(spans))}
+	sharedIDs := make(map[string]bool)
+	for _, zipkinSpan := range spans {
+		if zipkinSpan.Shared {
+			sharedIDs[zipkinSpan.ID] = true
+		}
+	}
+	for i, zipkinSpan := range spans {
+		attributes := make(map[string]string, len(zipkinSpan.Tags)+1)
+		for k, v := range zipkinSpan.Tags {
+			attributes[k] = v
+		}
+		if zipkinSpan.RemoteEndpoint != nil && zipkinSpan.RemoteEndpoint.ServiceName != "" {
+			attributes["peer.service"] = zipkinSpan.RemoteEndpoint.ServiceName
+		}
+		spanKind := otelSpanKind(zipkinSpan.Kind)
+		// the server side of a shared span has the same id as the client side, rename the client side
+		// and use it as the parent of the server side to keep span ids unique in one trace
+		spanID, parentSpanID := zipkinSpan.ID, zipkinSpan.ParentID
+		if zipkinSpan.Shared {
+			parentSpanID = z.zipkinClientSpanID(zipkinSpan.ID)
+		} else if sharedIDs[zipkinSpan.ID] {
+			spanID = z.zipkinClientSpanID(zipkinSpan.ID)
+		}
+		span := model.ExSpan{
+			Name:            zipkinSpan.Name,
+			ID:              generateExSpanID(zipkinSpan.ID, i),
+			StartTimeUs:     zipkinSpan.Timestamp,
+			EndTimeUs:       zipkinSpan.Timestamp + zipkinSpan.Duration,
+			TapSide:         spanKindToTapSide(spanKind),
+			TraceID:         zipkinSpan.TraceID,
+			SpanID:          spanID,
+			ParentSpanID:    parentSpanID,
+			SpanKind:        spanKind,
+			Endpoint:        zipkinSpan.Name,
+			RequestResource: zipkinSpan.Name, // maybe overwrite by tags
+			Attribute:       attributes,
+		}
+		if zipkinSpan.LocalEndpoint != nil {
+			span.AppService = zipkinSpan.LocalEndpoint.ServiceName
+			span.ServiceUname = zipkinSpan.LocalEndpoint.ServiceName
+			span.AppInstance = z.zipkinEndpointInstance(zipkinSpan.LocalEndpoint)
+		}
+		fillSpanRequestInfo(attributes, &span)
+		exTrace.Spans = append(exTrace.Spans, span)
+	}
+	return exTrace
+}
+
+func (z *ZipkinAdapter) zipkinClientSpanID(id string) string {
+	return fmt.Sprintf("%s-client", id)
+}
+
+func (z *ZipkinAdapter) zipkinEndpointInstance(endpoint *zipkinEndpoint) string {
+	ip := endpoint.IPv4
+	if ip == "" {
+		ip = endpoint.IPv6
+	}
+	if ip == "" || endpoint.Port == 0 {
+		return ip
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(endpoint.Port))
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+)
+
+func TestGetZipkinTrace(t *testing.T) {
+	zipkinAdapter := &ZipkinAdapter{}
+	data, err := os.ReadFile("testdata/zipkin_trace.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("TestGetZipkinTrace_Convert", t, func() {
+		spans, err := common.Deserialize[[]zipkinSpan](data)
+		So(err, ShouldBeNil)
+		result := zipkinAdapter.zipkinSpansToExTraces(*spans)
+		So(len(result.Spans), ShouldEqual, 3)
+
+		client := result.Spans[0]
+		So(client.TraceID, ShouldEqual, "463ac35c9f6413ad48485a3953bb6124")
+		So(client.SpanID, ShouldEqual, "a2fb4a1d1a96d312-client")
+		So(client.ParentSpanID, ShouldEqual, "")
+		So(client.SpanKind, ShouldEqual, int(v1.Span_SPAN_KIND_CLIENT))
+		So(client.TapSide, ShouldEqual, "c-app")
+		So(client.AppService, ShouldEqual, "frontend")
+		So(client.AppInstance, ShouldEqual, "10.1.0.12:8080")
+		So(client.StartTimeUs, ShouldEqual, 1694428678774000)
+		So(client.EndTimeUs, ShouldEqual, 1694428678827000)
+		So(client.RequestType, ShouldEqual, "GET")
+		So(client.RequestResource, ShouldEqual, "/api")
+		So(client.ResponseStatus, ShouldEqual, 200)
+		So(client.Attribute["peer.service"], ShouldEqual, "backend")
+
+		server := result.Spans[1]
+		So(server.SpanID, ShouldEqual, "a2fb4a1d1a96d312")
+		So(server.ParentSpanID, ShouldEqual, "a2fb4a1d1a96d312-client")
+		So(server.SpanKind, ShouldEqual, int(v1.Span_SPAN_KIND_SERVER))
+		So(server.TapSide, ShouldEqual, "s-app")
+		So(server.AppService, ShouldEqual, "backend")
+		So(server.ID, ShouldNotEqual, client.ID)
+
+		query := result.Spans[2]
+		So(query.ParentSpanID, ShouldEqual, "a2fb4a1d1a96d312")
+		So(query.AppInstance, ShouldEqual, "::1")
+		So(query.RequestResource, ShouldEqual, "query")
+		So(query.L7Protocol, ShouldEqual, 0)
+		So(query.Attribute["sql.query"], ShouldEqual, "select 1")
+	})
+
+	Convey("TestGetZipkinTrace_Request", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v2/trace/463ac35c9f6413ad48485a3953bb6124" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write(data)
+		}))
+		defer ts.Close()
+
+		c := &config.ExternalAPM{
+			Name: "zipkin",
+			Addr: ts.Listener.Addr().String(),
+			Auth: &config.AuthConfig{Type: config.AUTH_TYPE_BASIC, Username: "user", Password: "pass"},
+		}
+		result, err := zipkinAdapter.GetTrace("463ac35c9f6413ad48485a3953bb6124", c)
+		So(err, ShouldBeNil)
+		So(len(result.Spans), ShouldEqual, 3)
+
+		_, err = zipkinAdapter.GetTrace("unknown", c)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+)
+
+const (
+	// 请求所属组织，未携带时为默认组织
+	HEADER_KEY_X_ORG_ID = "X-Org-Id"
+	DEFAULT_ORG_ID      = ckdb.DEFAULT_ORG_ID
+)
+
+// 按组织隔离的数据库与 ingester 保持一致，flow_tag 等元数据库为所有组织共享
+var orgDatabaseRegexp = regexp.MustCompile(
+	"(^|[^\\w.])(`?)(" + strings.Join(ckdb.ORG_DATABASES, "|") + ")(`?)\\.",
+)
+
+type orgIDKey struct{}
+
+func ContextWithOrgID(ctx context.Context, orgID uint16) context.Context {
+	return context.WithValue(ctx, orgIDKey{}, orgID)
+}
+
+// GetOrgID 获取请求上下文中的组织 ID，未设置时返回默认组织
+func GetOrgID(ctx context.Context) uint16 {
+	if ctx == nil {
+		return DEFAULT_ORG_ID
+	}
+	if orgID, ok := ctx.Value(orgIDKey{}).(uint16); ok {
+		return orgID
+	}
+	return DEFAULT_ORG_ID
+}
+
+// ParseOrgID 解析请求头 X-Org-Id，为空时返回默认组织
+func ParseOrgID(value string) (uint16, error) {
+	if value == "" {
+		return DEFAULT_ORG_ID, nil
+	}
+	orgID, err := strconv.ParseUint(value, 10, 16)
+	if err != nil || orgID == 0 {
+		return 0, fmt.Errorf("invalid header %s: %s", HEADER_KEY_X_ORG_ID, value)
+	}
+	return uint16(orgID), nil
+}
+
+// OrgSql 将 SQL 中的数据库替换为组织的数据库，如 flow_log.l7_flow_log -> org0002_flow_log.l7_flow_log，
+// 单引号字符串中的内容保持不变
+func OrgSql(sql string, orgID uint16) string {
+	if ckdb.IsDefaultOrgID(orgID) {
+		return sql
+	}
+	replacement := "${1}${2}" + ckdb.OrgDatabasePrefix(orgID) + "${3}${4}."
+	var builder strings.Builder
+	start := 0
+	for i := 0; i < len(sql); i++ {
+		if sql[i] != '\'' {
+			continue
+		}
+		// 替换字符串之前的部分
+		builder.WriteString(orgDatabaseRegexp.ReplaceAllString(sql[start:i], replacement))
+		start = i
+		// 跳过字符串，支持 \' 和 '' 转义
+		for i++; i < len(sql); i++ {
+			if sql[i] == '\\' {
+				i++
+			} else if sql[i] == '\'' {
+				if i+1 < len(sql) && sql[i+1] == '\'' {
+					i++
+					continue
+				}
+				break
+			}
+		}
+		if i >= len(sql) {
+			i = len(sql) - 1
+		}
+		builder.WriteString(sql[start : i+1])
+		start = i + 1
+	}
+	builder.WriteString(orgDatabaseRegexp.ReplaceAllString(sql[start:], replacement))
+	return builder.String()
+}
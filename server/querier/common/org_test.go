@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOrgSql(t *testing.T) {
+	tests := []struct {
+		name  string
+		sql   string
+		orgID uint16
+		want  string
+	}{
+		{
+			name:  "default org",
+			sql:   "SELECT Sum(byte) FROM flow_metrics.`vtap_flow_port.1m` LIMIT 1",
+			orgID: 1,
+			want:  "SELECT Sum(byte) FROM flow_metrics.`vtap_flow_port.1m` LIMIT 1",
+		},
+		{
+			name:  "flow_metrics of org",
+			sql:   "SELECT Sum(byte) FROM flow_metrics.`vtap_flow_port.1m` LIMIT 1",
+			orgID: 2,
+			want:  "SELECT Sum(byte) FROM org0002_flow_metrics.`vtap_flow_port.1m` LIMIT 1",
+		},
+		{
+			name:  "every data database of org",
+			sql:   "SELECT 1 FROM ext_metrics.metrics, prometheus.samples, event.event, profile.in_process, deepflow_system.deepflow_system, flow_tag.pod_map",
+			orgID: 2,
+			want:  "SELECT 1 FROM org0002_ext_metrics.metrics, org0002_prometheus.samples, org0002_event.event, org0002_profile.in_process, org0002_deepflow_system.deepflow_system, flow_tag.pod_map",
+		},
+		{
+			name:  "shared flow_tag dictionary",
+			sql:   "SELECT dictGet(flow_tag.pod_map, 'name', toUInt64(pod_id_0)) AS `pod_0` FROM flow_log.`l7_flow_log` LIMIT 1",
+			orgID: 12,
+			want:  "SELECT dictGet(flow_tag.pod_map, 'name', toUInt64(pod_id_0)) AS `pod_0` FROM org0012_flow_log.`l7_flow_log` LIMIT 1",
+		},
+		{
+			name:  "quoted database and sub query",
+			sql:   "WITH a AS (SELECT 1 FROM `flow_log`.`l4_flow_log`) SELECT * FROM a JOIN (SELECT 1 FROM event.event) b",
+			orgID: 3,
+			want:  "WITH a AS (SELECT 1 FROM `org0003_flow_log`.`l4_flow_log`) SELECT * FROM a JOIN (SELECT 1 FROM org0003_event.event) b",
+		},
+		{
+			name:  "string literals are untouched",
+			sql:   "SELECT 1 FROM flow_log.l7_flow_log WHERE request_resource = 'flow_log.x' AND endpoint IN ('it''s flow_log.y', 'a\\' flow_log.z') AND 1=1",
+			orgID: 2,
+			want:  "SELECT 1 FROM org0002_flow_log.l7_flow_log WHERE request_resource = 'flow_log.x' AND endpoint IN ('it''s flow_log.y', 'a\\' flow_log.z') AND 1=1",
+		},
+		{
+			name:  "unterminated string literal",
+			sql:   "SELECT 1 FROM flow_log.l4_flow_log WHERE a = 'flow_log.",
+			orgID: 2,
+			want:  "SELECT 1 FROM org0002_flow_log.l4_flow_log WHERE a = 'flow_log.",
+		},
+		{
+			name:  "column names are untouched",
+			sql:   "SELECT my_flow_log.x, t.flow_log.y FROM flow_log.l4_flow_log",
+			orgID: 2,
+			want:  "SELECT my_flow_log.x, t.flow_log.y FROM org0002_flow_log.l4_flow_log",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OrgSql(tt.sql, tt.orgID); got != tt.want {
+				t.Errorf("OrgSql() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOrgID(t *testing.T) {
+	if orgID, err := ParseOrgID(""); err != nil || orgID != 1 {
+		t.Errorf("ParseOrgID(\"\") = %v, %v, want 1", orgID, err)
+	}
+	if orgID, err := ParseOrgID("5"); err != nil || orgID != 5 {
+		t.Errorf("ParseOrgID(\"5\") = %v, %v, want 5", orgID, err)
+	}
+	for _, value := range []string{"0", "-1", "abc", "65536"} {
+		if _, err := ParseOrgID(value); err == nil {
+			t.Errorf("ParseOrgID(%q) should fail", value)
+		}
+	}
+	if orgID := GetOrgID(ContextWithOrgID(context.Background(), 7)); orgID != 7 {
+		t.Errorf("GetOrgID() = %v, want 7", orgID)
+	}
+	if orgID := GetOrgID(context.Background()); orgID != 1 {
+		t.Errorf("GetOrgID() = %v, want 1", orgID)
+	}
+}
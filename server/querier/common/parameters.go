@@ -42,6 +42,8 @@ type TempoParams struct {
 	Limit       string
 	Debug       string
 	Filters     []*KeyValue
+	Query       string // TraceQL
+	SpansPerSet string
 	Context     context.Context
 }
 
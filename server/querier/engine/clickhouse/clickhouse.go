@@ -47,6 +47,7 @@ var DEFAULT_LIMIT = "10000"
 var INVALID_PROMETHEUS_SUBQUERY_CACHE_ENTRY = "-1"
 var subSqlRegexp = regexp.MustCompile(`\(SELECT\s.+?LIMIT\s.+?\)`)
 var checkWithSqlRegexp = regexp.MustCompile(`WITH\s+\S+\s+AS\s+\(`)
+var subSqlFromRegexp = regexp.MustCompile("(?i)\\sFROM\\s+`?(\\w+)`?\\.`?([\\w.]+)`?")
 var letterRegexp = regexp.MustCompile("^[a-zA-Z]")
 
 // Perform regular checks on show SQL and support the following formats:
@@ -780,10 +781,14 @@ func (e *CHEngine) ParseWithSql(sql string) (string, map[string]func(*common.Res
 	for _, match := range subMatches {
 		match = strings.TrimPrefix(match, "(")
 		match = strings.TrimSuffix(match, ")")
-		matchEngine := &CHEngine{DB: e.DB, DataSource: e.DataSource, Context: e.Context}
+		db, dataSource, match, err := e.parseSubSqlFrom(match)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		matchEngine := &CHEngine{DB: db, DataSource: dataSource, Context: e.Context}
 		matchEngine.Init()
 		matchParser := parse.Parser{Engine: matchEngine}
-		err := matchParser.ParseSQL(match)
+		err = matchParser.ParseSQL(match)
 		if err != nil {
 			return "", nil, nil, err
 		}
@@ -808,6 +813,57 @@ func (e *CHEngine) ParseWithSql(sql string) (string, map[string]func(*common.Res
 	return sql, callbacks, columnSchemaMap, nil
 }
 
+// 子查询可以通过 FROM db.table 或 FROM db.`table.datasource` 指定数据库和数据源，
+// 从而在 WITH 查询中 JOIN 不同数据库的表，例：
+// WITH query1 AS (SELECT ... FROM flow_log.l7_flow_log ... LIMIT 100), query2 AS (SELECT ... FROM flow_metrics.`vtap_app_port.1m` ... LIMIT 100)
+// SELECT ... FROM query1 LEFT JOIN query2 ON query1.pod = query2.pod AND query1.toi = query2.toi
+// 未指定数据库时使用外层查询的数据库和数据源，
+// 每个子查询只能在最外层的 FROM 中指定一次数据库，嵌套查询或 JOIN 中指定数据库时返回错误
+func (e *CHEngine) parseSubSqlFrom(sql string) (string, string, string, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", "", "", err
+	}
+	selectStmt, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return "", "", "", fmt.Errorf("sub sql (%s) is not a select statement", sql)
+	}
+	var qualifiedTables []sqlparser.TableName
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if from, ok := node.(*sqlparser.AliasedTableExpr); ok {
+			if table, ok := from.Expr.(sqlparser.TableName); ok && !table.Qualifier.IsEmpty() {
+				qualifiedTables = append(qualifiedTables, table)
+			}
+		}
+		return true, nil
+	}, selectStmt)
+	if len(qualifiedTables) == 0 {
+		return e.DB, e.DataSource, sql, nil
+	}
+	if len(qualifiedTables) > 1 || len(selectStmt.From) != 1 {
+		return "", "", "", fmt.Errorf("sub sql (%s) can only specify database in one FROM", sql)
+	}
+	if from, ok := selectStmt.From[0].(*sqlparser.AliasedTableExpr); !ok || from.Expr != qualifiedTables[0] {
+		return "", "", "", fmt.Errorf("sub sql (%s) can only specify database in the outermost FROM", sql)
+	}
+	db := qualifiedTables[0].Qualifier.String()
+	if _, ok := chCommon.DB_TABLE_MAP[db]; !ok {
+		return "", "", "", fmt.Errorf("database (%s) of sub sql (%s) not found", db, sql)
+	}
+	matches := subSqlFromRegexp.FindAllStringSubmatch(sql, -1)
+	if len(matches) != 1 || matches[0][1] != db {
+		return "", "", "", fmt.Errorf("sub sql (%s) can only specify database in one FROM", sql)
+	}
+	table, dataSource := qualifiedTables[0].Name.String(), ""
+	if tableSlice := strings.SplitN(table, ".", 2); len(tableSlice) == 2 {
+		table, dataSource = tableSlice[0], tableSlice[1]
+	} else if db == e.DB {
+		dataSource = e.DataSource
+	}
+	sql = strings.Replace(sql, matches[0][0], fmt.Sprintf(" FROM `%s`", table), 1)
+	return db, dataSource, sql, nil
+}
+
 func (e *CHEngine) Init() {
 	e.Model = view.NewModel()
 	e.Model.DB = e.DB
@@ -1644,12 +1700,12 @@ func (e *CHEngine) parseWhere(node sqlparser.Expr, w *Where, isCheck bool) (view
 		args := []string{}
 		for _, argExpr := range node.Exprs {
 			switch argExpr := argExpr.(*sqlparser.AliasedExpr).Expr.(type) {
-			case *sqlparser.ColName:
+			case *sqlparser.ColName, *sqlparser.SQLVal:
 				arg := sqlparser.String(argExpr)
 				args = append(args, arg)
 			}
 		}
-		whereFilter := TransWhereTagFunction(e.DB, sqlparser.String(node.Name), args)
+		whereFilter := TransWhereTagFunction(e.DB, e.Table, sqlparser.String(node.Name), args)
 		if whereFilter == "" {
 			return nil, nil
 		}
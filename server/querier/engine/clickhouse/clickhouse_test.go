@@ -457,6 +457,76 @@ var (
 		datasource: "1m",
 		input:      "SELECT time(time,1,1,0) as toi, PerSecond(Avg(`byte`)) AS `流量速率`, pod as pod FROM `vtap_flow_port` WHERE time>=1705040184 AND time<=1705045184 GROUP BY toi, pod ORDER BY toi desc SLIMIT 5",
 		output:     "WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, divide(sum(byte)/(60/60), 60) AS `流量速率` FROM flow_metrics.`vtap_flow_port.1m` PREWHERE (pod) GLOBAL IN (SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` FROM flow_metrics.`vtap_flow_port.1m` PREWHERE `time` >= 1705040184 AND `time` <= 1705045184 AND (pod_id!=0) GROUP BY dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 5) AND `time` >= 1705040184 AND `time` <= 1705045184 AND (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` ORDER BY `toi` desc LIMIT 10000",
+	}, {
+		name:   "window_lag",
+		input:  "select time(time, 60) as toi, pod, Lag(Sum(byte), 1) as lag_byte from l4_flow_log group by toi, pod limit 10",
+		output: "WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, lagInFrame(SUM(byte_tx+byte_rx), 1) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS `lag_byte` FROM flow_log.`l4_flow_log` PREWHERE (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 10",
+	}, {
+		name:   "window_moving_avg",
+		input:  "select time(time, 60) as toi, pod, MovingAvg(Sum(byte), 3) as ma_byte from l4_flow_log group by toi, pod limit 10",
+		output: "WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, avg(SUM(byte_tx+byte_rx)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) AS `ma_byte` FROM flow_log.`l4_flow_log` PREWHERE (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 10",
+	}, {
+		name:   "window_rank",
+		input:  "select time(time, 60) as toi, pod, Rank(Sum(byte)) as rank_byte from l4_flow_log group by toi, pod limit 10",
+		output: "WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, rank() OVER (PARTITION BY `toi` ORDER BY SUM(byte_tx+byte_rx) DESC) AS `rank_byte` FROM flow_log.`l4_flow_log` PREWHERE (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 10",
+	}, {
+		name:   "window_period_delta",
+		input:  "select time(time, 60) as toi, pod, PeriodDelta(Sum(byte), 5) as delta_byte from l4_flow_log group by toi, pod limit 10",
+		output: "WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, SUM(byte_tx+byte_rx) - anyOrNull(SUM(byte_tx+byte_rx)) OVER (PARTITION BY `pod` ORDER BY `toi` RANGE BETWEEN 300 PRECEDING AND 300 PRECEDING) AS `delta_byte` FROM flow_log.`l4_flow_log` PREWHERE (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 10",
+	}, {
+		name:   "with_multi_db_join",
+		input:  "WITH query1 AS (SELECT time(time, 60) AS toi, pod, Avg(`response_duration`) AS `rrt` FROM l7_flow_log WHERE time>=1704338640 AND time<=1704339600 GROUP BY toi, pod LIMIT 100), query2 AS (SELECT time(time, 60) AS toi, pod, Sum(`byte`) AS `byte` FROM flow_metrics.`vtap_flow_port.1m` WHERE time>=1704338640 AND time<=1704339600 GROUP BY toi, pod LIMIT 100) SELECT query1.`toi` AS `toi`, query1.`pod` AS `pod`, query1.`rrt` AS `rrt`, query2.`byte` AS `byte` FROM query1 LEFT JOIN query2 ON query1.`pod` = query2.`pod` AND query1.`toi` = query2.`toi`",
+		output: "WITH query1 AS (WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, AVGIf(response_duration, response_duration > 0) AS `rrt` FROM flow_log.`l7_flow_log` PREWHERE `time` >= 1704338640 AND `time` <= 1704339600 AND (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 100), query2 AS (WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, SUM(byte) AS `byte` FROM flow_metrics.`vtap_flow_port.1m` PREWHERE `time` >= 1704338640 AND `time` <= 1704339600 AND (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 100) SELECT query1.`toi` AS `toi`, query1.`pod` AS `pod`, query1.`rrt` AS `rrt`, query2.`byte` AS `byte` FROM query1 LEFT JOIN query2 ON query1.`pod` = query2.`pod` AND query1.`toi` = query2.`toi`",
+	}, {
+		name:    "with_multi_db_join_in_sub_sql",
+		input:   "WITH query1 AS (SELECT pod FROM flow_log.l7_flow_log AS a LEFT JOIN flow_metrics.`vtap_flow_port.1m` AS b ON a.pod = b.pod LIMIT 100) SELECT query1.`pod` AS `pod` FROM query1",
+		wantErr: "sub sql (SELECT pod FROM flow_log.l7_flow_log AS a LEFT JOIN flow_metrics.`vtap_flow_port.1m` AS b ON a.pod = b.pod LIMIT 100) can only specify database in one FROM",
+	}, {
+		name:    "with_unknown_db",
+		input:   "WITH query1 AS (SELECT pod FROM unknown_db.l7_flow_log LIMIT 100) SELECT query1.`pod` AS `pod` FROM query1",
+		wantErr: "database (unknown_db) of sub sql (SELECT pod FROM unknown_db.l7_flow_log LIMIT 100) not found",
+	}, {
+		name:   "anomaly_baseline",
+		input:  "select time(time, 60) as toi, pod, Baseline(Sum(byte), '1d') as baseline_byte from l4_flow_log group by toi, pod limit 10",
+		output: "WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, anyOrNull(SUM(byte_tx+byte_rx)) OVER (PARTITION BY `pod` ORDER BY `toi` RANGE BETWEEN 86400 PRECEDING AND 86400 PRECEDING) AS `baseline_byte` FROM flow_log.`l4_flow_log` PREWHERE (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 10",
+	}, {
+		name:    "anomaly_baseline_period_ms",
+		input:   "select time(time, 60) as toi, Baseline(Sum(byte), '5ms') as baseline_byte from l4_flow_log group by toi limit 10",
+		wantErr: "function [Baseline] period ['5ms'] unit is not supported, it should be one of s, m, h, d, w",
+	}, {
+		name:    "anomaly_baseline_period_zero",
+		input:   "select time(time, 60) as toi, Baseline(Sum(byte), '0d') as baseline_byte from l4_flow_log group by toi limit 10",
+		wantErr: "function [Baseline] period ['0d'] is not a positive integer",
+	}, {
+		name:    "anomaly_baseline_period_garbled",
+		input:   "select time(time, 60) as toi, Baseline(Sum(byte), '1.5d') as baseline_byte from l4_flow_log group by toi limit 10",
+		wantErr: "function [Baseline] period ['1.5d'] is not a positive integer",
+	}, {
+		name:    "anomaly_baseline_period_missing",
+		input:   "select time(time, 60) as toi, Baseline(Sum(byte)) as baseline_byte from l4_flow_log group by toi limit 10",
+		wantErr: "function [Baseline] requires a metric and a period",
+	}, {
+		name:   "anomaly_zscore",
+		input:  "select time(time, 60) as toi, pod, ZScore(Avg(rtt), 10) as zscore_rtt from l4_flow_log group by toi, pod limit 10",
+		output: "WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, (AVGIf(rtt, rtt > 0) - avg(AVGIf(rtt, rtt > 0)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN 10 PRECEDING AND 1 PRECEDING)) / nullIf(stddevPop(AVGIf(rtt, rtt > 0)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN 10 PRECEDING AND 1 PRECEDING), 0) AS `zscore_rtt` FROM flow_log.`l4_flow_log` PREWHERE (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 10",
+	}, {
+		name:   "anomaly_mad_score",
+		input:  "select time(time, 60) as toi, pod, MADScore(Avg(rtt), 10) as mad_rtt from l4_flow_log group by toi, pod limit 10",
+		output: "WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, (AVGIf(rtt, rtt > 0) - arrayReduce('median', groupArray(AVGIf(rtt, rtt > 0)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN 10 PRECEDING AND 1 PRECEDING))) / nullIf(1.4826 * arrayReduce('median', arrayMap((v, m) -> abs(v - m), groupArray(AVGIf(rtt, rtt > 0)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN 10 PRECEDING AND 1 PRECEDING), arrayWithConstant(length(groupArray(AVGIf(rtt, rtt > 0)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN 10 PRECEDING AND 1 PRECEDING)), arrayReduce('median', groupArray(AVGIf(rtt, rtt > 0)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN 10 PRECEDING AND 1 PRECEDING))))), 0) AS `mad_rtt` FROM flow_log.`l4_flow_log` PREWHERE (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 10",
+	}, {
+		name:   "anomaly_changepoint",
+		input:  "select time(time, 60) as toi, pod, Changepoint(Sum(byte), 5) as cp_byte from l4_flow_log group by toi, pod limit 10",
+		output: "WITH toStartOfInterval(time, toIntervalSecond(60)) + toIntervalSecond(arrayJoin([0]) * 60) AS `_toi` SELECT dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod`, toUnixTimestamp(`_toi`) AS `toi`, abs(avg(SUM(byte_tx+byte_rx)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN CURRENT ROW AND 4 FOLLOWING) - avg(SUM(byte_tx+byte_rx)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN 5 PRECEDING AND 1 PRECEDING)) / nullIf(stddevPop(SUM(byte_tx+byte_rx)) OVER (PARTITION BY `pod` ORDER BY `toi` ROWS BETWEEN 5 PRECEDING AND 4 FOLLOWING), 0) AS `cp_byte` FROM flow_log.`l4_flow_log` PREWHERE (pod_id!=0) GROUP BY `toi`, dictGet(flow_tag.pod_map, 'name', (toUInt64(pod_id))) AS `pod` LIMIT 10",
+	}, {
+		name:   "custom_ip_tag",
+		input:  "select `tag.team_1` from l4_flow_log where `tag.team_1`='payment' group by `tag.team_1` limit 1",
+		output: "SELECT JSONExtractString(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4_1), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6_1), '{}')), if(JSONHas(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4_1), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6_1), '{}')), toString(l3_epc_id_1)), toString(l3_epc_id_1), '0'), 'team') AS `tag.team_1` FROM flow_log.`l4_flow_log` PREWHERE JSONExtractString(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4_1), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6_1), '{}')), if(JSONHas(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4_1), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6_1), '{}')), toString(l3_epc_id_1)), toString(l3_epc_id_1), '0'), 'team') = 'payment' AND (JSONExtractString(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4_1), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6_1), '{}')), if(JSONHas(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4_1), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6_1), '{}')), toString(l3_epc_id_1)), toString(l3_epc_id_1), '0'), 'team') != '') GROUP BY `tag.team_1` LIMIT 1",
+	}, {
+		name:       "custom_ip_tag_single_side",
+		input:      "select `tag.team` from vtap_flow_port where `tag.team`='payment' group by `tag.team` limit 1",
+		output:     "SELECT JSONExtractString(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6), '{}')), if(JSONHas(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6), '{}')), toString(l3_epc_id)), toString(l3_epc_id), '0'), 'team') AS `tag.team` FROM flow_metrics.`vtap_flow_port.1m` PREWHERE JSONExtractString(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6), '{}')), if(JSONHas(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6), '{}')), toString(l3_epc_id)), toString(l3_epc_id), '0'), 'team') = 'payment' AND (JSONExtractString(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6), '{}')), if(JSONHas(if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip4), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(ip6), '{}')), toString(l3_epc_id)), toString(l3_epc_id), '0'), 'team') != '') GROUP BY `tag.team` LIMIT 1",
+		db:         "flow_metrics",
+		datasource: "1m",
 	}}
 )
 
@@ -115,6 +115,8 @@ func (c *Client) DoQuery(params *QueryParams) (result *common.Result, err error)
 	if c.Context == nil {
 		ctx = context.Background()
 	}
+	// 按请求所属组织查询对应的数据库
+	sqlstr = common.OrgSql(sqlstr, common.GetOrgID(ctx))
 	rows, err := c.connection.Query(ctx, sqlstr)
 	c.Debug.Sql = sqlstr
 	if err != nil {
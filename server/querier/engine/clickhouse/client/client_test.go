@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/statsd"
+)
+
+// fakeConn 按 SQL 中的数据库返回该数据库中的行
+type fakeConn struct {
+	driver.Conn
+	databases map[string][]string
+}
+
+var fromRegexp = regexp.MustCompile("FROM `?(\\w+)`?\\.")
+
+func (c *fakeConn) Query(ctx context.Context, query string, args ...interface{}) (driver.Rows, error) {
+	matches := fromRegexp.FindStringSubmatch(query)
+	if matches == nil {
+		return nil, fmt.Errorf("no database in sql: %s", query)
+	}
+	rows, ok := c.databases[matches[1]]
+	if !ok {
+		return nil, fmt.Errorf("database %s doesn't exist", matches[1])
+	}
+	return &fakeRows{values: rows, index: -1}, nil
+}
+
+type fakeRows struct {
+	driver.Rows
+	values []string
+	index  int
+}
+
+func (r *fakeRows) Next() bool {
+	r.index++
+	return r.index < len(r.values)
+}
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	*dest[0].(*string) = r.values[r.index]
+	return nil
+}
+
+func (r *fakeRows) ColumnTypes() []driver.ColumnType { return []driver.ColumnType{fakeColumnType{}} }
+func (r *fakeRows) Close() error                     { return nil }
+func (r *fakeRows) Err() error                       { return nil }
+
+type fakeColumnType struct{}
+
+func (fakeColumnType) Name() string             { return "owner" }
+func (fakeColumnType) Nullable() bool           { return false }
+func (fakeColumnType) ScanType() reflect.Type   { return reflect.TypeOf("") }
+func (fakeColumnType) DatabaseTypeName() string { return "String" }
+
+func TestDoQueryOrgIsolation(t *testing.T) {
+	statsd.QuerierCounter = statsd.NewCounter()
+	origConnection := connection
+	defer func() { connection = origConnection }()
+	connection = &fakeConn{databases: map[string][]string{
+		"flow_metrics":         {"org1"},
+		"org0002_flow_metrics": {"org2"},
+		"org0003_flow_metrics": {"org3"},
+		"ext_metrics":          {"org1"},
+		"org0002_ext_metrics":  {"org2"},
+		"org0003_ext_metrics":  {"org3"},
+		"prometheus":           {"org1"},
+		"org0002_prometheus":   {"org2"},
+		"event":                {"org1"},
+		"org0002_event":        {"org2"},
+		"profile":              {"org1"},
+		"org0002_profile":      {"org2"},
+	}}
+
+	sqls := []string{
+		"SELECT owner FROM flow_metrics.`network.1m`",
+		"SELECT owner FROM `ext_metrics`.`metrics`",
+		"SELECT owner FROM prometheus.samples",
+		"SELECT owner FROM event.event",
+		"SELECT owner FROM profile.in_process",
+	}
+	for _, sql := range sqls {
+		c := &Client{Context: common.ContextWithOrgID(context.Background(), 2)}
+		result, err := c.DoQuery(&QueryParams{Sql: sql})
+		if err != nil {
+			t.Fatalf("DoQuery(%s) failed: %v", sql, err)
+		}
+		if len(result.Values) != 1 {
+			t.Fatalf("DoQuery(%s) = %v, want only rows of org2", sql, result.Values)
+		}
+		if owner := result.Values[0].([]interface{})[0]; owner != "org2" {
+			t.Errorf("DoQuery(%s) returned rows of %v, want org2", sql, owner)
+		}
+	}
+}
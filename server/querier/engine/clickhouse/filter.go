@@ -73,7 +73,7 @@ func GetWhere(name, value string) WhereStatement {
 	}
 }
 
-func TransWhereTagFunction(db string, name string, args []string) (filter string) {
+func TransWhereTagFunction(db, table string, name string, args []string) (filter string) {
 	funcName := strings.ToLower(name)
 	switch funcName {
 	case "exist":
@@ -130,6 +130,8 @@ func TransWhereTagFunction(db string, name string, args []string) (filter string
 			processIDSuffix := "gprocess_id" + suffix
 			tagNoPreffix := strings.TrimPrefix(resourceNoSuffix, "os.app.")
 			filter = fmt.Sprintf("toUInt64(%s) IN (SELECT pid FROM flow_tag.os_app_tag_map WHERE key='%s')", processIDSuffix, tagNoPreffix)
+		} else if customIPTag, customIPTagKey, ok := tag.GetCustomIPTag(args[0], db, table); ok {
+			filter = fmt.Sprintf(customIPTag.NotNullFilter, customIPTagKey)
 		} else if deviceTypeValue, ok = tag.TAP_PORT_DEVICE_MAP[resourceNoSuffix]; ok {
 			filter = fmt.Sprintf("(toUInt64(vtap_id),toUInt64(tap_port)) IN (SELECT vtap_id,tap_port FROM flow_tag.vtap_port_map WHERE tap_port!=0 AND device_type=%d)", deviceTypeValue)
 
@@ -152,6 +154,27 @@ func TransWhereTagFunction(db string, name string, args []string) (filter string
 				filter = strings.Join([]string{"auto_service_type", suffix, " not in (10)"}, "")
 			}
 		}
+	case "series_shard":
+		// series_shard(count, index) 按 target 将 prometheus 序列分为 count 个分片，仅保留第 index 个分片，
+		// 同一序列总在同一分片中，用于 PromQL 聚合查询的并行分片执行
+		if db != chCommon.DB_NAME_PROMETHEUS {
+			return
+		}
+		if len(args) != 2 {
+			log.Errorf("The parameters of function %s are not 2", funcName)
+			return
+		}
+		count, err := strconv.Atoi(args[0])
+		if err != nil || count <= 0 {
+			log.Errorf("The shard count of function %s is invalid: %s", funcName, args[0])
+			return
+		}
+		index, err := strconv.Atoi(args[1])
+		if err != nil || index < 0 || index >= count {
+			log.Errorf("The shard index of function %s is invalid: %s", funcName, args[1])
+			return
+		}
+		filter = fmt.Sprintf("target_id%%%d=%d", count, index)
 	}
 	return
 }
@@ -621,6 +644,13 @@ func (t *WhereTag) Trans(expr sqlparser.Expr, w *Where, e *CHEngine) (view.Node,
 								}
 								return &view.Expr{Value: filter}, nil
 							}
+						} else if customIPTag, customIPTagKey, ok := tag.GetCustomIPTag(preAsTag, db, table); ok {
+							if strings.Contains(op, "match") {
+								filter = fmt.Sprintf(customIPTag.WhereRegexpTranslator, op, customIPTagKey, t.Value)
+							} else {
+								filter = fmt.Sprintf(customIPTag.WhereTranslator, customIPTagKey, op, t.Value)
+							}
+							return &view.Expr{Value: filter}, nil
 						} else if strings.HasPrefix(preAsTag, "tag.") || strings.HasPrefix(preAsTag, "attribute.") {
 							if strings.HasPrefix(preAsTag, "tag.") {
 								if isRemoteRead {
@@ -818,6 +848,13 @@ func (t *WhereTag) Trans(expr sqlparser.Expr, w *Where, e *CHEngine) (view.Node,
 							}
 							return &view.Expr{Value: filter}, nil
 						}
+					} else if customIPTag, customIPTagKey, ok := tag.GetCustomIPTag(tagName, db, table); ok {
+						if strings.Contains(op, "match") {
+							filter = fmt.Sprintf(customIPTag.WhereRegexpTranslator, op, customIPTagKey, t.Value)
+						} else {
+							filter = fmt.Sprintf(customIPTag.WhereTranslator, customIPTagKey, op, t.Value)
+						}
+						return &view.Expr{Value: filter}, nil
 					} else if strings.HasPrefix(tagName, "tag.") || strings.HasPrefix(tagName, "attribute.") {
 						if strings.HasPrefix(tagName, "tag.") {
 							if isRemoteRead {
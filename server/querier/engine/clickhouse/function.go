@@ -224,6 +224,18 @@ func GetTopKTrans(name string, args []string, alias string, db string, table str
 }
 
 func GetBinaryFunc(name string, args []Function) (*BinaryFunction, error) {
+	if name == view.FUNCTION_BASELINE {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("function [%s] requires a metric and a period", name)
+		}
+		period, ok := args[1].(*Field)
+		if !ok {
+			return nil, fmt.Errorf("function [%s] period must be a constant", name)
+		}
+		if _, err := view.ParsePeriod(period.Value); err != nil {
+			return nil, fmt.Errorf("function [%s] %s", name, err)
+		}
+	}
 	return &BinaryFunction{
 		Name:      name,
 		Functions: args,
@@ -277,6 +289,20 @@ func (f *BinaryFunction) Trans(m *view.Model) view.Node {
 		function.SetTime(m.Time)
 		function.Init()
 		return function
+	} else if common.IsValueInSliceString(f.Name, view.WINDOW_FUNCTIONS) {
+		function := view.GetFunc(f.Name)
+		function.SetFields(fields[:1]) // metrics
+		var args []string
+		for _, field := range fields[1:] {
+			args = append(args, field.ToString()) // offset
+		}
+		function.SetArgs(args)
+		function.SetFlag(view.METRICS_FLAG_OUTER)
+		function.SetTime(m.Time)
+		function.Init()
+		// 分区依赖 GROUP BY，在 WriteTo 时才读取
+		function.(*view.WindowFunction).Groups = m.Groups
+		return function
 	}
 	function := view.GetFunc(f.Name)
 	function.SetFields(fields)
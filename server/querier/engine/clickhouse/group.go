@@ -252,6 +252,9 @@ func GetNotNullFilter(name string, asTagMap map[string]string, db, table string)
 					filterName = strings.TrimSuffix(filterName, "_1")
 					filter := fmt.Sprintf(tagItem.NotNullFilter, filterName)
 					return &view.Expr{Value: "(" + filter + ")"}, true
+				} else if customIPTag, customIPTagKey, ok := tag.GetCustomIPTag(preAsTag, db, table); ok {
+					filter := fmt.Sprintf(customIPTag.NotNullFilter, customIPTagKey)
+					return &view.Expr{Value: "(" + filter + ")"}, true
 				} else if strings.HasPrefix(preAsTag, "tag.") || strings.HasPrefix(preAsTag, "attribute.") {
 					if db == chCommon.DB_NAME_PROMETHEUS {
 						return &view.Expr{}, false
@@ -337,6 +340,9 @@ func GetNotNullFilter(name string, asTagMap map[string]string, db, table string)
 				filterName = strings.TrimSuffix(filterName, "_1")
 				filter := fmt.Sprintf(tagItem.NotNullFilter, filterName)
 				return &view.Expr{Value: "(" + filter + ")"}, true
+			} else if customIPTag, customIPTagKey, ok := tag.GetCustomIPTag(name, db, table); ok {
+				filter := fmt.Sprintf(customIPTag.NotNullFilter, customIPTagKey)
+				return &view.Expr{Value: "(" + filter + ")"}, true
 			} else if strings.HasPrefix(name, "tag.") || strings.HasPrefix(name, "attribute.") {
 				if db == chCommon.DB_NAME_PROMETHEUS {
 					return &view.Expr{}, false
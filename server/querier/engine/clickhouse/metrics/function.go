@@ -50,6 +50,8 @@ var METRICS_FUNCTIONS = []string{
 	view.FUNCTION_UNIQ, view.FUNCTION_UNIQ_EXACT, view.FUNCTION_PERCENTAG,
 	view.FUNCTION_PERSECOND, view.FUNCTION_HISTOGRAM, view.FUNCTION_LAST, view.FUNCTION_COUNT,
 	view.FUNCTION_TOPK, view.FUNCTION_ANY,
+	view.FUNCTION_LAG, view.FUNCTION_LEAD, view.FUNCTION_MOVING_AVG, view.FUNCTION_RANK, view.FUNCTION_PERIOD_DELTA,
+	view.FUNCTION_BASELINE, view.FUNCTION_ZSCORE, view.FUNCTION_MAD_SCORE, view.FUNCTION_CHANGEPOINT,
 }
 
 var METRICS_FUNCTIONS_MAP = map[string]*Function{
@@ -74,6 +76,17 @@ var METRICS_FUNCTIONS_MAP = map[string]*Function{
 	view.FUNCTION_TOPK:       NewFunction(view.FUNCTION_TOPK, FUNCTION_TYPE_AGG, []int{METRICS_TYPE_TAG}, "$unit", 1, false, "String"),
 	view.FUNCTION_ANY:        NewFunction(view.FUNCTION_ANY, FUNCTION_TYPE_AGG, []int{METRICS_TYPE_TAG}, "$unit", 0, false, "String"),
 	view.FUNCTION_DERIVATIVE: NewFunction(view.FUNCTION_DERIVATIVE, FUNCTION_TYPE_AGG, []int{METRICS_TYPE_COUNTER}, "$unit", 0, true, "Number"),
+	// 窗口算子
+	view.FUNCTION_LAG:          NewFunction(view.FUNCTION_LAG, FUNCTION_TYPE_MATH, []int{METRICS_TYPE_COUNTER, METRICS_TYPE_GAUGE, METRICS_TYPE_DELAY, METRICS_TYPE_PERCENTAGE, METRICS_TYPE_QUOTIENT, METRICS_TYPE_BOUNDED_GAUGE}, "$unit", 1, true, "Number"),
+	view.FUNCTION_LEAD:         NewFunction(view.FUNCTION_LEAD, FUNCTION_TYPE_MATH, []int{METRICS_TYPE_COUNTER, METRICS_TYPE_GAUGE, METRICS_TYPE_DELAY, METRICS_TYPE_PERCENTAGE, METRICS_TYPE_QUOTIENT, METRICS_TYPE_BOUNDED_GAUGE}, "$unit", 1, true, "Number"),
+	view.FUNCTION_MOVING_AVG:   NewFunction(view.FUNCTION_MOVING_AVG, FUNCTION_TYPE_MATH, []int{METRICS_TYPE_COUNTER, METRICS_TYPE_GAUGE, METRICS_TYPE_DELAY, METRICS_TYPE_PERCENTAGE, METRICS_TYPE_QUOTIENT, METRICS_TYPE_BOUNDED_GAUGE}, "$unit", 1, true, "Number"),
+	view.FUNCTION_RANK:         NewFunction(view.FUNCTION_RANK, FUNCTION_TYPE_MATH, []int{METRICS_TYPE_COUNTER, METRICS_TYPE_GAUGE, METRICS_TYPE_DELAY, METRICS_TYPE_PERCENTAGE, METRICS_TYPE_QUOTIENT, METRICS_TYPE_BOUNDED_GAUGE}, "", 0, true, "Number"),
+	view.FUNCTION_PERIOD_DELTA: NewFunction(view.FUNCTION_PERIOD_DELTA, FUNCTION_TYPE_MATH, []int{METRICS_TYPE_COUNTER, METRICS_TYPE_GAUGE, METRICS_TYPE_DELAY, METRICS_TYPE_PERCENTAGE, METRICS_TYPE_QUOTIENT, METRICS_TYPE_BOUNDED_GAUGE}, "$unit", 1, true, "Number"),
+	// 异常检测算子
+	view.FUNCTION_BASELINE:    NewFunction(view.FUNCTION_BASELINE, FUNCTION_TYPE_MATH, nil, "$unit", 1, true, "Number"),
+	view.FUNCTION_ZSCORE:      NewFunction(view.FUNCTION_ZSCORE, FUNCTION_TYPE_MATH, nil, "", 1, true, "Number"),
+	view.FUNCTION_MAD_SCORE:   NewFunction(view.FUNCTION_MAD_SCORE, FUNCTION_TYPE_MATH, nil, "", 1, true, "Number"),
+	view.FUNCTION_CHANGEPOINT: NewFunction(view.FUNCTION_CHANGEPOINT, FUNCTION_TYPE_MATH, nil, "", 1, true, "Number"),
 }
 
 func GetFunctionDescriptions() (*common.Result, error) {
@@ -35,6 +35,9 @@ var DB_FIELD_CLIENT_ERROR = fmt.Sprintf(
 var DB_FIELD_SERVER_ERROR = fmt.Sprintf(
 	"if(response_status IN [%d],1,0)", FLOW_LOG_EXCEPTION_SERVER,
 )
+
+// 入库采样时记录了采样率，每行代表 1/sampling_rate 条原始日志
+var DB_FIELD_SAMPLING_WEIGHT = "if(sampling_rate>0,1/sampling_rate,1)"
 var DB_FIELD_SESSION_LENGTH = "if(request_length>0,request_length,0)+if(response_length>0,response_length,0)"
 
 var L7_FLOW_LOG_METRICS = map[string]*Metrics{}
@@ -50,6 +53,11 @@ var L7_FLOW_LOG_METRICS_REPLACE = map[string]*Metrics{
 	"client_error_ratio": NewReplaceMetrics(DB_FIELD_CLIENT_ERROR+"/"+DB_FIELD_RESPONSE, DB_FIELD_RESPONSE+">0"),
 	"server_error_ratio": NewReplaceMetrics(DB_FIELD_SERVER_ERROR+"/"+DB_FIELD_RESPONSE, DB_FIELD_RESPONSE+">0"),
 	"session_length":     NewReplaceMetrics(DB_FIELD_SESSION_LENGTH, "").SetIsAgg(false),
+
+	"log_count_extrapolated": NewReplaceMetrics(DB_FIELD_SAMPLING_WEIGHT, ""),
+	"request_extrapolated":   NewReplaceMetrics(DB_FIELD_REQUEST+"*"+DB_FIELD_SAMPLING_WEIGHT, ""),
+	"response_extrapolated":  NewReplaceMetrics(DB_FIELD_RESPONSE+"*"+DB_FIELD_SAMPLING_WEIGHT, ""),
+	"error_extrapolated":     NewReplaceMetrics(DB_FIELD_ERROR+"*"+DB_FIELD_SAMPLING_WEIGHT, ""),
 }
 
 func GetL7FlowLogMetrics() map[string]*Metrics {
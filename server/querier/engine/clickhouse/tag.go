@@ -125,6 +125,9 @@ func GetTagTranslator(name, alias, db, table string) ([]Statement, string, error
 			}
 			// callback
 			stmts = append(stmts, &SelectTag{Value: name})
+		} else if customIPTag, customIPTagKey, ok := tag.GetCustomIPTag(name, db, table); ok {
+			TagTranslatorStr := fmt.Sprintf(customIPTag.TagTranslator, customIPTagKey)
+			stmts = append(stmts, &SelectTag{Value: TagTranslatorStr, Alias: selectTag})
 		} else if strings.HasPrefix(name, "tag.") || strings.HasPrefix(name, "attribute.") {
 			if strings.HasPrefix(name, "tag.") {
 				if db == chCommon.DB_NAME_PROMETHEUS {
@@ -18,6 +18,10 @@ package tag
 
 import (
 	"strings"
+
+	"golang.org/x/exp/slices"
+
+	ckcommon "github.com/deepflowio/deepflow/server/querier/engine/clickhouse/common"
 )
 
 type Tag struct {
@@ -49,3 +53,36 @@ func GetTag(name, db, table, function string) (*Tag, bool) {
 	}
 	return tag, ok
 }
+
+// 带有 ip4/ip6/l3_epc_id 字段的单端表
+var CUSTOM_IP_TAG_SINGLE_SIDE_TABLES = []string{"vtap_flow_port", "vtap_app_port"}
+
+// 带有 ip4_0/ip6_0/l3_epc_id_0 等字段的双端表
+var CUSTOM_IP_TAG_EDGE_TABLES = []string{"l4_flow_log", "l7_flow_log", "vtap_flow_edge_port", "vtap_app_edge_port"}
+
+// GetCustomIPTag 解析 flow_log/flow_metrics 中的自定义 IP 业务标签，双端表中为 tag.<key>_0/tag.<key>_1，
+// 单端表中为 tag.<key>，返回对应的翻译及标签名；其他表中的 tag.<key> 仍为外部标签
+func GetCustomIPTag(name, db, table string) (*Tag, string, bool) {
+	name = strings.Trim(name, "`")
+	if (db != ckcommon.DB_NAME_FLOW_LOG && db != ckcommon.DB_NAME_FLOW_METRICS) || !strings.HasPrefix(name, "tag.") {
+		return &Tag{}, "", false
+	}
+	suffix := ""
+	if slices.Contains(CUSTOM_IP_TAG_EDGE_TABLES, table) {
+		if strings.HasSuffix(name, "_0") {
+			suffix = "_0"
+		} else if strings.HasSuffix(name, "_1") {
+			suffix = "_1"
+		} else {
+			return &Tag{}, "", false
+		}
+	} else if !slices.Contains(CUSTOM_IP_TAG_SINGLE_SIDE_TABLES, table) {
+		return &Tag{}, "", false
+	}
+	key := strings.TrimSuffix(strings.TrimPrefix(name, "tag."), suffix)
+	if key == "" || strings.ContainsAny(key, "'\\") {
+		return &Tag{}, "", false
+	}
+	tag, ok := GetTag("custom_ip_tag"+suffix, db, table, "default")
+	return tag, key, ok
+}
@@ -716,6 +716,26 @@ func GenerateTagResoureMap() map[string]map[string]*Tag {
 		}
 	}
 
+	// 自定义 IP 业务标签-flow_log/flow_metrics
+	// custom_ip_tag_map 为 IP_TRIE 字典，tags 为 l3_epc_id 到标签的 json，未匹配到 VPC 时使用 0（所有 VPC）
+	// 无后缀用于单端表，_0/_1 用于双端表
+	for _, suffix := range []string{"", "_0", "_1"} {
+		customIPTagSuffix := "custom_ip_tag" + suffix
+		ip4Suffix := "ip4" + suffix
+		ip6Suffix := "ip6" + suffix
+		l3EPCIDSuffix := "l3_epc_id" + suffix
+		customIPTags := "if(is_ipv4=1, dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(" + ip4Suffix + "), '{}'), dictGetOrDefault(flow_tag.custom_ip_tag_map, 'tags', tuple(" + ip6Suffix + "), '{}'))"
+		customIPTag := "JSONExtractString(" + customIPTags + ", if(JSONHas(" + customIPTags + ", toString(" + l3EPCIDSuffix + ")), toString(" + l3EPCIDSuffix + "), '0'), '%s')"
+		tagResourceMap[customIPTagSuffix] = map[string]*Tag{
+			"default": NewTag(
+				customIPTag,
+				customIPTag+" != ''",
+				customIPTag+" %s %v",
+				"%s("+customIPTag+",%v)",
+			),
+		}
+	}
+
 	// 单个外部字段-ext_metrics
 	tagResourceMap["tag."] = map[string]*Tag{
 		"default": NewTag(
@@ -54,6 +54,17 @@ const (
 	FUNCTION_TOPK        = "TopK"
 	FUNCTION_ANY         = "Any"
 	FUNCTION_DERIVATIVE  = "nonNegativeDerivative"
+	// 窗口算子，在计算层外层按分组标签分区、按时间排序计算
+	FUNCTION_LAG          = "Lag"
+	FUNCTION_LEAD         = "Lead"
+	FUNCTION_MOVING_AVG   = "MovingAvg"
+	FUNCTION_RANK         = "Rank"
+	FUNCTION_PERIOD_DELTA = "PeriodDelta"
+	// 异常检测算子，基于窗口内的历史值计算
+	FUNCTION_BASELINE    = "Baseline"
+	FUNCTION_ZSCORE      = "ZScore"
+	FUNCTION_MAD_SCORE   = "MADScore"
+	FUNCTION_CHANGEPOINT = "Changepoint"
 )
 
 // 对外提供的算子与数据库实际算子转换
@@ -82,6 +93,13 @@ var FUNC_NAME_MAP map[string]string = map[string]string{
 var MATH_FUNCTIONS = []string{
 	FUNCTION_DIV, FUNCTION_PLUS, FUNCTION_MINUS, FUNCTION_MULTIPLY,
 	FUNCTION_PERCENTAG, FUNCTION_PERSECOND, FUNCTION_HISTOGRAM,
+	FUNCTION_LAG, FUNCTION_LEAD, FUNCTION_MOVING_AVG, FUNCTION_RANK, FUNCTION_PERIOD_DELTA,
+	FUNCTION_BASELINE, FUNCTION_ZSCORE, FUNCTION_MAD_SCORE, FUNCTION_CHANGEPOINT,
+}
+
+var WINDOW_FUNCTIONS = []string{
+	FUNCTION_LAG, FUNCTION_LEAD, FUNCTION_MOVING_AVG, FUNCTION_RANK, FUNCTION_PERIOD_DELTA,
+	FUNCTION_BASELINE, FUNCTION_ZSCORE, FUNCTION_MAD_SCORE, FUNCTION_CHANGEPOINT,
 }
 
 func GetFunc(name string) Function {
@@ -108,6 +126,9 @@ func GetFunc(name string) Function {
 		return &DelayAvgFunction{DefaultFunction: DefaultFunction{Name: FUNC_NAME_MAP[FUNCTION_AAVG]}}
 	case FUNCTION_DERIVATIVE:
 		return &NonNegativeDerivativeFunction{DefaultFunction: DefaultFunction{Name: name}}
+	case FUNCTION_LAG, FUNCTION_LEAD, FUNCTION_MOVING_AVG, FUNCTION_RANK, FUNCTION_PERIOD_DELTA,
+		FUNCTION_BASELINE, FUNCTION_ZSCORE, FUNCTION_MAD_SCORE, FUNCTION_CHANGEPOINT:
+		return &WindowFunction{DefaultFunction: DefaultFunction{Name: name}}
 	default:
 		return &DefaultFunction{Name: name}
 	}
@@ -975,3 +996,146 @@ func (f *NonNegativeDerivativeFunction) WriteTo(buf *bytes.Buffer) {
 		buf.WriteString("`")
 	}
 }
+
+// WindowFunction 窗口算子，作用于计算层外层的聚合结果
+// 分区为除时间外的全部分组标签，排序为时间，例：
+// Lag(Sum(byte), 1) => lagInFrame(SUM(byte), 1) OVER (PARTITION BY `pod` ORDER BY `time` ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING)
+type WindowFunction struct {
+	DefaultFunction
+	Groups *Groups
+}
+
+func (f *WindowFunction) getPartitionBy() []string {
+	partitionBy := []string{}
+	if f.Groups == nil {
+		return partitionBy
+	}
+	for _, node := range f.Groups.groups {
+		group, ok := node.(*Group)
+		if !ok || group.Flag == GROUP_FLAG_METRICS_INNTER {
+			continue
+		}
+		name := group.Alias
+		if name == "" {
+			name = group.Value
+		}
+		name = strings.Trim(name, "`")
+		if f.Time != nil && name == f.Time.Alias {
+			continue
+		}
+		if strings.Contains(name, ",") {
+			partitionBy = append(partitionBy, name)
+		} else {
+			partitionBy = append(partitionBy, fmt.Sprintf("`%s`", name))
+		}
+	}
+	return partitionBy
+}
+
+func (f *WindowFunction) getOffset() int {
+	offset := 1
+	if len(f.Args) > 0 {
+		if n, err := strconv.Atoi(strings.TrimSpace(f.Args[0])); err == nil && n > 0 {
+			offset = n
+		}
+	}
+	return offset
+}
+
+var PERIOD_UNIT_TO_SECONDS = map[string]int{"": 1, "s": 1, "m": 60, "h": 3600, "d": 86400, "w": 7 * 86400}
+
+// ParsePeriod 解析季节性周期，支持秒数或带单位的时长，例：86400、'1d'、'1w'，周期必须为正整数秒
+func ParsePeriod(arg string) (int, error) {
+	period := strings.Trim(strings.TrimSpace(arg), "'")
+	number := strings.TrimRightFunc(period, func(r rune) bool { return r < '0' || r > '9' })
+	unit, ok := PERIOD_UNIT_TO_SECONDS[period[len(number):]]
+	if !ok {
+		return 0, fmt.Errorf("period [%s] unit is not supported, it should be one of s, m, h, d, w", arg)
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("period [%s] is not a positive integer", arg)
+	}
+	return n * unit, nil
+}
+
+// 周期在解析函数时已校验
+func (f *WindowFunction) getPeriod() int {
+	if len(f.Args) == 0 {
+		return 0
+	}
+	period, _ := ParsePeriod(f.Args[0])
+	return period
+}
+
+func (f *WindowFunction) WriteTo(buf *bytes.Buffer) {
+	field := f.Fields[0].ToString()
+	timeAlias := ""
+	if f.Time != nil && f.Time.Alias != "" {
+		timeAlias = fmt.Sprintf("`%s`", f.Time.Alias)
+	}
+	over := func(frame string) string {
+		partitionBy := f.getPartitionBy()
+		over := ""
+		if len(partitionBy) > 0 {
+			over = fmt.Sprintf("PARTITION BY %s ", strings.Join(partitionBy, ", "))
+		}
+		if timeAlias != "" {
+			over += fmt.Sprintf("ORDER BY %s ", timeAlias)
+		}
+		return fmt.Sprintf("OVER (%s%s)", over, frame)
+	}
+	offset := f.getOffset()
+	switch f.Name {
+	case FUNCTION_LAG, FUNCTION_LEAD:
+		// lag/lead 在 ClickHouse 中以 lagInFrame/leadInFrame 实现，需要显式指定完整窗口
+		buf.WriteString(fmt.Sprintf("%sInFrame(%s, %d) %s", strings.ToLower(f.Name), field, offset, over("ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING")))
+	case FUNCTION_MOVING_AVG:
+		buf.WriteString(fmt.Sprintf("avg(%s) %s", field, over(fmt.Sprintf("ROWS BETWEEN %d PRECEDING AND CURRENT ROW", offset-1))))
+	case FUNCTION_RANK:
+		// 同一时间点内按指标量降序排名
+		partitionBy := ""
+		if timeAlias != "" {
+			partitionBy = fmt.Sprintf("PARTITION BY %s ", timeAlias)
+		}
+		buf.WriteString(fmt.Sprintf("rank() OVER (%sORDER BY %s DESC)", partitionBy, field))
+	case FUNCTION_PERIOD_DELTA:
+		// 与 N 个时间间隔之前的值作差，按时间范围取值以避免缺失的时间点导致错位
+		if f.Time != nil && f.Time.Interval > 0 && timeAlias != "" {
+			step := offset * f.Time.Interval
+			buf.WriteString(fmt.Sprintf("%s - anyOrNull(%s) %s", field, field, over(fmt.Sprintf("RANGE BETWEEN %d PRECEDING AND %d PRECEDING", step, step))))
+		} else {
+			buf.WriteString(fmt.Sprintf("%s - lagInFrame(%s, %d) %s", field, field, offset, over("ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW")))
+		}
+	case FUNCTION_BASELINE:
+		// 同比基线：一个周期（如一天、一周）前同一时间点的值，查询时间范围需要覆盖该周期
+		if timeAlias != "" {
+			period := f.getPeriod()
+			buf.WriteString(fmt.Sprintf("anyOrNull(%s) %s", field, over(fmt.Sprintf("RANGE BETWEEN %d PRECEDING AND %d PRECEDING", period, period))))
+		} else {
+			buf.WriteString("NULL")
+		}
+	case FUNCTION_ZSCORE:
+		// 当前值相对之前 N 个时间点的标准分数
+		history := over(fmt.Sprintf("ROWS BETWEEN %d PRECEDING AND 1 PRECEDING", offset))
+		buf.WriteString(fmt.Sprintf("(%s - avg(%s) %s) / nullIf(stddevPop(%s) %s, 0)", field, field, history, field, history))
+	case FUNCTION_MAD_SCORE:
+		// 当前值相对之前 N 个时间点的中位数绝对偏差分数，1.4826 使其在正态分布下与标准分数一致
+		values := fmt.Sprintf("groupArray(%s) %s", field, over(fmt.Sprintf("ROWS BETWEEN %d PRECEDING AND 1 PRECEDING", offset)))
+		median := fmt.Sprintf("arrayReduce('median', %s)", values)
+		mad := fmt.Sprintf("arrayReduce('median', arrayMap((v, m) -> abs(v - m), %s, arrayWithConstant(length(%s), %s)))", values, values, median)
+		buf.WriteString(fmt.Sprintf("(%s - %s) / nullIf(1.4826 * %s, 0)", field, median, mad))
+	case FUNCTION_CHANGEPOINT:
+		// 变点分数：当前时间点前后各 N 个点的均值之差与整体标准差之比，值越大越可能在该点发生了突变
+		before := over(fmt.Sprintf("ROWS BETWEEN %d PRECEDING AND 1 PRECEDING", offset))
+		after := over(fmt.Sprintf("ROWS BETWEEN CURRENT ROW AND %d FOLLOWING", offset-1))
+		both := over(fmt.Sprintf("ROWS BETWEEN %d PRECEDING AND %d FOLLOWING", offset, offset-1))
+		buf.WriteString(fmt.Sprintf("abs(avg(%s) %s - avg(%s) %s) / nullIf(stddevPop(%s) %s, 0)", field, after, field, before, field, both))
+	}
+	if f.Alias != "" {
+		buf.WriteString(" AS ")
+		buf.WriteString("`")
+		buf.WriteString(strings.Trim(f.Alias, "`"))
+		buf.WriteString("`")
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "context"
+
+type NetworkPath struct {
+	TimeStart int `json:"time_start" binding:"required"`
+	TimeEnd   int `json:"time_end" binding:"required"`
+	// 按五元组指定流，Protocol 默认为 6（TCP）
+	IP0        string `json:"ip_0"`
+	IP1        string `json:"ip_1"`
+	ClientPort int    `json:"client_port"`
+	ServerPort int    `json:"server_port"`
+	Protocol   int    `json:"protocol"`
+	// 或按 l7_flow_log 的 _id 指定一个 L7 请求，使用其五元组
+	L7FlowID string `json:"l7_flow_id"`
+	Debug    bool   `json:"debug"`
+	Context  context.Context
+}
+
+type Flow struct {
+	IP0        string `json:"ip_0"`
+	IP1        string `json:"ip_1"`
+	ClientPort int    `json:"client_port"`
+	ServerPort int    `json:"server_port"`
+	Protocol   int    `json:"protocol"`
+	// 按 l7_flow_id 指定时为 L7 请求的时间范围，仅使用与之重叠的流日志，避免匹配到复用端口的其他连接
+	StartTimeUs int64 `json:"-"`
+	EndTimeUs   int64 `json:"-"`
+}
+
+type Path struct {
+	Flow   Flow     `json:"flow"`
+	Points []*Point `json:"points"`
+	Hops   []*Hop   `json:"hops"`
+	// 相邻两个有 RTT 的观测点之间的时延，中间没有 RTT 的观测点被跨越
+	LatencySpans []*LatencySpan `json:"latency_spans"`
+	Segments     []*Segment     `json:"segments"`
+	// 时延最大的区段，无法计算时延时为空
+	Bottleneck string `json:"bottleneck"`
+	// 流日志超过查询上限被截断，路径可能不完整
+	Truncated bool `json:"truncated"`
+	// 有观测点看到多次建连，时间范围内可能有复用相同五元组的多个连接
+	MultipleConnections bool `json:"multiple_connections"`
+}
+
+// Point 为一个采集器在一个观测点（tap_side）上对该流的观测，多条流日志合并为一个观测点
+type Point struct {
+	TapSide     string `json:"tap_side"`
+	TapSideName string `json:"tap_side_name"`
+	Zone        string `json:"zone"`
+	Vtap        string `json:"vtap"`
+	VtapID      int    `json:"vtap_id"`
+	TapPortName string `json:"tap_port_name"`
+	StartTimeUs int64  `json:"start_time_us"`
+	EndTimeUs   int64  `json:"end_time_us"`
+	FlowLogs    int    `json:"flow_logs"`
+	// 带有 RTT 的流日志数，即观测到的建连次数
+	Handshakes int `json:"handshakes"`
+
+	RTT       float64 `json:"rtt"`
+	RTTClient float64 `json:"rtt_client"`
+	RTTServer float64 `json:"rtt_server"`
+	SRTMax    float64 `json:"srt_max"`
+	ARTMax    float64 `json:"art_max"`
+	RetransTx int64   `json:"retrans_tx"`
+	RetransRx int64   `json:"retrans_rx"`
+	ZeroWinTx int64   `json:"zero_win_tx"`
+	ZeroWinRx int64   `json:"zero_win_rx"`
+	ClientRst int64   `json:"client_rst"`
+	ServerRst int64   `json:"server_rst"`
+	PacketTx  int64   `json:"packet_tx"`
+	PacketRx  int64   `json:"packet_rx"`
+}
+
+// Hop 为路径上相邻两个观测点之间的一跳，From、To 为 Points 的下标
+type Hop struct {
+	From    int     `json:"from"`
+	To      int     `json:"to"`
+	Segment string  `json:"segment"`
+	Latency float64 `json:"latency"`
+	// 任一观测点没有 RTT 时无法计算这一跳的时延，时延计入跨越这一跳的 LatencySpan
+	LatencyMeasured bool `json:"latency_measured"`
+	// To 与 From 观测值之差，不为 0 说明异常仅在一侧观测到，由这一跳引入或消除
+	RetransDiff int64 `json:"retrans_diff"`
+	ZeroWinDiff int64 `json:"zero_win_diff"`
+	RstDiff     int64 `json:"rst_diff"`
+}
+
+// LatencySpan 为两个有 RTT 的观测点之间的时延，From、To 为 Points 的下标，
+// Segment 为两个观测点之间跨越的区段
+type LatencySpan struct {
+	From    int     `json:"from"`
+	To      int     `json:"to"`
+	Segment string  `json:"segment"`
+	Latency float64 `json:"latency"`
+}
+
+type Segment struct {
+	Name    string  `json:"name"`
+	Latency float64 `json:"latency"`
+	// 占路径总时延的百分比
+	LatencyRatio float64 `json:"latency_ratio"`
+}
+
+type Debug struct {
+	IP        string `json:"ip"`
+	Sql       string `json:"sql"`
+	SqlCH     string `json:"sql_CH"`
+	QueryTime string `json:"query_time"`
+	QueryUUID string `json:"query_uuid"`
+	Error     string `json:"error"`
+}
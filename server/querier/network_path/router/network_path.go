@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/network_path/model"
+	"github.com/deepflowio/deepflow/server/querier/network_path/service"
+	"github.com/deepflowio/deepflow/server/querier/router"
+)
+
+func NetworkPathRouter(e *gin.Engine) {
+	e.POST("/v1/network-path/", networkPath())
+}
+
+func networkPath() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.NetworkPath
+
+		// 参数校验
+		err := c.ShouldBindBodyWith(&args, binding.JSON)
+		if err != nil {
+			router.BadRequestResponse(c, common.INVALID_POST_DATA, err.Error())
+			return
+		}
+		args.Context = c.Request.Context()
+		path, debug, err := service.NetworkPath(args)
+		if err == nil && !args.Debug {
+			debug = nil
+		}
+		router.JsonResponse(c, path, debug, err)
+	})
+}
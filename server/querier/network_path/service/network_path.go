@@ -0,0 +1,504 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	"github.com/deepflowio/deepflow/server/querier/network_path/model"
+)
+
+var log = logging.MustGetLogger("network_path")
+
+const (
+	DATABASE_FLOW_LOG  = "flow_log"
+	TABLE_L4_FLOW_LOG  = "l4_flow_log"
+	TABLE_L7_FLOW_LOG  = "l7_flow_log"
+	PROTOCOL_TCP       = 6
+	QUERY_LIMIT        = 1000
+	ZONE_CLIENT        = "client-node"
+	ZONE_GATEWAY       = "gateway"
+	ZONE_SERVER        = "server-node"
+	ZONE_NETWORK       = "network"
+	TAP_SIDE_REST_RANK = 6
+)
+
+// 观测点从客户端到服务端的顺序，同一位置的多个观测点按开始时间排序
+var TAP_SIDE_RANK = map[string]int{
+	"c-p":     0,
+	"c":       1,
+	"c-nd":    2,
+	"c-hv":    3,
+	"c-gw-hv": 4,
+	"c-gw":    5,
+	"local":   TAP_SIDE_REST_RANK,
+	"rest":    TAP_SIDE_REST_RANK,
+	"s-gw":    7,
+	"s-gw-hv": 8,
+	"s-hv":    9,
+	"s-nd":    10,
+	"s":       11,
+	"s-p":     12,
+}
+
+var TAP_SIDE_ZONE = map[string]string{
+	"c-p":     ZONE_CLIENT,
+	"c":       ZONE_CLIENT,
+	"c-nd":    ZONE_CLIENT,
+	"c-hv":    ZONE_CLIENT,
+	"c-gw-hv": ZONE_GATEWAY,
+	"c-gw":    ZONE_GATEWAY,
+	"s-gw":    ZONE_GATEWAY,
+	"s-gw-hv": ZONE_GATEWAY,
+	"s-hv":    ZONE_SERVER,
+	"s-nd":    ZONE_SERVER,
+	"s":       ZONE_SERVER,
+	"s-p":     ZONE_SERVER,
+}
+
+var L4_FLOW_LOG_FIELDS = []string{
+	"tap_side", "Enum(tap_side)", "vtap", "vtap_id", "tap_port_name",
+	"toUnixTimestamp64Micro(start_time) AS `start_time_us`", "toUnixTimestamp64Micro(end_time) AS `end_time_us`",
+	"rtt", "rtt_client", "rtt_server", "srt_max", "art_max",
+	"retrans_tx", "retrans_rx", "zero_win_tx", "zero_win_rx", "client_rst_flow", "server_rst_flow",
+	"packet_tx", "packet_rx",
+}
+
+func NetworkPath(args model.NetworkPath) (path *model.Path, debug interface{}, err error) {
+	if args.TimeStart >= args.TimeEnd {
+		return nil, nil, common.NewError(common.INVALID_POST_DATA, "time_start must be less than time_end")
+	}
+	debugs := []model.Debug{}
+	var flow model.Flow
+	if args.L7FlowID != "" {
+		if _, err := strconv.ParseUint(args.L7FlowID, 10, 64); err != nil {
+			return nil, nil, common.NewError(common.INVALID_POST_DATA, fmt.Sprintf("l7_flow_id (%s) is invalid", args.L7FlowID))
+		}
+		var l7Debug model.Debug
+		flow, l7Debug, err = queryL7Flow(args)
+		debugs = append(debugs, l7Debug)
+		if err != nil {
+			return nil, debugs, err
+		}
+	} else {
+		flow = model.Flow{IP0: args.IP0, IP1: args.IP1, ClientPort: args.ClientPort, ServerPort: args.ServerPort, Protocol: args.Protocol}
+		if flow.Protocol == 0 {
+			flow.Protocol = PROTOCOL_TCP
+		}
+	}
+	if net.ParseIP(flow.IP0) == nil || net.ParseIP(flow.IP1) == nil {
+		return nil, debugs, common.NewError(common.INVALID_POST_DATA, "ip_0 and ip_1 must be valid ip addresses, or specify l7_flow_id")
+	}
+
+	// 多查询一条用于判断是否超过上限
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY `start_time_us` LIMIT %d",
+		strings.Join(L4_FLOW_LOG_FIELDS, ", "), TABLE_L4_FLOW_LOG, flowFilter(flow, args.TimeStart, args.TimeEnd), QUERY_LIMIT+1)
+	result, l4Debug, err := query(args, sql)
+	debugs = append(debugs, l4Debug)
+	if err != nil {
+		return nil, debugs, err
+	}
+	truncated := false
+	if result != nil && len(result.Values) > QUERY_LIMIT {
+		result.Values = result.Values[:QUERY_LIMIT]
+		truncated = true
+	}
+	if flow.EndTimeUs > 0 {
+		filterByTime(result, flow.StartTimeUs, flow.EndTimeUs)
+	}
+	path = BuildPath(result)
+	path.Flow = flow
+	path.Truncated = truncated
+	return path, debugs, nil
+}
+
+// filterByTime 只保留与 [startTimeUs, endTimeUs] 重叠的流日志
+func filterByTime(result *common.Result, startTimeUs, endTimeUs int64) {
+	if result == nil {
+		return
+	}
+	r := newResultRows(result)
+	values := result.Values[:0]
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		if r.Int(row, "start_time_us") <= endTimeUs && r.Int(row, "end_time_us") >= startTimeUs {
+			values = append(values, value)
+		}
+	}
+	result.Values = values
+}
+
+func flowFilter(flow model.Flow, timeStart, timeEnd int) string {
+	return fmt.Sprintf("time>=%d AND time<=%d AND ip_0='%s' AND ip_1='%s' AND client_port=%d AND server_port=%d AND protocol=%d",
+		timeStart, timeEnd, flow.IP0, flow.IP1, flow.ClientPort, flow.ServerPort, flow.Protocol)
+}
+
+// queryL7Flow 查询 L7 请求的五元组，IP 经过 net.ParseIP 校验后才拼入 L4 的查询条件
+func queryL7Flow(args model.NetworkPath) (model.Flow, model.Debug, error) {
+	sql := fmt.Sprintf("SELECT ip_0, ip_1, client_port, server_port, protocol, "+
+		"toUnixTimestamp64Micro(start_time) AS `start_time_us`, toUnixTimestamp64Micro(end_time) AS `end_time_us` "+
+		"FROM %s WHERE time>=%d AND time<=%d AND _id=%s LIMIT 1",
+		TABLE_L7_FLOW_LOG, args.TimeStart, args.TimeEnd, args.L7FlowID)
+	result, debug, err := query(args, sql)
+	if err != nil {
+		return model.Flow{}, debug, err
+	}
+	rows := newResultRows(result)
+	if len(rows.rows()) == 0 {
+		return model.Flow{}, debug, common.NewError(common.RESOURCE_NOT_FOUND, fmt.Sprintf("l7 flow (%s) not found", args.L7FlowID))
+	}
+	row := rows.rows()[0]
+	return model.Flow{
+		IP0:         rows.String(row, "ip_0"),
+		IP1:         rows.String(row, "ip_1"),
+		ClientPort:  int(rows.Float(row, "client_port")),
+		ServerPort:  int(rows.Float(row, "server_port")),
+		Protocol:    int(rows.Float(row, "protocol")),
+		StartTimeUs: rows.Int(row, "start_time_us"),
+		EndTimeUs:   rows.Int(row, "end_time_us"),
+	}, debug, nil
+}
+
+func query(args model.NetworkPath, sql string) (*common.Result, model.Debug, error) {
+	ckEngine := &clickhouse.CHEngine{DB: DATABASE_FLOW_LOG, Context: args.Context}
+	ckEngine.Init()
+	querierArgs := common.QuerierParams{
+		DB:      DATABASE_FLOW_LOG,
+		Sql:     sql,
+		Debug:   strconv.FormatBool(args.Debug),
+		Context: args.Context,
+	}
+	result, querierDebug, err := ckEngine.ExecuteQuery(&querierArgs)
+	debug := model.Debug{Sql: sql}
+	debug.IP, _ = querierDebug["ip"].(string)
+	debug.QueryUUID, _ = querierDebug["query_uuid"].(string)
+	debug.SqlCH, _ = querierDebug["sql"].(string)
+	debug.Error, _ = querierDebug["error"].(string)
+	debug.QueryTime, _ = querierDebug["query_time"].(string)
+	if err != nil {
+		log.Errorf("ExecuteQuery failed: %v %v", debug, err)
+		return nil, debug, err
+	}
+	return result, debug, nil
+}
+
+type resultRows struct {
+	result        *common.Result
+	columnToIndex map[string]int
+}
+
+func newResultRows(result *common.Result) *resultRows {
+	r := &resultRows{result: result, columnToIndex: map[string]int{}}
+	if result == nil {
+		return r
+	}
+	for i, column := range result.Columns {
+		if name, ok := column.(string); ok {
+			r.columnToIndex[name] = i
+		}
+	}
+	return r
+}
+
+func (r *resultRows) rows() [][]interface{} {
+	rows := [][]interface{}{}
+	if r.result == nil {
+		return rows
+	}
+	for _, value := range r.result.Values {
+		if row, ok := value.([]interface{}); ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func (r *resultRows) value(row []interface{}, column string) interface{} {
+	index, ok := r.columnToIndex[column]
+	if !ok || index >= len(row) {
+		return nil
+	}
+	return row[index]
+}
+
+func (r *resultRows) String(row []interface{}, column string) string {
+	value := r.value(row, column)
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func (r *resultRows) Float(row []interface{}, column string) float64 {
+	switch v := r.value(row, column).(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
+func (r *resultRows) Int(row []interface{}, column string) int64 {
+	return int64(r.Float(row, column))
+}
+
+func tapSideRank(tapSide string) int {
+	if rank, ok := TAP_SIDE_RANK[tapSide]; ok {
+		return rank
+	}
+	return TAP_SIDE_REST_RANK
+}
+
+func tapSideZone(tapSide string) string {
+	if zone, ok := TAP_SIDE_ZONE[tapSide]; ok {
+		return zone
+	}
+	return ZONE_NETWORK
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// BuildPath 将各采集器的流日志按采集器、观测点、网卡合并为观测点，按从客户端到服务端的顺序排列，
+// 并计算相邻观测点之间每一跳的时延和异常差异
+func BuildPath(result *common.Result) *model.Path {
+	type pointKey struct {
+		tapSide     string
+		vtapID      int
+		tapPortName string
+	}
+	keyToPoint := map[pointKey]*model.Point{}
+	points := []*model.Point{}
+	r := newResultRows(result)
+	for _, row := range r.rows() {
+		key := pointKey{tapSide: r.String(row, "tap_side"), vtapID: int(r.Int(row, "vtap_id")), tapPortName: r.String(row, "tap_port_name")}
+		startTime, endTime := r.Int(row, "start_time_us"), r.Int(row, "end_time_us")
+		point, ok := keyToPoint[key]
+		if !ok {
+			point = &model.Point{
+				TapSide:     key.tapSide,
+				TapSideName: r.String(row, "Enum(tap_side)"),
+				Zone:        tapSideZone(key.tapSide),
+				Vtap:        r.String(row, "vtap"),
+				VtapID:      key.vtapID,
+				TapPortName: key.tapPortName,
+				StartTimeUs: startTime,
+				EndTimeUs:   endTime,
+			}
+			keyToPoint[key] = point
+			points = append(points, point)
+		}
+		if startTime < point.StartTimeUs {
+			point.StartTimeUs = startTime
+		}
+		if endTime > point.EndTimeUs {
+			point.EndTimeUs = endTime
+		}
+		point.FlowLogs++
+		// RTT 仅在建连所在的流日志中有值
+		rtt, rttClient, rttServer := r.Float(row, "rtt"), r.Float(row, "rtt_client"), r.Float(row, "rtt_server")
+		if rtt > 0 || rttClient > 0 || rttServer > 0 {
+			point.Handshakes++
+		}
+		point.RTT = maxFloat(point.RTT, rtt)
+		point.RTTClient = maxFloat(point.RTTClient, rttClient)
+		point.RTTServer = maxFloat(point.RTTServer, rttServer)
+		point.SRTMax = maxFloat(point.SRTMax, r.Float(row, "srt_max"))
+		point.ARTMax = maxFloat(point.ARTMax, r.Float(row, "art_max"))
+		point.RetransTx += r.Int(row, "retrans_tx")
+		point.RetransRx += r.Int(row, "retrans_rx")
+		point.ZeroWinTx += r.Int(row, "zero_win_tx")
+		point.ZeroWinRx += r.Int(row, "zero_win_rx")
+		point.ClientRst += r.Int(row, "client_rst_flow")
+		point.ServerRst += r.Int(row, "server_rst_flow")
+		point.PacketTx += r.Int(row, "packet_tx")
+		point.PacketRx += r.Int(row, "packet_rx")
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		ri, rj := tapSideRank(points[i].TapSide), tapSideRank(points[j].TapSide)
+		if ri != rj {
+			return ri < rj
+		}
+		if points[i].StartTimeUs != points[j].StartTimeUs {
+			return points[i].StartTimeUs < points[j].StartTimeUs
+		}
+		return points[i].VtapID < points[j].VtapID
+	})
+
+	path := &model.Path{Points: points, Hops: []*model.Hop{}}
+	for _, point := range points {
+		if point.Handshakes > 1 {
+			path.MultipleConnections = true
+		}
+	}
+	path.LatencySpans = buildLatencySpans(points)
+	for i := 0; i+1 < len(points); i++ {
+		path.Hops = append(path.Hops, buildHop(points, i, i+1, path.LatencySpans))
+	}
+	path.Segments, path.Bottleneck = buildSegments(path.LatencySpans)
+	return path
+}
+
+func hopSegment(from, to *model.Point) string {
+	if from.Zone == to.Zone {
+		return from.Zone
+	}
+	return from.Zone + "->" + to.Zone
+}
+
+// buildHop 计算相邻两个观测点之间的异常差异，两者都有 RTT 时这一跳的时延即为两者之间的 LatencySpan
+func buildHop(points []*model.Point, from, to int, spans []*model.LatencySpan) *model.Hop {
+	f, t := points[from], points[to]
+	hop := &model.Hop{
+		From:        from,
+		To:          to,
+		Segment:     hopSegment(f, t),
+		RetransDiff: (t.RetransTx + t.RetransRx) - (f.RetransTx + f.RetransRx),
+		ZeroWinDiff: (t.ZeroWinTx + t.ZeroWinRx) - (f.ZeroWinTx + f.ZeroWinRx),
+		RstDiff:     (t.ClientRst + t.ServerRst) - (f.ClientRst + f.ServerRst),
+	}
+	for _, span := range spans {
+		if span.From == from && span.To == to {
+			hop.LatencyMeasured = true
+			hop.Latency = span.Latency
+			break
+		}
+	}
+	return hop
+}
+
+// buildLatencySpans 观测点到服务端的 RTT 之差、到客户端的 RTT 之差均为两个观测点之间的往返时延，
+// 分别在相邻的有 RTT 的观测点之间计算，跨越中间没有 RTT 的观测点。两者范围相同时取平均值，
+// 范围交叠时保留跨度小的，避免同一段时延重复计入
+func buildLatencySpans(points []*model.Point) []*model.LatencySpan {
+	type spanKey struct{ from, to int }
+	keyToLatencies := map[spanKey][]float64{}
+	keys := []spanKey{}
+	addSpans := func(rtt func(p *model.Point) float64, latency func(from, to float64) float64) {
+		last := -1
+		for i, point := range points {
+			if rtt(point) <= 0 {
+				continue
+			}
+			if last >= 0 {
+				key := spanKey{last, i}
+				if _, ok := keyToLatencies[key]; !ok {
+					keys = append(keys, key)
+				}
+				keyToLatencies[key] = append(keyToLatencies[key], latency(rtt(points[last]), rtt(point)))
+			}
+			last = i
+		}
+	}
+	addSpans(func(p *model.Point) float64 { return p.RTTServer }, func(from, to float64) float64 { return from - to })
+	addSpans(func(p *model.Point) float64 { return p.RTTClient }, func(from, to float64) float64 { return to - from })
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].to-keys[i].from < keys[j].to-keys[j].from
+	})
+	covered := make([]bool, len(points))
+	spans := []*model.LatencySpan{}
+	for _, key := range keys {
+		overlapped := false
+		for i := key.from; i < key.to; i++ {
+			if covered[i] {
+				overlapped = true
+				break
+			}
+		}
+		if overlapped {
+			continue
+		}
+		for i := key.from; i < key.to; i++ {
+			covered[i] = true
+		}
+		span := &model.LatencySpan{From: key.from, To: key.to, Segment: hopSegment(points[key.from], points[key.to])}
+		for _, latency := range keyToLatencies[key] {
+			span.Latency += latency
+		}
+		span.Latency /= float64(len(keyToLatencies[key]))
+		// 不同采集器的测量误差可能导致差值为负
+		if span.Latency < 0 {
+			span.Latency = 0
+		}
+		spans = append(spans, span)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].From < spans[j].From })
+	return spans
+}
+
+// buildSegments 按路径顺序汇总各区段的时延，返回时延最大的区段
+func buildSegments(spans []*model.LatencySpan) ([]*model.Segment, string) {
+	segments := []*model.Segment{}
+	nameToSegment := map[string]*model.Segment{}
+	total := 0.0
+	for _, span := range spans {
+		segment, ok := nameToSegment[span.Segment]
+		if !ok {
+			segment = &model.Segment{Name: span.Segment}
+			nameToSegment[span.Segment] = segment
+			segments = append(segments, segment)
+		}
+		segment.Latency += span.Latency
+		total += span.Latency
+	}
+	bottleneck := ""
+	maxLatency := 0.0
+	for _, segment := range segments {
+		if total > 0 {
+			segment.LatencyRatio = segment.Latency * 100 / total
+		}
+		if segment.Latency > maxLatency {
+			maxLatency = segment.Latency
+			bottleneck = segment.Name
+		}
+	}
+	return segments, bottleneck
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+)
+
+func newTestResult(columns []string, rows ...[]interface{}) *common.Result {
+	result := &common.Result{}
+	for _, column := range columns {
+		result.Columns = append(result.Columns, column)
+	}
+	for _, row := range rows {
+		result.Values = append(result.Values, row)
+	}
+	return result
+}
+
+func TestBuildPath(t *testing.T) {
+	result := newTestResult(
+		[]string{"tap_side", "Enum(tap_side)", "vtap", "vtap_id", "tap_port_name", "start_time_us", "end_time_us",
+			"rtt_client", "rtt_server", "retrans_tx", "retrans_rx", "zero_win_tx", "zero_win_rx", "client_rst_flow", "server_rst_flow"},
+		[]interface{}{"s", "Server NIC", "node-2", 2, "eth0", int64(1100), int64(2100), 1900.0, 100.0, 1, 0, 0, 0, 0, 0},
+		[]interface{}{"c-gw", "Client-side Gateway", "gw", 3, "bond0", int64(1050), int64(2050), 600.0, 1400.0, 3, 0, 0, 0, 0, 0},
+		[]interface{}{"c", "Client NIC", "node-1", 1, "eth0", int64(1000), int64(2000), 50.0, 1950.0, 3, 0, 0, 0, 0, 0},
+		// 同一观测点的第二条流日志，建连的 RTT 不再有值
+		[]interface{}{"c", "Client NIC", "node-1", 1, "eth0", int64(2000), int64(3000), 0.0, 0.0, 1, 0, 0, 2, 0, 0},
+		[]interface{}{"s-nd", "Server K8s Node", "node-2", 2, "cni0", int64(1080), int64(2080), 0.0, 0.0, 1, 0, 0, 0, 0, 1},
+	)
+	path := BuildPath(result)
+	assert.Equal(t, 4, len(path.Points))
+	sides := []string{}
+	for _, point := range path.Points {
+		sides = append(sides, point.TapSide)
+	}
+	assert.Equal(t, []string{"c", "c-gw", "s-nd", "s"}, sides)
+	assert.Equal(t, 2, path.Points[0].FlowLogs)
+	assert.Equal(t, int64(3000), path.Points[0].EndTimeUs)
+	assert.Equal(t, int64(4), path.Points[0].RetransTx)
+
+	assert.Equal(t, 3, len(path.Hops))
+	// c -> c-gw：(1950-1400 + 600-50) / 2
+	assert.Equal(t, ZONE_CLIENT+"->"+ZONE_GATEWAY, path.Hops[0].Segment)
+	assert.True(t, path.Hops[0].LatencyMeasured)
+	assert.Equal(t, 550.0, path.Hops[0].Latency)
+	assert.Equal(t, int64(-1), path.Hops[0].RetransDiff)
+	assert.Equal(t, int64(-2), path.Hops[0].ZeroWinDiff)
+	// s-nd 没有 RTT，c-gw 到 s 的时延跨越 s-nd，计入 gateway->server-node
+	assert.False(t, path.Hops[1].LatencyMeasured)
+	assert.False(t, path.Hops[2].LatencyMeasured)
+	assert.Equal(t, int64(-2), path.Hops[1].RetransDiff)
+	assert.Equal(t, int64(-1), path.Hops[2].RstDiff)
+
+	assert.Equal(t, 2, len(path.LatencySpans))
+	assert.Equal(t, 1, path.LatencySpans[1].From)
+	assert.Equal(t, 3, path.LatencySpans[1].To)
+	assert.Equal(t, ZONE_GATEWAY+"->"+ZONE_SERVER, path.LatencySpans[1].Segment)
+	// (1400-100 + 1900-600) / 2
+	assert.Equal(t, 1300.0, path.LatencySpans[1].Latency)
+
+	assert.Equal(t, 2, len(path.Segments))
+	assert.Equal(t, ZONE_GATEWAY+"->"+ZONE_SERVER, path.Bottleneck)
+	assert.InDelta(t, 550.0*100/1850, path.Segments[0].LatencyRatio, 1e-9)
+	assert.False(t, path.MultipleConnections)
+}
+
+func TestBuildPathMultipleConnections(t *testing.T) {
+	result := newTestResult(
+		[]string{"tap_side", "vtap_id", "start_time_us", "end_time_us", "rtt"},
+		[]interface{}{"c", 1, int64(1000), int64(2000), 30.0},
+		[]interface{}{"c", 1, int64(5000), int64(6000), 40.0},
+	)
+	path := BuildPath(result)
+	assert.Equal(t, 2, path.Points[0].Handshakes)
+	assert.True(t, path.MultipleConnections)
+
+	// 按 L7 请求的时间只保留重叠的流日志
+	filterByTime(result, 5500, 5600)
+	path = BuildPath(result)
+	assert.Equal(t, 1, path.Points[0].FlowLogs)
+	assert.False(t, path.MultipleConnections)
+}
+
+func TestBuildHop(t *testing.T) {
+	result := newTestResult(
+		[]string{"tap_side", "vtap_id", "rtt_client", "rtt_server"},
+		[]interface{}{"c-p", 1, 10.0, 1000.0},
+		[]interface{}{"c", 1, 20.0, 0.0},
+		[]interface{}{"s-gw", 2, 0.0, 300.0},
+		[]interface{}{"s", 3, 900.0, 50.0},
+		[]interface{}{"rest", 4, 0.0, 0.0},
+	)
+	path := BuildPath(result)
+	sides := []string{}
+	for _, point := range path.Points {
+		sides = append(sides, point.TapSide)
+	}
+	assert.Equal(t, []string{"c-p", "c", "rest", "s-gw", "s"}, sides)
+	// c-p 到 c 只有 rtt_client 可用，跨度更大的 c-p 到 s-gw 的 rtt_server 时延与之交叠，不重复计入
+	assert.Equal(t, 10.0, path.Hops[0].Latency)
+	assert.Equal(t, ZONE_CLIENT, path.Hops[0].Segment)
+	assert.Equal(t, ZONE_NETWORK+"->"+ZONE_GATEWAY, path.Hops[2].Segment)
+	// 只有 rtt_server 可用
+	assert.Equal(t, 250.0, path.Hops[3].Latency)
+	assert.Equal(t, ZONE_GATEWAY+"->"+ZONE_SERVER, path.Bottleneck)
+
+	empty := BuildPath(nil)
+	assert.Equal(t, 0, len(empty.Points))
+	assert.Equal(t, "", empty.Bottleneck)
+}
@@ -30,12 +30,15 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/logger"
 	"github.com/deepflowio/deepflow/server/libs/stats"
 	prometheus_router "github.com/deepflowio/deepflow/server/querier/app/prometheus/router"
+	prometheus_service "github.com/deepflowio/deepflow/server/querier/app/prometheus/service"
 	tracing_adapter "github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/router"
 	"github.com/deepflowio/deepflow/server/querier/common"
 	"github.com/deepflowio/deepflow/server/querier/config"
 	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/trans_prometheus"
+	network_path_router "github.com/deepflowio/deepflow/server/querier/network_path/router"
 	profile_router "github.com/deepflowio/deepflow/server/querier/profile/router"
 	"github.com/deepflowio/deepflow/server/querier/router"
+	service_map_router "github.com/deepflowio/deepflow/server/querier/service_map/router"
 	"github.com/deepflowio/deepflow/server/querier/statsd"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
@@ -55,6 +58,7 @@ func Start(configPath, serverLogFile string) {
 	// statsd
 	statsd.QuerierCounter = statsd.NewCounter()
 	statsd.RegisterCountableForIngester("querier_count", statsd.QuerierCounter)
+	stats.RegisterGcMonitor()
 
 	// engine加载数据库tag/metric等信息
 	err := Load()
@@ -63,6 +67,9 @@ func Start(configPath, serverLogFile string) {
 		os.Exit(0)
 	}
 
+	// 注册 PromQL 异常检测函数，需在解析 PromQL 之前完成
+	prometheus_service.RegisterAnomalyFunctions()
+
 	// prometheus dict cache
 	go trans_prometheus.GeneratePrometheusMap()
 
@@ -82,10 +89,14 @@ func Start(configPath, serverLogFile string) {
 	r.Use(gin.LoggerWithFormatter(logger.GinLogFormat))
 	r.Use(StatdHandle())
 	r.Use(ErrHandle())
+	r.Use(OrgHandle())
 	router.QueryRouter(r)
 	profile_router.ProfileRouter(r, &cfg)
+	service_map_router.ServiceMapRouter(r)
+	network_path_router.NetworkPathRouter(r)
 	prometheus_router.PrometheusRouter(r)
 	tracing_adapter.TracingAdapterRouter(r)
+	r.GET("/metrics", gin.WrapH(stats.PrometheusHandler()))
 	registerRouterCounter(r.Routes())
 	// TODO: 增加router
 	if err := r.Run(fmt.Sprintf(":%d", cfg.ListenPort)); err != nil {
@@ -107,6 +118,20 @@ func registerRouterCounter(routers gin.RoutesInfo) {
 	}
 }
 
+// OrgHandle 将请求头 X-Org-Id 中的组织写入请求上下文，所有查询只访问该组织的数据库
+func OrgHandle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, err := common.ParseOrgID(c.GetHeader(common.HEADER_KEY_X_ORG_ID))
+		if err != nil {
+			router.BadRequestResponse(c, common.INVALID_PARAMETERS, err.Error())
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(common.ContextWithOrgID(c.Request.Context(), orgID))
+		c.Next()
+	}
+}
+
 func ErrHandle() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
@@ -70,6 +70,8 @@ func tempoSearchReader() gin.HandlerFunc {
 			StartTime:   c.Query("start"),
 			EndTime:     c.Query("end"),
 			Debug:       c.Query("debug"),
+			Query:       c.Query("q"),
+			SpansPerSet: c.Query("spss"),
 			Context:     c.Request.Context(),
 		}
 		args.SetFilters(c.Query("tags"))
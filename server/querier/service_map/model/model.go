@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "context"
+
+type ServiceMap struct {
+	TimeStart int `json:"time_start" binding:"required"`
+	TimeEnd   int `json:"time_end" binding:"required"`
+	// 节点类型：service（默认）、pod、process、ip
+	NodeType      string `json:"node_type"`
+	TagFilter     string `json:"tag_filter"`
+	DataPrecision string `json:"data_precision"`
+	// 从 Root 节点开始按 Depth 跳数展开，Depth 为 0 时不限制
+	Root  string `json:"root"`
+	Depth int    `json:"depth"`
+	// 与另一个时间窗口对比
+	CompareTimeStart int    `json:"compare_time_start"`
+	CompareTimeEnd   int    `json:"compare_time_end"`
+	Format           string `json:"format"`
+	Debug            bool   `json:"debug"`
+	Context          context.Context
+}
+
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+	// 查询结果达到行数上限，拓扑可能不完整
+	Truncated bool `json:"truncated"`
+}
+
+type Node struct {
+	ID          string  `json:"id"`
+	Type        string  `json:"type"`
+	IsInternet  bool    `json:"is_internet"`
+	Health      string  `json:"health"`
+	RequestRate float64 `json:"request_rate"`
+	ErrorRatio  float64 `json:"error_ratio"`
+}
+
+// 网络指标（ByteRate、Retrans、RTT、RetransRatio）不区分协议，同一客户端、服务端之间只附加在协议排序最小的一条边上
+type Edge struct {
+	Client       string    `json:"client"`
+	Server       string    `json:"server"`
+	Protocol     string    `json:"protocol"`
+	RequestRate  float64   `json:"request_rate"`
+	ErrorRatio   float64   `json:"error_ratio"`
+	LatencyAvg   float64   `json:"latency_avg"`
+	LatencyP50   float64   `json:"latency_p50"`
+	LatencyP95   float64   `json:"latency_p95"`
+	LatencyP99   float64   `json:"latency_p99"`
+	ByteRate     float64   `json:"byte_rate"`
+	Retrans      float64   `json:"retrans"`
+	RTT          float64   `json:"rtt"`
+	RetransRatio float64   `json:"retrans_ratio"`
+	Diff         *EdgeDiff `json:"diff,omitempty"`
+}
+
+// 与对比时间窗口的差异，Status 为 added/removed/changed/unchanged
+type EdgeDiff struct {
+	Status      string  `json:"status"`
+	RequestRate float64 `json:"request_rate"`
+	ErrorRatio  float64 `json:"error_ratio"`
+	LatencyAvg  float64 `json:"latency_avg"`
+	LatencyP95  float64 `json:"latency_p95"`
+}
+
+type Debug struct {
+	IP        string `json:"ip"`
+	Sql       string `json:"sql"`
+	SqlCH     string `json:"sql_CH"`
+	QueryTime string `json:"query_time"`
+	QueryUUID string `json:"query_uuid"`
+	Error     string `json:"error"`
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/router"
+	"github.com/deepflowio/deepflow/server/querier/service_map/model"
+	"github.com/deepflowio/deepflow/server/querier/service_map/service"
+)
+
+func ServiceMapRouter(e *gin.Engine) {
+	e.POST("/v1/service-map/", serviceMap())
+}
+
+func serviceMap() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.ServiceMap
+
+		// 参数校验
+		err := c.ShouldBindBodyWith(&args, binding.JSON)
+		if err != nil {
+			router.BadRequestResponse(c, common.INVALID_POST_DATA, err.Error())
+			return
+		}
+		if args.Format == "" {
+			args.Format = c.DefaultQuery("format", "json")
+		}
+		if args.Format != "json" && args.Format != "dot" {
+			router.BadRequestResponse(c, common.INVALID_POST_DATA, "format must be json or dot")
+			return
+		}
+		args.Context = c.Request.Context()
+		graph, debug, err := service.ServiceMap(args)
+		if err == nil && args.Format == "dot" {
+			c.Data(200, "text/vnd.graphviz; charset=utf-8", []byte(service.ToDOT(graph)))
+			return
+		}
+		if err == nil && !args.Debug {
+			debug = nil
+		}
+		router.JsonResponse(c, graph, debug, err)
+	})
+}
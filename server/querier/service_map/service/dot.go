@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/deepflowio/deepflow/server/querier/service_map/model"
+)
+
+var HEALTH_TO_COLOR = map[string]string{
+	HEALTH_UNKNOWN:  "gray",
+	HEALTH_HEALTHY:  "green",
+	HEALTH_WARNING:  "orange",
+	HEALTH_CRITICAL: "red",
+}
+
+var DIFF_TO_STYLE = map[string]string{
+	DIFF_ADDED:   "bold",
+	DIFF_REMOVED: "dashed",
+}
+
+// ToDOT 将服务拓扑输出为 Graphviz DOT 格式
+func ToDOT(graph *model.Graph) string {
+	buf := bytes.Buffer{}
+	buf.WriteString("digraph service_map {\n")
+	buf.WriteString("  rankdir=LR;\n")
+	buf.WriteString("  node [shape=box];\n")
+	for _, node := range graph.Nodes {
+		label := node.ID
+		if node.RequestRate > 0 {
+			label += fmt.Sprintf("\n%.2f req/s, err %.2f%%", node.RequestRate, node.ErrorRatio)
+		}
+		shape := ""
+		if node.IsInternet {
+			shape = ", shape=ellipse"
+		}
+		buf.WriteString(fmt.Sprintf("  %s [label=%s, color=%s%s];\n", strconv.Quote(node.ID), strconv.Quote(label), HEALTH_TO_COLOR[node.Health], shape))
+	}
+	for _, edge := range graph.Edges {
+		label := edge.Protocol
+		if edge.RequestRate > 0 {
+			label += fmt.Sprintf("\n%.2f req/s, err %.2f%%\navg %.0fus, p95 %.0fus", edge.RequestRate, edge.ErrorRatio, edge.LatencyAvg, edge.LatencyP95)
+		}
+		if edge.Retrans > 0 {
+			label += fmt.Sprintf("\nretrans %.0f", edge.Retrans)
+		}
+		style := ""
+		if edge.Diff != nil {
+			label += fmt.Sprintf("\n%s %+.2f req/s", edge.Diff.Status, edge.Diff.RequestRate)
+			if s, ok := DIFF_TO_STYLE[edge.Diff.Status]; ok {
+				style = ", style=" + s
+			}
+		}
+		buf.WriteString(fmt.Sprintf("  %s -> %s [label=%s%s];\n", strconv.Quote(edge.Client), strconv.Quote(edge.Server), strconv.Quote(label), style))
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
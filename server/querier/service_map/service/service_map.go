@@ -0,0 +1,563 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	logging "github.com/op/go-logging"
+	"github.com/xwb1989/sqlparser"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	"github.com/deepflowio/deepflow/server/querier/service_map/model"
+)
+
+var log = logging.MustGetLogger("service_map")
+
+const (
+	DATABASE_FLOW_METRICS  = "flow_metrics"
+	TABLE_APPLICATION_MAP  = "vtap_app_edge_port"
+	TABLE_NETWORK_MAP      = "vtap_flow_edge_port"
+	DEFAULT_DATA_PRECISION = "1m"
+	QUERY_LIMIT            = 10000
+
+	// 节点作为服务端的错误率（%）阈值
+	ERROR_RATIO_WARNING  = 1
+	ERROR_RATIO_CRITICAL = 5
+
+	HEALTH_UNKNOWN  = "unknown"
+	HEALTH_HEALTHY  = "healthy"
+	HEALTH_WARNING  = "warning"
+	HEALTH_CRITICAL = "critical"
+
+	DIFF_ADDED     = "added"
+	DIFF_REMOVED   = "removed"
+	DIFF_CHANGED   = "changed"
+	DIFF_UNCHANGED = "unchanged"
+)
+
+var NODE_TYPE_TO_TAG = map[string]string{
+	"service": "auto_service",
+	"pod":     "pod",
+	"process": "gprocess",
+	"ip":      "ip",
+}
+
+func ServiceMap(args model.ServiceMap) (graph *model.Graph, debug interface{}, err error) {
+	if args.NodeType == "" {
+		args.NodeType = "service"
+	}
+	if _, ok := NODE_TYPE_TO_TAG[args.NodeType]; !ok {
+		return nil, nil, common.NewError(common.INVALID_POST_DATA, fmt.Sprintf("node_type (%s) not support", args.NodeType))
+	}
+	if args.TimeStart >= args.TimeEnd {
+		return nil, nil, common.NewError(common.INVALID_POST_DATA, "time_start must be less than time_end")
+	}
+	if args.Depth < 0 {
+		return nil, nil, common.NewError(common.INVALID_POST_DATA, "depth must not be negative")
+	}
+	compare := args.CompareTimeStart != 0 || args.CompareTimeEnd != 0
+	if compare && (args.CompareTimeStart <= 0 || args.CompareTimeStart >= args.CompareTimeEnd) {
+		return nil, nil, common.NewError(common.INVALID_POST_DATA, "compare_time_start must be positive and less than compare_time_end")
+	}
+	tagFilter, err := ParseTagFilter(args.TagFilter)
+	if err != nil {
+		return nil, nil, common.NewError(common.INVALID_POST_DATA, err.Error())
+	}
+	args.TagFilter = tagFilter
+	if args.DataPrecision == "" {
+		args.DataPrecision = DEFAULT_DATA_PRECISION
+	}
+
+	debugs := []model.Debug{}
+	edges, internet, truncated, edgeDebugs, err := queryEdges(args, args.TimeStart, args.TimeEnd)
+	debugs = append(debugs, edgeDebugs...)
+	if err != nil {
+		return nil, debugs, err
+	}
+	if compare {
+		compareEdges, compareInternet, compareTruncated, compareDebugs, err := queryEdges(args, args.CompareTimeStart, args.CompareTimeEnd)
+		debugs = append(debugs, compareDebugs...)
+		if err != nil {
+			return nil, debugs, err
+		}
+		for id, isInternet := range compareInternet {
+			internet[id] = internet[id] || isInternet
+		}
+		edges = DiffEdges(edges, compareEdges)
+		truncated = truncated || compareTruncated
+	}
+	// 查询结果达到 QUERY_LIMIT 时拓扑可能不完整，从 root 展开的结果也可能缺少节点
+	graph = &model.Graph{Nodes: BuildNodes(edges, args.NodeType, internet), Edges: edges, Truncated: truncated}
+	if args.Root != "" {
+		graph = ExpandGraph(graph, args.Root, args.Depth)
+		graph.Truncated = truncated
+	}
+	return graph, debugs, nil
+}
+
+// ParseTagFilter 使用 sqlparser 解析 tag_filter，仅支持 tag 与常量比较的条件及其 AND/OR/NOT 组合，返回格式化后的条件
+func ParseTagFilter(tagFilter string) (string, error) {
+	if strings.TrimSpace(tagFilter) == "" {
+		return "", nil
+	}
+	stmt, err := sqlparser.Parse("SELECT 1 FROM t WHERE " + tagFilter)
+	if err != nil {
+		return "", fmt.Errorf("tag_filter (%s) parse failed: %s", tagFilter, err)
+	}
+	pStmt, ok := stmt.(*sqlparser.Select)
+	if !ok || pStmt.Where == nil || pStmt.GroupBy != nil || pStmt.Having != nil || pStmt.OrderBy != nil || pStmt.Limit != nil || pStmt.Lock != "" {
+		return "", fmt.Errorf("tag_filter (%s) must be a condition", tagFilter)
+	}
+	if !isSimpleFilter(pStmt.Where.Expr) {
+		return "", fmt.Errorf("tag_filter (%s) only supports comparisons between tags and constants", tagFilter)
+	}
+	return sqlparser.String(pStmt.Where.Expr), nil
+}
+
+func isSimpleFilter(expr sqlparser.Expr) bool {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		return isSimpleFilter(expr.Left) && isSimpleFilter(expr.Right)
+	case *sqlparser.OrExpr:
+		return isSimpleFilter(expr.Left) && isSimpleFilter(expr.Right)
+	case *sqlparser.NotExpr:
+		return isSimpleFilter(expr.Expr)
+	case *sqlparser.ParenExpr:
+		return isSimpleFilter(expr.Expr)
+	case *sqlparser.ComparisonExpr:
+		_, ok := expr.Left.(*sqlparser.ColName)
+		return ok && expr.Escape == nil && isConstant(expr.Right)
+	case *sqlparser.RangeCond:
+		_, ok := expr.Left.(*sqlparser.ColName)
+		return ok && isConstant(expr.From) && isConstant(expr.To)
+	case *sqlparser.IsExpr:
+		_, ok := expr.Expr.(*sqlparser.ColName)
+		return ok
+	}
+	return false
+}
+
+func isConstant(expr sqlparser.Expr) bool {
+	switch expr := expr.(type) {
+	case *sqlparser.SQLVal, *sqlparser.NullVal, sqlparser.BoolVal:
+		return true
+	case sqlparser.ValTuple:
+		for _, value := range expr {
+			if !isConstant(value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func queryEdges(args model.ServiceMap, timeStart, timeEnd int) ([]*model.Edge, map[string]bool, bool, []model.Debug, error) {
+	tag := NODE_TYPE_TO_TAG[args.NodeType]
+	whereSql := fmt.Sprintf("time>=%d AND time<=%d", timeStart, timeEnd)
+	if args.TagFilter != "" {
+		whereSql += fmt.Sprintf(" AND (%s)", args.TagFilter)
+	}
+	groupSql := fmt.Sprintf("%s_0, %s_1, is_internet_0, is_internet_1", tag, tag)
+	appSql := fmt.Sprintf(
+		"SELECT %s, Enum(l7_protocol), PerSecond(Sum(`request`)) AS `request_rate`, Avg(`error_ratio`) AS `error_ratio`, "+
+			"Avg(`rrt`) AS `latency_avg`, Percentile(`rrt`, 50) AS `latency_p50`, Percentile(`rrt`, 95) AS `latency_p95`, Percentile(`rrt`, 99) AS `latency_p99` "+
+			"FROM %s WHERE %s GROUP BY %s, l7_protocol LIMIT %d",
+		groupSql, TABLE_APPLICATION_MAP, whereSql, groupSql, QUERY_LIMIT,
+	)
+	networkSql := fmt.Sprintf(
+		"SELECT %s, PerSecond(Sum(`byte`)) AS `byte_rate`, Sum(`retrans`) AS `retrans`, Avg(`retrans_ratio`) AS `retrans_ratio`, Avg(`rtt`) AS `rtt` "+
+			"FROM %s WHERE %s GROUP BY %s LIMIT %d",
+		groupSql, TABLE_NETWORK_MAP, whereSql, groupSql, QUERY_LIMIT,
+	)
+	debugs := []model.Debug{}
+	appResult, appDebug, err := query(args, appSql)
+	debugs = append(debugs, appDebug)
+	if err != nil {
+		return nil, nil, false, debugs, err
+	}
+	networkResult, networkDebug, err := query(args, networkSql)
+	debugs = append(debugs, networkDebug)
+	if err != nil {
+		return nil, nil, false, debugs, err
+	}
+	truncated := len(newResultRows(appResult).rows()) >= QUERY_LIMIT || len(newResultRows(networkResult).rows()) >= QUERY_LIMIT
+	edges, internet := BuildEdges(tag, appResult, networkResult)
+	return edges, internet, truncated, debugs, nil
+}
+
+func query(args model.ServiceMap, sql string) (*common.Result, model.Debug, error) {
+	ckEngine := &clickhouse.CHEngine{DB: DATABASE_FLOW_METRICS, DataSource: args.DataPrecision, Context: args.Context}
+	ckEngine.Init()
+	querierArgs := common.QuerierParams{
+		DB:         DATABASE_FLOW_METRICS,
+		Sql:        sql,
+		DataSource: args.DataPrecision,
+		Debug:      strconv.FormatBool(args.Debug),
+		Context:    args.Context,
+	}
+	result, querierDebug, err := ckEngine.ExecuteQuery(&querierArgs)
+	debug := model.Debug{Sql: sql}
+	debug.IP, _ = querierDebug["ip"].(string)
+	debug.QueryUUID, _ = querierDebug["query_uuid"].(string)
+	debug.SqlCH, _ = querierDebug["sql"].(string)
+	debug.Error, _ = querierDebug["error"].(string)
+	debug.QueryTime, _ = querierDebug["query_time"].(string)
+	if err != nil {
+		log.Errorf("ExecuteQuery failed: %v %v", debug, err)
+		return nil, debug, err
+	}
+	return result, debug, nil
+}
+
+type resultRows struct {
+	result        *common.Result
+	columnToIndex map[string]int
+}
+
+func newResultRows(result *common.Result) *resultRows {
+	r := &resultRows{result: result, columnToIndex: map[string]int{}}
+	if result == nil {
+		return r
+	}
+	for i, column := range result.Columns {
+		if name, ok := column.(string); ok {
+			r.columnToIndex[name] = i
+		}
+	}
+	return r
+}
+
+func (r *resultRows) rows() [][]interface{} {
+	rows := [][]interface{}{}
+	if r.result == nil {
+		return rows
+	}
+	for _, value := range r.result.Values {
+		if row, ok := value.([]interface{}); ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func (r *resultRows) value(row []interface{}, column string) interface{} {
+	index, ok := r.columnToIndex[column]
+	if !ok || index >= len(row) {
+		return nil
+	}
+	return row[index]
+}
+
+func (r *resultRows) String(row []interface{}, column string) string {
+	value := r.value(row, column)
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func (r *resultRows) Float(row []interface{}, column string) float64 {
+	switch v := r.value(row, column).(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case bool:
+		if v {
+			return 1
+		}
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
+func edgeKey(client, server string) string {
+	return client + "\x00" + server
+}
+
+// 同一客户端、服务端之间的网络指标，可能由多行（如 is_internet 不同）聚合而来
+type networkMetrics struct {
+	byteRate float64
+	retrans  float64
+	// 按字节速率加权的时延、重传比例之和
+	weightedRTT          float64
+	weightedRetransRatio float64
+	// 时延、重传比例之和
+	rtt          float64
+	retransRatio float64
+	rowCount     int
+}
+
+// 时延和重传比例按字节速率加权平均，字节速率全为 0 时取算术平均
+func (m *networkMetrics) apply(edge *model.Edge) {
+	edge.ByteRate = m.byteRate
+	edge.Retrans = m.retrans
+	if m.byteRate > 0 {
+		edge.RTT = m.weightedRTT / m.byteRate
+		edge.RetransRatio = m.weightedRetransRatio / m.byteRate
+	} else if m.rowCount > 0 {
+		edge.RTT = m.rtt / float64(m.rowCount)
+		edge.RetransRatio = m.retransRatio / float64(m.rowCount)
+	}
+}
+
+// BuildEdges 合并应用（L7）和网络（L4）指标：应用指标按协议区分边，
+// 网络指标不区分协议，每对客户端、服务端只附加到其中协议排序最小的一条边上，避免重复计算；
+// 没有应用指标的客户端、服务端之间单独生成一条无协议的边
+func BuildEdges(tag string, appResult, networkResult *common.Result) ([]*model.Edge, map[string]bool) {
+	clientColumn, serverColumn := tag+"_0", tag+"_1"
+	internet := map[string]bool{}
+	pairToEdges := map[string][]*model.Edge{}
+	var edges []*model.Edge
+
+	app := newResultRows(appResult)
+	for _, row := range app.rows() {
+		client, server := app.String(row, clientColumn), app.String(row, serverColumn)
+		if client == "" || server == "" {
+			continue
+		}
+		internet[client] = internet[client] || app.Float(row, "is_internet_0") > 0
+		internet[server] = internet[server] || app.Float(row, "is_internet_1") > 0
+		edge := &model.Edge{
+			Client:      client,
+			Server:      server,
+			Protocol:    app.String(row, "Enum(l7_protocol)"),
+			RequestRate: app.Float(row, "request_rate"),
+			ErrorRatio:  app.Float(row, "error_ratio"),
+			LatencyAvg:  app.Float(row, "latency_avg"),
+			LatencyP50:  app.Float(row, "latency_p50"),
+			LatencyP95:  app.Float(row, "latency_p95"),
+			LatencyP99:  app.Float(row, "latency_p99"),
+		}
+		key := edgeKey(client, server)
+		pairToEdges[key] = append(pairToEdges[key], edge)
+		edges = append(edges, edge)
+	}
+
+	pairToNetwork := map[string]*networkMetrics{}
+	network := newResultRows(networkResult)
+	for _, row := range network.rows() {
+		client, server := network.String(row, clientColumn), network.String(row, serverColumn)
+		if client == "" || server == "" {
+			continue
+		}
+		internet[client] = internet[client] || network.Float(row, "is_internet_0") > 0
+		internet[server] = internet[server] || network.Float(row, "is_internet_1") > 0
+		key := edgeKey(client, server)
+		if _, ok := pairToEdges[key]; !ok {
+			edge := &model.Edge{Client: client, Server: server}
+			pairToEdges[key] = []*model.Edge{edge}
+			edges = append(edges, edge)
+		}
+		metrics, ok := pairToNetwork[key]
+		if !ok {
+			metrics = &networkMetrics{}
+			pairToNetwork[key] = metrics
+		}
+		byteRate, rtt, retransRatio := network.Float(row, "byte_rate"), network.Float(row, "rtt"), network.Float(row, "retrans_ratio")
+		metrics.byteRate += byteRate
+		metrics.retrans += network.Float(row, "retrans")
+		metrics.weightedRTT += rtt * byteRate
+		metrics.weightedRetransRatio += retransRatio * byteRate
+		metrics.rtt += rtt
+		metrics.retransRatio += retransRatio
+		metrics.rowCount++
+	}
+	sortEdges(edges)
+	for _, edge := range edges {
+		key := edgeKey(edge.Client, edge.Server)
+		if metrics, ok := pairToNetwork[key]; ok {
+			metrics.apply(edge)
+			delete(pairToNetwork, key)
+		}
+	}
+	return edges, internet
+}
+
+func sortEdges(edges []*model.Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Client != edges[j].Client {
+			return edges[i].Client < edges[j].Client
+		}
+		if edges[i].Server != edges[j].Server {
+			return edges[i].Server < edges[j].Server
+		}
+		return edges[i].Protocol < edges[j].Protocol
+	})
+}
+
+// BuildNodes 由边生成节点，节点的请求速率和错误率为其作为服务端的全部边按请求加权的结果
+func BuildNodes(edges []*model.Edge, nodeType string, internet map[string]bool) []*model.Node {
+	idToNode := map[string]*model.Node{}
+	errors := map[string]float64{}
+	getNode := func(id string) *model.Node {
+		node, ok := idToNode[id]
+		if !ok {
+			node = &model.Node{ID: id, Type: nodeType, IsInternet: internet[id]}
+			idToNode[id] = node
+		}
+		return node
+	}
+	for _, edge := range edges {
+		getNode(edge.Client)
+		server := getNode(edge.Server)
+		server.RequestRate += edge.RequestRate
+		errors[edge.Server] += edge.RequestRate * edge.ErrorRatio
+	}
+	nodes := make([]*model.Node, 0, len(idToNode))
+	for id, node := range idToNode {
+		if node.RequestRate > 0 {
+			node.ErrorRatio = errors[id] / node.RequestRate
+		}
+		node.Health = getHealth(node)
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+func getHealth(node *model.Node) string {
+	switch {
+	case node.RequestRate <= 0:
+		return HEALTH_UNKNOWN
+	case node.ErrorRatio >= ERROR_RATIO_CRITICAL:
+		return HEALTH_CRITICAL
+	case node.ErrorRatio >= ERROR_RATIO_WARNING:
+		return HEALTH_WARNING
+	default:
+		return HEALTH_HEALTHY
+	}
+}
+
+// DiffEdges 对比两个时间窗口的边，仅存在于对比窗口的边以 removed 状态追加，其指标为 0
+func DiffEdges(edges, compareEdges []*model.Edge) []*model.Edge {
+	key := func(edge *model.Edge) string {
+		return strings.Join([]string{edge.Client, edge.Server, edge.Protocol}, "\x00")
+	}
+	keyToCompare := map[string]*model.Edge{}
+	for _, edge := range compareEdges {
+		keyToCompare[key(edge)] = edge
+	}
+	for _, edge := range edges {
+		compare, ok := keyToCompare[key(edge)]
+		if !ok {
+			edge.Diff = &model.EdgeDiff{
+				Status:      DIFF_ADDED,
+				RequestRate: edge.RequestRate,
+				ErrorRatio:  edge.ErrorRatio,
+				LatencyAvg:  edge.LatencyAvg,
+				LatencyP95:  edge.LatencyP95,
+			}
+			continue
+		}
+		delete(keyToCompare, key(edge))
+		edge.Diff = &model.EdgeDiff{
+			Status:      DIFF_UNCHANGED,
+			RequestRate: edge.RequestRate - compare.RequestRate,
+			ErrorRatio:  edge.ErrorRatio - compare.ErrorRatio,
+			LatencyAvg:  edge.LatencyAvg - compare.LatencyAvg,
+			LatencyP95:  edge.LatencyP95 - compare.LatencyP95,
+		}
+		if edge.Diff.RequestRate != 0 || edge.Diff.ErrorRatio != 0 || edge.Diff.LatencyAvg != 0 || edge.Diff.LatencyP95 != 0 {
+			edge.Diff.Status = DIFF_CHANGED
+		}
+	}
+	for _, compare := range compareEdges {
+		if _, ok := keyToCompare[key(compare)]; !ok {
+			continue
+		}
+		edges = append(edges, &model.Edge{
+			Client:   compare.Client,
+			Server:   compare.Server,
+			Protocol: compare.Protocol,
+			Diff: &model.EdgeDiff{
+				Status:      DIFF_REMOVED,
+				RequestRate: -compare.RequestRate,
+				ErrorRatio:  -compare.ErrorRatio,
+				LatencyAvg:  -compare.LatencyAvg,
+				LatencyP95:  -compare.LatencyP95,
+			},
+		})
+	}
+	sortEdges(edges)
+	return edges
+}
+
+// ExpandGraph 从 root 节点开始，沿边（不区分方向）展开 depth 跳，depth 为 0 时展开全部可达节点
+func ExpandGraph(graph *model.Graph, root string, depth int) *model.Graph {
+	adjacency := map[string][]string{}
+	for _, edge := range graph.Edges {
+		adjacency[edge.Client] = append(adjacency[edge.Client], edge.Server)
+		adjacency[edge.Server] = append(adjacency[edge.Server], edge.Client)
+	}
+	expanded := &model.Graph{Nodes: []*model.Node{}, Edges: []*model.Edge{}}
+	if _, ok := adjacency[root]; !ok {
+		return expanded
+	}
+	distance := map[string]int{root: 0}
+	queue := []string{root}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if depth > 0 && distance[id] >= depth {
+			continue
+		}
+		for _, next := range adjacency[id] {
+			if _, ok := distance[next]; ok {
+				continue
+			}
+			distance[next] = distance[id] + 1
+			queue = append(queue, next)
+		}
+	}
+	for _, node := range graph.Nodes {
+		if _, ok := distance[node.ID]; ok {
+			expanded.Nodes = append(expanded.Nodes, node)
+		}
+	}
+	for _, edge := range graph.Edges {
+		clientDistance, clientOK := distance[edge.Client]
+		serverDistance, serverOK := distance[edge.Server]
+		// 两端都在展开范围内，且至少一端不在最外层，避免引入最外层节点之间的边
+		if clientOK && serverOK && (depth == 0 || clientDistance < depth || serverDistance < depth) {
+			expanded.Edges = append(expanded.Edges, edge)
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/service_map/model"
+)
+
+func newTestResult(columns []string, rows ...[]interface{}) *common.Result {
+	result := &common.Result{}
+	for _, column := range columns {
+		result.Columns = append(result.Columns, column)
+	}
+	for _, row := range rows {
+		result.Values = append(result.Values, row)
+	}
+	return result
+}
+
+func TestServiceMap(t *testing.T) {
+	appResult := newTestResult(
+		[]string{"auto_service_0", "auto_service_1", "is_internet_0", "is_internet_1", "Enum(l7_protocol)", "request_rate", "error_ratio", "latency_avg", "latency_p50", "latency_p95", "latency_p99"},
+		[]interface{}{"frontend", "cart", 0, 0, "HTTP", 10.0, 0.0, 1000.0, 800.0, 3000.0, 5000.0},
+		[]interface{}{"cart", "redis", 0, 0, "Redis", 30.0, 10.0, 200.0, 150.0, 500.0, 900.0},
+		[]interface{}{"1.1.1.1", "frontend", 1, 0, "HTTP", 5.0, 2.0, 2000.0, 1500.0, 6000.0, 9000.0},
+	)
+	networkResult := newTestResult(
+		[]string{"auto_service_0", "auto_service_1", "is_internet_0", "is_internet_1", "byte_rate", "retrans", "retrans_ratio", "rtt"},
+		[]interface{}{"frontend", "cart", 0, 0, 1024.0, uint64(3), 0.1, 100.0},
+		[]interface{}{"cart", "mysql", 0, 0, 2048.0, uint64(0), 0.0, 50.0},
+	)
+	edges, internet := BuildEdges("auto_service", appResult, networkResult)
+	assert.Equal(t, 4, len(edges))
+	assert.True(t, internet["1.1.1.1"])
+	assert.Equal(t, float64(3), edges[3].Retrans) // frontend -> cart
+	assert.Equal(t, "", edges[1].Protocol)        // cart -> mysql, L4 only
+
+	nodes := BuildNodes(edges, "service", internet)
+	assert.Equal(t, 5, len(nodes))
+	idToNode := map[string]*model.Node{}
+	for _, node := range nodes {
+		idToNode[node.ID] = node
+	}
+	assert.Equal(t, HEALTH_CRITICAL, idToNode["redis"].Health)
+	assert.Equal(t, HEALTH_HEALTHY, idToNode["cart"].Health)
+	assert.Equal(t, HEALTH_WARNING, idToNode["frontend"].Health)
+	assert.Equal(t, HEALTH_UNKNOWN, idToNode["mysql"].Health)
+
+	graph := &model.Graph{Nodes: nodes, Edges: edges}
+	expanded := ExpandGraph(graph, "frontend", 1)
+	assert.Equal(t, 3, len(expanded.Nodes)) // frontend, cart, 1.1.1.1
+	assert.Equal(t, 2, len(expanded.Edges))
+	assert.Equal(t, 5, len(ExpandGraph(graph, "frontend", 0).Nodes))
+	assert.Equal(t, 0, len(ExpandGraph(graph, "unknown", 1).Nodes))
+
+	compareEdges, _ := BuildEdges("auto_service", newTestResult(
+		[]string{"auto_service_0", "auto_service_1", "Enum(l7_protocol)", "request_rate"},
+		[]interface{}{"frontend", "cart", "HTTP", 10.0},
+		[]interface{}{"frontend", "payment", "gRPC", 3.0},
+	), nil)
+	diffEdges := DiffEdges(edges, compareEdges)
+	assert.Equal(t, 5, len(diffEdges))
+	statusCount := map[string]int{}
+	for _, edge := range diffEdges {
+		statusCount[edge.Diff.Status]++
+	}
+	assert.Equal(t, map[string]int{DIFF_ADDED: 3, DIFF_REMOVED: 1, DIFF_CHANGED: 1}, statusCount)
+
+	dot := ToDOT(graph)
+	assert.True(t, strings.HasPrefix(dot, "digraph service_map {"))
+	assert.Contains(t, dot, `"cart" -> "redis"`)
+	assert.Contains(t, dot, `"redis" [label="redis\n30.00 req/s, err 10.00%", color=red]`)
+}
+
+func TestBuildEdgesNetworkMetrics(t *testing.T) {
+	appResult := newTestResult(
+		[]string{"auto_service_0", "auto_service_1", "Enum(l7_protocol)", "request_rate"},
+		[]interface{}{"frontend", "cart", "HTTP", 10.0},
+		[]interface{}{"frontend", "cart", "gRPC", 5.0},
+	)
+	// 同一客户端、服务端的网络指标由多行聚合
+	networkResult := newTestResult(
+		[]string{"auto_service_0", "auto_service_1", "is_internet_0", "is_internet_1", "byte_rate", "retrans", "retrans_ratio", "rtt"},
+		[]interface{}{"frontend", "cart", 0, 0, 300.0, uint64(3), 0.1, 100.0},
+		[]interface{}{"frontend", "cart", 1, 0, 100.0, uint64(1), 0.5, 500.0},
+		[]interface{}{"cart", "mysql", 0, 0, 0.0, uint64(0), 0.2, 50.0},
+		[]interface{}{"cart", "mysql", 0, 1, 0.0, uint64(0), 0.4, 150.0},
+	)
+	edges, _ := BuildEdges("auto_service", appResult, networkResult)
+	assert.Equal(t, 3, len(edges))
+	// cart -> mysql 字节速率为 0，取算术平均
+	assert.Equal(t, "", edges[0].Protocol)
+	assert.InDelta(t, 100.0, edges[0].RTT, 1e-9)
+	assert.InDelta(t, 0.3, edges[0].RetransRatio, 1e-9)
+	// frontend -> cart 的网络指标只附加在 HTTP 边上，按字节速率加权
+	assert.Equal(t, "HTTP", edges[1].Protocol)
+	assert.Equal(t, 400.0, edges[1].ByteRate)
+	assert.Equal(t, 4.0, edges[1].Retrans)
+	assert.InDelta(t, 200.0, edges[1].RTT, 1e-9)
+	assert.InDelta(t, 0.2, edges[1].RetransRatio, 1e-9)
+	assert.Equal(t, "gRPC", edges[2].Protocol)
+	assert.Equal(t, 0.0, edges[2].ByteRate)
+	assert.Equal(t, 0.0, edges[2].Retrans)
+	assert.Equal(t, 0.0, edges[2].RTT)
+}
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		tagFilter string
+		want      string
+		wantErr   bool
+	}{
+		{tagFilter: "", want: ""},
+		{tagFilter: "pod_ns_0='default'", want: "pod_ns_0 = 'default'"},
+		{tagFilter: "pod_ns_0 IN ('a', 'b') AND NOT (l7_protocol=20 OR ip_1 LIKE '10.%')", want: "pod_ns_0 in ('a', 'b') and not (l7_protocol = 20 or ip_1 like '10.%')"},
+		{tagFilter: "auto_service_1 IS NOT NULL", want: "auto_service_1 is not null"},
+		{tagFilter: "1=1) OR (1=1", wantErr: true},
+		{tagFilter: "1=1", wantErr: true},
+		{tagFilter: "pod_0='a' GROUP BY pod_0", wantErr: true},
+		{tagFilter: "pod_0='a' LIMIT 1", wantErr: true},
+		{tagFilter: "pod_0 IN (SELECT pod FROM t)", wantErr: true},
+		{tagFilter: "pod_0=pod_1", wantErr: true},
+		{tagFilter: "sleep(1)", wantErr: true},
+		{tagFilter: "pod_0='a'; DROP TABLE t", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTagFilter(tt.tagFilter)
+		if tt.wantErr {
+			assert.Error(t, err, tt.tagFilter)
+			continue
+		}
+		assert.NoError(t, err, tt.tagFilter)
+		assert.Equal(t, tt.want, got, tt.tagFilter)
+	}
+}
+
+func TestServiceMapInvalidCompareWindow(t *testing.T) {
+	for _, window := range [][2]int{{0, 100}, {100, 0}, {200, 100}, {100, 100}, {-100, 100}} {
+		_, _, err := ServiceMap(model.ServiceMap{
+			TimeStart: 1000, TimeEnd: 2000, CompareTimeStart: window[0], CompareTimeEnd: window[1],
+		})
+		serviceError, ok := err.(*common.ServiceError)
+		if assert.True(t, ok, window) {
+			assert.Equal(t, common.INVALID_POST_DATA, serviceError.Status, window)
+		}
+	}
+}
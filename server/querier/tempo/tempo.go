@@ -40,6 +40,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -56,6 +57,27 @@ var L7_TRACING_OTEL_SDK_NAME = "telemetry.sdk.name"
 var L7_TRACING_OTEL_SDK_VERSION = "telemetry.sdk.version"
 var TABLE_NAME_L7_FLOW_LOG = "l7_flow_log"
 
+const (
+	TRACEQL_DEFAULT_LIMIT             = 20
+	TRACEQL_DEFAULT_SPANS_PER_SPANSET = 3
+	TRACEQL_CANDIDATE_TRACE_FACTOR    = 5
+	TRACEQL_MAX_CANDIDATE_SPANS       = 10000
+	TRACEQL_MAX_SPANS                 = 100000
+
+	// signal_source of l7_flow_log
+	SIGNAL_SOURCE_PACKET = 0
+	SIGNAL_SOURCE_XFLOW  = 1
+	SIGNAL_SOURCE_EBPF   = 3
+	SIGNAL_SOURCE_OTEL   = 4
+)
+
+// the order is used by newSpanFromValues
+var TRACEQL_SPAN_FIELDS = []string{
+	"trace_id", "span_id", "parent_span_id", "app_service", "endpoint", "request_resource",
+	"toUnixTimestamp64Micro(start_time) AS start_time_us", "toUnixTimestamp64Micro(end_time) AS end_time_us",
+	"response_duration", "response_status", "span_kind", "request_type", "response_code", "attribute", "signal_source",
+}
+
 var SEARCH_FIELDS = []string{
 	"trace_id as traceID", "app_service as rootServiceName", "endpoint as rootTraceName", "toUnixTimestamp64Micro(start_time) as startTimeUnixNano", "response_duration/1000 as durationMs",
 }
@@ -350,6 +372,9 @@ func ShowTagValues(args *common.TempoParams) (resp map[string][]interface{}, deb
 }
 
 func TraceSearch(args *common.TempoParams) (resp map[string]interface{}, debug map[string]interface{}, err error) {
+	if args.Query != "" {
+		return TraceQLSearch(args)
+	}
 	resp = map[string]interface{}{
 		"metrics": map[string]interface{}{
 			/* 			"inspectedBlocks": 1,
@@ -430,6 +455,205 @@ func TraceSearch(args *common.TempoParams) (resp map[string]interface{}, debug m
 	return resp, debug, err
 }
 
+// TraceQLSearch finds candidate traces with the conditions pushed down to DeepFlow SQL,
+// then evaluates the whole TraceQL pipeline over all spans of each candidate trace
+func TraceQLSearch(args *common.TempoParams) (resp map[string]interface{}, debug map[string]interface{}, err error) {
+	pl, err := ParseTraceQL(args.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit := TRACEQL_DEFAULT_LIMIT
+	if args.Limit != "" {
+		if limit, err = strconv.Atoi(args.Limit); err != nil || limit <= 0 {
+			return nil, nil, fmt.Errorf("invalid limit %s", args.Limit)
+		}
+	}
+	spansPerSet := TRACEQL_DEFAULT_SPANS_PER_SPANSET
+	if args.SpansPerSet != "" {
+		if spansPerSet, err = strconv.Atoi(args.SpansPerSet); err != nil {
+			return nil, nil, fmt.Errorf("invalid spss %s", args.SpansPerSet)
+		}
+	}
+	var minDuration, maxDuration time.Duration
+	if args.MinDuration != "" {
+		if minDuration, err = time.ParseDuration(args.MinDuration); err != nil {
+			return nil, nil, err
+		}
+	}
+	if args.MaxDuration != "" {
+		if maxDuration, err = time.ParseDuration(args.MaxDuration); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	timeFilters := []string{}
+	if args.StartTime != "" {
+		timeFilters = append(timeFilters, fmt.Sprintf("time>=%s", args.StartTime))
+	}
+	if args.EndTime != "" {
+		timeFilters = append(timeFilters, fmt.Sprintf("time<=%s", args.EndTime))
+	}
+	filters := append([]string{"trace_id != ''"}, timeFilters...)
+	if condition := pushdownPipeline(pl); condition != "" {
+		filters = append(filters, condition)
+	}
+	sql := fmt.Sprintf("SELECT trace_id AS traceID, toUnixTimestamp64Micro(start_time) AS startTimeUnixNano FROM %s WHERE %s ORDER BY startTimeUnixNano desc LIMIT %d",
+		TABLE_NAME_L7_FLOW_LOG, strings.Join(filters, " AND "), TRACEQL_MAX_CANDIDATE_SPANS)
+	result, debug, err := executeTraceQLSQL(sql, args)
+	if err != nil {
+		return nil, debug, err
+	}
+	traceIDs := []string{}
+	traceIDSet := map[string]bool{}
+	for _, d := range result.Values {
+		traceID := valueToString(d.([]interface{})[0])
+		if traceIDSet[traceID] {
+			continue
+		}
+		traceIDSet[traceID] = true
+		traceIDs = append(traceIDs, fmt.Sprintf("'%s'", escapeSQLString(traceID)))
+		if len(traceIDs) >= limit*TRACEQL_CANDIDATE_TRACE_FACTOR {
+			break
+		}
+	}
+	resp = map[string]interface{}{
+		"metrics": map[string]interface{}{"inspectedTraces": len(traceIDs)},
+		"traces":  []map[string]interface{}{},
+	}
+	if len(traceIDs) == 0 {
+		return resp, debug, nil
+	}
+
+	filters = append([]string{fmt.Sprintf("trace_id IN (%s)", strings.Join(traceIDs, ", "))}, timeFilters...)
+	// spans are ordered by time, so that the beginning of each trace (the root spans) is kept when the spans are truncated
+	sql = fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY start_time_us asc LIMIT %d",
+		strings.Join(TRACEQL_SPAN_FIELDS, ", "), TABLE_NAME_L7_FLOW_LOG, strings.Join(filters, " AND "), TRACEQL_MAX_SPANS+1)
+	result, debug, err = executeTraceQLSQL(sql, args)
+	if err != nil {
+		return nil, debug, err
+	}
+	values := result.Values
+	if len(values) > TRACEQL_MAX_SPANS {
+		values = values[:TRACEQL_MAX_SPANS]
+		log.Warningf("traceql query (%s) inspects more than %d spans, the later spans are ignored", args.Query, TRACEQL_MAX_SPANS)
+		resp["metrics"].(map[string]interface{})["truncated"] = true
+	}
+	traceSpans := map[string][]*span{}
+	for _, d := range values {
+		s := newSpanFromValues(d.([]interface{}))
+		traceSpans[s.TraceID] = append(traceSpans[s.TraceID], s)
+	}
+	traces := make([]*traceData, 0, len(traceSpans))
+	for traceID, spans := range traceSpans {
+		traces = append(traces, newTraceData(traceID, spans))
+	}
+	sort.Slice(traces, func(i, j int) bool { return traces[i].StartUs > traces[j].StartUs })
+
+	respTraces := []map[string]interface{}{}
+	for _, t := range traces {
+		duration := time.Duration(t.EndUs-t.StartUs) * time.Microsecond
+		if (minDuration > 0 && duration < minDuration) || (maxDuration > 0 && duration > maxDuration) {
+			continue
+		}
+		spanSets := evaluateTrace(pl, t)
+		if len(spanSets) == 0 {
+			continue
+		}
+		respTraces = append(respTraces, traceQLResult(t, spanSets, spansPerSet))
+		if len(respTraces) >= limit {
+			break
+		}
+	}
+	resp["traces"] = respTraces
+	resp["metrics"].(map[string]interface{})["inspectedSpans"] = len(values)
+	return resp, debug, nil
+}
+
+func executeTraceQLSQL(sql string, args *common.TempoParams) (*common.Result, map[string]interface{}, error) {
+	querierArgs := common.QuerierParams{
+		DB:         "flow_log",
+		Sql:        sql,
+		DataSource: "",
+		Debug:      args.Debug,
+		QueryUUID:  uuid.New().String(),
+		Context:    args.Context,
+	}
+	ckEngine := &clickhouse.CHEngine{DB: querierArgs.DB, DataSource: querierArgs.DataSource}
+	ckEngine.Init()
+	return ckEngine.ExecuteQuery(&querierArgs)
+}
+
+func newSpanFromValues(values []interface{}) *span {
+	s := &span{
+		TraceID:      valueToString(values[0]),
+		SpanID:       valueToString(values[1]),
+		ParentSpanID: valueToString(values[2]),
+		Service:      valueToString(values[3]),
+		Name:         valueToString(values[4]),
+		StartUs:      valueToInt64(values[6]),
+		EndUs:        valueToInt64(values[7]),
+		DurationUs:   valueToInt64(values[8]),
+		Kind:         int(valueToInt64(values[10])),
+		SignalSource: int(valueToInt64(values[14])),
+		Fields: map[string]static{
+			"service.name":     newStaticString(valueToString(values[3])),
+			"http.method":      newStaticString(valueToString(values[11])),
+			"http.status_code": newStaticNumber(float64(valueToInt64(values[12]))),
+		},
+		Attributes: map[string]string{},
+	}
+	if s.Name == "" {
+		s.Name = valueToString(values[5])
+	}
+	switch valueToInt64(values[9]) {
+	case 0:
+		s.Status = "ok"
+	case 3, 4:
+		s.Status = "error"
+	default:
+		s.Status = "unset"
+	}
+	if attrs := valueToString(values[13]); attrs != "" {
+		json.Unmarshal([]byte(attrs), &s.Attributes)
+	}
+	return s
+}
+
+func valueToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+func valueToInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
+
 func decodeIdBytes(id string, length int, idMap map[string][]byte) []byte {
 	idBytes := []byte{}
 	if len(id) == length*2 {
@@ -0,0 +1,784 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tempo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TraceQL subset supported by the tempo-compatible search api:
+//
+//	{ .http.method = "GET" && duration > 100ms }
+//	{ resource.service.name =~ "order-.*" } >> { status = error }
+//	{ name = "GET /api" } | select(span.http.url) | count() > 2
+//	{ } | avg(duration) > 1s
+//
+// Spanset operators: && || > >> ~, field operators: = != > >= < <= =~ !~ && || !,
+// aggregates: count() avg() min() max() sum().
+
+const (
+	tokEOF = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokLBrace
+	tokRBrace
+	tokLParen
+	tokRParen
+	tokPipe
+	tokComma
+	tokAnd
+	tokOr
+	tokNot
+	tokEq
+	tokNeq
+	tokRegex
+	tokNotRegex
+	tokGt
+	tokGte
+	tokLt
+	tokLte
+	tokDesc
+	tokSibling
+	tokMinus
+)
+
+type token struct {
+	typ int
+	val string
+	pos int
+}
+
+type lexer struct {
+	input  string
+	pos    int
+	tokens []token
+}
+
+func lexTraceQL(input string) ([]token, error) {
+	l := &lexer{input: input}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		l.tokens = append(l.tokens, tok)
+		if tok.typ == tokEOF {
+			return l.tokens, nil
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{typ: tokEOF, pos: start}, nil
+	}
+	c := l.input[l.pos]
+	two := ""
+	if l.pos+1 < len(l.input) {
+		two = l.input[l.pos : l.pos+2]
+	}
+	switch two {
+	case "&&":
+		l.pos += 2
+		return token{tokAnd, two, start}, nil
+	case "||":
+		l.pos += 2
+		return token{tokOr, two, start}, nil
+	case "!=":
+		l.pos += 2
+		return token{tokNeq, two, start}, nil
+	case "=~":
+		l.pos += 2
+		return token{tokRegex, two, start}, nil
+	case "!~":
+		l.pos += 2
+		return token{tokNotRegex, two, start}, nil
+	case ">=":
+		l.pos += 2
+		return token{tokGte, two, start}, nil
+	case "<=":
+		l.pos += 2
+		return token{tokLte, two, start}, nil
+	case ">>":
+		l.pos += 2
+		return token{tokDesc, two, start}, nil
+	}
+	switch c {
+	case '{':
+		l.pos++
+		return token{tokLBrace, "{", start}, nil
+	case '}':
+		l.pos++
+		return token{tokRBrace, "}", start}, nil
+	case '(':
+		l.pos++
+		return token{tokLParen, "(", start}, nil
+	case ')':
+		l.pos++
+		return token{tokRParen, ")", start}, nil
+	case '|':
+		l.pos++
+		return token{tokPipe, "|", start}, nil
+	case ',':
+		l.pos++
+		return token{tokComma, ",", start}, nil
+	case '!':
+		l.pos++
+		return token{tokNot, "!", start}, nil
+	case '=':
+		l.pos++
+		return token{tokEq, "=", start}, nil
+	case '>':
+		l.pos++
+		return token{tokGt, ">", start}, nil
+	case '<':
+		l.pos++
+		return token{tokLt, "<", start}, nil
+	case '~':
+		l.pos++
+		return token{tokSibling, "~", start}, nil
+	case '-':
+		l.pos++
+		return token{tokMinus, "-", start}, nil
+	case '"', '`':
+		return l.lexString(c)
+	}
+	if c >= '0' && c <= '9' {
+		return l.lexNumber()
+	}
+	if isIdentStart(c) {
+		for l.pos < len(l.input) && isIdentChar(l.input[l.pos]) {
+			l.pos++
+		}
+		return token{tokIdent, l.input[start:l.pos], start}, nil
+	}
+	return token{}, fmt.Errorf("traceql: unexpected character %q at position %d", c, start)
+}
+
+func (l *lexer) lexString(quote byte) (token, error) {
+	start := l.pos
+	l.pos++
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		if c == quote {
+			l.pos++
+			return token{tokString, sb.String(), start}, nil
+		}
+		// backquoted strings are raw, which is convenient for regular expressions
+		if c == '\\' && quote == '"' && l.pos+1 < len(l.input) {
+			l.pos++
+			c = l.input[l.pos]
+			switch c {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			}
+		}
+		sb.WriteByte(c)
+		l.pos++
+	}
+	return token{}, fmt.Errorf("traceql: unterminated string at position %d", start)
+}
+
+func (l *lexer) lexNumber() (token, error) {
+	start := l.pos
+	for l.pos < len(l.input) && (l.input[l.pos] >= '0' && l.input[l.pos] <= '9' || l.input[l.pos] == '.') {
+		l.pos++
+	}
+	unitStart := l.pos
+	for l.pos < len(l.input) && (unicode.IsLetter(rune(l.input[l.pos])) || l.input[l.pos] == 0xc2 || l.input[l.pos] == 0xb5) {
+		l.pos++
+	}
+	if unitStart == l.pos {
+		return token{tokNumber, l.input[start:l.pos], start}, nil
+	}
+	if _, err := time.ParseDuration(l.input[start:l.pos]); err != nil {
+		return token{}, fmt.Errorf("traceql: invalid duration %q at position %d", l.input[start:l.pos], start)
+	}
+	return token{tokDuration, l.input[start:l.pos], start}, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '.' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '-' || c == '/' || c == ':'
+}
+
+const (
+	scopeNone = iota
+	scopeIntrinsic
+	scopeSpan
+	scopeResource
+)
+
+const (
+	INTRINSIC_DURATION          = "duration"
+	INTRINSIC_NAME              = "name"
+	INTRINSIC_STATUS            = "status"
+	INTRINSIC_KIND              = "kind"
+	INTRINSIC_ROOT_NAME         = "rootName"
+	INTRINSIC_ROOT_SERVICE_NAME = "rootServiceName"
+	INTRINSIC_TRACE_DURATION    = "traceDuration"
+)
+
+var traceQLIntrinsics = map[string]bool{
+	INTRINSIC_DURATION:          true,
+	INTRINSIC_NAME:              true,
+	INTRINSIC_STATUS:            true,
+	INTRINSIC_KIND:              true,
+	INTRINSIC_ROOT_NAME:         true,
+	INTRINSIC_ROOT_SERVICE_NAME: true,
+	INTRINSIC_TRACE_DURATION:    true,
+}
+
+var traceQLStatuses = map[string]bool{"ok": true, "error": true, "unset": true}
+
+var traceQLKinds = map[string]int{
+	"unspecified": 0,
+	"internal":    1,
+	"server":      2,
+	"client":      3,
+	"producer":    4,
+	"consumer":    5,
+}
+
+type attribute struct {
+	scope int
+	name  string
+}
+
+func (a attribute) String() string {
+	switch a.scope {
+	case scopeIntrinsic:
+		return a.name
+	case scopeSpan:
+		return "span." + a.name
+	case scopeResource:
+		return "resource." + a.name
+	}
+	return "." + a.name
+}
+
+func newAttribute(ident string) attribute {
+	switch {
+	case strings.HasPrefix(ident, "."):
+		return attribute{scope: scopeNone, name: ident[1:]}
+	case strings.HasPrefix(ident, "span."):
+		return attribute{scope: scopeSpan, name: strings.TrimPrefix(ident, "span.")}
+	case strings.HasPrefix(ident, "resource."):
+		return attribute{scope: scopeResource, name: strings.TrimPrefix(ident, "resource.")}
+	}
+	return attribute{scope: scopeIntrinsic, name: ident}
+}
+
+// fieldExpression is evaluated against a single span
+type fieldExpression interface {
+	fmt.Stringer
+}
+
+type binaryFieldExpr struct {
+	op    int
+	lhs   fieldExpression
+	rhs   fieldExpression
+	regex *regexp.Regexp
+}
+
+func (e *binaryFieldExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.lhs, tokenString(e.op), e.rhs)
+}
+
+type unaryFieldExpr struct {
+	op   int
+	expr fieldExpression
+}
+
+func (e *unaryFieldExpr) String() string {
+	return fmt.Sprintf("%s%s", tokenString(e.op), e.expr)
+}
+
+// spansetElement transforms the spansets of a trace
+type spansetElement interface {
+	fmt.Stringer
+}
+
+type spansetFilter struct {
+	expr fieldExpression // nil matches all spans
+}
+
+func (f *spansetFilter) String() string {
+	if f.expr == nil {
+		return "{ }"
+	}
+	return fmt.Sprintf("{ %s }", f.expr)
+}
+
+type spansetOperation struct {
+	op  int
+	lhs spansetElement
+	rhs spansetElement
+}
+
+func (o *spansetOperation) String() string {
+	return fmt.Sprintf("(%s %s %s)", o.lhs, tokenString(o.op), o.rhs)
+}
+
+type pipeline struct {
+	elements []spansetElement
+}
+
+func (p *pipeline) String() string {
+	elements := make([]string, 0, len(p.elements))
+	for _, e := range p.elements {
+		elements = append(elements, e.String())
+	}
+	return strings.Join(elements, " | ")
+}
+
+const (
+	AGGREGATE_COUNT = "count"
+	AGGREGATE_AVG   = "avg"
+	AGGREGATE_MIN   = "min"
+	AGGREGATE_MAX   = "max"
+	AGGREGATE_SUM   = "sum"
+)
+
+type aggregate struct {
+	function string
+	attr     attribute
+}
+
+func (a *aggregate) String() string {
+	if a.function == AGGREGATE_COUNT {
+		return "count()"
+	}
+	return fmt.Sprintf("%s(%s)", a.function, a.attr)
+}
+
+type scalarFilter struct {
+	agg   *aggregate
+	op    int
+	value static
+}
+
+func (f *scalarFilter) String() string {
+	return fmt.Sprintf("%s %s %s", f.agg, tokenString(f.op), f.value)
+}
+
+type selectOperation struct {
+	attrs []attribute
+}
+
+func (s *selectOperation) String() string {
+	attrs := make([]string, 0, len(s.attrs))
+	for _, a := range s.attrs {
+		attrs = append(attrs, a.String())
+	}
+	return fmt.Sprintf("select(%s)", strings.Join(attrs, ", "))
+}
+
+func tokenString(typ int) string {
+	switch typ {
+	case tokAnd:
+		return "&&"
+	case tokOr:
+		return "||"
+	case tokNot:
+		return "!"
+	case tokEq:
+		return "="
+	case tokNeq:
+		return "!="
+	case tokRegex:
+		return "=~"
+	case tokNotRegex:
+		return "!~"
+	case tokGt:
+		return ">"
+	case tokGte:
+		return ">="
+	case tokLt:
+		return "<"
+	case tokLte:
+		return "<="
+	case tokDesc:
+		return ">>"
+	case tokSibling:
+		return "~"
+	case tokMinus:
+		return "-"
+	}
+	return "?"
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// ParseTraceQL parses a TraceQL query into a pipeline of spanset elements
+func ParseTraceQL(query string) (*pipeline, error) {
+	tokens, err := lexTraceQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	pl, err := p.parsePipeline()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != tokEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.val)
+	}
+	return pl, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.typ != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(typ int, val string) (token, error) {
+	tok := p.advance()
+	if tok.typ != typ {
+		return tok, p.errorf(tok, "expected %q, got %q", val, tok.val)
+	}
+	return tok, nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return fmt.Errorf("traceql: %s at position %d", fmt.Sprintf(format, args...), tok.pos)
+}
+
+func (p *parser) parsePipeline() (*pipeline, error) {
+	first, err := p.parseSpansetExpr()
+	if err != nil {
+		return nil, err
+	}
+	pl := &pipeline{elements: []spansetElement{first}}
+	for p.peek().typ == tokPipe {
+		p.advance()
+		element, err := p.parsePipelineElement()
+		if err != nil {
+			return nil, err
+		}
+		pl.elements = append(pl.elements, element)
+	}
+	return pl, nil
+}
+
+func (p *parser) parsePipelineElement() (spansetElement, error) {
+	tok := p.peek()
+	if tok.typ != tokIdent {
+		return p.parseSpansetExpr()
+	}
+	switch tok.val {
+	case "select":
+		p.advance()
+		if _, err := p.expect(tokLParen, "("); err != nil {
+			return nil, err
+		}
+		sel := &selectOperation{}
+		for {
+			attrTok, err := p.expect(tokIdent, "attribute")
+			if err != nil {
+				return nil, err
+			}
+			sel.attrs = append(sel.attrs, newAttribute(attrTok.val))
+			if p.peek().typ != tokComma {
+				break
+			}
+			p.advance()
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return sel, nil
+	case AGGREGATE_COUNT, AGGREGATE_AVG, AGGREGATE_MIN, AGGREGATE_MAX, AGGREGATE_SUM:
+		p.advance()
+		agg := &aggregate{function: tok.val}
+		if _, err := p.expect(tokLParen, "("); err != nil {
+			return nil, err
+		}
+		if agg.function != AGGREGATE_COUNT {
+			attrTok, err := p.expect(tokIdent, "attribute")
+			if err != nil {
+				return nil, err
+			}
+			agg.attr = newAttribute(attrTok.val)
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		opTok := p.advance()
+		switch opTok.typ {
+		case tokEq, tokNeq, tokGt, tokGte, tokLt, tokLte:
+		default:
+			return nil, p.errorf(opTok, "expected comparison after %s, got %q", agg, opTok.val)
+		}
+		value, err := p.parseStatic()
+		if err != nil {
+			return nil, err
+		}
+		return &scalarFilter{agg: agg, op: opTok.typ, value: value}, nil
+	}
+	return nil, p.errorf(tok, "unsupported pipeline element %q", tok.val)
+}
+
+// spanset operator precedence: || < && < structural operators
+func (p *parser) parseSpansetExpr() (spansetElement, error) {
+	lhs, err := p.parseSpansetAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokOr {
+		p.advance()
+		rhs, err := p.parseSpansetAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &spansetOperation{op: tokOr, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseSpansetAnd() (spansetElement, error) {
+	lhs, err := p.parseSpansetStructural()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokAnd {
+		p.advance()
+		rhs, err := p.parseSpansetStructural()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &spansetOperation{op: tokAnd, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseSpansetStructural() (spansetElement, error) {
+	lhs, err := p.parseSpansetTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek().typ
+		if op != tokGt && op != tokDesc && op != tokSibling {
+			return lhs, nil
+		}
+		p.advance()
+		rhs, err := p.parseSpansetTerm()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &spansetOperation{op: op, lhs: lhs, rhs: rhs}
+	}
+}
+
+func (p *parser) parseSpansetTerm() (spansetElement, error) {
+	tok := p.advance()
+	switch tok.typ {
+	case tokLBrace:
+		filter := &spansetFilter{}
+		if p.peek().typ != tokRBrace {
+			expr, err := p.parseFieldOr()
+			if err != nil {
+				return nil, err
+			}
+			filter.expr = expr
+		}
+		if _, err := p.expect(tokRBrace, "}"); err != nil {
+			return nil, err
+		}
+		return filter, nil
+	case tokLParen:
+		pl, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		if len(pl.elements) == 1 {
+			return pl.elements[0], nil
+		}
+		return pl, nil
+	}
+	return nil, p.errorf(tok, "expected spanset, got %q", tok.val)
+}
+
+func (p *parser) parseFieldOr() (fieldExpression, error) {
+	lhs, err := p.parseFieldAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokOr {
+		p.advance()
+		rhs, err := p.parseFieldAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &binaryFieldExpr{op: tokOr, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseFieldAnd() (fieldExpression, error) {
+	lhs, err := p.parseFieldComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokAnd {
+		p.advance()
+		rhs, err := p.parseFieldComparison()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &binaryFieldExpr{op: tokAnd, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseFieldComparison() (fieldExpression, error) {
+	lhs, err := p.parseFieldUnary()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek().typ
+	switch op {
+	case tokEq, tokNeq, tokGt, tokGte, tokLt, tokLte, tokRegex, tokNotRegex:
+	default:
+		return lhs, nil
+	}
+	opTok := p.advance()
+	rhs, err := p.parseFieldUnary()
+	if err != nil {
+		return nil, err
+	}
+	expr := &binaryFieldExpr{op: op, lhs: lhs, rhs: rhs}
+	if op == tokRegex || op == tokNotRegex {
+		pattern, ok := rhs.(static)
+		if !ok || pattern.typ != typeString {
+			return nil, p.errorf(opTok, "regular expression must be a string")
+		}
+		// regular expressions are fully anchored as in tempo
+		expr.regex, err = regexp.Compile("^(?:" + pattern.s + ")$")
+		if err != nil {
+			return nil, p.errorf(opTok, "invalid regular expression %q: %s", pattern.s, err)
+		}
+	}
+	return expr, nil
+}
+
+func (p *parser) parseFieldUnary() (fieldExpression, error) {
+	tok := p.peek()
+	switch tok.typ {
+	case tokNot:
+		p.advance()
+		expr, err := p.parseFieldUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryFieldExpr{op: tokNot, expr: expr}, nil
+	case tokLParen:
+		p.advance()
+		expr, err := p.parseFieldOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tokIdent:
+		if _, ok := traceQLIntrinsics[tok.val]; ok || strings.Contains(tok.val, ".") {
+			p.advance()
+			return newAttribute(tok.val), nil
+		}
+	}
+	return p.parseStatic()
+}
+
+func (p *parser) parseStatic() (static, error) {
+	tok := p.advance()
+	negative := false
+	if tok.typ == tokMinus {
+		negative = true
+		tok = p.advance()
+	}
+	switch tok.typ {
+	case tokString:
+		if !negative {
+			return newStaticString(tok.val), nil
+		}
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return static{}, p.errorf(tok, "invalid number %q", tok.val)
+		}
+		if negative {
+			n = -n
+		}
+		return newStaticNumber(n), nil
+	case tokDuration:
+		d, _ := time.ParseDuration(tok.val)
+		if negative {
+			d = -d
+		}
+		return newStaticDuration(d), nil
+	case tokIdent:
+		if negative {
+			break
+		}
+		switch {
+		case tok.val == "true" || tok.val == "false":
+			return newStaticBool(tok.val == "true"), nil
+		case tok.val == "nil":
+			return static{typ: typeNil}, nil
+		case traceQLStatuses[tok.val]:
+			return static{typ: typeStatus, s: tok.val}, nil
+		}
+		if _, ok := traceQLKinds[tok.val]; ok {
+			return static{typ: typeKind, s: tok.val}, nil
+		}
+		return static{}, p.errorf(tok, "unknown identifier %q", tok.val)
+	}
+	return static{}, p.errorf(tok, "expected value, got %q", tok.val)
+}
@@ -0,0 +1,708 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tempo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	typeNil = iota
+	typeString
+	typeNumber
+	typeDuration // n is in microseconds, the precision of response_duration
+	typeBool
+	typeStatus
+	typeKind
+)
+
+type static struct {
+	typ int
+	s   string
+	n   float64
+	b   bool
+}
+
+func newStaticString(s string) static {
+	return static{typ: typeString, s: s}
+}
+
+func newStaticNumber(n float64) static {
+	return static{typ: typeNumber, n: n}
+}
+
+func newStaticDuration(d time.Duration) static {
+	return static{typ: typeDuration, n: float64(d.Microseconds())}
+}
+
+func newStaticBool(b bool) static {
+	return static{typ: typeBool, b: b}
+}
+
+func (s static) String() string {
+	switch s.typ {
+	case typeString:
+		return strconv.Quote(s.s)
+	case typeNumber:
+		return strconv.FormatFloat(s.n, 'f', -1, 64)
+	case typeDuration:
+		return (time.Duration(s.n) * time.Microsecond).String()
+	case typeBool:
+		return strconv.FormatBool(s.b)
+	case typeStatus, typeKind:
+		return s.s
+	}
+	return "nil"
+}
+
+// text returns the value used in string comparisons and regular expressions
+func (s static) text() string {
+	if s.typ == typeString {
+		return s.s
+	}
+	return s.String()
+}
+
+func (s static) number() (float64, bool) {
+	switch s.typ {
+	case typeNumber, typeDuration:
+		return s.n, true
+	case typeString:
+		n, err := strconv.ParseFloat(s.s, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func (s static) toAttributeValue() map[string]interface{} {
+	switch s.typ {
+	case typeNumber:
+		if s.n == math.Trunc(s.n) {
+			return map[string]interface{}{"intValue": strconv.FormatInt(int64(s.n), 10)}
+		}
+		return map[string]interface{}{"doubleValue": s.n}
+	case typeDuration:
+		return map[string]interface{}{"stringValue": s.String()}
+	case typeBool:
+		return map[string]interface{}{"boolValue": s.b}
+	}
+	return map[string]interface{}{"stringValue": s.text()}
+}
+
+// span is a row of l7_flow_log used by the TraceQL evaluator
+type span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Service      string
+	Name         string
+	StartUs      int64
+	EndUs        int64
+	DurationUs   int64
+	Status       string
+	Kind         int
+	SignalSource int
+	Fields       map[string]static // well-known columns mapped to otel attribute names
+	Attributes   map[string]string
+}
+
+type traceData struct {
+	TraceID string
+	Spans   []*span
+	Root    *span
+	StartUs int64
+	EndUs   int64
+
+	spanByID map[spanKey]*span
+}
+
+// the same span may be captured by several signal sources (e.g. otel and ebpf), and by the client and server side
+// of the same signal source, so spans are indexed by span id and signal source, the earliest one is used for the same key
+type spanKey struct {
+	spanID       string
+	signalSource int
+}
+
+// when the parent span is not captured by the same signal source, the parent is looked up in this order,
+// spans reported by the application (otel) are preferred since they reflect the call hierarchy of the code
+var parentSignalSources = []int{SIGNAL_SOURCE_OTEL, SIGNAL_SOURCE_EBPF, SIGNAL_SOURCE_PACKET, SIGNAL_SOURCE_XFLOW}
+
+func newTraceData(traceID string, spans []*span) *traceData {
+	t := &traceData{TraceID: traceID, Spans: spans, spanByID: make(map[spanKey]*span, len(spans))}
+	for i, s := range spans {
+		if s.SpanID != "" {
+			key := spanKey{s.SpanID, s.SignalSource}
+			if indexed, ok := t.spanByID[key]; !ok || s.StartUs < indexed.StartUs {
+				t.spanByID[key] = s
+			}
+		}
+		if i == 0 || s.StartUs < t.StartUs {
+			t.StartUs = s.StartUs
+		}
+		if s.EndUs > t.EndUs {
+			t.EndUs = s.EndUs
+		}
+	}
+	// the root span has no parent in the trace, the earliest one wins
+	for _, s := range spans {
+		if t.parent(s) != nil {
+			continue
+		}
+		if t.Root == nil || s.StartUs < t.Root.StartUs {
+			t.Root = s
+		}
+	}
+	return t
+}
+
+func (t *traceData) parent(s *span) *span {
+	if s.ParentSpanID == "" || s.ParentSpanID == s.SpanID {
+		return nil
+	}
+	if p, ok := t.spanByID[spanKey{s.ParentSpanID, s.SignalSource}]; ok {
+		return p
+	}
+	for _, signalSource := range parentSignalSources {
+		if p, ok := t.spanByID[spanKey{s.ParentSpanID, signalSource}]; ok {
+			return p
+		}
+	}
+	return nil
+}
+
+type spanSet struct {
+	Spans      []*span
+	Selected   []attribute
+	Attributes []attributeResult
+}
+
+type attributeResult struct {
+	key   string
+	value static
+}
+
+func (ss *spanSet) clone(spans []*span) *spanSet {
+	return &spanSet{Spans: spans, Selected: ss.Selected, Attributes: ss.Attributes}
+}
+
+// evaluateTrace runs the pipeline over all spans of a trace and returns the matched spansets
+func evaluateTrace(pl *pipeline, t *traceData) []*spanSet {
+	return evaluateElement(pl, t, []*spanSet{{Spans: t.Spans}})
+}
+
+func evaluateElement(element spansetElement, t *traceData, input []*spanSet) []*spanSet {
+	var output []*spanSet
+	switch e := element.(type) {
+	case *pipeline:
+		output = input
+		for _, child := range e.elements {
+			output = evaluateElement(child, t, output)
+			if len(output) == 0 {
+				break
+			}
+		}
+	case *spansetFilter:
+		for _, ss := range input {
+			var matched []*span
+			for _, s := range ss.Spans {
+				if e.expr == nil || evaluateField(e.expr, s, t).b {
+					matched = append(matched, s)
+				}
+			}
+			if len(matched) > 0 {
+				output = append(output, ss.clone(matched))
+			}
+		}
+	case *spansetOperation:
+		for _, ss := range input {
+			lhs := evaluateElement(e.lhs, t, []*spanSet{ss})
+			rhs := evaluateElement(e.rhs, t, []*spanSet{ss})
+			var matched []*span
+			switch e.op {
+			case tokAnd:
+				if len(lhs) == 0 || len(rhs) == 0 {
+					continue
+				}
+				matched = unionSpans(lhs, rhs)
+			case tokOr:
+				matched = unionSpans(lhs, rhs)
+			default:
+				matched = structuralMatch(e.op, t, collectSpans(lhs), collectSpans(rhs))
+			}
+			if len(matched) > 0 {
+				output = append(output, ss.clone(matched))
+			}
+		}
+	case *scalarFilter:
+		for _, ss := range input {
+			value, ok := evaluateAggregate(e.agg, ss, t)
+			if !ok || !compareStatic(e.op, value, e.value) {
+				continue
+			}
+			result := ss.clone(ss.Spans)
+			result.Attributes = append(append([]attributeResult{}, ss.Attributes...), attributeResult{key: e.agg.String(), value: value})
+			output = append(output, result)
+		}
+	case *selectOperation:
+		for _, ss := range input {
+			result := ss.clone(ss.Spans)
+			result.Selected = append(append([]attribute{}, ss.Selected...), e.attrs...)
+			output = append(output, result)
+		}
+	}
+	return output
+}
+
+func collectSpans(sets []*spanSet) []*span {
+	var spans []*span
+	for _, ss := range sets {
+		spans = append(spans, ss.Spans...)
+	}
+	return spans
+}
+
+func unionSpans(lhs, rhs []*spanSet) []*span {
+	seen := map[*span]bool{}
+	var spans []*span
+	for _, s := range append(collectSpans(lhs), collectSpans(rhs)...) {
+		if !seen[s] {
+			seen[s] = true
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// structural operators return the spans on the right side which are children, descendants
+// or siblings of any span on the left side
+func structuralMatch(op int, t *traceData, lhs, rhs []*span) []*span {
+	lhsSet := make(map[*span]bool, len(lhs))
+	lhsParents := map[*span]bool{}
+	for _, s := range lhs {
+		lhsSet[s] = true
+		if p := t.parent(s); p != nil {
+			lhsParents[p] = true
+		}
+	}
+	var matched []*span
+	for _, s := range rhs {
+		switch op {
+		case tokGt:
+			if p := t.parent(s); p != nil && lhsSet[p] {
+				matched = append(matched, s)
+			}
+		case tokDesc:
+			visited := map[*span]bool{s: true}
+			for p := t.parent(s); p != nil && !visited[p]; p = t.parent(p) {
+				if lhsSet[p] {
+					matched = append(matched, s)
+					break
+				}
+				visited[p] = true
+			}
+		case tokSibling:
+			if p := t.parent(s); p != nil && lhsParents[p] {
+				for _, l := range lhs {
+					if l != s && t.parent(l) == p {
+						matched = append(matched, s)
+						break
+					}
+				}
+			}
+		}
+	}
+	return matched
+}
+
+func evaluateAggregate(agg *aggregate, ss *spanSet, t *traceData) (static, bool) {
+	if agg.function == AGGREGATE_COUNT {
+		return newStaticNumber(float64(len(ss.Spans))), true
+	}
+	var values []float64
+	valueType := typeNumber
+	for _, s := range ss.Spans {
+		v := attributeValue(agg.attr, s, t)
+		if n, ok := v.number(); ok {
+			values = append(values, n)
+			if v.typ == typeDuration {
+				valueType = typeDuration
+			}
+		}
+	}
+	if len(values) == 0 {
+		return static{}, false
+	}
+	result := values[0]
+	switch agg.function {
+	case AGGREGATE_SUM, AGGREGATE_AVG:
+		result = 0
+		for _, v := range values {
+			result += v
+		}
+		if agg.function == AGGREGATE_AVG {
+			result /= float64(len(values))
+		}
+	case AGGREGATE_MIN:
+		for _, v := range values {
+			result = math.Min(result, v)
+		}
+	case AGGREGATE_MAX:
+		for _, v := range values {
+			result = math.Max(result, v)
+		}
+	}
+	return static{typ: valueType, n: result}, true
+}
+
+func evaluateField(expr fieldExpression, s *span, t *traceData) static {
+	switch e := expr.(type) {
+	case static:
+		return e
+	case attribute:
+		return attributeValue(e, s, t)
+	case *unaryFieldExpr:
+		v := evaluateField(e.expr, s, t)
+		return newStaticBool(v.typ == typeBool && !v.b)
+	case *binaryFieldExpr:
+		switch e.op {
+		case tokAnd:
+			return newStaticBool(evaluateField(e.lhs, s, t).b && evaluateField(e.rhs, s, t).b)
+		case tokOr:
+			return newStaticBool(evaluateField(e.lhs, s, t).b || evaluateField(e.rhs, s, t).b)
+		case tokRegex, tokNotRegex:
+			lhs := evaluateField(e.lhs, s, t)
+			if lhs.typ == typeNil {
+				return newStaticBool(false)
+			}
+			return newStaticBool(e.regex.MatchString(lhs.text()) == (e.op == tokRegex))
+		}
+		return newStaticBool(compareStatic(e.op, evaluateField(e.lhs, s, t), evaluateField(e.rhs, s, t)))
+	}
+	return static{}
+}
+
+// compareStatic compares two values, a missing attribute never matches
+func compareStatic(op int, lhs, rhs static) bool {
+	if lhs.typ == typeNil || rhs.typ == typeNil {
+		return false
+	}
+	var cmp int
+	ln, lok := lhs.number()
+	rn, rok := rhs.number()
+	switch {
+	case lok && rok && (lhs.typ != typeString || rhs.typ != typeString):
+		cmp = compareFloat(ln, rn)
+	case lhs.typ == typeBool || rhs.typ == typeBool:
+		lb, lok := staticBool(lhs)
+		rb, rok := staticBool(rhs)
+		if !lok || !rok || (op != tokEq && op != tokNeq) {
+			return false
+		}
+		if lb != rb {
+			cmp = 1
+		}
+	default:
+		cmp = strings.Compare(lhs.text(), rhs.text())
+	}
+	switch op {
+	case tokEq:
+		return cmp == 0
+	case tokNeq:
+		return cmp != 0
+	case tokGt:
+		return cmp > 0
+	case tokGte:
+		return cmp >= 0
+	case tokLt:
+		return cmp < 0
+	case tokLte:
+		return cmp <= 0
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func staticBool(s static) (bool, bool) {
+	switch s.typ {
+	case typeBool:
+		return s.b, true
+	case typeString:
+		b, err := strconv.ParseBool(s.s)
+		return b, err == nil
+	}
+	return false, false
+}
+
+func attributeValue(a attribute, s *span, t *traceData) static {
+	if a.scope == scopeIntrinsic {
+		switch a.name {
+		case INTRINSIC_DURATION:
+			return static{typ: typeDuration, n: float64(s.DurationUs)}
+		case INTRINSIC_NAME:
+			return newStaticString(s.Name)
+		case INTRINSIC_STATUS:
+			return static{typ: typeStatus, s: s.Status}
+		case INTRINSIC_KIND:
+			for name, kind := range traceQLKinds {
+				if kind == s.Kind {
+					return static{typ: typeKind, s: name}
+				}
+			}
+			return static{typ: typeKind, s: "unspecified"}
+		case INTRINSIC_ROOT_NAME:
+			if t.Root != nil {
+				return newStaticString(t.Root.Name)
+			}
+		case INTRINSIC_ROOT_SERVICE_NAME:
+			if t.Root != nil {
+				return newStaticString(t.Root.Service)
+			}
+		case INTRINSIC_TRACE_DURATION:
+			return static{typ: typeDuration, n: float64(t.EndUs - t.StartUs)}
+		}
+		return static{}
+	}
+	if v, ok := s.Fields[a.name]; ok {
+		return v
+	}
+	if v, ok := s.Attributes[a.name]; ok {
+		return newStaticString(v)
+	}
+	return static{}
+}
+
+// column of l7_flow_log for the otel attribute, numeric columns support range comparisons
+type traceQLColumn struct {
+	name    string
+	numeric bool
+}
+
+var TRACEQL_ATTRIBUTE_COLUMNS = map[string]traceQLColumn{
+	"service.name":     {name: L7_FLOW_LOG_SERVICE_NAME},
+	"http.method":      {name: "request_type"},
+	"http.status_code": {name: "response_code", numeric: true},
+}
+
+var TRACEQL_INTRINSIC_COLUMNS = map[string]traceQLColumn{
+	INTRINSIC_NAME:     {name: L7_TRACING_ENDPOINT},
+	INTRINSIC_DURATION: {name: "response_duration", numeric: true},
+	INTRINSIC_KIND:     {name: "span_kind", numeric: true},
+}
+
+// response_status of l7_flow_log: 0 normal, 3 server error, 4 client error
+var TRACEQL_STATUS_CONDITIONS = map[string]string{
+	"ok":    "response_status=0",
+	"error": "response_status IN (3, 4)",
+	"unset": "response_status NOT IN (0, 3, 4)",
+}
+
+// pushdownPipeline translates the first spanset expression of the pipeline into a DeepFlow SQL
+// condition that every matching trace must have a span satisfying, "" if nothing can be pushed down
+func pushdownPipeline(pl *pipeline) string {
+	if len(pl.elements) == 0 {
+		return ""
+	}
+	return pushdownSpanset(pl.elements[0])
+}
+
+func pushdownSpanset(element spansetElement) string {
+	switch e := element.(type) {
+	case *pipeline:
+		return pushdownPipeline(e)
+	case *spansetFilter:
+		if e.expr == nil {
+			return ""
+		}
+		return pushdownField(e.expr)
+	case *spansetOperation:
+		lhs, rhs := pushdownSpanset(e.lhs), pushdownSpanset(e.rhs)
+		if e.op == tokOr {
+			if lhs == "" || rhs == "" {
+				return ""
+			}
+			return fmt.Sprintf("(%s OR %s)", lhs, rhs)
+		}
+		// && and structural operators need spans matching both sides, which are different rows
+		switch {
+		case lhs == "":
+			return rhs
+		case rhs == "":
+			return lhs
+		}
+		return fmt.Sprintf("(%s OR %s)", lhs, rhs)
+	}
+	return ""
+}
+
+func pushdownField(expr fieldExpression) string {
+	e, ok := expr.(*binaryFieldExpr)
+	if !ok {
+		return ""
+	}
+	switch e.op {
+	case tokAnd:
+		lhs, rhs := pushdownField(e.lhs), pushdownField(e.rhs)
+		switch {
+		case lhs == "":
+			return rhs
+		case rhs == "":
+			return lhs
+		}
+		return fmt.Sprintf("(%s AND %s)", lhs, rhs)
+	case tokOr:
+		lhs, rhs := pushdownField(e.lhs), pushdownField(e.rhs)
+		if lhs == "" || rhs == "" {
+			return ""
+		}
+		return fmt.Sprintf("(%s OR %s)", lhs, rhs)
+	}
+	attr, ok := e.lhs.(attribute)
+	if !ok {
+		return ""
+	}
+	value, ok := e.rhs.(static)
+	if !ok {
+		return ""
+	}
+	if attr.scope == scopeIntrinsic && attr.name == INTRINSIC_STATUS {
+		condition, ok := TRACEQL_STATUS_CONDITIONS[value.s]
+		if !ok || value.typ != typeStatus {
+			return ""
+		}
+		switch e.op {
+		case tokEq:
+			return condition
+		case tokNeq:
+			return fmt.Sprintf("NOT (%s)", condition)
+		}
+		return ""
+	}
+
+	var column traceQLColumn
+	if attr.scope == scopeIntrinsic {
+		if column, ok = TRACEQL_INTRINSIC_COLUMNS[attr.name]; !ok {
+			return ""
+		}
+	} else if column, ok = TRACEQL_ATTRIBUTE_COLUMNS[attr.name]; !ok {
+		// attributes are stored as strings
+		column = traceQLColumn{name: "attribute." + attr.name}
+	}
+	if e.op == tokRegex || e.op == tokNotRegex {
+		if column.numeric {
+			return ""
+		}
+		operator := "REGEXP"
+		if e.op == tokNotRegex {
+			operator = "NOT REGEXP"
+		}
+		return fmt.Sprintf("%s %s '%s'", column.name, operator, escapeSQLString(e.regex.String()))
+	}
+	if column.numeric {
+		var n float64
+		switch {
+		case value.typ == typeKind:
+			n = float64(traceQLKinds[value.s])
+		case value.typ == typeNumber || value.typ == typeDuration:
+			n = value.n
+		default:
+			return ""
+		}
+		return fmt.Sprintf("%s%s%s", column.name, tokenString(e.op), strconv.FormatFloat(n, 'f', -1, 64))
+	}
+	if value.typ != typeString || (e.op != tokEq && e.op != tokNeq) {
+		return ""
+	}
+	return fmt.Sprintf("%s%s'%s'", column.name, tokenString(e.op), escapeSQLString(value.s))
+}
+
+func escapeSQLString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `'`, `\'`)
+}
+
+// traceQLResult converts a matched trace into the tempo search response format
+func traceQLResult(t *traceData, spanSets []*spanSet, spansPerSpanSet int) map[string]interface{} {
+	result := map[string]interface{}{
+		"traceID":           t.TraceID,
+		"startTimeUnixNano": strconv.FormatInt(t.StartUs*1000, 10),
+		"durationMs":        (t.EndUs - t.StartUs) / 1000,
+	}
+	if t.Root != nil {
+		result["rootServiceName"] = t.Root.Service
+		result["rootTraceName"] = t.Root.Name
+	}
+	sets := make([]map[string]interface{}, 0, len(spanSets))
+	for _, ss := range spanSets {
+		spans := append([]*span{}, ss.Spans...)
+		sort.SliceStable(spans, func(i, j int) bool { return spans[i].StartUs < spans[j].StartUs })
+		if spansPerSpanSet > 0 && len(spans) > spansPerSpanSet {
+			spans = spans[:spansPerSpanSet]
+		}
+		respSpans := make([]map[string]interface{}, 0, len(spans))
+		for _, s := range spans {
+			attrs := []map[string]interface{}{
+				{"key": "service.name", "value": newStaticString(s.Service).toAttributeValue()},
+			}
+			for _, a := range ss.Selected {
+				v := attributeValue(a, s, t)
+				if v.typ == typeNil || a.name == "service.name" {
+					continue
+				}
+				// tempo returns selected attributes without scope
+				attrs = append(attrs, map[string]interface{}{"key": a.name, "value": v.toAttributeValue()})
+			}
+			respSpans = append(respSpans, map[string]interface{}{
+				"spanID":            s.SpanID,
+				"name":              s.Name,
+				"startTimeUnixNano": strconv.FormatInt(s.StartUs*1000, 10),
+				"durationNanos":     strconv.FormatInt(s.DurationUs*1000, 10),
+				"attributes":        attrs,
+			})
+		}
+		set := map[string]interface{}{
+			"spans":   respSpans,
+			"matched": len(ss.Spans),
+		}
+		if len(ss.Attributes) > 0 {
+			attrs := make([]map[string]interface{}, 0, len(ss.Attributes))
+			for _, a := range ss.Attributes {
+				attrs = append(attrs, map[string]interface{}{"key": a.key, "value": a.value.toAttributeValue()})
+			}
+			set["attributes"] = attrs
+		}
+		sets = append(sets, set)
+	}
+	if len(sets) > 0 {
+		result["spanSet"] = sets[0]
+	}
+	result["spanSets"] = sets
+	return result
+}
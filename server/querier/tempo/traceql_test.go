@@ -0,0 +1,197 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tempo
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParseTraceQL(t *testing.T) {
+	testCases := []struct {
+		query  string
+		output string
+	}{
+		{`{}`, `{ }`},
+		{`{ .service.name = "frontend" }`, `{ (.service.name = "frontend") }`},
+		{`{ span.http.status_code >= 500 && duration > 1.5s }`, `{ ((span.http.status_code >= 500) && (duration > 1.5s)) }`},
+		{`{ status = error || name =~ "GET.*" }`, `{ ((status = error) || (name =~ "GET.*")) }`},
+		{`{ kind = server } >> { resource.service.name != "db" }`, `({ (kind = server) } >> { (resource.service.name != "db") })`},
+		{`{ .a = 1 } && { .b = 2 } || { .c = 3 }`, `(({ (.a = 1) } && { (.b = 2) }) || { (.c = 3) })`},
+		{`{ .a = 1 } | count() > 2`, `{ (.a = 1) } | count() > 2`},
+		{`{ } | avg(duration) >= 100ms | select(.x, name)`, `{ } | avg(duration) >= 100ms | select(.x, name)`},
+	}
+	for _, tc := range testCases {
+		pl, err := ParseTraceQL(tc.query)
+		if err != nil {
+			t.Errorf("ParseTraceQL(%s) error: %s", tc.query, err)
+			continue
+		}
+		if pl.String() != tc.output {
+			t.Errorf("ParseTraceQL(%s) = %s, expected %s", tc.query, pl.String(), tc.output)
+		}
+	}
+
+	for _, query := range []string{``, `{`, `{ .a = }`, `{ .a = "x" } |`, `{ .a =~ "(" }`, `{ .a = 1 } | foo()`} {
+		if _, err := ParseTraceQL(query); err == nil {
+			t.Errorf("ParseTraceQL(%s) expected error", query)
+		}
+	}
+}
+
+func TestPushdownPipeline(t *testing.T) {
+	testCases := []struct {
+		query     string
+		condition string
+	}{
+		{`{}`, ``},
+		{`{ .service.name = "frontend" }`, `app_service='frontend'`},
+		{`{ .http.status_code >= 500 && duration > 1ms }`, `(response_code>=500 AND response_duration>1000)`},
+		{`{ status = error }`, `response_status IN (3, 4)`},
+		{`{ name =~ "GET.*" || .user = "a'b" }`, `(endpoint REGEXP '^(?:GET.*)$' OR attribute.user='a\'b')`},
+		{`{ .user > 1 }`, ``},
+		{`{ .a = "x" } >> { .b = 1 }`, `attribute.a='x'`},
+		{`{ .a = "x" } || { .b = 1 }`, ``},
+	}
+	for _, tc := range testCases {
+		pl, err := ParseTraceQL(tc.query)
+		if err != nil {
+			t.Errorf("ParseTraceQL(%s) error: %s", tc.query, err)
+			continue
+		}
+		if condition := pushdownPipeline(pl); condition != tc.condition {
+			t.Errorf("pushdownPipeline(%s) = %s, expected %s", tc.query, condition, tc.condition)
+		}
+	}
+}
+
+func newTestSpan(spanID, parentSpanID, service, name string, startUs, durationUs int64, status string) *span {
+	return &span{
+		TraceID:      "trace-1",
+		SpanID:       spanID,
+		ParentSpanID: parentSpanID,
+		Service:      service,
+		Name:         name,
+		StartUs:      startUs,
+		EndUs:        startUs + durationUs,
+		DurationUs:   durationUs,
+		Status:       status,
+		Fields:       map[string]static{"service.name": newStaticString(service)},
+		Attributes:   map[string]string{},
+	}
+}
+
+func TestEvaluateTrace(t *testing.T) {
+	root := newTestSpan("a", "", "frontend", "GET /", 0, 10000, "ok")
+	child1 := newTestSpan("b", "a", "cart", "GetCart", 1000, 3000, "ok")
+	child2 := newTestSpan("c", "a", "checkout", "PlaceOrder", 4000, 5000, "error")
+	grandChild := newTestSpan("d", "c", "db", "SELECT", 5000, 1000, "ok")
+	grandChild.Attributes["db.system"] = "mysql"
+	trace := newTraceData("trace-1", []*span{root, child1, child2, grandChild})
+
+	testCases := []struct {
+		query string
+		spans []string // selected span ids of all spansets, nil if the trace does not match
+	}{
+		{`{ .service.name = "cart" }`, []string{"b"}},
+		{`{ .service.name =~ "c.*" }`, []string{"b", "c"}},
+		{`{ .service.name =~ "art" }`, nil},
+		{`{ status = error && duration > 2ms }`, []string{"c"}},
+		{`{ status = error || .db.system = "mysql" }`, []string{"c", "d"}},
+		{`{ .service.name = "cart" } && { .service.name = "db" }`, []string{"b", "d"}},
+		{`{ .service.name = "cart" } && { .service.name = "nothing" }`, nil},
+		{`{ .service.name = "frontend" } > { }`, []string{"b", "c"}},
+		{`{ .service.name = "frontend" } >> { .db.system = "mysql" }`, []string{"d"}},
+		{`{ .service.name = "cart" } >> { }`, nil},
+		{`{ .service.name = "cart" } ~ { }`, []string{"c"}},
+		{`{ rootServiceName = "frontend" && traceDuration >= 10ms && kind = unspecified }`, []string{"a", "b", "c", "d"}},
+		{`{ } | count() > 3`, []string{"a", "b", "c", "d"}},
+		{`{ } | count() > 4`, nil},
+		{`{ .service.name =~ "cart|checkout" } | avg(duration) = 4ms`, []string{"b", "c"}},
+	}
+	for _, tc := range testCases {
+		pl, err := ParseTraceQL(tc.query)
+		if err != nil {
+			t.Errorf("ParseTraceQL(%s) error: %s", tc.query, err)
+			continue
+		}
+		var spans []string
+		for _, ss := range evaluateTrace(pl, trace) {
+			for _, s := range ss.Spans {
+				spans = append(spans, s.SpanID)
+			}
+		}
+		sort.Strings(spans)
+		if len(spans) != len(tc.spans) {
+			t.Errorf("evaluateTrace(%s) = %v, expected %v", tc.query, spans, tc.spans)
+			continue
+		}
+		for i := range spans {
+			if spans[i] != tc.spans[i] {
+				t.Errorf("evaluateTrace(%s) = %v, expected %v", tc.query, spans, tc.spans)
+				break
+			}
+		}
+	}
+
+	pl, _ := ParseTraceQL(`{ .service.name = "db" } | select(.db.system, name)`)
+	spanSets := evaluateTrace(pl, trace)
+	result := traceQLResult(trace, spanSets, 3)
+	if result["rootServiceName"] != "frontend" || result["durationMs"] != int64(10) {
+		t.Errorf("traceQLResult = %v", result)
+	}
+	spanSet := result["spanSet"].(map[string]interface{})
+	if spanSet["matched"] != 1 {
+		t.Errorf("traceQLResult spanSet = %v", spanSet)
+	}
+}
+
+func TestTraceDataSignalSource(t *testing.T) {
+	root := newTestSpan("a", "", "frontend", "GET /", 0, 10000, "ok")
+	root.SignalSource = SIGNAL_SOURCE_OTEL
+	otelChild := newTestSpan("b", "a", "cart", "GetCart", 1000, 3000, "ok")
+	otelChild.SignalSource = SIGNAL_SOURCE_OTEL
+	// the same span captured by ebpf on the client and server side
+	ebpfClient := newTestSpan("b", "a", "cart", "GET /cart", 900, 3200, "ok")
+	ebpfClient.SignalSource = SIGNAL_SOURCE_EBPF
+	ebpfServer := newTestSpan("b", "a", "cart", "GET /cart", 950, 3100, "ok")
+	ebpfServer.SignalSource = SIGNAL_SOURCE_EBPF
+	ebpfGrandChild := newTestSpan("c", "b", "db", "SELECT", 1100, 500, "ok")
+	ebpfGrandChild.SignalSource = SIGNAL_SOURCE_EBPF
+	trace := newTraceData("trace-1", []*span{ebpfGrandChild, ebpfServer, otelChild, root, ebpfClient})
+
+	if trace.Root != root {
+		t.Errorf("root = %+v, expected %+v", trace.Root, root)
+	}
+	if p := trace.parent(ebpfGrandChild); p != ebpfClient {
+		t.Errorf("parent of ebpf span = %+v, expected the earliest ebpf span %+v", p, ebpfClient)
+	}
+	for _, s := range []*span{otelChild, ebpfClient, ebpfServer} {
+		if p := trace.parent(s); p != root {
+			t.Errorf("parent of %+v = %+v, expected %+v", s, p, root)
+		}
+	}
+
+	pl, _ := ParseTraceQL(`{ .service.name = "frontend" } > { }`)
+	matched := 0
+	for _, ss := range evaluateTrace(pl, trace) {
+		matched += len(ss.Spans)
+	}
+	if matched != 3 {
+		t.Errorf("matched %d child spans, expected 3", matched)
+	}
+}